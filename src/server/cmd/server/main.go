@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/classius/server/internal/middleware"
 	"github.com/classius/server/internal/services"
 	"github.com/spf13/viper"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -63,8 +65,49 @@ func main() {
 	
 	bookService := services.NewBookService(database, uploadPath, maxFileSize)
 
+	// Initialize Dictionary service
+	dictionaryService := services.NewDictionaryService(database, viper.GetString("dictionary.path"))
+	log.Printf("📚 Loaded %d dictionaries", len(dictionaryService.Sources()))
+
+	// Initialize Review service, delivering digests to a webhook if configured
+	var digestChannel services.DigestChannel
+	if webhookURL := viper.GetString("review.digest_webhook_url"); webhookURL != "" {
+		digestChannel = services.NewWebhookDigestChannel(webhookURL)
+	}
+	reviewService := services.NewReviewService(database, digestChannel)
+
+	// Initialize Attachment service, purging files of deleted annotations hourly
+	attachmentService := services.NewAnnotationAttachmentService(database,
+		filepath.Join(uploadPath, "attachments"),
+		viper.GetInt64("attachments.max_file_size"),
+		viper.GetInt64("attachments.user_quota"),
+		viper.GetDuration("attachments.deleted_retention"))
+	go attachmentService.PurgeEvery(context.Background(), time.Hour)
+
+	// Aggregate popular highlights across readers periodically
+	popularHighlightService := services.NewPopularHighlightService(database, viper.GetInt("popular_highlights.min_readers"))
+	go popularHighlightService.AggregateEvery(context.Background(), viper.GetDuration("popular_highlights.interval"))
+
+	// Initialize Marketplace service with the configured payment provider
+	var paymentProvider services.PaymentProvider
+	switch name := viper.GetString("marketplace.provider"); name {
+	case "fake":
+		paymentProvider = services.NewFakePaymentProvider(viper.GetString("marketplace.webhook_secret"))
+		log.Println("💳 Using the fake payment provider; payments are simulated")
+	case "none":
+		log.Println("💳 No payment provider; paid notes cannot be bought")
+	case "":
+		log.Fatal("marketplace.provider is not set: configure a payment provider, \"fake\" to simulate payments in development, or \"none\" to disable paid notes")
+	default:
+		log.Fatalf("Unknown payment provider %q", name)
+	}
+	marketplaceService := services.NewMarketplaceService(database, paymentProvider,
+		viper.GetString("marketplace.currency"),
+		viper.GetInt("marketplace.platform_fee_percent"),
+		viper.GetDuration("marketplace.refund_window"))
+
 	// Initialize router
-	router := setupRouter(sageService, bookService)
+	router := setupRouter(database, sageService, bookService, dictionaryService, reviewService, attachmentService, marketplaceService, popularHighlightService)
 
 	// Server configuration
 	port := viper.GetString("server.port")
@@ -131,6 +174,33 @@ func loadConfig() {
 	viper.SetDefault("storage.upload_path", "./uploads")
 	viper.SetDefault("storage.max_file_size", 104857600) // 100MB
 
+	// Dictionary defaults
+	viper.SetDefault("dictionary.path", "./dictionaries")
+
+	// Review defaults
+	viper.SetDefault("review.digest_webhook_url", "")
+
+	// Annotation defaults
+	viper.SetDefault("annotations.bulk_undo_retention", "168h") // How long bulk actions can be undone
+
+	// Attachment defaults
+	viper.SetDefault("attachments.max_file_size", 26214400) // 25MB
+	viper.SetDefault("attachments.user_quota", 1073741824) // 1GB
+	viper.SetDefault("attachments.deleted_retention", "720h") // How long files of deleted annotations are kept
+
+	// Marketplace defaults
+	viper.SetDefault("marketplace.webhook_secret", "")
+	viper.SetDefault("marketplace.currency", "usd")
+	viper.SetDefault("marketplace.platform_fee_percent", 20)
+	viper.SetDefault("marketplace.refund_window", "336h") // How long buyers can refund a purchase
+
+	// Publishing defaults
+	viper.SetDefault("publishing.review_threshold", 100) // Downloads after which new versions of a free note are reviewed
+
+	// Popular highlight defaults
+	viper.SetDefault("popular_highlights.min_readers", 5) // Readers who must highlight a passage before it is shown
+	viper.SetDefault("popular_highlights.interval", "6h")  // How often popular highlights are recomputed
+
 	// Read environment variables
 	viper.AutomaticEnv()
 
@@ -140,7 +210,7 @@ func loadConfig() {
 	}
 }
 
-func setupRouter(sageService *services.SageService, bookService *services.BookService) *gin.Engine {
+func setupRouter(database *gorm.DB, sageService *services.SageService, bookService *services.BookService, dictionaryService *services.DictionaryService, reviewService *services.ReviewService, attachmentService *services.AnnotationAttachmentService, marketplaceService *services.MarketplaceService, popularHighlightService *services.PopularHighlightService) *gin.Engine {
 	// Set Gin mode
 	if viper.GetString("environment") == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -175,6 +245,10 @@ func setupRouter(sageService *services.SageService, bookService *services.BookSe
 			auth.POST("/refresh", handlers.RefreshToken)
 		}
 
+		// Payment provider webhooks, verified by signature
+		marketplaceHandlers := handlers.NewMarketplaceHandlers(marketplaceService)
+		api.POST("/payments/webhook/:provider", marketplaceHandlers.Webhook)
+
 		// Protected routes
 		protected := api.Group("/")
 		protected.Use(middleware.AuthRequired())
@@ -206,6 +280,11 @@ func setupRouter(sageService *services.SageService, bookService *services.BookSe
 
 			// Book routes
 			bookHandlers := handlers.NewBookHandlers(bookService)
+			publishedNoteService := services.NewPublishedNoteService(database, viper.GetInt("publishing.review_threshold"))
+			publishedNoteHandlers := handlers.NewPublishedNoteHandlers(publishedNoteService)
+			discussionHandlers := handlers.NewDiscussionHandlers(services.NewDiscussionService(database))
+			annotationLayerHandlers := handlers.NewAnnotationLayerHandlers(services.NewAnnotationLayerService(database))
+			popularHighlightHandlers := handlers.NewPopularHighlightHandlers(popularHighlightService)
 			books := protected.Group("/books")
 			{
 				books.GET("/", bookHandlers.GetBooks)
@@ -220,9 +299,98 @@ func setupRouter(sageService *services.SageService, bookService *services.BookSe
 				books.GET("/:id/download", bookHandlers.DownloadBook)
 				books.GET("/:id/content", bookHandlers.GetBookContent)
 				books.GET("/:id/text", bookHandlers.GetBookText)
+
+				// Concordance and word-frequency explorer
+				concordanceHandlers := handlers.NewConcordanceHandlers(bookService, services.NewConcordanceService(database))
+				books.GET("/:id/concordance", concordanceHandlers.GetConcordance)
+				books.GET("/:id/concordance/frequencies", concordanceHandlers.GetFrequencies)
+				books.GET("/:id/concordance/collocations", concordanceHandlers.GetCollocations)
+				books.GET("/:id/concordance/distribution", concordanceHandlers.GetDistribution)
+				books.POST("/:id/concordance/rebuild", concordanceHandlers.RebuildConcordance)
+
+				// Overlays from subscribed published notes
+				books.GET("/:id/overlays", publishedNoteHandlers.BookOverlays)
+
+				// Discussions anchored to passages, placed in the user's copy
+				books.GET("/:id/discussions", discussionHandlers.BookDiscussions)
+
+				// Annotations shared with groups and the public, by layer
+				books.GET("/:id/layers", annotationLayerHandlers.ListLayers)
+				books.GET("/:id/shared-annotations", annotationLayerHandlers.SharedAnnotations)
+
+				// Passages many readers of the text highlighted
+				books.GET("/:id/popular-highlights", popularHighlightHandlers.BookPopularHighlights)
+			}
+
+			// Parallel text routes (original/translation pairs)
+			parallelHandlers := handlers.NewParallelHandlers(services.NewAlignmentService(database))
+			parallel := protected.Group("/parallel")
+			{
+				parallel.GET("/", parallelHandlers.GetPairs)
+				parallel.POST("/", parallelHandlers.CreatePair)
+				parallel.GET("/:id", parallelHandlers.GetPair)
+				parallel.DELETE("/:id", parallelHandlers.DeletePair)
+				parallel.POST("/:id/align", parallelHandlers.AlignPair)
+				parallel.GET("/:id/segments", parallelHandlers.GetSegments)
+				parallel.POST("/:id/annotations/:annotation_id/carry", parallelHandlers.CarryAnnotation)
+			}
+
+			// Dictionary routes
+			dictionaryHandlers := handlers.NewDictionaryHandlers(bookService, dictionaryService)
+			dictionary := protected.Group("/dictionary")
+			{
+				dictionary.GET("/lookup", dictionaryHandlers.Lookup)
+				dictionary.GET("/sources", dictionaryHandlers.GetSources)
+				dictionary.GET("/lookups", dictionaryHandlers.GetLookups)
+				dictionary.GET("/vocabulary", dictionaryHandlers.GetVocabulary)
+			}
+
+			// Vocabulary routes
+			vocabularyHandlers := handlers.NewVocabularyHandlers(services.NewVocabularyService(database))
+			vocab := protected.Group("/vocab")
+			{
+				vocab.GET("/", vocabularyHandlers.GetWords)
+				vocab.POST("/", vocabularyHandlers.AddWord)
+				vocab.GET("/review", vocabularyHandlers.GetReviewQueue)
+				vocab.GET("/stats", vocabularyHandlers.GetStats)
+				vocab.GET("/export", vocabularyHandlers.ExportWords)
+				vocab.GET("/:id", vocabularyHandlers.GetWord)
+				vocab.PUT("/:id", vocabularyHandlers.UpdateWord)
+				vocab.DELETE("/:id", vocabularyHandlers.DeleteWord)
+				vocab.POST("/:id/grade", vocabularyHandlers.GradeWord)
+			}
+
+			// Daily review routes
+			reviewHandlers := handlers.NewReviewHandlers(reviewService)
+			review := protected.Group("/review")
+			{
+				review.GET("/daily", reviewHandlers.GetDaily)
+				review.GET("/digest", reviewHandlers.GetDigest)
+				review.POST("/digest/send", reviewHandlers.SendDigest)
+				review.GET("/items", reviewHandlers.GetItems)
+				review.PUT("/items/:id", reviewHandlers.UpdateItem)
+				review.POST("/items/:id/grade", reviewHandlers.GradeItem)
+				review.GET("/settings", reviewHandlers.GetSettings)
+				review.PUT("/settings", reviewHandlers.SaveSetting)
+				review.DELETE("/settings/:id", reviewHandlers.DeleteSetting)
+			}
+
+			// Unified sync routes
+			syncHandlers := handlers.NewSyncHandlers(services.NewChangeFeedService(database, bookService))
+			sync := protected.Group("/sync")
+			{
+				sync.GET("/changes", syncHandlers.GetChanges)
+				sync.POST("/push", syncHandlers.Push)
 			}
 
 			// Annotation routes
+			annotationSyncHandlers := handlers.NewAnnotationSyncHandlers(services.NewAnnotationSyncService(database))
+			webAnnotationHandlers := handlers.NewWebAnnotationHandlers(services.NewWebAnnotationService(database))
+			clippingImportHandlers := handlers.NewClippingImportHandlers(services.NewClippingImportService(database))
+			annotationHistoryHandlers := handlers.NewAnnotationHistoryHandlers(services.NewAnnotationHistoryService(database))
+			bulkOperationHandlers := handlers.NewBulkOperationHandlers(services.NewBulkOperationService(database, viper.GetDuration("annotations.bulk_undo_retention")))
+			annotationLinkHandlers := handlers.NewAnnotationLinkHandlers(services.NewAnnotationLinkService(database))
+			attachmentHandlers := handlers.NewAnnotationAttachmentHandlers(attachmentService)
 			annotations := protected.Group("/annotations")
 			{
 				// Enhanced annotation management
@@ -230,12 +398,119 @@ func setupRouter(sageService *services.SageService, bookService *services.BookSe
 				annotations.POST("/", handlers.CreateAnnotationEnhanced)
 				annotations.PUT("/:id", handlers.UpdateAnnotationEnhanced)
 				annotations.DELETE("/:id", handlers.DeleteAnnotationEnhanced)
+				annotations.GET("/:id/svg", handlers.GetAnnotationSVG)
 				
+				// Revision history
+				annotations.GET("/:id/history", annotationHistoryHandlers.History)
+				annotations.GET("/:id/diff", annotationHistoryHandlers.Diff)
+				annotations.POST("/:id/restore/:rev", annotationHistoryHandlers.Restore)
+
 				// Bulk operations
-				annotations.POST("/bulk", handlers.BulkAnnotationActions)
+				annotations.POST("/bulk", bulkOperationHandlers.Apply)
+				annotations.POST("/bulk/:op_id/undo", bulkOperationHandlers.Undo)
+
+				// Links and the annotation graph
+				annotations.POST("/:id/links", annotationLinkHandlers.CreateLink)
+				annotations.GET("/:id/links", annotationLinkHandlers.Links)
+				annotations.GET("/:id/graph", annotationLinkHandlers.Graph)
+				annotations.DELETE("/links/:link_id", annotationLinkHandlers.DeleteLink)
+				annotations.GET("/graph/export", annotationLinkHandlers.ExportGraph)
+
+				// Attachments
+				annotations.POST("/:id/attachments", attachmentHandlers.Upload)
+				annotations.GET("/:id/attachments", attachmentHandlers.List)
+				annotations.GET("/attachments/usage", attachmentHandlers.Usage)
+				annotations.GET("/attachments/:attachment_id", attachmentHandlers.Download)
+				annotations.GET("/attachments/:attachment_id/thumbnail", attachmentHandlers.Thumbnail)
+				annotations.DELETE("/attachments/:attachment_id", attachmentHandlers.Delete)
+
+				// Replies to shared annotations
+				annotations.GET("/:id/replies", annotationLayerHandlers.ListAnnotationReplies)
+				annotations.POST("/:id/replies", annotationLayerHandlers.CreateAnnotationReply)
+				annotations.DELETE("/:id/replies/:reply_id", annotationLayerHandlers.DeleteAnnotationReply)
+
+				// Multi-device sync
+				annotations.POST("/sync", annotationSyncHandlers.Sync)
 				
 				// Export functionality
 				annotations.GET("/export", handlers.ExportAnnotations)
+
+				// W3C Web Annotation interchange
+				annotations.GET("/export/jsonld", webAnnotationHandlers.Export)
+				annotations.POST("/import/jsonld", webAnnotationHandlers.Import)
+
+				// Kindle and KOReader imports
+				annotations.POST("/import", clippingImportHandlers.Import)
+			}
+
+			// Tag management across annotations and books
+			tagHandlers := handlers.NewTagHandlers(services.NewTagService(database))
+			tags := protected.Group("/tags")
+			{
+				tags.GET("/", tagHandlers.ListTags)
+				tags.POST("/rename", tagHandlers.RenameTag)
+				tags.POST("/merge", tagHandlers.MergeTags)
+				tags.POST("/aliases", tagHandlers.AddAlias)
+				tags.DELETE("/aliases/:id", tagHandlers.RemoveAlias)
+			}
+
+			// Published note collections and subscriptions
+			publishedNotes := protected.Group("/published-notes")
+			{
+				publishedNotes.GET("/", publishedNoteHandlers.Catalog)
+				publishedNotes.POST("/", publishedNoteHandlers.Create)
+				publishedNotes.GET("/mine", publishedNoteHandlers.Mine)
+				publishedNotes.GET("/subscriptions", publishedNoteHandlers.Subscriptions)
+				publishedNotes.GET("/:id", publishedNoteHandlers.Get)
+				publishedNotes.PUT("/:id", publishedNoteHandlers.Update)
+				publishedNotes.DELETE("/:id", publishedNoteHandlers.Delete)
+				publishedNotes.PUT("/:id/items", publishedNoteHandlers.SetItems)
+				publishedNotes.POST("/:id/publish", publishedNoteHandlers.Publish)
+				publishedNotes.POST("/:id/withdraw", publishedNoteHandlers.Withdraw)
+				publishedNotes.GET("/:id/versions/:version", publishedNoteHandlers.VersionOverlays)
+				publishedNotes.POST("/:id/subscribe", publishedNoteHandlers.Subscribe)
+				publishedNotes.DELETE("/:id/subscribe", publishedNoteHandlers.Unsubscribe)
+				publishedNotes.POST("/:id/ratings", publishedNoteHandlers.Rate)
+				publishedNotes.GET("/:id/ratings", publishedNoteHandlers.Ratings)
+			}
+
+			// Purchases of paid published notes
+			marketplace := protected.Group("/marketplace")
+			{
+				marketplace.POST("/checkout", marketplaceHandlers.Checkout)
+				marketplace.GET("/orders", marketplaceHandlers.ListOrders)
+				marketplace.GET("/orders/:id", marketplaceHandlers.GetOrder)
+				marketplace.POST("/orders/:id/refund", marketplaceHandlers.RefundOrder)
+				marketplace.GET("/entitlements", marketplaceHandlers.ListEntitlements)
+				marketplace.GET("/revenue", marketplaceHandlers.GetRevenue)
+				if marketplaceService.SimulatesPayments() && viper.GetString("environment") != "production" {
+					marketplace.POST("/orders/:id/simulate-payment", marketplaceHandlers.SimulatePayment)
+				}
+			}
+
+			// Scholar verification
+			scholarHandlers := handlers.NewScholarHandlers(services.NewScholarService(database), publishedNoteService)
+			scholars := protected.Group("/scholars")
+			{
+				scholars.POST("/applications", scholarHandlers.Apply)
+				scholars.GET("/applications", scholarHandlers.ListApplications)
+				scholars.DELETE("/applications/:id", scholarHandlers.WithdrawApplication)
+				scholars.GET("/:user_id", scholarHandlers.GetProfile)
+			}
+
+			// Admin review of scholar applications and submitted note versions
+			admin := protected.Group("/admin")
+			admin.Use(middleware.AdminRequired())
+			{
+				admin.GET("/scholar-applications", scholarHandlers.ListApplicationQueue)
+				admin.POST("/scholar-applications/:id/approve", scholarHandlers.ApproveApplication)
+				admin.POST("/scholar-applications/:id/reject", scholarHandlers.RejectApplication)
+				admin.POST("/scholars/:user_id/revoke", scholarHandlers.RevokeScholar)
+				admin.GET("/note-reviews", scholarHandlers.ListNoteReviews)
+				admin.GET("/note-reviews/:id", scholarHandlers.GetNoteReview)
+				admin.POST("/note-reviews/:id/approve", scholarHandlers.ApproveNoteVersion)
+				admin.POST("/note-reviews/:id/reject", scholarHandlers.RejectNoteVersion)
+				admin.GET("/review-audit", scholarHandlers.ListReviewAudit)
 			}
 
 			// Reading Progress routes
@@ -291,14 +566,75 @@ func setupRouter(sageService *services.SageService, bookService *services.BookSe
 				}
 			}
 
-			// Community routes (placeholder - to be implemented later)
-			// community := protected.Group("/community")
-			// {
-			//	community.GET("/discussions", handlers.GetDiscussions)
-			//	community.POST("/discussions", handlers.CreateDiscussion)
-			//	community.GET("/discussions/:id", handlers.GetDiscussion)
-			//	community.POST("/discussions/:id/comments", handlers.AddComment)
-			// }
+			// Reading groups
+			groupHandlers := handlers.NewGroupHandlers(services.NewGroupService(database))
+			groups := protected.Group("/groups")
+			{
+				groups.GET("/", groupHandlers.DiscoverGroups)
+				groups.POST("/", groupHandlers.CreateGroup)
+				groups.GET("/mine", groupHandlers.MyGroups)
+				groups.POST("/join/:token", groupHandlers.JoinByLink)
+				groups.GET("/invitations", groupHandlers.MyInvitations)
+				groups.POST("/invitations/:invitation_id/accept", groupHandlers.AcceptInvitation)
+				groups.POST("/invitations/:invitation_id/decline", groupHandlers.DeclineInvitation)
+				groups.GET("/:id", groupHandlers.GetGroup)
+				groups.PUT("/:id", groupHandlers.UpdateGroup)
+				groups.DELETE("/:id", groupHandlers.DeleteGroup)
+				groups.POST("/:id/join", groupHandlers.JoinGroup)
+				groups.POST("/:id/leave", groupHandlers.LeaveGroup)
+				groups.GET("/:id/members", groupHandlers.ListMembers)
+				groups.PUT("/:id/members/:user_id", groupHandlers.SetMemberRole)
+				groups.DELETE("/:id/members/:user_id", groupHandlers.RemoveMember)
+				groups.POST("/:id/invitations", groupHandlers.CreateInvitation)
+				groups.GET("/:id/invitations", groupHandlers.ListGroupInvitations)
+				groups.DELETE("/:id/invitations/:invitation_id", groupHandlers.RevokeInvitation)
+				groups.GET("/:id/books", groupHandlers.ListGroupBooks)
+				groups.POST("/:id/books", groupHandlers.AddGroupBook)
+				groups.PUT("/:id/books/:entry_id", groupHandlers.UpdateGroupBook)
+				groups.DELETE("/:id/books/:entry_id", groupHandlers.RemoveGroupBook)
+				groups.GET("/:id/layer", annotationLayerHandlers.GroupLayer)
+
+				// Reading schedules for books on the list
+				scheduleHandlers := handlers.NewScheduleHandlers(services.NewScheduleService(database))
+				groups.GET("/:id/schedules", scheduleHandlers.ListSchedules)
+				groups.POST("/:id/schedules", scheduleHandlers.CreateSchedule)
+				groups.GET("/:id/schedules/:schedule_id", scheduleHandlers.GetSchedule)
+				groups.PUT("/:id/schedules/:schedule_id", scheduleHandlers.UpdateSchedule)
+				groups.DELETE("/:id/schedules/:schedule_id", scheduleHandlers.DeleteSchedule)
+				groups.GET("/:id/schedules/:schedule_id/progress", scheduleHandlers.ScheduleProgress)
+			}
+
+			// Threaded discussions in groups and about books
+			discussions := protected.Group("/discussions")
+			{
+				discussions.GET("/", discussionHandlers.ListDiscussions)
+				discussions.POST("/", discussionHandlers.CreateDiscussion)
+				discussions.GET("/:id", discussionHandlers.GetDiscussion)
+				discussions.PUT("/:id", discussionHandlers.UpdateDiscussion)
+				discussions.DELETE("/:id", discussionHandlers.DeleteDiscussion)
+				discussions.GET("/:id/replies", discussionHandlers.ListReplies)
+				discussions.POST("/:id/replies", discussionHandlers.CreateReply)
+				discussions.POST("/:id/vote", discussionHandlers.Upvote)
+				discussions.DELETE("/:id/vote", discussionHandlers.WithdrawVote)
+			}
+
+			// Follows, public profiles and the activity feed
+			activityHandlers := handlers.NewActivityHandlers(services.NewActivityService(database))
+			users := protected.Group("/users")
+			{
+				users.GET("/:id/profile", activityHandlers.Profile)
+				users.POST("/:id/follow", activityHandlers.Follow)
+				users.DELETE("/:id/follow", activityHandlers.Unfollow)
+				users.GET("/:id/followers", activityHandlers.Followers)
+				users.GET("/:id/following", activityHandlers.Following)
+				users.POST("/:id/mute", activityHandlers.Mute)
+				users.DELETE("/:id/mute", activityHandlers.Unmute)
+			}
+			feed := protected.Group("/feed")
+			{
+				feed.GET("/", activityHandlers.Feed)
+				feed.GET("/mutes", activityHandlers.ListMutes)
+			}
 		}
 	}
 
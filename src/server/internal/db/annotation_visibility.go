@@ -0,0 +1,34 @@
+package db
+
+import (
+	_ "embed"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// annotationVisibilitySQL installs the trigger keeping annotations'
+// is_private in step with their visibility. It is idempotent, so it runs on
+// every migration.
+//
+//go:embed migrations/027_install_annotation_visibility_trigger.sql
+var annotationVisibilitySQL string
+
+// InstallAnnotationVisibilityTrigger (re)creates the annotation visibility
+// trigger
+func InstallAnnotationVisibilityTrigger(db *gorm.DB) error {
+	if err := db.Exec(annotationVisibilitySQL).Error; err != nil {
+		return fmt.Errorf("failed to install annotation visibility trigger: %w", err)
+	}
+	return nil
+}
+
+// BackfillAnnotationVisibility gives annotations created before visibility
+// existed the scope their is_private gave them
+func BackfillAnnotationVisibility(db *gorm.DB) error {
+	if err := db.Exec(`UPDATE annotations SET visibility = CASE WHEN is_private IS FALSE THEN 'public' ELSE 'private' END
+		WHERE visibility <> CASE WHEN is_private IS FALSE THEN 'public' ELSE 'private' END`).Error; err != nil {
+		return fmt.Errorf("failed to backfill annotation visibility: %w", err)
+	}
+	return nil
+}
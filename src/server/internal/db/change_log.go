@@ -0,0 +1,23 @@
+package db
+
+import (
+	_ "embed"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// changeLogTriggersSQL installs the triggers that write the sync change log.
+// It is idempotent, so it runs on every migration.
+//
+//go:embed migrations/012_install_change_log_triggers.sql
+var changeLogTriggersSQL string
+
+// InstallChangeLogTriggers (re)creates the change log functions and attaches
+// them to the synced tables
+func InstallChangeLogTriggers(db *gorm.DB) error {
+	if err := db.Exec(changeLogTriggersSQL).Error; err != nil {
+		return fmt.Errorf("failed to install change log triggers: %w", err)
+	}
+	return nil
+}
@@ -97,6 +97,11 @@ func InitRedis() *redis.Client {
 func AutoMigrate(db *gorm.DB) error {
 	log.Println("🔄 Running database migrations...")
 
+	// Annotations from before visibility existed are backfilled once its
+	// column is added
+	backfillVisibility := db.Migrator().HasTable(&models.Annotation{}) &&
+		!db.Migrator().HasColumn(&models.Annotation{}, "Visibility")
+
 	err := db.AutoMigrate(
 		&models.User{},
 		&models.Book{},
@@ -106,18 +111,67 @@ func AutoMigrate(db *gorm.DB) error {
 		&models.Annotation{},
 		&models.Bookmark{},
 		&models.SageConversation{},
-		// &models.ReadingGroup{},
-		// &models.GroupMember{},
-		// &models.Discussion{},
+		&models.ReadingGroup{},
+		&models.GroupMember{},
+		&models.Discussion{},
 		&models.PublishedNote{},
 		&models.NoteOverlay{},
 		&models.UserSession{},
+		&models.ConcordanceIndex{},
+		&models.ConcordanceTerm{},
+		&models.ParallelPair{},
+		&models.AlignedSegment{},
+		&models.WordLookup{},
+		&models.VocabularyWord{},
+		&models.VocabularyReview{},
+		&models.AnnotationReview{},
+		&models.ReviewSetting{},
+		&models.SyncCounter{},
+		&models.ChangeLogEntry{},
+		&models.UserPreference{},
+		&models.AnnotationRevision{},
+		&models.BulkOperation{},
+		&models.AnnotationLink{},
+		&models.TagAlias{},
+		&models.AnnotationAttachment{},
+		&models.PublishedNoteItem{},
+		&models.PublishedNoteVersion{},
+		&models.NoteSubscription{},
+		&models.NoteRating{},
+		&models.NoteOrder{},
+		&models.NoteEntitlement{},
+		&models.PaymentEvent{},
+		&models.ScholarApplication{},
+		&models.ReviewAuditEntry{},
+		&models.GroupInvitation{},
+		&models.GroupBook{},
+		&models.DiscussionVote{},
+		&models.ReadingSchedule{},
+		&models.ScheduleAssignment{},
+		&models.AnnotationReply{},
+		&models.PopularHighlight{},
+		&models.Follow{},
+		&models.UserMute{},
+		&models.ActivityEvent{},
+		&models.FeedItem{},
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	if backfillVisibility {
+		if err := BackfillAnnotationVisibility(db); err != nil {
+			return err
+		}
+	}
+	if err := InstallChangeLogTriggers(db); err != nil {
+		return err
+	}
+	if err := InstallAnnotationVisibilityTrigger(db); err != nil {
+		return err
+	}
+
 	log.Println("✅ Database migrations completed")
 	return nil
 }
@@ -0,0 +1,36 @@
+-- Migration: 005_create_concordance_index.sql
+-- Description: Create tables for the per-book concordance and word-frequency index
+
+-- Create concordance_indexes table (one row per indexed book)
+CREATE TABLE IF NOT EXISTS concordance_indexes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    book_id UUID NOT NULL UNIQUE REFERENCES books(id) ON DELETE CASCADE,
+    language VARCHAR(10) NOT NULL,
+    token_count INTEGER DEFAULT 0,
+    type_count INTEGER DEFAULT 0,
+    chapters JSONB, -- Chapter boundaries as rune offsets into book_contents.full_text
+    built_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create concordance_terms table (postings for each normalized word form)
+CREATE TABLE IF NOT EXISTS concordance_terms (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    term VARCHAR(100) NOT NULL,
+    stem VARCHAR(100) NOT NULL,
+    is_stopword BOOLEAN DEFAULT FALSE,
+    count INTEGER NOT NULL,
+    positions JSONB, -- Rune offsets of each occurrence
+    ordinals JSONB   -- Token numbers of each occurrence
+);
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_concordance_terms_book_term ON concordance_terms(book_id, term);
+CREATE INDEX IF NOT EXISTS idx_concordance_terms_book_stem ON concordance_terms(book_id, stem);
+CREATE INDEX IF NOT EXISTS idx_concordance_terms_count ON concordance_terms(count);
+
+CREATE TRIGGER update_concordance_indexes_updated_at 
+    BEFORE UPDATE ON concordance_indexes
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,47 @@
+-- Migration: 006_create_parallel_alignment.sql
+-- Description: Create tables for original/translation pairs and their alignment
+
+-- Create parallel_pairs table (an original edition linked with a translation)
+CREATE TABLE IF NOT EXISTS parallel_pairs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    original_book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    translation_book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'aligning', 'aligned', 'error')),
+    error TEXT,
+    length_ratio DOUBLE PRECISION DEFAULT 0,
+    segment_count INTEGER DEFAULT 0,
+    aligned_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    UNIQUE(user_id, original_book_id, translation_book_id)
+);
+
+-- Create aligned_segments table (rune offsets into each book's full text)
+CREATE TABLE IF NOT EXISTS aligned_segments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    pair_id UUID NOT NULL REFERENCES parallel_pairs(id) ON DELETE CASCADE,
+    level VARCHAR(20) NOT NULL CHECK (level IN ('chapter', 'paragraph', 'sentence')),
+    chapter_index INTEGER DEFAULT 0,
+    ordinal INTEGER NOT NULL,
+    source_start INTEGER NOT NULL,
+    source_end INTEGER NOT NULL,
+    target_start INTEGER NOT NULL,
+    target_end INTEGER NOT NULL,
+    bead_type VARCHAR(10), -- 1-1, 2-1, 1-0, ...
+    marker VARCHAR(50),    -- Chapter/verse marker shared by both sides, if any
+    cost DOUBLE PRECISION DEFAULT 0
+);
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_parallel_pairs_user_id ON parallel_pairs(user_id);
+CREATE INDEX IF NOT EXISTS idx_parallel_pairs_original_book_id ON parallel_pairs(original_book_id);
+CREATE INDEX IF NOT EXISTS idx_parallel_pairs_translation_book_id ON parallel_pairs(translation_book_id);
+CREATE INDEX IF NOT EXISTS idx_aligned_segments_pair_level ON aligned_segments(pair_id, level, ordinal);
+CREATE INDEX IF NOT EXISTS idx_aligned_segments_source ON aligned_segments(pair_id, source_start);
+CREATE INDEX IF NOT EXISTS idx_aligned_segments_target ON aligned_segments(pair_id, target_start);
+
+CREATE TRIGGER update_parallel_pairs_updated_at 
+    BEFORE UPDATE ON parallel_pairs
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,24 @@
+-- Migration: 007_create_word_lookups.sql
+-- Description: Create table logging dictionary lookups made while reading
+
+-- Create word_lookups table
+CREATE TABLE IF NOT EXISTS word_lookups (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    book_id UUID REFERENCES books(id) ON DELETE CASCADE,
+    position INTEGER, -- Rune offset into book_contents.full_text
+    page_number INTEGER DEFAULT 0,
+    word VARCHAR(100) NOT NULL,
+    language VARCHAR(10) NOT NULL,
+    lemma VARCHAR(100),
+    part_of_speech VARCHAR(30),
+    definition TEXT,
+    context TEXT,
+    found BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_word_lookups_user_lemma ON word_lookups(user_id, lemma);
+CREATE INDEX IF NOT EXISTS idx_word_lookups_book_id ON word_lookups(book_id);
+CREATE INDEX IF NOT EXISTS idx_word_lookups_created_at ON word_lookups(created_at);
@@ -0,0 +1,60 @@
+-- Migration: 008_create_vocabulary.sql
+-- Description: Create tables for saved vocabulary words and their review history
+
+-- Create vocabulary_words table (a saved word plus its spaced-repetition schedule)
+CREATE TABLE IF NOT EXISTS vocabulary_words (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    book_id UUID REFERENCES books(id) ON DELETE SET NULL,
+    lookup_id UUID REFERENCES word_lookups(id) ON DELETE SET NULL,
+    word VARCHAR(100) NOT NULL,
+    lemma VARCHAR(100) NOT NULL,
+    language VARCHAR(10) NOT NULL,
+    part_of_speech VARCHAR(30),
+    definition TEXT,
+    context TEXT,
+    position INTEGER, -- Rune offset into book_contents.full_text
+    page_number INTEGER DEFAULT 0,
+    notes TEXT,
+    suspended BOOLEAN DEFAULT FALSE,
+    algorithm VARCHAR(10) DEFAULT 'sm2' CHECK (algorithm IN ('sm2', 'fsrs')),
+    state VARCHAR(20) DEFAULT 'new' CHECK (state IN ('new', 'learning', 'review', 'relearning')),
+    due_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    interval_days DOUBLE PRECISION DEFAULT 0,
+    ease_factor DOUBLE PRECISION DEFAULT 2.5,
+    stability DOUBLE PRECISION DEFAULT 0,
+    difficulty DOUBLE PRECISION DEFAULT 0,
+    repetitions INTEGER DEFAULT 0,
+    lapses INTEGER DEFAULT 0,
+    last_reviewed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+-- Create vocabulary_reviews table (one row per graded review)
+CREATE TABLE IF NOT EXISTS vocabulary_reviews (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    word_id UUID NOT NULL REFERENCES vocabulary_words(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    grade INTEGER NOT NULL CHECK (grade BETWEEN 1 AND 4),
+    algorithm VARCHAR(10),
+    state_before VARCHAR(20),
+    elapsed_days DOUBLE PRECISION DEFAULT 0,
+    interval_days DOUBLE PRECISION DEFAULT 0,
+    duration_ms INTEGER DEFAULT 0,
+    reviewed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_vocabulary_words_user_id ON vocabulary_words(user_id);
+CREATE INDEX IF NOT EXISTS idx_vocabulary_words_user_lemma ON vocabulary_words(user_id, language, lemma);
+CREATE INDEX IF NOT EXISTS idx_vocabulary_words_book_id ON vocabulary_words(book_id);
+CREATE INDEX IF NOT EXISTS idx_vocabulary_words_due ON vocabulary_words(user_id, due_at) WHERE deleted_at IS NULL AND suspended = FALSE;
+CREATE INDEX IF NOT EXISTS idx_vocabulary_words_state ON vocabulary_words(state);
+CREATE INDEX IF NOT EXISTS idx_vocabulary_reviews_word_id ON vocabulary_reviews(word_id);
+CREATE INDEX IF NOT EXISTS idx_vocabulary_reviews_user_time ON vocabulary_reviews(user_id, reviewed_at);
+
+CREATE TRIGGER update_vocabulary_words_updated_at 
+    BEFORE UPDATE ON vocabulary_words
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,57 @@
+-- Migration: 009_create_annotation_reviews.sql
+-- Description: Create tables for the spaced-repetition review of highlights and notes
+
+-- Create annotation_reviews table (review schedule of one highlight or note)
+CREATE TABLE IF NOT EXISTS annotation_reviews (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    annotation_id UUID NOT NULL UNIQUE REFERENCES annotations(id) ON DELETE CASCADE,
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    favorite BOOLEAN DEFAULT FALSE,
+    discarded BOOLEAN DEFAULT FALSE,
+    discarded_at TIMESTAMP,
+    algorithm VARCHAR(10) DEFAULT 'sm2' CHECK (algorithm IN ('sm2', 'fsrs')),
+    state VARCHAR(20) DEFAULT 'new' CHECK (state IN ('new', 'learning', 'review', 'relearning')),
+    due_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    interval_days DOUBLE PRECISION DEFAULT 0,
+    ease_factor DOUBLE PRECISION DEFAULT 2.5,
+    stability DOUBLE PRECISION DEFAULT 0,
+    difficulty DOUBLE PRECISION DEFAULT 0,
+    repetitions INTEGER DEFAULT 0,
+    lapses INTEGER DEFAULT 0,
+    last_reviewed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+-- Create review_settings table (review frequency for all annotations, a book or a tag)
+CREATE TABLE IF NOT EXISTS review_settings (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('default', 'book', 'tag')),
+    book_id UUID REFERENCES books(id) ON DELETE CASCADE,
+    tag VARCHAR(100),
+    interval_multiplier DOUBLE PRECISION DEFAULT 1 CHECK (interval_multiplier BETWEEN 0.1 AND 10),
+    excluded BOOLEAN DEFAULT FALSE,
+    daily_limit INTEGER DEFAULT 0,
+    algorithm VARCHAR(10),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_annotation_reviews_user_due ON annotation_reviews(user_id, due_at) WHERE discarded = FALSE;
+CREATE INDEX IF NOT EXISTS idx_annotation_reviews_book_id ON annotation_reviews(book_id);
+CREATE INDEX IF NOT EXISTS idx_annotation_reviews_state ON annotation_reviews(state);
+CREATE INDEX IF NOT EXISTS idx_review_settings_user_id ON review_settings(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_review_settings_scope ON review_settings(user_id, scope, COALESCE(book_id::text, ''), LOWER(COALESCE(tag, ''))) WHERE deleted_at IS NULL;
+
+CREATE TRIGGER update_annotation_reviews_updated_at 
+    BEFORE UPDATE ON annotation_reviews
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_review_settings_updated_at 
+    BEFORE UPDATE ON review_settings
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
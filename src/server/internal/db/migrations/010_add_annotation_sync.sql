@@ -0,0 +1,16 @@
+-- Migration: 010_add_annotation_sync.sql
+-- Description: Add revisions and sync positions to annotations for multi-device sync
+
+-- Per-record revision, per-field revisions and position in the owner's sync stream
+ALTER TABLE annotations ADD COLUMN IF NOT EXISTS revision INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE annotations ADD COLUMN IF NOT EXISTS field_revisions JSONB;
+ALTER TABLE annotations ADD COLUMN IF NOT EXISTS sync_seq BIGINT NOT NULL DEFAULT 0;
+
+-- Create sync_counters table (last sync sequence number issued per user)
+CREATE TABLE IF NOT EXISTS sync_counters (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    seq BIGINT NOT NULL DEFAULT 0
+);
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_annotations_sync ON annotations(user_id, sync_seq, id);
@@ -0,0 +1,31 @@
+-- Migration: 011_create_change_log.sql
+-- Description: Create the per-user change log behind the unified sync feed, and persist user preferences
+
+-- Create user_preferences table (theme, notification, reading and privacy settings)
+CREATE TABLE IF NOT EXISTS user_preferences (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    settings JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+-- Create change_log table (latest change to each synced entity, ordered by sync sequence)
+CREATE TABLE IF NOT EXISTS change_log (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    entity_type VARCHAR(30) NOT NULL,
+    entity_id UUID NOT NULL,
+    seq BIGINT NOT NULL,
+    operation VARCHAR(10) NOT NULL CHECK (operation IN ('upsert', 'delete')),
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, entity_type, entity_id)
+);
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_change_log_user_seq ON change_log(user_id, seq, entity_id);
+CREATE INDEX IF NOT EXISTS idx_user_preferences_deleted_at ON user_preferences(deleted_at);
+
+CREATE TRIGGER update_user_preferences_updated_at 
+    BEFORE UPDATE ON user_preferences
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
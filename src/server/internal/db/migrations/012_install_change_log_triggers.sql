@@ -0,0 +1,138 @@
+-- Migration: 012_install_change_log_triggers.sql
+-- Description: Record changes to synced tables in the change log, in the transaction making them
+-- This migration is idempotent and is also applied on every AutoMigrate.
+
+-- next_sync_seq issues the user's next sync sequence. The sync_counters row
+-- stays locked until commit, so sequence order matches commit order.
+CREATE OR REPLACE FUNCTION next_sync_seq(p_user_id UUID)
+RETURNS BIGINT AS $$
+DECLARE
+    next_seq BIGINT;
+BEGIN
+    INSERT INTO sync_counters (user_id, seq) VALUES (p_user_id, 1)
+    ON CONFLICT (user_id) DO UPDATE SET seq = sync_counters.seq + 1
+    RETURNING seq INTO next_seq;
+    RETURN next_seq;
+END;
+$$ LANGUAGE plpgsql;
+
+-- record_change moves the entity's change log entry to sync sequence p_seq,
+-- or to the user's next one when p_seq is NULL
+CREATE OR REPLACE FUNCTION record_change(p_user_id UUID, p_entity_type TEXT, p_entity_id UUID, p_operation TEXT, p_seq BIGINT)
+RETURNS VOID AS $$
+BEGIN
+    -- Rows removed along with their user have nobody left to sync to
+    IF p_user_id IS NULL OR NOT EXISTS (SELECT 1 FROM users WHERE id = p_user_id) THEN
+        RETURN;
+    END IF;
+
+    IF p_seq IS NULL THEN
+        p_seq := next_sync_seq(p_user_id);
+    END IF;
+
+    INSERT INTO change_log (user_id, entity_type, entity_id, seq, operation, changed_at)
+    VALUES (p_user_id, p_entity_type, p_entity_id, p_seq, p_operation, NOW())
+    ON CONFLICT (user_id, entity_type, entity_id) DO UPDATE
+    SET seq = EXCLUDED.seq, operation = EXCLUDED.operation, changed_at = EXCLUDED.changed_at;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Earlier installs had a record_change without p_seq
+DROP FUNCTION IF EXISTS record_change(UUID, TEXT, UUID, TEXT);
+
+-- stamp_sync_seq gives a row with its own sync_seq column (annotations) the
+-- next sync sequence on every write. log_entity_change then logs the row at
+-- that sequence, so the row and its change log entry share one number.
+CREATE OR REPLACE FUNCTION stamp_sync_seq()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.user_id IS NOT NULL THEN
+        NEW.sync_seq := next_sync_seq(NEW.user_id);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- log_entity_change logs a row of a table with id, user_id and deleted_at
+-- columns. TG_ARGV[0] is the entity type. Soft-deleted rows are tombstones.
+CREATE OR REPLACE FUNCTION log_entity_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    row_data JSONB;
+    operation TEXT := 'upsert';
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_data := to_jsonb(OLD);
+        operation := 'delete';
+    ELSE
+        row_data := to_jsonb(NEW);
+        IF row_data->>'deleted_at' IS NOT NULL THEN
+            operation := 'delete';
+        END IF;
+    END IF;
+
+    -- Rows stamped by stamp_sync_seq are logged at their own sequence
+    IF TG_OP <> 'DELETE' AND row_data ? 'sync_seq' THEN
+        PERFORM record_change((row_data->>'user_id')::uuid, TG_ARGV[0], (row_data->>'id')::uuid, operation, (row_data->>'sync_seq')::bigint);
+    ELSE
+        PERFORM record_change((row_data->>'user_id')::uuid, TG_ARGV[0], (row_data->>'id')::uuid, operation, NULL);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- log_book_tags_change logs tagging and untagging as a change to the book
+CREATE OR REPLACE FUNCTION log_book_tags_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    changed_book UUID;
+    owner_id UUID;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed_book := OLD.book_id;
+    ELSE
+        changed_book := NEW.book_id;
+    END IF;
+
+    SELECT user_id INTO owner_id FROM books WHERE id = changed_book AND deleted_at IS NULL;
+    IF owner_id IS NOT NULL THEN
+        PERFORM record_change(owner_id, 'book', changed_book, 'upsert', NULL);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Attach the triggers to every synced table that exists
+DO $$
+DECLARE
+    target RECORD;
+BEGIN
+    FOR target IN SELECT * FROM (VALUES
+        ('annotations', 'annotation'),
+        ('books', 'book'),
+        ('bookmarks', 'bookmark'),
+        ('reading_progress', 'reading_progress'),
+        ('reading_sessions', 'reading_session'),
+        ('tags', 'tag'),
+        ('user_preferences', 'preferences')
+    ) AS t(table_name, entity_type)
+    LOOP
+        IF to_regclass(target.table_name) IS NOT NULL THEN
+            EXECUTE format('DROP TRIGGER IF EXISTS log_%s_change ON %I', target.table_name, target.table_name);
+            EXECUTE format('CREATE TRIGGER log_%s_change AFTER INSERT OR UPDATE OR DELETE ON %I FOR EACH ROW EXECUTE FUNCTION log_entity_change(%L)',
+                target.table_name, target.table_name, target.entity_type);
+        END IF;
+    END LOOP;
+
+    IF to_regclass('annotations') IS NOT NULL THEN
+        DROP TRIGGER IF EXISTS stamp_annotations_sync_seq ON annotations;
+        CREATE TRIGGER stamp_annotations_sync_seq BEFORE INSERT OR UPDATE ON annotations
+            FOR EACH ROW EXECUTE FUNCTION stamp_sync_seq();
+    END IF;
+
+    IF to_regclass('book_tags') IS NOT NULL THEN
+        DROP TRIGGER IF EXISTS log_book_tags_change ON book_tags;
+        CREATE TRIGGER log_book_tags_change AFTER INSERT OR DELETE ON book_tags
+            FOR EACH ROW EXECUTE FUNCTION log_book_tags_change();
+    END IF;
+END $$;
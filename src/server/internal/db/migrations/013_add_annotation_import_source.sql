@@ -0,0 +1,9 @@
+-- Migration: 013_add_annotation_import_source.sql
+-- Description: Record where imported annotations came from (Kindle, KOReader, W3C Web Annotations)
+
+ALTER TABLE annotations ADD COLUMN IF NOT EXISTS import_source VARCHAR(20);
+ALTER TABLE annotations ADD COLUMN IF NOT EXISTS import_ref TEXT;
+ALTER TABLE annotations ADD COLUMN IF NOT EXISTS imported_at TIMESTAMP;
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_annotations_import_source ON annotations(import_source);
@@ -0,0 +1,36 @@
+-- Migration: 014_create_annotation_revisions.sql
+-- Description: Keep the history of every annotation change so edits and deletions can be reviewed and undone
+
+-- Create annotation_revisions table (state of an annotation after each change)
+CREATE TABLE IF NOT EXISTS annotation_revisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    annotation_id UUID NOT NULL,
+    revision INTEGER NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    operation VARCHAR(10) NOT NULL CHECK (operation IN ('create', 'update', 'delete', 'restore', 'snapshot')),
+    changed_fields TEXT[],
+    book_id UUID NOT NULL,
+    type VARCHAR(20),
+    page_number INTEGER,
+    start_position INTEGER,
+    end_position INTEGER,
+    selected_text TEXT,
+    content TEXT,
+    color VARCHAR(20),
+    tags TEXT[],
+    is_private BOOLEAN,
+    deleted BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE UNIQUE INDEX IF NOT EXISTS idx_annotation_revisions_revision ON annotation_revisions(annotation_id, revision);
+CREATE INDEX IF NOT EXISTS idx_annotation_revisions_user_id ON annotation_revisions(user_id);
+
+-- Start the history of existing annotations from their current state
+INSERT INTO annotation_revisions (annotation_id, revision, user_id, operation, book_id, type, page_number,
+    start_position, end_position, selected_text, content, color, tags, is_private, deleted, created_at)
+SELECT id, revision, user_id, 'snapshot', book_id, type, page_number, start_position, end_position,
+    selected_text, content, color, tags, is_private, deleted_at IS NOT NULL, COALESCE(deleted_at, updated_at)
+FROM annotations
+ON CONFLICT (annotation_id, revision) DO NOTHING;
@@ -0,0 +1,26 @@
+-- Migration: 015_create_bulk_operations.sql
+-- Description: Record bulk annotation actions with the state they replaced, so they can be undone
+
+-- Create bulk_operations table
+CREATE TABLE IF NOT EXISTS bulk_operations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    action VARCHAR(30) NOT NULL,
+    parameters JSONB,
+    items JSONB,
+    annotation_count INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    undone_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_bulk_operations_user_id ON bulk_operations(user_id);
+CREATE INDEX IF NOT EXISTS idx_bulk_operations_expires_at ON bulk_operations(expires_at);
+CREATE INDEX IF NOT EXISTS idx_bulk_operations_deleted_at ON bulk_operations(deleted_at);
+
+CREATE TRIGGER update_bulk_operations_updated_at 
+    BEFORE UPDATE ON bulk_operations
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,31 @@
+-- Migration: 016_create_annotation_links.sql
+-- Description: Typed links between annotations, or from an annotation to a passage of another book
+
+-- Create annotation_links table
+CREATE TABLE IF NOT EXISTS annotation_links (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source_annotation_id UUID NOT NULL REFERENCES annotations(id) ON DELETE CASCADE,
+    target_annotation_id UUID REFERENCES annotations(id) ON DELETE CASCADE,
+    target_book_id UUID REFERENCES books(id) ON DELETE CASCADE,
+    target_start INTEGER NOT NULL DEFAULT 0,
+    target_end INTEGER NOT NULL DEFAULT 0,
+    target_text TEXT,
+    relation VARCHAR(20) NOT NULL CHECK (relation IN ('echoes', 'contradicts', 'cites', 'explains')),
+    note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    CHECK ((target_annotation_id IS NULL) <> (target_book_id IS NULL))
+);
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_annotation_links_user_id ON annotation_links(user_id);
+CREATE INDEX IF NOT EXISTS idx_annotation_links_source ON annotation_links(source_annotation_id);
+CREATE INDEX IF NOT EXISTS idx_annotation_links_target ON annotation_links(target_annotation_id);
+CREATE INDEX IF NOT EXISTS idx_annotation_links_target_book ON annotation_links(target_book_id, target_start, target_end);
+CREATE INDEX IF NOT EXISTS idx_annotation_links_deleted_at ON annotation_links(deleted_at);
+
+CREATE TRIGGER update_annotation_links_updated_at
+    BEFORE UPDATE ON annotation_links
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
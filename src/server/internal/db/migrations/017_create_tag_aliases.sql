@@ -0,0 +1,37 @@
+-- Migration: 017_create_tag_aliases.sql
+-- Description: Tag aliases, and a registry of annotation tags alongside book tags
+
+-- Create tag_aliases table
+CREATE TABLE IF NOT EXISTS tag_aliases (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    alias VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_tag_aliases_user_id ON tag_aliases(user_id);
+CREATE INDEX IF NOT EXISTS idx_tag_aliases_tag_id ON tag_aliases(tag_id);
+CREATE INDEX IF NOT EXISTS idx_tag_aliases_deleted_at ON tag_aliases(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Aliases resolve case-insensitively, so they must be unique that way
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tag_aliases_user_alias_unique ON tag_aliases(user_id, LOWER(alias))
+WHERE deleted_at IS NULL;
+
+-- Find tags by hierarchy prefix
+CREATE INDEX IF NOT EXISTS idx_tags_user_name_prefix ON tags(user_id, name varchar_pattern_ops)
+WHERE deleted_at IS NULL;
+
+CREATE TRIGGER update_tag_aliases_updated_at
+    BEFORE UPDATE ON tag_aliases
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Register the tags already used on annotations, so they are managed with
+-- book tags
+INSERT INTO tags (user_id, name)
+SELECT DISTINCT a.user_id, TRIM(t.name)
+FROM annotations a, unnest(a.tags) AS t(name)
+WHERE a.deleted_at IS NULL AND TRIM(t.name) <> '' AND LENGTH(TRIM(t.name)) <= 100
+ON CONFLICT DO NOTHING;
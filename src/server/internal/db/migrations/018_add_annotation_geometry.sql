@@ -0,0 +1,16 @@
+-- Migration: 018_add_annotation_geometry.sql
+-- Description: Area and ink annotations, with page geometry and pen strokes
+
+-- Allow the new annotation types
+ALTER TABLE annotations DROP CONSTRAINT IF EXISTS annotations_type_check;
+ALTER TABLE annotations ADD CONSTRAINT annotations_type_check
+    CHECK (type IN ('highlight', 'note', 'bookmark', 'area', 'ink'));
+
+-- Page rectangles and quads, as fractions of the page
+ALTER TABLE annotations ADD COLUMN IF NOT EXISTS geometry JSONB;
+
+-- Pen strokes in the compact binary encoding of models.InkDrawing
+ALTER TABLE annotations ADD COLUMN IF NOT EXISTS ink BYTEA;
+
+ALTER TABLE annotation_revisions ADD COLUMN IF NOT EXISTS geometry JSONB;
+ALTER TABLE annotation_revisions ADD COLUMN IF NOT EXISTS ink BYTEA;
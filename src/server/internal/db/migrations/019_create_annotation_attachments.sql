@@ -0,0 +1,31 @@
+-- Migration: 019_create_annotation_attachments.sql
+-- Description: Files attached to annotations: images, audio memos and documents
+
+-- Create annotation_attachments table
+CREATE TABLE IF NOT EXISTS annotation_attachments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    annotation_id UUID NOT NULL REFERENCES annotations(id) ON DELETE CASCADE,
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('image', 'audio', 'file')),
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL CHECK (size > 0),
+    sha256 VARCHAR(64) NOT NULL,
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    has_thumbnail BOOLEAN NOT NULL DEFAULT FALSE,
+    storage_path VARCHAR(500) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_annotation_attachments_user_id ON annotation_attachments(user_id);
+CREATE INDEX IF NOT EXISTS idx_annotation_attachments_annotation_id ON annotation_attachments(annotation_id);
+
+-- Attachments deleted with their annotation wait here to be purged
+CREATE INDEX IF NOT EXISTS idx_annotation_attachments_deleted_at ON annotation_attachments(deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TRIGGER update_annotation_attachments_updated_at
+    BEFORE UPDATE ON annotation_attachments
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,116 @@
+-- Migration: 020_add_note_publishing.sql
+-- Description: Publishing workflow for note collections: drafts, versions, subscriptions and ratings
+
+-- Publishing state, and what identifies the author's copy of the book
+ALTER TABLE published_notes
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'published', 'withdrawn')),
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS published_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS ratings_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS book_isbn VARCHAR(20),
+    ADD COLUMN IF NOT EXISTS book_fingerprint VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_published_notes_status ON published_notes(status);
+CREATE INDEX IF NOT EXISTS idx_published_notes_book_isbn ON published_notes(book_isbn) WHERE book_isbn IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_published_notes_book_fingerprint ON published_notes(book_fingerprint) WHERE book_fingerprint IS NOT NULL;
+
+-- Overlays belong to a published version and carry their quote for re-anchoring
+ALTER TABLE note_overlays
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS annotation_id UUID REFERENCES annotations(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS quote TEXT,
+    ADD COLUMN IF NOT EXISTS quote_prefix TEXT,
+    ADD COLUMN IF NOT EXISTS quote_suffix TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_note_overlays_note_version ON note_overlays(published_note_id, version);
+
+-- Match readers' copies of a book by their text
+ALTER TABLE books ADD COLUMN IF NOT EXISTS text_fingerprint VARCHAR(64);
+CREATE INDEX IF NOT EXISTS idx_books_text_fingerprint ON books(text_fingerprint) WHERE text_fingerprint IS NOT NULL;
+
+-- Create published_note_items table
+CREATE TABLE IF NOT EXISTS published_note_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    published_note_id UUID NOT NULL REFERENCES published_notes(id) ON DELETE CASCADE,
+    annotation_id UUID NOT NULL REFERENCES annotations(id) ON DELETE CASCADE,
+    note_type VARCHAR(50) NOT NULL CHECK (note_type IN ('explanation', 'context', 'cross_reference', 'translation', 'historical_note')),
+    display_order INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_published_note_items_published_note_id ON published_note_items(published_note_id);
+CREATE INDEX IF NOT EXISTS idx_published_note_items_annotation_id ON published_note_items(annotation_id);
+CREATE INDEX IF NOT EXISTS idx_published_note_items_deleted_at ON published_note_items(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_published_note_items_unique ON published_note_items(published_note_id, annotation_id)
+WHERE deleted_at IS NULL;
+
+-- Create published_note_versions table
+CREATE TABLE IF NOT EXISTS published_note_versions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    published_note_id UUID NOT NULL REFERENCES published_notes(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    difficulty_level VARCHAR(20),
+    changelog TEXT,
+    overlay_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_published_note_versions_version ON published_note_versions(published_note_id, version);
+CREATE INDEX IF NOT EXISTS idx_published_note_versions_deleted_at ON published_note_versions(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Create note_subscriptions table
+CREATE TABLE IF NOT EXISTS note_subscriptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    published_note_id UUID NOT NULL REFERENCES published_notes(id) ON DELETE CASCADE,
+    pinned_version INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_note_subscriptions_user_id ON note_subscriptions(user_id);
+CREATE INDEX IF NOT EXISTS idx_note_subscriptions_published_note_id ON note_subscriptions(published_note_id);
+CREATE INDEX IF NOT EXISTS idx_note_subscriptions_deleted_at ON note_subscriptions(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_note_subscriptions_unique ON note_subscriptions(user_id, published_note_id)
+WHERE deleted_at IS NULL;
+
+-- Create note_ratings table
+CREATE TABLE IF NOT EXISTS note_ratings (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    published_note_id UUID NOT NULL REFERENCES published_notes(id) ON DELETE CASCADE,
+    rating INTEGER NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    review TEXT,
+    version INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_note_ratings_published_note_id ON note_ratings(published_note_id);
+CREATE INDEX IF NOT EXISTS idx_note_ratings_deleted_at ON note_ratings(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_note_ratings_unique ON note_ratings(user_id, published_note_id)
+WHERE deleted_at IS NULL;
+
+CREATE TRIGGER update_published_note_items_updated_at
+    BEFORE UPDATE ON published_note_items
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_published_note_versions_updated_at
+    BEFORE UPDATE ON published_note_versions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_note_subscriptions_updated_at
+    BEFORE UPDATE ON note_subscriptions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_note_ratings_updated_at
+    BEFORE UPDATE ON note_ratings
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,85 @@
+-- Migration: 021_create_marketplace.sql
+-- Description: Orders, entitlements and payment webhook events for paid published notes
+
+ALTER TABLE published_notes DROP CONSTRAINT IF EXISTS published_notes_price_cents_check;
+ALTER TABLE published_notes ADD CONSTRAINT published_notes_price_cents_check CHECK (price_cents >= 0);
+
+-- Create note_orders table
+CREATE TABLE IF NOT EXISTS note_orders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    published_note_id UUID NOT NULL REFERENCES published_notes(id) ON DELETE RESTRICT,
+    author_id UUID NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+    amount_cents INTEGER NOT NULL CHECK (amount_cents > 0),
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'paid', 'failed', 'refunded')),
+    provider VARCHAR(20) NOT NULL,
+    provider_ref VARCHAR(255),
+    client_secret VARCHAR(255),
+    checkout_url TEXT,
+    idempotency_key VARCHAR(255),
+    platform_fee_cents INTEGER NOT NULL DEFAULT 0,
+    author_share_cents INTEGER NOT NULL DEFAULT 0,
+    failure_reason TEXT,
+    refund_ref VARCHAR(255),
+    paid_at TIMESTAMP,
+    refunded_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_note_orders_user_id ON note_orders(user_id);
+CREATE INDEX IF NOT EXISTS idx_note_orders_published_note_id ON note_orders(published_note_id);
+CREATE INDEX IF NOT EXISTS idx_note_orders_author_id ON note_orders(author_id, paid_at);
+CREATE INDEX IF NOT EXISTS idx_note_orders_status ON note_orders(status);
+CREATE INDEX IF NOT EXISTS idx_note_orders_deleted_at ON note_orders(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_note_orders_provider_ref ON note_orders(provider, provider_ref)
+WHERE provider_ref IS NOT NULL AND provider_ref <> '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_note_orders_idempotency_key ON note_orders(user_id, idempotency_key)
+WHERE idempotency_key IS NOT NULL AND idempotency_key <> '';
+
+-- Create note_entitlements table
+CREATE TABLE IF NOT EXISTS note_entitlements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    published_note_id UUID NOT NULL REFERENCES published_notes(id) ON DELETE CASCADE,
+    order_id UUID REFERENCES note_orders(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_note_entitlements_user_id ON note_entitlements(user_id);
+CREATE INDEX IF NOT EXISTS idx_note_entitlements_published_note_id ON note_entitlements(published_note_id);
+CREATE INDEX IF NOT EXISTS idx_note_entitlements_order_id ON note_entitlements(order_id);
+CREATE INDEX IF NOT EXISTS idx_note_entitlements_deleted_at ON note_entitlements(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_note_entitlements_unique ON note_entitlements(user_id, published_note_id)
+WHERE deleted_at IS NULL;
+
+-- Create payment_events table
+CREATE TABLE IF NOT EXISTS payment_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    provider VARCHAR(20) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    provider_ref VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_events_event ON payment_events(provider, event_id);
+CREATE INDEX IF NOT EXISTS idx_payment_events_provider_ref ON payment_events(provider_ref);
+
+CREATE TRIGGER update_note_orders_updated_at
+    BEFORE UPDATE ON note_orders
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_note_entitlements_updated_at
+    BEFORE UPDATE ON note_entitlements
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_payment_events_updated_at
+    BEFORE UPDATE ON payment_events
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,73 @@
+-- Migration: 022_add_scholar_verification.sql
+-- Description: Verified scholar applications, review of published note versions and the review audit trail
+
+-- Admins review applications and submitted notes. Grant with:
+--   UPDATE users SET is_admin = true WHERE username = '...';
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS verified_scholar BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS scholar_field VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS scholar_affiliation VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS verified_at TIMESTAMP;
+
+-- Versions of notes above the review threshold wait for approval
+ALTER TABLE published_note_versions
+    ADD COLUMN IF NOT EXISTS review_status VARCHAR(20) NOT NULL DEFAULT 'approved' CHECK (review_status IN ('submitted', 'approved', 'rejected')),
+    ADD COLUMN IF NOT EXISTS review_comment TEXT,
+    ADD COLUMN IF NOT EXISTS reviewer_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_published_note_versions_review_status ON published_note_versions(review_status);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_published_note_versions_one_submitted ON published_note_versions(published_note_id)
+WHERE review_status = 'submitted' AND deleted_at IS NULL;
+
+-- Create scholar_applications table
+CREATE TABLE IF NOT EXISTS scholar_applications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'submitted' CHECK (status IN ('submitted', 'approved', 'rejected', 'withdrawn', 'revoked')),
+    full_name VARCHAR(255) NOT NULL,
+    affiliation VARCHAR(255),
+    field VARCHAR(255) NOT NULL,
+    credentials TEXT NOT NULL,
+    evidence_urls JSONB,
+    orcid VARCHAR(19),
+    reviewer_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    review_comment TEXT,
+    reviewed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_scholar_applications_user_id ON scholar_applications(user_id);
+CREATE INDEX IF NOT EXISTS idx_scholar_applications_status ON scholar_applications(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_scholar_applications_deleted_at ON scholar_applications(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scholar_applications_one_open ON scholar_applications(user_id)
+WHERE status = 'submitted' AND deleted_at IS NULL;
+
+-- Create review_audit_entries table
+CREATE TABLE IF NOT EXISTS review_audit_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subject_type VARCHAR(30) NOT NULL CHECK (subject_type IN ('scholar_application', 'note_version')),
+    subject_id UUID NOT NULL,
+    actor_id UUID NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+    action VARCHAR(20) NOT NULL,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    comment TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_review_audit_subject ON review_audit_entries(subject_type, subject_id);
+CREATE INDEX IF NOT EXISTS idx_review_audit_entries_actor_id ON review_audit_entries(actor_id);
+
+CREATE TRIGGER update_scholar_applications_updated_at
+    BEFORE UPDATE ON scholar_applications
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_review_audit_entries_updated_at
+    BEFORE UPDATE ON review_audit_entries
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,66 @@
+-- Migration: 023_create_group_invitations_and_books.sql
+-- Description: Reading group invitations, shared group book lists and rejoinable memberships
+
+-- Members who left can rejoin: only active memberships are unique
+ALTER TABLE group_members DROP CONSTRAINT IF EXISTS group_members_group_id_user_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_group_members_unique ON group_members(group_id, user_id)
+WHERE deleted_at IS NULL;
+
+-- Create group_invitations table
+CREATE TABLE IF NOT EXISTS group_invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES reading_groups(id) ON DELETE CASCADE,
+    inviter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    invitee_id UUID REFERENCES users(id) ON DELETE CASCADE, -- Invitations by username
+    token VARCHAR(64), -- Link invitations
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined', 'revoked')),
+    max_uses INTEGER NOT NULL DEFAULT 0,
+    uses INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+
+    CHECK ((invitee_id IS NULL) <> (token IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_invitations_group_id ON group_invitations(group_id);
+CREATE INDEX IF NOT EXISTS idx_group_invitations_inviter_id ON group_invitations(inviter_id);
+CREATE INDEX IF NOT EXISTS idx_group_invitations_invitee_id ON group_invitations(invitee_id);
+CREATE INDEX IF NOT EXISTS idx_group_invitations_deleted_at ON group_invitations(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_group_invitations_token ON group_invitations(token)
+WHERE token IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_group_invitations_pending_invitee ON group_invitations(group_id, invitee_id)
+WHERE status = 'pending' AND invitee_id IS NOT NULL AND deleted_at IS NULL;
+
+-- Create group_books table
+CREATE TABLE IF NOT EXISTS group_books (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES reading_groups(id) ON DELETE CASCADE,
+    added_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    book_id UUID REFERENCES books(id) ON DELETE SET NULL,
+    title VARCHAR(255) NOT NULL,
+    author VARCHAR(255),
+    isbn VARCHAR(20),
+    text_fingerprint VARCHAR(64),
+    status VARCHAR(20) NOT NULL DEFAULT 'upcoming' CHECK (status IN ('upcoming', 'current', 'finished')),
+    position INTEGER NOT NULL DEFAULT 0,
+    note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_books_group_id ON group_books(group_id);
+CREATE INDEX IF NOT EXISTS idx_group_books_book_id ON group_books(book_id);
+CREATE INDEX IF NOT EXISTS idx_group_books_isbn ON group_books(isbn);
+CREATE INDEX IF NOT EXISTS idx_group_books_text_fingerprint ON group_books(text_fingerprint);
+CREATE INDEX IF NOT EXISTS idx_group_books_deleted_at ON group_books(deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TRIGGER update_group_invitations_updated_at
+    BEFORE UPDATE ON group_invitations
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_group_books_updated_at
+    BEFORE UPDATE ON group_books
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,47 @@
+-- Migration: 024_add_discussion_threads.sql
+-- Description: Threaded, passage-anchored discussions with per-user votes and tombstones
+
+ALTER TABLE discussions
+    ADD COLUMN IF NOT EXISTS root_id UUID REFERENCES discussions(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS depth INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS reply_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS content_html TEXT,
+    ADD COLUMN IF NOT EXISTS last_activity_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS start_position INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS end_position INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS quote TEXT,
+    ADD COLUMN IF NOT EXISTS quote_prefix TEXT,
+    ADD COLUMN IF NOT EXISTS quote_suffix TEXT,
+    ADD COLUMN IF NOT EXISTS book_isbn VARCHAR(20),
+    ADD COLUMN IF NOT EXISTS book_fingerprint VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS tombstoned_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS tombstone_reason VARCHAR(20) CHECK (tombstone_reason IN ('deleted', 'removed'));
+
+CREATE INDEX IF NOT EXISTS idx_discussions_root_id ON discussions(root_id);
+CREATE INDEX IF NOT EXISTS idx_discussions_book_isbn ON discussions(book_isbn);
+CREATE INDEX IF NOT EXISTS idx_discussions_book_fingerprint ON discussions(book_fingerprint);
+CREATE INDEX IF NOT EXISTS idx_discussions_last_activity_at ON discussions(last_activity_at);
+
+-- Create discussion_votes table
+CREATE TABLE IF NOT EXISTS discussion_votes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    discussion_id UUID NOT NULL REFERENCES discussions(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_discussion_votes_discussion_id ON discussion_votes(discussion_id);
+CREATE INDEX IF NOT EXISTS idx_discussion_votes_user_id ON discussion_votes(user_id);
+CREATE INDEX IF NOT EXISTS idx_discussion_votes_deleted_at ON discussion_votes(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_discussion_votes_unique ON discussion_votes(discussion_id, user_id)
+WHERE deleted_at IS NULL;
+
+-- Votes were a raw counter; they are now counted from discussion_votes
+UPDATE discussions SET upvotes = 0;
+
+CREATE TRIGGER update_discussion_votes_updated_at
+    BEFORE UPDATE ON discussion_votes
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,58 @@
+-- Migration: 025_create_reading_schedules.sql
+-- Description: Group reading schedules with dated assignments, and spoiler-safe discussion gating
+
+-- Create reading_schedules table
+CREATE TABLE IF NOT EXISTS reading_schedules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES reading_groups(id) ON DELETE CASCADE,
+    group_book_id UUID NOT NULL REFERENCES group_books(id) ON DELETE CASCADE,
+    created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    split_by VARCHAR(20) NOT NULL CHECK (split_by IN ('chapter', 'percentage')),
+    spoiler_safe BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reading_schedules_group_id ON reading_schedules(group_id);
+CREATE INDEX IF NOT EXISTS idx_reading_schedules_group_book_id ON reading_schedules(group_book_id);
+CREATE INDEX IF NOT EXISTS idx_reading_schedules_deleted_at ON reading_schedules(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Create schedule_assignments table
+CREATE TABLE IF NOT EXISTS schedule_assignments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    schedule_id UUID NOT NULL REFERENCES reading_schedules(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL DEFAULT 0,
+    title VARCHAR(255) NOT NULL,
+    due_at TIMESTAMP NOT NULL,
+    start_percent DOUBLE PRECISION NOT NULL DEFAULT 0,
+    end_percent DOUBLE PRECISION NOT NULL DEFAULT 0,
+    first_chapter INTEGER,
+    last_chapter INTEGER,
+    note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+
+    CHECK (start_percent >= 0 AND end_percent > start_percent AND end_percent <= 100)
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_assignments_schedule_id ON schedule_assignments(schedule_id);
+CREATE INDEX IF NOT EXISTS idx_schedule_assignments_deleted_at ON schedule_assignments(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Where anchored threads start, for hiding them from members who have not read that far
+ALTER TABLE discussions ADD COLUMN IF NOT EXISTS anchor_percent DOUBLE PRECISION;
+
+UPDATE discussions d
+SET anchor_percent = d.start_position * 100.0 / char_length(bc.full_text)
+FROM book_contents bc
+WHERE bc.book_id = d.book_id AND d.end_position > d.start_position AND char_length(bc.full_text) > 0;
+
+CREATE TRIGGER update_reading_schedules_updated_at
+    BEFORE UPDATE ON reading_schedules
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_schedule_assignments_updated_at
+    BEFORE UPDATE ON schedule_assignments
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
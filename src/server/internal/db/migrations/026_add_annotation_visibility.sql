@@ -0,0 +1,38 @@
+-- Migration: 026_add_annotation_visibility.sql
+-- Description: Annotation visibility scopes (private, group:<id>, public) and replies to shared annotations
+
+ALTER TABLE annotations ADD COLUMN IF NOT EXISTS visibility VARCHAR(50) NOT NULL DEFAULT 'private';
+
+-- Existing annotations keep the scope is_private gave them; a NULL
+-- is_private was never shared. Only rows whose visibility changes are
+-- written, so the change log records just those.
+UPDATE annotations SET visibility = CASE WHEN is_private IS FALSE THEN 'public' ELSE 'private' END
+WHERE visibility <> CASE WHEN is_private IS FALSE THEN 'public' ELSE 'private' END;
+
+ALTER TABLE annotations DROP CONSTRAINT IF EXISTS annotations_visibility_check;
+ALTER TABLE annotations ADD CONSTRAINT annotations_visibility_check CHECK (
+    visibility IN ('private', 'public') OR
+    visibility ~ '^group:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$'
+);
+
+CREATE INDEX IF NOT EXISTS idx_annotations_visibility ON annotations(visibility) WHERE visibility <> 'private';
+
+-- Create annotation_replies table
+CREATE TABLE IF NOT EXISTS annotation_replies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    annotation_id UUID NOT NULL REFERENCES annotations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    content_html TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_annotation_replies_annotation_id ON annotation_replies(annotation_id);
+CREATE INDEX IF NOT EXISTS idx_annotation_replies_user_id ON annotation_replies(user_id);
+CREATE INDEX IF NOT EXISTS idx_annotation_replies_deleted_at ON annotation_replies(deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TRIGGER update_annotation_replies_updated_at
+    BEFORE UPDATE ON annotation_replies
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
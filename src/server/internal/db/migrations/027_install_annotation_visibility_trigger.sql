@@ -0,0 +1,27 @@
+-- Migration: 027_install_annotation_visibility_trigger.sql
+-- Description: Keep annotations.is_private in step with annotations.visibility on every write
+-- This migration is idempotent and is also applied on every AutoMigrate.
+
+-- Visibility is authoritative. Writers that only know is_private (older
+-- clients, sync, bulk privacy toggles, undo) can make an annotation private
+-- but never share it: clearing is_private keeps the visibility it had, and
+-- a new annotation without a visibility is private.
+CREATE OR REPLACE FUNCTION sync_annotation_visibility()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.visibility IS NOT DISTINCT FROM OLD.visibility
+       AND NEW.is_private IS TRUE AND OLD.is_private IS NOT TRUE THEN
+        NEW.visibility := 'private';
+    END IF;
+    IF NEW.visibility IS NULL OR NEW.visibility = '' THEN
+        NEW.visibility := 'private';
+    END IF;
+    NEW.is_private := NEW.visibility = 'private';
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS sync_annotations_visibility ON annotations;
+CREATE TRIGGER sync_annotations_visibility
+    BEFORE INSERT OR UPDATE ON annotations
+    FOR EACH ROW EXECUTE FUNCTION sync_annotation_visibility();
@@ -0,0 +1,31 @@
+-- Migration: 028_create_popular_highlights.sql
+-- Description: Popular highlights aggregated anonymously across readers of the same text
+
+-- Create popular_highlights table
+CREATE TABLE IF NOT EXISTS popular_highlights (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    text_fingerprint VARCHAR(64) NOT NULL,
+    reference_book_id UUID NOT NULL,
+    start_position INTEGER NOT NULL DEFAULT 0,
+    end_position INTEGER NOT NULL DEFAULT 0,
+    quote TEXT,
+    quote_prefix TEXT,
+    quote_suffix TEXT,
+    reader_count INTEGER NOT NULL,
+    text_readers INTEGER NOT NULL,
+    computed_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_popular_highlights_text_fingerprint ON popular_highlights(text_fingerprint);
+CREATE INDEX IF NOT EXISTS idx_popular_highlights_deleted_at ON popular_highlights(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- The aggregation reads only public highlights
+CREATE INDEX IF NOT EXISTS idx_annotations_public_highlights ON annotations(book_id)
+    WHERE type = 'highlight' AND visibility = 'public' AND deleted_at IS NULL;
+
+CREATE TRIGGER update_popular_highlights_updated_at
+    BEFORE UPDATE ON popular_highlights
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
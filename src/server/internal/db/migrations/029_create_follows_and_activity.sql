@@ -0,0 +1,73 @@
+-- Migration: 029_create_follows_and_activity.sql
+-- Description: Follow graph, mutes, activity events and their fan-out to followers' feeds
+
+-- Create follows table
+CREATE TABLE IF NOT EXISTS follows (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_follows_pair ON follows(follower_id, followee_id);
+CREATE INDEX IF NOT EXISTS idx_follows_followee_id ON follows(followee_id);
+CREATE INDEX IF NOT EXISTS idx_follows_deleted_at ON follows(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Create user_mutes table
+CREATE TABLE IF NOT EXISTS user_mutes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    muted_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_mutes_pair ON user_mutes(user_id, muted_user_id);
+CREATE INDEX IF NOT EXISTS idx_user_mutes_deleted_at ON user_mutes(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Create activity_events table
+CREATE TABLE IF NOT EXISTS activity_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(30) NOT NULL CHECK (type IN ('started_book', 'finished_book', 'published_note', 'public_highlight', 'goal_reached')),
+    key VARCHAR(255) NOT NULL,
+    book_id UUID,
+    subject_id UUID,
+    summary TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_activity_events_key ON activity_events(user_id, key);
+CREATE INDEX IF NOT EXISTS idx_activity_events_user_created ON activity_events(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_activity_events_deleted_at ON activity_events(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Create feed_items table
+CREATE TABLE IF NOT EXISTS feed_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL REFERENCES activity_events(id) ON DELETE CASCADE,
+    actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_feed_items_event ON feed_items(user_id, event_id);
+CREATE INDEX IF NOT EXISTS idx_feed_items_page ON feed_items(user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_feed_items_actor ON feed_items(actor_id);
+
+CREATE TRIGGER update_follows_updated_at
+    BEFORE UPDATE ON follows
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_user_mutes_updated_at
+    BEFORE UPDATE ON user_mutes
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_activity_events_updated_at
+    BEFORE UPDATE ON activity_events
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
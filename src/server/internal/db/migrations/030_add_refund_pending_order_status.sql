@@ -0,0 +1,6 @@
+-- Migration: 030_add_refund_pending_order_status.sql
+-- Description: Orders are refund_pending while the payment provider processes a refund
+
+ALTER TABLE note_orders DROP CONSTRAINT IF EXISTS note_orders_status_check;
+ALTER TABLE note_orders ADD CONSTRAINT note_orders_status_check
+    CHECK (status IN ('pending', 'paid', 'failed', 'refund_pending', 'refunded'));
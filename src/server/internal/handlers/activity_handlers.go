@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// ActivityHandlers serves follows, mutes, activity feeds and public
+// profiles
+type ActivityHandlers struct {
+	activityService *services.ActivityService
+}
+
+// NewActivityHandlers creates new activity handlers
+func NewActivityHandlers(activityService *services.ActivityService) *ActivityHandlers {
+	return &ActivityHandlers{activityService: activityService}
+}
+
+// Follow follows a user
+// POST /api/users/:id/follow
+func (h *ActivityHandlers) Follow(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	followeeID, ok := getUUIDParam(c, "id", "user ID")
+	if !ok {
+		return
+	}
+
+	if err := h.activityService.Follow(c.Request.Context(), userID, followeeID); err != nil {
+		respondServiceError(c, "Failed to follow user", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "User followed successfully", nil)
+}
+
+// Unfollow stops following a user
+// DELETE /api/users/:id/follow
+func (h *ActivityHandlers) Unfollow(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	followeeID, ok := getUUIDParam(c, "id", "user ID")
+	if !ok {
+		return
+	}
+
+	if err := h.activityService.Unfollow(c.Request.Context(), userID, followeeID); err != nil {
+		respondServiceError(c, "Failed to unfollow user", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "User unfollowed successfully", nil)
+}
+
+// Followers lists a user's followers
+// GET /api/users/:id/followers
+func (h *ActivityHandlers) Followers(c *gin.Context) {
+	h.listFollows(c, "followers", h.activityService.Followers)
+}
+
+// Following lists who a user follows
+// GET /api/users/:id/following
+func (h *ActivityHandlers) Following(c *gin.Context) {
+	h.listFollows(c, "followed users", h.activityService.Following)
+}
+
+// followList lists the followers or followed users of a profile
+type followList func(ctx context.Context, viewerID, userID uuid.UUID, limit, offset int) ([]services.UserSummary, int64, error)
+
+func (h *ActivityHandlers) listFollows(c *gin.Context, label string, list followList) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	profileID, ok := getUUIDParam(c, "id", "user ID")
+	if !ok {
+		return
+	}
+
+	page := utils.GetIntQuery(c, "page", 1, 1, 100000)
+	perPage := utils.GetIntQuery(c, "per_page", 50, 1, 100)
+
+	users, total, err := list(c.Request.Context(), userID, profileID, perPage, (page-1)*perPage)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve "+label, err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Users retrieved successfully", users, utils.PaginationResponse{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      int(total),
+		TotalPages: (int(total) + perPage - 1) / perPage,
+	})
+}
+
+// Mute hides a user's activity from the feed
+// POST /api/users/:id/mute
+func (h *ActivityHandlers) Mute(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	mutedID, ok := getUUIDParam(c, "id", "user ID")
+	if !ok {
+		return
+	}
+
+	if err := h.activityService.Mute(c.Request.Context(), userID, mutedID); err != nil {
+		respondServiceError(c, "Failed to mute user", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "User muted successfully", nil)
+}
+
+// Unmute shows a muted user's activity again
+// DELETE /api/users/:id/mute
+func (h *ActivityHandlers) Unmute(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	mutedID, ok := getUUIDParam(c, "id", "user ID")
+	if !ok {
+		return
+	}
+
+	if err := h.activityService.Unmute(c.Request.Context(), userID, mutedID); err != nil {
+		respondServiceError(c, "Failed to unmute user", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "User unmuted successfully", nil)
+}
+
+// ListMutes lists the users the user muted
+// GET /api/feed/mutes
+func (h *ActivityHandlers) ListMutes(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	users, err := h.activityService.Mutes(c.Request.Context(), userID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve mutes", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Mutes retrieved successfully", users)
+}
+
+// Feed returns a page of the user's activity feed
+// GET /api/feed?cursor=&limit=
+func (h *ActivityHandlers) Feed(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	limit := utils.GetIntQuery(c, "limit", 20, 1, 100)
+
+	feed, err := h.activityService.Feed(c.Request.Context(), userID, c.Query("cursor"), limit)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve feed", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Feed retrieved successfully", feed)
+}
+
+// Profile returns a user's profile, showing what their privacy settings
+// allow
+// GET /api/users/:id/profile
+func (h *ActivityHandlers) Profile(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	profileID, ok := getUUIDParam(c, "id", "user ID")
+	if !ok {
+		return
+	}
+
+	profile, err := h.activityService.Profile(c.Request.Context(), userID, profileID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve profile", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Profile retrieved successfully", profile)
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/classius/server/internal/models"
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// multipartOverhead allows for the form around an uploaded file
+const multipartOverhead = 1 << 20
+
+// AnnotationAttachmentHandlers serves files attached to annotations
+type AnnotationAttachmentHandlers struct {
+	attachmentService *services.AnnotationAttachmentService
+}
+
+// NewAnnotationAttachmentHandlers creates new annotation attachment handlers
+func NewAnnotationAttachmentHandlers(attachmentService *services.AnnotationAttachmentService) *AnnotationAttachmentHandlers {
+	return &AnnotationAttachmentHandlers{attachmentService: attachmentService}
+}
+
+// Upload attaches the multipart file field "file" to an annotation
+// POST /api/annotations/:id/attachments
+func (h *AnnotationAttachmentHandlers) Upload(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	annotationID, ok := getUUIDParam(c, "id", "annotation ID")
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.attachmentService.MaxSize()+multipartOverhead)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, "Attachment is too large", err)
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadRequest, "No file provided", err)
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.attachmentService.Upload(c.Request.Context(), userID, annotationID,
+		header.Filename, header.Header.Get("Content-Type"), file)
+	if err != nil {
+		respondServiceError(c, "Failed to upload attachment", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Attachment uploaded successfully",
+		"data":    attachment,
+	})
+}
+
+// List lists an annotation's attachments
+// GET /api/annotations/:id/attachments
+func (h *AnnotationAttachmentHandlers) List(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	annotationID, ok := getUUIDParam(c, "id", "annotation ID")
+	if !ok {
+		return
+	}
+
+	attachments, err := h.attachmentService.List(c.Request.Context(), userID, annotationID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve attachments", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Attachments retrieved successfully", attachments)
+}
+
+// Download serves an attachment's file, supporting range requests so audio
+// can be seeked
+// GET /api/annotations/attachments/:attachment_id
+func (h *AnnotationAttachmentHandlers) Download(c *gin.Context) {
+	h.serve(c, false)
+}
+
+// Thumbnail serves an image attachment's JPEG thumbnail
+// GET /api/annotations/attachments/:attachment_id/thumbnail
+func (h *AnnotationAttachmentHandlers) Thumbnail(c *gin.Context) {
+	h.serve(c, true)
+}
+
+func (h *AnnotationAttachmentHandlers) serve(c *gin.Context, thumbnail bool) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	attachmentID, ok := getUUIDParam(c, "attachment_id", "attachment ID")
+	if !ok {
+		return
+	}
+
+	attachment, f, err := h.attachmentService.Open(c.Request.Context(), userID, attachmentID, thumbnail)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve attachment", err)
+		return
+	}
+	defer f.Close()
+
+	contentType, disposition := attachment.ContentType, "attachment"
+	if thumbnail {
+		contentType = "image/jpeg"
+	}
+	if thumbnail || attachment.Kind != models.AttachmentFile {
+		disposition = "inline"
+	}
+	if header := mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}); header != "" {
+		disposition = header
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", disposition)
+	c.Header("Content-Security-Policy", "sandbox")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, max-age=3600")
+	http.ServeContent(c.Writer, c.Request, "", attachment.CreatedAt, f)
+}
+
+// Delete removes an attachment
+// DELETE /api/annotations/attachments/:attachment_id
+func (h *AnnotationAttachmentHandlers) Delete(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	attachmentID, ok := getUUIDParam(c, "attachment_id", "attachment ID")
+	if !ok {
+		return
+	}
+
+	if err := h.attachmentService.Delete(c.Request.Context(), userID, attachmentID); err != nil {
+		respondServiceError(c, "Failed to delete attachment", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Attachment deleted successfully", nil)
+}
+
+// Usage reports the user's attachment storage against their quota
+// GET /api/annotations/attachments/usage
+func (h *AnnotationAttachmentHandlers) Usage(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	usage, err := h.attachmentService.Usage(c.Request.Context(), userID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve attachment usage", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Attachment usage retrieved successfully", usage)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// AnnotationHistoryHandlers serves annotation revision history
+type AnnotationHistoryHandlers struct {
+	historyService *services.AnnotationHistoryService
+}
+
+// NewAnnotationHistoryHandlers creates new annotation history handlers
+func NewAnnotationHistoryHandlers(historyService *services.AnnotationHistoryService) *AnnotationHistoryHandlers {
+	return &AnnotationHistoryHandlers{historyService: historyService}
+}
+
+// History lists an annotation's revisions, newest first
+// GET /api/annotations/:id/history
+func (h *AnnotationHistoryHandlers) History(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	annotationID, ok := getUUIDParam(c, "id", "annotation ID")
+	if !ok {
+		return
+	}
+
+	history, err := h.historyService.History(c.Request.Context(), userID, annotationID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve annotation history", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Annotation history retrieved successfully", history)
+}
+
+// Restore returns an annotation to an earlier revision, undeleting it if
+// it was deleted
+// POST /api/annotations/:id/restore/:rev
+func (h *AnnotationHistoryHandlers) Restore(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	annotationID, ok := getUUIDParam(c, "id", "annotation ID")
+	if !ok {
+		return
+	}
+	revision, err := strconv.Atoi(c.Param("rev"))
+	if err != nil || revision < 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid revision", err)
+		return
+	}
+
+	annotation, err := h.historyService.Restore(c.Request.Context(), userID, annotationID, revision)
+	if err != nil {
+		respondServiceError(c, "Failed to restore annotation", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Annotation restored successfully", annotation)
+}
+
+// Diff compares two revisions of an annotation, with a word diff of its
+// note. to defaults to the latest revision and from to the one before it.
+// GET /api/annotations/:id/diff?from=&to=
+func (h *AnnotationHistoryHandlers) Diff(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	annotationID, ok := getUUIDParam(c, "id", "annotation ID")
+	if !ok {
+		return
+	}
+	from := utils.GetIntQuery(c, "from", -1, 0, 1<<31-1)
+	to := utils.GetIntQuery(c, "to", -1, 0, 1<<31-1)
+
+	diff, err := h.historyService.Diff(c.Request.Context(), userID, annotationID, from, to)
+	if err != nil {
+		respondServiceError(c, "Failed to compare revisions", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Revisions compared successfully", diff)
+}
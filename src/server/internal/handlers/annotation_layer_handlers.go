@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// AnnotationLayerHandlers serves annotations shared with groups and the
+// public, and the replies to them
+type AnnotationLayerHandlers struct {
+	layerService *services.AnnotationLayerService
+}
+
+// NewAnnotationLayerHandlers creates new annotation layer handlers
+func NewAnnotationLayerHandlers(layerService *services.AnnotationLayerService) *AnnotationLayerHandlers {
+	return &AnnotationLayerHandlers{layerService: layerService}
+}
+
+// sharedAnnotationFilter reads the range, type and user_id query
+// parameters
+func sharedAnnotationFilter(c *gin.Context, layer services.AnnotationLayer) (services.SharedAnnotationFilter, bool) {
+	filter := services.SharedAnnotationFilter{Layer: layer, Type: c.Query("type")}
+	if rangeStr := c.Query("range"); rangeStr != "" {
+		within, err := services.ParseTextRange(rangeStr)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid range", err)
+			return filter, false
+		}
+		filter.Range = within
+	}
+	if author := c.Query("user_id"); author != "" {
+		authorID, err := uuid.Parse(author)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID", err)
+			return filter, false
+		}
+		filter.AuthorID = &authorID
+	}
+	return filter, true
+}
+
+// ListLayers lists the annotation layers over a book with their sizes
+// GET /api/books/:id/layers
+func (h *AnnotationLayerHandlers) ListLayers(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	bookID, ok := getUUIDParam(c, "id", "book ID")
+	if !ok {
+		return
+	}
+
+	layers, err := h.layerService.Layers(c.Request.Context(), userID, bookID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve layers", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Layers retrieved successfully", layers)
+}
+
+// SharedAnnotations returns a layer's annotations placed in the user's copy
+// of a book
+// GET /api/books/:id/shared-annotations
+func (h *AnnotationLayerHandlers) SharedAnnotations(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	bookID, ok := getUUIDParam(c, "id", "book ID")
+	if !ok {
+		return
+	}
+	layer, err := services.ParseAnnotationLayer(c.Query("layer"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid layer", err)
+		return
+	}
+	filter, ok := sharedAnnotationFilter(c, layer)
+	if !ok {
+		return
+	}
+
+	annotations, err := h.layerService.SharedAnnotations(c.Request.Context(), userID, bookID, filter)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve shared annotations", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Shared annotations retrieved successfully", annotations)
+}
+
+// GroupLayer returns what a group's members share over the user's copy of
+// a book
+// GET /api/groups/:id/layer?book_id=
+func (h *AnnotationLayerHandlers) GroupLayer(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+	bookID, err := uuid.Parse(c.Query("book_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid or missing book_id", err)
+		return
+	}
+	filter, ok := sharedAnnotationFilter(c, services.AnnotationLayer{Kind: "group", GroupID: &groupID})
+	if !ok {
+		return
+	}
+
+	annotations, err := h.layerService.SharedAnnotations(c.Request.Context(), userID, bookID, filter)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve group layer", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Group layer retrieved successfully", annotations)
+}
+
+// ListAnnotationReplies lists the replies to a shared annotation
+// GET /api/annotations/:id/replies
+func (h *AnnotationLayerHandlers) ListAnnotationReplies(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	annotationID, ok := getUUIDParam(c, "id", "annotation ID")
+	if !ok {
+		return
+	}
+
+	page := utils.GetIntQuery(c, "page", 1, 1, 100000)
+	perPage := utils.GetIntQuery(c, "per_page", 50, 1, 100)
+
+	replies, total, err := h.layerService.Replies(c.Request.Context(), userID, annotationID, perPage, (page-1)*perPage)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve replies", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Replies retrieved successfully", replies, utils.PaginationResponse{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      int(total),
+		TotalPages: (int(total) + perPage - 1) / perPage,
+	})
+}
+
+// CreateAnnotationReply replies to a shared annotation
+// POST /api/annotations/:id/replies
+func (h *AnnotationLayerHandlers) CreateAnnotationReply(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	annotationID, ok := getUUIDParam(c, "id", "annotation ID")
+	if !ok {
+		return
+	}
+
+	var req services.ReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid reply data", err)
+		return
+	}
+
+	reply, err := h.layerService.Reply(c.Request.Context(), userID, annotationID, req.Content)
+	if err != nil {
+		respondServiceError(c, "Failed to create reply", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Reply created successfully",
+		"data":    reply,
+	})
+}
+
+// DeleteAnnotationReply deletes a reply to an annotation
+// DELETE /api/annotations/:id/replies/:reply_id
+func (h *AnnotationLayerHandlers) DeleteAnnotationReply(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	annotationID, ok := getUUIDParam(c, "id", "annotation ID")
+	if !ok {
+		return
+	}
+	replyID, ok := getUUIDParam(c, "reply_id", "reply ID")
+	if !ok {
+		return
+	}
+
+	if err := h.layerService.DeleteReply(c.Request.Context(), userID, annotationID, replyID); err != nil {
+		respondServiceError(c, "Failed to delete reply", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Reply deleted successfully", nil)
+}
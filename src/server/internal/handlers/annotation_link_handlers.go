@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// AnnotationLinkHandlers serves links between annotations and passages
+type AnnotationLinkHandlers struct {
+	linkService *services.AnnotationLinkService
+}
+
+// NewAnnotationLinkHandlers creates new annotation link handlers
+func NewAnnotationLinkHandlers(linkService *services.AnnotationLinkService) *AnnotationLinkHandlers {
+	return &AnnotationLinkHandlers{linkService: linkService}
+}
+
+// CreateLink links an annotation to another annotation or a passage
+// POST /api/annotations/:id/links
+func (h *AnnotationLinkHandlers) CreateLink(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	annotationID, ok := getUUIDParam(c, "id", "annotation ID")
+	if !ok {
+		return
+	}
+
+	var req services.CreateLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid link data", err)
+		return
+	}
+
+	link, err := h.linkService.CreateLink(c.Request.Context(), userID, annotationID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to create link", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Link created successfully",
+		"data":    link,
+	})
+}
+
+// Links lists an annotation's outgoing links and backlinks
+// GET /api/annotations/:id/links
+func (h *AnnotationLinkHandlers) Links(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	annotationID, ok := getUUIDParam(c, "id", "annotation ID")
+	if !ok {
+		return
+	}
+
+	links, err := h.linkService.Links(c.Request.Context(), userID, annotationID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve links", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Links retrieved successfully", links)
+}
+
+// DeleteLink removes a link
+// DELETE /api/annotations/links/:link_id
+func (h *AnnotationLinkHandlers) DeleteLink(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	linkID, ok := getUUIDParam(c, "link_id", "link ID")
+	if !ok {
+		return
+	}
+
+	if err := h.linkService.DeleteLink(c.Request.Context(), userID, linkID); err != nil {
+		respondServiceError(c, "Failed to delete link", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Link deleted successfully", nil)
+}
+
+// Graph returns the annotations and passages within depth links of an
+// annotation. relation is a comma-separated list of relations to follow.
+// GET /api/annotations/:id/graph?depth=&relation=
+func (h *AnnotationLinkHandlers) Graph(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	annotationID, ok := getUUIDParam(c, "id", "annotation ID")
+	if !ok {
+		return
+	}
+	relations, ok := relationFilter(c)
+	if !ok {
+		return
+	}
+	depth := utils.GetIntQuery(c, "depth", 2, 1, 5)
+
+	graph, err := h.linkService.Graph(c.Request.Context(), userID, annotationID, depth, relations)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve graph", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Graph retrieved successfully", graph)
+}
+
+// ExportGraph downloads the user's annotation graph as JSON or GraphML
+// GET /api/annotations/graph/export?format=json|graphml&book_id=&relation=
+func (h *AnnotationLinkHandlers) ExportGraph(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	bookID, ok := optionalBookID(c)
+	if !ok {
+		return
+	}
+	relations, ok := relationFilter(c)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "graphml" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid format: use json or graphml", nil)
+		return
+	}
+
+	graph, err := h.linkService.FullGraph(c.Request.Context(), userID, bookID, relations)
+	if err != nil {
+		respondServiceError(c, "Failed to export graph", err)
+		return
+	}
+
+	if format == "json" {
+		c.Header("Content-Disposition", `attachment; filename="annotation-graph.json"`)
+		c.JSON(http.StatusOK, graph)
+		return
+	}
+	var buf bytes.Buffer
+	if err := services.WriteGraphML(&buf, graph); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to export graph", err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="annotation-graph.graphml"`)
+	c.Data(http.StatusOK, "application/graphml+xml", buf.Bytes())
+}
+
+// relationFilter parses a comma-separated relation query parameter
+func relationFilter(c *gin.Context) ([]string, bool) {
+	var relations []string
+	for _, relation := range strings.Split(c.Query("relation"), ",") {
+		relation = strings.TrimSpace(relation)
+		if relation == "" {
+			continue
+		}
+		if !services.IsLinkRelation(relation) {
+			utils.ErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("Invalid relation %q", relation), nil)
+			return nil, false
+		}
+		relations = append(relations, relation)
+	}
+	return relations, true
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/classius/server/internal/db"
 	"github.com/classius/server/internal/models"
+	"github.com/classius/server/internal/services"
 	"github.com/classius/server/internal/utils"
 )
 
@@ -28,6 +30,7 @@ type AnnotationFilterRequest struct {
 	DateFrom     string    `json:"date_from" form:"date_from"`
 	DateTo       string    `json:"date_to" form:"date_to"`
 	IsPrivate    *bool     `json:"is_private" form:"is_private"`
+	Visibility   string    `json:"visibility" form:"visibility"` // private, public, group (any group) or group:<id>
 	MinPage      *int      `json:"min_page" form:"min_page"`
 	MaxPage      *int      `json:"max_page" form:"max_page"`
 	SortBy       string    `json:"sort_by" form:"sort_by"`     // date, book, page, type
@@ -61,6 +64,10 @@ type EnhancedAnnotationResponse struct {
 	Color        string    `json:"color"`
 	Tags         []string  `json:"tags"`
 	IsPrivate    bool      `json:"is_private"`
+	Visibility   string    `json:"visibility"`
+	Geometry     *models.AnnotationGeometry `json:"geometry,omitempty"`
+	Ink          *models.InkDrawing         `json:"ink,omitempty"`
+	Attachments  []models.AnnotationAttachment `json:"attachments"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -126,27 +133,16 @@ type ExportAnnotation struct {
 	Content      string    `json:"content"`
 	Color        string    `json:"color"`
 	Tags         []string  `json:"tags"`
+	Geometry     *models.AnnotationGeometry `json:"geometry,omitempty"`
+	Ink          *models.InkDrawing         `json:"ink,omitempty"`
 	BookTitle    string    `json:"book_title"`
 	BookAuthor   string    `json:"book_author"`
+	AnnotatedBy  string    `json:"annotated_by,omitempty"` // The annotation's author, in exports of shared layers
+	Visibility   string    `json:"visibility"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
-// BulkActionRequest represents bulk operations on annotations
-type BulkActionRequest struct {
-	AnnotationIDs []string               `json:"annotation_ids" binding:"required"`
-	Action        string                 `json:"action" binding:"required"` // delete, update_tags, update_color, toggle_private
-	Parameters    map[string]interface{} `json:"parameters,omitempty"`
-}
-
-// BulkActionResponse represents the result of bulk operations
-type BulkActionResponse struct {
-	Success   int      `json:"success"`
-	Failed    int      `json:"failed"`
-	Errors    []string `json:"errors,omitempty"`
-	UpdatedAt time.Time `json:"updated_at"`
-}
-
 // GetAnnotationsAdvanced returns annotations with advanced filtering and pagination
 func GetAnnotationsAdvanced(c *gin.Context) {
 	userID, exists := c.Get("user_id")
@@ -225,14 +221,23 @@ func CreateAnnotationEnhanced(c *gin.Context) {
 	}
 
 	// Validate annotation type
-	validTypes := map[string]bool{"highlight": true, "note": true, "bookmark": true}
-	if !validTypes[req.Type] {
+	if !models.IsAnnotationType(req.Type) {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid annotation type", nil)
 		return
 	}
+	if err := services.ValidateAnnotationShape(req.Type, req.Geometry, req.Ink); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid annotation shape", err)
+		return
+	}
 
 	database := db.DB
 
+	tags, err := services.ResolveTags(database, userUUID, req.Tags)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid tags", err)
+		return
+	}
+
 	// Verify user has access to the book
 	var userBook models.UserBook
 	if err := database.Where("user_id = ? AND book_id = ? AND deleted_at IS NULL", userUUID, req.BookID).
@@ -245,6 +250,12 @@ func CreateAnnotationEnhanced(c *gin.Context) {
 		return
 	}
 
+	visibility, err := services.ResolveVisibility(database, userUUID, req.requestedVisibility())
+	if err != nil {
+		respondServiceError(c, "Invalid visibility", err)
+		return
+	}
+
 	// Create annotation
 	annotation := models.Annotation{
 		UserID:        userUUID,
@@ -256,11 +267,23 @@ func CreateAnnotationEnhanced(c *gin.Context) {
 		SelectedText:  req.SelectedText,
 		Content:       req.Content,
 		Color:         req.Color,
-		Tags:          req.Tags,
-		IsPrivate:     req.IsPrivate,
+		Tags:          tags,
+		IsPrivate:     visibility == models.VisibilityPrivate,
+		Visibility:    visibility,
+		Geometry:      req.Geometry,
+		Ink:           req.Ink,
 	}
 
-	if err := database.Create(&annotation).Error; err != nil {
+	err = database.Transaction(func(tx *gorm.DB) error {
+		if _, err := services.RegisterTags(tx, userUUID, tags); err != nil {
+			return err
+		}
+		if err := tx.Create(&annotation).Error; err != nil {
+			return err
+		}
+		return services.RecordAnnotationChange(tx, userUUID, []uuid.UUID{annotation.ID})
+	})
+	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create annotation", nil)
 		return
 	}
@@ -270,6 +293,7 @@ func CreateAnnotationEnhanced(c *gin.Context) {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Annotation created but failed to load details", nil)
 		return
 	}
+	recordHighlightActivity(database, &annotation)
 
 	response := convertToEnhancedResponse(&annotation)
 	utils.SuccessResponse(c, "Annotation created successfully", response)
@@ -323,12 +347,39 @@ func UpdateAnnotationEnhanced(c *gin.Context) {
 	if req.Color != "" {
 		updateData["color"] = req.Color
 	}
+	var tags []string
 	if req.Tags != nil {
-		updateData["tags"] = req.Tags
+		if tags, err = services.ResolveTags(database, userUUID, req.Tags); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid tags", err)
+			return
+		}
+		updateData["tags"] = tags
 	}
 	if req.IsPrivate != nil {
 		updateData["is_private"] = *req.IsPrivate
 	}
+	if req.Visibility != "" {
+		visibility, err := services.ResolveVisibility(database, userUUID, req.Visibility)
+		if err != nil {
+			respondServiceError(c, "Invalid visibility", err)
+			return
+		}
+		updateData["visibility"] = visibility
+		updateData["is_private"] = visibility == models.VisibilityPrivate
+	}
+	if req.Geometry != nil || req.Ink != nil {
+		geometry, ink := annotation.Geometry, annotation.Ink
+		if req.Geometry != nil {
+			geometry, updateData["geometry"] = req.Geometry, req.Geometry
+		}
+		if req.Ink != nil {
+			ink, updateData["ink"] = req.Ink, req.Ink
+		}
+		if err := services.ValidateAnnotationShape(annotation.Type, geometry, ink); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid annotation shape", err)
+			return
+		}
+	}
 
 	if len(updateData) == 0 {
 		utils.ErrorResponse(c, http.StatusBadRequest, "No valid updates provided", nil)
@@ -336,7 +387,16 @@ func UpdateAnnotationEnhanced(c *gin.Context) {
 	}
 
 	// Update annotation
-	if err := database.Model(&annotation).Updates(updateData).Error; err != nil {
+	err = database.Transaction(func(tx *gorm.DB) error {
+		if _, err := services.RegisterTags(tx, userUUID, tags); err != nil {
+			return err
+		}
+		if err := tx.Model(&annotation).Updates(updateData).Error; err != nil {
+			return err
+		}
+		return services.RecordAnnotationChange(tx, userUUID, []uuid.UUID{annotation.ID}, updatedFields(updateData)...)
+	})
+	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update annotation", nil)
 		return
 	}
@@ -346,8 +406,17 @@ func UpdateAnnotationEnhanced(c *gin.Context) {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Update completed but failed to load details", nil)
 		return
 	}
+	recordHighlightActivity(database, &annotation)
 
 	response := convertToEnhancedResponse(&annotation)
+	attachments, err := services.AnnotationAttachments(database, []uuid.UUID{annotation.ID})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Update completed but failed to load details", nil)
+		return
+	}
+	if list := attachments[annotation.ID]; list != nil {
+		response.Attachments = list
+	}
 	utils.SuccessResponse(c, "Annotation updated successfully", response)
 }
 
@@ -385,8 +454,14 @@ func DeleteAnnotationEnhanced(c *gin.Context) {
 		return
 	}
 
-	// Soft delete
-	if err := database.Delete(&annotation).Error; err != nil {
+	// Soft delete, leaving a tombstone for sync
+	err = database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&annotation).Error; err != nil {
+			return err
+		}
+		return services.RecordAnnotationChange(tx, userUUID, []uuid.UUID{annotation.ID})
+	})
+	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete annotation", nil)
 		return
 	}
@@ -394,44 +469,35 @@ func DeleteAnnotationEnhanced(c *gin.Context) {
 	utils.SuccessResponse(c, "Annotation deleted successfully", nil)
 }
 
-// BulkAnnotationActions performs bulk operations on annotations
-func BulkAnnotationActions(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
-		return
-	}
-
-	userUUID, err := uuid.Parse(userID.(string))
-	if err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID", nil)
+// GetAnnotationSVG renders an area or ink annotation as an SVG image
+// GET /api/annotations/:id/svg
+func GetAnnotationSVG(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
 		return
 	}
-
-	var req BulkActionRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid bulk action data", err)
+	annotationID, ok := getUUIDParam(c, "id", "annotation ID")
+	if !ok {
 		return
 	}
 
-	if len(req.AnnotationIDs) == 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "No annotations specified", nil)
+	var annotation models.Annotation
+	result := db.DB.Where("id = ? AND user_id = ?", annotationID, userID).Limit(1).Find(&annotation)
+	if result.Error != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve annotation", nil)
 		return
 	}
-
-	if len(req.AnnotationIDs) > 100 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Too many annotations (max 100)", nil)
+	if result.RowsAffected == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "Annotation not found", nil)
 		return
 	}
-
-	database := db.DB
-	response, err := performBulkAction(database, userUUID, req)
-	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Bulk action failed", err)
+	if !services.HasShape(&annotation) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Annotation has no geometry or ink", nil)
 		return
 	}
 
-	utils.SuccessResponse(c, "Bulk action completed", response)
+	c.Header("Cache-Control", "private, max-age=60")
+	c.Data(http.StatusOK, "image/svg+xml", services.RenderAnnotationSVG(&annotation))
 }
 
 // ExportAnnotations exports annotations in various formats
@@ -448,20 +514,60 @@ func ExportAnnotations(c *gin.Context) {
 		return
 	}
 
-	format := c.DefaultQuery("format", "csv") // csv, json
+	format := c.DefaultQuery("format", "csv") // csv, json, markdown, logseq, readwise, zip
 	bookID := c.Query("book_id")
 	annotationType := c.Query("type")
 
+	// The user's own annotations unless another layer is asked for
+	layer := services.AnnotationLayer{Kind: services.LayerMine}
+	if layerName := c.Query("layer"); layerName != "" {
+		if layer, err = services.ParseAnnotationLayer(layerName); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid layer", err)
+			return
+		}
+	}
+	shared := layer.Kind != services.LayerMine
+
 	database := db.DB
 
+	if services.IsAnnotationExportFormat(format) {
+		if shared {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid layer", fmt.Errorf("%s exports only include your own annotations", format))
+			return
+		}
+		exportAnnotationDocuments(c, database, userUUID, format, annotationType)
+		return
+	}
+	if err := services.RequireLayerAccess(database, userUUID, layer); err != nil {
+		respondServiceError(c, "Failed to export annotations", err)
+		return
+	}
+
 	// Build query
+	scope, scopeArgs := services.LayerCondition("a", layer, userUUID)
 	query := database.Table("annotations a").
-		Select(`a.id, a.type, a.page_number, a.selected_text, a.content, a.color, a.tags, 
-		        a.created_at, a.updated_at, b.title as book_title, b.author as book_author`).
+		Select(`a.id, a.type, a.page_number, a.selected_text, a.content, a.color, a.tags, a.geometry, a.ink, a.visibility,
+		        a.created_at, a.updated_at, b.title as book_title, b.author as book_author,
+		        COALESCE(NULLIF(u.full_name, ''), u.username) as annotated_by`).
 		Joins("JOIN books b ON a.book_id = b.id").
-		Where("a.user_id = ? AND a.deleted_at IS NULL AND b.deleted_at IS NULL", userUUID)
+		Joins("JOIN users u ON u.id = a.user_id").
+		Where("a.deleted_at IS NULL AND b.deleted_at IS NULL").
+		Where(scope, scopeArgs...)
 
-	if bookID != "" {
+	if bookID != "" && shared {
+		// Shared annotations are on other readers' copies of the book
+		bookUUID, err := uuid.Parse(bookID)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid book ID", err)
+			return
+		}
+		match, matchArgs, err := services.AnnotationBookMatch(database, userUUID, bookUUID)
+		if err != nil {
+			respondServiceError(c, "Failed to export annotations", err)
+			return
+		}
+		query = query.Where(match, matchArgs...)
+	} else if bookID != "" {
 		query = query.Where("a.book_id = ?", bookID)
 	}
 	if annotationType != "" {
@@ -474,9 +580,15 @@ func ExportAnnotations(c *gin.Context) {
 		return
 	}
 
+	if !shared {
+		for i := range annotations {
+			annotations[i].AnnotatedBy = ""
+		}
+	}
+
 	switch format {
 	case "csv":
-		exportAnnotationsCSV(c, annotations)
+		exportAnnotationsCSV(c, annotations, shared)
 	case "json":
 		exportAnnotationsJSON(c, annotations)
 	default:
@@ -490,7 +602,7 @@ func getAnnotationsWithFilters(database *gorm.DB, userID uuid.UUID, req Annotati
 	// Build base query
 	query := database.Table("annotations a").
 		Select(`a.id, a.book_id, a.type, a.page_number, a.start_position, a.end_position, 
-		        a.selected_text, a.content, a.color, a.tags, a.is_private, a.created_at, a.updated_at,
+		        a.selected_text, a.content, a.color, a.tags, a.is_private, a.visibility, a.geometry, a.ink, a.created_at, a.updated_at,
 		        b.title as book_title, b.author as book_author, b.cover_url as book_cover`).
 		Joins("JOIN books b ON a.book_id = b.id").
 		Joins("JOIN user_books ub ON a.book_id = ub.book_id AND ub.user_id = a.user_id").
@@ -515,8 +627,14 @@ func getAnnotationsWithFilters(database *gorm.DB, userID uuid.UUID, req Annotati
 		query = query.Where("b.author IN ?", req.Authors)
 	}
 	if len(req.Tags) > 0 {
-		for _, tag := range req.Tags {
-			query = query.Where("? = ANY(a.tags)", tag)
+		// Each tag matches its descendants too
+		tags, err := services.ResolveTags(database, userID, req.Tags)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		for _, tag := range tags {
+			exact, pattern := services.TagScope(tag)
+			query = query.Where("EXISTS (SELECT 1 FROM unnest(a.tags) AS t(name) WHERE t.name = ? OR t.name LIKE ?)", exact, pattern)
 		}
 	}
 	if len(req.Colors) > 0 {
@@ -525,6 +643,11 @@ func getAnnotationsWithFilters(database *gorm.DB, userID uuid.UUID, req Annotati
 	if req.IsPrivate != nil {
 		query = query.Where("a.is_private = ?", *req.IsPrivate)
 	}
+	if req.Visibility == "group" {
+		query = query.Where("a.visibility LIKE 'group:%'")
+	} else if req.Visibility != "" {
+		query = query.Where("a.visibility = ?", req.Visibility)
+	}
 	if req.MinPage != nil {
 		query = query.Where("a.page_number >= ?", *req.MinPage)
 	}
@@ -589,6 +712,9 @@ func getAnnotationsWithFilters(database *gorm.DB, userID uuid.UUID, req Annotati
 		Color        string    `json:"color"`
 		Tags         []string  `json:"tags"`
 		IsPrivate    bool      `json:"is_private"`
+		Visibility   string    `json:"visibility"`
+		Geometry     *models.AnnotationGeometry `json:"geometry"`
+		Ink          *models.InkDrawing         `json:"ink"`
 		CreatedAt    time.Time `json:"created_at"`
 		UpdatedAt    time.Time `json:"updated_at"`
 		BookTitle    string    `json:"book_title"`
@@ -622,11 +748,30 @@ func getAnnotationsWithFilters(database *gorm.DB, userID uuid.UUID, req Annotati
 			Color:        result.Color,
 			Tags:         result.Tags,
 			IsPrivate:    result.IsPrivate,
+			Visibility:   result.Visibility,
+			Geometry:     result.Geometry,
+			Ink:          result.Ink,
+			Attachments:  []models.AnnotationAttachment{},
 			CreatedAt:    result.CreatedAt,
 			UpdatedAt:    result.UpdatedAt,
 		}
 	}
 
+	// Attach files in one query for the page
+	annotationIDs := make([]uuid.UUID, len(annotations))
+	for i := range annotations {
+		annotationIDs[i] = annotations[i].ID
+	}
+	attachments, err := services.AnnotationAttachments(database, annotationIDs)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	for i := range annotations {
+		if list := attachments[annotations[i].ID]; list != nil {
+			annotations[i].Attachments = list
+		}
+	}
+
 	// Build pagination response
 	totalPages := int((total + int64(req.PerPage) - 1) / int64(req.PerPage))
 	pagination := &PaginationResponse{
@@ -773,88 +918,22 @@ func getAnnotationStats(database *gorm.DB, userID uuid.UUID) (*AnnotationStatsRe
 	return stats, nil
 }
 
-func performBulkAction(database *gorm.DB, userID uuid.UUID, req BulkActionRequest) (*BulkActionResponse, error) {
-	response := &BulkActionResponse{
-		UpdatedAt: time.Now(),
-	}
-
-	// Verify all annotations belong to the user
-	var count int64
-	database.Model(&models.Annotation{}).
-		Where("id IN ? AND user_id = ? AND deleted_at IS NULL", req.AnnotationIDs, userID).
-		Count(&count)
-
-	if int(count) != len(req.AnnotationIDs) {
-		return nil, fmt.Errorf("some annotations not found or not accessible")
+// recordHighlightActivity shows a highlight made public in the author's
+// followers' feeds
+func recordHighlightActivity(database *gorm.DB, annotation *models.Annotation) {
+	if err := services.RecordHighlightActivity(database, annotation); err != nil {
+		fmt.Printf("Warning: %v\n", err)
 	}
+}
 
-	switch req.Action {
-	case "delete":
-		result := database.Where("id IN ? AND user_id = ?", req.AnnotationIDs, userID).Delete(&models.Annotation{})
-		if result.Error != nil {
-			return nil, result.Error
-		}
-		response.Success = int(result.RowsAffected)
-
-	case "update_tags":
-		tags, ok := req.Parameters["tags"].([]interface{})
-		if !ok {
-			return nil, fmt.Errorf("invalid tags parameter")
-		}
-		
-		var tagStrings []string
-		for _, tag := range tags {
-			if tagStr, ok := tag.(string); ok {
-				tagStrings = append(tagStrings, tagStr)
-			}
-		}
-
-		result := database.Model(&models.Annotation{}).
-			Where("id IN ? AND user_id = ?", req.AnnotationIDs, userID).
-			Update("tags", tagStrings)
-		if result.Error != nil {
-			return nil, result.Error
-		}
-		response.Success = int(result.RowsAffected)
-
-	case "update_color":
-		color, ok := req.Parameters["color"].(string)
-		if !ok {
-			return nil, fmt.Errorf("invalid color parameter")
-		}
-
-		result := database.Model(&models.Annotation{}).
-			Where("id IN ? AND user_id = ?", req.AnnotationIDs, userID).
-			Update("color", color)
-		if result.Error != nil {
-			return nil, result.Error
-		}
-		response.Success = int(result.RowsAffected)
-
-	case "toggle_private":
-		// This requires individual updates since we need to toggle each one
-		for _, annotationID := range req.AnnotationIDs {
-			var annotation models.Annotation
-			if err := database.Where("id = ? AND user_id = ?", annotationID, userID).First(&annotation).Error; err != nil {
-				response.Failed++
-				response.Errors = append(response.Errors, fmt.Sprintf("Failed to find annotation %s", annotationID))
-				continue
-			}
-
-			if err := database.Model(&annotation).Update("is_private", !annotation.IsPrivate).Error; err != nil {
-				response.Failed++
-				response.Errors = append(response.Errors, fmt.Sprintf("Failed to update annotation %s", annotationID))
-				continue
-			}
-
-			response.Success++
-		}
-
-	default:
-		return nil, fmt.Errorf("unsupported bulk action: %s", req.Action)
+// updatedFields lists the columns in an update map, for sync field revisions
+func updatedFields(updateData map[string]interface{}) []string {
+	fields := make([]string, 0, len(updateData))
+	for field := range updateData {
+		fields = append(fields, field)
 	}
-
-	return response, nil
+	sort.Strings(fields)
+	return fields
 }
 
 func convertToEnhancedResponse(annotation *models.Annotation) EnhancedAnnotationResponse {
@@ -873,12 +952,18 @@ func convertToEnhancedResponse(annotation *models.Annotation) EnhancedAnnotation
 		Color:        annotation.Color,
 		Tags:         annotation.Tags,
 		IsPrivate:    annotation.IsPrivate,
+		Visibility:   annotation.Visibility,
+		Geometry:     annotation.Geometry,
+		Ink:          annotation.Ink,
+		Attachments:  []models.AnnotationAttachment{},
 		CreatedAt:    annotation.CreatedAt,
 		UpdatedAt:    annotation.UpdatedAt,
 	}
 }
 
-func exportAnnotationsCSV(c *gin.Context, annotations []ExportAnnotation) {
+// exportAnnotationsCSV writes annotations as CSV, with who wrote each one
+// for shared layers
+func exportAnnotationsCSV(c *gin.Context, annotations []ExportAnnotation, shared bool) {
 	c.Header("Content-Type", "text/csv")
 	c.Header("Content-Disposition", `attachment; filename="annotations.csv"`)
 
@@ -886,12 +971,16 @@ func exportAnnotationsCSV(c *gin.Context, annotations []ExportAnnotation) {
 	defer writer.Flush()
 
 	// Write header
-	writer.Write([]string{"Book Title", "Author", "Type", "Page", "Selected Text", "Content", "Color", "Tags", "Created At"})
+	header := []string{"Book Title", "Author", "Type", "Page", "Selected Text", "Content", "Color", "Tags", "Created At"}
+	if shared {
+		header = append(header, "Annotated By", "Visibility")
+	}
+	writer.Write(header)
 
 	// Write data
 	for _, annotation := range annotations {
 		tags := strings.Join(annotation.Tags, ", ")
-		writer.Write([]string{
+		record := []string{
 			annotation.BookTitle,
 			annotation.BookAuthor,
 			annotation.Type,
@@ -901,7 +990,11 @@ func exportAnnotationsCSV(c *gin.Context, annotations []ExportAnnotation) {
 			annotation.Color,
 			tags,
 			annotation.CreatedAt.Format("2006-01-02 15:04:05"),
-		})
+		}
+		if shared {
+			record = append(record, annotation.AnnotatedBy, annotation.Visibility)
+		}
+		writer.Write(record)
 	}
 }
 
@@ -914,4 +1007,33 @@ func exportAnnotationsJSON(c *gin.Context, annotations []ExportAnnotation) {
 		"exported_at": time.Now(),
 		"count":       len(annotations),
 	})
-}
\ No newline at end of file
+}
+
+// exportAnnotationDocuments exports annotations as Markdown, Logseq,
+// Readwise CSV or a zip of Markdown files, using the user's own Markdown
+// template unless template=default is given
+func exportAnnotationDocuments(c *gin.Context, database *gorm.DB, userID uuid.UUID, format, annotationType string) {
+	bookID, ok := optionalBookID(c)
+	if !ok {
+		return
+	}
+
+	opts := services.AnnotationExportOptions{Format: format, BookID: bookID, Type: annotationType}
+	if c.Query("template") != "default" {
+		preferences, err := loadUserPreferences(database, userID)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to export annotations", err)
+			return
+		}
+		opts.Template = preferences.ExportSettings.MarkdownTemplate
+	}
+
+	file, err := services.NewAnnotationExportService(database).Export(c.Request.Context(), userID, opts)
+	if err != nil {
+		respondServiceError(c, "Failed to export annotations", err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Filename))
+	c.Data(http.StatusOK, file.ContentType, file.Data)
+}
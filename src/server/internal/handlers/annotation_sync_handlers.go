@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// AnnotationSyncHandlers syncs annotations between devices
+type AnnotationSyncHandlers struct {
+	syncService *services.AnnotationSyncService
+}
+
+// NewAnnotationSyncHandlers creates new annotation sync handlers
+func NewAnnotationSyncHandlers(syncService *services.AnnotationSyncService) *AnnotationSyncHandlers {
+	return &AnnotationSyncHandlers{syncService: syncService}
+}
+
+// Sync applies a device's annotation changes and returns the server's
+// changes since the device's cursor, with any conflicts to resolve
+// POST /api/annotations/sync
+func (h *AnnotationSyncHandlers) Sync(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	var req services.AnnotationSyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid sync request", err)
+		return
+	}
+	if len(req.Changes) > 500 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Too many changes (max 500)", nil)
+		return
+	}
+
+	response, err := h.syncService.Sync(c.Request.Context(), userID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to sync annotations", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Annotations synchronized successfully", response)
+}
@@ -12,12 +12,13 @@ import (
 	"github.com/classius/server/internal/db"
 	"github.com/classius/server/internal/middleware"
 	"github.com/classius/server/internal/models"
+	"github.com/classius/server/internal/services"
 )
 
 // CreateAnnotationRequest represents the request to create an annotation
 type CreateAnnotationRequest struct {
 	BookID        uuid.UUID `json:"book_id" binding:"required"`
-	Type          string    `json:"type" binding:"required,oneof=highlight note bookmark"`
+	Type          string    `json:"type" binding:"required,oneof=highlight note bookmark area ink"`
 	PageNumber    int       `json:"page_number"`
 	StartPosition int       `json:"start_position"`
 	EndPosition   int       `json:"end_position"`
@@ -25,7 +26,23 @@ type CreateAnnotationRequest struct {
 	Content       string    `json:"content"`
 	Color         string    `json:"color"`
 	Tags          []string  `json:"tags"`
-	IsPrivate     bool      `json:"is_private"`
+	IsPrivate     *bool     `json:"is_private"` // false shares the annotation publicly when no visibility is given
+	Visibility    string    `json:"visibility"` // private (the default), public or group:<id>
+
+	Geometry *models.AnnotationGeometry `json:"geometry"` // Page areas, required for area annotations
+	Ink      *models.InkDrawing         `json:"ink"`      // Pen strokes, required for ink annotations
+}
+
+// requestedVisibility returns the visibility a new annotation asks for.
+// Clients from before visibility existed send is_private alone.
+func (r *CreateAnnotationRequest) requestedVisibility() string {
+	switch {
+	case r.Visibility != "":
+		return r.Visibility
+	case r.IsPrivate != nil && !*r.IsPrivate:
+		return models.VisibilityPublic
+	}
+	return models.VisibilityPrivate
 }
 
 // UpdateAnnotationRequest represents the request to update an annotation
@@ -34,6 +51,10 @@ type UpdateAnnotationRequest struct {
 	Color     string   `json:"color,omitempty"`
 	Tags      []string `json:"tags,omitempty"`
 	IsPrivate *bool    `json:"is_private,omitempty"`
+	Visibility string  `json:"visibility,omitempty"` // private, public or group:<id>; takes precedence over is_private
+
+	Geometry *models.AnnotationGeometry `json:"geometry,omitempty"`
+	Ink      *models.InkDrawing         `json:"ink,omitempty"`
 }
 
 // AnnotationResponse represents an annotation in API responses
@@ -50,23 +71,11 @@ type AnnotationResponse struct {
 	Color         string    `json:"color"`
 	Tags          []string  `json:"tags"`
 	IsPrivate     bool      `json:"is_private"`
+	Visibility    string    `json:"visibility"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 }
 
-// SyncAnnotationsRequest represents bulk annotation sync request
-type SyncAnnotationsRequest struct {
-	LastSyncTime *time.Time                `json:"last_sync_time,omitempty"`
-	Annotations  []CreateAnnotationRequest `json:"annotations"`
-}
-
-// SyncAnnotationsResponse represents bulk annotation sync response
-type SyncAnnotationsResponse struct {
-	ServerAnnotations []AnnotationResponse `json:"server_annotations"`
-	ConflictCount     int                  `json:"conflict_count"`
-	SyncTime          time.Time            `json:"sync_time"`
-}
-
 // ToAnnotationResponse converts an Annotation model to AnnotationResponse
 func ToAnnotationResponse(annotation *models.Annotation) AnnotationResponse {
 	response := AnnotationResponse{
@@ -81,6 +90,7 @@ func ToAnnotationResponse(annotation *models.Annotation) AnnotationResponse {
 		Color:         annotation.Color,
 		Tags:          annotation.Tags,
 		IsPrivate:     annotation.IsPrivate,
+		Visibility:    annotation.Visibility,
 		CreatedAt:     annotation.CreatedAt,
 		UpdatedAt:     annotation.UpdatedAt,
 	}
@@ -196,6 +206,15 @@ func CreateAnnotation(c *gin.Context) {
 		return
 	}
 
+	visibility, err := services.ResolveVisibility(db.DB, user.ID, req.requestedVisibility())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid visibility",
+			"message": err.Error(),
+		})
+		return
+	}
+
 	// Create annotation
 	annotation := models.Annotation{
 		UserID:        user.ID,
@@ -208,10 +227,17 @@ func CreateAnnotation(c *gin.Context) {
 		Content:       req.Content,
 		Color:         req.Color,
 		Tags:          req.Tags,
-		IsPrivate:     req.IsPrivate,
+		IsPrivate:     visibility == models.VisibilityPrivate,
+		Visibility:    visibility,
 	}
 
-	if err := db.DB.Create(&annotation).Error; err != nil {
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&annotation).Error; err != nil {
+			return err
+		}
+		return services.RecordAnnotationChange(tx, user.ID, []uuid.UUID{annotation.ID})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to create annotation",
 			"message": "Unable to save annotation",
@@ -227,6 +253,7 @@ func CreateAnnotation(c *gin.Context) {
 		})
 		return
 	}
+	recordHighlightActivity(db.DB, &annotation)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Annotation created successfully",
@@ -294,6 +321,18 @@ func UpdateAnnotation(c *gin.Context) {
 	if req.IsPrivate != nil {
 		updateData["is_private"] = *req.IsPrivate
 	}
+	if req.Visibility != "" {
+		visibility, err := services.ResolveVisibility(db.DB, user.ID, req.Visibility)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid visibility",
+				"message": err.Error(),
+			})
+			return
+		}
+		updateData["visibility"] = visibility
+		updateData["is_private"] = visibility == models.VisibilityPrivate
+	}
 
 	if len(updateData) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -304,7 +343,13 @@ func UpdateAnnotation(c *gin.Context) {
 	}
 
 	// Update annotation
-	if err := db.DB.Model(&annotation).Updates(updateData).Error; err != nil {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&annotation).Updates(updateData).Error; err != nil {
+			return err
+		}
+		return services.RecordAnnotationChange(tx, user.ID, []uuid.UUID{annotation.ID}, updatedFields(updateData)...)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to update annotation",
 			"message": "Unable to save annotation changes",
@@ -320,6 +365,7 @@ func UpdateAnnotation(c *gin.Context) {
 		})
 		return
 	}
+	recordHighlightActivity(db.DB, &annotation)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Annotation updated successfully",
@@ -364,8 +410,14 @@ func DeleteAnnotation(c *gin.Context) {
 		return
 	}
 
-	// Delete annotation
-	if err := db.DB.Delete(&annotation).Error; err != nil {
+	// Delete annotation, leaving a tombstone for sync
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&annotation).Error; err != nil {
+			return err
+		}
+		return services.RecordAnnotationChange(tx, user.ID, []uuid.UUID{annotation.ID})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to delete annotation",
 			"message": "Unable to delete annotation",
@@ -377,89 +429,3 @@ func DeleteAnnotation(c *gin.Context) {
 		"message": "Annotation deleted successfully",
 	})
 }
-
-// SyncAnnotations handles bidirectional annotation synchronization
-func SyncAnnotations(c *gin.Context) {
-	user, exists := middleware.GetCurrentUser(c)
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":   "User not authenticated",
-			"message": "Please log in to sync annotations",
-		})
-		return
-	}
-
-	var req SyncAnnotationsRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request data",
-			"message": err.Error(),
-		})
-		return
-	}
-
-	now := time.Now()
-	var conflicts int
-
-	// Process incoming annotations from device
-	for _, annotationReq := range req.Annotations {
-		// Verify user has access to the book
-		var userBook models.UserBook
-		if err := db.DB.Where("user_id = ? AND book_id = ?", user.ID, annotationReq.BookID).First(&userBook).Error; err != nil {
-			conflicts++
-			continue // Skip this annotation if user doesn't have access to book
-		}
-
-		// Create annotation
-		annotation := models.Annotation{
-			UserID:        user.ID,
-			BookID:        annotationReq.BookID,
-			Type:          annotationReq.Type,
-			PageNumber:    annotationReq.PageNumber,
-			StartPosition: annotationReq.StartPosition,
-			EndPosition:   annotationReq.EndPosition,
-			SelectedText:  annotationReq.SelectedText,
-			Content:       annotationReq.Content,
-			Color:         annotationReq.Color,
-			Tags:          annotationReq.Tags,
-			IsPrivate:     annotationReq.IsPrivate,
-		}
-
-		if err := db.DB.Create(&annotation).Error; err != nil {
-			conflicts++
-			// Log error but continue with other annotations
-		}
-	}
-
-	// Get server annotations modified since last sync
-	query := db.DB.Preload("Book").Where("user_id = ?", user.ID)
-	if req.LastSyncTime != nil {
-		query = query.Where("updated_at > ?", *req.LastSyncTime)
-	}
-
-	var serverAnnotations []models.Annotation
-	if err := query.Order("updated_at ASC").Find(&serverAnnotations).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to load server annotations",
-			"message": "Unable to retrieve server annotations for sync",
-		})
-		return
-	}
-
-	// Convert to response format
-	var annotationData []AnnotationResponse
-	for _, annotation := range serverAnnotations {
-		annotationData = append(annotationData, ToAnnotationResponse(&annotation))
-	}
-
-	response := SyncAnnotationsResponse{
-		ServerAnnotations: annotationData,
-		ConflictCount:     conflicts,
-		SyncTime:          now,
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Annotations synchronized successfully",
-		"data":    response,
-	})
-}
\ No newline at end of file
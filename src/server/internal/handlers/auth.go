@@ -50,6 +50,12 @@ type UserResponse struct {
 	SubscriptionTier string     `json:"subscription_tier"`
 	LastActive       *time.Time `json:"last_active"`
 	CreatedAt        time.Time  `json:"created_at"`
+
+	IsAdmin            bool       `json:"is_admin"`
+	VerifiedScholar    bool       `json:"verified_scholar"`
+	ScholarField       string     `json:"scholar_field,omitempty"`
+	ScholarAffiliation string     `json:"scholar_affiliation,omitempty"`
+	VerifiedAt         *time.Time `json:"verified_at,omitempty"`
 }
 
 // ToUserResponse converts a User model to UserResponse
@@ -63,6 +69,12 @@ func ToUserResponse(user *models.User) *UserResponse {
 		SubscriptionTier: user.SubscriptionTier,
 		LastActive:       user.LastActive,
 		CreatedAt:        user.CreatedAt,
+
+		IsAdmin:            user.IsAdmin,
+		VerifiedScholar:    user.VerifiedScholar,
+		ScholarField:       user.ScholarField,
+		ScholarAffiliation: user.ScholarAffiliation,
+		VerifiedAt:         user.VerifiedAt,
 	}
 }
 
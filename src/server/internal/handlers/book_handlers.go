@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -221,7 +222,7 @@ func (h *BookHandlers) GetBook(c *gin.Context) {
 	// Get book
 	book, err := h.bookService.GetBook(c.Request.Context(), userUUID, bookID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, services.ErrNotFound) {
 			utils.ErrorResponse(c, http.StatusNotFound, "Book not found", err)
 		} else {
 			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve book", err)
@@ -272,7 +273,7 @@ func (h *BookHandlers) UpdateBook(c *gin.Context) {
 	// Update book
 	book, err := h.bookService.UpdateBook(c.Request.Context(), userUUID, bookID, &req)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, services.ErrNotFound) {
 			utils.ErrorResponse(c, http.StatusNotFound, "Book not found", err)
 		} else {
 			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update book", err)
@@ -315,7 +316,7 @@ func (h *BookHandlers) DeleteBook(c *gin.Context) {
 
 	// Delete book
 	if err := h.bookService.DeleteBook(c.Request.Context(), userUUID, bookID); err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, services.ErrNotFound) {
 			utils.ErrorResponse(c, http.StatusNotFound, "Book not found", err)
 		} else {
 			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete book", err)
@@ -362,7 +363,7 @@ func (h *BookHandlers) DownloadBook(c *gin.Context) {
 	// Get book
 	book, err := h.bookService.GetBook(c.Request.Context(), userUUID, bookID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, services.ErrNotFound) {
 			utils.ErrorResponse(c, http.StatusNotFound, "Book not found", err)
 		} else {
 			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve book", err)
@@ -420,7 +421,7 @@ func (h *BookHandlers) GetBookContent(c *gin.Context) {
 	// Get book
 	book, err := h.bookService.GetBook(c.Request.Context(), userUUID, bookID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, services.ErrNotFound) {
 			utils.ErrorResponse(c, http.StatusNotFound, "Book not found", err)
 		} else {
 			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve book", err)
@@ -475,9 +476,9 @@ func (h *BookHandlers) GetBookText(c *gin.Context) {
 	// Get book content
 	content, err := h.bookService.GetBookContent(c.Request.Context(), userUUID, bookID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, services.ErrNotFound) {
 			utils.ErrorResponse(c, http.StatusNotFound, "Book or content not found", err)
-		} else if strings.Contains(err.Error(), "not yet extracted") {
+		} else if errors.Is(err, services.ErrNotExtracted) {
 			utils.ErrorResponse(c, http.StatusAccepted, "Book is still being processed", err)
 		} else {
 			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve book content", err)
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// maxBulkAnnotations bounds the annotations in one bulk action
+const maxBulkAnnotations = 100
+
+// BulkOperationHandlers applies and undoes bulk annotation actions
+type BulkOperationHandlers struct {
+	bulkService *services.BulkOperationService
+}
+
+// NewBulkOperationHandlers creates new bulk operation handlers
+func NewBulkOperationHandlers(bulkService *services.BulkOperationService) *BulkOperationHandlers {
+	return &BulkOperationHandlers{bulkService: bulkService}
+}
+
+// Apply performs a bulk action on annotations, returning an operation ID
+// to undo it with. With dry_run set, it reports the annotations that
+// would change instead.
+// POST /api/annotations/bulk
+func (h *BulkOperationHandlers) Apply(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	var req services.BulkActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid bulk action data", err)
+		return
+	}
+	if len(req.AnnotationIDs) == 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "No annotations specified", nil)
+		return
+	}
+	if len(req.AnnotationIDs) > maxBulkAnnotations {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Too many annotations (max 100)", nil)
+		return
+	}
+	req.DryRun = req.DryRun || utils.GetBoolQuery(c, "dry_run", false)
+
+	response, err := h.bulkService.Apply(c.Request.Context(), userID, req)
+	if err != nil {
+		respondServiceError(c, "Bulk action failed", err)
+		return
+	}
+
+	if req.DryRun {
+		utils.SuccessResponse(c, "Bulk action previewed", response)
+		return
+	}
+	utils.SuccessResponse(c, "Bulk action completed", response)
+}
+
+// Undo reverts a bulk action within the undo window, leaving annotations
+// changed since then alone
+// POST /api/annotations/bulk/:op_id/undo?dry_run=
+func (h *BulkOperationHandlers) Undo(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	operationID, ok := getUUIDParam(c, "op_id", "operation ID")
+	if !ok {
+		return
+	}
+	dryRun := utils.GetBoolQuery(c, "dry_run", false)
+
+	response, err := h.bulkService.Undo(c.Request.Context(), userID, operationID, dryRun)
+	if err != nil {
+		respondServiceError(c, "Failed to undo bulk action", err)
+		return
+	}
+
+	if dryRun {
+		utils.SuccessResponse(c, "Undo previewed", response)
+		return
+	}
+	utils.SuccessResponse(c, "Bulk action undone", response)
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// ClippingImportHandlers imports highlights and notes from e-readers
+type ClippingImportHandlers struct {
+	clippingImportService *services.ClippingImportService
+}
+
+// NewClippingImportHandlers creates new clipping import handlers
+func NewClippingImportHandlers(clippingImportService *services.ClippingImportService) *ClippingImportHandlers {
+	return &ClippingImportHandlers{clippingImportService: clippingImportService}
+}
+
+// Import creates annotations from a Kindle "My Clippings.txt", a KOReader
+// metadata sidecar or a KOReader JSON export, uploaded as the "file" form
+// field or as the request body. The format is detected unless given.
+// POST /api/annotations/import?format=kindle|koreader|koreader_json&book_id=
+func (h *ClippingImportHandlers) Import(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	bookID, ok := optionalBookID(c)
+	if !ok {
+		return
+	}
+
+	var filename string
+	var data []byte
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxWebAnnotationImportSize)
+	if file, err := c.FormFile("file"); err == nil {
+		filename = file.Filename
+		f, err := file.Open()
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid import file", err)
+			return
+		}
+		defer f.Close()
+		if data, err = io.ReadAll(f); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid import file", err)
+			return
+		}
+	} else {
+		if data, err = io.ReadAll(c.Request.Body); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid import file", err)
+			return
+		}
+	}
+	if len(data) == 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Import file is required", nil)
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		format = services.DetectClippingFormat(filename, data)
+	}
+
+	result, err := h.clippingImportService.Import(c.Request.Context(), userID, bookID, format, data)
+	if err != nil {
+		respondServiceError(c, "Failed to import annotations", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Annotations imported successfully", result)
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// ConcordanceHandlers manages concordance and word-frequency endpoints
+type ConcordanceHandlers struct {
+	bookService        *services.BookService
+	concordanceService *services.ConcordanceService
+}
+
+// NewConcordanceHandlers creates new concordance handlers
+func NewConcordanceHandlers(bookService *services.BookService, concordanceService *services.ConcordanceService) *ConcordanceHandlers {
+	return &ConcordanceHandlers{
+		bookService:        bookService,
+		concordanceService: concordanceService,
+	}
+}
+
+// GetConcordance returns keyword-in-context lines for a word
+// GET /api/books/:id/concordance?word=&stem=&context=&limit=&offset=
+func (h *ConcordanceHandlers) GetConcordance(c *gin.Context) {
+	_, bookID, ok := requireOwnedBook(c, h.bookService)
+	if !ok {
+		return
+	}
+
+	word := strings.TrimSpace(c.Query("word"))
+	if word == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Query parameter 'word' is required", nil)
+		return
+	}
+
+	query := services.KWICQuery{
+		Word:    word,
+		Stem:    utils.GetBoolQuery(c, "stem", false),
+		Context: utils.GetIntQuery(c, "context", 60, 10, 500),
+		Limit:   utils.GetIntQuery(c, "limit", 50, 1, 500),
+		Offset:  utils.GetIntQuery(c, "offset", 0, 0, 1000000),
+	}
+
+	result, err := h.concordanceService.KeywordInContext(c.Request.Context(), bookID, query)
+	if err != nil {
+		respondServiceError(c, "Failed to build concordance", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Concordance retrieved successfully", result)
+}
+
+// GetFrequencies returns the top-N most frequent words in a book
+// GET /api/books/:id/concordance/frequencies?limit=&stopwords=&stem=
+func (h *ConcordanceHandlers) GetFrequencies(c *gin.Context) {
+	_, bookID, ok := requireOwnedBook(c, h.bookService)
+	if !ok {
+		return
+	}
+
+	limit := utils.GetIntQuery(c, "limit", 100, 1, 1000)
+	includeStopwords := utils.GetBoolQuery(c, "stopwords", false)
+	stem := utils.GetBoolQuery(c, "stem", false)
+
+	frequencies, err := h.concordanceService.Frequencies(c.Request.Context(), bookID, limit, includeStopwords, stem)
+	if err != nil {
+		respondServiceError(c, "Failed to compute word frequencies", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Word frequencies retrieved successfully", frequencies)
+}
+
+// GetCollocations returns words that frequently appear near a word
+// GET /api/books/:id/concordance/collocations?word=&window=&min_count=&limit=&stem=&stopwords=
+func (h *ConcordanceHandlers) GetCollocations(c *gin.Context) {
+	_, bookID, ok := requireOwnedBook(c, h.bookService)
+	if !ok {
+		return
+	}
+
+	word := strings.TrimSpace(c.Query("word"))
+	if word == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Query parameter 'word' is required", nil)
+		return
+	}
+
+	collocations, err := h.concordanceService.Collocations(
+		c.Request.Context(),
+		bookID,
+		word,
+		utils.GetBoolQuery(c, "stem", false),
+		utils.GetIntQuery(c, "window", 5, 1, 20),
+		utils.GetIntQuery(c, "min_count", 2, 1, 1000),
+		utils.GetIntQuery(c, "limit", 30, 1, 200),
+		utils.GetBoolQuery(c, "stopwords", false),
+	)
+	if err != nil {
+		respondServiceError(c, "Failed to compute collocations", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Collocations retrieved successfully", collocations)
+}
+
+// GetDistribution returns how occurrences of a word spread across chapters
+// GET /api/books/:id/concordance/distribution?word=&stem=
+func (h *ConcordanceHandlers) GetDistribution(c *gin.Context) {
+	_, bookID, ok := requireOwnedBook(c, h.bookService)
+	if !ok {
+		return
+	}
+
+	word := strings.TrimSpace(c.Query("word"))
+	if word == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Query parameter 'word' is required", nil)
+		return
+	}
+
+	distribution, err := h.concordanceService.Distribution(c.Request.Context(), bookID, word, utils.GetBoolQuery(c, "stem", false))
+	if err != nil {
+		respondServiceError(c, "Failed to compute distribution", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Distribution retrieved successfully", distribution)
+}
+
+// RebuildConcordance rebuilds the concordance index from the extracted text
+// POST /api/books/:id/concordance/rebuild
+func (h *ConcordanceHandlers) RebuildConcordance(c *gin.Context) {
+	_, bookID, ok := requireOwnedBook(c, h.bookService)
+	if !ok {
+		return
+	}
+
+	index, err := h.concordanceService.BuildIndex(c.Request.Context(), bookID)
+	if err != nil {
+		respondServiceError(c, "Failed to rebuild concordance index", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Concordance index rebuilt successfully", index)
+}
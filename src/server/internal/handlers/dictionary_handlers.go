@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// DictionaryHandlers manages dictionary lookups and the lookup log
+type DictionaryHandlers struct {
+	bookService       *services.BookService
+	dictionaryService *services.DictionaryService
+}
+
+// NewDictionaryHandlers creates new dictionary handlers
+func NewDictionaryHandlers(bookService *services.BookService, dictionaryService *services.DictionaryService) *DictionaryHandlers {
+	return &DictionaryHandlers{
+		bookService:       bookService,
+		dictionaryService: dictionaryService,
+	}
+}
+
+// Lookup returns lemma, part of speech, parse and definitions for a word.
+// When book_id is given the lookup is logged against the book and position.
+// GET /api/dictionary/lookup?word=&lang=&book_id=&position=&page=&context=
+func (h *DictionaryHandlers) Lookup(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	word := strings.TrimSpace(c.Query("word"))
+	if word == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Query parameter 'word' is required", nil)
+		return
+	}
+	if len([]rune(word)) > 100 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Word is too long", nil)
+		return
+	}
+
+	req := services.LookupRequest{
+		Word:       word,
+		Language:   c.Query("lang"),
+		PageNumber: utils.GetIntQuery(c, "page", 0, 0, 1000000),
+		Context:    strings.TrimSpace(c.Query("context")),
+	}
+
+	fallbackLanguage := ""
+	if bookIDStr := c.Query("book_id"); bookIDStr != "" {
+		bookID, err := uuid.Parse(bookIDStr)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid book ID", err)
+			return
+		}
+		book, err := h.bookService.GetBook(c.Request.Context(), userID, bookID)
+		if err != nil {
+			respondServiceError(c, "Book not found", err)
+			return
+		}
+		req.BookID = &bookID
+		fallbackLanguage = book.Language
+	}
+	if positionStr := c.Query("position"); positionStr != "" {
+		position, err := strconv.Atoi(positionStr)
+		if err != nil || position < 0 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid position", err)
+			return
+		}
+		req.Position = &position
+	}
+
+	result, err := h.dictionaryService.Lookup(req.Word, req.Language, fallbackLanguage)
+	if err != nil {
+		respondServiceError(c, "Failed to look up word", err)
+		return
+	}
+
+	if req.BookID != nil {
+		// Logging is best-effort; a failure should not hide the definitions
+		if lookup, err := h.dictionaryService.LogLookup(c.Request.Context(), userID, req, result); err == nil {
+			result.LookupID = &lookup.ID
+		}
+	}
+
+	utils.SuccessResponse(c, "Lookup completed successfully", result)
+}
+
+// GetSources lists the loaded dictionaries
+// GET /api/dictionary/sources
+func (h *DictionaryHandlers) GetSources(c *gin.Context) {
+	utils.SuccessResponse(c, "Dictionaries retrieved successfully", h.dictionaryService.Sources())
+}
+
+// GetLookups returns the user's lookup history
+// GET /api/dictionary/lookups?book_id=&page=&per_page=
+func (h *DictionaryHandlers) GetLookups(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	bookID, ok := optionalBookID(c)
+	if !ok {
+		return
+	}
+
+	page := utils.GetIntQuery(c, "page", 1, 1, 100000)
+	perPage := utils.GetIntQuery(c, "per_page", 50, 1, 200)
+
+	lookups, total, err := h.dictionaryService.GetLookups(c.Request.Context(), userID, bookID, perPage, (page-1)*perPage)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve lookups", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Lookups retrieved successfully", lookups, utils.PaginationResponse{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      int(total),
+		TotalPages: (int(total) + perPage - 1) / perPage,
+	})
+}
+
+// GetVocabulary returns the words the user has looked up, grouped by lemma
+// GET /api/dictionary/vocabulary?book_id=&lang=&limit=
+func (h *DictionaryHandlers) GetVocabulary(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	bookID, ok := optionalBookID(c)
+	if !ok {
+		return
+	}
+
+	items, err := h.dictionaryService.GetVocabulary(c.Request.Context(), userID, bookID, c.Query("lang"), utils.GetIntQuery(c, "limit", 200, 1, 1000))
+	if err != nil {
+		respondServiceError(c, "Failed to build vocabulary list", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Vocabulary retrieved successfully", items)
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// DiscussionHandlers serves threaded discussions in groups and about books
+type DiscussionHandlers struct {
+	discussionService *services.DiscussionService
+}
+
+// NewDiscussionHandlers creates new discussion handlers
+func NewDiscussionHandlers(discussionService *services.DiscussionService) *DiscussionHandlers {
+	return &DiscussionHandlers{discussionService: discussionService}
+}
+
+// ListDiscussions lists threads by group_id and/or book_id, sorted by
+// active, recent or top
+// GET /api/discussions
+func (h *DiscussionHandlers) ListDiscussions(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	bookID, ok := optionalBookID(c)
+	if !ok {
+		return
+	}
+	filter := services.DiscussionFilter{BookID: bookID, Sort: c.Query("sort")}
+	if groupIDStr := c.Query("group_id"); groupIDStr != "" {
+		groupID, err := uuid.Parse(groupIDStr)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid group ID", err)
+			return
+		}
+		filter.GroupID = &groupID
+	}
+
+	page := utils.GetIntQuery(c, "page", 1, 1, 100000)
+	perPage := utils.GetIntQuery(c, "per_page", 20, 1, 100)
+
+	threads, total, err := h.discussionService.Threads(c.Request.Context(), userID, filter, perPage, (page-1)*perPage)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve discussions", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Discussions retrieved successfully", threads, utils.PaginationResponse{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      int(total),
+		TotalPages: (int(total) + perPage - 1) / perPage,
+	})
+}
+
+// CreateDiscussion starts a thread, optionally anchored to a passage
+// POST /api/discussions
+func (h *DiscussionHandlers) CreateDiscussion(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	var req services.CreateDiscussionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid discussion data", err)
+		return
+	}
+
+	thread, err := h.discussionService.CreateThread(c.Request.Context(), userID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to create discussion", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Discussion created successfully",
+		"data":    thread,
+	})
+}
+
+// GetDiscussion returns a post with its first per_page replies, nested
+// depth levels deep
+// GET /api/discussions/:id
+func (h *DiscussionHandlers) GetDiscussion(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	postID, ok := getUUIDParam(c, "id", "discussion ID")
+	if !ok {
+		return
+	}
+
+	depth := utils.GetIntQuery(c, "depth", 2, 0, 5)
+	perPage := utils.GetIntQuery(c, "per_page", 20, 1, 100)
+
+	post, err := h.discussionService.Get(c.Request.Context(), userID, postID, depth, perPage)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve discussion", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Discussion retrieved successfully", post)
+}
+
+// UpdateDiscussion edits the user's own post
+// PUT /api/discussions/:id
+func (h *DiscussionHandlers) UpdateDiscussion(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	postID, ok := getUUIDParam(c, "id", "discussion ID")
+	if !ok {
+		return
+	}
+
+	var req services.UpdateDiscussionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid discussion data", err)
+		return
+	}
+
+	post, err := h.discussionService.Update(c.Request.Context(), userID, postID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to update discussion", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Discussion updated successfully", post)
+}
+
+// DeleteDiscussion deletes a post, leaving a tombstone if it has replies
+// DELETE /api/discussions/:id
+func (h *DiscussionHandlers) DeleteDiscussion(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	postID, ok := getUUIDParam(c, "id", "discussion ID")
+	if !ok {
+		return
+	}
+
+	if err := h.discussionService.Delete(c.Request.Context(), userID, postID); err != nil {
+		respondServiceError(c, "Failed to delete discussion", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Discussion deleted successfully", nil)
+}
+
+// ListReplies pages through a post's direct replies, nested depth levels deep
+// GET /api/discussions/:id/replies
+func (h *DiscussionHandlers) ListReplies(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	postID, ok := getUUIDParam(c, "id", "discussion ID")
+	if !ok {
+		return
+	}
+
+	page := utils.GetIntQuery(c, "page", 1, 1, 100000)
+	perPage := utils.GetIntQuery(c, "per_page", 20, 1, 100)
+	depth := utils.GetIntQuery(c, "depth", 2, 1, 5)
+
+	replies, total, err := h.discussionService.Replies(c.Request.Context(), userID, postID, depth, perPage, (page-1)*perPage)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve replies", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Replies retrieved successfully", replies, utils.PaginationResponse{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      int(total),
+		TotalPages: (int(total) + perPage - 1) / perPage,
+	})
+}
+
+// CreateReply replies to a post
+// POST /api/discussions/:id/replies
+func (h *DiscussionHandlers) CreateReply(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	postID, ok := getUUIDParam(c, "id", "discussion ID")
+	if !ok {
+		return
+	}
+
+	var req services.ReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid reply data", err)
+		return
+	}
+
+	reply, err := h.discussionService.Reply(c.Request.Context(), userID, postID, req.Content)
+	if err != nil {
+		respondServiceError(c, "Failed to create reply", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Reply created successfully",
+		"data":    reply,
+	})
+}
+
+// Upvote upvotes a post; voting again has no effect
+// POST /api/discussions/:id/vote
+func (h *DiscussionHandlers) Upvote(c *gin.Context) {
+	h.vote(c, true, "Vote recorded successfully")
+}
+
+// WithdrawVote withdraws the user's upvote of a post
+// DELETE /api/discussions/:id/vote
+func (h *DiscussionHandlers) WithdrawVote(c *gin.Context) {
+	h.vote(c, false, "Vote withdrawn successfully")
+}
+
+func (h *DiscussionHandlers) vote(c *gin.Context, up bool, message string) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	postID, ok := getUUIDParam(c, "id", "discussion ID")
+	if !ok {
+		return
+	}
+
+	post, err := h.discussionService.Vote(c.Request.Context(), userID, postID, up)
+	if err != nil {
+		respondServiceError(c, "Failed to vote", err)
+		return
+	}
+
+	utils.SuccessResponse(c, message, post)
+}
+
+// BookDiscussions returns the anchored threads about one of the user's
+// books, placed in their copy, optionally within range=start-end
+// GET /api/books/:id/discussions
+func (h *DiscussionHandlers) BookDiscussions(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	bookID, ok := getUUIDParam(c, "id", "book ID")
+	if !ok {
+		return
+	}
+	var within *services.TextRange
+	if rangeStr := c.Query("range"); rangeStr != "" {
+		var err error
+		if within, err = services.ParseTextRange(rangeStr); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid range", err)
+			return
+		}
+	}
+
+	threads, err := h.discussionService.BookDiscussions(c.Request.Context(), userID, bookID, within)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve discussions", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Discussions retrieved successfully", threads)
+}
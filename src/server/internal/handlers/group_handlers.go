@@ -0,0 +1,545 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// GroupHandlers serves reading groups, their members, invitations and
+// shared book lists
+type GroupHandlers struct {
+	groupService *services.GroupService
+}
+
+// NewGroupHandlers creates new group handlers
+func NewGroupHandlers(groupService *services.GroupService) *GroupHandlers {
+	return &GroupHandlers{groupService: groupService}
+}
+
+// SetRoleRequest changes a member's role
+type SetRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin moderator member"`
+}
+
+// DiscoverGroups lists public groups, optionally filtered by q and group_type
+// GET /api/groups
+func (h *GroupHandlers) DiscoverGroups(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	page := utils.GetIntQuery(c, "page", 1, 1, 100000)
+	perPage := utils.GetIntQuery(c, "per_page", 20, 1, 100)
+
+	groups, total, err := h.groupService.Discover(c.Request.Context(), userID, c.Query("q"), c.Query("group_type"), perPage, (page-1)*perPage)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve groups", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Groups retrieved successfully", groups, utils.PaginationResponse{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      int(total),
+		TotalPages: (int(total) + perPage - 1) / perPage,
+	})
+}
+
+// MyGroups lists the groups the user belongs to
+// GET /api/groups/mine
+func (h *GroupHandlers) MyGroups(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	groups, err := h.groupService.Mine(c.Request.Context(), userID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve groups", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Groups retrieved successfully", groups)
+}
+
+// CreateGroup creates a group with the user as its admin
+// POST /api/groups
+func (h *GroupHandlers) CreateGroup(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	var req services.CreateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid group data", err)
+		return
+	}
+
+	group, err := h.groupService.Create(c.Request.Context(), userID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to create group", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Group created successfully",
+		"data":    group,
+	})
+}
+
+// GetGroup returns a public group or one the user belongs to
+// GET /api/groups/:id
+func (h *GroupHandlers) GetGroup(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+
+	group, err := h.groupService.Get(c.Request.Context(), userID, groupID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve group", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Group retrieved successfully", group)
+}
+
+// UpdateGroup changes a group's settings
+// PUT /api/groups/:id
+func (h *GroupHandlers) UpdateGroup(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+
+	var req services.UpdateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid group data", err)
+		return
+	}
+
+	group, err := h.groupService.Update(c.Request.Context(), userID, groupID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to update group", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Group updated successfully", group)
+}
+
+// DeleteGroup deletes a group
+// DELETE /api/groups/:id
+func (h *GroupHandlers) DeleteGroup(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+
+	if err := h.groupService.Delete(c.Request.Context(), userID, groupID); err != nil {
+		respondServiceError(c, "Failed to delete group", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Group deleted successfully", nil)
+}
+
+// JoinGroup joins a public group
+// POST /api/groups/:id/join
+func (h *GroupHandlers) JoinGroup(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+
+	group, err := h.groupService.Join(c.Request.Context(), userID, groupID)
+	if err != nil {
+		respondServiceError(c, "Failed to join group", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Joined group successfully", group)
+}
+
+// JoinByLink joins a group with a link invitation's token
+// POST /api/groups/join/:token
+func (h *GroupHandlers) JoinByLink(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	group, err := h.groupService.JoinByLink(c.Request.Context(), userID, c.Param("token"))
+	if err != nil {
+		respondServiceError(c, "Failed to join group", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Joined group successfully", group)
+}
+
+// LeaveGroup leaves a group
+// POST /api/groups/:id/leave
+func (h *GroupHandlers) LeaveGroup(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+
+	if err := h.groupService.Leave(c.Request.Context(), userID, groupID); err != nil {
+		respondServiceError(c, "Failed to leave group", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Left group successfully", nil)
+}
+
+// ListMembers lists a group's members
+// GET /api/groups/:id/members
+func (h *GroupHandlers) ListMembers(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+
+	page := utils.GetIntQuery(c, "page", 1, 1, 100000)
+	perPage := utils.GetIntQuery(c, "per_page", 50, 1, 200)
+
+	members, total, err := h.groupService.Members(c.Request.Context(), userID, groupID, perPage, (page-1)*perPage)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve members", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Members retrieved successfully", members, utils.PaginationResponse{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      int(total),
+		TotalPages: (int(total) + perPage - 1) / perPage,
+	})
+}
+
+// SetMemberRole changes a member's role
+// PUT /api/groups/:id/members/:user_id
+func (h *GroupHandlers) SetMemberRole(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+	memberID, ok := getUUIDParam(c, "user_id", "user ID")
+	if !ok {
+		return
+	}
+
+	var req SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid role", err)
+		return
+	}
+
+	if err := h.groupService.SetRole(c.Request.Context(), userID, groupID, memberID, req.Role); err != nil {
+		respondServiceError(c, "Failed to change role", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Role changed successfully", nil)
+}
+
+// RemoveMember removes a member from a group
+// DELETE /api/groups/:id/members/:user_id
+func (h *GroupHandlers) RemoveMember(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+	memberID, ok := getUUIDParam(c, "user_id", "user ID")
+	if !ok {
+		return
+	}
+
+	if err := h.groupService.RemoveMember(c.Request.Context(), userID, groupID, memberID); err != nil {
+		respondServiceError(c, "Failed to remove member", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Member removed successfully", nil)
+}
+
+// CreateInvitation invites a user by username or creates a link invitation
+// POST /api/groups/:id/invitations
+func (h *GroupHandlers) CreateInvitation(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+
+	var req services.GroupInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid invitation data", err)
+		return
+	}
+
+	invitation, err := h.groupService.Invite(c.Request.Context(), userID, groupID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to create invitation", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Invitation created successfully",
+		"data":    invitation,
+	})
+}
+
+// ListGroupInvitations lists a group's pending invitations
+// GET /api/groups/:id/invitations
+func (h *GroupHandlers) ListGroupInvitations(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+
+	invitations, err := h.groupService.GroupInvitations(c.Request.Context(), userID, groupID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve invitations", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Invitations retrieved successfully", invitations)
+}
+
+// RevokeInvitation revokes a pending invitation
+// DELETE /api/groups/:id/invitations/:invitation_id
+func (h *GroupHandlers) RevokeInvitation(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+	invitationID, ok := getUUIDParam(c, "invitation_id", "invitation ID")
+	if !ok {
+		return
+	}
+
+	if err := h.groupService.RevokeInvitation(c.Request.Context(), userID, groupID, invitationID); err != nil {
+		respondServiceError(c, "Failed to revoke invitation", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Invitation revoked successfully", nil)
+}
+
+// MyInvitations lists the user's pending invitations
+// GET /api/groups/invitations
+func (h *GroupHandlers) MyInvitations(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.groupService.MyInvitations(c.Request.Context(), userID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve invitations", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Invitations retrieved successfully", invitations)
+}
+
+// AcceptInvitation accepts an invitation, joining its group
+// POST /api/groups/invitations/:invitation_id/accept
+func (h *GroupHandlers) AcceptInvitation(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	invitationID, ok := getUUIDParam(c, "invitation_id", "invitation ID")
+	if !ok {
+		return
+	}
+
+	group, err := h.groupService.RespondToInvitation(c.Request.Context(), userID, invitationID, true)
+	if err != nil {
+		respondServiceError(c, "Failed to accept invitation", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Invitation accepted successfully", group)
+}
+
+// DeclineInvitation declines an invitation
+// POST /api/groups/invitations/:invitation_id/decline
+func (h *GroupHandlers) DeclineInvitation(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	invitationID, ok := getUUIDParam(c, "invitation_id", "invitation ID")
+	if !ok {
+		return
+	}
+
+	if _, err := h.groupService.RespondToInvitation(c.Request.Context(), userID, invitationID, false); err != nil {
+		respondServiceError(c, "Failed to decline invitation", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Invitation declined successfully", nil)
+}
+
+// ListGroupBooks lists a group's shared book list
+// GET /api/groups/:id/books
+func (h *GroupHandlers) ListGroupBooks(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+
+	books, err := h.groupService.Books(c.Request.Context(), userID, groupID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve group books", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Group books retrieved successfully", books)
+}
+
+// AddGroupBook adds a book to a group's list
+// POST /api/groups/:id/books
+func (h *GroupHandlers) AddGroupBook(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+
+	var req services.GroupBookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid book data", err)
+		return
+	}
+
+	entry, err := h.groupService.AddBook(c.Request.Context(), userID, groupID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to add group book", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Book added to the group successfully",
+		"data":    entry,
+	})
+}
+
+// UpdateGroupBook changes a book list entry
+// PUT /api/groups/:id/books/:entry_id
+func (h *GroupHandlers) UpdateGroupBook(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+	entryID, ok := getUUIDParam(c, "entry_id", "entry ID")
+	if !ok {
+		return
+	}
+
+	var req services.UpdateGroupBookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid book data", err)
+		return
+	}
+
+	entry, err := h.groupService.UpdateBook(c.Request.Context(), userID, groupID, entryID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to update group book", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Group book updated successfully", entry)
+}
+
+// RemoveGroupBook removes a book from a group's list
+// DELETE /api/groups/:id/books/:entry_id
+func (h *GroupHandlers) RemoveGroupBook(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+	entryID, ok := getUUIDParam(c, "entry_id", "entry ID")
+	if !ok {
+		return
+	}
+
+	if err := h.groupService.RemoveBook(c.Request.Context(), userID, groupID, entryID); err != nil {
+		respondServiceError(c, "Failed to remove group book", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Group book removed successfully", nil)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// getUserUUID extracts the authenticated user's ID from the context,
+// writing an error response and returning false if it is missing or invalid
+func getUserUUID(c *gin.Context) (uuid.UUID, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return uuid.Nil, false
+	}
+
+	userIDStr, ok := userID.(string)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Invalid user ID format", nil)
+		return uuid.Nil, false
+	}
+
+	userUUID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID", err)
+		return uuid.Nil, false
+	}
+
+	return userUUID, true
+}
+
+// getUUIDParam parses a UUID path parameter, writing an error response on failure
+func getUUIDParam(c *gin.Context, name, label string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param(name))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid "+label, err)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// optionalBookID parses an optional book_id query parameter
+func optionalBookID(c *gin.Context) (*uuid.UUID, bool) {
+	bookIDStr := c.Query("book_id")
+	if bookIDStr == "" {
+		return nil, true
+	}
+	bookID, err := uuid.Parse(bookIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid book ID", err)
+		return nil, false
+	}
+	return &bookID, true
+}
+
+// requireOwnedBook resolves the current user and the book in the :id
+// parameter, writing an error response unless the user owns the book
+func requireOwnedBook(c *gin.Context, bookService *services.BookService) (uuid.UUID, uuid.UUID, bool) {
+	userUUID, ok := getUserUUID(c)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	bookID, ok := getUUIDParam(c, "id", "book ID")
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	if _, err := bookService.GetBook(c.Request.Context(), userUUID, bookID); err != nil {
+		respondServiceError(c, "Book not found", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userUUID, bookID, true
+}
+
+// respondServiceError maps the kind of a service error onto an HTTP status
+func respondServiceError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, message, err)
+	case errors.Is(err, services.ErrNotExtracted):
+		utils.ErrorResponse(c, http.StatusAccepted, "Book is still being processed", err)
+	case errors.Is(err, services.ErrPaymentRequired):
+		utils.ErrorResponse(c, http.StatusPaymentRequired, message, err)
+	case errors.Is(err, services.ErrAccessDenied):
+		utils.ErrorResponse(c, http.StatusForbidden, message, err)
+	case errors.Is(err, services.ErrTooLarge), errors.Is(err, services.ErrQuotaExceeded):
+		utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, message, err)
+	case errors.Is(err, services.ErrUnsupportedMedia):
+		utils.ErrorResponse(c, http.StatusUnsupportedMediaType, message, err)
+	case errors.Is(err, services.ErrInvalid):
+		utils.ErrorResponse(c, http.StatusBadRequest, message, err)
+	case errors.Is(err, services.ErrConflict):
+		utils.ErrorResponse(c, http.StatusConflict, message, err)
+	case errors.Is(err, services.ErrExpired):
+		utils.ErrorResponse(c, http.StatusGone, message, err)
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, message, err)
+	}
+}
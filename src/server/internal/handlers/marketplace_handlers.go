@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// maxWebhookSize bounds payment provider webhook payloads
+const maxWebhookSize = 1 << 20
+
+// MarketplaceHandlers serves purchases of paid published notes
+type MarketplaceHandlers struct {
+	marketplaceService *services.MarketplaceService
+}
+
+// NewMarketplaceHandlers creates new marketplace handlers
+func NewMarketplaceHandlers(marketplaceService *services.MarketplaceService) *MarketplaceHandlers {
+	return &MarketplaceHandlers{marketplaceService: marketplaceService}
+}
+
+// CheckoutRequest starts buying a published note
+type CheckoutRequest struct {
+	PublishedNoteID uuid.UUID `json:"published_note_id" binding:"required"`
+}
+
+// SimulatePaymentRequest completes a payment through the fake provider
+type SimulatePaymentRequest struct {
+	Outcome string `json:"outcome" binding:"required,oneof=succeeded failed"`
+}
+
+// Checkout creates an order for a paid note. An Idempotency-Key header
+// makes retries return the same order.
+// POST /api/marketplace/checkout
+func (h *MarketplaceHandlers) Checkout(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	var req CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid checkout data", err)
+		return
+	}
+
+	order, err := h.marketplaceService.Checkout(c.Request.Context(), userID, req.PublishedNoteID, c.GetHeader("Idempotency-Key"))
+	if err != nil {
+		respondServiceError(c, "Failed to check out", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Order created successfully",
+		"data":    order,
+	})
+}
+
+// Webhook receives payment provider events. It is not authenticated; the
+// provider verifies each event's signature.
+// POST /api/payments/webhook/:provider
+func (h *MarketplaceHandlers) Webhook(c *gin.Context) {
+	payload, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookSize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, "Webhook payload is too large", err)
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid webhook payload", err)
+		return
+	}
+
+	if err := h.marketplaceService.HandleWebhook(c.Request.Context(), c.Param("provider"), payload, c.Request.Header); err != nil {
+		respondServiceError(c, "Failed to handle payment event", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Payment event handled", nil)
+}
+
+// ListOrders lists the user's purchases
+// GET /api/marketplace/orders?page=&per_page=
+func (h *MarketplaceHandlers) ListOrders(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	page := utils.GetIntQuery(c, "page", 1, 1, 100000)
+	perPage := utils.GetIntQuery(c, "per_page", 20, 1, 100)
+
+	orders, total, err := h.marketplaceService.Orders(c.Request.Context(), userID, perPage, (page-1)*perPage)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve orders", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Orders retrieved successfully", orders, utils.PaginationResponse{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      int(total),
+		TotalPages: (int(total) + perPage - 1) / perPage,
+	})
+}
+
+// GetOrder returns an order the user bought or sold
+// GET /api/marketplace/orders/:id
+func (h *MarketplaceHandlers) GetOrder(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	orderID, ok := getUUIDParam(c, "id", "order ID")
+	if !ok {
+		return
+	}
+
+	order, err := h.marketplaceService.Order(c.Request.Context(), userID, orderID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve order", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Order retrieved successfully", order)
+}
+
+// RefundOrder refunds a paid order and revokes its access
+// POST /api/marketplace/orders/:id/refund
+func (h *MarketplaceHandlers) RefundOrder(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	orderID, ok := getUUIDParam(c, "id", "order ID")
+	if !ok {
+		return
+	}
+
+	order, err := h.marketplaceService.Refund(c.Request.Context(), userID, orderID)
+	if err != nil {
+		respondServiceError(c, "Failed to refund order", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Order refunded successfully", order)
+}
+
+// SimulatePayment completes or fails a pending order's payment when the
+// fake payment provider is in use
+// POST /api/marketplace/orders/:id/simulate-payment
+func (h *MarketplaceHandlers) SimulatePayment(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	orderID, ok := getUUIDParam(c, "id", "order ID")
+	if !ok {
+		return
+	}
+
+	var req SimulatePaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid payment outcome", err)
+		return
+	}
+
+	order, err := h.marketplaceService.SimulatePayment(c.Request.Context(), userID, orderID, req.Outcome == "succeeded")
+	if err != nil {
+		respondServiceError(c, "Failed to simulate payment", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Payment simulated successfully", order)
+}
+
+// ListEntitlements lists the paid notes the user has bought
+// GET /api/marketplace/entitlements
+func (h *MarketplaceHandlers) ListEntitlements(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	entitlements, err := h.marketplaceService.Entitlements(c.Request.Context(), userID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve entitlements", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Entitlements retrieved successfully", entitlements)
+}
+
+// GetRevenue reports the user's sales as an author between two dates
+// (YYYY-MM-DD, to exclusive)
+// GET /api/marketplace/revenue?from=&to=
+func (h *MarketplaceHandlers) GetRevenue(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	var from, to *time.Time
+	for _, param := range []struct {
+		name string
+		dest **time.Time
+	}{{"from", &from}, {"to", &to}} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid "+param.name+" date", err)
+			return
+		}
+		*param.dest = &t
+	}
+
+	report, err := h.marketplaceService.Revenue(c.Request.Context(), userID, from, to)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve revenue", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Revenue retrieved successfully", report)
+}
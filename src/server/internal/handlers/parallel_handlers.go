@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// ParallelHandlers manages original/translation pairs and their alignment
+type ParallelHandlers struct {
+	alignmentService *services.AlignmentService
+}
+
+// NewParallelHandlers creates new parallel text handlers
+func NewParallelHandlers(alignmentService *services.AlignmentService) *ParallelHandlers {
+	return &ParallelHandlers{alignmentService: alignmentService}
+}
+
+// CreatePairRequest links an original edition with a translation
+type CreatePairRequest struct {
+	OriginalBookID    uuid.UUID `json:"original_book_id" binding:"required"`
+	TranslationBookID uuid.UUID `json:"translation_book_id" binding:"required"`
+}
+
+// CreatePair links two books and starts aligning them
+// POST /api/parallel
+func (h *ParallelHandlers) CreatePair(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	var req CreatePairRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", err)
+		return
+	}
+
+	pair, err := h.alignmentService.CreatePair(c.Request.Context(), userID, req.OriginalBookID, req.TranslationBookID)
+	if err != nil {
+		respondServiceError(c, "Failed to create parallel pair", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, utils.APIResponse{
+		Success: true,
+		Message: "Parallel pair created, alignment started",
+		Data:    pair,
+	})
+}
+
+// GetPairs lists the user's pairs
+// GET /api/parallel?book_id=
+func (h *ParallelHandlers) GetPairs(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	bookID, ok := optionalBookID(c)
+	if !ok {
+		return
+	}
+
+	pairs, err := h.alignmentService.ListPairs(c.Request.Context(), userID, bookID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve parallel pairs", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Parallel pairs retrieved successfully", pairs)
+}
+
+// GetPair returns a single pair with its alignment status
+// GET /api/parallel/:id
+func (h *ParallelHandlers) GetPair(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	pairID, ok := getUUIDParam(c, "id", "pair ID")
+	if !ok {
+		return
+	}
+
+	pair, err := h.alignmentService.GetPair(c.Request.Context(), userID, pairID)
+	if err != nil {
+		respondServiceError(c, "Parallel pair not found", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Parallel pair retrieved successfully", pair)
+}
+
+// DeletePair removes a pair and its alignment; the books are untouched
+// DELETE /api/parallel/:id
+func (h *ParallelHandlers) DeletePair(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	pairID, ok := getUUIDParam(c, "id", "pair ID")
+	if !ok {
+		return
+	}
+
+	if err := h.alignmentService.DeletePair(c.Request.Context(), userID, pairID); err != nil {
+		respondServiceError(c, "Failed to delete parallel pair", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Parallel pair deleted successfully", nil)
+}
+
+// AlignPair re-runs the alignment job for a pair
+// POST /api/parallel/:id/align
+func (h *ParallelHandlers) AlignPair(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	pairID, ok := getUUIDParam(c, "id", "pair ID")
+	if !ok {
+		return
+	}
+
+	pair, err := h.alignmentService.StartAlignment(c.Request.Context(), userID, pairID)
+	if err != nil {
+		respondServiceError(c, "Failed to start alignment", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, utils.APIResponse{
+		Success: true,
+		Message: "Alignment started",
+		Data:    pair,
+	})
+}
+
+// GetSegments returns aligned segment pairs for side-by-side reading
+// GET /api/parallel/:id/segments?level=&chapter=&side=&from=&to=&limit=&offset=
+func (h *ParallelHandlers) GetSegments(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	pairID, ok := getUUIDParam(c, "id", "pair ID")
+	if !ok {
+		return
+	}
+
+	query := services.SegmentQuery{
+		Level:  c.Query("level"),
+		Side:   c.DefaultQuery("side", "original"),
+		From:   utils.GetIntQuery(c, "from", 0, 0, 1<<31-1),
+		To:     utils.GetIntQuery(c, "to", 0, 0, 1<<31-1),
+		Limit:  utils.GetIntQuery(c, "limit", 50, 1, 500),
+		Offset: utils.GetIntQuery(c, "offset", 0, 0, 1000000),
+	}
+	if chapterStr := c.Query("chapter"); chapterStr != "" {
+		chapter, err := strconv.Atoi(chapterStr)
+		if err != nil || chapter < 0 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid chapter", err)
+			return
+		}
+		query.Chapter = &chapter
+	}
+
+	page, err := h.alignmentService.GetSegments(c.Request.Context(), userID, pairID, query)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve aligned segments", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Aligned segments retrieved successfully", page)
+}
+
+// CarryAnnotation copies an annotation onto the matching span of the other book
+// POST /api/parallel/:id/annotations/:annotation_id/carry
+func (h *ParallelHandlers) CarryAnnotation(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	pairID, ok := getUUIDParam(c, "id", "pair ID")
+	if !ok {
+		return
+	}
+	annotationID, ok := getUUIDParam(c, "annotation_id", "annotation ID")
+	if !ok {
+		return
+	}
+
+	carried, err := h.alignmentService.CarryAnnotation(c.Request.Context(), userID, pairID, annotationID)
+	if err != nil {
+		respondServiceError(c, "Failed to carry annotation", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.APIResponse{
+		Success: true,
+		Message: "Annotation carried to the parallel text",
+		Data:    carried,
+	})
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// PopularHighlightHandlers serves the passages many readers highlighted
+type PopularHighlightHandlers struct {
+	popularService *services.PopularHighlightService
+}
+
+// NewPopularHighlightHandlers creates new popular highlight handlers
+func NewPopularHighlightHandlers(popularService *services.PopularHighlightService) *PopularHighlightHandlers {
+	return &PopularHighlightHandlers{popularService: popularService}
+}
+
+// BookPopularHighlights returns the most highlighted passages of a book's
+// text, placed in the user's copy
+// GET /api/books/:id/popular-highlights
+func (h *PopularHighlightHandlers) BookPopularHighlights(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	bookID, ok := getUUIDParam(c, "id", "book ID")
+	if !ok {
+		return
+	}
+	var within *services.TextRange
+	if rangeStr := c.Query("range"); rangeStr != "" {
+		var err error
+		if within, err = services.ParseTextRange(rangeStr); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid range", err)
+			return
+		}
+	}
+	limit := utils.GetIntQuery(c, "limit", 20, 1, 100)
+
+	highlights, err := h.popularService.BookHighlights(c.Request.Context(), userID, bookID, within, limit)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve popular highlights", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Popular highlights retrieved successfully", highlights)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/classius/server/internal/db"
 	"github.com/classius/server/internal/models"
+	"github.com/classius/server/internal/services"
 	"github.com/classius/server/internal/utils"
 )
 
@@ -246,6 +248,7 @@ func UpdateReadingProgress(c *gin.Context) {
 	// Update or create reading progress
 	var progress models.ReadingProgress
 	err = database.Where("user_id = ? AND book_id = ?", userUUID, req.BookID).First(&progress).Error
+	previousPercentage := progress.Percentage
 	
 	if err == gorm.ErrRecordNotFound {
 		// Create new progress record
@@ -296,6 +299,11 @@ func UpdateReadingProgress(c *gin.Context) {
 		return
 	}
 
+	// Starting or finishing the book shows in followers' feeds
+	if err := services.RecordProgressActivity(database, userUUID, progress.BookID, previousPercentage, progress.Percentage); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
 	response := convertProgressToResponse(&progress)
 	utils.SuccessResponse(c, "Reading progress updated successfully", response)
 }
@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/classius/server/internal/models"
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// PublishedNoteHandlers serves published note collections and their
+// overlays
+type PublishedNoteHandlers struct {
+	noteService *services.PublishedNoteService
+}
+
+// NewPublishedNoteHandlers creates new published note handlers
+func NewPublishedNoteHandlers(noteService *services.PublishedNoteService) *PublishedNoteHandlers {
+	return &PublishedNoteHandlers{noteService: noteService}
+}
+
+// SetItemsRequest replaces the annotations in a note's draft
+type SetItemsRequest struct {
+	Items []services.PublishedNoteItemInput `json:"items" binding:"dive"`
+}
+
+// PublishRequest publishes a note's draft as a new version
+type PublishRequest struct {
+	Changelog string `json:"changelog"`
+}
+
+// SubscribeRequest subscribes to a note, optionally pinned to a version
+type SubscribeRequest struct {
+	Version *int `json:"version"` // Omit to follow the latest version
+}
+
+// RateRequest rates a subscribed note
+type RateRequest struct {
+	Rating int    `json:"rating" binding:"required,min=1,max=5"`
+	Review string `json:"review"`
+}
+
+// Catalog lists published notes
+// GET /api/published-notes?book_id=&q=&difficulty=&sort=&page=&per_page=
+func (h *PublishedNoteHandlers) Catalog(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	bookID, ok := optionalBookID(c)
+	if !ok {
+		return
+	}
+
+	page := utils.GetIntQuery(c, "page", 1, 1, 100000)
+	perPage := utils.GetIntQuery(c, "per_page", 20, 1, 100)
+
+	notes, total, err := h.noteService.Catalog(c.Request.Context(), services.PublishedNoteFilter{
+		ViewerID:   userID,
+		BookID:     bookID,
+		Query:      c.Query("q"),
+		Difficulty: c.Query("difficulty"),
+		Sort:       c.Query("sort"),
+		Limit:      perPage,
+		Offset:     (page - 1) * perPage,
+	})
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve published notes", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Published notes retrieved successfully", notes, utils.PaginationResponse{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      int(total),
+		TotalPages: (int(total) + perPage - 1) / perPage,
+	})
+}
+
+// Create starts a draft collection from the user's annotations
+// POST /api/published-notes
+func (h *PublishedNoteHandlers) Create(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	var req services.CreatePublishedNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid published note data", err)
+		return
+	}
+
+	note, err := h.noteService.Create(c.Request.Context(), userID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to create published note", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Published note created successfully",
+		"data":    note,
+	})
+}
+
+// Mine lists the user's own notes, drafts included
+// GET /api/published-notes/mine
+func (h *PublishedNoteHandlers) Mine(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	notes, err := h.noteService.Mine(c.Request.Context(), userID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve published notes", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Published notes retrieved successfully", notes)
+}
+
+// Subscriptions lists the notes the user subscribes to
+// GET /api/published-notes/subscriptions
+func (h *PublishedNoteHandlers) Subscriptions(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	notes, err := h.noteService.Subscriptions(c.Request.Context(), userID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve subscriptions", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Subscriptions retrieved successfully", notes)
+}
+
+// Get returns a note with its versions
+// GET /api/published-notes/:id
+func (h *PublishedNoteHandlers) Get(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	noteID, ok := getUUIDParam(c, "id", "published note ID")
+	if !ok {
+		return
+	}
+
+	note, err := h.noteService.Get(c.Request.Context(), userID, noteID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve published note", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Published note retrieved successfully", note)
+}
+
+// Update changes a note's title, description or difficulty
+// PUT /api/published-notes/:id
+func (h *PublishedNoteHandlers) Update(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	noteID, ok := getUUIDParam(c, "id", "published note ID")
+	if !ok {
+		return
+	}
+
+	var req services.UpdatePublishedNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid published note data", err)
+		return
+	}
+
+	note, err := h.noteService.Update(c.Request.Context(), userID, noteID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to update published note", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Published note updated successfully", note)
+}
+
+// Delete deletes a note
+// DELETE /api/published-notes/:id
+func (h *PublishedNoteHandlers) Delete(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	noteID, ok := getUUIDParam(c, "id", "published note ID")
+	if !ok {
+		return
+	}
+
+	if err := h.noteService.Delete(c.Request.Context(), userID, noteID); err != nil {
+		respondServiceError(c, "Failed to delete published note", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Published note deleted successfully", nil)
+}
+
+// SetItems replaces the annotations in a note's draft
+// PUT /api/published-notes/:id/items
+func (h *PublishedNoteHandlers) SetItems(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	noteID, ok := getUUIDParam(c, "id", "published note ID")
+	if !ok {
+		return
+	}
+
+	var req SetItemsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid items", err)
+		return
+	}
+
+	items, err := h.noteService.SetItems(c.Request.Context(), userID, noteID, req.Items)
+	if err != nil {
+		respondServiceError(c, "Failed to save items", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Items saved successfully", items)
+}
+
+// Publish publishes a note's draft as its next version
+// POST /api/published-notes/:id/publish
+func (h *PublishedNoteHandlers) Publish(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	noteID, ok := getUUIDParam(c, "id", "published note ID")
+	if !ok {
+		return
+	}
+
+	var req PublishRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid publish data", err)
+			return
+		}
+	}
+
+	version, err := h.noteService.Publish(c.Request.Context(), userID, noteID, req.Changelog)
+	if err != nil {
+		respondServiceError(c, "Failed to publish note", err)
+		return
+	}
+
+	message := "Note published successfully"
+	if version.ReviewStatus == models.ReviewSubmitted {
+		message = "Note version submitted for review"
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": message,
+		"data":    version,
+	})
+}
+
+// Withdraw removes a note from the catalog
+// POST /api/published-notes/:id/withdraw
+func (h *PublishedNoteHandlers) Withdraw(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	noteID, ok := getUUIDParam(c, "id", "published note ID")
+	if !ok {
+		return
+	}
+
+	if err := h.noteService.Withdraw(c.Request.Context(), userID, noteID); err != nil {
+		respondServiceError(c, "Failed to withdraw note", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Note withdrawn successfully", nil)
+}
+
+// VersionOverlays returns the overlays of one published version
+// GET /api/published-notes/:id/versions/:version
+func (h *PublishedNoteHandlers) VersionOverlays(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	noteID, ok := getUUIDParam(c, "id", "published note ID")
+	if !ok {
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid version", err)
+		return
+	}
+
+	overlays, err := h.noteService.VersionOverlays(c.Request.Context(), userID, noteID, version)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve overlays", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Overlays retrieved successfully", overlays)
+}
+
+// Subscribe subscribes the user to a note
+// POST /api/published-notes/:id/subscribe
+func (h *PublishedNoteHandlers) Subscribe(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	noteID, ok := getUUIDParam(c, "id", "published note ID")
+	if !ok {
+		return
+	}
+
+	var req SubscribeRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid subscription data", err)
+			return
+		}
+	}
+
+	subscription, err := h.noteService.Subscribe(c.Request.Context(), userID, noteID, req.Version)
+	if err != nil {
+		respondServiceError(c, "Failed to subscribe", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Subscribed successfully", subscription)
+}
+
+// Unsubscribe ends the user's subscription to a note
+// DELETE /api/published-notes/:id/subscribe
+func (h *PublishedNoteHandlers) Unsubscribe(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	noteID, ok := getUUIDParam(c, "id", "published note ID")
+	if !ok {
+		return
+	}
+
+	if err := h.noteService.Unsubscribe(c.Request.Context(), userID, noteID); err != nil {
+		respondServiceError(c, "Failed to unsubscribe", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Unsubscribed successfully", nil)
+}
+
+// Rate rates a note the user subscribes to
+// POST /api/published-notes/:id/ratings
+func (h *PublishedNoteHandlers) Rate(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	noteID, ok := getUUIDParam(c, "id", "published note ID")
+	if !ok {
+		return
+	}
+
+	var req RateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid rating", err)
+		return
+	}
+
+	rating, err := h.noteService.Rate(c.Request.Context(), userID, noteID, req.Rating, req.Review)
+	if err != nil {
+		respondServiceError(c, "Failed to rate note", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Note rated successfully", rating)
+}
+
+// Ratings lists a note's ratings and reviews
+// GET /api/published-notes/:id/ratings?page=&per_page=
+func (h *PublishedNoteHandlers) Ratings(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	noteID, ok := getUUIDParam(c, "id", "published note ID")
+	if !ok {
+		return
+	}
+
+	page := utils.GetIntQuery(c, "page", 1, 1, 100000)
+	perPage := utils.GetIntQuery(c, "per_page", 20, 1, 100)
+
+	ratings, total, err := h.noteService.Ratings(c.Request.Context(), userID, noteID, perPage, (page-1)*perPage)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve ratings", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Ratings retrieved successfully", ratings, utils.PaginationResponse{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      int(total),
+		TotalPages: (int(total) + perPage - 1) / perPage,
+	})
+}
+
+// BookOverlays returns the overlays of the user's subscriptions that match
+// one of their books
+// GET /api/books/:id/overlays
+func (h *PublishedNoteHandlers) BookOverlays(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	bookID, ok := getUUIDParam(c, "id", "book ID")
+	if !ok {
+		return
+	}
+
+	overlays, err := h.noteService.BookOverlays(c.Request.Context(), userID, bookID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve overlays", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Overlays retrieved successfully", overlays)
+}
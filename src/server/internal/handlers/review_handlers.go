@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// ReviewHandlers manages the daily review of highlights and notes
+type ReviewHandlers struct {
+	reviewService *services.ReviewService
+}
+
+// NewReviewHandlers creates new review handlers
+func NewReviewHandlers(reviewService *services.ReviewService) *ReviewHandlers {
+	return &ReviewHandlers{reviewService: reviewService}
+}
+
+// GetDaily returns today's batch of annotations to review
+// GET /api/review/daily?limit=&book_id=
+func (h *ReviewHandlers) GetDaily(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	bookID, ok := optionalBookID(c)
+	if !ok {
+		return
+	}
+
+	daily, err := h.reviewService.Daily(c.Request.Context(), userID, bookID, utils.GetIntQuery(c, "limit", 0, 0, 500))
+	if err != nil {
+		respondServiceError(c, "Failed to build daily review", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Daily review retrieved successfully", daily)
+}
+
+// GetItems lists review items
+// GET /api/review/items?book_id=&favorite=&discarded=&page=&per_page=
+func (h *ReviewHandlers) GetItems(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	bookID, ok := optionalBookID(c)
+	if !ok {
+		return
+	}
+
+	page := utils.GetIntQuery(c, "page", 1, 1, 100000)
+	perPage := utils.GetIntQuery(c, "per_page", 50, 1, 200)
+
+	filter := services.ReviewItemFilter{
+		UserID: userID,
+		BookID: bookID,
+		Limit:  perPage,
+		Offset: (page - 1) * perPage,
+	}
+	if c.Query("favorite") != "" {
+		favorite := utils.GetBoolQuery(c, "favorite", false)
+		filter.Favorite = &favorite
+	}
+	if c.Query("discarded") != "" {
+		discarded := utils.GetBoolQuery(c, "discarded", false)
+		filter.Discarded = &discarded
+	}
+
+	items, total, err := h.reviewService.ListItems(c.Request.Context(), filter)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve review items", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Review items retrieved successfully", items, utils.PaginationResponse{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      int(total),
+		TotalPages: (int(total) + perPage - 1) / perPage,
+	})
+}
+
+// UpdateItem favorites, discards or restores an annotation in the review
+// PUT /api/review/items/:id
+func (h *ReviewHandlers) UpdateItem(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	annotationID, ok := getUUIDParam(c, "id", "annotation ID")
+	if !ok {
+		return
+	}
+
+	var req services.UpdateReviewItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", err)
+		return
+	}
+
+	item, err := h.reviewService.UpdateItem(c.Request.Context(), userID, annotationID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to update review item", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Review item updated successfully", item)
+}
+
+// GradeItem records the answer for a reviewed annotation and reschedules it
+// POST /api/review/items/:id/grade
+func (h *ReviewHandlers) GradeItem(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	annotationID, ok := getUUIDParam(c, "id", "annotation ID")
+	if !ok {
+		return
+	}
+
+	var req GradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", err)
+		return
+	}
+
+	item, err := h.reviewService.Grade(c.Request.Context(), userID, annotationID, req.Grade)
+	if err != nil {
+		respondServiceError(c, "Failed to grade annotation", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Review recorded successfully", item)
+}
+
+// GetSettings returns the review frequency settings
+// GET /api/review/settings
+func (h *ReviewHandlers) GetSettings(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	settings, err := h.reviewService.GetSettings(c.Request.Context(), userID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve review settings", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Review settings retrieved successfully", settings)
+}
+
+// SaveSetting creates or replaces the review setting for all annotations, a book or a tag
+// PUT /api/review/settings
+func (h *ReviewHandlers) SaveSetting(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	var req services.ReviewSettingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", err)
+		return
+	}
+
+	setting, err := h.reviewService.SaveSetting(c.Request.Context(), userID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to save review setting", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Review setting saved successfully", setting)
+}
+
+// DeleteSetting removes a review setting
+// DELETE /api/review/settings/:id
+func (h *ReviewHandlers) DeleteSetting(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	settingID, ok := getUUIDParam(c, "id", "setting ID")
+	if !ok {
+		return
+	}
+
+	if err := h.reviewService.DeleteSetting(c.Request.Context(), userID, settingID); err != nil {
+		respondServiceError(c, "Failed to delete review setting", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Review setting deleted successfully", nil)
+}
+
+// GetDigest returns today's review as a notification payload
+// GET /api/review/digest?limit=
+func (h *ReviewHandlers) GetDigest(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	digest, err := h.reviewService.BuildDigest(c.Request.Context(), userID, utils.GetIntQuery(c, "limit", 0, 0, 500))
+	if err != nil {
+		respondServiceError(c, "Failed to build review digest", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Review digest retrieved successfully", digest)
+}
+
+// SendDigest delivers today's review digest through the notification channel
+// POST /api/review/digest/send?limit=
+func (h *ReviewHandlers) SendDigest(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	if !h.reviewService.DigestEnabled() {
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Review digest channel is not configured", nil)
+		return
+	}
+
+	digest, err := h.reviewService.SendDigest(c.Request.Context(), userID, utils.GetIntQuery(c, "limit", 0, 0, 500))
+	if err != nil {
+		respondServiceError(c, "Failed to send review digest", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Review digest sent successfully", digest)
+}
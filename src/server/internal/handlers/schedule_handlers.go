@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// ScheduleHandlers serves reading groups' schedules and members' progress
+// against them
+type ScheduleHandlers struct {
+	scheduleService *services.ScheduleService
+}
+
+// NewScheduleHandlers creates new schedule handlers
+func NewScheduleHandlers(scheduleService *services.ScheduleService) *ScheduleHandlers {
+	return &ScheduleHandlers{scheduleService: scheduleService}
+}
+
+// scheduleParams resolves the user, the group in :id and the schedule in
+// :schedule_id
+func scheduleParams(c *gin.Context) (uuid.UUID, uuid.UUID, uuid.UUID, bool) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	scheduleID, ok := getUUIDParam(c, "schedule_id", "schedule ID")
+	if !ok {
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	return userID, groupID, scheduleID, true
+}
+
+// ListSchedules lists a group's reading schedules with the user's progress
+// GET /api/groups/:id/schedules
+func (h *ScheduleHandlers) ListSchedules(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+
+	schedules, err := h.scheduleService.List(c.Request.Context(), userID, groupID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve schedules", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Schedules retrieved successfully", schedules)
+}
+
+// CreateSchedule splits a book on the group's list into dated assignments
+// POST /api/groups/:id/schedules
+func (h *ScheduleHandlers) CreateSchedule(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	groupID, ok := getUUIDParam(c, "id", "group ID")
+	if !ok {
+		return
+	}
+
+	var req services.ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid schedule data", err)
+		return
+	}
+
+	schedule, err := h.scheduleService.Create(c.Request.Context(), userID, groupID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to create schedule", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Schedule created successfully",
+		"data":    schedule,
+	})
+}
+
+// GetSchedule returns a schedule with the user's progress in it
+// GET /api/groups/:id/schedules/:schedule_id
+func (h *ScheduleHandlers) GetSchedule(c *gin.Context) {
+	userID, groupID, scheduleID, ok := scheduleParams(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := h.scheduleService.Get(c.Request.Context(), userID, groupID, scheduleID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve schedule", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Schedule retrieved successfully", schedule)
+}
+
+// UpdateSchedule changes a schedule or replans its assignments
+// PUT /api/groups/:id/schedules/:schedule_id
+func (h *ScheduleHandlers) UpdateSchedule(c *gin.Context) {
+	userID, groupID, scheduleID, ok := scheduleParams(c)
+	if !ok {
+		return
+	}
+
+	var req services.UpdateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid schedule data", err)
+		return
+	}
+
+	schedule, err := h.scheduleService.Update(c.Request.Context(), userID, groupID, scheduleID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to update schedule", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Schedule updated successfully", schedule)
+}
+
+// DeleteSchedule deletes a schedule
+// DELETE /api/groups/:id/schedules/:schedule_id
+func (h *ScheduleHandlers) DeleteSchedule(c *gin.Context) {
+	userID, groupID, scheduleID, ok := scheduleParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.scheduleService.Delete(c.Request.Context(), userID, groupID, scheduleID); err != nil {
+		respondServiceError(c, "Failed to delete schedule", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Schedule deleted successfully", nil)
+}
+
+// ScheduleProgress shows which members are behind, on track or ahead
+// GET /api/groups/:id/schedules/:schedule_id/progress
+func (h *ScheduleHandlers) ScheduleProgress(c *gin.Context) {
+	userID, groupID, scheduleID, ok := scheduleParams(c)
+	if !ok {
+		return
+	}
+
+	dashboard, err := h.scheduleService.Progress(c.Request.Context(), userID, groupID, scheduleID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve schedule progress", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Schedule progress retrieved successfully", dashboard)
+}
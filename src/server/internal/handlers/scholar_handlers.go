@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/models"
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// ScholarHandlers serves scholar verification and the admins' review of
+// scholar applications and submitted note versions
+type ScholarHandlers struct {
+	scholarService *services.ScholarService
+	noteService    *services.PublishedNoteService
+}
+
+// NewScholarHandlers creates new scholar handlers
+func NewScholarHandlers(scholarService *services.ScholarService, noteService *services.PublishedNoteService) *ScholarHandlers {
+	return &ScholarHandlers{scholarService: scholarService, noteService: noteService}
+}
+
+// ReviewDecisionRequest approves or rejects an application or version.
+// Rejections and revocations require a comment.
+type ReviewDecisionRequest struct {
+	Comment string `json:"comment"`
+}
+
+// Apply submits a scholar verification application
+// POST /api/scholars/applications
+func (h *ScholarHandlers) Apply(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	var req services.ScholarApplicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid application data", err)
+		return
+	}
+
+	application, err := h.scholarService.Apply(c.Request.Context(), userID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to submit application", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Application submitted successfully",
+		"data":    application,
+	})
+}
+
+// ListApplications lists the user's own applications
+// GET /api/scholars/applications
+func (h *ScholarHandlers) ListApplications(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	applications, err := h.scholarService.Applications(c.Request.Context(), userID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve applications", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Applications retrieved successfully", applications)
+}
+
+// WithdrawApplication withdraws an application before it is reviewed
+// DELETE /api/scholars/applications/:id
+func (h *ScholarHandlers) WithdrawApplication(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	applicationID, ok := getUUIDParam(c, "id", "application ID")
+	if !ok {
+		return
+	}
+
+	if err := h.scholarService.Withdraw(c.Request.Context(), userID, applicationID); err != nil {
+		respondServiceError(c, "Failed to withdraw application", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Application withdrawn successfully", nil)
+}
+
+// GetProfile returns a user's public scholar standing
+// GET /api/scholars/:user_id
+func (h *ScholarHandlers) GetProfile(c *gin.Context) {
+	userID, ok := getUUIDParam(c, "user_id", "user ID")
+	if !ok {
+		return
+	}
+
+	profile, err := h.scholarService.Profile(c.Request.Context(), userID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve scholar profile", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Scholar profile retrieved successfully", profile)
+}
+
+// ListApplicationQueue lists applications by status, submitted by default
+// GET /api/admin/scholar-applications
+func (h *ScholarHandlers) ListApplicationQueue(c *gin.Context) {
+	page := utils.GetIntQuery(c, "page", 1, 1, 100000)
+	perPage := utils.GetIntQuery(c, "per_page", 20, 1, 100)
+
+	applications, total, err := h.scholarService.Queue(c.Request.Context(), c.Query("status"), perPage, (page-1)*perPage)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve applications", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Applications retrieved successfully", applications, utils.PaginationResponse{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      int(total),
+		TotalPages: (int(total) + perPage - 1) / perPage,
+	})
+}
+
+// ApproveApplication verifies the applicant as a scholar
+// POST /api/admin/scholar-applications/:id/approve
+func (h *ScholarHandlers) ApproveApplication(c *gin.Context) {
+	h.decideApplication(c, true)
+}
+
+// RejectApplication rejects an application with a reason
+// POST /api/admin/scholar-applications/:id/reject
+func (h *ScholarHandlers) RejectApplication(c *gin.Context) {
+	h.decideApplication(c, false)
+}
+
+func (h *ScholarHandlers) decideApplication(c *gin.Context, approve bool) {
+	adminID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	applicationID, ok := getUUIDParam(c, "id", "application ID")
+	if !ok {
+		return
+	}
+	req, ok := bindReviewDecision(c)
+	if !ok {
+		return
+	}
+
+	application, err := h.scholarService.Decide(c.Request.Context(), adminID, applicationID, approve, req.Comment)
+	if err != nil {
+		respondServiceError(c, "Failed to review application", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Application "+application.Status+" successfully", application)
+}
+
+// RevokeScholar withdraws a user's scholar verification
+// POST /api/admin/scholars/:user_id/revoke
+func (h *ScholarHandlers) RevokeScholar(c *gin.Context) {
+	adminID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	userID, ok := getUUIDParam(c, "user_id", "user ID")
+	if !ok {
+		return
+	}
+	req, ok := bindReviewDecision(c)
+	if !ok {
+		return
+	}
+
+	if err := h.scholarService.Revoke(c.Request.Context(), adminID, userID, req.Comment); err != nil {
+		respondServiceError(c, "Failed to revoke verification", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Scholar verification revoked successfully", nil)
+}
+
+// ListNoteReviews lists note versions by review status, submitted by default
+// GET /api/admin/note-reviews
+func (h *ScholarHandlers) ListNoteReviews(c *gin.Context) {
+	page := utils.GetIntQuery(c, "page", 1, 1, 100000)
+	perPage := utils.GetIntQuery(c, "per_page", 20, 1, 100)
+
+	reviews, total, err := h.noteService.ReviewQueue(c.Request.Context(), c.Query("status"), perPage, (page-1)*perPage)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve note reviews", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Note reviews retrieved successfully", reviews, utils.PaginationResponse{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      int(total),
+		TotalPages: (int(total) + perPage - 1) / perPage,
+	})
+}
+
+// GetNoteReview returns a note version with the overlays under review
+// GET /api/admin/note-reviews/:id
+func (h *ScholarHandlers) GetNoteReview(c *gin.Context) {
+	versionID, ok := getUUIDParam(c, "id", "version ID")
+	if !ok {
+		return
+	}
+
+	review, err := h.noteService.ReviewDetail(c.Request.Context(), versionID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve note review", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Note review retrieved successfully", review)
+}
+
+// ApproveNoteVersion releases a submitted version to readers
+// POST /api/admin/note-reviews/:id/approve
+func (h *ScholarHandlers) ApproveNoteVersion(c *gin.Context) {
+	h.decideNoteVersion(c, true)
+}
+
+// RejectNoteVersion rejects a submitted version with a reason
+// POST /api/admin/note-reviews/:id/reject
+func (h *ScholarHandlers) RejectNoteVersion(c *gin.Context) {
+	h.decideNoteVersion(c, false)
+}
+
+func (h *ScholarHandlers) decideNoteVersion(c *gin.Context, approve bool) {
+	adminID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	versionID, ok := getUUIDParam(c, "id", "version ID")
+	if !ok {
+		return
+	}
+	req, ok := bindReviewDecision(c)
+	if !ok {
+		return
+	}
+
+	version, err := h.noteService.DecideVersion(c.Request.Context(), adminID, versionID, approve, req.Comment)
+	if err != nil {
+		respondServiceError(c, "Failed to review note version", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Note version "+version.ReviewStatus+" successfully", version)
+}
+
+// ListReviewAudit lists review decisions, newest first, optionally for one
+// subject_type and subject_id
+// GET /api/admin/review-audit
+func (h *ScholarHandlers) ListReviewAudit(c *gin.Context) {
+	subjectType := c.Query("subject_type")
+	if subjectType != "" && subjectType != models.AuditScholarApplication && subjectType != models.AuditNoteVersion {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid subject type", nil)
+		return
+	}
+	var subjectID *uuid.UUID
+	if raw := c.Query("subject_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid subject ID", err)
+			return
+		}
+		subjectID = &id
+	}
+	page := utils.GetIntQuery(c, "page", 1, 1, 100000)
+	perPage := utils.GetIntQuery(c, "per_page", 50, 1, 200)
+
+	entries, total, err := h.scholarService.AuditTrail(c.Request.Context(), subjectType, subjectID, perPage, (page-1)*perPage)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve review audit", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Review audit retrieved successfully", entries, utils.PaginationResponse{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      int(total),
+		TotalPages: (int(total) + perPage - 1) / perPage,
+	})
+}
+
+// bindReviewDecision reads an optional review decision body
+func bindReviewDecision(c *gin.Context) (ReviewDecisionRequest, bool) {
+	var req ReviewDecisionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid review data", err)
+			return req, false
+		}
+	}
+	return req, true
+}
@@ -9,6 +9,7 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/classius/server/internal/db"
+	"github.com/classius/server/internal/services"
 	"github.com/classius/server/internal/utils"
 )
 
@@ -27,6 +28,8 @@ type SearchRequest struct {
 	SortOrder  string   `json:"sort_order" form:"sort_order"` // asc, desc
 	Page       int      `json:"page" form:"page"`
 	PerPage    int      `json:"per_page" form:"per_page"`
+
+	IncludeShared bool `json:"include_shared" form:"include_shared"` // Also search annotations shared with the user
 }
 
 // SearchResponse represents the search response
@@ -74,6 +77,8 @@ type AnnotationResult struct {
 	BookTitle    string  `json:"book_title"`
 	BookAuthor   string  `json:"book_author"`
 	BookID       string  `json:"book_id"`
+	UserID       string  `json:"user_id"`
+	AuthorName   string  `json:"author_name"` // Who wrote it, for shared annotations
 	CreatedAt    string  `json:"created_at"`
 	Relevance    float64 `json:"relevance"`
 	Snippet      string  `json:"snippet"`
@@ -88,6 +93,8 @@ type HighlightResult struct {
 	BookTitle    string   `json:"book_title"`
 	BookAuthor   string   `json:"book_author"`
 	BookID       string   `json:"book_id"`
+	UserID       string   `json:"user_id"`
+	AuthorName   string   `json:"author_name"` // Who wrote it, for shared annotations
 	CreatedAt    string   `json:"created_at"`
 	Relevance    float64  `json:"relevance"`
 	Snippet      string   `json:"snippet"`
@@ -102,6 +109,8 @@ type NoteResult struct {
 	BookTitle    string   `json:"book_title"`
 	BookAuthor   string   `json:"book_author"`
 	BookID       string   `json:"book_id"`
+	UserID       string   `json:"user_id"`
+	AuthorName   string   `json:"author_name"` // Who wrote it, for shared annotations
 	CreatedAt    string   `json:"created_at"`
 	Relevance    float64  `json:"relevance"`
 	Snippet      string   `json:"snippet"`
@@ -348,11 +357,15 @@ func searchBooks(database *gorm.DB, userID uuid.UUID, req SearchRequest) ([]Book
 }
 
 func searchAnnotations(database *gorm.DB, userID uuid.UUID, req SearchRequest) ([]AnnotationResult, int, error) {
+	scope, scopeArgs := annotationScope(userID, req)
 	query := database.Table("annotations a").
 		Select(`a.id, a.type, a.content, a.selected_text, a.page_number, a.color,
-		        a.tags, a.created_at, b.title as book_title, b.author as book_author, b.id as book_id`).
+		        a.tags, a.created_at, b.title as book_title, b.author as book_author, b.id as book_id,
+		        a.user_id, COALESCE(NULLIF(u.full_name, ''), u.username) as author_name`).
 		Joins("JOIN books b ON a.book_id = b.id").
-		Where("a.user_id = ? AND a.deleted_at IS NULL AND b.deleted_at IS NULL", userID)
+		Joins("JOIN users u ON u.id = a.user_id").
+		Where("a.deleted_at IS NULL AND b.deleted_at IS NULL").
+		Where(scope, scopeArgs...)
 
 	// Apply search query
 	if req.Query != "" {
@@ -419,6 +432,8 @@ func searchAnnotations(database *gorm.DB, userID uuid.UUID, req SearchRequest) (
 		BookTitle    string   `json:"book_title"`
 		BookAuthor   string   `json:"book_author"`
 		BookID       string   `json:"book_id"`
+		UserID       string   `json:"user_id"`
+		AuthorName   string   `json:"author_name"`
 	}
 
 	var queryResults []AnnotationQueryResult
@@ -445,6 +460,8 @@ func searchAnnotations(database *gorm.DB, userID uuid.UUID, req SearchRequest) (
 			BookTitle:    result.BookTitle,
 			BookAuthor:   result.BookAuthor,
 			BookID:       result.BookID,
+			UserID:       result.UserID,
+			AuthorName:   result.AuthorName,
 			CreatedAt:    result.CreatedAt,
 			Relevance:    calculateRelevance(req.Query, result.Content, result.SelectedText, ""),
 			Snippet:      snippet,
@@ -455,11 +472,15 @@ func searchAnnotations(database *gorm.DB, userID uuid.UUID, req SearchRequest) (
 }
 
 func searchHighlights(database *gorm.DB, userID uuid.UUID, req SearchRequest) ([]HighlightResult, int, error) {
+	scope, scopeArgs := annotationScope(userID, req)
 	query := database.Table("annotations a").
 		Select(`a.id, a.selected_text, a.page_number, a.color, a.tags, a.created_at,
-		        b.title as book_title, b.author as book_author, b.id as book_id`).
+		        b.title as book_title, b.author as book_author, b.id as book_id,
+		        a.user_id, COALESCE(NULLIF(u.full_name, ''), u.username) as author_name`).
 		Joins("JOIN books b ON a.book_id = b.id").
-		Where("a.user_id = ? AND a.type = 'highlight' AND a.deleted_at IS NULL AND b.deleted_at IS NULL", userID)
+		Joins("JOIN users u ON u.id = a.user_id").
+		Where("a.type = 'highlight' AND a.deleted_at IS NULL AND b.deleted_at IS NULL").
+		Where(scope, scopeArgs...)
 
 	// Apply search query
 	if req.Query != "" {
@@ -522,6 +543,8 @@ func searchHighlights(database *gorm.DB, userID uuid.UUID, req SearchRequest) ([
 		BookTitle    string   `json:"book_title"`
 		BookAuthor   string   `json:"book_author"`
 		BookID       string   `json:"book_id"`
+		UserID       string   `json:"user_id"`
+		AuthorName   string   `json:"author_name"`
 	}
 
 	var queryResults []HighlightQueryResult
@@ -545,6 +568,8 @@ func searchHighlights(database *gorm.DB, userID uuid.UUID, req SearchRequest) ([
 			BookTitle:    result.BookTitle,
 			BookAuthor:   result.BookAuthor,
 			BookID:       result.BookID,
+			UserID:       result.UserID,
+			AuthorName:   result.AuthorName,
 			CreatedAt:    result.CreatedAt,
 			Relevance:    calculateRelevance(req.Query, result.SelectedText, "", ""),
 			Snippet:      snippet,
@@ -555,11 +580,15 @@ func searchHighlights(database *gorm.DB, userID uuid.UUID, req SearchRequest) ([
 }
 
 func searchNotes(database *gorm.DB, userID uuid.UUID, req SearchRequest) ([]NoteResult, int, error) {
+	scope, scopeArgs := annotationScope(userID, req)
 	query := database.Table("annotations a").
 		Select(`a.id, a.content, a.selected_text, a.page_number, a.tags, a.created_at,
-		        b.title as book_title, b.author as book_author, b.id as book_id`).
+		        b.title as book_title, b.author as book_author, b.id as book_id,
+		        a.user_id, COALESCE(NULLIF(u.full_name, ''), u.username) as author_name`).
 		Joins("JOIN books b ON a.book_id = b.id").
-		Where("a.user_id = ? AND a.type = 'note' AND a.deleted_at IS NULL AND b.deleted_at IS NULL", userID)
+		Joins("JOIN users u ON u.id = a.user_id").
+		Where("a.type = 'note' AND a.deleted_at IS NULL AND b.deleted_at IS NULL").
+		Where(scope, scopeArgs...)
 
 	// Apply search query
 	if req.Query != "" {
@@ -623,6 +652,8 @@ func searchNotes(database *gorm.DB, userID uuid.UUID, req SearchRequest) ([]Note
 		BookTitle    string   `json:"book_title"`
 		BookAuthor   string   `json:"book_author"`
 		BookID       string   `json:"book_id"`
+		UserID       string   `json:"user_id"`
+		AuthorName   string   `json:"author_name"`
 	}
 
 	var queryResults []NoteQueryResult
@@ -647,6 +678,8 @@ func searchNotes(database *gorm.DB, userID uuid.UUID, req SearchRequest) ([]Note
 			BookTitle:    result.BookTitle,
 			BookAuthor:   result.BookAuthor,
 			BookID:       result.BookID,
+			UserID:       result.UserID,
+			AuthorName:   result.AuthorName,
 			CreatedAt:    result.CreatedAt,
 			Relevance:    calculateRelevance(req.Query, result.Content, result.SelectedText, ""),
 			Snippet:      snippet,
@@ -656,6 +689,15 @@ func searchNotes(database *gorm.DB, userID uuid.UUID, req SearchRequest) ([]Note
 	return notes, int(count), nil
 }
 
+// annotationScope selects the user's own annotations or, with
+// include_shared, every annotation they may see
+func annotationScope(userID uuid.UUID, req SearchRequest) (string, []interface{}) {
+	if req.IncludeShared {
+		return services.AnnotationVisibility("a", userID)
+	}
+	return "a.user_id = ?", []interface{}{userID}
+}
+
 func getSearchFilters(database *gorm.DB, userID uuid.UUID) (*SearchFilters, error) {
 	filters := &SearchFilters{
 		AvailableTypes: []string{"books", "annotations", "highlights", "notes"},
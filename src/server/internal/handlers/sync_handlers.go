@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// SyncHandlers serves the unified change feed for multi-device sync
+type SyncHandlers struct {
+	changeFeedService *services.ChangeFeedService
+}
+
+// NewSyncHandlers creates new sync handlers
+func NewSyncHandlers(changeFeedService *services.ChangeFeedService) *SyncHandlers {
+	return &SyncHandlers{changeFeedService: changeFeedService}
+}
+
+// GetChanges returns changes to the user's books, tags, bookmarks, reading
+// progress and sessions, preferences and annotations since the cursor
+// GET /api/sync/changes?cursor=&limit=&types=
+func (h *SyncHandlers) GetChanges(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	var types []string
+	for _, entityType := range strings.Split(c.Query("types"), ",") {
+		if entityType = strings.TrimSpace(entityType); entityType != "" {
+			types = append(types, entityType)
+		}
+	}
+
+	changes, err := h.changeFeedService.Changes(c.Request.Context(), userID, c.Query("cursor"),
+		utils.GetIntQuery(c, "limit", 500, 1, 1000), types)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve changes", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Changes retrieved successfully", changes)
+}
+
+// Push applies a batch of changes made on a device
+// POST /api/sync/push
+func (h *SyncHandlers) Push(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	var req services.PushRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid push request", err)
+		return
+	}
+	if len(req.Mutations) > 500 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Too many mutations (max 500)", nil)
+		return
+	}
+
+	response, err := h.changeFeedService.Push(c.Request.Context(), userID, req.Mutations)
+	if err != nil {
+		respondServiceError(c, "Failed to push changes", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Changes pushed successfully", response)
+}
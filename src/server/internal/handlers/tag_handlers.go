@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// TagHandlers serves tag management across annotations and books
+type TagHandlers struct {
+	tagService *services.TagService
+}
+
+// NewTagHandlers creates new tag handlers
+func NewTagHandlers(tagService *services.TagService) *TagHandlers {
+	return &TagHandlers{tagService: tagService}
+}
+
+// RenameTagRequest renames a tag and its descendants
+type RenameTagRequest struct {
+	From      string `json:"from" binding:"required"`
+	To        string `json:"to" binding:"required"`
+	KeepAlias bool   `json:"keep_alias"` // Keep the old name as an alias
+}
+
+// MergeTagsRequest merges tags into a target tag
+type MergeTagsRequest struct {
+	Sources []string `json:"sources" binding:"required,min=1"`
+	Target  string   `json:"target" binding:"required"`
+}
+
+// TagAliasRequest adds an alias to a tag
+type TagAliasRequest struct {
+	Tag   string `json:"tag" binding:"required"`
+	Alias string `json:"alias" binding:"required"`
+}
+
+// ListTags lists the user's tags with their aliases and usage counts
+// GET /api/tags
+func (h *TagHandlers) ListTags(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	tags, err := h.tagService.List(c.Request.Context(), userID)
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve tags", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Tags retrieved successfully", tags)
+}
+
+// RenameTag renames a tag and its descendants on annotations and books
+// POST /api/tags/rename
+func (h *TagHandlers) RenameTag(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	var req RenameTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid rename data", err)
+		return
+	}
+
+	change, err := h.tagService.Rename(c.Request.Context(), userID, req.From, req.To, req.KeepAlias)
+	if err != nil {
+		respondServiceError(c, "Failed to rename tag", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Tag renamed successfully", change)
+}
+
+// MergeTags retags everything tagged with the sources with the target
+// POST /api/tags/merge
+func (h *TagHandlers) MergeTags(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	var req MergeTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid merge data", err)
+		return
+	}
+
+	change, err := h.tagService.Merge(c.Request.Context(), userID, req.Sources, req.Target)
+	if err != nil {
+		respondServiceError(c, "Failed to merge tags", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Tags merged successfully", change)
+}
+
+// AddAlias makes another name resolve to a tag
+// POST /api/tags/aliases
+func (h *TagHandlers) AddAlias(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	var req TagAliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid alias data", err)
+		return
+	}
+
+	alias, err := h.tagService.AddAlias(c.Request.Context(), userID, req.Tag, req.Alias)
+	if err != nil {
+		respondServiceError(c, "Failed to add alias", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Alias added successfully",
+		"data":    alias,
+	})
+}
+
+// RemoveAlias deletes a tag alias
+// DELETE /api/tags/aliases/:id
+func (h *TagHandlers) RemoveAlias(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	aliasID, ok := getUUIDParam(c, "id", "alias ID")
+	if !ok {
+		return
+	}
+
+	if err := h.tagService.RemoveAlias(c.Request.Context(), userID, aliasID); err != nil {
+		respondServiceError(c, "Failed to remove alias", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Alias removed successfully", nil)
+}
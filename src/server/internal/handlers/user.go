@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/classius/server/internal/db"
 	"github.com/classius/server/internal/middleware"
 	"github.com/classius/server/internal/models"
+	"github.com/classius/server/internal/services"
 )
 
 // UpdateUserProfileRequest represents the request to update user profile
@@ -238,6 +240,7 @@ func SaveUserProgress(c *gin.Context) {
 	// Find existing progress or create new
 	var progress models.ReadingProgress
 	result := db.DB.Where("user_id = ? AND book_id = ?", user.ID, req.BookID).First(&progress)
+	previousPercentage := progress.Percentage
 
 	if result.Error != nil {
 		// Create new progress record
@@ -292,6 +295,11 @@ func SaveUserProgress(c *gin.Context) {
 		return
 	}
 
+	// Starting or finishing the book shows in followers' feeds
+	if err := services.RecordProgressActivity(db.DB, user.ID, progress.BookID, previousPercentage, progress.Percentage); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
 	responseData := UserProgressResponse{
 		BookID:            progress.BookID,
 		BookTitle:         progress.Book.Title,
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 
 	"github.com/classius/server/internal/db"
 	"github.com/classius/server/internal/models"
+	"github.com/classius/server/internal/services"
 	"github.com/classius/server/internal/utils"
 )
 
@@ -59,6 +61,7 @@ type UserPreferences struct {
 	NotificationSettings NotificationSettings `json:"notification_settings"`
 	ReadingSettings      ReadingSettings      `json:"reading_settings"`
 	PrivacySettings      PrivacySettings      `json:"privacy_settings"`
+	ExportSettings       ExportSettings       `json:"export_settings"`
 }
 
 // NotificationSettings represents notification preferences
@@ -90,6 +93,11 @@ type PrivacySettings struct {
 	ShareReadingActivity bool   `json:"share_reading_activity"`
 }
 
+// ExportSettings represents annotation export preferences
+type ExportSettings struct {
+	MarkdownTemplate string `json:"markdown_template,omitempty"` // Go text/template for Markdown exports
+}
+
 // UserReadingGoals represents user reading goals
 type UserReadingGoals struct {
 	YearlyBooksGoal     int     `json:"yearly_books_goal"`
@@ -115,6 +123,7 @@ type UpdatePreferencesRequest struct {
 	NotificationSettings *NotificationSettings `json:"notification_settings,omitempty"`
 	ReadingSettings      *ReadingSettings      `json:"reading_settings,omitempty"`
 	PrivacySettings      *PrivacySettings      `json:"privacy_settings,omitempty"`
+	ExportSettings       *ExportSettings       `json:"export_settings,omitempty"`
 }
 
 // UpdateGoalsRequest represents reading goals update request
@@ -317,36 +326,54 @@ func UpdateUserProfileDetailed(c *gin.Context) {
 
 // GetUserPreferences returns user preferences
 func GetUserPreferences(c *gin.Context) {
-	_, exists := c.Get("user_id")
+	userID, exists := c.Get("user_id")
 	if !exists {
 		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
 		return
 	}
 
-	// For now, return default preferences since we haven't implemented the preferences table
-	// In a full implementation, this would load from a user_preferences table
-	preferences := getDefaultPreferences()
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	preferences, err := loadUserPreferences(db.DB, userUUID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve preferences", err)
+		return
+	}
 
 	utils.SuccessResponse(c, "Preferences retrieved successfully", preferences)
 }
 
 // UpdateUserPreferences updates user preferences
 func UpdateUserPreferences(c *gin.Context) {
-	_, exists := c.Get("user_id")
+	userID, exists := c.Get("user_id")
 	if !exists {
 		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
 		return
 	}
 
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
 	var req UpdatePreferencesRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid preferences data", err)
 		return
 	}
 
-	// For now, simulate successful update
-	// In a full implementation, this would update the user_preferences table
-	preferences := getDefaultPreferences()
+	database := db.DB
+
+	preferences, err := loadUserPreferences(database, userUUID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve preferences", err)
+		return
+	}
 
 	// Apply updates from request
 	if req.Theme != "" {
@@ -367,6 +394,18 @@ func UpdateUserPreferences(c *gin.Context) {
 	if req.PrivacySettings != nil {
 		preferences.PrivacySettings = *req.PrivacySettings
 	}
+	if req.ExportSettings != nil {
+		if _, err := services.ParseExportTemplate(req.ExportSettings.MarkdownTemplate); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid export template", err)
+			return
+		}
+		preferences.ExportSettings = *req.ExportSettings
+	}
+
+	if err := saveUserPreferences(database, userUUID, preferences); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update preferences", err)
+		return
+	}
 
 	utils.SuccessResponse(c, "Preferences updated successfully", preferences)
 }
@@ -602,7 +641,10 @@ func buildUserProfile(database *gorm.DB, user *models.User) (*UserProfileRespons
 	}
 
 	// Get preferences
-	preferences := getDefaultPreferences()
+	preferences, err := loadUserPreferences(database, user.ID)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get reading goals
 	goals, err := calculateReadingGoals(database, user.ID)
@@ -726,6 +768,54 @@ func getDefaultPreferences() UserPreferences {
 	}
 }
 
+// loadUserPreferences returns the user's stored preferences over the defaults
+func loadUserPreferences(database *gorm.DB, userID uuid.UUID) (UserPreferences, error) {
+	preferences := getDefaultPreferences()
+
+	var stored models.UserPreference
+	result := database.Where("user_id = ?", userID).Limit(1).Find(&stored)
+	if result.Error != nil {
+		return preferences, result.Error
+	}
+	if result.RowsAffected == 0 || len(stored.Settings) == 0 {
+		return preferences, nil
+	}
+
+	// Stored settings may be partial (e.g. pushed by a device), so they are
+	// decoded over the defaults
+	data, err := json.Marshal(stored.Settings)
+	if err != nil {
+		return preferences, err
+	}
+	if err := json.Unmarshal(data, &preferences); err != nil {
+		return preferences, err
+	}
+	return preferences, nil
+}
+
+// saveUserPreferences stores the user's preferences
+func saveUserPreferences(database *gorm.DB, userID uuid.UUID, preferences UserPreferences) error {
+	data, err := json.Marshal(preferences)
+	if err != nil {
+		return err
+	}
+	var settings map[string]interface{}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return err
+	}
+
+	var stored models.UserPreference
+	result := database.Where("user_id = ?", userID).Limit(1).Find(&stored)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return database.Create(&models.UserPreference{UserID: userID, Settings: settings}).Error
+	}
+	stored.Settings = settings
+	return database.Save(&stored).Error
+}
+
 func calculateReadingGoals(database *gorm.DB, userID uuid.UUID) (*UserReadingGoals, error) {
 	// Get current year progress
 	yearStart := time.Date(time.Now().Year(), 1, 1, 0, 0, 0, 0, time.UTC)
@@ -743,8 +833,8 @@ func calculateReadingGoals(database *gorm.DB, userID uuid.UUID) (*UserReadingGoa
 		Count(&monthlyBooksRead)
 
 	// Default goals
-	yearlyGoal := 24
-	monthlyGoal := 2
+	yearlyGoal := services.DefaultYearlyBooksGoal
+	monthlyGoal := services.DefaultMonthlyBooksGoal
 
 	// Calculate progress
 	yearlyProgress := float64(yearlyBooksRead) / float64(yearlyGoal) * 100
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/classius/server/internal/models"
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// VocabularyHandlers manages the vocabulary list and flashcard reviews
+type VocabularyHandlers struct {
+	vocabularyService *services.VocabularyService
+}
+
+// NewVocabularyHandlers creates new vocabulary handlers
+func NewVocabularyHandlers(vocabularyService *services.VocabularyService) *VocabularyHandlers {
+	return &VocabularyHandlers{vocabularyService: vocabularyService}
+}
+
+// GradeRequest is the answer given for a reviewed word
+type GradeRequest struct {
+	Grade      int `json:"grade" binding:"required"` // 1 again, 2 hard, 3 good, 4 easy
+	DurationMs int `json:"duration_ms"`
+}
+
+// AddWord saves a word to the vocabulary list
+// POST /api/vocab
+func (h *VocabularyHandlers) AddWord(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	var req services.AddWordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", err)
+		return
+	}
+
+	word, err := h.vocabularyService.AddWord(c.Request.Context(), userID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to save word", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.APIResponse{
+		Success: true,
+		Message: "Word saved successfully",
+		Data:    word,
+	})
+}
+
+// GetWords lists saved words
+// GET /api/vocab?book_id=&lang=&state=&q=&page=&per_page=
+func (h *VocabularyHandlers) GetWords(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	bookID, ok := optionalBookID(c)
+	if !ok {
+		return
+	}
+
+	page := utils.GetIntQuery(c, "page", 1, 1, 100000)
+	perPage := utils.GetIntQuery(c, "per_page", 50, 1, 200)
+
+	words, total, err := h.vocabularyService.ListWords(c.Request.Context(), services.VocabularyFilter{
+		UserID:   userID,
+		BookID:   bookID,
+		Language: c.Query("lang"),
+		State:    c.Query("state"),
+		Query:    c.Query("q"),
+		Limit:    perPage,
+		Offset:   (page - 1) * perPage,
+	})
+	if err != nil {
+		respondServiceError(c, "Failed to retrieve vocabulary", err)
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, "Vocabulary retrieved successfully", words, utils.PaginationResponse{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      int(total),
+		TotalPages: (int(total) + perPage - 1) / perPage,
+	})
+}
+
+// GetWord returns a saved word
+// GET /api/vocab/:id
+func (h *VocabularyHandlers) GetWord(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	wordID, ok := getUUIDParam(c, "id", "word ID")
+	if !ok {
+		return
+	}
+
+	word, err := h.vocabularyService.GetWord(c.Request.Context(), userID, wordID)
+	if err != nil {
+		respondServiceError(c, "Word not found", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Word retrieved successfully", word)
+}
+
+// UpdateWord edits a saved word's definition, context, notes or suspension
+// PUT /api/vocab/:id
+func (h *VocabularyHandlers) UpdateWord(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	wordID, ok := getUUIDParam(c, "id", "word ID")
+	if !ok {
+		return
+	}
+
+	var req services.UpdateWordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", err)
+		return
+	}
+
+	word, err := h.vocabularyService.UpdateWord(c.Request.Context(), userID, wordID, req)
+	if err != nil {
+		respondServiceError(c, "Failed to update word", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Word updated successfully", word)
+}
+
+// DeleteWord removes a saved word and its review history
+// DELETE /api/vocab/:id
+func (h *VocabularyHandlers) DeleteWord(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	wordID, ok := getUUIDParam(c, "id", "word ID")
+	if !ok {
+		return
+	}
+
+	if err := h.vocabularyService.DeleteWord(c.Request.Context(), userID, wordID); err != nil {
+		respondServiceError(c, "Failed to delete word", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Word deleted successfully", nil)
+}
+
+// GetReviewQueue returns the words due for review now
+// GET /api/vocab/review?limit=&new=&book_id=&lang=
+func (h *VocabularyHandlers) GetReviewQueue(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	bookID, ok := optionalBookID(c)
+	if !ok {
+		return
+	}
+
+	queue, err := h.vocabularyService.ReviewQueue(
+		c.Request.Context(),
+		userID,
+		bookID,
+		c.Query("lang"),
+		utils.GetIntQuery(c, "limit", 50, 1, 500),
+		utils.GetIntQuery(c, "new", 20, 0, 500),
+	)
+	if err != nil {
+		respondServiceError(c, "Failed to build review queue", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Review queue retrieved successfully", queue)
+}
+
+// GradeWord records the answer for a reviewed word and reschedules it
+// POST /api/vocab/:id/grade
+func (h *VocabularyHandlers) GradeWord(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	wordID, ok := getUUIDParam(c, "id", "word ID")
+	if !ok {
+		return
+	}
+
+	var req GradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", err)
+		return
+	}
+
+	word, err := h.vocabularyService.GradeWord(c.Request.Context(), userID, wordID, req.Grade, req.DurationMs)
+	if err != nil {
+		respondServiceError(c, "Failed to grade word", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Review recorded successfully", word)
+}
+
+// GetStats returns retention and workload statistics
+// GET /api/vocab/stats
+func (h *VocabularyHandlers) GetStats(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.vocabularyService.Stats(c.Request.Context(), userID)
+	if err != nil {
+		respondServiceError(c, "Failed to compute vocabulary statistics", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Vocabulary statistics retrieved successfully", stats)
+}
+
+// ExportWords exports the vocabulary list as CSV or an Anki package
+// GET /api/vocab/export?format=csv|apkg&book_id=&lang=
+func (h *VocabularyHandlers) ExportWords(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	bookID, ok := optionalBookID(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "apkg" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Unsupported export format", nil)
+		return
+	}
+
+	words, _, err := h.vocabularyService.ListWords(c.Request.Context(), services.VocabularyFilter{
+		UserID:   userID,
+		BookID:   bookID,
+		Language: c.Query("lang"),
+	})
+	if err != nil {
+		respondServiceError(c, "Failed to export vocabulary", err)
+		return
+	}
+
+	switch format {
+	case "csv":
+		exportVocabularyCSV(c, words)
+	case "apkg":
+		// Build the package in memory so a failure can still be reported as JSON
+		var buf bytes.Buffer
+		if err := services.WriteAnkiPackage(c.Request.Context(), &buf, "Classius Vocabulary", words); err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to build Anki package", err)
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="classius-vocabulary.apkg"`)
+		c.Data(http.StatusOK, "application/apkg", buf.Bytes())
+	}
+}
+
+func exportVocabularyCSV(c *gin.Context, words []models.VocabularyWord) {
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", `attachment; filename="vocabulary.csv"`)
+
+	writer := csv.NewWriter(c.Writer)
+	defer writer.Flush()
+
+	writer.Write([]string{"Word", "Lemma", "Language", "Part of Speech", "Definition", "Context", "Book", "Page",
+		"State", "Due At", "Interval Days", "Ease Factor", "Stability", "Difficulty", "Repetitions", "Lapses", "Created At"})
+
+	for _, word := range words {
+		book := ""
+		if word.Book != nil {
+			book = word.Book.Title
+		}
+		writer.Write([]string{
+			word.Word,
+			word.Lemma,
+			word.Language,
+			word.PartOfSpeech,
+			word.Definition,
+			word.Context,
+			book,
+			strconv.Itoa(word.PageNumber),
+			word.State,
+			word.DueAt.Format(time.RFC3339),
+			strconv.FormatFloat(word.IntervalDays, 'f', 0, 64),
+			fmt.Sprintf("%.2f", word.EaseFactor),
+			fmt.Sprintf("%.2f", word.Stability),
+			fmt.Sprintf("%.2f", word.Difficulty),
+			strconv.Itoa(word.Repetitions),
+			strconv.Itoa(word.Lapses),
+			word.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+}
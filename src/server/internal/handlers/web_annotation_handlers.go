@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/classius/server/internal/services"
+	"github.com/classius/server/internal/utils"
+)
+
+// maxWebAnnotationImportSize bounds the size of imported documents
+const maxWebAnnotationImportSize = 20 << 20
+
+// WebAnnotationHandlers exports and imports W3C Web Annotations
+type WebAnnotationHandlers struct {
+	webAnnotationService *services.WebAnnotationService
+}
+
+// NewWebAnnotationHandlers creates new web annotation handlers
+func NewWebAnnotationHandlers(webAnnotationService *services.WebAnnotationService) *WebAnnotationHandlers {
+	return &WebAnnotationHandlers{webAnnotationService: webAnnotationService}
+}
+
+// Export returns annotations as a W3C Web Annotation JSON-LD collection
+// GET /api/annotations/export/jsonld?book_id=&type=
+func (h *WebAnnotationHandlers) Export(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	bookID, ok := optionalBookID(c)
+	if !ok {
+		return
+	}
+
+	collection, err := h.webAnnotationService.Export(c.Request.Context(), userID, bookID, c.Query("type"))
+	if err != nil {
+		respondServiceError(c, "Failed to export annotations", err)
+		return
+	}
+
+	data, err := json.MarshalIndent(collection, "", "  ")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to export annotations", err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="annotations.jsonld"`)
+	c.Data(http.StatusOK, services.WebAnnotationContentType, data)
+}
+
+// Import creates annotations from a W3C Web Annotation JSON-LD document,
+// skipping annotations that already exist
+// POST /api/annotations/import/jsonld?book_id=
+func (h *WebAnnotationHandlers) Import(c *gin.Context) {
+	userID, ok := getUserUUID(c)
+	if !ok {
+		return
+	}
+	bookID, ok := optionalBookID(c)
+	if !ok {
+		return
+	}
+
+	document, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebAnnotationImportSize))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid import document", err)
+		return
+	}
+
+	result, err := h.webAnnotationService.Import(c.Request.Context(), userID, bookID, document)
+	if err != nil {
+		respondServiceError(c, "Failed to import annotations", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Annotations imported successfully", result)
+}
@@ -182,6 +182,24 @@ func GetCurrentUser(c *gin.Context) (*models.User, bool) {
 	return nil, false
 }
 
+// AdminRequired middleware that restricts a route to admins. It must run
+// after AuthRequired.
+func AdminRequired() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		user, ok := GetCurrentUser(c)
+		if !ok || !user.IsAdmin {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Admin access required",
+				"message": "This action is restricted to administrators",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	})
+}
+
 // RateLimiting middleware (basic implementation)
 func RateLimiting() gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
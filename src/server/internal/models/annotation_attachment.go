@@ -0,0 +1,40 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// Kinds of annotation attachment
+const (
+	AttachmentImage = "image"
+	AttachmentAudio = "audio"
+	AttachmentFile  = "file"
+)
+
+// AnnotationAttachment is a file attached to an annotation: a photo, a voice
+// memo or a document. Attachments are soft-deleted with their annotation,
+// and come back if it is restored, until their files are purged.
+type AnnotationAttachment struct {
+	BaseModel
+	UserID       uuid.UUID `json:"user_id" gorm:"not null;index"`
+	AnnotationID uuid.UUID `json:"annotation_id" gorm:"not null;index"`
+	Kind         string    `json:"kind" gorm:"size:10;not null"` // image, audio or file
+	Filename     string    `json:"filename" gorm:"size:255;not null"`
+	ContentType  string    `json:"content_type" gorm:"size:100;not null"` // Sniffed from the content
+	Size         int64     `json:"size" gorm:"not null"`
+	SHA256       string    `json:"sha256" gorm:"column:sha256;size:64;not null"`
+	Width        int       `json:"width,omitempty"` // Images only
+	Height       int       `json:"height,omitempty"`
+	HasThumbnail bool      `json:"has_thumbnail" gorm:"default:false"`
+	StoragePath  string    `json:"-" gorm:"size:500;not null"`
+}
+
+// TableName returns the table name for the AnnotationAttachment model
+func (AnnotationAttachment) TableName() string {
+	return "annotation_attachments"
+}
+
+// ThumbnailPath is where the attachment's thumbnail is stored
+func (a *AnnotationAttachment) ThumbnailPath() string {
+	return a.StoragePath + ".thumb.jpg"
+}
@@ -0,0 +1,268 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Annotation types
+const (
+	AnnotationHighlight = "highlight"
+	AnnotationNote      = "note"
+	AnnotationBookmark  = "bookmark"
+	AnnotationArea      = "area" // A region of a page, e.g. a diagram in a scanned PDF
+	AnnotationInk       = "ink"  // Pen strokes
+)
+
+// IsAnnotationType reports whether t is a known annotation type
+func IsAnnotationType(t string) bool {
+	switch t {
+	case AnnotationHighlight, AnnotationNote, AnnotationBookmark, AnnotationArea, AnnotationInk:
+		return true
+	}
+	return false
+}
+
+// AnnotationGeometry locates an annotation on its page. Coordinates are
+// fractions of the page's width and height, from its top left corner.
+type AnnotationGeometry struct {
+	Rects []PageRect `json:"rects,omitempty"`
+	Quads []PageQuad `json:"quads,omitempty"` // For rotated or skewed regions, like PDF QuadPoints
+}
+
+// PageRect is a rectangle on a page
+type PageRect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// PageQuad is a quadrilateral on a page: x1, y1 ... x4, y4, in drawing order
+type PageQuad [8]float64
+
+// Value stores the geometry as JSON
+func (g AnnotationGeometry) Value() (driver.Value, error) {
+	return json.Marshal(g)
+}
+
+// Scan reads geometry stored as JSON
+func (g *AnnotationGeometry) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, g)
+	case string:
+		return json.Unmarshal([]byte(v), g)
+	case nil:
+		*g = AnnotationGeometry{}
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into AnnotationGeometry", value)
+}
+
+// InkDrawing is pen strokes on a page. Points use page fractions like
+// AnnotationGeometry.
+type InkDrawing struct {
+	PageWidth  float64     `json:"page_width,omitempty"` // Page size in any unit, for the aspect ratio
+	PageHeight float64     `json:"page_height,omitempty"`
+	Strokes    []InkStroke `json:"strokes"`
+}
+
+// InkStroke is one continuous pen stroke
+type InkStroke struct {
+	Color  string     `json:"color,omitempty"` // Hex color code
+	Width  float64    `json:"width"`           // Fraction of the page width
+	Points []InkPoint `json:"points"`
+}
+
+// InkPoint is a sampled pen position. Pressure is 0 to 1, or 0 when not
+// sensed; T is milliseconds since the drawing began.
+type InkPoint struct {
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Pressure float64 `json:"p,omitempty"`
+	T        int64   `json:"t,omitempty"`
+}
+
+// Ink encoding. Coordinates and widths are stored in units of 1/InkScale
+// of the page, pressure in 1/255ths, and each stroke's points as deltas
+// from the point before.
+const (
+	InkScale       = 10000
+	inkVersion     = 1
+	inkHasPressure = 1 << 0
+	inkHasTime     = 1 << 1
+)
+
+// MarshalBinary encodes the drawing compactly, with point coordinates
+// rounded to 1/InkScale of the page
+func (d InkDrawing) MarshalBinary() ([]byte, error) {
+	buf := []byte{inkVersion}
+	buf = binary.AppendUvarint(buf, uint64(math.Round(d.PageWidth*100)))
+	buf = binary.AppendUvarint(buf, uint64(math.Round(d.PageHeight*100)))
+	buf = binary.AppendUvarint(buf, uint64(len(d.Strokes)))
+	for _, s := range d.Strokes {
+		buf = binary.AppendUvarint(buf, uint64(len(s.Color)))
+		buf = append(buf, s.Color...)
+		buf = binary.AppendUvarint(buf, uint64(inkUnits(s.Width)))
+
+		var flags byte
+		for _, p := range s.Points {
+			if p.Pressure != 0 {
+				flags |= inkHasPressure
+			}
+			if p.T != 0 {
+				flags |= inkHasTime
+			}
+		}
+		buf = append(buf, flags)
+		buf = binary.AppendUvarint(buf, uint64(len(s.Points)))
+
+		var x, y, t int64
+		for _, p := range s.Points {
+			px, py := inkUnits(p.X), inkUnits(p.Y)
+			buf = binary.AppendVarint(buf, px-x)
+			buf = binary.AppendVarint(buf, py-y)
+			x, y = px, py
+			if flags&inkHasPressure != 0 {
+				buf = append(buf, byte(math.Round(math.Max(0, math.Min(1, p.Pressure))*255)))
+			}
+			if flags&inkHasTime != 0 {
+				buf = binary.AppendVarint(buf, p.T-t)
+				t = p.T
+			}
+		}
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a drawing encoded by MarshalBinary
+func (d *InkDrawing) UnmarshalBinary(data []byte) error {
+	r := inkReader{data: data}
+	if version := r.byte(); version != inkVersion {
+		return fmt.Errorf("unsupported ink encoding version %d", version)
+	}
+	drawing := InkDrawing{
+		PageWidth:  float64(r.uvarint()) / 100,
+		PageHeight: float64(r.uvarint()) / 100,
+	}
+	strokes := r.count()
+	drawing.Strokes = make([]InkStroke, 0, strokes)
+	for i := 0; i < strokes && r.err == nil; i++ {
+		s := InkStroke{Color: string(r.bytes(r.count())), Width: float64(r.uvarint()) / InkScale}
+		flags := r.byte()
+		points := r.count()
+		s.Points = make([]InkPoint, 0, points)
+		var x, y, t int64
+		for j := 0; j < points && r.err == nil; j++ {
+			x += r.varint()
+			y += r.varint()
+			p := InkPoint{X: float64(x) / InkScale, Y: float64(y) / InkScale}
+			if flags&inkHasPressure != 0 {
+				p.Pressure = math.Round(float64(r.byte())/255*1000) / 1000
+			}
+			if flags&inkHasTime != 0 {
+				t += r.varint()
+				p.T = t
+			}
+			s.Points = append(s.Points, p)
+		}
+		drawing.Strokes = append(drawing.Strokes, s)
+	}
+	if r.err != nil {
+		return r.err
+	}
+	*d = drawing
+	return nil
+}
+
+// Value stores the drawing in its binary encoding
+func (d InkDrawing) Value() (driver.Value, error) {
+	return d.MarshalBinary()
+}
+
+// Scan reads a drawing in its binary encoding
+func (d *InkDrawing) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return d.UnmarshalBinary(v)
+	case nil:
+		*d = InkDrawing{}
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into InkDrawing", value)
+}
+
+func inkUnits(v float64) int64 {
+	return int64(math.Round(v * InkScale))
+}
+
+var errInkTruncated = errors.New("truncated ink data")
+
+// inkReader reads the ink encoding, remembering the first error
+type inkReader struct {
+	data []byte
+	err  error
+}
+
+func (r *inkReader) byte() byte {
+	if r.err != nil || len(r.data) == 0 {
+		r.err = errInkTruncated
+		return 0
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+func (r *inkReader) bytes(n int) []byte {
+	if r.err != nil || len(r.data) < n {
+		r.err = errInkTruncated
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *inkReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = errInkTruncated
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *inkReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = errInkTruncated
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// count reads a length, which cannot exceed the bytes left
+func (r *inkReader) count() int {
+	v := r.uvarint()
+	if v > uint64(len(r.data)) {
+		if r.err == nil {
+			r.err = errInkTruncated
+		}
+		return 0
+	}
+	return int(v)
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestInkDrawingBinaryRoundTrip(t *testing.T) {
+	drawing := InkDrawing{
+		PageWidth:  595.28,
+		PageHeight: 841.89,
+		Strokes: []InkStroke{
+			{Color: "#1a237e", Width: 0.004, Points: []InkPoint{
+				{X: 0.1, Y: 0.2, Pressure: 0.5, T: 0},
+				{X: 0.10013, Y: 0.19, Pressure: 0.75, T: 16},
+				{X: 0.3, Y: 0.05, Pressure: 1, T: 40},
+			}},
+			{Width: 0.002, Points: []InkPoint{{X: 1, Y: 1}, {X: 0, Y: 0}}},
+		},
+	}
+	data, err := drawing.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonData, _ := json.Marshal(drawing)
+	if len(data)*3 > len(jsonData) {
+		t.Errorf("binary encoding is %d bytes, JSON %d", len(data), len(jsonData))
+	}
+
+	var decoded InkDrawing
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.PageWidth != 595.28 || decoded.PageHeight != 841.89 || len(decoded.Strokes) != 2 {
+		t.Fatalf("unexpected drawing %+v", decoded)
+	}
+	for i, s := range drawing.Strokes {
+		got := decoded.Strokes[i]
+		if got.Color != s.Color || got.Width != s.Width || len(got.Points) != len(s.Points) {
+			t.Fatalf("stroke %d: got %+v", i, got)
+		}
+		for j, p := range s.Points {
+			q := got.Points[j]
+			if math.Abs(q.X-p.X) > 0.5/InkScale || math.Abs(q.Y-p.Y) > 0.5/InkScale ||
+				math.Abs(q.Pressure-p.Pressure) > 0.003 || q.T != p.T {
+				t.Errorf("stroke %d point %d: got %+v, want %+v", i, j, q, p)
+			}
+		}
+	}
+
+	for n := 1; n < len(data); n++ {
+		if err := new(InkDrawing).UnmarshalBinary(data[:n]); err == nil {
+			t.Errorf("no error decoding %d of %d bytes", n, len(data))
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// Relations between linked passages
+const (
+	LinkEchoes      = "echoes"
+	LinkContradicts = "contradicts"
+	LinkCites       = "cites"
+	LinkExplains    = "explains"
+)
+
+// AnnotationLink connects an annotation to another annotation, or to a
+// passage of a book given by its rune offsets
+type AnnotationLink struct {
+	BaseModel
+	UserID             uuid.UUID  `json:"user_id" gorm:"not null;index"`
+	SourceAnnotationID uuid.UUID  `json:"source_annotation_id" gorm:"not null;index"`
+	TargetAnnotationID *uuid.UUID `json:"target_annotation_id,omitempty" gorm:"index"`
+	TargetBookID       *uuid.UUID `json:"target_book_id,omitempty" gorm:"index"` // Passage targets only
+	TargetStart        int        `json:"target_start,omitempty"`
+	TargetEnd          int        `json:"target_end,omitempty"`
+	TargetText         string     `json:"target_text,omitempty" gorm:"type:text"`
+	Relation           string     `json:"relation" gorm:"size:20;not null;index"` // echoes, contradicts, cites, explains
+	Note               string     `json:"note,omitempty" gorm:"type:text"`
+}
+
+// TableName returns the table name for the AnnotationLink model
+func (AnnotationLink) TableName() string {
+	return "annotation_links"
+}
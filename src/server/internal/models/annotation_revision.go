@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Annotation revision operations
+const (
+	RevisionCreate   = "create"
+	RevisionUpdate   = "update"
+	RevisionDelete   = "delete"
+	RevisionRestore  = "restore"
+	RevisionSnapshot = "snapshot" // State of an annotation when history began
+)
+
+// AnnotationRevision is the state of an annotation after one of its
+// changes. Revisions are written in the transaction making the change and
+// never modified.
+type AnnotationRevision struct {
+	ID            uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	AnnotationID  uuid.UUID `json:"annotation_id" gorm:"type:uuid;not null;uniqueIndex:idx_annotation_revisions_revision"`
+	Revision      int       `json:"revision" gorm:"not null;uniqueIndex:idx_annotation_revisions_revision"`
+	UserID        uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Operation     string    `json:"operation" gorm:"size:10;not null"` // create, update, delete, restore, snapshot
+	ChangedFields []string  `json:"changed_fields" gorm:"type:text[]"` // Empty for creations and deletions
+
+	// Snapshot of the annotation
+	BookID        uuid.UUID           `json:"book_id" gorm:"type:uuid;not null"`
+	Type          string              `json:"type" gorm:"size:20"`
+	PageNumber    int                 `json:"page_number"`
+	StartPosition int                 `json:"start_position"`
+	EndPosition   int                 `json:"end_position"`
+	SelectedText  string              `json:"selected_text" gorm:"type:text"`
+	Content       string              `json:"content" gorm:"type:text"`
+	Color         string              `json:"color" gorm:"size:20"`
+	Tags          []string            `json:"tags" gorm:"type:text[]"`
+	IsPrivate     bool                `json:"is_private"`
+	Geometry      *AnnotationGeometry `json:"geometry,omitempty" gorm:"type:jsonb"`
+	Ink           *InkDrawing         `json:"ink,omitempty" gorm:"type:bytea"`
+	Deleted       bool                `json:"deleted"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName returns the table name for the AnnotationRevision model
+func (AnnotationRevision) TableName() string {
+	return "annotation_revisions"
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Annotation visibility scopes. Group scopes are "group:" followed by the
+// reading group's ID.
+const (
+	VisibilityPrivate = "private"
+	VisibilityPublic  = "public"
+
+	visibilityGroupPrefix = "group:"
+)
+
+// GroupVisibility returns the visibility scope sharing with a reading group
+func GroupVisibility(groupID uuid.UUID) string {
+	return visibilityGroupPrefix + groupID.String()
+}
+
+// ParseVisibility splits a visibility scope into its kind (private, group
+// or public) and, for group scopes, the group's ID
+func ParseVisibility(v string) (string, *uuid.UUID, bool) {
+	switch v {
+	case VisibilityPrivate, VisibilityPublic:
+		return v, nil, true
+	}
+	if rest, ok := strings.CutPrefix(v, visibilityGroupPrefix); ok {
+		if groupID, err := uuid.Parse(rest); err == nil {
+			return "group", &groupID, true
+		}
+	}
+	return "", nil, false
+}
+
+// AnnotationReply is a reply to an annotation shared with a group or the
+// public, seen by whoever can see the annotation
+type AnnotationReply struct {
+	BaseModel
+	AnnotationID uuid.UUID `json:"annotation_id" gorm:"not null;index"`
+	UserID       uuid.UUID `json:"user_id" gorm:"not null;index"`
+	Content      string    `json:"content" gorm:"not null;type:text"` // Markdown
+	ContentHTML  string    `json:"content_html" gorm:"type:text"`
+}
+
+// TableName returns the table name for the AnnotationReply model
+func (AnnotationReply) TableName() string {
+	return "annotation_replies"
+}
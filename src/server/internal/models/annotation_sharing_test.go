@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseVisibility(t *testing.T) {
+	groupID := uuid.New()
+	tests := []struct {
+		in    string
+		kind  string
+		group bool
+		ok    bool
+	}{
+		{"private", "private", false, true},
+		{"public", "public", false, true},
+		{GroupVisibility(groupID), "group", true, true},
+		{"group:not-a-uuid", "", false, false},
+		{"group:", "", false, false},
+		{"friends", "", false, false},
+		{"", "", false, false},
+	}
+	for _, tt := range tests {
+		kind, id, ok := ParseVisibility(tt.in)
+		if kind != tt.kind || ok != tt.ok || (id != nil) != tt.group {
+			t.Errorf("ParseVisibility(%q) = %q, %v, %v", tt.in, kind, id, ok)
+		}
+		if tt.group && *id != groupID {
+			t.Errorf("ParseVisibility(%q) group = %v, want %v", tt.in, *id, groupID)
+		}
+	}
+}
@@ -17,6 +17,7 @@ type Book struct {
 	Publisher   string         `json:"publisher,omitempty"`
 	PublishedAt *time.Time     `json:"published_at,omitempty"`
 	ISBN        string         `json:"isbn,omitempty" gorm:"index"`
+	TextFingerprint string     `json:"text_fingerprint,omitempty" gorm:"size:64;index"` // Identifies the text across copies, once extracted
 	Description string         `json:"description,omitempty" gorm:"type:text"`
 	CoverURL    string         `json:"cover_url,omitempty"`
 	FileURL     string         `json:"file_url,omitempty"`
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BulkOperation is a bulk annotation action, kept with the state it
+// replaced so that it can be undone until ExpiresAt
+type BulkOperation struct {
+	BaseModel
+	UserID          uuid.UUID              `json:"user_id" gorm:"not null;index"`
+	Action          string                 `json:"action" gorm:"size:30;not null"` // delete, update_tags, update_color, toggle_private
+	Parameters      map[string]interface{} `json:"parameters,omitempty" gorm:"type:jsonb;serializer:json"`
+	Items           []BulkOperationItem    `json:"items" gorm:"type:jsonb;serializer:json"`
+	AnnotationCount int                    `json:"annotation_count"`
+	ExpiresAt       time.Time              `json:"expires_at" gorm:"not null;index"`
+	UndoneAt        *time.Time             `json:"undone_at,omitempty"`
+}
+
+// BulkOperationItem is an annotation changed by a bulk operation, with the
+// values the operation replaced
+type BulkOperationItem struct {
+	AnnotationID uuid.UUID `json:"annotation_id"`
+	Revision     int       `json:"revision"` // Revision the operation left the annotation at
+	Tags         []string  `json:"tags"`
+	Color        string    `json:"color"`
+	IsPrivate    bool      `json:"is_private"`
+}
+
+// TableName returns the table name for the BulkOperation model
+func (BulkOperation) TableName() string {
+	return "bulk_operations"
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConcordanceIndex describes the word index built for a book's extracted text
+type ConcordanceIndex struct {
+	ID         uuid.UUID            `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	BookID     uuid.UUID            `json:"book_id" gorm:"type:uuid;not null;uniqueIndex"`
+	Language   string               `json:"language" gorm:"size:10;not null"`
+	TokenCount int                  `json:"token_count"`
+	TypeCount  int                  `json:"type_count"`
+	Chapters   []ConcordanceChapter `json:"chapters" gorm:"type:jsonb;serializer:json"`
+	BuiltAt    time.Time            `json:"built_at"`
+	CreatedAt  time.Time            `json:"created_at"`
+	UpdatedAt  time.Time            `json:"updated_at"`
+}
+
+// ConcordanceChapter records a chapter boundary inside the indexed text
+type ConcordanceChapter struct {
+	Index      int    `json:"index"`
+	Title      string `json:"title"`
+	Start      int    `json:"start"`
+	End        int    `json:"end"`
+	TokenCount int    `json:"token_count"`
+}
+
+// ConcordanceTerm holds the postings for one normalized word form in a book.
+// Positions are rune offsets into BookContent.FullText and Ordinals are the
+// matching token numbers, which lets collocations be computed by word distance.
+type ConcordanceTerm struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	BookID     uuid.UUID `json:"book_id" gorm:"type:uuid;not null;index:idx_concordance_terms_book_term,priority:1;index:idx_concordance_terms_book_stem,priority:1"`
+	Term       string    `json:"term" gorm:"size:100;not null;index:idx_concordance_terms_book_term,priority:2"`
+	Stem       string    `json:"stem" gorm:"size:100;not null;index:idx_concordance_terms_book_stem,priority:2"`
+	IsStopword bool      `json:"is_stopword" gorm:"default:false"`
+	Count      int       `json:"count" gorm:"not null;index"`
+	Positions  []int     `json:"positions" gorm:"type:jsonb;serializer:json"`
+	Ordinals   []int     `json:"ordinals" gorm:"type:jsonb;serializer:json"`
+}
+
+// TableName returns the table name for the ConcordanceIndex model
+func (ConcordanceIndex) TableName() string {
+	return "concordance_indexes"
+}
+
+// TableName returns the table name for the ConcordanceTerm model
+func (ConcordanceTerm) TableName() string {
+	return "concordance_terms"
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WordLookup records a dictionary lookup made while reading a book
+type WordLookup struct {
+	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID       uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index:idx_word_lookups_user_lemma,priority:1"`
+	BookID       *uuid.UUID `json:"book_id" gorm:"type:uuid;index"`
+	Position     *int       `json:"position"` // Rune offset into BookContent.FullText
+	PageNumber   int        `json:"page_number"`
+	Word         string     `json:"word" gorm:"size:100;not null"`
+	Language     string     `json:"language" gorm:"size:10;not null"`
+	Lemma        string     `json:"lemma" gorm:"size:100;index:idx_word_lookups_user_lemma,priority:2"`
+	PartOfSpeech string     `json:"part_of_speech" gorm:"size:30"`
+	Definition   string     `json:"definition" gorm:"type:text"` // First definition found, kept for the vocabulary list
+	Context      string     `json:"context" gorm:"type:text"`    // Sentence the word was looked up in
+	Found        bool       `json:"found"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// TableName returns the table name for the WordLookup model
+func (WordLookup) TableName() string {
+	return "word_lookups"
+}
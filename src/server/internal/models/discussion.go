@@ -0,0 +1,24 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// Discussion tombstone reasons
+const (
+	TombstoneDeleted = "deleted" // By the post's author
+	TombstoneRemoved = "removed" // By a group moderator or an admin
+)
+
+// DiscussionVote is a user's upvote of a discussion post. Users vote once
+// per post; Discussion.Upvotes counts the votes.
+type DiscussionVote struct {
+	BaseModel
+	DiscussionID uuid.UUID `json:"discussion_id" gorm:"not null;index"`
+	UserID       uuid.UUID `json:"user_id" gorm:"not null;index"`
+}
+
+// TableName returns the table name for the DiscussionVote model
+func (DiscussionVote) TableName() string {
+	return "discussion_votes"
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reading group member roles, from most to least privileged
+const (
+	GroupRoleAdmin     = "admin"     // Manages the group, its settings and roles
+	GroupRoleModerator = "moderator" // Invites and removes members, curates the book list
+	GroupRoleMember    = "member"
+)
+
+// GroupRoleRank orders roles by privilege; unknown roles rank 0
+func GroupRoleRank(role string) int {
+	switch role {
+	case GroupRoleAdmin:
+		return 3
+	case GroupRoleModerator:
+		return 2
+	case GroupRoleMember:
+		return 1
+	}
+	return 0
+}
+
+// IsGroupType reports whether t is a valid reading group type
+func IsGroupType(t string) bool {
+	switch t {
+	case "book_club", "study_group", "discussion":
+		return true
+	}
+	return false
+}
+
+// Group invitation statuses
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationRevoked  = "revoked"
+)
+
+// GroupInvitation invites a user, by username, or anyone holding its link
+// token to join a reading group. Link invitations stay pending, counting
+// their uses, until they expire, run out or are revoked.
+type GroupInvitation struct {
+	BaseModel
+	GroupID   uuid.UUID  `json:"group_id" gorm:"not null;index"`
+	InviterID uuid.UUID  `json:"inviter_id" gorm:"not null;index"`
+	InviteeID *uuid.UUID `json:"invitee_id,omitempty" gorm:"index"`    // Set for invitations by username
+	Token     string     `json:"token,omitempty" gorm:"size:64;index"` // Set for link invitations
+	Status    string     `json:"status" gorm:"not null;size:20;default:'pending'"`
+	MaxUses   int        `json:"max_uses,omitempty"` // Link invitations only, 0 for unlimited
+	Uses      int        `json:"uses"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// TableName returns the table name for the GroupInvitation model
+func (GroupInvitation) TableName() string {
+	return "group_invitations"
+}
+
+// Group book list statuses
+const (
+	GroupBookUpcoming = "upcoming"
+	GroupBookCurrent  = "current"
+	GroupBookFinished = "finished"
+)
+
+// IsGroupBookStatus reports whether s is a valid group book list status
+func IsGroupBookStatus(s string) bool {
+	switch s {
+	case GroupBookUpcoming, GroupBookCurrent, GroupBookFinished:
+		return true
+	}
+	return false
+}
+
+// GroupBook is an entry in a reading group's shared book list. Books belong
+// to their uploaders, so the entry records what identifies the text and
+// members find their own copies by ISBN or text fingerprint.
+type GroupBook struct {
+	BaseModel
+	GroupID         uuid.UUID  `json:"group_id" gorm:"not null;index"`
+	AddedBy         uuid.UUID  `json:"added_by" gorm:"not null"`
+	BookID          *uuid.UUID `json:"book_id,omitempty" gorm:"index"` // The adding member's copy
+	Title           string     `json:"title" gorm:"not null;size:255"`
+	Author          string     `json:"author" gorm:"size:255"`
+	ISBN            string     `json:"isbn,omitempty" gorm:"size:20;index"`
+	TextFingerprint string     `json:"-" gorm:"size:64;index"`
+	Status          string     `json:"status" gorm:"not null;size:20;default:'upcoming'"`
+	Position        int        `json:"position"`
+	Note            string     `json:"note,omitempty" gorm:"type:text"`
+}
+
+// TableName returns the table name for the GroupBook model
+func (GroupBook) TableName() string {
+	return "group_books"
+}
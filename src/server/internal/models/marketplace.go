@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Order statuses
+const (
+	OrderPending       = "pending"
+	OrderPaid          = "paid"
+	OrderFailed        = "failed"
+	OrderRefundPending = "refund_pending" // Paid, with a refund requested from the provider
+	OrderRefunded      = "refunded"
+)
+
+// NoteOrder is a reader's purchase of a paid published note. The amount,
+// currency and revenue split are fixed when the order is created and paid.
+type NoteOrder struct {
+	BaseModel
+	UserID           uuid.UUID  `json:"user_id" gorm:"not null;index"` // The buyer
+	PublishedNoteID  uuid.UUID  `json:"published_note_id" gorm:"not null;index"`
+	AuthorID         uuid.UUID  `json:"author_id" gorm:"not null;index"`
+	AmountCents      int        `json:"amount_cents" gorm:"not null"`
+	Currency         string     `json:"currency" gorm:"not null;size:3"`
+	Status           string     `json:"status" gorm:"not null;size:20;default:'pending';index"`
+	Provider         string     `json:"provider" gorm:"not null;size:20"`
+	ProviderRef      string     `json:"-" gorm:"size:255;index"`                 // The provider's payment ID
+	ClientSecret     string     `json:"client_secret,omitempty" gorm:"size:255"` // Lets the buyer's client complete payment
+	CheckoutURL      string     `json:"checkout_url,omitempty" gorm:"type:text"`
+	IdempotencyKey   string     `json:"-" gorm:"size:255"`
+	PlatformFeeCents int        `json:"platform_fee_cents"`
+	AuthorShareCents int        `json:"author_share_cents"`
+	FailureReason    string     `json:"failure_reason,omitempty" gorm:"type:text"`
+	RefundRef        string     `json:"-" gorm:"size:255"`
+	PaidAt           *time.Time `json:"paid_at,omitempty"`
+	RefundedAt       *time.Time `json:"refunded_at,omitempty"`
+}
+
+// TableName returns the table name for the NoteOrder model
+func (NoteOrder) TableName() string {
+	return "note_orders"
+}
+
+// NoteEntitlement grants a user access to a paid published note, for all
+// its versions. Refunds revoke it.
+type NoteEntitlement struct {
+	BaseModel
+	UserID          uuid.UUID  `json:"user_id" gorm:"not null;index"`
+	PublishedNoteID uuid.UUID  `json:"published_note_id" gorm:"not null;index"`
+	OrderID         *uuid.UUID `json:"order_id,omitempty" gorm:"index"`
+}
+
+// TableName returns the table name for the NoteEntitlement model
+func (NoteEntitlement) TableName() string {
+	return "note_entitlements"
+}
+
+// PaymentEvent records a payment provider webhook event once it has been
+// handled, so redelivered events are ignored
+type PaymentEvent struct {
+	BaseModel
+	Provider    string `json:"provider" gorm:"not null;size:20;uniqueIndex:idx_payment_events_event"`
+	EventID     string `json:"event_id" gorm:"not null;size:255;uniqueIndex:idx_payment_events_event"`
+	Type        string `json:"type" gorm:"not null;size:50"`
+	ProviderRef string `json:"provider_ref" gorm:"size:255;index"`
+}
+
+// TableName returns the table name for the PaymentEvent model
+func (PaymentEvent) TableName() string {
+	return "payment_events"
+}
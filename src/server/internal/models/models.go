@@ -33,6 +33,13 @@ type User struct {
 	AvatarURL        string    `json:"avatar_url" gorm:"type:text"`
 	SubscriptionTier string    `json:"subscription_tier" gorm:"default:'free';size:20"`
 	LastActive       *time.Time `json:"last_active"`
+	IsAdmin          bool      `json:"is_admin" gorm:"default:false"` // Reviews scholar applications and submitted notes
+
+	// Verified scholar status, from an approved ScholarApplication
+	VerifiedScholar     bool       `json:"verified_scholar" gorm:"default:false"`
+	ScholarField        string     `json:"scholar_field,omitempty" gorm:"size:255"`
+	ScholarAffiliation  string     `json:"scholar_affiliation,omitempty" gorm:"size:255"`
+	VerifiedAt          *time.Time `json:"verified_at,omitempty"`
 	
 	// Relationships
 	UserBooks        []UserBook        `json:"user_books,omitempty"`
@@ -89,7 +96,7 @@ type Annotation struct {
 	BaseModel
 	UserID        uuid.UUID `json:"user_id" gorm:"not null;index"`
 	BookID        uuid.UUID `json:"book_id" gorm:"not null;index"`
-	Type          string    `json:"type" gorm:"not null;size:20;index"` // highlight, note, bookmark
+	Type          string    `json:"type" gorm:"not null;size:20;index"` // highlight, note, bookmark, area, ink
 	PageNumber    int       `json:"page_number"`
 	StartPosition int       `json:"start_position"`
 	EndPosition   int       `json:"end_position"`
@@ -97,7 +104,22 @@ type Annotation struct {
 	Content       string    `json:"content" gorm:"type:text"` // Note content
 	Color         string    `json:"color" gorm:"size:20"`     // For highlights
 	Tags          []string  `json:"tags" gorm:"type:text[]"`
-	IsPrivate     bool      `json:"is_private" gorm:"default:true"`
+	IsPrivate     bool      `json:"is_private" gorm:"default:true"` // Kept in step with Visibility by the database
+	Visibility    string    `json:"visibility" gorm:"not null;size:50;default:'private';index"` // private, group:<id> or public
+
+	// Page geometry, for area highlights and ink
+	Geometry *AnnotationGeometry `json:"geometry,omitempty" gorm:"type:jsonb"`
+	Ink      *InkDrawing         `json:"ink,omitempty" gorm:"type:bytea"` // Stored in InkDrawing's binary encoding
+
+	// Sync state
+	Revision       int            `json:"revision" gorm:"not null;default:0"`                // Incremented on every change
+	FieldRevisions map[string]int `json:"field_revisions,omitempty" gorm:"type:jsonb;serializer:json"` // Revision at which each field last changed
+	SyncSeq        int64          `json:"sync_seq" gorm:"not null;default:0;index"`          // Position in the owner's sync stream
+
+	// Import provenance, for annotations imported from other readers
+	ImportSource string     `json:"import_source,omitempty" gorm:"size:20;index"` // kindle, koreader, w3c
+	ImportRef    string     `json:"import_ref,omitempty" gorm:"type:text"`        // Where the annotation was in the source, e.g. a Kindle location
+	ImportedAt   *time.Time `json:"imported_at,omitempty"`
 	
 	// Relationships
 	User User `json:"user,omitempty"`
@@ -173,7 +195,26 @@ type Discussion struct {
 	Content          string     `json:"content" gorm:"not null;type:text"`
 	PassageReference string     `json:"passage_reference" gorm:"type:text"` // Reference to specific passage
 	ParentID         *uuid.UUID `json:"parent_id" gorm:"index"`             // For threaded replies
-	Upvotes          int        `json:"upvotes" gorm:"default:0"`
+	Upvotes          int        `json:"upvotes" gorm:"default:0"`           // Count of DiscussionVotes
+	RootID           *uuid.UUID `json:"root_id,omitempty" gorm:"index"`     // The thread's first post, for replies
+	Depth            int        `json:"depth" gorm:"default:0"`
+	ReplyCount       int        `json:"reply_count" gorm:"default:0"`  // Direct replies
+	ContentHTML      string     `json:"content_html" gorm:"type:text"` // Content's Markdown, rendered and sanitized
+	LastActivityAt   *time.Time `json:"last_activity_at,omitempty"`    // Latest post in the thread, on its first post
+
+	// Passage the thread is anchored to, in the starter's copy of the book
+	StartPosition   int    `json:"start_position"`
+	EndPosition     int    `json:"end_position"`
+	Quote           string `json:"quote,omitempty" gorm:"type:text"`
+	QuotePrefix     string `json:"-" gorm:"type:text"`
+	QuoteSuffix     string `json:"-" gorm:"type:text"`
+	BookISBN        string `json:"-" gorm:"size:20;index"`
+	BookFingerprint string `json:"-" gorm:"size:64;index"`
+	AnchorPercent   *float64 `json:"anchor_percent,omitempty"` // Where the passage starts, as a percentage of the text, once extracted
+
+	EditedAt        *time.Time `json:"edited_at,omitempty"`
+	TombstonedAt    *time.Time `json:"tombstoned_at,omitempty"` // Deleted, but kept in place for its replies
+	TombstoneReason string     `json:"tombstone_reason,omitempty" gorm:"size:20"` // deleted or removed
 	
 	// Relationships
 	Group    *ReadingGroup `json:"group,omitempty"`
@@ -182,7 +223,9 @@ type Discussion struct {
 	Replies  []Discussion  `json:"replies,omitempty" gorm:"foreignKey:ParentID"`
 }
 
-// PublishedNote represents published annotation collections
+// PublishedNote represents published annotation collections. Authors
+// gather annotations into a draft and publish it as numbered, immutable
+// versions of overlays.
 type PublishedNote struct {
 	BaseModel
 	AuthorID       uuid.UUID `json:"author_id" gorm:"not null;index"`
@@ -193,7 +236,13 @@ type PublishedNote struct {
 	PriceCents     int       `json:"price_cents" gorm:"default:0"` // 0 for free
 	IsVerified     bool      `json:"is_verified" gorm:"default:false"` // For verified scholars
 	Rating         float64   `json:"rating" gorm:"default:0.0"`
+	RatingsCount   int       `json:"ratings_count" gorm:"default:0"`
 	DownloadsCount int       `json:"downloads_count" gorm:"default:0"`
+	Status         string    `json:"status" gorm:"default:'draft';size:20"` // draft, published, withdrawn
+	Version        int       `json:"version" gorm:"default:0"`              // Latest published version, 0 before publishing
+	PublishedAt    *time.Time `json:"published_at,omitempty"`
+	BookISBN        string   `json:"book_isbn,omitempty" gorm:"size:20;index"`         // Normalized, for matching readers' copies
+	BookFingerprint string   `json:"book_fingerprint,omitempty" gorm:"size:64;index"` // TextFingerprint of the author's copy
 	
 	// Relationships
 	Author   User         `json:"author,omitempty"`
@@ -201,15 +250,21 @@ type PublishedNote struct {
 	Overlays []NoteOverlay `json:"overlays,omitempty"`
 }
 
-// NoteOverlay represents expert annotations/overlays
+// NoteOverlay represents expert annotations/overlays. Each belongs to one
+// published version of its note and is never changed.
 type NoteOverlay struct {
 	BaseModel
 	PublishedNoteID uuid.UUID `json:"published_note_id" gorm:"not null;index"`
+	Version         int       `json:"version" gorm:"not null;default:1;index"`
+	AnnotationID    *uuid.UUID `json:"-"` // The author's annotation it was published from
 	PageNumber      int       `json:"page_number"`
 	StartPosition   int       `json:"start_position"`
 	EndPosition     int       `json:"end_position"`
-	NoteType        string    `json:"note_type" gorm:"size:50"` // explanation, context, cross_reference
+	NoteType        string    `json:"note_type" gorm:"size:50"` // explanation, context, cross_reference, translation, historical_note
 	Content         string    `json:"content" gorm:"not null;type:text"`
+	Quote           string    `json:"quote,omitempty" gorm:"type:text"` // Passage the overlay is anchored to
+	QuotePrefix     string    `json:"quote_prefix,omitempty" gorm:"type:text"`
+	QuoteSuffix     string    `json:"quote_suffix,omitempty" gorm:"type:text"`
 	DisplayOrder    int       `json:"display_order" gorm:"default:0"`
 	
 	// Relationships
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ParallelPairStatus represents the state of a pair's alignment job
+type ParallelPairStatus string
+
+const (
+	ParallelPairPending  ParallelPairStatus = "pending"
+	ParallelPairAligning ParallelPairStatus = "aligning"
+	ParallelPairAligned  ParallelPairStatus = "aligned"
+	ParallelPairError    ParallelPairStatus = "error"
+)
+
+// Alignment levels stored for a parallel pair
+const (
+	AlignmentLevelChapter   = "chapter"
+	AlignmentLevelParagraph = "paragraph"
+	AlignmentLevelSentence  = "sentence"
+)
+
+// ParallelPair links an original text with a translation of it
+type ParallelPair struct {
+	BaseModel
+	UserID            uuid.UUID          `json:"user_id" gorm:"not null;index"`
+	OriginalBookID    uuid.UUID          `json:"original_book_id" gorm:"not null;index"`
+	TranslationBookID uuid.UUID          `json:"translation_book_id" gorm:"not null;index"`
+	Status            ParallelPairStatus `json:"status" gorm:"default:'pending';size:20"`
+	Error             string             `json:"error,omitempty" gorm:"type:text"`
+	LengthRatio       float64            `json:"length_ratio"` // Translation characters per original character
+	SegmentCount      int                `json:"segment_count" gorm:"default:0"`
+	AlignedAt         *time.Time         `json:"aligned_at"`
+
+	// Relationships
+	OriginalBook    Book `json:"original_book,omitempty" gorm:"foreignKey:OriginalBookID"`
+	TranslationBook Book `json:"translation_book,omitempty" gorm:"foreignKey:TranslationBookID"`
+}
+
+// AlignedSegment is one aligned region of a parallel pair. Offsets are rune
+// offsets into each book's BookContent.FullText; either side may be empty
+// when a passage has no counterpart (1-0 and 0-1 beads).
+type AlignedSegment struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PairID       uuid.UUID `json:"pair_id" gorm:"type:uuid;not null;index:idx_aligned_segments_pair_level,priority:1"`
+	Level        string    `json:"level" gorm:"size:20;not null;index:idx_aligned_segments_pair_level,priority:2"`
+	ChapterIndex int       `json:"chapter_index"`
+	Ordinal      int       `json:"ordinal" gorm:"index:idx_aligned_segments_pair_level,priority:3"`
+	SourceStart  int       `json:"source_start"`
+	SourceEnd    int       `json:"source_end"`
+	TargetStart  int       `json:"target_start"`
+	TargetEnd    int       `json:"target_end"`
+	BeadType     string    `json:"bead_type" gorm:"size:10"` // 1-1, 2-1, 1-0, ...
+	Marker       string    `json:"marker,omitempty" gorm:"size:50"`
+	Cost         float64   `json:"cost"`
+}
+
+// TableName returns the table name for the ParallelPair model
+func (ParallelPair) TableName() string {
+	return "parallel_pairs"
+}
+
+// TableName returns the table name for the AlignedSegment model
+func (AlignedSegment) TableName() string {
+	return "aligned_segments"
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PopularHighlight is a passage of a text that many readers highlighted,
+// aggregated anonymously across every copy with the same text fingerprint.
+// Offsets are in the reference copy; other copies find it by its quote.
+type PopularHighlight struct {
+	BaseModel
+	TextFingerprint string    `json:"-" gorm:"not null;size:64;index"`
+	ReferenceBookID uuid.UUID `json:"-" gorm:"not null"`
+	StartPosition   int       `json:"start_position"`
+	EndPosition     int       `json:"end_position"`
+	Quote           string    `json:"quote" gorm:"type:text"`
+	QuotePrefix     string    `json:"-" gorm:"type:text"`
+	QuoteSuffix     string    `json:"-" gorm:"type:text"`
+	ReaderCount     int       `json:"reader_count" gorm:"not null"` // Readers who highlighted the passage, never fewer than the threshold
+	TextReaders     int       `json:"text_readers" gorm:"not null"` // Readers with a copy of the text
+	ComputedAt      time.Time `json:"computed_at" gorm:"not null"`
+}
+
+// TableName returns the table name for the PopularHighlight model
+func (PopularHighlight) TableName() string {
+	return "popular_highlights"
+}
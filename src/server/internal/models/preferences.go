@@ -0,0 +1,18 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// UserPreference stores a user's preferences (theme, notification, reading
+// and privacy settings) as a JSON document
+type UserPreference struct {
+	BaseModel
+	UserID   uuid.UUID              `json:"user_id" gorm:"not null;uniqueIndex"`
+	Settings map[string]interface{} `json:"settings" gorm:"type:jsonb;serializer:json"`
+}
+
+// TableName returns the table name for the UserPreference model
+func (UserPreference) TableName() string {
+	return "user_preferences"
+}
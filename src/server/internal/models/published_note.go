@@ -0,0 +1,116 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Published note statuses
+const (
+	NoteDraft     = "draft"
+	NotePublished = "published"
+	NoteWithdrawn = "withdrawn" // Hidden from the catalog; subscribers keep their versions
+)
+
+// Published version review statuses
+const (
+	ReviewSubmitted = "submitted"
+	ReviewApproved  = "approved"
+	ReviewRejected  = "rejected"
+)
+
+// Overlay note types
+const (
+	OverlayExplanation    = "explanation"
+	OverlayContext        = "context"
+	OverlayCrossReference = "cross_reference"
+	OverlayTranslation    = "translation"
+	OverlayHistoricalNote = "historical_note"
+)
+
+// IsOverlayType reports whether t is a known overlay note type
+func IsOverlayType(t string) bool {
+	switch t {
+	case OverlayExplanation, OverlayContext, OverlayCrossReference, OverlayTranslation, OverlayHistoricalNote:
+		return true
+	}
+	return false
+}
+
+// IsDifficultyLevel reports whether level is a published note difficulty
+func IsDifficultyLevel(level string) bool {
+	switch level {
+	case "beginner", "intermediate", "advanced", "expert":
+		return true
+	}
+	return false
+}
+
+// PublishedNoteItem is an annotation in a published note's draft. The next
+// published version snapshots the items as overlays.
+type PublishedNoteItem struct {
+	BaseModel
+	PublishedNoteID uuid.UUID `json:"published_note_id" gorm:"not null;index"`
+	AnnotationID    uuid.UUID `json:"annotation_id" gorm:"not null;index"`
+	NoteType        string    `json:"note_type" gorm:"size:50;not null"`
+	DisplayOrder    int       `json:"display_order" gorm:"default:0"`
+}
+
+// TableName returns the table name for the PublishedNoteItem model
+func (PublishedNoteItem) TableName() string {
+	return "published_note_items"
+}
+
+// PublishedNoteVersion records a published version of a note: its listing
+// at the time and how many overlays it holds
+type PublishedNoteVersion struct {
+	BaseModel
+	PublishedNoteID uuid.UUID `json:"published_note_id" gorm:"not null;uniqueIndex:idx_published_note_versions_version"`
+	Version         int       `json:"version" gorm:"not null;uniqueIndex:idx_published_note_versions_version"`
+	Title           string    `json:"title" gorm:"not null;size:255"`
+	Description     string    `json:"description" gorm:"type:text"`
+	DifficultyLevel string    `json:"difficulty_level" gorm:"size:20"`
+	Changelog       string    `json:"changelog,omitempty" gorm:"type:text"`
+	OverlayCount    int       `json:"overlay_count"`
+
+	// Review, for notes whose versions must be approved before going live
+	ReviewStatus  string     `json:"review_status" gorm:"size:20;default:'approved';index"` // submitted, approved, rejected
+	ReviewComment string     `json:"review_comment,omitempty" gorm:"type:text"`
+	ReviewerID    *uuid.UUID `json:"-"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// TableName returns the table name for the PublishedNoteVersion model
+func (PublishedNoteVersion) TableName() string {
+	return "published_note_versions"
+}
+
+// NoteSubscription is a reader's subscription to a published note. Readers
+// follow the latest version unless they pin one.
+type NoteSubscription struct {
+	BaseModel
+	UserID          uuid.UUID `json:"user_id" gorm:"not null;index"`
+	PublishedNoteID uuid.UUID `json:"published_note_id" gorm:"not null;index"`
+	PinnedVersion   *int      `json:"pinned_version,omitempty"`
+}
+
+// TableName returns the table name for the NoteSubscription model
+func (NoteSubscription) TableName() string {
+	return "note_subscriptions"
+}
+
+// NoteRating is a subscriber's rating of a published note
+type NoteRating struct {
+	BaseModel
+	UserID          uuid.UUID `json:"user_id" gorm:"not null;index"`
+	PublishedNoteID uuid.UUID `json:"published_note_id" gorm:"not null;index"`
+	Rating          int       `json:"rating" gorm:"not null"` // 1 to 5
+	Review          string    `json:"review,omitempty" gorm:"type:text"`
+	Version         int       `json:"version"` // Version the subscriber had when rating
+}
+
+// TableName returns the table name for the NoteRating model
+func (NoteRating) TableName() string {
+	return "note_ratings"
+}
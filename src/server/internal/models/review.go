@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes of a review frequency setting
+const (
+	ReviewScopeDefault = "default"
+	ReviewScopeBook    = "book"
+	ReviewScopeTag     = "tag"
+)
+
+// AnnotationReview is the spaced-repetition state of a highlight or note
+// resurfaced in the daily review
+type AnnotationReview struct {
+	BaseModel
+	UserID       uuid.UUID  `json:"user_id" gorm:"not null;index"`
+	AnnotationID uuid.UUID  `json:"annotation_id" gorm:"not null;uniqueIndex"`
+	BookID       uuid.UUID  `json:"book_id" gorm:"not null;index"`
+	Favorite     bool       `json:"favorite" gorm:"default:false"`
+	Discarded    bool       `json:"discarded" gorm:"default:false;index"` // Never resurfaced again
+	DiscardedAt  *time.Time `json:"discarded_at"`
+
+	ReviewSchedule `gorm:"embedded"`
+
+	// Relationships
+	Annotation *Annotation `json:"annotation,omitempty"`
+}
+
+// ReviewSetting tunes how often annotations resurface, for all annotations
+// (default scope), for one book or for one tag. A book setting takes
+// precedence over tag settings, which take precedence over the default.
+type ReviewSetting struct {
+	BaseModel
+	UserID             uuid.UUID  `json:"user_id" gorm:"not null;index"`
+	Scope              string     `json:"scope" gorm:"size:20;not null"` // default, book, tag
+	BookID             *uuid.UUID `json:"book_id"`
+	Tag                string     `json:"tag" gorm:"size:100"`
+	IntervalMultiplier float64    `json:"interval_multiplier" gorm:"default:1"` // Below 1 resurfaces more often, above 1 less often
+	Excluded           bool       `json:"excluded" gorm:"default:false"`        // Leave these annotations out of the review
+	DailyLimit         int        `json:"daily_limit" gorm:"default:0"`         // Default scope only; 0 uses the server default
+	Algorithm          string     `json:"algorithm" gorm:"size:10"`             // Default scope only; scheduler for new items
+}
+
+// TableName returns the table name for the AnnotationReview model
+func (AnnotationReview) TableName() string {
+	return "annotation_reviews"
+}
+
+// TableName returns the table name for the ReviewSetting model
+func (ReviewSetting) TableName() string {
+	return "review_settings"
+}
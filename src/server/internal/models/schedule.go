@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ways a reading schedule splits its book
+const (
+	ScheduleByChapter    = "chapter"
+	ScheduleByPercentage = "percentage"
+)
+
+// ReadingSchedule plans a reading group's reading of a book on its list as
+// dated assignments. Assignments end at a percentage of the text, so each
+// member's progress in their own copy compares against them.
+type ReadingSchedule struct {
+	BaseModel
+	GroupID     uuid.UUID            `json:"group_id" gorm:"not null;index"`
+	GroupBookID uuid.UUID            `json:"group_book_id" gorm:"not null;index"`
+	CreatedBy   uuid.UUID            `json:"created_by" gorm:"not null"`
+	Title       string               `json:"title" gorm:"not null;size:255"`
+	SplitBy     string               `json:"split_by" gorm:"not null;size:20"` // chapter or percentage
+	SpoilerSafe bool                 `json:"spoiler_safe" gorm:"not null"`     // Hide threads anchored beyond a member's position
+	Assignments []ScheduleAssignment `json:"assignments,omitempty" gorm:"foreignKey:ScheduleID"`
+}
+
+// TableName returns the table name for the ReadingSchedule model
+func (ReadingSchedule) TableName() string {
+	return "reading_schedules"
+}
+
+// ScheduleAssignment is the stretch of a book to read by a due date, from
+// where the previous assignment ended
+type ScheduleAssignment struct {
+	BaseModel
+	ScheduleID   uuid.UUID `json:"schedule_id" gorm:"not null;index"`
+	Position     int       `json:"position"`
+	Title        string    `json:"title" gorm:"not null;size:255"`
+	DueAt        time.Time `json:"due_at" gorm:"not null"`
+	StartPercent float64   `json:"start_percent"`
+	EndPercent   float64   `json:"end_percent"`
+	FirstChapter *int      `json:"first_chapter,omitempty"` // Chapter indexes, for schedules by chapter
+	LastChapter  *int      `json:"last_chapter,omitempty"`
+	Note         string    `json:"note,omitempty" gorm:"type:text"`
+}
+
+// TableName returns the table name for the ScheduleAssignment model
+func (ScheduleAssignment) TableName() string {
+	return "schedule_assignments"
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scholar application statuses
+const (
+	ApplicationSubmitted = "submitted"
+	ApplicationApproved  = "approved"
+	ApplicationRejected  = "rejected"
+	ApplicationWithdrawn = "withdrawn" // By the applicant, before review
+	ApplicationRevoked   = "revoked"   // Verification withdrawn by an admin after approval
+)
+
+// Review audit subjects
+const (
+	AuditScholarApplication = "scholar_application"
+	AuditNoteVersion        = "note_version"
+)
+
+// ScholarApplication is a user's request to be verified as a scholar, with
+// the credentials admins review
+type ScholarApplication struct {
+	BaseModel
+	UserID        uuid.UUID  `json:"user_id" gorm:"not null;index"`
+	Status        string     `json:"status" gorm:"not null;size:20;default:'submitted';index"`
+	FullName      string     `json:"full_name" gorm:"not null;size:255"`
+	Affiliation   string     `json:"affiliation" gorm:"size:255"` // University, institute or other
+	Field         string     `json:"field" gorm:"not null;size:255"`
+	Credentials   string     `json:"credentials" gorm:"not null;type:text"` // Degrees, positions, publications
+	EvidenceURLs  []string   `json:"evidence_urls" gorm:"type:jsonb;serializer:json"`
+	ORCID         string     `json:"orcid,omitempty" gorm:"size:19"`
+	ReviewerID    *uuid.UUID `json:"-"`
+	ReviewComment string     `json:"review_comment,omitempty" gorm:"type:text"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// TableName returns the table name for the ScholarApplication model
+func (ScholarApplication) TableName() string {
+	return "scholar_applications"
+}
+
+// ReviewAuditEntry records a decision on a scholar application or a
+// submitted note version: who made it, the change of status and why
+type ReviewAuditEntry struct {
+	BaseModel
+	SubjectType string    `json:"subject_type" gorm:"not null;size:30;index:idx_review_audit_subject"`
+	SubjectID   uuid.UUID `json:"subject_id" gorm:"not null;index:idx_review_audit_subject"`
+	ActorID     uuid.UUID `json:"actor_id" gorm:"not null;index"`
+	Action      string    `json:"action" gorm:"not null;size:20"` // submitted, approved, rejected, withdrawn, revoked
+	FromStatus  string    `json:"from_status,omitempty" gorm:"size:20"`
+	ToStatus    string    `json:"to_status" gorm:"not null;size:20"`
+	Comment     string    `json:"comment,omitempty" gorm:"type:text"`
+}
+
+// TableName returns the table name for the ReviewAuditEntry model
+func (ReviewAuditEntry) TableName() string {
+	return "review_audit_entries"
+}
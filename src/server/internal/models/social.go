@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Activity types, the domain events shown in followers' feeds
+const (
+	ActivityStartedBook     = "started_book"
+	ActivityFinishedBook    = "finished_book"
+	ActivityPublishedNote   = "published_note"
+	ActivityPublicHighlight = "public_highlight"
+	ActivityGoalReached     = "goal_reached"
+)
+
+// Profile visibilities, from a user's privacy settings
+const (
+	ProfilePublic  = "public"
+	ProfileFriends = "friends" // Users who follow each other
+	ProfilePrivate = "private"
+)
+
+// Follow is a user following another's activity. Unfollowing deletes it.
+type Follow struct {
+	BaseModel
+	FollowerID uuid.UUID `json:"follower_id" gorm:"not null;uniqueIndex:idx_follows_pair"`
+	FolloweeID uuid.UUID `json:"followee_id" gorm:"not null;uniqueIndex:idx_follows_pair;index"`
+}
+
+// TableName returns the table name for the Follow model
+func (Follow) TableName() string {
+	return "follows"
+}
+
+// UserMute hides a user's activity from another's feed without
+// unfollowing them. Unmuting deletes it.
+type UserMute struct {
+	BaseModel
+	UserID      uuid.UUID `json:"user_id" gorm:"not null;uniqueIndex:idx_user_mutes_pair"`
+	MutedUserID uuid.UUID `json:"muted_user_id" gorm:"not null;uniqueIndex:idx_user_mutes_pair"`
+}
+
+// TableName returns the table name for the UserMute model
+func (UserMute) TableName() string {
+	return "user_mutes"
+}
+
+// ActivityEvent is something a user did that their followers may see.
+// Key identifies the event so it is recorded once, e.g. finishing a given
+// book.
+type ActivityEvent struct {
+	BaseModel
+	UserID    uuid.UUID  `json:"user_id" gorm:"not null;uniqueIndex:idx_activity_events_key;index"`
+	Type      string     `json:"type" gorm:"not null;size:30"`
+	Key       string     `json:"-" gorm:"not null;size:255;uniqueIndex:idx_activity_events_key"`
+	BookID    *uuid.UUID `json:"book_id,omitempty"`
+	SubjectID *uuid.UUID `json:"subject_id,omitempty"`     // The annotation or published note
+	Summary   string     `json:"summary" gorm:"type:text"` // e.g. the book's title or the highlighted quote
+}
+
+// TableName returns the table name for the ActivityEvent model
+func (ActivityEvent) TableName() string {
+	return "activity_events"
+}
+
+// FeedItem is an activity event delivered to a follower's feed
+type FeedItem struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID    uuid.UUID `json:"user_id" gorm:"not null;uniqueIndex:idx_feed_items_event"`
+	EventID   uuid.UUID `json:"event_id" gorm:"not null;uniqueIndex:idx_feed_items_event;index"`
+	ActorID   uuid.UUID `json:"actor_id" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null;index"`
+}
+
+// TableName returns the table name for the FeedItem model
+func (FeedItem) TableName() string {
+	return "feed_items"
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Entity types recorded in the change log
+const (
+	EntityAnnotation      = "annotation"
+	EntityBook            = "book"
+	EntityBookmark        = "bookmark"
+	EntityReadingProgress = "reading_progress"
+	EntityReadingSession  = "reading_session"
+	EntityTag             = "tag"
+	EntityPreferences     = "preferences"
+)
+
+// Change log operations
+const (
+	ChangeUpsert = "upsert"
+	ChangeDelete = "delete" // Tombstone: the entity was deleted or soft-deleted
+)
+
+// SyncCounter holds the last sync sequence number issued for a user. Each
+// change takes the next number while holding the row lock, so a user's
+// changes are numbered in commit order.
+type SyncCounter struct {
+	UserID uuid.UUID `json:"user_id" gorm:"type:uuid;primary_key"`
+	Seq    int64     `json:"seq" gorm:"not null;default:0"`
+}
+
+// ChangeLogEntry is the latest change to one of a user's entities. Entries
+// are written by database triggers in the transaction that makes the
+// change; a later change to the same entity moves its entry to a new Seq.
+type ChangeLogEntry struct {
+	UserID     uuid.UUID `json:"user_id" gorm:"type:uuid;primary_key"`
+	EntityType string    `json:"entity_type" gorm:"size:30;primary_key"`
+	EntityID   uuid.UUID `json:"entity_id" gorm:"type:uuid;primary_key"`
+	Seq        int64     `json:"seq" gorm:"not null;index:idx_change_log_user_seq"`
+	Operation  string    `json:"operation" gorm:"size:10;not null"` // upsert, delete
+	ChangedAt  time.Time `json:"changed_at" gorm:"not null"`
+}
+
+// TableName returns the table name for the SyncCounter model
+func (SyncCounter) TableName() string {
+	return "sync_counters"
+}
+
+// TableName returns the table name for the ChangeLogEntry model
+func (ChangeLogEntry) TableName() string {
+	return "change_log"
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// TagPathSeparator separates the levels of a hierarchical tag name, as in
+// "philosophy/ethics"
+const TagPathSeparator = "/"
+
+// TagAlias is another name for a tag. Annotations and books tagged with an
+// alias get the tag itself.
+type TagAlias struct {
+	BaseModel
+	UserID uuid.UUID `json:"user_id" gorm:"not null;index"`
+	TagID  uuid.UUID `json:"tag_id" gorm:"not null;index"`
+	Alias  string    `json:"alias" gorm:"size:100;not null"`
+}
+
+// TableName returns the table name for the TagAlias model
+func (TagAlias) TableName() string {
+	return "tag_aliases"
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Spaced-repetition algorithms
+const (
+	SchedulerSM2  = "sm2"
+	SchedulerFSRS = "fsrs"
+)
+
+// Review states of a scheduled item
+const (
+	ReviewStateNew        = "new"
+	ReviewStateLearning   = "learning"
+	ReviewStateReview     = "review"
+	ReviewStateRelearning = "relearning"
+)
+
+// Review grades, following Anki's four answer buttons
+const (
+	GradeAgain = 1
+	GradeHard  = 2
+	GradeGood  = 3
+	GradeEasy  = 4
+)
+
+// ReviewSchedule is the spaced-repetition state of a reviewable item. SM-2
+// uses EaseFactor and Repetitions; FSRS uses Stability and Difficulty.
+type ReviewSchedule struct {
+	Algorithm      string     `json:"algorithm" gorm:"size:10;default:'sm2'"`
+	State          string     `json:"state" gorm:"size:20;default:'new';index"`
+	DueAt          time.Time  `json:"due_at" gorm:"index"`
+	IntervalDays   float64    `json:"interval_days" gorm:"default:0"`
+	EaseFactor     float64    `json:"ease_factor" gorm:"default:2.5"`
+	Stability      float64    `json:"stability" gorm:"default:0"`
+	Difficulty     float64    `json:"difficulty" gorm:"default:0"`
+	Repetitions    int        `json:"repetitions" gorm:"default:0"`
+	Lapses         int        `json:"lapses" gorm:"default:0"`
+	LastReviewedAt *time.Time `json:"last_reviewed_at"`
+}
+
+// VocabularyWord is a word saved by a reader for review
+type VocabularyWord struct {
+	BaseModel
+	UserID       uuid.UUID  `json:"user_id" gorm:"not null;index"`
+	BookID       *uuid.UUID `json:"book_id" gorm:"index"`
+	LookupID     *uuid.UUID `json:"lookup_id"` // WordLookup the word was saved from, if any
+	Word         string     `json:"word" gorm:"size:100;not null"`
+	Lemma        string     `json:"lemma" gorm:"size:100;not null"`
+	Language     string     `json:"language" gorm:"size:10;not null"`
+	PartOfSpeech string     `json:"part_of_speech" gorm:"size:30"`
+	Definition   string     `json:"definition" gorm:"type:text"`
+	Context      string     `json:"context" gorm:"type:text"` // Sentence the word was found in
+	Position     *int       `json:"position"`                 // Rune offset into BookContent.FullText
+	PageNumber   int        `json:"page_number"`
+	Notes        string     `json:"notes" gorm:"type:text"`
+	Suspended    bool       `json:"suspended" gorm:"default:false"`
+
+	ReviewSchedule `gorm:"embedded"`
+
+	// Relationships
+	Book *Book `json:"book,omitempty"`
+}
+
+// VocabularyReview logs one review of a vocabulary word
+type VocabularyReview struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	WordID       uuid.UUID `json:"word_id" gorm:"type:uuid;not null;index"`
+	UserID       uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index:idx_vocabulary_reviews_user_time,priority:1"`
+	Grade        int       `json:"grade"`
+	Algorithm    string    `json:"algorithm" gorm:"size:10"`
+	StateBefore  string    `json:"state_before" gorm:"size:20"`
+	ElapsedDays  float64   `json:"elapsed_days"`  // Days since the previous review
+	IntervalDays float64   `json:"interval_days"` // Interval scheduled by this review
+	DurationMs   int       `json:"duration_ms"`   // Time the reader took to answer
+	ReviewedAt   time.Time `json:"reviewed_at" gorm:"index:idx_vocabulary_reviews_user_time,priority:2"`
+}
+
+// TableName returns the table name for the VocabularyWord model
+func (VocabularyWord) TableName() string {
+	return "vocabulary_words"
+}
+
+// TableName returns the table name for the VocabularyReview model
+func (VocabularyReview) TableName() string {
+	return "vocabulary_reviews"
+}
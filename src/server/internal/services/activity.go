@@ -0,0 +1,588 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/classius/server/internal/models"
+)
+
+// Reading goals, until users can set their own
+const (
+	DefaultYearlyBooksGoal  = 24
+	DefaultMonthlyBooksGoal = 2
+)
+
+// Activity limits
+const (
+	maxActivitySummaryRunes = 280 // Runes of a highlighted quote shown in feeds
+	profileActivityItems    = 10  // Recent events on a profile
+)
+
+// ActivityService manages the follow graph, mutes, activity feeds and
+// public profiles. Events are delivered to followers' feeds when they
+// happen; what a feed shows is checked again against the actor's current
+// privacy settings when it is read.
+type ActivityService struct {
+	db *gorm.DB
+}
+
+// NewActivityService creates a new activity service
+func NewActivityService(db *gorm.DB) *ActivityService {
+	return &ActivityService{db: db}
+}
+
+// UserSummary is a user as listed among followers, following and mutes
+type UserSummary struct {
+	ID              uuid.UUID `json:"id"`
+	Username        string    `json:"username"`
+	Name            string    `json:"name"`
+	AvatarURL       string    `json:"avatar_url"`
+	VerifiedScholar bool      `json:"verified_scholar"`
+}
+
+// ActivityView is an event with its actor
+type ActivityView struct {
+	ID            uuid.UUID  `json:"id"`
+	Type          string     `json:"type"`
+	UserID        uuid.UUID  `json:"user_id"`
+	ActorName     string     `json:"actor_name"`
+	ActorUsername string     `json:"actor_username"`
+	ActorAvatar   string     `json:"actor_avatar"`
+	ActorVerified bool       `json:"actor_verified"`
+	BookID        *uuid.UUID `json:"book_id,omitempty"`
+	SubjectID     *uuid.UUID `json:"subject_id,omitempty"`
+	Summary       string     `json:"summary"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ItemID        uuid.UUID  `json:"-"` // The feed item, for cursors
+	ItemCreatedAt time.Time  `json:"-"`
+}
+
+// ActivityFeed is a page of a user's feed
+type ActivityFeed struct {
+	Items      []ActivityView `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"` // Pass back for the next page
+	HasMore    bool           `json:"has_more"`
+}
+
+// PublicProfile is a user's profile as the viewer may see it. Restricted
+// profiles show only who the user is.
+type PublicProfile struct {
+	UserSummary
+	ScholarField   string         `json:"scholar_field,omitempty"`
+	Restricted     bool           `json:"restricted"`
+	Followers      *int64         `json:"followers,omitempty"`
+	Following      *int64         `json:"following,omitempty"`
+	IsFollowing    bool           `json:"is_following"`
+	FollowsYou     bool           `json:"follows_you"`
+	Muted          bool           `json:"muted"`
+	PublishedNotes *int64         `json:"published_notes,omitempty"`
+	Stats          *ProfileStats  `json:"stats,omitempty"` // When the user's reading stats are public
+	RecentActivity []ActivityView `json:"recent_activity,omitempty"`
+}
+
+// ProfileStats are the reading stats shown on a profile
+type ProfileStats struct {
+	BooksFinished    int64  `json:"books_finished"`
+	BooksReading     int64  `json:"books_reading"`
+	PublicHighlights *int64 `json:"public_highlights,omitempty"` // When the user's annotations are public
+}
+
+// privacySettings are the privacy preferences the services enforce
+type privacySettings struct {
+	ProfileVisibility    string `json:"profile_visibility"`
+	AnnotationsPublic    bool   `json:"annotations_public"`
+	ReadingStatsPublic   bool   `json:"reading_stats_public"`
+	ShareReadingActivity bool   `json:"share_reading_activity"`
+}
+
+// loadPrivacySettings returns a user's privacy settings over the defaults
+// of their preferences
+func loadPrivacySettings(db *gorm.DB, userID uuid.UUID) (privacySettings, error) {
+	settings := privacySettings{ProfileVisibility: models.ProfilePublic, ReadingStatsPublic: true}
+	var stored models.UserPreference
+	result := db.Where("user_id = ?", userID).Limit(1).Find(&stored)
+	if result.Error != nil {
+		return settings, fmt.Errorf("failed to retrieve preferences: %w", result.Error)
+	}
+	privacy, ok := stored.Settings["privacy_settings"]
+	if result.RowsAffected == 0 || !ok {
+		return settings, nil
+	}
+	data, err := json.Marshal(privacy)
+	if err != nil {
+		return settings, fmt.Errorf("failed to read privacy settings: %w", err)
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return settings, fmt.Errorf("failed to read privacy settings: %w", err)
+	}
+	return settings, nil
+}
+
+// profileVisibilitySQL is the profile visibility of the user in userColumn
+func profileVisibilitySQL(userColumn string) string {
+	return fmt.Sprintf(`COALESCE((SELECT up.settings->'privacy_settings'->>'profile_visibility' FROM user_preferences up
+		WHERE up.user_id = %s AND up.deleted_at IS NULL), 'public')`, userColumn)
+}
+
+// profileVisibleTo builds the condition that the viewer may see the
+// profile and activity of the user in userColumn: their own, public ones,
+// and friends-only ones of users they follow who follow them back
+func profileVisibleTo(userColumn string, viewerID uuid.UUID) (string, []interface{}) {
+	visibility := profileVisibilitySQL(userColumn)
+	return fmt.Sprintf(`(%[1]s = ? OR %[2]s = 'public' OR (%[2]s = 'friends' AND
+		EXISTS (SELECT 1 FROM follows fo WHERE fo.follower_id = ? AND fo.followee_id = %[1]s) AND
+		EXISTS (SELECT 1 FROM follows fb WHERE fb.follower_id = %[1]s AND fb.followee_id = ?)))`, userColumn, visibility),
+		[]interface{}{viewerID, viewerID, viewerID}
+}
+
+// activityAllowedSQL is the condition that the actor's privacy settings,
+// and the state of its subject, still allow showing event e: reading
+// activity when they share it, highlights still public by readers whose
+// annotations are public, and notes still published
+var activityAllowedSQL = `((e.type IN ('started_book', 'finished_book', 'goal_reached') AND ` +
+	privacyOptIn("e.user_id", "share_reading_activity") + `) OR
+	(e.type = 'public_highlight' AND ` + privacyOptIn("e.user_id", "annotations_public") + ` AND
+	 EXISTS (SELECT 1 FROM annotations sa WHERE sa.id = e.subject_id AND sa.visibility = 'public' AND sa.deleted_at IS NULL)) OR
+	(e.type = 'published_note' AND
+	 EXISTS (SELECT 1 FROM published_notes sn WHERE sn.id = e.subject_id AND sn.status = 'published' AND sn.deleted_at IS NULL)))`
+
+// activityColumns are the columns of an ActivityView
+const activityColumns = `e.id, e.type, e.user_id, e.book_id, e.subject_id, e.summary, e.created_at,
+	COALESCE(NULLIF(u.full_name, ''), u.username) AS actor_name, u.username AS actor_username,
+	u.avatar_url AS actor_avatar, u.verified_scholar AS actor_verified`
+
+// RecordActivity records an event and delivers it to the feeds of the
+// actor's followers, if their privacy settings allow. An event already
+// recorded under its key is ignored.
+func RecordActivity(tx *gorm.DB, event *models.ActivityEvent) error {
+	result := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "key"}},
+		DoNothing: true,
+	}).Create(event)
+	if result.Error != nil {
+		return fmt.Errorf("failed to record activity: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	var allowed int64
+	if err := tx.Table("activity_events e").
+		Where("e.id = ?", event.ID).
+		Where(activityAllowedSQL).
+		Where(profileVisibilitySQL("e.user_id") + " <> 'private'").
+		Count(&allowed).Error; err != nil {
+		return fmt.Errorf("failed to check activity privacy: %w", err)
+	}
+	if allowed == 0 {
+		return nil
+	}
+	if err := tx.Exec(`INSERT INTO feed_items (id, user_id, event_id, actor_id, created_at)
+		SELECT gen_random_uuid(), f.follower_id, ?, ?, ? FROM follows f WHERE f.followee_id = ? AND f.deleted_at IS NULL
+		ON CONFLICT (user_id, event_id) DO NOTHING`, event.ID, event.UserID, event.CreatedAt, event.UserID).Error; err != nil {
+		return fmt.Errorf("failed to deliver activity: %w", err)
+	}
+	return nil
+}
+
+// RecordProgressActivity records what a change in a user's progress
+// through a book means: starting it, finishing it, and reaching a reading
+// goal with it. Percentages are from 0 to 100.
+func RecordProgressActivity(db *gorm.DB, userID, bookID uuid.UUID, before, after float64) error {
+	if before > 0 && (before >= 100 || after < 100) {
+		return nil
+	}
+	var book models.Book
+	result := db.Select("id, title").Where("id = ?", bookID).Limit(1).Find(&book)
+	if result.Error != nil {
+		return fmt.Errorf("failed to retrieve book: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if before <= 0 && after > 0 {
+			if err := RecordActivity(tx, &models.ActivityEvent{UserID: userID, Type: models.ActivityStartedBook,
+				Key: "started_book:" + bookID.String(), BookID: &bookID, Summary: book.Title}); err != nil {
+				return err
+			}
+		}
+		if before >= 100 || after < 100 {
+			return nil
+		}
+		if err := RecordActivity(tx, &models.ActivityEvent{UserID: userID, Type: models.ActivityFinishedBook,
+			Key: "finished_book:" + bookID.String(), BookID: &bookID, Summary: book.Title}); err != nil {
+			return err
+		}
+		return recordGoalActivity(tx, userID, time.Now().UTC())
+	})
+}
+
+// recordGoalActivity records the reading goals the user's finished books
+// reach at now
+func recordGoalActivity(tx *gorm.DB, userID uuid.UUID, now time.Time) error {
+	goals := []struct {
+		period string
+		since  time.Time
+		goal   int
+		key    string
+	}{
+		{"this year", time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.UTC), DefaultYearlyBooksGoal, now.Format("2006")},
+		{"this month", time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), DefaultMonthlyBooksGoal, now.Format("2006-01")},
+	}
+	for _, goal := range goals {
+		var finished int64
+		if err := tx.Model(&models.ReadingProgress{}).
+			Where("user_id = ? AND percentage >= 100 AND updated_at >= ?", userID, goal.since).
+			Count(&finished).Error; err != nil {
+			return fmt.Errorf("failed to count finished books: %w", err)
+		}
+		if finished < int64(goal.goal) {
+			continue
+		}
+		if err := RecordActivity(tx, &models.ActivityEvent{UserID: userID, Type: models.ActivityGoalReached,
+			Key:     "goal_reached:" + goal.key,
+			Summary: fmt.Sprintf("Read %d books %s", goal.goal, goal.period)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordHighlightActivity records a highlight being made public
+func RecordHighlightActivity(db *gorm.DB, annotation *models.Annotation) error {
+	if annotation.Type != "highlight" || annotation.Visibility != models.VisibilityPublic {
+		return nil
+	}
+	summary := strings.TrimSpace(annotation.SelectedText)
+	if utf8.RuneCountInString(summary) > maxActivitySummaryRunes {
+		summary = string([]rune(summary)[:maxActivitySummaryRunes]) + "…"
+	}
+	bookID, annotationID := annotation.BookID, annotation.ID
+	return RecordActivity(db, &models.ActivityEvent{UserID: annotation.UserID, Type: models.ActivityPublicHighlight,
+		Key: "public_highlight:" + annotationID.String(), BookID: &bookID, SubjectID: &annotationID, Summary: summary})
+}
+
+// Follow makes the follower follow a user; following again does nothing
+func (s *ActivityService) Follow(ctx context.Context, followerID, userID uuid.UUID) error {
+	db := s.db.WithContext(ctx)
+	if followerID == userID {
+		return fmt.Errorf("%w follow: you cannot follow yourself", ErrInvalid)
+	}
+	if err := requireUser(db, userID); err != nil {
+		return err
+	}
+	follow := models.Follow{FollowerID: followerID, FolloweeID: userID}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&follow).Error; err != nil {
+		return fmt.Errorf("failed to follow user: %w", err)
+	}
+	return nil
+}
+
+// Unfollow stops following a user, removing their activity from the feed
+func (s *ActivityService) Unfollow(ctx context.Context, followerID, userID uuid.UUID) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("follower_id = ? AND followee_id = ?", followerID, userID).Delete(&models.Follow{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to unfollow user: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("follow %w", ErrNotFound)
+		}
+		if err := tx.Where("user_id = ? AND actor_id = ?", followerID, userID).Delete(&models.FeedItem{}).Error; err != nil {
+			return fmt.Errorf("failed to clear feed: %w", err)
+		}
+		return nil
+	})
+}
+
+// Followers lists who follows a user whose profile the viewer may see
+func (s *ActivityService) Followers(ctx context.Context, viewerID, userID uuid.UUID, limit, offset int) ([]UserSummary, int64, error) {
+	return s.followList(ctx, viewerID, userID, "f.followee_id = ?", "f.follower_id", limit, offset)
+}
+
+// Following lists who a user whose profile the viewer may see follows
+func (s *ActivityService) Following(ctx context.Context, viewerID, userID uuid.UUID, limit, offset int) ([]UserSummary, int64, error) {
+	return s.followList(ctx, viewerID, userID, "f.follower_id = ?", "f.followee_id", limit, offset)
+}
+
+func (s *ActivityService) followList(ctx context.Context, viewerID, userID uuid.UUID, match, listed string, limit, offset int) ([]UserSummary, int64, error) {
+	db := s.db.WithContext(ctx)
+	visible, err := profileVisible(db, viewerID, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !visible {
+		return nil, 0, fmt.Errorf("%w: this profile is private", ErrAccessDenied)
+	}
+
+	query := s.users(ctx).
+		Joins("JOIN follows f ON "+listed+" = u.id AND f.deleted_at IS NULL").
+		Where(match, userID)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count follows: %w", err)
+	}
+	users := []UserSummary{}
+	if err := query.Order("f.created_at DESC").Limit(limit).Offset(offset).Scan(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve follows: %w", err)
+	}
+	return users, total, nil
+}
+
+// Mute hides a user's activity from the user's feed
+func (s *ActivityService) Mute(ctx context.Context, userID, mutedID uuid.UUID) error {
+	db := s.db.WithContext(ctx)
+	if userID == mutedID {
+		return fmt.Errorf("%w mute: you cannot mute yourself", ErrInvalid)
+	}
+	if err := requireUser(db, mutedID); err != nil {
+		return err
+	}
+	mute := models.UserMute{UserID: userID, MutedUserID: mutedID}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&mute).Error; err != nil {
+		return fmt.Errorf("failed to mute user: %w", err)
+	}
+	return nil
+}
+
+// Unmute shows a muted user's activity again
+func (s *ActivityService) Unmute(ctx context.Context, userID, mutedID uuid.UUID) error {
+	result := s.db.WithContext(ctx).Unscoped().
+		Where("user_id = ? AND muted_user_id = ?", userID, mutedID).Delete(&models.UserMute{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to unmute user: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("mute %w", ErrNotFound)
+	}
+	return nil
+}
+
+// Mutes lists the users the user muted
+func (s *ActivityService) Mutes(ctx context.Context, userID uuid.UUID) ([]UserSummary, error) {
+	users := []UserSummary{}
+	if err := s.users(ctx).
+		Joins("JOIN user_mutes m ON m.muted_user_id = u.id AND m.deleted_at IS NULL").
+		Where("m.user_id = ?", userID).
+		Order("m.created_at DESC").Scan(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve mutes: %w", err)
+	}
+	return users, nil
+}
+
+// Feed returns a page of the user's feed, newest first, after the cursor
+// of the previous page. Muted users and activity their privacy settings
+// no longer share are left out.
+func (s *ActivityService) Feed(ctx context.Context, userID uuid.UUID, cursor string, limit int) (*ActivityFeed, error) {
+	visible, visibleArgs := profileVisibleTo("e.user_id", userID)
+	query := s.db.WithContext(ctx).Table("feed_items f").
+		Select(activityColumns+", f.id AS item_id, f.created_at AS item_created_at").
+		Joins("JOIN activity_events e ON e.id = f.event_id AND e.deleted_at IS NULL").
+		Joins("JOIN users u ON u.id = e.user_id").
+		Where("f.user_id = ?", userID).
+		Where("NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.user_id = f.user_id AND m.muted_user_id = f.actor_id AND m.deleted_at IS NULL)").
+		Where(activityAllowedSQL).
+		Where(visible, visibleArgs...)
+	if cursor != "" {
+		at, id, err := decodeFeedCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("(f.created_at, f.id) < (?, ?)", at, id)
+	}
+
+	var items []ActivityView
+	if err := query.Order("f.created_at DESC, f.id DESC").Limit(limit + 1).Scan(&items).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve feed: %w", err)
+	}
+	feed := &ActivityFeed{Items: items}
+	if len(items) > limit {
+		feed.Items, feed.HasMore = items[:limit], true
+	}
+	if feed.Items == nil {
+		feed.Items = []ActivityView{}
+	}
+	if feed.HasMore {
+		last := feed.Items[len(feed.Items)-1]
+		feed.NextCursor = encodeFeedCursor(last.ItemCreatedAt, last.ItemID)
+	}
+	return feed, nil
+}
+
+// Profile returns a user's profile as the viewer may see it
+func (s *ActivityService) Profile(ctx context.Context, viewerID, userID uuid.UUID) (*PublicProfile, error) {
+	db := s.db.WithContext(ctx)
+	var profile PublicProfile
+	result := s.users(ctx).Select(userSummaryColumns+", u.scholar_field").Where("u.id = ?", userID).Limit(1).Scan(&profile)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve user: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("user %w", ErrNotFound)
+	}
+
+	var relation struct {
+		IsFollowing bool
+		FollowsYou  bool
+		Muted       bool
+	}
+	if err := db.Raw(`SELECT
+		EXISTS (SELECT 1 FROM follows WHERE follower_id = ? AND followee_id = ?) AS is_following,
+		EXISTS (SELECT 1 FROM follows WHERE follower_id = ? AND followee_id = ?) AS follows_you,
+		EXISTS (SELECT 1 FROM user_mutes WHERE user_id = ? AND muted_user_id = ? AND deleted_at IS NULL) AS muted`,
+		viewerID, userID, userID, viewerID, viewerID, userID).Scan(&relation).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve follows: %w", err)
+	}
+	profile.IsFollowing, profile.FollowsYou, profile.Muted = relation.IsFollowing, relation.FollowsYou, relation.Muted
+
+	settings, err := loadPrivacySettings(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	self := viewerID == userID
+	profile.Restricted = !self && !profileAllows(settings.ProfileVisibility, relation.IsFollowing && relation.FollowsYou)
+	if profile.Restricted {
+		profile.ScholarField = ""
+		return &profile, nil
+	}
+
+	var counts struct {
+		Followers      int64
+		Following      int64
+		PublishedNotes int64
+	}
+	if err := db.Raw(`SELECT
+		(SELECT COUNT(*) FROM follows WHERE followee_id = ? AND deleted_at IS NULL) AS followers,
+		(SELECT COUNT(*) FROM follows WHERE follower_id = ? AND deleted_at IS NULL) AS following,
+		(SELECT COUNT(*) FROM published_notes WHERE author_id = ? AND status = ? AND deleted_at IS NULL) AS published_notes`,
+		userID, userID, userID, models.NotePublished).Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("failed to count follows: %w", err)
+	}
+	profile.Followers, profile.Following, profile.PublishedNotes = &counts.Followers, &counts.Following, &counts.PublishedNotes
+
+	if self || settings.ReadingStatsPublic {
+		if profile.Stats, err = profileStats(db, userID, self || settings.AnnotationsPublic); err != nil {
+			return nil, err
+		}
+	}
+
+	profile.RecentActivity = []ActivityView{}
+	if err := db.Table("activity_events e").
+		Select(activityColumns).
+		Joins("JOIN users u ON u.id = e.user_id").
+		Where("e.user_id = ? AND e.deleted_at IS NULL", userID).
+		Where(activityAllowedSQL).
+		Order("e.created_at DESC").Limit(profileActivityItems).
+		Scan(&profile.RecentActivity).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve activity: %w", err)
+	}
+	return &profile, nil
+}
+
+// userSummaryColumns are the columns of a UserSummary
+const userSummaryColumns = `u.id, u.username, COALESCE(NULLIF(u.full_name, ''), u.username) AS name,
+	u.avatar_url, u.verified_scholar`
+
+// users selects users as summaries
+func (s *ActivityService) users(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).Table("users u").
+		Select(userSummaryColumns).
+		Where("u.deleted_at IS NULL")
+}
+
+// profileStats counts a user's reading for their profile
+func profileStats(db *gorm.DB, userID uuid.UUID, highlights bool) (*ProfileStats, error) {
+	var stats ProfileStats
+	if err := db.Raw(`SELECT
+		COUNT(*) FILTER (WHERE percentage >= 100) AS books_finished,
+		COUNT(*) FILTER (WHERE percentage > 0 AND percentage < 100) AS books_reading
+		FROM reading_progress WHERE user_id = ? AND deleted_at IS NULL`, userID).Scan(&stats).Error; err != nil {
+		return nil, fmt.Errorf("failed to count books: %w", err)
+	}
+	if highlights {
+		var count int64
+		if err := db.Model(&models.Annotation{}).
+			Where("user_id = ? AND type = 'highlight' AND visibility = ?", userID, models.VisibilityPublic).
+			Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to count highlights: %w", err)
+		}
+		stats.PublicHighlights = &count
+	}
+	return &stats, nil
+}
+
+// profileAllows reports whether a profile with the given visibility is
+// shown to another user, who is a friend if they follow each other
+func profileAllows(visibility string, friends bool) bool {
+	switch visibility {
+	case models.ProfilePrivate:
+		return false
+	case models.ProfileFriends:
+		return friends
+	}
+	return true
+}
+
+// profileVisible reports whether the viewer may see a user's profile
+func profileVisible(db *gorm.DB, viewerID, userID uuid.UUID) (bool, error) {
+	if err := requireUser(db, userID); err != nil {
+		return false, err
+	}
+	visible, args := profileVisibleTo("u.id", viewerID)
+	var count int64
+	if err := db.Table("users u").Where("u.id = ?", userID).Where(visible, args...).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to retrieve profile: %w", err)
+	}
+	return count > 0, nil
+}
+
+// requireUser checks that a user exists
+func requireUser(db *gorm.DB, userID uuid.UUID) error {
+	var count int64
+	if err := db.Model(&models.User{}).Where("id = ?", userID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to retrieve user: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("user %w", ErrNotFound)
+	}
+	return nil
+}
+
+// Feed cursors are opaque to clients: the time and ID of the last item
+// delivered, so items sharing a time are never skipped
+func encodeFeedCursor(at time.Time, id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(at.UnixMicro(), 10) + ":" + id.String()))
+}
+
+func decodeFeedCursor(cursor string) (time.Time, uuid.UUID, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("%w cursor", ErrInvalid)
+	}
+	micros, idStr, ok := strings.Cut(string(data), ":")
+	if !ok {
+		return time.Time{}, uuid.Nil, fmt.Errorf("%w cursor", ErrInvalid)
+	}
+	at, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("%w cursor", ErrInvalid)
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("%w cursor", ErrInvalid)
+	}
+	return time.UnixMicro(at).UTC(), id, nil
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestFeedCursor(t *testing.T) {
+	at := time.Date(2026, 10, 19, 8, 30, 15, 123456000, time.UTC)
+	id := uuid.New()
+
+	gotAt, gotID, err := decodeFeedCursor(encodeFeedCursor(at, id))
+	if err != nil {
+		t.Fatalf("decodeFeedCursor: %v", err)
+	}
+	if !gotAt.Equal(at) || gotID != id {
+		t.Errorf("round trip = %v %v, want %v %v", gotAt, gotID, at, id)
+	}
+
+	for _, cursor := range []string{"not base64!", "bm9jb2xvbg", "MTIzOm5vdC1hLXV1aWQ"} {
+		if _, _, err := decodeFeedCursor(cursor); err == nil {
+			t.Errorf("decodeFeedCursor(%q) accepted a malformed cursor", cursor)
+		}
+	}
+}
+
+func TestProfileAllows(t *testing.T) {
+	tests := []struct {
+		visibility string
+		friends    bool
+		want       bool
+	}{
+		{models.ProfilePublic, false, true},
+		{models.ProfileFriends, false, false},
+		{models.ProfileFriends, true, true},
+		{models.ProfilePrivate, true, false},
+		{"", false, true}, // Unset settings are public
+	}
+	for _, tt := range tests {
+		if got := profileAllows(tt.visibility, tt.friends); got != tt.want {
+			t.Errorf("profileAllows(%q, %v) = %v, want %v", tt.visibility, tt.friends, got, tt.want)
+		}
+	}
+}
+
+func TestProfileVisible(t *testing.T) {
+	tx := testDB(t)
+	svc := NewActivityService(tx)
+	ctx := context.Background()
+	privacy := func(visibility string) map[string]interface{} {
+		return map[string]interface{}{"profile_visibility": visibility}
+	}
+
+	viewer := createTestUser(t, tx, "viewer", privacy(models.ProfilePrivate))
+	public := createTestUser(t, tx, "public", nil) // Unset settings are public
+	friend := createTestUser(t, tx, "friend", privacy(models.ProfileFriends))
+	followed := createTestUser(t, tx, "followed", privacy(models.ProfileFriends))
+	follower := createTestUser(t, tx, "follower", privacy(models.ProfileFriends))
+	private := createTestUser(t, tx, "private", privacy(models.ProfilePrivate))
+	for _, follow := range [][2]uuid.UUID{{viewer, friend}, {friend, viewer}, {viewer, followed}, {follower, viewer}, {viewer, private}, {private, viewer}} {
+		if err := svc.Follow(ctx, follow[0], follow[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		user uuid.UUID
+		want bool
+	}{
+		{"own private profile", viewer, true},
+		{"public", public, true},
+		{"friends-only, following each other", friend, true},
+		{"friends-only, followed by the viewer only", followed, false},
+		{"friends-only, following the viewer only", follower, false},
+		{"private, following each other", private, false},
+	}
+	for _, tt := range tests {
+		got, err := profileVisible(tx, viewer, tt.user)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: visible = %v, want %v", tt.name, got, tt.want)
+		}
+		profile, err := svc.Profile(ctx, viewer, tt.user)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if profile.Restricted == tt.want {
+			t.Errorf("%s: restricted = %v", tt.name, profile.Restricted)
+		}
+	}
+}
+
+func TestFeedPrivacy(t *testing.T) {
+	tx := testDB(t)
+	svc := NewActivityService(tx)
+	ctx := context.Background()
+	sharing := map[string]interface{}{"share_reading_activity": true, "annotations_public": true}
+
+	viewer := createTestUser(t, tx, "viewer", nil)
+	reader := createTestUser(t, tx, "reader", sharing)
+	optedOut := createTestUser(t, tx, "opted-out", map[string]interface{}{"share_reading_activity": false, "annotations_public": false})
+	unset := createTestUser(t, tx, "unset", nil) // Activity is shared only when opted in
+	muted := createTestUser(t, tx, "muted", sharing)
+	private := createTestUser(t, tx, "private", map[string]interface{}{"profile_visibility": models.ProfilePrivate, "share_reading_activity": true})
+	friend := createTestUser(t, tx, "friend", map[string]interface{}{"profile_visibility": models.ProfileFriends, "share_reading_activity": true})
+	oneWay := createTestUser(t, tx, "one-way", map[string]interface{}{"profile_visibility": models.ProfileFriends, "share_reading_activity": true})
+	unshared := createTestUser(t, tx, "unshared", sharing)
+	for _, user := range []uuid.UUID{reader, optedOut, unset, muted, private, friend, oneWay, unshared} {
+		if err := svc.Follow(ctx, viewer, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := svc.Follow(ctx, friend, viewer); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Mute(ctx, viewer, muted); err != nil {
+		t.Fatal(err)
+	}
+
+	book := createTestBook(t, tx, reader)
+	record := func(user uuid.UUID, kind string, subject *uuid.UUID) {
+		t.Helper()
+		event := models.ActivityEvent{UserID: user, Type: kind, Key: kind + ":" + uuid.NewString(), BookID: &book, SubjectID: subject}
+		if err := RecordActivity(tx, &event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, user := range []uuid.UUID{reader, optedOut, unset, muted, private, friend, oneWay} {
+		record(user, models.ActivityStartedBook, nil)
+	}
+	highlight := createTestAnnotation(t, tx, reader, book, models.VisibilityPublic)
+	record(reader, models.ActivityPublicHighlight, &highlight.ID)
+	hidden := createTestAnnotation(t, tx, unshared, book, models.VisibilityPublic)
+	record(unshared, models.ActivityPublicHighlight, &hidden.ID)
+	if err := tx.Model(hidden).Update("visibility", models.VisibilityPrivate).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	feed, err := svc.Feed(ctx, viewer, "", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for _, item := range feed.Items {
+		got[item.Type+" "+item.UserID.String()] = true
+	}
+	want := map[string]bool{
+		models.ActivityStartedBook + " " + reader.String():     true,
+		models.ActivityPublicHighlight + " " + reader.String(): true,
+		models.ActivityStartedBook + " " + friend.String():     true,
+	}
+	if len(got) != len(want) || len(feed.Items) != len(want) {
+		t.Errorf("feed has %d items %v, want %v", len(feed.Items), got, want)
+	}
+	for key := range want {
+		if !got[key] {
+			t.Errorf("feed lacks %s", key)
+		}
+	}
+
+	// Unmuting shows the muted user's activity again
+	if err := svc.Unmute(ctx, viewer, muted); err != nil {
+		t.Fatal(err)
+	}
+	if feed, err = svc.Feed(ctx, viewer, "", 50); err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Items) != len(want)+1 {
+		t.Errorf("feed after unmuting has %d items, want %d", len(feed.Items), len(want)+1)
+	}
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Span is a contiguous region of text located by rune offsets
+type Span struct {
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	Marker string `json:"marker,omitempty"` // Chapter/verse/section marker found at the start, if any
+}
+
+// Length returns the number of runes covered by the span
+func (s Span) Length() int {
+	return s.End - s.Start
+}
+
+// Bead is one step of an alignment: SourceCount source units matched with
+// TargetCount target units, starting at the given unit indexes
+type Bead struct {
+	SourceIndex int     `json:"source_index"`
+	SourceCount int     `json:"source_count"`
+	TargetIndex int     `json:"target_index"`
+	TargetCount int     `json:"target_count"`
+	Cost        float64 `json:"cost"`
+}
+
+// Type returns the bead shape, e.g. "1-1" or "2-1"
+func (b Bead) Type() string {
+	return fmt.Sprintf("%d-%d", b.SourceCount, b.TargetCount)
+}
+
+// Gale-Church parameters: variance of the length ratio and prior
+// probabilities of each bead shape, from Gale & Church (1993)
+const galeChurchVariance = 6.8
+
+var galeChurchPriors = []struct {
+	source, target int
+	prob           float64
+}{
+	{1, 1, 0.89},
+	{1, 0, 0.0099 / 2},
+	{0, 1, 0.0099 / 2},
+	{2, 1, 0.089 / 2},
+	{1, 2, 0.089 / 2},
+	{2, 2, 0.011},
+}
+
+// GaleChurch aligns two sequences of unit lengths (in characters) using
+// the length-based dynamic programme of Gale & Church. ratio is the expected
+// number of target characters per source character; pass 0 to derive it
+// from the totals.
+func GaleChurch(source, target []int, ratio float64) []Bead {
+	n, m := len(source), len(target)
+	if n == 0 && m == 0 {
+		return nil
+	}
+
+	if ratio <= 0 {
+		ratio = 1
+		if sum(source) > 0 && sum(target) > 0 {
+			ratio = float64(sum(target)) / float64(sum(source))
+		}
+	}
+
+	inf := math.Inf(1)
+	cost := make([][]float64, n+1)
+	back := make([][]int, n+1)
+	for i := range cost {
+		cost[i] = make([]float64, m+1)
+		back[i] = make([]int, m+1)
+		for j := range cost[i] {
+			cost[i][j] = inf
+			back[i][j] = -1
+		}
+	}
+	cost[0][0] = 0
+
+	for i := 0; i <= n; i++ {
+		for j := 0; j <= m; j++ {
+			if i == 0 && j == 0 {
+				continue
+			}
+			for k, prior := range galeChurchPriors {
+				pi, pj := i-prior.source, j-prior.target
+				if pi < 0 || pj < 0 || math.IsInf(cost[pi][pj], 1) {
+					continue
+				}
+				l1 := sum(source[pi:i])
+				l2 := sum(target[pj:j])
+				c := cost[pi][pj] + beadCost(l1, l2, ratio, prior.prob)
+				if c < cost[i][j] {
+					cost[i][j] = c
+					back[i][j] = k
+				}
+			}
+		}
+	}
+
+	var beads []Bead
+	i, j := n, m
+	for i > 0 || j > 0 {
+		k := back[i][j]
+		if k < 0 {
+			break
+		}
+		prior := galeChurchPriors[k]
+		pi, pj := i-prior.source, j-prior.target
+		beads = append(beads, Bead{
+			SourceIndex: pi,
+			SourceCount: prior.source,
+			TargetIndex: pj,
+			TargetCount: prior.target,
+			Cost:        math.Round((cost[i][j]-cost[pi][pj])*1000) / 1000,
+		})
+		i, j = pi, pj
+	}
+
+	for left, right := 0, len(beads)-1; left < right; left, right = left+1, right-1 {
+		beads[left], beads[right] = beads[right], beads[left]
+	}
+	return beads
+}
+
+// beadCost is -log P(match type) - log P(delta | match)
+func beadCost(l1, l2 int, ratio, prior float64) float64 {
+	if l1 == 0 && l2 == 0 {
+		return -math.Log(prior)
+	}
+	mean := (float64(l1) + float64(l2)/ratio) / 2
+	delta := (float64(l2) - float64(l1)*ratio) / math.Sqrt(mean*galeChurchVariance)
+	// two-tailed probability of a deviation at least this large
+	pd := 2 * (1 - normalCDF(math.Abs(delta)))
+	if pd < 1e-300 {
+		pd = 1e-300
+	}
+	return -math.Log(prior) - math.Log(pd)
+}
+
+func normalCDF(x float64) float64 {
+	return 0.5 * (1 + math.Erf(x/math.Sqrt2))
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+var (
+	// Matches leading section markers such as "12.", "[12]", "(3)", "327a", "1094b" or "1.2.3"
+	leadingMarkerPattern = regexp.MustCompile(`^\s*[\[(]?((?:\d+\.)*\d+[a-e]?)[\])]?[.:]?\s`)
+	// Matches inline bracketed markers such as "[12]" or "[327a]"
+	inlineMarkerPattern = regexp.MustCompile(`\[((?:\d+\.)*\d+[a-e]?)\]`)
+)
+
+// SplitParagraphs splits text[start:end] (rune offsets) into paragraphs
+// separated by blank lines, recording any leading section marker
+func SplitParagraphs(text []rune, start, end int) []Span {
+	var spans []Span
+	paraStart := -1
+	newlines := 0
+
+	closeParagraph := func(at int) {
+		if paraStart < 0 {
+			return
+		}
+		trimmed := at
+		for trimmed > paraStart && unicode.IsSpace(text[trimmed-1]) {
+			trimmed--
+		}
+		span := Span{Start: paraStart, End: trimmed}
+		span.Marker = findMarker(string(text[paraStart:trimmed]))
+		spans = append(spans, span)
+		paraStart = -1
+	}
+
+	for i := start; i < end; i++ {
+		r := text[i]
+		if r == '\n' {
+			newlines++
+			if newlines >= 2 {
+				closeParagraph(i)
+			}
+			continue
+		}
+		if unicode.IsSpace(r) {
+			continue
+		}
+		newlines = 0
+		if paraStart < 0 {
+			paraStart = i
+		}
+	}
+	closeParagraph(end)
+
+	return spans
+}
+
+// SplitSentences splits text[start:end] into sentences. Greek uses ";" as a
+// question mark and "·" as a pause, so both end sentences in Greek texts.
+func SplitSentences(text []rune, start, end int, language string) []Span {
+	terminal := ".!?"
+	if language == LangGreek {
+		terminal = ".!?;\u037e\u00b7\u0387"
+	}
+
+	var spans []Span
+	sentStart := -1
+	for i := start; i < end; i++ {
+		r := text[i]
+		if sentStart < 0 {
+			if unicode.IsSpace(r) {
+				continue
+			}
+			sentStart = i
+		}
+		if !strings.ContainsRune(terminal, r) {
+			continue
+		}
+
+		// Absorb closing quotes and brackets after the terminal punctuation
+		j := i + 1
+		for j < end && strings.ContainsRune(`"'”’»)]`, text[j]) {
+			j++
+		}
+		if j < end && !unicode.IsSpace(text[j]) {
+			continue
+		}
+		if r == '.' && isAbbreviation(text, sentStart, i) {
+			continue
+		}
+
+		spans = append(spans, Span{Start: sentStart, End: j, Marker: findMarker(string(text[sentStart:j]))})
+		sentStart = -1
+		i = j - 1
+	}
+	if sentStart >= 0 {
+		trimmed := end
+		for trimmed > sentStart && unicode.IsSpace(text[trimmed-1]) {
+			trimmed--
+		}
+		if trimmed > sentStart {
+			spans = append(spans, Span{Start: sentStart, End: trimmed, Marker: findMarker(string(text[sentStart:trimmed]))})
+		}
+	}
+
+	return spans
+}
+
+// isAbbreviation reports whether the word ending at a period looks like an
+// abbreviation ("Mr.", "cf.", "i.e.") or a section number rather than a sentence end
+func isAbbreviation(text []rune, sentStart, period int) bool {
+	wordStart := period
+	for wordStart > sentStart && !unicode.IsSpace(text[wordStart-1]) {
+		wordStart--
+	}
+	word := strings.ToLower(string(text[wordStart:period]))
+	switch word {
+	case "mr", "mrs", "dr", "st", "cf", "e.g", "i.e", "vol", "ch", "bk", "ll", "l", "p", "pp", "viz", "etc", "no":
+		return true
+	}
+	if word != "" && strings.Trim(word, "0123456789") == "" {
+		// "12." at the start of a sentence is a section number
+		return wordStart == sentStart
+	}
+	return false
+}
+
+func findMarker(text string) string {
+	if m := leadingMarkerPattern.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	if m := inlineMarkerPattern.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// AnchorGroups partitions source and target units into groups delimited by
+// markers that occur on both sides in the same order. Each group is returned
+// as [sourceFrom, sourceTo, targetFrom, targetTo) unit index ranges. Without
+// shared markers a single group covering everything is returned.
+func AnchorGroups(source, target []Span) [][4]int {
+	targetByMarker := make(map[string]int)
+	for j, span := range target {
+		if span.Marker != "" {
+			if _, exists := targetByMarker[span.Marker]; !exists {
+				targetByMarker[span.Marker] = j
+			}
+		}
+	}
+
+	var groups [][4]int
+	lastS, lastT := 0, 0
+	for i, span := range source {
+		if span.Marker == "" || i == 0 {
+			continue
+		}
+		j, ok := targetByMarker[span.Marker]
+		if !ok || j <= lastT {
+			continue
+		}
+		groups = append(groups, [4]int{lastS, i, lastT, j})
+		lastS, lastT = i, j
+	}
+	groups = append(groups, [4]int{lastS, len(source), lastT, len(target)})
+
+	return groups
+}
+
+// spanLengths returns the length of each span in runes
+func spanLengths(spans []Span) []int {
+	lengths := make([]int, len(spans))
+	for i, span := range spans {
+		lengths[i] = span.Length()
+	}
+	return lengths
+}
@@ -0,0 +1,591 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/classius/server/internal/models"
+)
+
+// maxAlignmentCells bounds the size of a single Gale-Church table; larger
+// groups are cut into proportional chunks before alignment
+const maxAlignmentCells = 4000000
+
+// AlignmentService pairs original texts with translations and aligns them
+type AlignmentService struct {
+	db *gorm.DB
+}
+
+// NewAlignmentService creates a new alignment service
+func NewAlignmentService(db *gorm.DB) *AlignmentService {
+	return &AlignmentService{db: db}
+}
+
+// SegmentQuery filters the aligned segments returned for a pair
+type SegmentQuery struct {
+	Level   string `json:"level"`
+	Chapter *int   `json:"chapter,omitempty"`
+	Side    string `json:"side"` // original or translation; which side From/To refer to
+	From    int    `json:"from"`
+	To      int    `json:"to"` // 0 means no upper bound
+	Limit   int    `json:"limit"`
+	Offset  int    `json:"offset"`
+}
+
+// SegmentView is an aligned segment with the text of both sides
+type SegmentView struct {
+	models.AlignedSegment
+	SourceText string `json:"source_text"`
+	TargetText string `json:"target_text"`
+}
+
+// SegmentPage is a page of aligned segments for side-by-side reading
+type SegmentPage struct {
+	PairID   uuid.UUID     `json:"pair_id"`
+	Level    string        `json:"level"`
+	Total    int64         `json:"total"`
+	Segments []SegmentView `json:"segments"`
+}
+
+// CarriedAnnotation is the result of projecting an annotation onto the other
+// side of a pair
+type CarriedAnnotation struct {
+	Source     *models.Annotation      `json:"source"`
+	Annotation *models.Annotation      `json:"annotation"`
+	Segments   []models.AlignedSegment `json:"segments"`
+}
+
+// CreatePair links two of the user's books and starts aligning them
+func (s *AlignmentService) CreatePair(ctx context.Context, userID, originalID, translationID uuid.UUID) (*models.ParallelPair, error) {
+	if originalID == translationID {
+		return nil, fmt.Errorf("%w pair: original and translation must be different books", ErrInvalid)
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.Book{}).
+		Where("id IN ? AND user_id = ?", []uuid.UUID{originalID, translationID}, userID).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to verify books: %w", err)
+	}
+	if count != 2 {
+		return nil, fmt.Errorf("book %w", ErrNotFound)
+	}
+
+	var existing int64
+	if err := s.db.WithContext(ctx).Model(&models.ParallelPair{}).
+		Where("user_id = ? AND original_book_id = ? AND translation_book_id = ?", userID, originalID, translationID).
+		Count(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to check existing pairs: %w", err)
+	}
+	if existing > 0 {
+		return nil, fmt.Errorf("%w: pair already exists", ErrConflict)
+	}
+
+	pair := &models.ParallelPair{
+		UserID:            userID,
+		OriginalBookID:    originalID,
+		TranslationBookID: translationID,
+		Status:            models.ParallelPairPending,
+	}
+	if err := s.db.WithContext(ctx).Create(pair).Error; err != nil {
+		if isUniqueViolation(err) { // Created concurrently
+			return nil, fmt.Errorf("%w: pair already exists", ErrConflict)
+		}
+		return nil, fmt.Errorf("failed to create pair: %w", err)
+	}
+
+	go s.alignInBackground(pair.ID)
+
+	return pair, nil
+}
+
+// GetPair retrieves a pair owned by the user
+func (s *AlignmentService) GetPair(ctx context.Context, userID, pairID uuid.UUID) (*models.ParallelPair, error) {
+	var pair models.ParallelPair
+	if err := s.db.WithContext(ctx).
+		Preload("OriginalBook").
+		Preload("TranslationBook").
+		Where("id = ? AND user_id = ?", pairID, userID).
+		First(&pair).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("pair %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to retrieve pair: %w", err)
+	}
+	return &pair, nil
+}
+
+// ListPairs returns the user's pairs, optionally restricted to those involving a book
+func (s *AlignmentService) ListPairs(ctx context.Context, userID uuid.UUID, bookID *uuid.UUID) ([]models.ParallelPair, error) {
+	query := s.db.WithContext(ctx).
+		Preload("OriginalBook").
+		Preload("TranslationBook").
+		Where("user_id = ?", userID)
+	if bookID != nil {
+		query = query.Where("original_book_id = ? OR translation_book_id = ?", *bookID, *bookID)
+	}
+
+	var pairs []models.ParallelPair
+	if err := query.Order("created_at DESC").Find(&pairs).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve pairs: %w", err)
+	}
+	return pairs, nil
+}
+
+// DeletePair removes a pair and its alignment
+func (s *AlignmentService) DeletePair(ctx context.Context, userID, pairID uuid.UUID) error {
+	if _, err := s.GetPair(ctx, userID, pairID); err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("pair_id = ?", pairID).Delete(&models.AlignedSegment{}).Error; err != nil {
+			return fmt.Errorf("failed to delete segments: %w", err)
+		}
+		if err := tx.Unscoped().Delete(&models.ParallelPair{}, "id = ?", pairID).Error; err != nil {
+			return fmt.Errorf("failed to delete pair: %w", err)
+		}
+		return nil
+	})
+}
+
+// StartAlignment queues a (re)alignment of a pair
+func (s *AlignmentService) StartAlignment(ctx context.Context, userID, pairID uuid.UUID) (*models.ParallelPair, error) {
+	pair, err := s.GetPair(ctx, userID, pairID)
+	if err != nil {
+		return nil, err
+	}
+	if pair.Status == models.ParallelPairAligning {
+		return nil, fmt.Errorf("%w: alignment already running", ErrConflict)
+	}
+
+	pair.Status = models.ParallelPairPending
+	pair.Error = ""
+	if err := s.db.WithContext(ctx).Model(&models.ParallelPair{}).Where("id = ?", pairID).
+		Updates(map[string]interface{}{"status": pair.Status, "error": ""}).Error; err != nil {
+		return nil, fmt.Errorf("failed to update pair: %w", err)
+	}
+
+	go s.alignInBackground(pair.ID)
+
+	return pair, nil
+}
+
+// alignInBackground runs AlignPair and records failures on the pair
+func (s *AlignmentService) alignInBackground(pairID uuid.UUID) {
+	if err := s.AlignPair(context.Background(), pairID); err != nil {
+		fmt.Printf("Failed to align pair %s: %v\n", pairID, err)
+		if err := s.db.Model(&models.ParallelPair{}).Where("id = ?", pairID).
+			Updates(map[string]interface{}{"status": models.ParallelPairError, "error": err.Error()}).Error; err != nil {
+			fmt.Printf("Failed to record alignment error for pair %s: %v\n", pairID, err)
+		}
+	}
+}
+
+// AlignPair aligns chapters, then paragraphs, then sentences, and replaces
+// the stored segments for the pair
+func (s *AlignmentService) AlignPair(ctx context.Context, pairID uuid.UUID) error {
+	var pair models.ParallelPair
+	if err := s.db.WithContext(ctx).Preload("OriginalBook").Preload("TranslationBook").
+		Where("id = ?", pairID).First(&pair).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("pair %w", ErrNotFound)
+		}
+		return fmt.Errorf("failed to retrieve pair: %w", err)
+	}
+
+	if err := s.db.WithContext(ctx).Model(&models.ParallelPair{}).Where("id = ?", pairID).
+		Update("status", models.ParallelPairAligning).Error; err != nil {
+		return fmt.Errorf("failed to update pair status: %w", err)
+	}
+
+	source, err := s.loadText(ctx, pair.OriginalBookID)
+	if err != nil {
+		return err
+	}
+	target, err := s.loadText(ctx, pair.TranslationBookID)
+	if err != nil {
+		return err
+	}
+
+	// No more runes than LanguageSample keeps bytes need converting
+	sourceLang := DetectLanguage(LanguageSample(string(source[:min(len(source), maxLanguageSample)])), pair.OriginalBook.Language)
+	targetLang := DetectLanguage(LanguageSample(string(target[:min(len(target), maxLanguageSample)])), pair.TranslationBook.Language)
+
+	segments, ratio := AlignTexts(source, target, sourceLang, targetLang)
+	for i := range segments {
+		segments[i].ID = uuid.New()
+		segments[i].PairID = pairID
+	}
+
+	now := time.Now()
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("pair_id = ?", pairID).Delete(&models.AlignedSegment{}).Error; err != nil {
+			return fmt.Errorf("failed to clear old segments: %w", err)
+		}
+		if len(segments) > 0 {
+			if err := tx.CreateInBatches(segments, 500).Error; err != nil {
+				return fmt.Errorf("failed to store segments: %w", err)
+			}
+		}
+		return tx.Model(&models.ParallelPair{}).Where("id = ?", pairID).Updates(map[string]interface{}{
+			"status":        models.ParallelPairAligned,
+			"error":         "",
+			"length_ratio":  ratio,
+			"segment_count": len(segments),
+			"aligned_at":    &now,
+		}).Error
+	})
+}
+
+// GetSegments returns aligned segments of a pair with the text of both sides
+func (s *AlignmentService) GetSegments(ctx context.Context, userID, pairID uuid.UUID, query SegmentQuery) (*SegmentPage, error) {
+	pair, err := s.GetPair(ctx, userID, pairID)
+	if err != nil {
+		return nil, err
+	}
+	if pair.Status != models.ParallelPairAligned {
+		return nil, fmt.Errorf("%w: pair is not aligned yet (status %s)", ErrConflict, pair.Status)
+	}
+
+	level := query.Level
+	if level == "" {
+		level = models.AlignmentLevelSentence
+	}
+	if level != models.AlignmentLevelChapter && level != models.AlignmentLevelParagraph && level != models.AlignmentLevelSentence {
+		return nil, fmt.Errorf("%w level %q", ErrInvalid, level)
+	}
+
+	startCol, endCol := "source_start", "source_end"
+	if query.Side == "translation" {
+		startCol, endCol = "target_start", "target_end"
+	}
+
+	dbQuery := s.db.WithContext(ctx).Model(&models.AlignedSegment{}).
+		Where("pair_id = ? AND level = ?", pairID, level)
+	if query.Chapter != nil {
+		dbQuery = dbQuery.Where("chapter_index = ?", *query.Chapter)
+	}
+	if query.From > 0 {
+		dbQuery = dbQuery.Where(endCol+" > ?", query.From)
+	}
+	if query.To > 0 {
+		dbQuery = dbQuery.Where(startCol+" < ?", query.To)
+	}
+
+	var total int64
+	if err := dbQuery.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count segments: %w", err)
+	}
+
+	var segments []models.AlignedSegment
+	if err := dbQuery.Order("ordinal ASC").Limit(query.Limit).Offset(query.Offset).Find(&segments).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve segments: %w", err)
+	}
+
+	source, err := s.loadText(ctx, pair.OriginalBookID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.loadText(ctx, pair.TranslationBookID)
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]SegmentView, len(segments))
+	for i, seg := range segments {
+		views[i] = SegmentView{
+			AlignedSegment: seg,
+			SourceText:     runeSlice(source, seg.SourceStart, seg.SourceEnd),
+			TargetText:     runeSlice(target, seg.TargetStart, seg.TargetEnd),
+		}
+	}
+
+	return &SegmentPage{PairID: pairID, Level: level, Total: total, Segments: views}, nil
+}
+
+// CarryAnnotation copies an annotation on one side of a pair onto the
+// matching span of the other side, using sentence-level alignment where
+// available and paragraph-level alignment otherwise
+func (s *AlignmentService) CarryAnnotation(ctx context.Context, userID, pairID, annotationID uuid.UUID) (*CarriedAnnotation, error) {
+	pair, err := s.GetPair(ctx, userID, pairID)
+	if err != nil {
+		return nil, err
+	}
+	if pair.Status != models.ParallelPairAligned {
+		return nil, fmt.Errorf("%w: pair is not aligned yet (status %s)", ErrConflict, pair.Status)
+	}
+
+	var annotation models.Annotation
+	if err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", annotationID, userID).First(&annotation).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("annotation %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to retrieve annotation: %w", err)
+	}
+
+	var fromStart, fromEnd, toStart, toEnd string
+	var otherBookID uuid.UUID
+	switch annotation.BookID {
+	case pair.OriginalBookID:
+		fromStart, fromEnd, toStart, toEnd = "source_start", "source_end", "target_start", "target_end"
+		otherBookID = pair.TranslationBookID
+	case pair.TranslationBookID:
+		fromStart, fromEnd, toStart, toEnd = "target_start", "target_end", "source_start", "source_end"
+		otherBookID = pair.OriginalBookID
+	default:
+		return nil, fmt.Errorf("%w annotation: it does not belong to either book of the pair", ErrInvalid)
+	}
+
+	start, end := annotation.StartPosition, annotation.EndPosition
+	if end <= start {
+		end = start + 1
+	}
+
+	var segments []models.AlignedSegment
+	for _, level := range []string{models.AlignmentLevelSentence, models.AlignmentLevelParagraph} {
+		if err := s.db.WithContext(ctx).
+			Where("pair_id = ? AND level = ?", pairID, level).
+			Where(fromEnd+" > ? AND "+fromStart+" < ?", start, end).
+			Where(toEnd + " > " + toStart).
+			Order("ordinal ASC").
+			Find(&segments).Error; err != nil {
+			return nil, fmt.Errorf("failed to retrieve segments: %w", err)
+		}
+		if len(segments) > 0 {
+			break
+		}
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("aligned counterpart %w for annotation", ErrNotFound)
+	}
+
+	targetStart, targetEnd := -1, -1
+	for _, seg := range segments {
+		segStart, segEnd := seg.TargetStart, seg.TargetEnd
+		if otherBookID == pair.OriginalBookID {
+			segStart, segEnd = seg.SourceStart, seg.SourceEnd
+		}
+		if targetStart < 0 || segStart < targetStart {
+			targetStart = segStart
+		}
+		if segEnd > targetEnd {
+			targetEnd = segEnd
+		}
+	}
+
+	otherText, err := s.loadText(ctx, otherBookID)
+	if err != nil {
+		return nil, err
+	}
+
+	carried := &models.Annotation{
+		UserID:        userID,
+		BookID:        otherBookID,
+		Type:          annotation.Type,
+		StartPosition: targetStart,
+		EndPosition:   targetEnd,
+		SelectedText:  runeSlice(otherText, targetStart, targetEnd),
+		Content:       annotation.Content,
+		Color:         annotation.Color,
+		Tags:          annotation.Tags,
+		IsPrivate:     annotation.IsPrivate,
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(carried).Error; err != nil {
+			return fmt.Errorf("failed to create annotation: %w", err)
+		}
+		return RecordAnnotationChange(tx, userID, []uuid.UUID{carried.ID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	carried.Revision = 1
+
+	return &CarriedAnnotation{Source: &annotation, Annotation: carried, Segments: segments}, nil
+}
+
+// loadText returns a book's extracted text as runes
+func (s *AlignmentService) loadText(ctx context.Context, bookID uuid.UUID) ([]rune, error) {
+	var content models.BookContent
+	if err := s.db.WithContext(ctx).Where("book_id = ?", bookID).First(&content).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("book content %w", ErrNotExtracted)
+		}
+		return nil, fmt.Errorf("failed to retrieve book content: %w", err)
+	}
+	return []rune(content.FullText), nil
+}
+
+// AlignTexts aligns a source text with its translation at chapter, paragraph
+// and sentence level. It returns the segments (without IDs) and the global
+// length ratio used. Paragraphs and sentences are only aligned inside
+// chapter and paragraph beads that have text on both sides.
+func AlignTexts(source, target []rune, sourceLang, targetLang string) ([]models.AlignedSegment, float64) {
+	ratio := 1.0
+	if len(source) > 0 && len(target) > 0 {
+		ratio = float64(len(target)) / float64(len(source))
+	}
+
+	sourceChapters := chapterSpans(DetectChapters(string(source)))
+	targetChapters := chapterSpans(DetectChapters(string(target)))
+
+	var chapterBeads []Bead
+	if len(sourceChapters) == len(targetChapters) {
+		for i := range sourceChapters {
+			chapterBeads = append(chapterBeads, Bead{SourceIndex: i, SourceCount: 1, TargetIndex: i, TargetCount: 1})
+		}
+	} else {
+		chapterBeads = alignUnits(sourceChapters, targetChapters, ratio)
+	}
+
+	var segments []models.AlignedSegment
+	ordinals := map[string]int{}
+	add := func(level string, chapter int, bead Bead, src, tgt []Span, srcFallback, tgtFallback int) models.AlignedSegment {
+		ss, se, marker := unionSpan(src, bead.SourceIndex, bead.SourceCount, srcFallback)
+		ts, te, _ := unionSpan(tgt, bead.TargetIndex, bead.TargetCount, tgtFallback)
+		seg := models.AlignedSegment{
+			Level:        level,
+			ChapterIndex: chapter,
+			Ordinal:      ordinals[level],
+			SourceStart:  ss,
+			SourceEnd:    se,
+			TargetStart:  ts,
+			TargetEnd:    te,
+			BeadType:     bead.Type(),
+			Marker:       marker,
+			Cost:         bead.Cost,
+		}
+		ordinals[level]++
+		segments = append(segments, seg)
+		return seg
+	}
+
+	for chapter, chapterBead := range chapterBeads {
+		chSeg := add(models.AlignmentLevelChapter, chapter, chapterBead, sourceChapters, targetChapters, 0, 0)
+		if chSeg.SourceEnd <= chSeg.SourceStart || chSeg.TargetEnd <= chSeg.TargetStart {
+			continue
+		}
+
+		sourceParas := SplitParagraphs(source, chSeg.SourceStart, chSeg.SourceEnd)
+		targetParas := SplitParagraphs(target, chSeg.TargetStart, chSeg.TargetEnd)
+		for _, paraBead := range alignUnits(sourceParas, targetParas, ratio) {
+			pSeg := add(models.AlignmentLevelParagraph, chapter, paraBead, sourceParas, targetParas, chSeg.SourceStart, chSeg.TargetStart)
+			if pSeg.SourceEnd <= pSeg.SourceStart || pSeg.TargetEnd <= pSeg.TargetStart {
+				continue
+			}
+
+			sourceSents := SplitSentences(source, pSeg.SourceStart, pSeg.SourceEnd, sourceLang)
+			targetSents := SplitSentences(target, pSeg.TargetStart, pSeg.TargetEnd, targetLang)
+			for _, sentBead := range alignUnits(sourceSents, targetSents, ratio) {
+				add(models.AlignmentLevelSentence, chapter, sentBead, sourceSents, targetSents, pSeg.SourceStart, pSeg.TargetStart)
+			}
+		}
+	}
+
+	return segments, ratio
+}
+
+// alignUnits aligns two unit sequences, first anchoring on shared markers
+// and then running Gale-Church inside each anchored group. Bead indexes in
+// the result refer to the full sequences.
+func alignUnits(source, target []Span, ratio float64) []Bead {
+	var beads []Bead
+	for _, group := range AnchorGroups(source, target) {
+		for _, chunk := range chunkGroup(source, target, group) {
+			src := spanLengths(source[chunk[0]:chunk[1]])
+			tgt := spanLengths(target[chunk[2]:chunk[3]])
+			for _, bead := range GaleChurch(src, tgt, ratio) {
+				bead.SourceIndex += chunk[0]
+				bead.TargetIndex += chunk[2]
+				beads = append(beads, bead)
+			}
+		}
+	}
+	return beads
+}
+
+// chunkGroup splits a group whose alignment table would exceed
+// maxAlignmentCells into chunks cut at proportional character positions
+func chunkGroup(source, target []Span, group [4]int) [][4]int {
+	n, m := group[1]-group[0], group[3]-group[2]
+	if n*m <= maxAlignmentCells || n == 0 || m == 0 {
+		return [][4]int{group}
+	}
+
+	pieces := (n*m)/maxAlignmentCells + 1
+	sourceCuts := proportionalCuts(source[group[0]:group[1]], pieces)
+	targetCuts := proportionalCuts(target[group[2]:group[3]], pieces)
+
+	chunks := make([][4]int, 0, pieces)
+	for p := 0; p < pieces; p++ {
+		chunks = append(chunks, [4]int{
+			group[0] + sourceCuts[p], group[0] + sourceCuts[p+1],
+			group[2] + targetCuts[p], group[2] + targetCuts[p+1],
+		})
+	}
+	return chunks
+}
+
+// proportionalCuts returns pieces+1 unit indexes dividing spans into pieces
+// of roughly equal character length
+func proportionalCuts(spans []Span, pieces int) []int {
+	total := sum(spanLengths(spans))
+	cuts := make([]int, 0, pieces+1)
+	cuts = append(cuts, 0)
+	running, next := 0, 1
+	for i, span := range spans {
+		running += span.Length()
+		for next < pieces && running*pieces >= total*next {
+			cuts = append(cuts, i+1)
+			next++
+		}
+	}
+	for len(cuts) <= pieces {
+		cuts = append(cuts, len(spans))
+	}
+	cuts[pieces] = len(spans)
+	return cuts
+}
+
+// unionSpan returns the range covered by spans[index:index+count]. An empty
+// bead is placed at the start of the next unit (or fallback) so it still
+// has a position in the text.
+func unionSpan(spans []Span, index, count, fallback int) (int, int, string) {
+	if count == 0 {
+		pos := fallback
+		if index < len(spans) {
+			pos = spans[index].Start
+		} else if index > 0 && index-1 < len(spans) {
+			pos = spans[index-1].End
+		}
+		return pos, pos, ""
+	}
+	first, last := spans[index], spans[index+count-1]
+	return first.Start, last.End, first.Marker
+}
+
+func chapterSpans(chapters []Chapter) []Span {
+	spans := make([]Span, len(chapters))
+	for i, ch := range chapters {
+		spans[i] = Span{Start: ch.Start, End: ch.End}
+	}
+	return spans
+}
+
+func runeSlice(text []rune, start, end int) string {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(text) {
+		end = len(text)
+	}
+	if start >= end {
+		return ""
+	}
+	return string(text[start:end])
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestGaleChurchMergesSplitSentence(t *testing.T) {
+	// The third source sentence is rendered as two target sentences
+	source := []int{100, 40, 180, 60}
+	target := []int{110, 45, 95, 100, 65}
+
+	beads := GaleChurch(source, target, 0)
+	types := make([]string, len(beads))
+	for i, bead := range beads {
+		types[i] = bead.Type()
+	}
+
+	expected := []string{"1-1", "1-1", "1-2", "1-1"}
+	if len(types) != len(expected) {
+		t.Fatalf("Expected beads %v, got %v", expected, types)
+	}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Errorf("Bead %d: expected %s, got %s (all: %v)", i, expected[i], types[i], types)
+		}
+	}
+}
+
+func TestSplitSentences(t *testing.T) {
+	text := []rune("Mr. Smith read it. Then he left! τί ἐστιν; οὐδέν.")
+	spans := SplitSentences(text, 0, len(text), LangGreek)
+
+	expected := []string{"Mr. Smith read it.", "Then he left!", "τί ἐστιν;", "οὐδέν."}
+	if len(spans) != len(expected) {
+		t.Fatalf("Expected %d sentences, got %d: %+v", len(expected), len(spans), spans)
+	}
+	for i, span := range spans {
+		if got := string(text[span.Start:span.End]); got != expected[i] {
+			t.Errorf("Sentence %d: expected %q, got %q", i, expected[i], got)
+		}
+	}
+}
+
+func TestAnchorGroupsUsesSharedMarkers(t *testing.T) {
+	source := []Span{{Marker: "1"}, {}, {Marker: "2"}, {}, {Marker: "3"}}
+	target := []Span{{Marker: "1"}, {Marker: "2"}, {}, {}, {Marker: "3"}, {}}
+
+	groups := AnchorGroups(source, target)
+	expected := [][4]int{{0, 2, 0, 1}, {2, 4, 1, 4}, {4, 5, 4, 6}}
+	if len(groups) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, groups)
+	}
+	for i := range expected {
+		if groups[i] != expected[i] {
+			t.Errorf("Group %d: expected %v, got %v", i, expected[i], groups[i])
+		}
+	}
+}
+
+func TestAlignTextsLevels(t *testing.T) {
+	source := []rune("CHAPTER I\n\nArma virumque cano. Troiae qui primus ab oris venit.\n\nMusa, mihi causas memora.\n\nCHAPTER II\n\nConticuere omnes.")
+	target := []rune("CHAPTER I\n\nI sing of arms and the man. He came first from the shores of Troy.\n\nMuse, recall to me the causes.\n\nCHAPTER II\n\nAll fell silent.")
+
+	segments, ratio := AlignTexts(source, target, LangLatin, LangEnglish)
+	if ratio <= 1 {
+		t.Errorf("Expected the English translation to be longer, got ratio %f", ratio)
+	}
+
+	counts := map[string]int{}
+	for _, seg := range segments {
+		counts[seg.Level]++
+		if seg.SourceStart > seg.SourceEnd || seg.TargetStart > seg.TargetEnd {
+			t.Errorf("Segment has inverted offsets: %+v", seg)
+		}
+	}
+	if counts[models.AlignmentLevelChapter] != 2 {
+		t.Errorf("Expected 2 chapter segments, got %d", counts[models.AlignmentLevelChapter])
+	}
+
+	for _, seg := range segments {
+		if seg.Level == models.AlignmentLevelSentence && string(source[seg.SourceStart:seg.SourceEnd]) == "Arma virumque cano." {
+			if got := string(target[seg.TargetStart:seg.TargetEnd]); got != "I sing of arms and the man." {
+				t.Errorf("Expected first sentence to align with its translation, got %q", got)
+			}
+			return
+		}
+	}
+	t.Errorf("First source sentence was not aligned on its own: %+v", segments)
+}
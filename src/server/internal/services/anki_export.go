@@ -0,0 +1,265 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/classius/server/internal/models"
+)
+
+// Anki collection schema (version 11), as read by Anki's .apkg importer
+const ankiSchema = `
+CREATE TABLE col (id integer primary key, crt integer not null, mod integer not null, scm integer not null, ver integer not null, dty integer not null, usn integer not null, ls integer not null, conf text not null, models text not null, decks text not null, dconf text not null, tags text not null);
+CREATE TABLE notes (id integer primary key, guid text not null, mid integer not null, mod integer not null, usn integer not null, tags text not null, flds text not null, sfld integer not null, csum integer not null, flags integer not null, data text not null);
+CREATE TABLE cards (id integer primary key, nid integer not null, did integer not null, ord integer not null, mod integer not null, usn integer not null, type integer not null, queue integer not null, due integer not null, ivl integer not null, factor integer not null, reps integer not null, lapses integer not null, left integer not null, odue integer not null, odid integer not null, flags integer not null, data text not null);
+CREATE TABLE revlog (id integer primary key, cid integer not null, usn integer not null, ease integer not null, ivl integer not null, lastIvl integer not null, factor integer not null, time integer not null, type integer not null);
+CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null);
+CREATE INDEX ix_notes_usn on notes (usn);
+CREATE INDEX ix_cards_usn on cards (usn);
+CREATE INDEX ix_revlog_usn on revlog (usn);
+CREATE INDEX ix_cards_nid on cards (nid);
+CREATE INDEX ix_cards_sched on cards (did, queue, due);
+CREATE INDEX ix_revlog_cid on revlog (cid);
+CREATE INDEX ix_notes_csum on notes (csum);
+`
+
+// Fields of the exported note type, in order
+var ankiFields = []string{"Word", "Definition", "Context", "Lemma", "Source"}
+
+// WriteAnkiPackage writes vocabulary words as an Anki .apkg package: a zip
+// holding a SQLite collection with one note type, one deck named deckName
+// and one card per word carrying its current schedule
+func WriteAnkiPackage(ctx context.Context, w io.Writer, deckName string, words []models.VocabularyWord) error {
+	dir, err := os.MkdirTemp("", "classius-apkg-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	dbPath := filepath.Join(dir, "collection.anki2")
+	if err := writeAnkiCollection(ctx, dbPath, deckName, words); err != nil {
+		return err
+	}
+
+	collection, err := os.ReadFile(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to read collection: %w", err)
+	}
+
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"collection.anki2", collection},
+		{"media", []byte("{}")},
+	}
+	for _, file := range files {
+		entry, err := archive.Create(file.name)
+		if err != nil {
+			return fmt.Errorf("failed to write package: %w", err)
+		}
+		if _, err := entry.Write(file.data); err != nil {
+			return fmt.Errorf("failed to write package: %w", err)
+		}
+	}
+	return archive.Close()
+}
+
+func writeAnkiCollection(ctx context.Context, path, deckName string, words []models.VocabularyWord) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return fmt.Errorf("failed to create collection: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, ankiSchema); err != nil {
+		return fmt.Errorf("failed to create collection schema: %w", err)
+	}
+
+	now := time.Now()
+	y, m, d := now.Date()
+	created := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	modelID := now.UnixMilli()
+	deckID := modelID + 1
+
+	conf, _ := json.Marshal(map[string]interface{}{
+		"nextPos": len(words) + 1, "estTimes": true, "activeDecks": []int64{deckID}, "sortType": "noteFld",
+		"timeLim": 0, "sortBackwards": false, "addToCur": true, "curDeck": deckID, "newBury": true,
+		"newSpread": 0, "dueCounts": true, "curModel": strconv.FormatInt(modelID, 10), "collapseTime": 1200,
+	})
+	noteTypes, _ := json.Marshal(map[string]interface{}{strconv.FormatInt(modelID, 10): ankiNoteType(modelID, deckID, now)})
+	decks, _ := json.Marshal(map[string]interface{}{
+		"1":                           ankiDeck(1, "Default", now),
+		strconv.FormatInt(deckID, 10): ankiDeck(deckID, deckName, now),
+	})
+	dconf, _ := json.Marshal(map[string]interface{}{"1": ankiDeckConfig()})
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start collection transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO col VALUES (1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, '{}')`,
+		created.Unix(), now.UnixMilli(), now.UnixMilli(), string(conf), string(noteTypes), string(decks), string(dconf),
+	); err != nil {
+		return fmt.Errorf("failed to write collection header: %w", err)
+	}
+
+	for i, word := range words {
+		noteID := modelID + int64(i)*2 + 2
+		cardID := noteID + 1
+
+		source := ""
+		if word.Book != nil {
+			source = word.Book.Title
+			if word.Book.Author != "" {
+				source += " — " + word.Book.Author
+			}
+			if word.PageNumber > 0 {
+				source += fmt.Sprintf(", p. %d", word.PageNumber)
+			}
+		}
+		fields := []string{
+			html.EscapeString(word.Word),
+			strings.ReplaceAll(html.EscapeString(word.Definition), "\n", "<br>"),
+			html.EscapeString(word.Context),
+			html.EscapeString(word.Lemma),
+			html.EscapeString(source),
+		}
+		tags := []string{"classius", word.Language}
+		if word.PartOfSpeech != "" {
+			tags = append(tags, strings.ReplaceAll(word.PartOfSpeech, " ", "_"))
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO notes VALUES (?, ?, ?, ?, -1, ?, ?, ?, ?, 0, '')`,
+			noteID, ankiGUID(word.ID.String()), modelID, now.Unix(), " "+strings.Join(tags, " ")+" ",
+			strings.Join(fields, "\x1f"), word.Word, ankiChecksum(word.Word),
+		); err != nil {
+			return fmt.Errorf("failed to write note: %w", err)
+		}
+
+		cardType, queue, due := ankiCardSchedule(word, i, created)
+		factor := int(math.Round(word.EaseFactor * 1000))
+		if factor == 0 {
+			factor = 2500
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO cards VALUES (?, ?, ?, 0, ?, -1, ?, ?, ?, ?, ?, ?, ?, 0, 0, 0, 0, '')`,
+			cardID, noteID, deckID, now.Unix(), cardType, queue, due,
+			int(word.IntervalDays), factor, word.Repetitions, word.Lapses,
+		); err != nil {
+			return fmt.Errorf("failed to write card: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ankiCardSchedule maps a review schedule onto Anki's card type, queue and
+// due value. New cards are due by position, learning cards by timestamp and
+// review cards by day number relative to the collection's creation.
+func ankiCardSchedule(word models.VocabularyWord, position int, created time.Time) (int, int, int64) {
+	var cardType, queue int
+	var due int64
+	switch word.State {
+	case models.ReviewStateReview:
+		cardType, queue = 2, 2
+		due = int64(math.Floor(word.DueAt.Sub(created).Hours() / 24))
+	case models.ReviewStateLearning, models.ReviewStateRelearning:
+		cardType, queue = 1, 1
+		if word.State == models.ReviewStateRelearning {
+			cardType = 3
+		}
+		due = word.DueAt.Unix()
+	default:
+		cardType, queue = 0, 0
+		due = int64(position + 1)
+	}
+	if word.Suspended {
+		queue = -1
+	}
+	return cardType, queue, due
+}
+
+func ankiNoteType(modelID, deckID int64, now time.Time) map[string]interface{} {
+	fields := make([]map[string]interface{}, len(ankiFields))
+	for i, name := range ankiFields {
+		fields[i] = map[string]interface{}{
+			"name": name, "ord": i, "sticky": false, "rtl": false, "font": "Arial", "size": 20, "media": []string{},
+		}
+	}
+	return map[string]interface{}{
+		"id": modelID, "name": "Classius Vocabulary", "type": 0, "mod": now.Unix(), "usn": -1, "sortf": 0, "did": deckID,
+		"tmpls": []map[string]interface{}{{
+			"name": "Recognition", "ord": 0, "did": nil, "bqfmt": "", "bafmt": "",
+			"qfmt": `<div class="word">{{Word}}</div>`,
+			"afmt": `{{FrontSide}}<hr id="answer"><div class="definition">{{Definition}}</div>` +
+				`{{#Context}}<div class="context">{{Context}}</div>{{/Context}}` +
+				`{{#Source}}<div class="source">{{Source}}</div>{{/Source}}`,
+		}},
+		"flds":      fields,
+		"css":       ".card { font-family: Georgia, serif; font-size: 20px; text-align: center; }\n.word { font-size: 32px; }\n.context { font-style: italic; margin-top: 1em; }\n.source { font-size: 14px; color: #888; margin-top: 1em; }",
+		"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+		"latexPost": "\\end{document}",
+		"tags":      []string{},
+		"vers":      []string{},
+		"req":       []interface{}{[]interface{}{0, "any", []int{0}}},
+	}
+}
+
+func ankiDeck(id int64, name string, now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"id": id, "name": name, "mod": now.Unix(), "usn": -1, "desc": "", "dyn": 0, "conf": 1, "collapsed": false,
+		"lrnToday": []int{0, 0}, "revToday": []int{0, 0}, "newToday": []int{0, 0}, "timeToday": []int{0, 0},
+		"extendNew": 10, "extendRev": 50,
+	}
+}
+
+func ankiDeckConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60, "autoplay": true, "timer": 0, "replayq": true,
+		"new":   map[string]interface{}{"perDay": 20, "delays": []int{1, 10}, "separate": true, "ints": []int{1, 4, 7}, "initialFactor": 2500, "bury": false, "order": 1},
+		"lapse": map[string]interface{}{"leechFails": 8, "minInt": 1, "delays": []int{10}, "leechAction": 0, "mult": 0},
+		"rev":   map[string]interface{}{"perDay": 200, "fuzz": 0.05, "ivlFct": 1, "maxIvl": maxIntervalDays, "ease4": 1.3, "bury": false, "minSpace": 1},
+	}
+}
+
+// ankiGUID derives a stable note GUID from the word ID so re-imports update
+// existing notes instead of duplicating them
+func ankiGUID(id string) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!#$%&()*+,-./:;<=>?@[]^_`{|}~"
+	sum := sha1.Sum([]byte(id))
+	n := binary.BigEndian.Uint64(sum[:8])
+	var guid []byte
+	for n > 0 {
+		guid = append(guid, alphabet[n%uint64(len(alphabet))])
+		n /= uint64(len(alphabet))
+	}
+	return string(guid)
+}
+
+// ankiChecksum is the first 8 hex digits of the SHA-1 of the sort field,
+// which Anki uses for duplicate detection
+func ankiChecksum(field string) int64 {
+	sum := sha1.Sum([]byte(field))
+	return int64(binary.BigEndian.Uint32(sum[:4]))
+}
@@ -0,0 +1,634 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Decoders for thumbnails
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/classius/server/internal/models"
+)
+
+// Attachment limits
+const (
+	DefaultAttachmentMaxSize    = 25 << 20            // Bytes per file
+	DefaultAttachmentQuota      = 1 << 30             // Bytes per user
+	DefaultAttachmentRetention  = 30 * 24 * time.Hour // How long files of deleted annotations are kept
+	MaxAttachmentsPerAnnotation = 20
+	ThumbnailSize               = 320 // Longest side of thumbnails, in pixels
+
+	maxThumbnailPixels = 50_000_000 // Larger images get no thumbnail
+	thumbnailSamples   = 4          // Samples per axis averaged into each thumbnail pixel
+	exifScanSize       = 64 << 10   // Bytes of a JPEG searched for its orientation
+)
+
+// Extensions of audio recorded in containers that sniff as video
+var audioExtensions = map[string]bool{".m4a": true, ".weba": true, ".webm": true, ".aac": true}
+
+// AnnotationAttachmentService stores files attached to annotations, within
+// a per-user quota
+type AnnotationAttachmentService struct {
+	db        *gorm.DB
+	root      string
+	maxSize   int64
+	quota     int64
+	retention time.Duration
+}
+
+// NewAnnotationAttachmentService creates a new attachment service storing
+// files under root. Zero limits take their defaults.
+func NewAnnotationAttachmentService(db *gorm.DB, root string, maxSize, quota int64, retention time.Duration) *AnnotationAttachmentService {
+	if maxSize <= 0 {
+		maxSize = DefaultAttachmentMaxSize
+	}
+	if quota <= 0 {
+		quota = DefaultAttachmentQuota
+	}
+	if retention <= 0 {
+		retention = DefaultAttachmentRetention
+	}
+	return &AnnotationAttachmentService{db: db, root: root, maxSize: maxSize, quota: quota, retention: retention}
+}
+
+// MaxSize is the largest file the service accepts
+func (s *AnnotationAttachmentService) MaxSize() int64 {
+	return s.maxSize
+}
+
+// AttachmentUsage is a user's attachment storage
+type AttachmentUsage struct {
+	Used        int64 `json:"used"` // Bytes, including files of deleted annotations not yet purged
+	Quota       int64 `json:"quota"`
+	Count       int64 `json:"count"`
+	MaxFileSize int64 `json:"max_file_size"`
+}
+
+// Upload attaches a file to an annotation. The type is sniffed from the
+// content; declaredType only tells audio from video in shared containers.
+func (s *AnnotationAttachmentService) Upload(ctx context.Context, userID, annotationID uuid.UUID, filename, declaredType string, r io.Reader) (*models.AnnotationAttachment, error) {
+	filename = cleanAttachmentFilename(filename)
+	if filename == "" {
+		return nil, fmt.Errorf("%w: filename is required", ErrInvalid)
+	}
+	if err := checkAttachable(s.db.WithContext(ctx), userID, annotationID); err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	head = head[:n]
+	contentType, kind, err := SniffAttachment(head, filename, declaredType)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(s.root, userID.String())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+	attachment := &models.AnnotationAttachment{
+		BaseModel:    models.BaseModel{ID: uuid.New()},
+		UserID:       userID,
+		AnnotationID: annotationID,
+		Kind:         kind,
+		Filename:     filename,
+		ContentType:  contentType,
+	}
+	attachment.StoragePath = filepath.Join(dir, attachment.ID.String())
+
+	stored := false
+	defer func() {
+		if !stored {
+			removeAttachmentFiles(attachment)
+		}
+	}()
+	if err := s.writeFile(attachment, io.MultiReader(bytes.NewReader(head), r)); err != nil {
+		return nil, err
+	}
+	if kind == models.AttachmentImage {
+		writeThumbnail(attachment)
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Serialize the user's uploads, so concurrent ones cannot overrun the quota
+		if err := tx.Exec("SELECT id FROM users WHERE id = ? FOR UPDATE", userID).Error; err != nil {
+			return fmt.Errorf("failed to store attachment: %w", err)
+		}
+		if err := checkAttachable(tx, userID, annotationID); err != nil {
+			return err
+		}
+		used, err := attachmentBytes(tx, userID)
+		if err != nil {
+			return err
+		}
+		if used+attachment.Size > s.quota {
+			return fmt.Errorf("storage %w: %s of %s used, and the file is %s", ErrQuotaExceeded,
+				formatBytes(used), formatBytes(s.quota), formatBytes(attachment.Size))
+		}
+		if err := tx.Create(attachment).Error; err != nil {
+			return fmt.Errorf("failed to store attachment: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	stored = true
+	return attachment, nil
+}
+
+// writeFile stores an attachment's content, recording its size and hash
+func (s *AnnotationAttachmentService) writeFile(attachment *models.AnnotationAttachment, r io.Reader) error {
+	out, err := os.OpenFile(attachment.StoragePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to store attachment: %w", err)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hash), io.LimitReader(r, s.maxSize+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return fmt.Errorf("attachment is %w: the limit is %s", ErrTooLarge, formatBytes(s.maxSize))
+		}
+		return fmt.Errorf("failed to store attachment: %w", err)
+	}
+	if size > s.maxSize {
+		return fmt.Errorf("attachment is %w: the limit is %s", ErrTooLarge, formatBytes(s.maxSize))
+	}
+	attachment.Size = size
+	attachment.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
+
+// List returns an annotation's attachments, oldest first
+func (s *AnnotationAttachmentService) List(ctx context.Context, userID, annotationID uuid.UUID) ([]models.AnnotationAttachment, error) {
+	db := s.db.WithContext(ctx)
+	var count int64
+	if err := db.Model(&models.Annotation{}).Where("id = ? AND user_id = ?", annotationID, userID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve annotation: %w", err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("annotation %w", ErrNotFound)
+	}
+	attachments, err := AnnotationAttachments(db, []uuid.UUID{annotationID})
+	if err != nil {
+		return nil, err
+	}
+	if attachments[annotationID] == nil {
+		return []models.AnnotationAttachment{}, nil
+	}
+	return attachments[annotationID], nil
+}
+
+// Open opens an attachment's file, or its thumbnail. The caller closes it.
+func (s *AnnotationAttachmentService) Open(ctx context.Context, userID, attachmentID uuid.UUID, thumbnail bool) (*models.AnnotationAttachment, *os.File, error) {
+	attachment, err := s.get(ctx, userID, attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	path := attachment.StoragePath
+	if thumbnail {
+		if !attachment.HasThumbnail {
+			return nil, nil, fmt.Errorf("thumbnail %w", ErrNotFound)
+		}
+		path = attachment.ThumbnailPath()
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("attachment file %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open attachment: %w", err)
+	}
+	return attachment, f, nil
+}
+
+// Delete removes an attachment and its files
+func (s *AnnotationAttachmentService) Delete(ctx context.Context, userID, attachmentID uuid.UUID) error {
+	attachment, err := s.get(ctx, userID, attachmentID)
+	if err != nil {
+		return err
+	}
+	if err := s.db.WithContext(ctx).Unscoped().Delete(attachment).Error; err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	removeAttachmentFiles(attachment)
+	return nil
+}
+
+// Usage reports the user's attachment storage against their quota
+func (s *AnnotationAttachmentService) Usage(ctx context.Context, userID uuid.UUID) (*AttachmentUsage, error) {
+	db := s.db.WithContext(ctx)
+	used, err := attachmentBytes(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	usage := &AttachmentUsage{Used: used, Quota: s.quota, MaxFileSize: s.maxSize}
+	if err := db.Model(&models.AnnotationAttachment{}).Where("user_id = ?", userID).Count(&usage.Count).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve attachment usage: %w", err)
+	}
+	return usage, nil
+}
+
+// PurgeDeleted removes attachments whose annotation was deleted longer
+// than the retention period ago, returning how many were removed
+func (s *AnnotationAttachmentService) PurgeDeleted(ctx context.Context) (int, error) {
+	var purged []models.AnnotationAttachment
+	err := s.db.WithContext(ctx).Unscoped().Clauses(clause.Returning{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", time.Now().Add(-s.retention)).
+		Delete(&purged).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge attachments: %w", err)
+	}
+	for i := range purged {
+		removeAttachmentFiles(&purged[i])
+	}
+	return len(purged), nil
+}
+
+// PurgeEvery runs PurgeDeleted at each interval until ctx is done
+func (s *AnnotationAttachmentService) PurgeEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.PurgeDeleted(ctx); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		}
+	}
+}
+
+func (s *AnnotationAttachmentService) get(ctx context.Context, userID, attachmentID uuid.UUID) (*models.AnnotationAttachment, error) {
+	var attachment models.AnnotationAttachment
+	err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", attachmentID, userID).First(&attachment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("attachment %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve attachment: %w", err)
+	}
+	return &attachment, nil
+}
+
+// AnnotationAttachments loads the attachments of annotations, oldest first
+func AnnotationAttachments(db *gorm.DB, annotationIDs []uuid.UUID) (map[uuid.UUID][]models.AnnotationAttachment, error) {
+	byAnnotation := make(map[uuid.UUID][]models.AnnotationAttachment)
+	if len(annotationIDs) == 0 {
+		return byAnnotation, nil
+	}
+	var attachments []models.AnnotationAttachment
+	if err := db.Where("annotation_id IN ?", annotationIDs).Order("created_at, id").Find(&attachments).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve attachments: %w", err)
+	}
+	for _, a := range attachments {
+		byAnnotation[a.AnnotationID] = append(byAnnotation[a.AnnotationID], a)
+	}
+	return byAnnotation, nil
+}
+
+// cascadeAnnotationAttachments soft-deletes the attachments of deleted
+// annotations and brings back those of restored ones. Their files stay
+// until PurgeDeleted.
+func cascadeAnnotationAttachments(tx *gorm.DB, userID uuid.UUID, annotationIDs []uuid.UUID) error {
+	deleted := tx.Unscoped().Model(&models.Annotation{}).Select("id").
+		Where("id IN ? AND user_id = ? AND deleted_at IS NOT NULL", annotationIDs, userID)
+	if err := tx.Where("annotation_id IN (?)", deleted).Delete(&models.AnnotationAttachment{}).Error; err != nil {
+		return fmt.Errorf("failed to delete attachments: %w", err)
+	}
+	live := tx.Model(&models.Annotation{}).Select("id").
+		Where("id IN ? AND user_id = ?", annotationIDs, userID)
+	if err := tx.Unscoped().Model(&models.AnnotationAttachment{}).
+		Where("deleted_at IS NOT NULL AND annotation_id IN (?)", live).
+		Update("deleted_at", nil).Error; err != nil {
+		return fmt.Errorf("failed to restore attachments: %w", err)
+	}
+	return nil
+}
+
+// checkAttachable checks the annotation exists and has room for another
+// attachment
+func checkAttachable(db *gorm.DB, userID, annotationID uuid.UUID) error {
+	var count int64
+	if err := db.Model(&models.Annotation{}).Where("id = ? AND user_id = ?", annotationID, userID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to retrieve annotation: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("annotation %w", ErrNotFound)
+	}
+	if err := db.Model(&models.AnnotationAttachment{}).Where("annotation_id = ?", annotationID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to retrieve attachments: %w", err)
+	}
+	if count >= MaxAttachmentsPerAnnotation {
+		return fmt.Errorf("%w: annotation already has %d attachments", ErrConflict, MaxAttachmentsPerAnnotation)
+	}
+	return nil
+}
+
+// attachmentBytes totals the user's stored files, counting attachments
+// awaiting purge
+func attachmentBytes(db *gorm.DB, userID uuid.UUID) (int64, error) {
+	var used int64
+	if err := db.Unscoped().Model(&models.AnnotationAttachment{}).Where("user_id = ?", userID).
+		Select("COALESCE(SUM(size), 0)").Scan(&used).Error; err != nil {
+		return 0, fmt.Errorf("failed to retrieve attachment usage: %w", err)
+	}
+	return used, nil
+}
+
+// removeAttachmentFiles deletes an attachment's file and thumbnail from disk
+func removeAttachmentFiles(attachment *models.AnnotationAttachment) {
+	for _, path := range []string{attachment.StoragePath, attachment.ThumbnailPath()} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			// Log error but don't fail the operation
+			fmt.Printf("Warning: failed to delete file %s: %v\n", path, err)
+		}
+	}
+}
+
+// SniffAttachment identifies an attachment from its first 512 bytes,
+// returning its content type and kind. Only images, audio, PDFs and plain
+// text are accepted; in particular nothing a browser would run as HTML.
+func SniffAttachment(head []byte, filename, declaredType string) (string, string, error) {
+	if len(head) == 0 {
+		return "", "", fmt.Errorf("%w attachment: the file is empty", ErrInvalid)
+	}
+	detected := http.DetectContentType(head)
+	sniffed, _, _ := mime.ParseMediaType(detected)
+	declared, _, _ := mime.ParseMediaType(declaredType)
+
+	switch sniffed {
+	case "image/jpeg", "image/png", "image/gif", "image/webp", "image/bmp":
+		return sniffed, models.AttachmentImage, nil
+	case "audio/mpeg", "audio/wave", "audio/aiff", "audio/basic", "audio/midi":
+		return sniffed, models.AttachmentAudio, nil
+	case "application/ogg":
+		return "audio/ogg", models.AttachmentAudio, nil
+	case "video/webm", "video/mp4":
+		// Phones and browsers record voice memos in video containers
+		if strings.HasPrefix(declared, "audio/") || audioExtensions[strings.ToLower(filepath.Ext(filename))] {
+			return "audio/" + strings.TrimPrefix(sniffed, "video/"), models.AttachmentAudio, nil
+		}
+	case "application/pdf":
+		return sniffed, models.AttachmentFile, nil
+	case "text/plain":
+		return detected, models.AttachmentFile, nil
+	}
+	return "", "", fmt.Errorf("%w %s", ErrUnsupportedMedia, sniffed)
+}
+
+// cleanAttachmentFilename keeps the base name of an uploaded file, without
+// control characters
+func cleanAttachmentFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "." || name == "/" {
+		return ""
+	}
+	if runes := []rune(name); len(runes) > 255 {
+		ext := []rune(filepath.Ext(name))
+		if len(ext) > 16 {
+			ext = nil
+		}
+		name = string(runes[:255-len(ext)]) + string(ext)
+	}
+	return name
+}
+
+// writeThumbnail records an image's size and writes its thumbnail. Images
+// the standard decoders cannot read, or too large to decode, get none.
+func writeThumbnail(attachment *models.AnnotationAttachment) {
+	f, err := os.Open(attachment.StoragePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	config, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return
+	}
+	orientation := 1
+	if format == "jpeg" {
+		header := make([]byte, exifScanSize)
+		n, _ := f.ReadAt(header, 0)
+		orientation = jpegOrientation(header[:n])
+	}
+	attachment.Width, attachment.Height = config.Width, config.Height
+	if orientation >= 5 {
+		attachment.Width, attachment.Height = config.Height, config.Width
+	}
+	if config.Width*config.Height > maxThumbnailPixels {
+		return
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return
+	}
+	out, err := os.Create(attachment.ThumbnailPath())
+	if err != nil {
+		return
+	}
+	err = jpeg.Encode(out, orient(thumbnail(img, ThumbnailSize), orientation), &jpeg.Options{Quality: 80})
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(attachment.ThumbnailPath())
+		return
+	}
+	attachment.HasThumbnail = true
+}
+
+// thumbnail scales img to fit within size pixels square, averaging a grid
+// of samples for each pixel. Transparent images are drawn over white.
+func thumbnail(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w > size || h > size {
+		if w >= h {
+			tw, th = size, max(1, (h*size+w/2)/w)
+		} else {
+			tw, th = max(1, (w*size+h/2)/h), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := y*h/th, max((y+1)*h/th, y*h/th+1)
+		ny := min(y1-y0, thumbnailSamples)
+		for x := 0; x < tw; x++ {
+			x0, x1 := x*w/tw, max((x+1)*w/tw, x*w/tw+1)
+			nx := min(x1-x0, thumbnailSamples)
+
+			var r, g, bl uint32
+			for sy := 0; sy < ny; sy++ {
+				py := b.Min.Y + y0 + (2*sy+1)*(y1-y0)/(2*ny)
+				for sx := 0; sx < nx; sx++ {
+					px := b.Min.X + x0 + (2*sx+1)*(x1-x0)/(2*nx)
+					cr, cg, cb, ca := img.At(px, py).RGBA()
+					white := 0xffff - ca
+					r += (cr + white) >> 8
+					g += (cg + white) >> 8
+					bl += (cb + white) >> 8
+				}
+			}
+			n := uint32(nx * ny)
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: 0xff})
+		}
+	}
+	return dst
+}
+
+// orient turns an image as its EXIF orientation (1 to 8) says it should
+// be displayed
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // Mirrored
+				dx, dy = w-1-x, y
+			case 3: // Upside down
+				dx, dy = w-1-x, h-1-y
+			case 4: // Mirrored upside down
+				dx, dy = x, h-1-y
+			case 5: // Transposed
+				dx, dy = y, x
+			case 6: // Needs turning clockwise
+				dx, dy = h-1-y, x
+			case 7: // Transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // Needs turning anticlockwise
+				dx, dy = y, w-1-x
+			}
+			dst.SetRGBA(dx, dy, img.RGBAAt(x, y))
+		}
+	}
+	return dst
+}
+
+// jpegOrientation reads the EXIF orientation of a JPEG from its header,
+// or 1 if it has none
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xda || marker == 0xd9 { // Start of scan, end of image
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation finds the orientation tag in the first IFD of EXIF data
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// formatBytes formats a size in bytes for messages, e.g. "25 MB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	s := fmt.Sprintf("%.1f", float64(n)/float64(div))
+	return strings.TrimSuffix(s, ".0") + " " + string("KMGTPE"[exp]) + "B"
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestSniffAttachment(t *testing.T) {
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	m4a := append([]byte{0, 0, 0, 0x20}, []byte("ftypM4A \x00\x00\x00\x00M4A mp42isom\x00\x00\x00\x00")...)
+
+	tests := []struct {
+		name, filename, declared string
+		head                     []byte
+		contentType, kind        string
+	}{
+		{"png", "board.png", "", pngData.Bytes(), "image/png", models.AttachmentImage},
+		{"png named as text", "board.txt", "text/plain", pngData.Bytes(), "image/png", models.AttachmentImage},
+		{"mp3", "memo.mp3", "", []byte("ID3\x03\x00\x00\x00\x00\x00\x00"), "audio/mpeg", models.AttachmentAudio},
+		{"ogg", "memo.opus", "", []byte("OggS\x00\x02\x00\x00"), "audio/ogg", models.AttachmentAudio},
+		{"m4a by extension", "memo.m4a", "application/octet-stream", m4a, "audio/mp4", models.AttachmentAudio},
+		{"m4a by type", "memo", "audio/x-m4a", m4a, "audio/mp4", models.AttachmentAudio},
+		{"mp4 video", "lecture.mp4", "video/mp4", m4a, "", ""},
+		{"pdf", "handout.pdf", "", []byte("%PDF-1.7\n"), "application/pdf", models.AttachmentFile},
+		{"text", "notes.md", "", []byte("# Notes\n"), "text/plain; charset=utf-8", models.AttachmentFile},
+		{"html", "notes.txt", "text/plain", []byte("<html><script>alert(1)</script>"), "", ""},
+		{"svg", "drawing.svg", "image/svg+xml", []byte(`<?xml version="1.0"?><svg/>`), "", ""},
+		{"binary", "tool.exe", "", []byte("MZ\x90\x00\x03\x00\x00\x00"), "", ""},
+		{"empty", "empty.txt", "", nil, "", ""},
+	}
+	for _, tt := range tests {
+		contentType, kind, err := SniffAttachment(tt.head, tt.filename, tt.declared)
+		if tt.kind == "" {
+			if err == nil {
+				t.Errorf("%s: accepted as %s", tt.name, contentType)
+			}
+			continue
+		}
+		if err != nil || contentType != tt.contentType || kind != tt.kind {
+			t.Errorf("%s: got %q, %q, %v; want %q, %q", tt.name, contentType, kind, err, tt.contentType, tt.kind)
+		}
+	}
+}
+
+func TestCleanAttachmentFilename(t *testing.T) {
+	tests := map[string]string{
+		"whiteboard.jpg":                  "whiteboard.jpg",
+		`C:\Users\me\Desktop\a.pdf`:       "a.pdf",
+		"../../etc/passwd":                "passwd",
+		" memo\x00\n.m4a ":                "memo.m4a",
+		"/":                               "",
+		strings.Repeat("x", 300) + ".png": strings.Repeat("x", 251) + ".png",
+	}
+	for in, want := range tests {
+		if got := cleanAttachmentFilename(in); got != want {
+			t.Errorf("cleanAttachmentFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	// Red on the left, transparent on the right
+	src := image.NewNRGBA(image.Rect(0, 0, 1000, 500))
+	for y := 0; y < 500; y++ {
+		for x := 0; x < 500; x++ {
+			src.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	thumb := thumbnail(src, 320)
+	if b := thumb.Bounds(); b.Dx() != 320 || b.Dy() != 160 {
+		t.Fatalf("thumbnail is %v", b)
+	}
+	if got := thumb.RGBAAt(10, 10); got != (color.RGBA{R: 255, A: 255}) {
+		t.Errorf("opaque pixel %v", got)
+	}
+	if got := thumb.RGBAAt(310, 10); got != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("transparent pixel not drawn over white: %v", got)
+	}
+
+	small := thumbnail(image.NewRGBA(image.Rect(0, 0, 40, 30)), 320)
+	if b := small.Bounds(); b.Dx() != 40 || b.Dy() != 30 {
+		t.Errorf("small image scaled to %v", b)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// A 3×2 image with a marked top left corner
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.SetRGBA(0, 0, color.RGBA{R: 255, A: 255})
+	corners := map[int]image.Point{
+		1: {0, 0}, 2: {2, 0}, 3: {2, 1}, 4: {0, 1},
+		5: {0, 0}, 6: {1, 0}, 7: {1, 2}, 8: {0, 2},
+	}
+	for orientation, want := range corners {
+		out := orient(img, orientation)
+		w, h := 3, 2
+		if orientation >= 5 {
+			w, h = 2, 3
+		}
+		if b := out.Bounds(); b.Dx() != w || b.Dy() != h {
+			t.Errorf("orientation %d: size %v", orientation, b)
+			continue
+		}
+		if got := out.RGBAAt(want.X, want.Y); got.R != 255 {
+			t.Errorf("orientation %d: corner not at %v", orientation, want)
+		}
+	}
+}
+
+// exifJPEG is a JPEG of the given size whose EXIF says it needs rotating
+func exifJPEG(t *testing.T, width, height int, orientation uint16) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1) // One IFD entry
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	data := []byte{0xff, 0xd8, 0xff, 0xe1}
+	data = binary.BigEndian.AppendUint16(data, uint16(len(segment)+2))
+	data = append(data, segment...)
+	return append(data, encoded.Bytes()[2:]...)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	if got := jpegOrientation(exifJPEG(t, 4, 2, 6)); got != 6 {
+		t.Errorf("got orientation %d, want 6", got)
+	}
+	var plain bytes.Buffer
+	if err := jpeg.Encode(&plain, image.NewRGBA(image.Rect(0, 0, 4, 2)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := jpegOrientation(plain.Bytes()); got != 1 {
+		t.Errorf("JPEG without EXIF: got orientation %d", got)
+	}
+	if got := jpegOrientation([]byte{0xff, 0xd8, 0xff, 0xe1, 0xff, 0xff}); got != 1 {
+		t.Errorf("truncated segment: got orientation %d", got)
+	}
+}
+
+func TestWriteThumbnail(t *testing.T) {
+	dir := t.TempDir()
+	attachment := &models.AnnotationAttachment{StoragePath: filepath.Join(dir, "photo")}
+	if err := os.WriteFile(attachment.StoragePath, exifJPEG(t, 800, 400, 6), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeThumbnail(attachment)
+	if !attachment.HasThumbnail || attachment.Width != 400 || attachment.Height != 800 {
+		t.Fatalf("got %+v", attachment)
+	}
+	f, err := os.Open(attachment.ThumbnailPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	config, err := jpeg.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 160 || config.Height != 320 {
+		t.Errorf("thumbnail is %dx%d, want 160x320", config.Width, config.Height)
+	}
+}
+
+func TestAttachmentWriteFile(t *testing.T) {
+	s := NewAnnotationAttachmentService(nil, t.TempDir(), 10, 0, 0)
+	attachment := &models.AnnotationAttachment{StoragePath: filepath.Join(s.root, "a")}
+	if err := s.writeFile(attachment, strings.NewReader("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	if attachment.Size != 10 || attachment.SHA256 != "84d89877f0d4041efb6bf91a16f0248f2fd573e6af05c19f96bedb9f882f7882" {
+		t.Errorf("got size %d, hash %s", attachment.Size, attachment.SHA256)
+	}
+
+	attachment = &models.AnnotationAttachment{StoragePath: filepath.Join(s.root, "b")}
+	err := s.writeFile(attachment, strings.NewReader("0123456789a"))
+	if err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("oversized file: got %v", err)
+	}
+}
+
+func TestWriteAnnotationZipAttachments(t *testing.T) {
+	dir := t.TempDir()
+	stored := filepath.Join(dir, uuid.NewString())
+	if err := os.WriteFile(stored, []byte("%PDF-1.7\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	doc := exportTestDocument()
+	id := uuid.New()
+	doc.Annotations[0].Attachments = []ExportedAttachment{
+		{ID: id, Kind: models.AttachmentFile, Filename: "Lecture handout (1).pdf", storagePath: stored},
+		{ID: uuid.New(), Kind: models.AttachmentImage, Filename: "gone.png", storagePath: filepath.Join(dir, "missing")},
+	}
+
+	var buf bytes.Buffer
+	if err := writeAnnotationZip(&buf, []AnnotationDocument{doc}, markdownTemplate); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "attachments/" + id.String() + "/Lecture_handout__1_.pdf"
+	if len(zr.File) != 2 || zr.File[1].Name != path {
+		t.Fatalf("unexpected files %v", zr.File)
+	}
+	f, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := string(data)
+	if !strings.Contains(md, "[Lecture handout (1).pdf]("+path+")") {
+		t.Errorf("attachment not linked:\n%s", md)
+	}
+	if !strings.Contains(md, "Attachment: gone.png") {
+		t.Errorf("missing attachment not named:\n%s", md)
+	}
+}
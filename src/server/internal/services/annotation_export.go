@@ -0,0 +1,635 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/classius/server/internal/models"
+)
+
+// Annotation export formats rendered by AnnotationExportService
+const (
+	ExportFormatMarkdown = "markdown" // One Markdown file with YAML front matter, for Obsidian
+	ExportFormatLogseq   = "logseq"   // One Logseq page, annotations as blocks with id:: properties
+	ExportFormatReadwise = "readwise" // Readwise CSV import layout
+	ExportFormatZip      = "zip"      // A Markdown file per book
+
+	// MaxExportTemplateSize bounds user-defined export templates
+	MaxExportTemplateSize = 64 << 10
+	// MaxExportRenderSize bounds the output of a template for one document
+	MaxExportRenderSize = 16 << 20
+)
+
+// AnnotationExportService renders annotations as documents for note-taking
+// tools
+type AnnotationExportService struct {
+	db *gorm.DB
+}
+
+// NewAnnotationExportService creates a new annotation export service
+func NewAnnotationExportService(db *gorm.DB) *AnnotationExportService {
+	return &AnnotationExportService{db: db}
+}
+
+// IsAnnotationExportFormat reports whether the service renders format
+func IsAnnotationExportFormat(format string) bool {
+	switch format {
+	case ExportFormatMarkdown, "obsidian", ExportFormatLogseq, ExportFormatReadwise, ExportFormatZip:
+		return true
+	}
+	return false
+}
+
+// AnnotationExportOptions selects what is exported and how
+type AnnotationExportOptions struct {
+	Format   string
+	BookID   *uuid.UUID
+	Type     string
+	Template string // User-defined Markdown template; the built-in one if empty
+}
+
+// ExportFile is a rendered export
+type ExportFile struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// AnnotationDocument is the data export templates are executed with: one
+// book and its annotations
+type AnnotationDocument struct {
+	Book        ExportedBook         `json:"book"`
+	Annotations []ExportedAnnotation `json:"annotations"`
+	Tags        []string             `json:"tags"` // Every tag used in the book's annotations
+	ExportedAt  time.Time            `json:"exported_at"`
+}
+
+// ExportedBook describes the book of an AnnotationDocument
+type ExportedBook struct {
+	ID        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	Author    string    `json:"author"`
+	ISBN      string    `json:"isbn,omitempty"`
+	Publisher string    `json:"publisher,omitempty"`
+	Language  string    `json:"language,omitempty"`
+}
+
+// ExportedAnnotation is an annotation in an AnnotationDocument. Its ID
+// doubles as the block ID, so links to it survive re-exports.
+type ExportedAnnotation struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	Text      string    `json:"text,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	Color     string    `json:"color,omitempty"`
+	Page      int       `json:"page,omitempty"`
+	Start     int       `json:"start"`
+	End       int       `json:"end"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Geometry *models.AnnotationGeometry `json:"geometry,omitempty"`
+	Ink      *models.InkDrawing         `json:"ink,omitempty"`
+	Image    string                     `json:"image,omitempty"` // SVG data URI of the geometry and ink
+
+	Attachments []ExportedAttachment `json:"attachments,omitempty"`
+}
+
+// ExportedAttachment is a file attached to an ExportedAnnotation. Path is
+// set in zip exports, which bundle the file.
+type ExportedAttachment struct {
+	ID          uuid.UUID `json:"id"`
+	Kind        string    `json:"kind"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Path        string    `json:"path,omitempty"` // Relative to the Markdown files
+
+	storagePath string
+}
+
+// Export renders the user's annotations in a document format. Markdown
+// and Logseq exports cover one book; use the zip format for several.
+func (s *AnnotationExportService) Export(ctx context.Context, userID uuid.UUID, opts AnnotationExportOptions) (*ExportFile, error) {
+	if !IsAnnotationExportFormat(opts.Format) {
+		return nil, fmt.Errorf("%w export format %q", ErrInvalid, opts.Format)
+	}
+	markdown, err := ParseExportTemplate(opts.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	documents, err := s.Documents(ctx, userID, opts.BookID, opts.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts.Format {
+	case ExportFormatReadwise:
+		var buf bytes.Buffer
+		if err := WriteReadwiseCSV(&buf, documents); err != nil {
+			return nil, err
+		}
+		return &ExportFile{Filename: "readwise.csv", ContentType: "text/csv", Data: buf.Bytes()}, nil
+
+	case ExportFormatZip:
+		var buf bytes.Buffer
+		if err := writeAnnotationZip(&buf, documents, markdown); err != nil {
+			return nil, err
+		}
+		return &ExportFile{Filename: "annotations.zip", ContentType: "application/zip", Data: buf.Bytes()}, nil
+	}
+
+	if len(documents) == 0 {
+		return nil, fmt.Errorf("no annotations found to export")
+	}
+	if len(documents) > 1 {
+		return nil, fmt.Errorf("%w: book_id is required for %s exports; use the zip format to export every book", ErrInvalid, opts.Format)
+	}
+	tmpl := markdown
+	if opts.Format == ExportFormatLogseq {
+		tmpl = logseqTemplate
+	}
+	data, err := RenderAnnotationMarkdown(tmpl, documents[0])
+	if err != nil {
+		return nil, err
+	}
+	return &ExportFile{
+		Filename:    exportFilename(documents[0].Book) + ".md",
+		ContentType: "text/markdown; charset=utf-8",
+		Data:        data,
+	}, nil
+}
+
+// Documents loads the user's annotations grouped by book, books ordered by
+// title and annotations by position
+func (s *AnnotationExportService) Documents(ctx context.Context, userID uuid.UUID, bookID *uuid.UUID, annotationType string) ([]AnnotationDocument, error) {
+	db := s.db.WithContext(ctx)
+	if bookID != nil {
+		var count int64
+		if err := db.Model(&models.Book{}).Where("id = ? AND user_id = ?", *bookID, userID).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to retrieve book: %w", err)
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("book %w", ErrNotFound)
+		}
+	}
+
+	query := db.Where("user_id = ?", userID)
+	if bookID != nil {
+		query = query.Where("book_id = ?", *bookID)
+	}
+	if annotationType != "" {
+		query = query.Where("type = ?", annotationType)
+	}
+	var annotations []models.Annotation
+	if err := query.Order("start_position, page_number, created_at").Find(&annotations).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve annotations: %w", err)
+	}
+
+	bookIDs := make([]uuid.UUID, 0)
+	byBook := make(map[uuid.UUID][]models.Annotation)
+	for _, a := range annotations {
+		if _, ok := byBook[a.BookID]; !ok {
+			bookIDs = append(bookIDs, a.BookID)
+		}
+		byBook[a.BookID] = append(byBook[a.BookID], a)
+	}
+	if bookID != nil && len(bookIDs) == 0 {
+		bookIDs = append(bookIDs, *bookID)
+	}
+	if len(bookIDs) == 0 {
+		return []AnnotationDocument{}, nil
+	}
+
+	annotationIDs := make([]uuid.UUID, len(annotations))
+	for i, a := range annotations {
+		annotationIDs[i] = a.ID
+	}
+	attachments, err := AnnotationAttachments(db, annotationIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var books []models.Book
+	if err := db.Where("id IN ?", bookIDs).Order("title, id").Find(&books).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve books: %w", err)
+	}
+
+	now := time.Now().UTC()
+	documents := make([]AnnotationDocument, 0, len(books))
+	for _, book := range books {
+		doc := AnnotationDocument{
+			Book: ExportedBook{
+				ID:        book.ID,
+				Title:     book.Title,
+				Author:    book.Author,
+				ISBN:      book.ISBN,
+				Publisher: book.Publisher,
+				Language:  book.Language,
+			},
+			Annotations: []ExportedAnnotation{},
+			Tags:        []string{},
+			ExportedAt:  now,
+		}
+		for _, a := range byBook[book.ID] {
+			exported := toExportedAnnotation(&a)
+			for _, attachment := range attachments[a.ID] {
+				exported.Attachments = append(exported.Attachments, ExportedAttachment{
+					ID:          attachment.ID,
+					Kind:        attachment.Kind,
+					Filename:    attachment.Filename,
+					ContentType: attachment.ContentType,
+					Size:        attachment.Size,
+					storagePath: attachment.StoragePath,
+				})
+			}
+			doc.Annotations = append(doc.Annotations, exported)
+			for _, tag := range a.Tags {
+				if !containsString(doc.Tags, tag) {
+					doc.Tags = append(doc.Tags, tag)
+				}
+			}
+		}
+		sort.Strings(doc.Tags)
+		documents = append(documents, doc)
+	}
+	return documents, nil
+}
+
+func toExportedAnnotation(a *models.Annotation) ExportedAnnotation {
+	tags := a.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	exported := ExportedAnnotation{
+		ID:        a.ID,
+		Type:      a.Type,
+		Text:      a.SelectedText,
+		Note:      a.Content,
+		Color:     a.Color,
+		Page:      a.PageNumber,
+		Start:     a.StartPosition,
+		End:       a.EndPosition,
+		Tags:      tags,
+		CreatedAt: a.CreatedAt,
+		UpdatedAt: a.UpdatedAt,
+		Geometry:  a.Geometry,
+		Ink:       a.Ink,
+	}
+	if HasShape(a) {
+		exported.Image = SVGDataURI(RenderAnnotationSVG(a))
+	}
+	return exported
+}
+
+// Functions available to export templates
+var exportTemplateFuncs = template.FuncMap{
+	// quote prefixes every line with "> "
+	"quote": func(s string) string {
+		return "> " + strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n> ")
+	},
+	// indent indents every line but the first by n spaces
+	"indent": func(n int, s string) string {
+		return strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n"+strings.Repeat(" ", n))
+	},
+	// yaml quotes a string for YAML
+	"yaml":    yamlString,
+	"hashtag": hashtag,
+	"join":    strings.Join,
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"title": func(s string) string {
+		if s == "" {
+			return s
+		}
+		r, size := utf8.DecodeRuneInString(s)
+		return string(unicode.ToUpper(r)) + s[size:]
+	},
+	// date formats a time, as 2006-01-02 unless a layout is given
+	"date": func(t time.Time, layout ...string) string {
+		if len(layout) > 0 {
+			return t.Format(layout[0])
+		}
+		return t.Format("2006-01-02")
+	},
+}
+
+const defaultMarkdownTemplate = `---
+title: {{yaml .Book.Title}}
+author: {{yaml .Book.Author}}
+{{- with .Book.ISBN}}
+isbn: {{yaml .}}
+{{- end}}
+classius_book_id: {{.Book.ID}}
+annotations: {{len .Annotations}}
+tags: [{{range $i, $tag := .Tags}}{{if $i}}, {{end}}{{yaml $tag}}{{end}}]
+exported: {{date .ExportedAt "2006-01-02T15:04:05Z07:00"}}
+---
+
+# {{.Book.Title}}
+{{- with .Book.Author}}
+
+*{{.}}*
+{{- end}}
+{{range .Annotations}}
+{{if .Text}}{{quote .Text}} ^{{.ID}}{{else}}**{{title .Type}}**{{with .Page}} (page {{.}}){{end}} ^{{.ID}}{{end}}
+{{- with .Image}}
+
+![Drawing]({{.}})
+{{- end}}
+{{- with .Note}}
+
+{{.}}
+{{- end}}
+{{- range .Attachments}}
+
+{{if .Path}}{{if eq .Kind "image"}}!{{end}}[{{.Filename}}]({{.Path}}){{else}}Attachment: {{.Filename}}{{end}}
+{{- end}}
+{{- if and .Text (or .Page .Tags)}}
+
+{{with .Page}}Page {{.}}{{end}}{{if and .Page .Tags}} · {{end}}{{range $i, $tag := .Tags}}{{if $i}} {{end}}{{hashtag $tag}}{{end}}
+{{- end}}
+{{end}}`
+
+const defaultLogseqTemplate = `title:: {{.Book.Title}}
+{{- with .Book.Author}}
+author:: {{.}}
+{{- end}}
+{{- with .Book.ISBN}}
+isbn:: {{.}}
+{{- end}}
+classius-book-id:: {{.Book.ID}}
+{{- with .Tags}}
+tags:: {{range $i, $tag := .}}{{if $i}}, {{end}}[[{{$tag}}]]{{end}}
+{{- end}}
+{{range .Annotations}}
+- {{if .Text}}{{indent 2 (quote .Text)}}{{else}}{{title .Type}}{{end}}
+  id:: {{.ID}}
+{{- with .Page}}
+  page:: {{.}}
+{{- end}}
+{{- with .Tags}}
+  tags:: {{range $i, $tag := .}}{{if $i}}, {{end}}[[{{$tag}}]]{{end}}
+{{- end}}
+{{- with .Image}}
+  - ![Drawing]({{.}})
+{{- end}}
+{{- with .Note}}
+  - {{indent 4 .}}
+{{- end}}
+{{- range .Attachments}}
+  - Attachment: {{.Filename}}
+{{- end}}
+{{- end}}
+`
+
+var (
+	markdownTemplate = template.Must(template.New("markdown").Funcs(exportTemplateFuncs).Parse(defaultMarkdownTemplate))
+	logseqTemplate   = template.Must(template.New("logseq").Funcs(exportTemplateFuncs).Parse(defaultLogseqTemplate))
+)
+
+// ParseExportTemplate parses a user-defined Markdown export template,
+// returning the built-in template if source is empty. Templates are
+// executed with an AnnotationDocument.
+func ParseExportTemplate(source string) (*template.Template, error) {
+	if strings.TrimSpace(source) == "" {
+		return markdownTemplate, nil
+	}
+	if len(source) > MaxExportTemplateSize {
+		return nil, fmt.Errorf("%w export template: larger than %d bytes", ErrInvalid, MaxExportTemplateSize)
+	}
+	tmpl, err := template.New("custom").Funcs(exportTemplateFuncs).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("%w export template: %w", ErrInvalid, err)
+	}
+	return tmpl, nil
+}
+
+// RenderAnnotationMarkdown executes a Markdown template for one document
+func RenderAnnotationMarkdown(tmpl *template.Template, doc AnnotationDocument) ([]byte, error) {
+	var buf bytes.Buffer
+	w := &renderLimitWriter{buf: &buf, limit: MaxExportRenderSize}
+	if err := tmpl.Execute(w, doc); err != nil {
+		if w.exceeded {
+			return nil, fmt.Errorf("%w export template: output larger than %d bytes", ErrInvalid, MaxExportRenderSize)
+		}
+		return nil, fmt.Errorf("%w export template: %w", ErrInvalid, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// renderLimitWriter fails writes past limit, stopping a template that
+// expands without bound
+type renderLimitWriter struct {
+	buf      *bytes.Buffer
+	limit    int
+	exceeded bool
+}
+
+func (w *renderLimitWriter) Write(p []byte) (int, error) {
+	if w.buf.Len()+len(p) > w.limit {
+		w.exceeded = true
+		return 0, io.ErrShortWrite
+	}
+	return w.buf.Write(p)
+}
+
+// WriteReadwiseCSV writes highlights in Readwise's CSV import layout.
+// Tags are appended to the note as ".tag", which Readwise reads as tags;
+// annotations without highlighted text (bookmarks) are left out.
+func WriteReadwiseCSV(w io.Writer, documents []AnnotationDocument) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"Highlight", "Title", "Author", "URL", "Note", "Location", "Location Type", "Date"}); err != nil {
+		return err
+	}
+	for _, doc := range documents {
+		for _, a := range doc.Annotations {
+			if strings.TrimSpace(a.Text) == "" {
+				continue
+			}
+			note := a.Note
+			for _, tag := range a.Tags {
+				note = strings.TrimSpace(note + " ." + strings.Join(strings.Fields(tag), "-"))
+			}
+			location, locationType := strconv.Itoa(a.Start), "order"
+			if a.Page > 0 {
+				location, locationType = strconv.Itoa(a.Page), "page"
+			}
+			if err := writer.Write([]string{
+				a.Text,
+				doc.Book.Title,
+				doc.Book.Author,
+				"",
+				note,
+				location,
+				locationType,
+				a.CreatedAt.UTC().Format("2006-01-02 15:04:05"),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeAnnotationZip writes a Markdown file per book, and attachments
+// under attachments/<attachment ID>/ for the Markdown to link to
+func writeAnnotationZip(w *bytes.Buffer, documents []AnnotationDocument, tmpl *template.Template) error {
+	zw := zip.NewWriter(w)
+	var bundled []*ExportedAttachment
+	for _, doc := range documents {
+		for i := range doc.Annotations {
+			for j := range doc.Annotations[i].Attachments {
+				attachment := &doc.Annotations[i].Attachments[j]
+				if _, err := os.Stat(attachment.storagePath); err != nil {
+					continue
+				}
+				attachment.Path = "attachments/" + attachment.ID.String() + "/" + zipSafeName(attachment.Filename)
+				bundled = append(bundled, attachment)
+			}
+		}
+	}
+
+	used := make(map[string]int)
+	for _, doc := range documents {
+		data, err := RenderAnnotationMarkdown(tmpl, doc)
+		if err != nil {
+			return err
+		}
+		name := exportFilename(doc.Book)
+		if n := used[strings.ToLower(name)]; n > 0 {
+			used[strings.ToLower(name)]++
+			name = fmt.Sprintf("%s (%d)", name, n+1)
+		} else {
+			used[strings.ToLower(name)] = 1
+		}
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name + ".md", Method: zip.Deflate, Modified: doc.ExportedAt})
+		if err != nil {
+			return fmt.Errorf("failed to write export: %w", err)
+		}
+		if _, err := f.Write(data); err != nil {
+			return fmt.Errorf("failed to write export: %w", err)
+		}
+	}
+	for _, attachment := range bundled {
+		if err := writeZipAttachment(zw, attachment, documents[0].ExportedAt); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	return nil
+}
+
+// writeZipAttachment copies an attachment's file into the zip. Images and
+// audio are already compressed, so they are stored as they are.
+func writeZipAttachment(zw *zip.Writer, attachment *ExportedAttachment, modified time.Time) error {
+	src, err := os.Open(attachment.storagePath)
+	if err != nil {
+		return fmt.Errorf("failed to read attachment: %w", err)
+	}
+	defer src.Close()
+
+	method := zip.Deflate
+	if attachment.Kind != models.AttachmentFile {
+		method = zip.Store
+	}
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: attachment.Path, Method: method, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	return nil
+}
+
+// zipSafeName reduces a filename to characters that need no escaping in
+// Markdown links or zip paths
+func zipSafeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x80 && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("._-", r)) {
+			return r
+		}
+		return '_'
+	}, name)
+	name = strings.Trim(name, ".")
+	if name == "" {
+		return "attachment"
+	}
+	return name
+}
+
+// exportFilename names a book's export file "Title - Author", without
+// characters file systems reject
+func exportFilename(book ExportedBook) string {
+	name := book.Title
+	if book.Author != "" {
+		name += " - " + book.Author
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return ' '
+		}
+		return r
+	}, name)
+	name = strings.Join(strings.Fields(name), " ")
+	if runes := []rune(name); len(runes) > 120 {
+		name = strings.TrimSpace(string(runes[:120]))
+	}
+	name = strings.Trim(name, ". ")
+	if name == "" {
+		name = book.ID.String()
+	}
+	return name
+}
+
+// yamlString quotes s as a YAML double-quoted scalar
+func yamlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if unicode.IsControl(r) {
+				fmt.Fprintf(&b, `\u%04x`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// hashtag formats a tag as an Obsidian #tag, which cannot contain spaces
+func hashtag(tag string) string {
+	return "#" + strings.Join(strings.Fields(tag), "-")
+}
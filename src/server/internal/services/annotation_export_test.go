@@ -0,0 +1,135 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func exportTestDocument() AnnotationDocument {
+	return AnnotationDocument{
+		Book:       ExportedBook{ID: uuid.New(), Title: `Meditations: "Ta eis heauton"`, Author: "Marcus Aurelius"},
+		Tags:       []string{"stoicism", "virtue ethics"},
+		ExportedAt: time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC),
+		Annotations: []ExportedAnnotation{
+			{ID: uuid.New(), Type: "highlight", Text: "You have power over your mind\nnot outside events.", Page: 12, Tags: []string{"virtue ethics"}},
+			{ID: uuid.New(), Type: "note", Text: "Waste no more time", Note: "Compare Epictetus", Start: 400},
+			{ID: uuid.New(), Type: "bookmark", Page: 55},
+		},
+	}
+}
+
+func TestRenderAnnotationMarkdown(t *testing.T) {
+	doc := exportTestDocument()
+	tmpl, err := ParseExportTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := RenderAnnotationMarkdown(tmpl, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+
+	for _, want := range []string{
+		"---\ntitle: \"Meditations: \\\"Ta eis heauton\\\"\"\nauthor: \"Marcus Aurelius\"\n",
+		`tags: ["stoicism", "virtue ethics"]`,
+		"> You have power over your mind\n> not outside events. ^" + doc.Annotations[0].ID.String(),
+		"Page 12 · #virtue-ethics",
+		"> Waste no more time ^" + doc.Annotations[1].ID.String() + "\n\nCompare Epictetus",
+		"**Bookmark** (page 55) ^" + doc.Annotations[2].ID.String(),
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("markdown missing %q:\n%s", want, out)
+		}
+	}
+
+	data, err = RenderAnnotationMarkdown(logseqTemplate, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "- > Waste no more time\n  id:: " + doc.Annotations[1].ID.String() + "\n  - Compare Epictetus"; !strings.Contains(string(data), want) {
+		t.Errorf("logseq page missing %q:\n%s", want, data)
+	}
+}
+
+func TestParseExportTemplate(t *testing.T) {
+	tmpl, err := ParseExportTemplate(`# {{.Book.Title}}{{range .Annotations}}
+- {{.Text}} ({{date .CreatedAt}}){{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := RenderAnnotationMarkdown(tmpl, exportTestDocument())
+	if err != nil || !strings.HasPrefix(string(data), `# Meditations: "Ta eis heauton"`) {
+		t.Errorf("unexpected output %q (%v)", data, err)
+	}
+
+	if _, err := ParseExportTemplate("{{.Book.Title"); err == nil {
+		t.Error("expected a parse error")
+	}
+	tmpl, _ = ParseExportTemplate("{{.Book.Missing}}")
+	if _, err := RenderAnnotationMarkdown(tmpl, exportTestDocument()); err == nil {
+		t.Error("expected an execution error")
+	}
+
+	tmpl, _ = ParseExportTemplate(`{{range .Annotations}}{{title .Note}}|{{end}}`)
+	if data, err := RenderAnnotationMarkdown(tmpl, exportTestDocument()); err != nil || strings.Contains(string(data), "\uFFFD") {
+		t.Errorf("title of an empty note rendered %q (%v)", data, err)
+	}
+
+	// Nested ranges multiply the output; it is cut off rather than buffered
+	tmpl, _ = ParseExportTemplate(`{{range .Tags}}{{range $.Tags}}{{range $.Tags}}{{range $.Tags}}{{range $.Tags}}` +
+		strings.Repeat("x", 1024) + `{{end}}{{end}}{{end}}{{end}}{{end}}`)
+	doc := exportTestDocument()
+	doc.Tags = make([]string, 32)
+	if _, err := RenderAnnotationMarkdown(tmpl, doc); !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), "output larger") {
+		t.Errorf("expected the rendered size to be capped, got %v", err)
+	}
+}
+
+func TestWriteReadwiseCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteReadwiseCSV(&buf, []AnnotationDocument{exportTestDocument()}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected a header and 2 highlights, got %d rows", len(rows))
+	}
+	if got := rows[1]; got[4] != ".virtue-ethics" || got[5] != "12" || got[6] != "page" {
+		t.Errorf("unexpected row %q", got)
+	}
+	if got := rows[2]; got[4] != "Compare Epictetus" || got[5] != "400" || got[6] != "order" {
+		t.Errorf("unexpected row %q", got)
+	}
+}
+
+func TestWriteAnnotationZip(t *testing.T) {
+	first, second := exportTestDocument(), exportTestDocument()
+	second.Book.ID = uuid.New()
+	var buf bytes.Buffer
+	if err := writeAnnotationZip(&buf, []AnnotationDocument{first, second}, markdownTemplate); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	want := []string{"Meditations Ta eis heauton - Marcus Aurelius.md", "Meditations Ta eis heauton - Marcus Aurelius (2).md"}
+	if strings.Join(names, "|") != strings.Join(want, "|") {
+		t.Errorf("unexpected files %q", names)
+	}
+}
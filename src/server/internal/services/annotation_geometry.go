@@ -0,0 +1,248 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/classius/server/internal/models"
+)
+
+// Limits on annotation geometry
+const (
+	MaxGeometryShapes = 200        // Rects and quads per annotation
+	MaxInkStrokes     = 1000       // Strokes per drawing
+	MaxInkPoints      = 50000      // Points per drawing
+	MaxInkSize        = 256 * 1024 // Bytes of encoded ink
+	maxInkWidth       = 0.2        // Of the page width
+)
+
+const (
+	svgWidth            = 1000   // SVG user units across the page
+	defaultPageAspect   = 1.4142 // Height over width of A-series paper, when the drawing has no page size
+	defaultAreaColor    = "#ffd54f"
+	inkPressureLevels   = 8
+	minInkPressureScale = 0.25 // Width of the lightest pressure, as a fraction of the stroke width
+)
+
+var svgColorRe = regexp.MustCompile(`^(#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6}|[a-zA-Z]{1,20})$`)
+
+// ValidateAnnotationShape checks an annotation's geometry and ink. Area
+// annotations need geometry and ink annotations need strokes.
+func ValidateAnnotationShape(annotationType string, geometry *models.AnnotationGeometry, ink *models.InkDrawing) error {
+	if geometry != nil {
+		if err := validateGeometry(geometry); err != nil {
+			return fmt.Errorf("%w geometry: %w", ErrInvalid, err)
+		}
+	}
+	if ink != nil {
+		if err := validateInk(ink); err != nil {
+			return fmt.Errorf("%w ink: %w", ErrInvalid, err)
+		}
+	}
+	switch annotationType {
+	case models.AnnotationArea:
+		if geometry == nil || len(geometry.Rects)+len(geometry.Quads) == 0 {
+			return fmt.Errorf("%w: geometry with at least one rect or quad is required for area annotations", ErrInvalid)
+		}
+	case models.AnnotationInk:
+		if ink == nil || len(ink.Strokes) == 0 {
+			return fmt.Errorf("%w: ink with at least one stroke is required for ink annotations", ErrInvalid)
+		}
+	}
+	return nil
+}
+
+func validateGeometry(g *models.AnnotationGeometry) error {
+	if len(g.Rects)+len(g.Quads) > MaxGeometryShapes {
+		return fmt.Errorf("more than %d shapes", MaxGeometryShapes)
+	}
+	for i, r := range g.Rects {
+		if !inPage(r.X) || !inPage(r.Y) || !(r.Width > 0) || !(r.Height > 0) || !inPage(r.X+r.Width) || !inPage(r.Y+r.Height) {
+			return fmt.Errorf("rect %d is not within the page", i)
+		}
+	}
+	for i, q := range g.Quads {
+		for _, v := range q {
+			if !inPage(v) {
+				return fmt.Errorf("quad %d is not within the page", i)
+			}
+		}
+	}
+	return nil
+}
+
+func validateInk(d *models.InkDrawing) error {
+	if d.PageWidth < 0 || d.PageHeight < 0 || (d.PageWidth == 0) != (d.PageHeight == 0) {
+		return fmt.Errorf("page_width and page_height must both be positive, or both be omitted")
+	}
+	if len(d.Strokes) > MaxInkStrokes {
+		return fmt.Errorf("more than %d strokes", MaxInkStrokes)
+	}
+	points := 0
+	for i, s := range d.Strokes {
+		if len(s.Points) == 0 {
+			return fmt.Errorf("stroke %d has no points", i)
+		}
+		if !(s.Width > 0) || s.Width > maxInkWidth {
+			return fmt.Errorf("stroke %d width must be above 0 and at most %g of the page", i, maxInkWidth)
+		}
+		if s.Color != "" && !svgColorRe.MatchString(s.Color) {
+			return fmt.Errorf("stroke %d color %q is not a color", i, s.Color)
+		}
+		var last int64
+		for j, p := range s.Points {
+			if !inPage(p.X) || !inPage(p.Y) {
+				return fmt.Errorf("stroke %d point %d is not within the page", i, j)
+			}
+			if !(p.Pressure >= 0 && p.Pressure <= 1) {
+				return fmt.Errorf("stroke %d point %d pressure must be between 0 and 1", i, j)
+			}
+			if p.T < last {
+				return fmt.Errorf("stroke %d point %d is earlier than the point before", i, j)
+			}
+			last = p.T
+		}
+		points += len(s.Points)
+	}
+	if points > MaxInkPoints {
+		return fmt.Errorf("more than %d points", MaxInkPoints)
+	}
+	data, err := d.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if len(data) > MaxInkSize {
+		return fmt.Errorf("larger than %d bytes encoded", MaxInkSize)
+	}
+	return nil
+}
+
+func inPage(v float64) bool {
+	return v >= 0 && v <= 1
+}
+
+// HasShape reports whether an annotation has geometry or ink to render
+func HasShape(a *models.Annotation) bool {
+	return (a.Geometry != nil && len(a.Geometry.Rects)+len(a.Geometry.Quads) > 0) ||
+		(a.Ink != nil && len(a.Ink.Strokes) > 0)
+}
+
+// RenderAnnotationSVG draws an annotation's areas and ink on an empty page.
+// Areas are filled translucently in the annotation's color. Ink is drawn
+// in its strokes' colors, with pressure varying the width.
+func RenderAnnotationSVG(a *models.Annotation) []byte {
+	width, height := float64(svgWidth), svgWidth*defaultPageAspect
+	if a.Ink != nil && a.Ink.PageWidth > 0 && a.Ink.PageHeight > 0 {
+		height = svgWidth * a.Ink.PageHeight / a.Ink.PageWidth
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %s %s">`, svgNumber(width), svgNumber(height))
+	if a.Geometry != nil && len(a.Geometry.Rects)+len(a.Geometry.Quads) > 0 {
+		color := defaultAreaColor
+		if svgColorRe.MatchString(a.Color) {
+			color = a.Color
+		}
+		fmt.Fprintf(&buf, `<g fill="%s" fill-opacity="0.35">`, color)
+		for _, r := range a.Geometry.Rects {
+			fmt.Fprintf(&buf, `<rect x="%s" y="%s" width="%s" height="%s"/>`,
+				svgNumber(r.X*width), svgNumber(r.Y*height), svgNumber(r.Width*width), svgNumber(r.Height*height))
+		}
+		for _, q := range a.Geometry.Quads {
+			points := make([]string, 0, 4)
+			for i := 0; i < 8; i += 2 {
+				points = append(points, svgNumber(q[i]*width)+","+svgNumber(q[i+1]*height))
+			}
+			fmt.Fprintf(&buf, `<polygon points="%s"/>`, strings.Join(points, " "))
+		}
+		buf.WriteString(`</g>`)
+	}
+	if a.Ink != nil && len(a.Ink.Strokes) > 0 {
+		buf.WriteString(`<g fill="none" stroke-linecap="round" stroke-linejoin="round">`)
+		for _, s := range a.Ink.Strokes {
+			writeInkStroke(&buf, s, width, height)
+		}
+		buf.WriteString(`</g>`)
+	}
+	buf.WriteString(`</svg>`)
+	return buf.Bytes()
+}
+
+// writeInkStroke draws a stroke as paths. Strokes with pressure are split
+// into runs of segments of similar pressure, each drawn at its own width.
+func writeInkStroke(buf *bytes.Buffer, s models.InkStroke, width, height float64) {
+	color := "currentColor"
+	if svgColorRe.MatchString(s.Color) {
+		color = s.Color
+	}
+	strokeWidth := s.Width * width
+	point := func(p models.InkPoint) string {
+		return svgNumber(p.X*width) + " " + svgNumber(p.Y*height)
+	}
+
+	if len(s.Points) == 1 {
+		p := s.Points[0]
+		fmt.Fprintf(buf, `<circle cx="%s" cy="%s" r="%s" fill="%s"/>`,
+			svgNumber(p.X*width), svgNumber(p.Y*height), svgNumber(strokeWidth/2), color)
+		return
+	}
+
+	pressured := false
+	for _, p := range s.Points {
+		if p.Pressure > 0 {
+			pressured = true
+			break
+		}
+	}
+	if !pressured {
+		var path strings.Builder
+		for i, p := range s.Points {
+			if i == 0 {
+				path.WriteString("M" + point(p))
+			} else {
+				path.WriteString(" L" + point(p))
+			}
+		}
+		fmt.Fprintf(buf, `<path d="%s" stroke="%s" stroke-width="%s"/>`, path.String(), color, svgNumber(strokeWidth))
+		return
+	}
+
+	level := func(i int) int {
+		pressure := (s.Points[i-1].Pressure + s.Points[i].Pressure) / 2
+		return int(math.Min(inkPressureLevels-1, math.Floor(pressure*inkPressureLevels)))
+	}
+	for start := 1; start < len(s.Points); {
+		l := level(start)
+		end := start
+		for end+1 < len(s.Points) && level(end+1) == l {
+			end++
+		}
+		path := "M" + point(s.Points[start-1])
+		for i := start; i <= end; i++ {
+			path += " L" + point(s.Points[i])
+		}
+		scale := minInkPressureScale + (1-minInkPressureScale)*(float64(l)+0.5)/inkPressureLevels
+		fmt.Fprintf(buf, `<path d="%s" stroke="%s" stroke-width="%s"/>`, path, color, svgNumber(strokeWidth*scale))
+		start = end + 1
+	}
+}
+
+// svgNumber formats a coordinate to two decimal places, without trailing zeros
+func svgNumber(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// SVGDataURI embeds an SVG image in a data URI, for Markdown exports
+func SVGDataURI(svg []byte) string {
+	return "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString(svg)
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestValidateAnnotationShape(t *testing.T) {
+	area := &models.AnnotationGeometry{Rects: []models.PageRect{{X: 0.1, Y: 0.2, Width: 0.5, Height: 0.3}}}
+	stroke := models.InkStroke{Width: 0.003, Points: []models.InkPoint{{X: 0.1, Y: 0.1, T: 5}, {X: 0.2, Y: 0.2, T: 9}}}
+
+	valid := []struct {
+		typ      string
+		geometry *models.AnnotationGeometry
+		ink      *models.InkDrawing
+	}{
+		{models.AnnotationArea, area, nil},
+		{models.AnnotationInk, nil, &models.InkDrawing{Strokes: []models.InkStroke{stroke}}},
+		{models.AnnotationHighlight, &models.AnnotationGeometry{Quads: []models.PageQuad{{0, 0, 1, 0, 1, 0.1, 0, 0.1}}}, nil},
+		{models.AnnotationNote, nil, nil},
+	}
+	for i, tc := range valid {
+		if err := ValidateAnnotationShape(tc.typ, tc.geometry, tc.ink); err != nil {
+			t.Errorf("case %d: unexpected error %v", i, err)
+		}
+	}
+
+	backwards := stroke
+	backwards.Points = []models.InkPoint{{X: 0.1, Y: 0.1, T: 9}, {X: 0.2, Y: 0.2, T: 5}}
+	invalid := []struct {
+		typ      string
+		geometry *models.AnnotationGeometry
+		ink      *models.InkDrawing
+	}{
+		{models.AnnotationArea, nil, nil},
+		{models.AnnotationInk, area, nil},
+		{models.AnnotationArea, &models.AnnotationGeometry{Rects: []models.PageRect{{X: 0.8, Y: 0, Width: 0.3, Height: 0.1}}}, nil},
+		{models.AnnotationInk, nil, &models.InkDrawing{Strokes: []models.InkStroke{backwards}}},
+		{models.AnnotationInk, nil, &models.InkDrawing{Strokes: []models.InkStroke{{Width: 0.5, Points: stroke.Points}}}},
+		{models.AnnotationInk, nil, &models.InkDrawing{Strokes: []models.InkStroke{{Width: 0.01, Color: `red" onload="x`, Points: stroke.Points}}}},
+		{models.AnnotationInk, nil, &models.InkDrawing{PageWidth: 100, Strokes: []models.InkStroke{stroke}}},
+	}
+	for i, tc := range invalid {
+		if err := ValidateAnnotationShape(tc.typ, tc.geometry, tc.ink); err == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+}
+
+func TestRenderAnnotationSVG(t *testing.T) {
+	a := &models.Annotation{
+		Type:     models.AnnotationInk,
+		Color:    "yellow",
+		Geometry: &models.AnnotationGeometry{Rects: []models.PageRect{{X: 0.1, Y: 0.1, Width: 0.5, Height: 0.25}}},
+		Ink: &models.InkDrawing{PageWidth: 100, PageHeight: 200, Strokes: []models.InkStroke{
+			{Width: 0.004, Points: []models.InkPoint{{X: 0.1, Y: 0.1}, {X: 0.2, Y: 0.15}, {X: 0.3, Y: 0.1}}},
+			{Color: "#c00", Width: 0.01, Points: []models.InkPoint{{X: 0.5, Y: 0.5, Pressure: 0.1}, {X: 0.6, Y: 0.5, Pressure: 0.1}, {X: 0.7, Y: 0.5, Pressure: 0.9}}},
+			{Width: 0.01, Points: []models.InkPoint{{X: 0.9, Y: 0.9}}},
+		}},
+	}
+	svg := string(RenderAnnotationSVG(a))
+	if err := xml.Unmarshal([]byte(svg), new(struct{})); err != nil {
+		t.Fatalf("invalid SVG: %v\n%s", err, svg)
+	}
+
+	for _, want := range []string{
+		`viewBox="0 0 1000 2000"`,
+		`<g fill="yellow" fill-opacity="0.35"><rect x="100" y="200" width="500" height="500"/></g>`,
+		`<path d="M100 200 L200 300 L300 200" stroke="currentColor" stroke-width="4"/>`,
+		`<path d="M500 1000 L600 1000" stroke="#c00" stroke-width="2.97"/>`,
+		`<path d="M600 1000 L700 1000" stroke="#c00" stroke-width="6.72"/>`,
+		`<circle cx="900" cy="1800" r="5" fill="currentColor"/>`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG missing %s:\n%s", want, svg)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/classius/server/internal/models"
+)
+
+// maxDiffCells bounds the word diff table; longer notes are diffed by line
+const maxDiffCells = 4_000_000
+
+// AnnotationHistoryService lists, compares and restores annotation revisions
+type AnnotationHistoryService struct {
+	db *gorm.DB
+}
+
+// NewAnnotationHistoryService creates a new annotation history service
+func NewAnnotationHistoryService(db *gorm.DB) *AnnotationHistoryService {
+	return &AnnotationHistoryService{db: db}
+}
+
+// AnnotationHistory is an annotation's revisions, newest first
+type AnnotationHistory struct {
+	AnnotationID    uuid.UUID                   `json:"annotation_id"`
+	CurrentRevision int                         `json:"current_revision"`
+	Deleted         bool                        `json:"deleted"`
+	Revisions       []models.AnnotationRevision `json:"revisions"`
+}
+
+// AnnotationRevisionDiff compares two revisions of an annotation
+type AnnotationRevisionDiff struct {
+	AnnotationID uuid.UUID     `json:"annotation_id"`
+	From         int           `json:"from"`
+	To           int           `json:"to"`
+	Changes      []FieldChange `json:"changes"` // Fields whose values differ
+	Content      []DiffSegment `json:"content"` // Word diff of the note content
+}
+
+// FieldChange is a field that differs between two revisions
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// DiffSegment is a run of text that is unchanged, inserted or deleted
+type DiffSegment struct {
+	Op   string `json:"op"` // equal, insert, delete
+	Text string `json:"text"`
+}
+
+// recordAnnotationRevisions snapshots annotations after a change. The
+// operation is inferred when not given.
+func recordAnnotationRevisions(tx *gorm.DB, userID uuid.UUID, annotationIDs []uuid.UUID, operation string, fields []string) error {
+	var annotations []models.Annotation
+	if err := tx.Unscoped().Where("id IN ? AND user_id = ?", annotationIDs, userID).Find(&annotations).Error; err != nil {
+		return fmt.Errorf("failed to record annotation revision: %w", err)
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	if fields == nil {
+		fields = []string{}
+	}
+	revisions := make([]models.AnnotationRevision, 0, len(annotations))
+	for i := range annotations {
+		a := &annotations[i]
+		op := operation
+		if op == "" {
+			switch {
+			case a.DeletedAt.Valid:
+				op = models.RevisionDelete
+			case a.Revision <= 1:
+				op = models.RevisionCreate
+			default:
+				op = models.RevisionUpdate
+			}
+		}
+		revisions = append(revisions, annotationRevision(a, op, fields))
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&revisions, 500).Error; err != nil {
+		return fmt.Errorf("failed to record annotation revision: %w", err)
+	}
+	return nil
+}
+
+func annotationRevision(a *models.Annotation, operation string, fields []string) models.AnnotationRevision {
+	tags := a.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return models.AnnotationRevision{
+		ID:            uuid.New(),
+		AnnotationID:  a.ID,
+		Revision:      a.Revision,
+		UserID:        a.UserID,
+		Operation:     operation,
+		ChangedFields: fields,
+		BookID:        a.BookID,
+		Type:          a.Type,
+		PageNumber:    a.PageNumber,
+		StartPosition: a.StartPosition,
+		EndPosition:   a.EndPosition,
+		SelectedText:  a.SelectedText,
+		Content:       a.Content,
+		Color:         a.Color,
+		Tags:          tags,
+		IsPrivate:     a.IsPrivate,
+		Geometry:      a.Geometry,
+		Ink:           a.Ink,
+		Deleted:       a.DeletedAt.Valid,
+	}
+}
+
+// History returns an annotation's revisions, including a deleted
+// annotation's
+func (s *AnnotationHistoryService) History(ctx context.Context, userID, annotationID uuid.UUID) (*AnnotationHistory, error) {
+	annotation, err := s.findAnnotation(s.db.WithContext(ctx), userID, annotationID)
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []models.AnnotationRevision
+	if err := s.db.WithContext(ctx).Where("annotation_id = ? AND user_id = ?", annotationID, userID).
+		Order("revision DESC").Find(&revisions).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve annotation history: %w", err)
+	}
+	return &AnnotationHistory{
+		AnnotationID:    annotationID,
+		CurrentRevision: annotation.Revision,
+		Deleted:         annotation.DeletedAt.Valid,
+		Revisions:       revisions,
+	}, nil
+}
+
+// Restore returns an annotation to its state at a revision, undeleting it
+// if needed. The restore is itself recorded as a new revision.
+func (s *AnnotationHistoryService) Restore(ctx context.Context, userID, annotationID uuid.UUID, revision int) (*models.Annotation, error) {
+	var restored *models.Annotation
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		annotation, err := s.findAnnotation(tx.Clauses(clause.Locking{Strength: "UPDATE"}), userID, annotationID)
+		if err != nil {
+			return err
+		}
+		target, err := s.findRevision(tx, userID, annotationID, revision)
+		if err != nil {
+			return err
+		}
+
+		current := annotationRevision(annotation, "", nil)
+		updates := make(map[string]interface{})
+		var fields []string
+		for _, change := range revisionChanges(&current, target) {
+			if change.Field == "deleted" {
+				continue
+			}
+			updates[change.Field] = change.To
+			fields = append(fields, change.Field)
+		}
+		if len(updates) == 0 && !annotation.DeletedAt.Valid {
+			restored = annotation
+			return nil
+		}
+		updates["deleted_at"] = nil
+		if err := tx.Unscoped().Model(&models.Annotation{}).Where("id = ?", annotationID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to restore annotation: %w", err)
+		}
+		if err := recordAnnotationChange(tx, userID, []uuid.UUID{annotationID}, models.RevisionRestore, fields); err != nil {
+			return err
+		}
+
+		restored, err = s.findAnnotation(tx, userID, annotationID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// Diff compares two revisions of an annotation. to defaults (when
+// negative) to the latest revision and from to the one before to.
+func (s *AnnotationHistoryService) Diff(ctx context.Context, userID, annotationID uuid.UUID, from, to int) (*AnnotationRevisionDiff, error) {
+	db := s.db.WithContext(ctx)
+	if _, err := s.findAnnotation(db, userID, annotationID); err != nil {
+		return nil, err
+	}
+
+	var newer, older models.AnnotationRevision
+	query := db.Where("annotation_id = ? AND user_id = ?", annotationID, userID)
+	if to >= 0 {
+		query = query.Where("revision = ?", to)
+	}
+	result := query.Order("revision DESC").Limit(1).Find(&newer)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve revision: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("revision %w", ErrNotFound)
+	}
+
+	query = db.Where("annotation_id = ? AND user_id = ?", annotationID, userID)
+	if from >= 0 {
+		query = query.Where("revision = ?", from)
+	} else {
+		query = query.Where("revision < ?", newer.Revision)
+	}
+	result = query.Order("revision DESC").Limit(1).Find(&older)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve revision: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		if from >= 0 {
+			return nil, fmt.Errorf("revision %w", ErrNotFound)
+		}
+		// The first revision: compare against nothing
+		older = models.AnnotationRevision{Revision: 0, Tags: []string{}}
+	}
+
+	return &AnnotationRevisionDiff{
+		AnnotationID: annotationID,
+		From:         older.Revision,
+		To:           newer.Revision,
+		Changes:      revisionChanges(&older, &newer),
+		Content:      DiffText(older.Content, newer.Content),
+	}, nil
+}
+
+func (s *AnnotationHistoryService) findAnnotation(db *gorm.DB, userID, annotationID uuid.UUID) (*models.Annotation, error) {
+	var annotation models.Annotation
+	result := db.Unscoped().Where("id = ? AND user_id = ?", annotationID, userID).Limit(1).Find(&annotation)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve annotation: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("annotation %w", ErrNotFound)
+	}
+	return &annotation, nil
+}
+
+func (s *AnnotationHistoryService) findRevision(db *gorm.DB, userID, annotationID uuid.UUID, revision int) (*models.AnnotationRevision, error) {
+	var rev models.AnnotationRevision
+	result := db.Where("annotation_id = ? AND user_id = ? AND revision = ?", annotationID, userID, revision).Limit(1).Find(&rev)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve revision: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("revision %w", ErrNotFound)
+	}
+	return &rev, nil
+}
+
+// revisionChanges lists the annotation fields that differ between two
+// revisions, by column name
+func revisionChanges(from, to *models.AnnotationRevision) []FieldChange {
+	fields := []struct {
+		name     string
+		from, to interface{}
+	}{
+		{"type", from.Type, to.Type},
+		{"page_number", from.PageNumber, to.PageNumber},
+		{"start_position", from.StartPosition, to.StartPosition},
+		{"end_position", from.EndPosition, to.EndPosition},
+		{"selected_text", from.SelectedText, to.SelectedText},
+		{"content", from.Content, to.Content},
+		{"color", from.Color, to.Color},
+		{"tags", nonNilStrings(from.Tags), nonNilStrings(to.Tags)},
+		{"is_private", from.IsPrivate, to.IsPrivate},
+		{"geometry", from.Geometry, to.Geometry},
+		{"ink", from.Ink, to.Ink},
+		{"deleted", from.Deleted, to.Deleted},
+	}
+	changes := []FieldChange{}
+	for _, f := range fields {
+		if !reflect.DeepEqual(f.from, f.to) {
+			changes = append(changes, FieldChange{Field: f.name, From: f.from, To: f.to})
+		}
+	}
+	return changes
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+var diffTokenRe = regexp.MustCompile(`\s+|[^\s]+`)
+
+// DiffText diffs two texts word by word, keeping whitespace, or line by
+// line when they are too long for a word diff
+func DiffText(from, to string) []DiffSegment {
+	a, b := diffTokenRe.FindAllString(from, -1), diffTokenRe.FindAllString(to, -1)
+	if len(a)*len(b) > maxDiffCells {
+		a, b = splitLines(from), splitLines(to)
+	}
+	if len(a)*len(b) > maxDiffCells {
+		return mergeSegments([]DiffSegment{{Op: "delete", Text: from}, {Op: "insert", Text: to}})
+	}
+
+	// Longest common subsequence of tokens, from the end
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var segments []DiffSegment
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			segments = append(segments, DiffSegment{Op: "equal", Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			segments = append(segments, DiffSegment{Op: "delete", Text: a[i]})
+			i++
+		default:
+			segments = append(segments, DiffSegment{Op: "insert", Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		segments = append(segments, DiffSegment{Op: "delete", Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		segments = append(segments, DiffSegment{Op: "insert", Text: b[j]})
+	}
+	return mergeSegments(segments)
+}
+
+// splitLines splits text after each newline, keeping them
+func splitLines(text string) []string {
+	var lines []string
+	for len(text) > 0 {
+		i := 0
+		for i < len(text) && text[i] != '\n' {
+			i++
+		}
+		if i < len(text) {
+			i++
+		}
+		lines = append(lines, text[:i])
+		text = text[i:]
+	}
+	return lines
+}
+
+// mergeSegments joins adjacent segments with the same operation and drops
+// empty ones
+func mergeSegments(segments []DiffSegment) []DiffSegment {
+	merged := []DiffSegment{}
+	for _, s := range segments {
+		if s.Text == "" {
+			continue
+		}
+		if n := len(merged); n > 0 && merged[n-1].Op == s.Op {
+			merged[n-1].Text += s.Text
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
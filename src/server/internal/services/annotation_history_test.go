@@ -0,0 +1,58 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestDiffText(t *testing.T) {
+	segments := DiffText("The unexamined life is not worth living.", "The examined life is worth living, said Socrates.")
+	var from, to strings.Builder
+	for _, s := range segments {
+		if s.Op != "insert" {
+			from.WriteString(s.Text)
+		}
+		if s.Op != "delete" {
+			to.WriteString(s.Text)
+		}
+	}
+	if from.String() != "The unexamined life is not worth living." || to.String() != "The examined life is worth living, said Socrates." {
+		t.Fatalf("segments do not rebuild both texts: %+v", segments)
+	}
+	want := []DiffSegment{
+		{"equal", "The "},
+		{"delete", "unexamined"},
+		{"insert", "examined"},
+		{"equal", " life is "},
+		{"delete", "not "},
+		{"equal", "worth "},
+		{"delete", "living."},
+		{"insert", "living, said Socrates."},
+	}
+	if len(segments) != len(want) {
+		t.Fatalf("unexpected segments %+v", segments)
+	}
+	for i := range want {
+		if segments[i] != want[i] {
+			t.Errorf("segment %d: got %+v, want %+v", i, segments[i], want[i])
+		}
+	}
+
+	if got := DiffText("", "new note"); len(got) != 1 || got[0].Op != "insert" {
+		t.Errorf("unexpected diff from empty: %+v", got)
+	}
+	if got := DiffText("same", "same"); len(got) != 1 || got[0].Op != "equal" {
+		t.Errorf("unexpected diff of equal texts: %+v", got)
+	}
+}
+
+func TestRevisionChanges(t *testing.T) {
+	from := &models.AnnotationRevision{Type: "highlight", Content: "a", Tags: nil, Color: "yellow"}
+	to := &models.AnnotationRevision{Type: "note", Content: "a", Tags: []string{}, Color: "yellow", Deleted: true}
+	changes := revisionChanges(from, to)
+	if len(changes) != 2 || changes[0].Field != "type" || changes[1].Field != "deleted" {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+}
@@ -0,0 +1,439 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/classius/server/internal/models"
+)
+
+// Annotation layers, choosing whose annotations are shown over a book.
+// A group's layer is named by its visibility scope, "group:<id>".
+const (
+	LayerShared = "shared" // Everything others share with the viewer
+	LayerMine   = "mine"   // The viewer's own annotations
+	LayerPublic = "public" // Public annotations
+
+	maxLayerAnnotations = 1000 // Annotations considered for a book's layers
+)
+
+// AnnotationLayerService shows the annotations readers share with their
+// groups or the public over other copies of the same text, and the replies
+// to them
+type AnnotationLayerService struct {
+	db *gorm.DB
+}
+
+// NewAnnotationLayerService creates a new annotation layer service
+func NewAnnotationLayerService(db *gorm.DB) *AnnotationLayerService {
+	return &AnnotationLayerService{db: db}
+}
+
+// AnnotationLayer is a parsed layer name
+type AnnotationLayer struct {
+	Kind    string     // shared, mine, public or group
+	GroupID *uuid.UUID // For group layers
+}
+
+// SharedAnnotationFilter narrows the annotations shown over a book
+type SharedAnnotationFilter struct {
+	Layer    AnnotationLayer
+	Type     string     // highlight, note, bookmark, area or ink
+	AuthorID *uuid.UUID // Only this reader's annotations
+	Range    *TextRange // Only annotations overlapping this span
+}
+
+// AnnotationLayerSummary is a layer available over a book, with the number
+// of annotations in it
+type AnnotationLayerSummary struct {
+	Layer   string     `json:"layer"`
+	Name    string     `json:"name"`
+	GroupID *uuid.UUID `json:"group_id,omitempty"`
+	Count   int64      `json:"count"`
+}
+
+// SharedAnnotation is an annotation as shown over the viewer's copy of a
+// book, with its author
+type SharedAnnotation struct {
+	ID              uuid.UUID                  `json:"id"`
+	UserID          uuid.UUID                  `json:"user_id"`
+	AuthorName      string                     `json:"author_name"`
+	AuthorUsername  string                     `json:"author_username"`
+	AuthorVerified  bool                       `json:"author_verified"`
+	BookID          uuid.UUID                  `json:"-"` // The author's copy
+	BookFingerprint string                     `json:"-"`
+	Visibility      string                     `json:"visibility"`
+	Type            string                     `json:"type"`
+	PageNumber      int                        `json:"page_number"`
+	StartPosition   int                        `json:"start_position"` // In the viewer's copy
+	EndPosition     int                        `json:"end_position"`
+	SelectedText    string                     `json:"selected_text"`
+	Content         string                     `json:"content"`
+	Color           string                     `json:"color"`
+	Geometry        *models.AnnotationGeometry `json:"geometry,omitempty"`
+	Ink             *models.InkDrawing         `json:"ink,omitempty"`
+	ReplyCount      int                        `json:"reply_count"`
+	Anchored        bool                       `json:"anchored"`
+	CreatedAt       time.Time                  `json:"created_at"`
+	UpdatedAt       time.Time                  `json:"updated_at"`
+}
+
+// AnnotationReplyView is a reply with its author
+type AnnotationReplyView struct {
+	ID             uuid.UUID `json:"id"`
+	AnnotationID   uuid.UUID `json:"annotation_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	AuthorName     string    `json:"author_name"`
+	AuthorUsername string    `json:"author_username"`
+	AuthorVerified bool      `json:"author_verified"`
+	Content        string    `json:"content"`
+	ContentHTML    string    `json:"content_html"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// AnnotationVisibility builds the condition matching the annotations, under
+// alias, that the viewer may see: their own, public ones, and those shared
+// with a group both they and the author still belong to. Every query
+// returning other readers' annotations must apply it.
+func AnnotationVisibility(alias string, viewerID uuid.UUID) (string, []interface{}) {
+	return fmt.Sprintf(`(%[1]s.user_id = ? OR %[1]s.visibility = 'public' OR (%[1]s.visibility LIKE 'group:%%' AND EXISTS (
+		SELECT 1 FROM group_members vm
+		JOIN group_members am ON am.group_id = vm.group_id AND am.user_id = %[1]s.user_id AND am.deleted_at IS NULL
+		JOIN reading_groups vg ON vg.id = vm.group_id AND vg.deleted_at IS NULL
+		WHERE vm.user_id = ? AND vm.deleted_at IS NULL AND %[1]s.visibility = 'group:' || vm.group_id::text)))`, alias),
+		[]interface{}{viewerID, viewerID}
+}
+
+// ResolveVisibility checks a visibility scope the user gives one of their
+// annotations, returning it in canonical form. Sharing with a group needs
+// membership.
+func ResolveVisibility(db *gorm.DB, userID uuid.UUID, visibility string) (string, error) {
+	kind, groupID, ok := models.ParseVisibility(strings.TrimSpace(visibility))
+	if !ok {
+		return "", fmt.Errorf("%w visibility %q: use private, public or group:<id>", ErrInvalid, visibility)
+	}
+	if kind != "group" {
+		return kind, nil
+	}
+	if _, err := requireGroupRole(db, *groupID, userID, models.GroupRoleMember); err != nil {
+		return "", err
+	}
+	return models.GroupVisibility(*groupID), nil
+}
+
+// ParseAnnotationLayer parses a layer name: shared (the default), mine,
+// public or group:<id>
+func ParseAnnotationLayer(s string) (AnnotationLayer, error) {
+	switch s {
+	case "", LayerShared:
+		return AnnotationLayer{Kind: LayerShared}, nil
+	case LayerMine, LayerPublic:
+		return AnnotationLayer{Kind: s}, nil
+	}
+	if kind, groupID, ok := models.ParseVisibility(s); ok && kind == "group" {
+		return AnnotationLayer{Kind: kind, GroupID: groupID}, nil
+	}
+	return AnnotationLayer{}, fmt.Errorf("%w layer %q: use shared, mine, public or group:<id>", ErrInvalid, s)
+}
+
+// LayerCondition builds the condition selecting a layer's annotations,
+// under alias, among those the viewer may see
+func LayerCondition(alias string, layer AnnotationLayer, viewerID uuid.UUID) (string, []interface{}) {
+	condition, args := AnnotationVisibility(alias, viewerID)
+	switch layer.Kind {
+	case LayerMine:
+		return alias + ".user_id = ?", []interface{}{viewerID}
+	case LayerPublic:
+		return condition + " AND " + alias + ".visibility = 'public'", args
+	case "group":
+		return condition + " AND " + alias + ".visibility = ?", append(args, models.GroupVisibility(*layer.GroupID))
+	}
+	return condition + " AND " + alias + ".user_id <> ?", append(args, viewerID)
+}
+
+// RequireLayerAccess checks that the viewer can open a layer: group layers
+// are for members
+func RequireLayerAccess(db *gorm.DB, viewerID uuid.UUID, layer AnnotationLayer) error {
+	if layer.Kind != "group" {
+		return nil
+	}
+	_, err := requireGroupRole(db, *layer.GroupID, viewerID, models.GroupRoleMember)
+	return err
+}
+
+// AnnotationBookMatch builds the condition matching annotations on any copy
+// of the viewer's book, by ISBN or text, given the annotation's book as b
+func AnnotationBookMatch(db *gorm.DB, viewerID, bookID uuid.UUID) (string, []interface{}, error) {
+	book, err := ownBook(db, viewerID, bookID)
+	if err != nil {
+		return "", nil, err
+	}
+	return annotationBookMatch(db, book)
+}
+
+func annotationBookMatch(db *gorm.DB, book *models.Book) (string, []interface{}, error) {
+	fingerprint, err := bookTextFingerprint(db, book)
+	if err != nil {
+		return "", nil, err
+	}
+	conditions := []string{"b.id = ?"}
+	args := []interface{}{book.ID}
+	if isbn := normalizeISBN(book.ISBN); isbn != "" {
+		conditions = append(conditions, "UPPER(REPLACE(REPLACE(b.isbn, '-', ''), ' ', '')) = ?")
+		args = append(args, isbn)
+	}
+	if fingerprint != "" {
+		conditions = append(conditions, "b.text_fingerprint = ?")
+		args = append(args, fingerprint)
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args, nil
+}
+
+// Layers lists the layers over one of the viewer's books: their own, the
+// public one and one per group they belong to
+func (s *AnnotationLayerService) Layers(ctx context.Context, viewerID, bookID uuid.UUID) ([]AnnotationLayerSummary, error) {
+	db := s.db.WithContext(ctx)
+	book, err := ownBook(db, viewerID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	match, args, err := annotationBookMatch(db, book)
+	if err != nil {
+		return nil, err
+	}
+	visible, visibleArgs := AnnotationVisibility("a", viewerID)
+
+	var counts []struct {
+		Visibility string
+		Mine       bool
+		Count      int64
+	}
+	if err := db.Table("annotations a").
+		Select("a.visibility, a.user_id = ? AS mine, COUNT(*) AS count", viewerID).
+		Joins("JOIN books b ON b.id = a.book_id AND b.deleted_at IS NULL").
+		Where("a.deleted_at IS NULL").
+		Where(match, args...).
+		Where(visible, visibleArgs...).
+		Group("a.visibility, mine").Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("failed to count annotations: %w", err)
+	}
+
+	var groups []struct {
+		ID   uuid.UUID
+		Name string
+	}
+	if err := db.Table("group_members m").
+		Select("g.id, g.name").
+		Joins("JOIN reading_groups g ON g.id = m.group_id AND g.deleted_at IS NULL").
+		Where("m.user_id = ? AND m.deleted_at IS NULL", viewerID).
+		Order("g.name").Scan(&groups).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve groups: %w", err)
+	}
+
+	byLayer := map[string]int64{}
+	for _, count := range counts {
+		if count.Mine {
+			byLayer[LayerMine] += count.Count
+			continue
+		}
+		byLayer[count.Visibility] += count.Count
+		byLayer[LayerShared] += count.Count
+	}
+	layers := []AnnotationLayerSummary{
+		{Layer: LayerShared, Name: "Shared with me", Count: byLayer[LayerShared]},
+		{Layer: LayerMine, Name: "My annotations", Count: byLayer[LayerMine]},
+		{Layer: LayerPublic, Name: "Public", Count: byLayer[models.VisibilityPublic]},
+	}
+	for _, group := range groups {
+		groupID := group.ID
+		layer := models.GroupVisibility(groupID)
+		layers = append(layers, AnnotationLayerSummary{Layer: layer, Name: group.Name, GroupID: &groupID, Count: byLayer[layer]})
+	}
+	return layers, nil
+}
+
+// SharedAnnotations returns a layer's annotations over one of the viewer's
+// books, placed in the viewer's copy, in text order. Annotations made on
+// other copies are found by their quote; those that cannot be placed are
+// left out.
+func (s *AnnotationLayerService) SharedAnnotations(ctx context.Context, viewerID, bookID uuid.UUID, filter SharedAnnotationFilter) ([]SharedAnnotation, error) {
+	db := s.db.WithContext(ctx)
+	if err := RequireLayerAccess(db, viewerID, filter.Layer); err != nil {
+		return nil, err
+	}
+	book, err := ownBook(db, viewerID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	match, args, err := annotationBookMatch(db, book)
+	if err != nil {
+		return nil, err
+	}
+	layer, layerArgs := LayerCondition("a", filter.Layer, viewerID)
+
+	query := db.Table("annotations a").
+		Select(`a.id, a.user_id, a.book_id, a.visibility, a.type, a.page_number, a.start_position, a.end_position,
+			a.selected_text, a.content, a.color, a.geometry, a.ink, a.created_at, a.updated_at,
+			b.text_fingerprint AS book_fingerprint,
+			COALESCE(NULLIF(u.full_name, ''), u.username) AS author_name, u.username AS author_username,
+			u.verified_scholar AS author_verified,
+			(SELECT COUNT(*) FROM annotation_replies r WHERE r.annotation_id = a.id AND r.deleted_at IS NULL) AS reply_count`).
+		Joins("JOIN books b ON b.id = a.book_id AND b.deleted_at IS NULL").
+		Joins("JOIN users u ON u.id = a.user_id").
+		Where("a.deleted_at IS NULL").
+		Where(match, args...).
+		Where(layer, layerArgs...)
+	if filter.Type != "" {
+		query = query.Where("a.type = ?", filter.Type)
+	}
+	if filter.AuthorID != nil {
+		query = query.Where("a.user_id = ?", *filter.AuthorID)
+	}
+	var candidates []SharedAnnotation
+	if err := query.Order("a.created_at DESC").Limit(maxLayerAnnotations).Scan(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve annotations: %w", err)
+	}
+
+	var locator *QuoteLocator
+	if len(candidates) > 0 {
+		if locator, err = bookLocator(db, book.ID); err != nil {
+			return nil, err
+		}
+	}
+	annotations := []SharedAnnotation{}
+	for _, annotation := range candidates {
+		if annotation.BookID == book.ID {
+			annotation.Anchored = true
+		} else {
+			sameText := book.TextFingerprint != "" && annotation.BookFingerprint == book.TextFingerprint
+			annotation.StartPosition, annotation.EndPosition, annotation.Anchored = anchorQuote(locator,
+				annotation.StartPosition, annotation.EndPosition, annotation.SelectedText, "", "", sameText)
+		}
+		if !annotation.Anchored || !inTextRange(filter.Range, annotation.StartPosition, annotation.EndPosition) {
+			continue
+		}
+		annotations = append(annotations, annotation)
+	}
+	sort.SliceStable(annotations, func(i, j int) bool {
+		a, b := annotations[i], annotations[j]
+		if a.StartPosition != b.StartPosition {
+			return a.StartPosition < b.StartPosition
+		}
+		return a.PageNumber < b.PageNumber
+	})
+	return annotations, nil
+}
+
+// Replies lists the replies to an annotation the viewer can see, oldest
+// first
+func (s *AnnotationLayerService) Replies(ctx context.Context, viewerID, annotationID uuid.UUID, limit, offset int) ([]AnnotationReplyView, int64, error) {
+	db := s.db.WithContext(ctx)
+	if _, err := visibleAnnotation(db, viewerID, annotationID); err != nil {
+		return nil, 0, err
+	}
+
+	query := s.replies(ctx).Where("r.annotation_id = ?", annotationID)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count replies: %w", err)
+	}
+	replies := []AnnotationReplyView{}
+	if err := query.Order("r.created_at, r.id").Limit(limit).Offset(offset).Scan(&replies).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve replies: %w", err)
+	}
+	return replies, total, nil
+}
+
+// Reply replies to a shared annotation the viewer can see
+func (s *AnnotationLayerService) Reply(ctx context.Context, viewerID, annotationID uuid.UUID, content string) (*AnnotationReplyView, error) {
+	db := s.db.WithContext(ctx)
+	annotation, err := visibleAnnotation(db, viewerID, annotationID)
+	if err != nil {
+		return nil, err
+	}
+	if annotation.Visibility == models.VisibilityPrivate {
+		return nil, fmt.Errorf("%w: only shared annotations take replies", ErrAccessDenied)
+	}
+	content, html, err := discussionContent(content)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := models.AnnotationReply{AnnotationID: annotationID, UserID: viewerID, Content: content, ContentHTML: html}
+	if err := db.Create(&reply).Error; err != nil {
+		return nil, fmt.Errorf("failed to create reply: %w", err)
+	}
+	var view AnnotationReplyView
+	if err := s.replies(ctx).Where("r.id = ?", reply.ID).Scan(&view).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve reply: %w", err)
+	}
+	return &view, nil
+}
+
+// DeleteReply deletes a reply, by its author or the annotation's
+func (s *AnnotationLayerService) DeleteReply(ctx context.Context, userID, annotationID, replyID uuid.UUID) error {
+	db := s.db.WithContext(ctx)
+	annotation, err := visibleAnnotation(db, userID, annotationID)
+	if err != nil {
+		return err
+	}
+	var reply models.AnnotationReply
+	result := db.Where("id = ? AND annotation_id = ?", replyID, annotationID).Limit(1).Find(&reply)
+	if result.Error != nil {
+		return fmt.Errorf("failed to retrieve reply: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("reply %w", ErrNotFound)
+	}
+	if reply.UserID != userID && annotation.UserID != userID {
+		return fmt.Errorf("%w: only its author or the annotation's can delete a reply", ErrAccessDenied)
+	}
+	if err := db.Delete(&reply).Error; err != nil {
+		return fmt.Errorf("failed to delete reply: %w", err)
+	}
+	return nil
+}
+
+// replies selects replies with their authors
+func (s *AnnotationLayerService) replies(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).Table("annotation_replies r").
+		Select(`r.id, r.annotation_id, r.user_id, r.content, r.content_html, r.created_at, r.updated_at,
+			COALESCE(NULLIF(u.full_name, ''), u.username) AS author_name, u.username AS author_username,
+			u.verified_scholar AS author_verified`).
+		Joins("JOIN users u ON u.id = r.user_id").
+		Where("r.deleted_at IS NULL")
+}
+
+// visibleAnnotation loads an annotation the viewer may see. Others'
+// private annotations are reported not found.
+func visibleAnnotation(db *gorm.DB, viewerID, annotationID uuid.UUID) (*models.Annotation, error) {
+	visible, args := AnnotationVisibility("annotations", viewerID)
+	var annotation models.Annotation
+	result := db.Where("id = ?", annotationID).Where(visible, args...).Limit(1).Find(&annotation)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve annotation: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("annotation %w", ErrNotFound)
+	}
+	return &annotation, nil
+}
+
+// inTextRange reports whether a span, or a point for bookmarks, falls in
+// the range. Without a range everything does.
+func inTextRange(within *TextRange, start, end int) bool {
+	if within == nil {
+		return true
+	}
+	if end <= start {
+		return start >= within.Start && start < within.End
+	}
+	return end > within.Start && start < within.End
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestParseAnnotationLayer(t *testing.T) {
+	groupID := uuid.New()
+	for in, want := range map[string]string{"": LayerShared, "shared": LayerShared, "mine": LayerMine, "public": LayerPublic} {
+		layer, err := ParseAnnotationLayer(in)
+		if err != nil || layer.Kind != want || layer.GroupID != nil {
+			t.Errorf("ParseAnnotationLayer(%q) = %+v, %v", in, layer, err)
+		}
+	}
+	layer, err := ParseAnnotationLayer(models.GroupVisibility(groupID))
+	if err != nil || layer.Kind != "group" || *layer.GroupID != groupID {
+		t.Errorf("group layer = %+v, %v", layer, err)
+	}
+	for _, bad := range []string{"private", "group:x", "everyone"} {
+		if _, err := ParseAnnotationLayer(bad); err == nil {
+			t.Errorf("ParseAnnotationLayer(%q) should fail", bad)
+		}
+	}
+}
+
+func TestLayerCondition(t *testing.T) {
+	tx := testDB(t)
+	viewer := createTestUser(t, tx, "viewer", nil)
+	author := createTestUser(t, tx, "author", nil)
+	leaver := createTestUser(t, tx, "leaver", nil)
+	outsider := createTestUser(t, tx, "outsider", nil)
+	book := createTestBook(t, tx, author)
+
+	shared := createTestGroup(t, tx, viewer, author, leaver)
+	other := createTestGroup(t, tx, author, outsider)
+	sharedGroup, otherGroup := models.GroupVisibility(shared), models.GroupVisibility(other)
+
+	own := createTestAnnotation(t, tx, viewer, book, models.VisibilityPrivate).ID
+	public := createTestAnnotation(t, tx, author, book, models.VisibilityPublic).ID
+	private := createTestAnnotation(t, tx, author, book, models.VisibilityPrivate).ID
+	inGroup := createTestAnnotation(t, tx, author, book, sharedGroup).ID
+	inOtherGroup := createTestAnnotation(t, tx, author, book, otherGroup).ID
+	byLeaver := createTestAnnotation(t, tx, leaver, book, sharedGroup).ID
+	if err := tx.Where("group_id = ? AND user_id = ?", shared, leaver).Delete(&models.GroupMember{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		layer AnnotationLayer
+		want  []uuid.UUID
+	}{
+		// Others' annotations the viewer may see: not private ones, nor
+		// those shared with a group the viewer or the author is not in
+		{AnnotationLayer{Kind: LayerShared}, []uuid.UUID{public, inGroup}},
+		{AnnotationLayer{Kind: LayerMine}, []uuid.UUID{own}},
+		{AnnotationLayer{Kind: LayerPublic}, []uuid.UUID{public}},
+		{AnnotationLayer{Kind: "group", GroupID: &shared}, []uuid.UUID{inGroup}},
+		{AnnotationLayer{Kind: "group", GroupID: &other}, nil},
+	}
+	for _, tt := range tests {
+		condition, args := LayerCondition("x", tt.layer, viewer)
+		var got []uuid.UUID
+		if err := tx.Table("annotations x").Where("x.book_id = ? AND x.deleted_at IS NULL", book).
+			Where(condition, args...).Pluck("x.id", &got).Error; err != nil {
+			t.Fatalf("%s: %v", tt.layer.Kind, err)
+		}
+		if !sameIDs(got, tt.want) {
+			t.Errorf("%s layer = %v, want %v (private %s, other group %s, leaver %s)",
+				tt.layer.Kind, got, tt.want, private, inOtherGroup, byLeaver)
+		}
+	}
+}
+
+// sameIDs reports whether two lists hold the same IDs in any order
+func sameIDs(a, b []uuid.UUID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[uuid.UUID]int)
+	for _, id := range a {
+		seen[id]++
+	}
+	for _, id := range b {
+		if seen[id] == 0 {
+			return false
+		}
+		seen[id]--
+	}
+	return true
+}
+
+func TestInTextRange(t *testing.T) {
+	within := &TextRange{Start: 100, End: 200}
+	tests := []struct {
+		start, end int
+		want       bool
+	}{
+		{50, 100, false},
+		{50, 101, true},
+		{150, 250, true},
+		{200, 250, false},
+		{100, 100, true}, // Bookmarks are points
+		{200, 200, false},
+	}
+	for _, tt := range tests {
+		if got := inTextRange(within, tt.start, tt.end); got != tt.want {
+			t.Errorf("inTextRange(%d, %d) = %v, want %v", tt.start, tt.end, got, tt.want)
+		}
+	}
+	if !inTextRange(nil, 5, 10) {
+		t.Error("without a range everything is in it")
+	}
+}
+
+func TestAnnotationVisibilityTrigger(t *testing.T) {
+	tx := testDB(t)
+	owner := createTestUser(t, tx, "owner", nil)
+	book := createTestBook(t, tx, owner)
+	group := models.GroupVisibility(createTestGroup(t, tx, owner))
+
+	state := func(id uuid.UUID) (string, bool) {
+		t.Helper()
+		var row struct {
+			Visibility string
+			IsPrivate  bool
+		}
+		if err := tx.Raw("SELECT visibility, is_private FROM annotations WHERE id = ?", id).Scan(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+		return row.Visibility, row.IsPrivate
+	}
+	set := func(id uuid.UUID, column string, value interface{}) {
+		t.Helper()
+		if err := tx.Exec("UPDATE annotations SET "+column+" = ? WHERE id = ?", value, id).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		from           string
+		column         string
+		value          interface{}
+		wantVisibility string
+	}{
+		// Clearing is_private never shares an annotation
+		{"private cleared", models.VisibilityPrivate, "is_private", false, models.VisibilityPrivate},
+		{"group cleared", group, "is_private", false, group},
+		{"public cleared", models.VisibilityPublic, "is_private", false, models.VisibilityPublic},
+		// Setting it makes any annotation private
+		{"group set", group, "is_private", true, models.VisibilityPrivate},
+		{"public set", models.VisibilityPublic, "is_private", true, models.VisibilityPrivate},
+		// Visibility is authoritative
+		{"shared", models.VisibilityPrivate, "visibility", models.VisibilityPublic, models.VisibilityPublic},
+		{"shared with a group", models.VisibilityPrivate, "visibility", group, group},
+		{"unshared", group, "visibility", models.VisibilityPrivate, models.VisibilityPrivate},
+	}
+	for _, tt := range tests {
+		annotation := createTestAnnotation(t, tx, owner, book, tt.from)
+		set(annotation.ID, tt.column, tt.value)
+		visibility, private := state(annotation.ID)
+		if visibility != tt.wantVisibility || private != (tt.wantVisibility == models.VisibilityPrivate) {
+			t.Errorf("%s: visibility %q, is_private %v; want %q", tt.name, visibility, private, tt.wantVisibility)
+		}
+	}
+
+	// A group annotation made private stays private when is_private is cleared again
+	annotation := createTestAnnotation(t, tx, owner, book, group)
+	set(annotation.ID, "is_private", true)
+	set(annotation.ID, "is_private", false)
+	if visibility, private := state(annotation.ID); visibility != models.VisibilityPrivate || !private {
+		t.Errorf("re-cleared group annotation: visibility %q, is_private %v", visibility, private)
+	}
+
+	// Writers that only know is_private create private annotations
+	id := uuid.New()
+	if err := tx.Exec(`INSERT INTO annotations (id, user_id, book_id, type, is_private, visibility, created_at, updated_at)
+		VALUES (?, ?, ?, 'highlight', false, '', NOW(), NOW())`, id, owner, book).Error; err != nil {
+		t.Fatal(err)
+	}
+	if visibility, private := state(id); visibility != models.VisibilityPrivate || !private {
+		t.Errorf("inserted without a visibility: visibility %q, is_private %v", visibility, private)
+	}
+}
@@ -0,0 +1,538 @@
+package services
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/classius/server/internal/models"
+)
+
+const (
+	maxGraphDepth = 5
+	maxGraphNodes = 1000
+	excerptLength = 200 // Runes of annotation text shown in graph nodes
+)
+
+// Inverse names of the link relations, as seen from the target
+var linkInverseRelations = map[string]string{
+	models.LinkEchoes:      "echoed_by",
+	models.LinkContradicts: "contradicted_by",
+	models.LinkCites:       "cited_by",
+	models.LinkExplains:    "explained_by",
+}
+
+// AnnotationLinkService connects annotations and passages into a graph
+type AnnotationLinkService struct {
+	db *gorm.DB
+}
+
+// NewAnnotationLinkService creates a new annotation link service
+func NewAnnotationLinkService(db *gorm.DB) *AnnotationLinkService {
+	return &AnnotationLinkService{db: db}
+}
+
+// CreateLinkRequest links an annotation to another annotation or to a
+// passage (target_book_id with rune offsets)
+type CreateLinkRequest struct {
+	TargetAnnotationID *uuid.UUID `json:"target_annotation_id"`
+	TargetBookID       *uuid.UUID `json:"target_book_id"`
+	TargetStart        int        `json:"target_start"`
+	TargetEnd          int        `json:"target_end"`
+	TargetText         string     `json:"target_text"` // Filled from the book when omitted
+	Relation           string     `json:"relation" binding:"required"`
+	Note               string     `json:"note"`
+}
+
+// AnnotationLinks are an annotation's links in both directions
+type AnnotationLinks struct {
+	AnnotationID uuid.UUID  `json:"annotation_id"`
+	Outgoing     []LinkView `json:"outgoing"`
+	Backlinks    []LinkView `json:"backlinks"` // Links to the annotation, or to a passage overlapping it
+}
+
+// LinkView is a link as seen from one of its ends
+type LinkView struct {
+	models.AnnotationLink
+	Direction string     `json:"direction"` // outgoing, incoming
+	Relation  string     `json:"relation"`  // As read from this end, e.g. cited_by for incoming cites
+	Other     *GraphNode `json:"other"`     // The annotation or passage at the other end
+}
+
+// AnnotationGraph is a set of annotations and passages and the links
+// between them
+type AnnotationGraph struct {
+	Nodes     []GraphNode `json:"nodes"`
+	Edges     []GraphEdge `json:"edges"`
+	Truncated bool        `json:"truncated,omitempty"` // Stopped at the node limit
+}
+
+// GraphNode is an annotation or a linked passage
+type GraphNode struct {
+	ID        string     `json:"id"`   // Annotation ID, or passage:<book>:<start>-<end>
+	Kind      string     `json:"kind"` // annotation, passage
+	BookID    uuid.UUID  `json:"book_id"`
+	BookTitle string     `json:"book_title,omitempty"`
+	Type      string     `json:"type,omitempty"` // Annotation type
+	Text      string     `json:"text,omitempty"`
+	Note      string     `json:"note,omitempty"`
+	Start     int        `json:"start"`
+	End       int        `json:"end"`
+	Depth     int        `json:"depth"` // Distance from the traversal root
+	annotID   *uuid.UUID // Set for annotation nodes
+}
+
+// GraphEdge is a link in a graph
+type GraphEdge struct {
+	ID       uuid.UUID `json:"id"`
+	Source   string    `json:"source"`
+	Target   string    `json:"target"`
+	Relation string    `json:"relation"`
+	Note     string    `json:"note,omitempty"`
+}
+
+// IsLinkRelation reports whether relation is a known link relation
+func IsLinkRelation(relation string) bool {
+	_, ok := linkInverseRelations[relation]
+	return ok
+}
+
+// CreateLink links one of the user's annotations to another of their
+// annotations, or to a passage of one of their books
+func (s *AnnotationLinkService) CreateLink(ctx context.Context, userID, sourceID uuid.UUID, req CreateLinkRequest) (*models.AnnotationLink, error) {
+	if !IsLinkRelation(req.Relation) {
+		return nil, fmt.Errorf("%w relation %q: use echoes, contradicts, cites or explains", ErrInvalid, req.Relation)
+	}
+	if (req.TargetAnnotationID == nil) == (req.TargetBookID == nil) {
+		return nil, fmt.Errorf("%w: either target_annotation_id or target_book_id is required", ErrInvalid)
+	}
+
+	db := s.db.WithContext(ctx)
+	if _, err := s.findAnnotation(db, userID, sourceID); err != nil {
+		return nil, err
+	}
+
+	link := &models.AnnotationLink{
+		UserID:             userID,
+		SourceAnnotationID: sourceID,
+		Relation:           req.Relation,
+		Note:               strings.TrimSpace(req.Note),
+	}
+	duplicate := db.Model(&models.AnnotationLink{}).
+		Where("user_id = ? AND source_annotation_id = ? AND relation = ?", userID, sourceID, req.Relation)
+
+	if req.TargetAnnotationID != nil {
+		if *req.TargetAnnotationID == sourceID {
+			return nil, fmt.Errorf("%w link: an annotation cannot link to itself", ErrInvalid)
+		}
+		if _, err := s.findAnnotation(db, userID, *req.TargetAnnotationID); err != nil {
+			return nil, fmt.Errorf("target %w", err)
+		}
+		link.TargetAnnotationID = req.TargetAnnotationID
+		duplicate = duplicate.Where("target_annotation_id = ?", *req.TargetAnnotationID)
+	} else {
+		if req.TargetStart < 0 || req.TargetEnd <= req.TargetStart {
+			return nil, fmt.Errorf("%w passage: target_end must be after target_start", ErrInvalid)
+		}
+		var book models.Book
+		result := db.Select("id").Where("id = ? AND user_id = ?", *req.TargetBookID, userID).Limit(1).Find(&book)
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to retrieve book: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil, fmt.Errorf("target book %w", ErrNotFound)
+		}
+
+		link.TargetBookID = req.TargetBookID
+		link.TargetStart, link.TargetEnd = req.TargetStart, req.TargetEnd
+		link.TargetText = strings.TrimSpace(req.TargetText)
+		var content models.BookContent
+		result = db.Select("full_text").Where("book_id = ?", book.ID).Limit(1).Find(&content)
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to retrieve book text: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			text := []rune(content.FullText)
+			if req.TargetEnd > len(text) {
+				return nil, fmt.Errorf("%w passage: the book's text has %d characters", ErrInvalid, len(text))
+			}
+			if link.TargetText == "" {
+				link.TargetText = string(text[req.TargetStart:req.TargetEnd])
+			}
+		}
+		duplicate = duplicate.Where("target_book_id = ? AND target_start = ? AND target_end = ?",
+			*req.TargetBookID, req.TargetStart, req.TargetEnd)
+	}
+
+	var count int64
+	if err := duplicate.Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check for duplicate links: %w", err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("%w: link already exists", ErrConflict)
+	}
+	if err := db.Create(link).Error; err != nil {
+		return nil, fmt.Errorf("failed to create link: %w", err)
+	}
+	return link, nil
+}
+
+// DeleteLink removes one of the user's links
+func (s *AnnotationLinkService) DeleteLink(ctx context.Context, userID, linkID uuid.UUID) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", linkID, userID).Delete(&models.AnnotationLink{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete link: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("link %w", ErrNotFound)
+	}
+	return nil
+}
+
+// Links returns an annotation's outgoing links and backlinks. Backlinks
+// include passage links into the annotation's book that overlap it.
+func (s *AnnotationLinkService) Links(ctx context.Context, userID, annotationID uuid.UUID) (*AnnotationLinks, error) {
+	db := s.db.WithContext(ctx)
+	annotation, err := s.findAnnotation(db, userID, annotationID)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []models.AnnotationLink
+	query := db.Where("user_id = ?", userID).
+		Where(db.Where("source_annotation_id = ? OR target_annotation_id = ?", annotationID, annotationID).
+			Or("target_book_id = ? AND target_start < ? AND target_end > ?", annotation.BookID, annotation.EndPosition, annotation.StartPosition))
+	if err := query.Order("created_at").Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve links: %w", err)
+	}
+
+	graph, err := s.buildGraph(db, userID, links)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]*GraphNode, len(graph.Nodes))
+	for i := range graph.Nodes {
+		nodes[graph.Nodes[i].ID] = &graph.Nodes[i]
+	}
+
+	result := &AnnotationLinks{AnnotationID: annotationID, Outgoing: []LinkView{}, Backlinks: []LinkView{}}
+	self := annotationID.String()
+	for _, link := range links {
+		source, target := linkEnds(&link)
+		if nodes[source] == nil || nodes[target] == nil {
+			continue // An end was deleted
+		}
+		if source == self {
+			result.Outgoing = append(result.Outgoing, LinkView{AnnotationLink: link, Direction: "outgoing", Relation: link.Relation, Other: nodes[target]})
+		} else {
+			result.Backlinks = append(result.Backlinks, LinkView{AnnotationLink: link, Direction: "incoming", Relation: linkInverseRelations[link.Relation], Other: nodes[source]})
+		}
+	}
+	return result, nil
+}
+
+// Graph traverses links in both directions from an annotation, up to depth
+// links away, optionally following only some relations
+func (s *AnnotationLinkService) Graph(ctx context.Context, userID, rootID uuid.UUID, depth int, relations []string) (*AnnotationGraph, error) {
+	db := s.db.WithContext(ctx)
+	if _, err := s.findAnnotation(db, userID, rootID); err != nil {
+		return nil, err
+	}
+	links, err := s.userLinks(db, userID, nil, relations)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := s.buildGraph(db, userID, links)
+	if err != nil {
+		return nil, err
+	}
+	return traverseGraph(graph, rootID.String(), depth), nil
+}
+
+// FullGraph returns all of the user's links, or those touching a book
+func (s *AnnotationLinkService) FullGraph(ctx context.Context, userID uuid.UUID, bookID *uuid.UUID, relations []string) (*AnnotationGraph, error) {
+	db := s.db.WithContext(ctx)
+	links, err := s.userLinks(db, userID, bookID, relations)
+	if err != nil {
+		return nil, err
+	}
+	return s.buildGraph(db, userID, links)
+}
+
+func (s *AnnotationLinkService) userLinks(db *gorm.DB, userID uuid.UUID, bookID *uuid.UUID, relations []string) ([]models.AnnotationLink, error) {
+	query := db.Where("user_id = ?", userID)
+	if len(relations) > 0 {
+		query = query.Where("relation IN ?", relations)
+	}
+	if bookID != nil {
+		inBook := db.Model(&models.Annotation{}).Select("id").Where("user_id = ? AND book_id = ?", userID, *bookID)
+		query = query.Where(db.Where("source_annotation_id IN (?)", inBook).
+			Or("target_annotation_id IN (?)", inBook).
+			Or("target_book_id = ?", *bookID))
+	}
+	var links []models.AnnotationLink
+	if err := query.Order("created_at").Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve links: %w", err)
+	}
+	return links, nil
+}
+
+// buildGraph loads the annotations and books at the ends of links. Links
+// to deleted annotations are left out.
+func (s *AnnotationLinkService) buildGraph(db *gorm.DB, userID uuid.UUID, links []models.AnnotationLink) (*AnnotationGraph, error) {
+	graph := &AnnotationGraph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	if len(links) == 0 {
+		return graph, nil
+	}
+
+	var annotationIDs []uuid.UUID
+	bookIDs := make(map[uuid.UUID]bool)
+	for _, link := range links {
+		annotationIDs = append(annotationIDs, link.SourceAnnotationID)
+		if link.TargetAnnotationID != nil {
+			annotationIDs = append(annotationIDs, *link.TargetAnnotationID)
+		} else if link.TargetBookID != nil {
+			bookIDs[*link.TargetBookID] = true
+		}
+	}
+	var annotations []models.Annotation
+	if err := db.Where("id IN ? AND user_id = ?", annotationIDs, userID).Find(&annotations).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve annotations: %w", err)
+	}
+	for _, a := range annotations {
+		bookIDs[a.BookID] = true
+	}
+	ids := make([]uuid.UUID, 0, len(bookIDs))
+	for id := range bookIDs {
+		ids = append(ids, id)
+	}
+	var books []models.Book
+	if err := db.Select("id", "title").Where("id IN ?", ids).Find(&books).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve books: %w", err)
+	}
+	titles := make(map[uuid.UUID]string, len(books))
+	for _, b := range books {
+		titles[b.ID] = b.Title
+	}
+
+	seen := make(map[string]bool)
+	for i := range annotations {
+		a := &annotations[i]
+		id := a.ID
+		node := GraphNode{
+			ID:        a.ID.String(),
+			Kind:      "annotation",
+			BookID:    a.BookID,
+			BookTitle: titles[a.BookID],
+			Type:      a.Type,
+			Text:      excerpt(a.SelectedText),
+			Note:      excerpt(a.Content),
+			Start:     a.StartPosition,
+			End:       a.EndPosition,
+			annotID:   &id,
+		}
+		graph.Nodes = append(graph.Nodes, node)
+		seen[node.ID] = true
+	}
+	for _, link := range links {
+		source, target := linkEnds(&link)
+		if !seen[source] {
+			continue
+		}
+		if link.TargetBookID != nil && !seen[target] {
+			if _, ok := titles[*link.TargetBookID]; !ok {
+				continue
+			}
+			graph.Nodes = append(graph.Nodes, GraphNode{
+				ID:        target,
+				Kind:      "passage",
+				BookID:    *link.TargetBookID,
+				BookTitle: titles[*link.TargetBookID],
+				Text:      excerpt(link.TargetText),
+				Start:     link.TargetStart,
+				End:       link.TargetEnd,
+			})
+			seen[target] = true
+		}
+		if !seen[target] {
+			continue
+		}
+		graph.Edges = append(graph.Edges, GraphEdge{ID: link.ID, Source: source, Target: target, Relation: link.Relation, Note: link.Note})
+	}
+	return graph, nil
+}
+
+// traverseGraph keeps the nodes within depth links of root, following
+// links in both directions
+func traverseGraph(graph *AnnotationGraph, root string, depth int) *AnnotationGraph {
+	if depth <= 0 || depth > maxGraphDepth {
+		depth = maxGraphDepth
+	}
+	neighbours := make(map[string][]string)
+	for _, e := range graph.Edges {
+		neighbours[e.Source] = append(neighbours[e.Source], e.Target)
+		neighbours[e.Target] = append(neighbours[e.Target], e.Source)
+	}
+
+	result := &AnnotationGraph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	depths := map[string]int{root: 0}
+	queue := []string{root}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if depths[id] >= depth {
+			continue
+		}
+		for _, next := range neighbours[id] {
+			if _, ok := depths[next]; ok {
+				continue
+			}
+			if len(depths) >= maxGraphNodes {
+				result.Truncated = true
+				break
+			}
+			depths[next] = depths[id] + 1
+			queue = append(queue, next)
+		}
+	}
+
+	for _, node := range graph.Nodes {
+		if d, ok := depths[node.ID]; ok {
+			node.Depth = d
+			result.Nodes = append(result.Nodes, node)
+		}
+	}
+	for _, e := range graph.Edges {
+		_, source := depths[e.Source]
+		_, target := depths[e.Target]
+		if source && target {
+			result.Edges = append(result.Edges, e)
+		}
+	}
+	return result
+}
+
+func (s *AnnotationLinkService) findAnnotation(db *gorm.DB, userID, annotationID uuid.UUID) (*models.Annotation, error) {
+	var annotation models.Annotation
+	result := db.Where("id = ? AND user_id = ?", annotationID, userID).Limit(1).Find(&annotation)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve annotation: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("annotation %w", ErrNotFound)
+	}
+	return &annotation, nil
+}
+
+// linkEnds returns the graph node IDs of a link's source and target
+func linkEnds(link *models.AnnotationLink) (string, string) {
+	source := link.SourceAnnotationID.String()
+	if link.TargetAnnotationID != nil {
+		return source, link.TargetAnnotationID.String()
+	}
+	if link.TargetBookID == nil {
+		return source, ""
+	}
+	return source, fmt.Sprintf("passage:%s:%d-%d", *link.TargetBookID, link.TargetStart, link.TargetEnd)
+}
+
+func excerpt(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > excerptLength {
+		return string(runes[:excerptLength]) + "…"
+	}
+	return text
+}
+
+// GraphML document types (http://graphml.graphdrawing.org/)
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes a graph as a directed GraphML document, which Gephi,
+// yEd and Cytoscape open
+func WriteGraphML(w io.Writer, graph *AnnotationGraph) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "kind", For: "node", Name: "kind", Type: "string"},
+			{ID: "label", For: "node", Name: "label", Type: "string"},
+			{ID: "book", For: "node", Name: "book", Type: "string"},
+			{ID: "type", For: "node", Name: "type", Type: "string"},
+			{ID: "note", For: "node", Name: "note", Type: "string"},
+			{ID: "relation", For: "edge", Name: "relation", Type: "string"},
+			{ID: "edge_note", For: "edge", Name: "note", Type: "string"},
+		},
+		Graph: graphMLGraph{ID: "annotations", EdgeDefault: "directed"},
+	}
+	for _, n := range graph.Nodes {
+		node := graphMLNode{ID: n.ID, Data: []graphMLData{
+			{Key: "kind", Value: n.Kind},
+			{Key: "label", Value: n.Text},
+			{Key: "book", Value: n.BookTitle},
+		}}
+		if n.Type != "" {
+			node.Data = append(node.Data, graphMLData{Key: "type", Value: n.Type})
+		}
+		if n.Note != "" {
+			node.Data = append(node.Data, graphMLData{Key: "note", Value: n.Note})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for _, e := range graph.Edges {
+		edge := graphMLEdge{ID: e.ID.String(), Source: e.Source, Target: e.Target, Data: []graphMLData{{Key: "relation", Value: e.Relation}}}
+		if e.Note != "" {
+			edge.Data = append(edge.Data, graphMLData{Key: "edge_note", Value: e.Note})
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, edge)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write GraphML: %w", err)
+	}
+	return enc.Flush()
+}
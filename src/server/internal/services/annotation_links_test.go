@@ -0,0 +1,90 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/models"
+)
+
+// linkTestGraph is a chain a -> b -> c -> passage, plus an unconnected d
+func linkTestGraph() (*AnnotationGraph, []string) {
+	book := uuid.New()
+	passage := &models.AnnotationLink{SourceAnnotationID: uuid.New(), TargetBookID: &book, TargetStart: 10, TargetEnd: 42}
+	_, passageID := linkEnds(passage)
+	ids := []string{uuid.NewString(), uuid.NewString(), passage.SourceAnnotationID.String(), passageID, uuid.NewString()}
+
+	graph := &AnnotationGraph{}
+	for i, id := range ids {
+		kind := "annotation"
+		if i == 3 {
+			kind = "passage"
+		}
+		graph.Nodes = append(graph.Nodes, GraphNode{ID: id, Kind: kind, BookID: book, Text: "node & <text> " + id[:4]})
+	}
+	graph.Edges = []GraphEdge{
+		{ID: uuid.New(), Source: ids[0], Target: ids[1], Relation: models.LinkEchoes},
+		{ID: uuid.New(), Source: ids[1], Target: ids[2], Relation: models.LinkContradicts, Note: "but see"},
+		{ID: uuid.New(), Source: ids[2], Target: ids[3], Relation: models.LinkCites},
+	}
+	return graph, ids
+}
+
+func TestTraverseGraph(t *testing.T) {
+	graph, ids := linkTestGraph()
+
+	// Links are followed in both directions
+	sub := traverseGraph(graph, ids[1], 1)
+	if len(sub.Nodes) != 3 || len(sub.Edges) != 2 {
+		t.Fatalf("depth 1 from b: got %d nodes, %d edges", len(sub.Nodes), len(sub.Edges))
+	}
+	for _, n := range sub.Nodes {
+		if want := map[string]int{ids[0]: 1, ids[1]: 0, ids[2]: 1}[n.ID]; n.Depth != want {
+			t.Errorf("node %s at depth %d, want %d", n.ID, n.Depth, want)
+		}
+	}
+
+	sub = traverseGraph(graph, ids[0], 3)
+	if len(sub.Nodes) != 4 || len(sub.Edges) != 3 {
+		t.Errorf("depth 3 from a: got %d nodes, %d edges", len(sub.Nodes), len(sub.Edges))
+	}
+
+	sub = traverseGraph(graph, ids[4], 2)
+	if len(sub.Nodes) != 1 || len(sub.Edges) != 0 {
+		t.Errorf("unlinked node: got %d nodes, %d edges", len(sub.Nodes), len(sub.Edges))
+	}
+}
+
+func TestWriteGraphML(t *testing.T) {
+	graph, ids := linkTestGraph()
+	var buf bytes.Buffer
+	if err := WriteGraphML(&buf, graph); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Error("missing XML header")
+	}
+
+	var doc graphML
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid GraphML: %v\n%s", err, buf.String())
+	}
+	if doc.Graph.EdgeDefault != "directed" || len(doc.Graph.Nodes) != 5 || len(doc.Graph.Edges) != 3 {
+		t.Fatalf("unexpected graph: %+v", doc.Graph)
+	}
+	if got := doc.Graph.Nodes[0].Data[1]; got.Key != "label" || got.Value != "node & <text> "+ids[0][:4] {
+		t.Errorf("label not escaped correctly: %+v", got)
+	}
+	edge := doc.Graph.Edges[1]
+	if edge.Source != ids[1] || edge.Target != ids[2] || len(edge.Data) != 2 ||
+		edge.Data[0].Value != models.LinkContradicts || edge.Data[1].Value != "but see" {
+		t.Errorf("unexpected edge: %+v", edge)
+	}
+	if !strings.HasPrefix(doc.Graph.Edges[2].Target, "passage:") {
+		t.Errorf("passage target %q", doc.Graph.Edges[2].Target)
+	}
+}
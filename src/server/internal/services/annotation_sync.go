@@ -0,0 +1,653 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/classius/server/internal/models"
+)
+
+// AnnotationSyncFields are the annotation fields merged individually during sync
+var AnnotationSyncFields = []string{
+	"type", "page_number", "start_position", "end_position",
+	"selected_text", "content", "color", "tags", "is_private", "geometry", "ink",
+}
+
+// Outcomes of a pushed annotation change
+const (
+	SyncStatusCreated   = "created"
+	SyncStatusUpdated   = "updated"
+	SyncStatusMerged    = "merged" // Applied alongside concurrent server changes
+	SyncStatusDeleted   = "deleted"
+	SyncStatusUnchanged = "unchanged"
+	SyncStatusConflict  = "conflict" // Not applied: the server version won
+	SyncStatusRejected  = "rejected"
+)
+
+// Conflict resolutions
+const (
+	ResolutionLocal  = "local"  // The device's value was kept
+	ResolutionServer = "server" // The server's value was kept
+)
+
+// AnnotationSyncService implements conflict-aware annotation sync between devices
+type AnnotationSyncService struct {
+	db *gorm.DB
+}
+
+// NewAnnotationSyncService creates a new annotation sync service
+func NewAnnotationSyncService(db *gorm.DB) *AnnotationSyncService {
+	return &AnnotationSyncService{db: db}
+}
+
+// AnnotationSyncRequest pushes local changes and pulls server changes since a cursor
+type AnnotationSyncRequest struct {
+	Cursor  string             `json:"cursor"` // Empty for a full sync
+	Changes []AnnotationChange `json:"changes"`
+	Limit   int                `json:"limit"`
+}
+
+// AnnotationChange is an annotation created, edited or deleted on a device
+type AnnotationChange struct {
+	ID            uuid.UUID `json:"id"`             // Generated by the device
+	BaseRevision  int       `json:"base_revision"`  // Server revision the edit was based on, 0 for new annotations
+	Deleted       bool      `json:"deleted"`        // Tombstone
+	ModifiedAt    time.Time `json:"modified_at"`    // When the change was made on the device
+	ChangedFields []string  `json:"changed_fields"` // Fields edited since the base revision; empty means all
+
+	BookID        uuid.UUID `json:"book_id"`
+	Type          string    `json:"type"`
+	PageNumber    int       `json:"page_number"`
+	StartPosition int       `json:"start_position"`
+	EndPosition   int       `json:"end_position"`
+	SelectedText  string    `json:"selected_text"`
+	Content       string    `json:"content"`
+	Color         string    `json:"color"`
+	Tags          []string  `json:"tags"`
+	IsPrivate     bool      `json:"is_private"`
+
+	Geometry *models.AnnotationGeometry `json:"geometry,omitempty"`
+	Ink      *models.InkDrawing         `json:"ink,omitempty"`
+
+	// Tags at the base revision, enabling a three-way merge of concurrent
+	// tag edits; without them concurrent tag edits are unioned
+	BaseTags []string `json:"base_tags,omitempty"`
+}
+
+// AnnotationChangeResult reports what happened to a pushed change
+type AnnotationChangeResult struct {
+	ID       uuid.UUID `json:"id"`
+	Status   string    `json:"status"`
+	Revision int       `json:"revision"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// AnnotationConflict is a field edited both on the device and on the server
+// since the device's base revision. The field holds Resolved; the client
+// may push another change against Revision to override it.
+type AnnotationConflict struct {
+	ID          uuid.UUID   `json:"id"`
+	Field       string      `json:"field"` // An annotation field, or "deleted" for edit/delete conflicts
+	LocalValue  interface{} `json:"local_value"`
+	ServerValue interface{} `json:"server_value"`
+	Resolved    interface{} `json:"resolved"`
+	Resolution  string      `json:"resolution"` // local or server
+	Revision    int         `json:"revision"`
+}
+
+// AnnotationSyncRecord is an annotation as sent to devices. Deleted
+// records are tombstones carrying only their identity and revision.
+type AnnotationSyncRecord struct {
+	ID            uuid.UUID  `json:"id"`
+	BookID        uuid.UUID  `json:"book_id"`
+	Revision      int        `json:"revision"`
+	Deleted       bool       `json:"deleted"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	Type          string     `json:"type,omitempty"`
+	PageNumber    int        `json:"page_number,omitempty"`
+	StartPosition int        `json:"start_position,omitempty"`
+	EndPosition   int        `json:"end_position,omitempty"`
+	SelectedText  string     `json:"selected_text,omitempty"`
+	Content       string     `json:"content,omitempty"`
+	Color         string     `json:"color,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+	IsPrivate     bool       `json:"is_private"`
+	Visibility    string     `json:"visibility,omitempty"` // Changed through the API; devices push is_private
+	Geometry      *models.AnnotationGeometry `json:"geometry,omitempty"`
+	Ink           *models.InkDrawing         `json:"ink,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// AnnotationSyncResponse carries push results, conflicts and the server delta
+type AnnotationSyncResponse struct {
+	Results   []AnnotationChangeResult `json:"results"`
+	Conflicts []AnnotationConflict     `json:"conflicts"`
+	Changes   []AnnotationSyncRecord   `json:"changes"`
+	Cursor    string                   `json:"cursor"`   // Pass back on the next sync
+	HasMore   bool                     `json:"has_more"` // More changes are waiting past Cursor
+}
+
+// RecordAnnotationChange bumps the revision of annotations changed in tx,
+// including soft-deleted ones, and records the revision in their history.
+// Their sync position is issued by the database on every write (see
+// migration 012), along with their change log entry. fields names the columns that changed; creations and
+// deletions pass none. Attachments follow their annotation's deletion or
+// restore.
+func RecordAnnotationChange(tx *gorm.DB, userID uuid.UUID, annotationIDs []uuid.UUID, fields ...string) error {
+	return recordAnnotationChange(tx, userID, annotationIDs, "", fields)
+}
+
+// recordAnnotationChange is RecordAnnotationChange with the revision
+// operation given, rather than inferred from the annotations
+func recordAnnotationChange(tx *gorm.DB, userID uuid.UUID, annotationIDs []uuid.UUID, operation string, fields []string) error {
+	if len(annotationIDs) == 0 {
+		return nil
+	}
+	updates := map[string]interface{}{
+		"revision": gorm.Expr("revision + 1"),
+	}
+	if len(fields) > 0 {
+		var pairs []string
+		for _, field := range fields {
+			pairs = append(pairs, "'"+strings.ReplaceAll(field, "'", "")+"', revision + 1")
+		}
+		updates["field_revisions"] = gorm.Expr("COALESCE(field_revisions, '{}'::jsonb) || jsonb_build_object(" + strings.Join(pairs, ", ") + ")")
+	}
+
+	if err := tx.Unscoped().Model(&models.Annotation{}).
+		Where("id IN ? AND user_id = ?", annotationIDs, userID).
+		UpdateColumns(updates).Error; err != nil {
+		return fmt.Errorf("failed to record annotation change: %w", err)
+	}
+	if err := cascadeAnnotationAttachments(tx, userID, annotationIDs); err != nil {
+		return err
+	}
+	return recordAnnotationRevisions(tx, userID, annotationIDs, operation, fields)
+}
+
+// Sync applies a device's changes and returns server changes past the cursor
+func (s *AnnotationSyncService) Sync(ctx context.Context, userID uuid.UUID, req AnnotationSyncRequest) (*AnnotationSyncResponse, error) {
+	cursorSeq, cursorID, err := decodeSyncCursor(req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &AnnotationSyncResponse{
+		Results:   []AnnotationChangeResult{},
+		Conflicts: []AnnotationConflict{},
+		Changes:   []AnnotationSyncRecord{},
+	}
+
+	for _, change := range req.Changes {
+		var conflicts []AnnotationConflict
+		var result AnnotationChangeResult
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var err error
+			result, conflicts, err = s.applyChange(tx, userID, change)
+			return err
+		})
+		if err != nil {
+			result = AnnotationChangeResult{ID: change.ID, Status: SyncStatusRejected, Error: err.Error()}
+			conflicts = nil
+		}
+		response.Results = append(response.Results, result)
+		response.Conflicts = append(response.Conflicts, conflicts...)
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 500
+	}
+	var annotations []models.Annotation
+	if err := s.db.WithContext(ctx).Unscoped().
+		Where("user_id = ? AND (sync_seq, id) > (?, ?)", userID, cursorSeq, cursorID).
+		Order("sync_seq ASC, id ASC").Limit(limit + 1).
+		Find(&annotations).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve annotation changes: %w", err)
+	}
+	if len(annotations) > limit {
+		annotations = annotations[:limit]
+		response.HasMore = true
+	}
+	for i := range annotations {
+		response.Changes = append(response.Changes, toAnnotationSyncRecord(&annotations[i]))
+	}
+
+	if n := len(annotations); n > 0 {
+		response.Cursor = encodeSyncCursor(annotations[n-1].SyncSeq, annotations[n-1].ID)
+	} else {
+		response.Cursor = encodeSyncCursor(cursorSeq, cursorID)
+	}
+
+	return response, nil
+}
+
+// applyChange merges one device change into the server copy
+func (s *AnnotationSyncService) applyChange(tx *gorm.DB, userID uuid.UUID, change AnnotationChange) (AnnotationChangeResult, []AnnotationConflict, error) {
+	result := AnnotationChangeResult{ID: change.ID}
+	if change.ID == uuid.Nil {
+		return result, nil, fmt.Errorf("%w: id is required", ErrInvalid)
+	}
+	for _, field := range change.ChangedFields {
+		if !containsString(AnnotationSyncFields, field) {
+			return result, nil, fmt.Errorf("%w changed field %q", ErrInvalid, field)
+		}
+	}
+	if change.ModifiedAt.IsZero() {
+		change.ModifiedAt = time.Now()
+	}
+
+	var existing models.Annotation
+	err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", change.ID).First(&existing).Error
+	if err == gorm.ErrRecordNotFound {
+		return s.createFromChange(tx, userID, change)
+	}
+	if err != nil {
+		return result, nil, fmt.Errorf("failed to retrieve annotation: %w", err)
+	}
+	if existing.UserID != userID {
+		return result, nil, fmt.Errorf("id %w: annotation belongs to another user", ErrConflict)
+	}
+
+	serverChanged := make(map[string]bool)
+	if change.BaseRevision < existing.Revision {
+		for field, revision := range existing.FieldRevisions {
+			if revision > change.BaseRevision {
+				serverChanged[field] = true
+			}
+		}
+	}
+
+	// Deleted on the server
+	if existing.DeletedAt.Valid {
+		if change.Deleted {
+			result.Status, result.Revision = SyncStatusUnchanged, existing.Revision
+			return result, nil, nil
+		}
+		conflict := AnnotationConflict{ID: change.ID, Field: "deleted", LocalValue: false, ServerValue: true}
+		if !change.ModifiedAt.After(existing.DeletedAt.Time) {
+			conflict.Resolved, conflict.Resolution, conflict.Revision = true, ResolutionServer, existing.Revision
+			result.Status, result.Revision = SyncStatusConflict, existing.Revision
+			return result, []AnnotationConflict{conflict}, nil
+		}
+		// Edited after the deletion: restore with the device's version
+		if err := tx.Unscoped().Model(&models.Annotation{}).Where("id = ?", existing.ID).
+			UpdateColumn("deleted_at", nil).Error; err != nil {
+			return result, nil, fmt.Errorf("failed to restore annotation: %w", err)
+		}
+		existing.DeletedAt = gorm.DeletedAt{}
+		serverChanged = nil
+		res, conflicts, err := s.updateFromChange(tx, userID, &existing, change, serverChanged)
+		if err != nil {
+			return res, nil, err
+		}
+		if res.Status == SyncStatusUnchanged {
+			// Restored as it was: the restore itself is the change
+			if err := RecordAnnotationChange(tx, userID, []uuid.UUID{existing.ID}); err != nil {
+				return res, nil, err
+			}
+			res.Status, res.Revision = SyncStatusUpdated, existing.Revision+1
+		}
+		conflict.Resolved, conflict.Resolution, conflict.Revision = false, ResolutionLocal, res.Revision
+		return res, append(conflicts, conflict), nil
+	}
+
+	// Deleted on the device
+	if change.Deleted {
+		if len(serverChanged) > 0 && existing.UpdatedAt.After(change.ModifiedAt) {
+			conflict := AnnotationConflict{
+				ID: change.ID, Field: "deleted", LocalValue: true, ServerValue: false,
+				Resolved: false, Resolution: ResolutionServer, Revision: existing.Revision,
+			}
+			result.Status, result.Revision = SyncStatusConflict, existing.Revision
+			return result, []AnnotationConflict{conflict}, nil
+		}
+		if err := tx.Delete(&models.Annotation{}, "id = ?", existing.ID).Error; err != nil {
+			return result, nil, fmt.Errorf("failed to delete annotation: %w", err)
+		}
+		if err := RecordAnnotationChange(tx, userID, []uuid.UUID{existing.ID}); err != nil {
+			return result, nil, err
+		}
+		result.Status, result.Revision = SyncStatusDeleted, existing.Revision+1
+		return result, nil, nil
+	}
+
+	return s.updateFromChange(tx, userID, &existing, change, serverChanged)
+}
+
+func (s *AnnotationSyncService) createFromChange(tx *gorm.DB, userID uuid.UUID, change AnnotationChange) (AnnotationChangeResult, []AnnotationConflict, error) {
+	result := AnnotationChangeResult{ID: change.ID}
+	if change.Deleted {
+		// Created and deleted on the device before it ever synced
+		result.Status = SyncStatusDeleted
+		return result, nil, nil
+	}
+	if !models.IsAnnotationType(change.Type) {
+		return result, nil, fmt.Errorf("%w annotation type %q", ErrInvalid, change.Type)
+	}
+	if err := ValidateAnnotationShape(change.Type, change.Geometry, change.Ink); err != nil {
+		return result, nil, err
+	}
+
+	var count int64
+	if err := tx.Model(&models.UserBook{}).
+		Where("user_id = ? AND book_id = ?", userID, change.BookID).Count(&count).Error; err != nil {
+		return result, nil, fmt.Errorf("failed to verify book access: %w", err)
+	}
+	if count == 0 {
+		return result, nil, fmt.Errorf("book %w in your library", ErrNotFound)
+	}
+
+	annotation := &models.Annotation{
+		UserID:        userID,
+		BookID:        change.BookID,
+		Type:          change.Type,
+		PageNumber:    change.PageNumber,
+		StartPosition: change.StartPosition,
+		EndPosition:   change.EndPosition,
+		SelectedText:  change.SelectedText,
+		Content:       change.Content,
+		Color:         change.Color,
+		Tags:          change.Tags,
+		IsPrivate:     change.IsPrivate,
+		Geometry:      change.Geometry,
+		Ink:           change.Ink,
+	}
+	annotation.ID = change.ID
+	if err := tx.Create(annotation).Error; err != nil {
+		return result, nil, fmt.Errorf("failed to create annotation: %w", err)
+	}
+	if err := RecordAnnotationChange(tx, userID, []uuid.UUID{annotation.ID}); err != nil {
+		return result, nil, err
+	}
+
+	result.Status, result.Revision = SyncStatusCreated, 1
+	return result, nil, nil
+}
+
+// updateFromChange applies the fields edited on the device. Fields edited
+// only on the device are applied; fields edited on both sides are merged
+// (tags) or resolved last-writer-wins and reported as conflicts.
+func (s *AnnotationSyncService) updateFromChange(tx *gorm.DB, userID uuid.UUID, existing *models.Annotation, change AnnotationChange, serverChanged map[string]bool) (AnnotationChangeResult, []AnnotationConflict, error) {
+	result := AnnotationChangeResult{ID: change.ID}
+	if err := ValidateAnnotationShape("", change.Geometry, change.Ink); err != nil {
+		return result, nil, err
+	}
+	fields := change.ChangedFields
+	if len(fields) == 0 {
+		if err := validateFullChange(existing, &change); err != nil {
+			return result, nil, err
+		}
+		fields = AnnotationSyncFields
+	} else if containsString(fields, "type") && !models.IsAnnotationType(change.Type) {
+		return result, nil, fmt.Errorf("%w annotation type %q", ErrInvalid, change.Type)
+	}
+	localWins := !existing.UpdatedAt.After(change.ModifiedAt)
+
+	updates := make(map[string]interface{})
+	var conflicts []AnnotationConflict
+	var pendingConflicts []int
+	for _, field := range fields {
+		if len(change.ChangedFields) == 0 && ((field == "geometry" && change.Geometry == nil) || (field == "ink" && change.Ink == nil)) {
+			// Devices without geometry support leave it out rather than clearing it
+			continue
+		}
+		local := annotationChangeValue(&change, field)
+		server := annotationFieldValue(existing, field)
+		if valuesEqual(local, server) {
+			continue
+		}
+		if !serverChanged[field] {
+			updates[field] = local
+			continue
+		}
+
+		if field == "tags" {
+			updates[field] = mergeTags(change.BaseTags, change.Tags, existing.Tags)
+			continue
+		}
+
+		conflict := AnnotationConflict{ID: change.ID, Field: field, LocalValue: local, ServerValue: server}
+		if localWins {
+			updates[field] = local
+			conflict.Resolved, conflict.Resolution = local, ResolutionLocal
+		} else {
+			conflict.Resolved, conflict.Resolution = server, ResolutionServer
+		}
+		pendingConflicts = append(pendingConflicts, len(conflicts))
+		conflicts = append(conflicts, conflict)
+	}
+	if tags, ok := updates["tags"]; ok && valuesEqual(tags, existing.Tags) {
+		delete(updates, "tags")
+	}
+
+	revision := existing.Revision
+	if len(updates) > 0 {
+		if err := tx.Model(&models.Annotation{}).Where("id = ?", existing.ID).Updates(updates).Error; err != nil {
+			return result, nil, fmt.Errorf("failed to update annotation: %w", err)
+		}
+		changed := make([]string, 0, len(updates))
+		for field := range updates {
+			changed = append(changed, field)
+		}
+		sort.Strings(changed)
+		if err := RecordAnnotationChange(tx, userID, []uuid.UUID{existing.ID}, changed...); err != nil {
+			return result, nil, err
+		}
+		revision++
+	}
+	for _, i := range pendingConflicts {
+		conflicts[i].Revision = revision
+	}
+
+	switch {
+	case len(updates) == 0 && len(conflicts) > 0:
+		result.Status = SyncStatusConflict
+	case len(updates) == 0:
+		result.Status = SyncStatusUnchanged
+	case len(serverChanged) > 0:
+		result.Status = SyncStatusMerged
+	default:
+		result.Status = SyncStatusUpdated
+	}
+	result.Revision = revision
+	return result, conflicts, nil
+}
+
+// validateFullChange checks a change pushed without changed fields, which
+// replaces every sync field. It must carry the whole annotation: a device
+// that left fields out would otherwise clear them.
+func validateFullChange(existing *models.Annotation, change *AnnotationChange) error {
+	if change.BookID == uuid.Nil || change.Type == "" {
+		return fmt.Errorf("%w: book_id and type are required when changed_fields is empty", ErrInvalid)
+	}
+	if change.BookID != existing.BookID {
+		return fmt.Errorf("%w book_id: annotation belongs to another book", ErrInvalid)
+	}
+	if !models.IsAnnotationType(change.Type) {
+		return fmt.Errorf("%w annotation type %q", ErrInvalid, change.Type)
+	}
+	return nil
+}
+
+// mergeTags merges concurrent tag edits. With the base tags it applies the
+// device's additions and removals to the server's tags; without them it
+// takes the union.
+func mergeTags(base, local, server []string) []string {
+	set := make(map[string]bool)
+	var merged []string
+	add := func(tag string) {
+		if !set[tag] {
+			set[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+
+	if base == nil {
+		for _, tag := range server {
+			add(tag)
+		}
+		for _, tag := range local {
+			add(tag)
+		}
+		return merged
+	}
+
+	removed := make(map[string]bool)
+	for _, tag := range base {
+		if !containsString(local, tag) {
+			removed[tag] = true
+		}
+	}
+	for _, tag := range server {
+		if !removed[tag] {
+			add(tag)
+		}
+	}
+	for _, tag := range local {
+		if !containsString(base, tag) {
+			add(tag)
+		}
+	}
+	if merged == nil {
+		merged = []string{}
+	}
+	return merged
+}
+
+func annotationFieldValue(a *models.Annotation, field string) interface{} {
+	switch field {
+	case "type":
+		return a.Type
+	case "page_number":
+		return a.PageNumber
+	case "start_position":
+		return a.StartPosition
+	case "end_position":
+		return a.EndPosition
+	case "selected_text":
+		return a.SelectedText
+	case "content":
+		return a.Content
+	case "color":
+		return a.Color
+	case "tags":
+		return a.Tags
+	case "is_private":
+		return a.IsPrivate
+	case "geometry":
+		return a.Geometry
+	case "ink":
+		return a.Ink
+	}
+	return nil
+}
+
+func annotationChangeValue(c *AnnotationChange, field string) interface{} {
+	switch field {
+	case "type":
+		return c.Type
+	case "page_number":
+		return c.PageNumber
+	case "start_position":
+		return c.StartPosition
+	case "end_position":
+		return c.EndPosition
+	case "selected_text":
+		return c.SelectedText
+	case "content":
+		return c.Content
+	case "color":
+		return c.Color
+	case "tags":
+		return c.Tags
+	case "is_private":
+		return c.IsPrivate
+	case "geometry":
+		return c.Geometry
+	case "ink":
+		return c.Ink
+	}
+	return nil
+}
+
+// valuesEqual compares field values, treating nil and empty tag lists alike
+func valuesEqual(a, b interface{}) bool {
+	if ta, ok := a.([]string); ok {
+		tb, _ := b.([]string)
+		if len(ta) == 0 && len(tb) == 0 {
+			return true
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toAnnotationSyncRecord(a *models.Annotation) AnnotationSyncRecord {
+	record := AnnotationSyncRecord{
+		ID:        a.ID,
+		BookID:    a.BookID,
+		Revision:  a.Revision,
+		CreatedAt: a.CreatedAt,
+		UpdatedAt: a.UpdatedAt,
+	}
+	if a.DeletedAt.Valid {
+		deletedAt := a.DeletedAt.Time
+		record.Deleted = true
+		record.DeletedAt = &deletedAt
+		return record
+	}
+	record.Type = a.Type
+	record.PageNumber = a.PageNumber
+	record.StartPosition = a.StartPosition
+	record.EndPosition = a.EndPosition
+	record.SelectedText = a.SelectedText
+	record.Content = a.Content
+	record.Color = a.Color
+	record.Tags = a.Tags
+	record.IsPrivate = a.IsPrivate
+	record.Visibility = a.Visibility
+	record.Geometry = a.Geometry
+	record.Ink = a.Ink
+	return record
+}
+
+// Sync cursors are opaque to clients: the sync sequence and ID of the last
+// record delivered, so records sharing a sequence are never skipped
+func encodeSyncCursor(seq int64, id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(seq, 10) + ":" + id.String()))
+}
+
+func decodeSyncCursor(cursor string) (int64, uuid.UUID, error) {
+	if cursor == "" {
+		return -1, uuid.Nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, uuid.Nil, fmt.Errorf("%w cursor", ErrInvalid)
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return 0, uuid.Nil, fmt.Errorf("%w cursor", ErrInvalid)
+	}
+	seq, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, uuid.Nil, fmt.Errorf("%w cursor", ErrInvalid)
+	}
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return 0, uuid.Nil, fmt.Errorf("%w cursor", ErrInvalid)
+	}
+	return seq, id, nil
+}
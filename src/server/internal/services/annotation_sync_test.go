@@ -0,0 +1,96 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestMergeTags(t *testing.T) {
+	tests := []struct {
+		name             string
+		base, local, srv []string
+		want             []string
+	}{
+		{"union without base", nil, []string{"a", "b"}, []string{"b", "c"}, []string{"b", "c", "a"}},
+		{"device adds", []string{"a"}, []string{"a", "b"}, []string{"a", "c"}, []string{"a", "c", "b"}},
+		{"device removes", []string{"a", "b"}, []string{"b"}, []string{"a", "b", "c"}, []string{"b", "c"}},
+		{"both remove", []string{"a"}, []string{}, []string{}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := mergeTags(tt.base, tt.local, tt.srv); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSyncCursor(t *testing.T) {
+	id := uuid.New()
+	seq, gotID, err := decodeSyncCursor(encodeSyncCursor(42, id))
+	if err != nil || seq != 42 || gotID != id {
+		t.Errorf("round trip = (%d, %s, %v), want (42, %s)", seq, gotID, err, id)
+	}
+
+	if seq, _, err := decodeSyncCursor(""); err != nil || seq != -1 {
+		t.Errorf("empty cursor = (%d, %v), want a full sync", seq, err)
+	}
+	if _, _, err := decodeSyncCursor("not a cursor"); err == nil {
+		t.Error("expected an error for a malformed cursor")
+	}
+}
+
+func TestValidateFullChange(t *testing.T) {
+	existing := &models.Annotation{BookID: uuid.New(), Type: "highlight"}
+	tests := []struct {
+		name   string
+		change AnnotationChange
+		want   error
+	}{
+		{"complete", AnnotationChange{BookID: existing.BookID, Type: "note"}, nil},
+		{"fields left out", AnnotationChange{Content: "edited"}, ErrInvalid},
+		{"other book", AnnotationChange{BookID: uuid.New(), Type: "note"}, ErrInvalid},
+		{"unknown type", AnnotationChange{BookID: existing.BookID, Type: "scribble"}, ErrInvalid},
+	}
+	for _, tt := range tests {
+		if err := validateFullChange(existing, &tt.change); !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRecordAnnotationChangeSyncSeq(t *testing.T) {
+	tx := testDB(t)
+	user := createTestUser(t, tx, "reader", nil)
+	annotation := createTestAnnotation(t, tx, user, createTestBook(t, tx, user), models.VisibilityPrivate)
+
+	var before models.SyncCounter
+	if err := tx.First(&before, "user_id = ?", user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordAnnotationChange(tx, user, []uuid.UUID{annotation.ID}, "note"); err != nil {
+		t.Fatal(err)
+	}
+
+	var after models.SyncCounter
+	var stored models.Annotation
+	var entry models.ChangeLogEntry
+	if err := tx.First(&after, "user_id = ?", user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.First(&stored, "id = ?", annotation.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.First(&entry, "user_id = ? AND entity_type = ? AND entity_id = ?", user, "annotation", annotation.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if after.Seq != before.Seq+1 {
+		t.Errorf("recording a change issued %d sequence numbers, want 1", after.Seq-before.Seq)
+	}
+	if stored.SyncSeq != after.Seq || entry.Seq != after.Seq {
+		t.Errorf("annotation sync_seq = %d, change log seq = %d, want both %d", stored.SyncSeq, entry.Seq, after.Seq)
+	}
+}
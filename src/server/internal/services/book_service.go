@@ -172,7 +172,7 @@ func (s *BookService) GetBook(ctx context.Context, userID, bookID uuid.UUID) (*m
 		Where("id = ? AND user_id = ?", bookID, userID).
 		First(&book).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("book not found")
+			return nil, fmt.Errorf("book %w", ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to retrieve book: %w", err)
 	}
@@ -224,13 +224,17 @@ func (s *BookService) GetBooks(ctx context.Context, filter *models.BookFilter) (
 		query = query.Where("created_at <= ?", *filter.CreatedBefore)
 	}
 
-	// Handle tag filtering
+	// Handle tag filtering: books with every tag, or one of its descendants
 	if len(filter.Tags) > 0 {
-		query = query.Joins("JOIN book_tags ON books.id = book_tags.book_id").
-			Joins("JOIN tags ON book_tags.tag_id = tags.id").
-			Where("tags.name IN ?", filter.Tags).
-			Group("books.id").
-			Having("COUNT(DISTINCT tags.id) = ?", len(filter.Tags))
+		tags, err := ResolveTags(s.db.WithContext(ctx), filter.UserID, filter.Tags)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			exact, pattern := TagScope(tag)
+			query = query.Where(`EXISTS (SELECT 1 FROM book_tags bt JOIN tags t ON t.id = bt.tag_id
+				WHERE bt.book_id = books.id AND t.deleted_at IS NULL AND (t.name = ? OR t.name LIKE ?))`, exact, pattern)
+		}
 	}
 
 	// Count total records
@@ -303,6 +307,21 @@ func (s *BookService) UpdateBook(ctx context.Context, userID, bookID uuid.UUID,
 		}
 	}()
 
+	if err := s.applyBookUpdate(tx, book, userID, req); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Return updated book
+	return s.GetBook(ctx, userID, bookID)
+}
+
+// applyBookUpdate applies a metadata and tag update to a book within tx
+func (s *BookService) applyBookUpdate(tx *gorm.DB, book *models.Book, userID uuid.UUID, req *BookUpdateRequest) error {
 	// Update fields if provided
 	updates := make(map[string]interface{})
 	if req.Title != nil {
@@ -339,34 +358,26 @@ func (s *BookService) UpdateBook(ctx context.Context, userID, bookID uuid.UUID,
 	// Apply updates
 	if len(updates) > 0 {
 		if err := tx.Model(book).Updates(updates).Error; err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("failed to update book: %w", err)
+			return fmt.Errorf("failed to update book: %w", err)
 		}
 	}
 
 	// Handle tags update if provided
 	if req.Tags != nil {
 		// Clear existing tags
-		if err := tx.Exec("DELETE FROM book_tags WHERE book_id = ?", bookID).Error; err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("failed to clear existing tags: %w", err)
+		if err := tx.Exec("DELETE FROM book_tags WHERE book_id = ?", book.ID).Error; err != nil {
+			return fmt.Errorf("failed to clear existing tags: %w", err)
 		}
 
 		// Add new tags
 		if len(req.Tags) > 0 {
-			if err := s.handleBookTags(tx, userID, bookID, req.Tags); err != nil {
-				tx.Rollback()
-				return nil, fmt.Errorf("failed to handle tags: %w", err)
+			if err := s.handleBookTags(tx, userID, book.ID, req.Tags); err != nil {
+				return fmt.Errorf("failed to handle tags: %w", err)
 			}
 		}
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	// Return updated book
-	return s.GetBook(ctx, userID, bookID)
+	return nil
 }
 
 // DeleteBook deletes a book and its associated file
@@ -394,15 +405,19 @@ func (s *BookService) DeleteBook(ctx context.Context, userID, bookID uuid.UUID)
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	// Delete file from disk
+	s.removeBookFile(book)
+
+	return nil
+}
+
+// removeBookFile deletes a deleted book's file from disk
+func (s *BookService) removeBookFile(book *models.Book) {
 	if book.FilePath != "" {
 		if err := os.Remove(book.FilePath); err != nil {
 			// Log error but don't fail the operation
 			fmt.Printf("Warning: failed to delete file %s: %v\n", book.FilePath, err)
 		}
 	}
-
-	return nil
 }
 
 // GetBookStats returns statistics about a user's book collection
@@ -593,34 +608,20 @@ func (s *BookService) extractMetadata(filePath, fileType string) (*models.BookMe
 
 // handleBookTags creates or associates tags with a book
 func (s *BookService) handleBookTags(tx *gorm.DB, userID, bookID uuid.UUID, tagNames []string) error {
-	for _, tagName := range tagNames {
-		if strings.TrimSpace(tagName) == "" {
-			continue
-		}
-
-		var tag models.Tag
-		// Find or create tag
-		if err := tx.Where("user_id = ? AND name = ?", userID, tagName).
-			First(&tag).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				// Create new tag
-				tag = models.Tag{
-					ID:     uuid.New(),
-					UserID: userID,
-					Name:   tagName,
-				}
-				if err := tx.Create(&tag).Error; err != nil {
-					return fmt.Errorf("failed to create tag %s: %w", tagName, err)
-				}
-			} else {
-				return fmt.Errorf("failed to find tag %s: %w", tagName, err)
-			}
-		}
+	names, err := ResolveTags(tx, userID, tagNames)
+	if err != nil {
+		return err
+	}
+	tags, err := RegisterTags(tx, userID, names)
+	if err != nil {
+		return err
+	}
 
+	for _, tag := range tags {
 		// Associate tag with book
 		if err := tx.Exec("INSERT INTO book_tags (book_id, tag_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
 			bookID, tag.ID).Error; err != nil {
-			return fmt.Errorf("failed to associate tag %s with book: %w", tagName, err)
+			return fmt.Errorf("failed to associate tag %s with book: %w", tag.Name, err)
 		}
 	}
 	return nil
@@ -665,12 +666,18 @@ func (s *BookService) processBookInBackground(bookID uuid.UUID) {
 		"status":              models.BookStatusActive,
 		"metadata.has_images": content.HasImages,
 		"metadata.has_toc":    content.HasTOC,
+		"text_fingerprint":    TextFingerprint(content.Text),
 	}
 
 	if err := s.db.Model(&models.Book{}).Where("id = ?", bookID).Updates(updates).Error; err != nil {
 		fmt.Printf("Failed to update book %s metadata: %v\n", bookID, err)
 	}
 
+	// Build the concordance index used for word lookups
+	if _, err := NewConcordanceService(s.db).BuildIndex(context.Background(), bookID); err != nil {
+		fmt.Printf("Warning: failed to build concordance index for %s: %v\n", bookID, err)
+	}
+
 	fmt.Printf("Successfully processed book %s: %d pages, %d words\n", bookID, content.PageCount, content.WordCount)
 }
 
@@ -689,7 +696,7 @@ func (s *BookService) GetBookContent(ctx context.Context, userID, bookID uuid.UU
 		Where("id = ? AND user_id = ?", bookID, userID).
 		First(&book).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("book not found")
+			return nil, fmt.Errorf("book %w", ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to retrieve book: %w", err)
 	}
@@ -700,7 +707,7 @@ func (s *BookService) GetBookContent(ctx context.Context, userID, bookID uuid.UU
 		Where("book_id = ?", bookID).
 		First(&content).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("book content not yet extracted")
+			return nil, fmt.Errorf("book content %w", ErrNotExtracted)
 		}
 		return nil, fmt.Errorf("failed to retrieve book content: %w", err)
 	}
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/classius/server/internal/models"
+)
+
+// DefaultBulkUndoRetention is how long bulk operations can be undone
+const DefaultBulkUndoRetention = 7 * 24 * time.Hour
+
+// Bulk annotation actions
+const (
+	BulkActionDelete        = "delete"
+	BulkActionUpdateTags    = "update_tags"
+	BulkActionUpdateColor   = "update_color"
+	BulkActionTogglePrivate = "toggle_private"
+)
+
+// BulkOperationService applies bulk annotation actions, recording each
+// with the state it replaced so it can be undone
+type BulkOperationService struct {
+	db        *gorm.DB
+	retention time.Duration
+}
+
+// NewBulkOperationService creates a new bulk operation service. Operations
+// can be undone for retention, or DefaultBulkUndoRetention if it is zero.
+func NewBulkOperationService(db *gorm.DB, retention time.Duration) *BulkOperationService {
+	if retention <= 0 {
+		retention = DefaultBulkUndoRetention
+	}
+	return &BulkOperationService{db: db, retention: retention}
+}
+
+// BulkActionRequest represents bulk operations on annotations
+type BulkActionRequest struct {
+	AnnotationIDs []string               `json:"annotation_ids" binding:"required"`
+	Action        string                 `json:"action" binding:"required"` // delete, update_tags, update_color, toggle_private
+	Parameters    map[string]interface{} `json:"parameters,omitempty"`
+	DryRun        bool                   `json:"dry_run"` // Report the annotations that would change without changing them
+}
+
+// BulkActionResponse represents the result of bulk operations
+type BulkActionResponse struct {
+	Success     int                  `json:"success"`
+	Failed      int                  `json:"failed"`
+	Errors      []string             `json:"errors,omitempty"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	OperationID *uuid.UUID           `json:"operation_id,omitempty"` // For undo
+	ExpiresAt   *time.Time           `json:"expires_at,omitempty"`   // End of the undo window
+	DryRun      bool                 `json:"dry_run,omitempty"`
+	Affected    []AffectedAnnotation `json:"affected,omitempty"` // Dry runs only
+}
+
+// AffectedAnnotation is an annotation a bulk action or undo would change
+type AffectedAnnotation struct {
+	ID           uuid.UUID     `json:"id"`
+	BookID       uuid.UUID     `json:"book_id"`
+	Type         string        `json:"type"`
+	SelectedText string        `json:"selected_text,omitempty"`
+	Changes      []FieldChange `json:"changes"`
+}
+
+// BulkUndoResponse reports the outcome of undoing a bulk operation
+type BulkUndoResponse struct {
+	OperationID uuid.UUID            `json:"operation_id"`
+	Action      string               `json:"action"`
+	Restored    int                  `json:"restored"`
+	Skipped     []BulkUndoSkip       `json:"skipped"`
+	DryRun      bool                 `json:"dry_run,omitempty"`
+	Affected    []AffectedAnnotation `json:"affected,omitempty"` // Dry runs only
+}
+
+// BulkUndoSkip is an annotation an undo left alone
+type BulkUndoSkip struct {
+	AnnotationID uuid.UUID `json:"annotation_id"`
+	Reason       string    `json:"reason"`
+}
+
+// Apply performs a bulk action, or reports what it would change on a dry run
+func (s *BulkOperationService) Apply(ctx context.Context, userID uuid.UUID, req BulkActionRequest) (*BulkActionResponse, error) {
+	response := &BulkActionResponse{
+		UpdatedAt: time.Now(),
+		DryRun:    req.DryRun,
+	}
+
+	annotationIDs := make([]uuid.UUID, 0, len(req.AnnotationIDs))
+	for _, id := range req.AnnotationIDs {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("%w annotation ID: %s", ErrInvalid, id)
+		}
+		annotationIDs = append(annotationIDs, parsed)
+	}
+
+	// Verify all annotations belong to the user, capturing their state
+	db := s.db.WithContext(ctx)
+	var prior []models.Annotation
+	if err := db.Where("id IN ? AND user_id = ?", annotationIDs, userID).Find(&prior).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve annotations: %w", err)
+	}
+	if len(prior) != len(req.AnnotationIDs) {
+		return nil, fmt.Errorf("some annotations %w or not accessible", ErrNotFound)
+	}
+
+	var tags []string
+	var color string
+	switch req.Action {
+	case BulkActionDelete, BulkActionTogglePrivate:
+	case BulkActionUpdateTags:
+		values, ok := req.Parameters["tags"].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w tags parameter", ErrInvalid)
+		}
+		var names []string
+		for _, tag := range values {
+			if tagStr, ok := tag.(string); ok {
+				names = append(names, tagStr)
+			}
+		}
+		var err error
+		if tags, err = ResolveTags(db, userID, names); err != nil {
+			return nil, err
+		}
+	case BulkActionUpdateColor:
+		var ok bool
+		if color, ok = req.Parameters["color"].(string); !ok {
+			return nil, fmt.Errorf("%w color parameter", ErrInvalid)
+		}
+	default:
+		return nil, fmt.Errorf("%w bulk action: %s", ErrInvalid, req.Action)
+	}
+
+	if req.DryRun {
+		response.Affected = []AffectedAnnotation{}
+		for i := range prior {
+			a := &prior[i]
+			before := annotationRevision(a, "", nil)
+			after := before
+			switch req.Action {
+			case BulkActionDelete:
+				after.Deleted = true
+			case BulkActionUpdateTags:
+				after.Tags = tags
+			case BulkActionUpdateColor:
+				after.Color = color
+			case BulkActionTogglePrivate:
+				after.IsPrivate = !a.IsPrivate
+			}
+			if changes := revisionChanges(&before, &after); len(changes) > 0 {
+				response.Affected = append(response.Affected, affectedAnnotation(a, changes))
+			}
+		}
+		response.Success = len(response.Affected)
+		return response, nil
+	}
+
+	operation := models.BulkOperation{
+		UserID:     userID,
+		Action:     req.Action,
+		Parameters: req.Parameters,
+		ExpiresAt:  time.Now().Add(s.retention),
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Capture the state being replaced, locked against concurrent changes
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ? AND user_id = ?", annotationIDs, userID).Find(&prior).Error; err != nil {
+			return fmt.Errorf("failed to retrieve annotations: %w", err)
+		}
+		for _, a := range prior {
+			operation.Items = append(operation.Items, models.BulkOperationItem{
+				AnnotationID: a.ID,
+				Revision:     a.Revision + 1,
+				Tags:         nonNilStrings(a.Tags),
+				Color:        a.Color,
+				IsPrivate:    a.IsPrivate,
+			})
+		}
+		operation.AnnotationCount = len(operation.Items)
+
+		var result *gorm.DB
+		var fields []string
+		scope := tx.Model(&models.Annotation{}).Where("id IN ? AND user_id = ?", annotationIDs, userID)
+		switch req.Action {
+		case BulkActionDelete:
+			result = tx.Where("id IN ? AND user_id = ?", annotationIDs, userID).Delete(&models.Annotation{})
+		case BulkActionUpdateTags:
+			if _, err := RegisterTags(tx, userID, tags); err != nil {
+				return err
+			}
+			result, fields = scope.Update("tags", tags), []string{"tags"}
+		case BulkActionUpdateColor:
+			result, fields = scope.Update("color", color), []string{"color"}
+		case BulkActionTogglePrivate:
+			result, fields = scope.Update("is_private", gorm.Expr("NOT is_private")), []string{"is_private"}
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		response.Success = int(result.RowsAffected)
+		if err := RecordAnnotationChange(tx, userID, annotationIDs, fields...); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&operation).Error; err != nil {
+			return fmt.Errorf("failed to record bulk operation: %w", err)
+		}
+		// Forget operations that can no longer be undone
+		return tx.Unscoped().Where("user_id = ? AND expires_at < ?", userID, time.Now()).Delete(&models.BulkOperation{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response.OperationID, response.ExpiresAt = &operation.ID, &operation.ExpiresAt
+	return response, nil
+}
+
+// Undo reverts a bulk operation. Annotations changed again since the
+// operation keep their newer state and are reported as skipped.
+func (s *BulkOperationService) Undo(ctx context.Context, userID, operationID uuid.UUID, dryRun bool) (*BulkUndoResponse, error) {
+	var response *BulkUndoResponse
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var operation models.BulkOperation
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", operationID, userID).Limit(1).Find(&operation)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve operation: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("operation %w", ErrNotFound)
+		}
+		if operation.UndoneAt != nil {
+			return fmt.Errorf("%w: operation already undone", ErrConflict)
+		}
+		if time.Now().After(operation.ExpiresAt) {
+			return fmt.Errorf("operation %w and can no longer be undone", ErrExpired)
+		}
+
+		response = &BulkUndoResponse{OperationID: operation.ID, Action: operation.Action, Skipped: []BulkUndoSkip{}, DryRun: dryRun}
+		ids := make([]uuid.UUID, 0, len(operation.Items))
+		for _, item := range operation.Items {
+			ids = append(ids, item.AnnotationID)
+		}
+		var annotations []models.Annotation
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ? AND user_id = ?", ids, userID).Find(&annotations).Error; err != nil {
+			return fmt.Errorf("failed to retrieve annotations: %w", err)
+		}
+		current := make(map[uuid.UUID]*models.Annotation, len(annotations))
+		for i := range annotations {
+			current[annotations[i].ID] = &annotations[i]
+		}
+
+		var restored []uuid.UUID
+		var affected []AffectedAnnotation
+		for _, item := range operation.Items {
+			a, ok := current[item.AnnotationID]
+			if !ok {
+				response.Skipped = append(response.Skipped, BulkUndoSkip{AnnotationID: item.AnnotationID, Reason: "annotation no longer exists"})
+				continue
+			}
+			if reason := undoConflict(&operation, &item, a); reason != "" {
+				response.Skipped = append(response.Skipped, BulkUndoSkip{AnnotationID: item.AnnotationID, Reason: reason})
+				continue
+			}
+
+			before := annotationRevision(a, "", nil)
+			after := before
+			updates := map[string]interface{}{}
+			switch operation.Action {
+			case BulkActionDelete:
+				after.Deleted = false
+			case BulkActionUpdateTags:
+				after.Tags, updates["tags"] = item.Tags, item.Tags
+			case BulkActionUpdateColor:
+				after.Color, updates["color"] = item.Color, item.Color
+			case BulkActionTogglePrivate:
+				after.IsPrivate, updates["is_private"] = item.IsPrivate, item.IsPrivate
+			}
+			changes := revisionChanges(&before, &after)
+			if len(changes) == 0 {
+				continue
+			}
+			affected = append(affected, affectedAnnotation(a, changes))
+			if dryRun || len(updates) == 0 {
+				restored = append(restored, a.ID)
+				continue
+			}
+			if err := tx.Unscoped().Model(&models.Annotation{}).Where("id = ?", a.ID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to undo operation: %w", err)
+			}
+			restored = append(restored, a.ID)
+		}
+		response.Restored = len(restored)
+
+		if dryRun {
+			response.Affected = affected
+			if response.Affected == nil {
+				response.Affected = []AffectedAnnotation{}
+			}
+			return nil
+		}
+
+		if operation.Action == BulkActionDelete {
+			if len(restored) > 0 {
+				if err := tx.Unscoped().Model(&models.Annotation{}).Where("id IN ?", restored).
+					Update("deleted_at", nil).Error; err != nil {
+					return fmt.Errorf("failed to undo operation: %w", err)
+				}
+			}
+			if err := recordAnnotationChange(tx, userID, restored, models.RevisionRestore, nil); err != nil {
+				return err
+			}
+		} else if err := RecordAnnotationChange(tx, userID, restored, bulkActionField(operation.Action)); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(&operation).Update("undone_at", &now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// undoConflict returns why an annotation cannot be reverted, or "" if it
+// can: it must still hold the values the operation gave it
+func undoConflict(operation *models.BulkOperation, item *models.BulkOperationItem, a *models.Annotation) string {
+	if operation.Action == BulkActionDelete {
+		if !a.DeletedAt.Valid {
+			return "annotation was restored since"
+		}
+		if a.Revision != item.Revision {
+			return "annotation was changed since"
+		}
+		return ""
+	}
+	if a.DeletedAt.Valid {
+		return "annotation was deleted since"
+	}
+	if a.FieldRevisions[bulkActionField(operation.Action)] > item.Revision {
+		return "annotation was changed since"
+	}
+	return ""
+}
+
+// bulkActionField is the column a bulk action changes
+func bulkActionField(action string) string {
+	switch action {
+	case BulkActionUpdateTags:
+		return "tags"
+	case BulkActionUpdateColor:
+		return "color"
+	case BulkActionTogglePrivate:
+		return "is_private"
+	}
+	return ""
+}
+
+func affectedAnnotation(a *models.Annotation, changes []FieldChange) AffectedAnnotation {
+	text := a.SelectedText
+	if runes := []rune(text); len(runes) > 100 {
+		text = string(runes[:100]) + "…"
+	}
+	return AffectedAnnotation{ID: a.ID, BookID: a.BookID, Type: a.Type, SelectedText: text, Changes: changes}
+}
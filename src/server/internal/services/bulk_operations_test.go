@@ -0,0 +1,38 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestUndoConflict(t *testing.T) {
+	deleted := gorm.DeletedAt{Time: time.Now(), Valid: true}
+	item := &models.BulkOperationItem{Revision: 4}
+
+	del := &models.BulkOperation{Action: BulkActionDelete}
+	cases := []struct {
+		name       string
+		operation  *models.BulkOperation
+		annotation models.Annotation
+		conflict   bool
+	}{
+		{"still deleted", del, models.Annotation{Revision: 4, BaseModel: models.BaseModel{DeletedAt: deleted}}, false},
+		{"restored since", del, models.Annotation{Revision: 5}, true},
+		{"deleted again", del, models.Annotation{Revision: 6, BaseModel: models.BaseModel{DeletedAt: deleted}}, true},
+		{"tags untouched since", &models.BulkOperation{Action: BulkActionUpdateTags},
+			models.Annotation{Revision: 7, FieldRevisions: map[string]int{"tags": 4, "content": 7}}, false},
+		{"tags edited since", &models.BulkOperation{Action: BulkActionUpdateTags},
+			models.Annotation{Revision: 7, FieldRevisions: map[string]int{"tags": 7}}, true},
+		{"deleted since", &models.BulkOperation{Action: BulkActionUpdateColor},
+			models.Annotation{Revision: 5, BaseModel: models.BaseModel{DeletedAt: deleted}}, true},
+	}
+	for _, tc := range cases {
+		if got := undoConflict(tc.operation, item, &tc.annotation) != ""; got != tc.conflict {
+			t.Errorf("%s: conflict = %v, want %v", tc.name, got, tc.conflict)
+		}
+	}
+}
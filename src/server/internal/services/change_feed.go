@@ -0,0 +1,874 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/classius/server/internal/models"
+)
+
+// ChangeFeedEntityTypes are the entity types carried by the change feed
+var ChangeFeedEntityTypes = []string{
+	models.EntityAnnotation, models.EntityBook, models.EntityBookmark,
+	models.EntityReadingProgress, models.EntityReadingSession,
+	models.EntityTag, models.EntityPreferences,
+}
+
+// ChangeFeedService serves the unified change feed that devices use to sync
+// a user's library, reading state and annotations. Changes are recorded in
+// the change log by database triggers, in the transaction making them.
+type ChangeFeedService struct {
+	db             *gorm.DB
+	annotationSync *AnnotationSyncService
+	bookService    *BookService
+}
+
+// NewChangeFeedService creates a new change feed service
+func NewChangeFeedService(db *gorm.DB, bookService *BookService) *ChangeFeedService {
+	return &ChangeFeedService{
+		db:             db,
+		annotationSync: NewAnnotationSyncService(db),
+		bookService:    bookService,
+	}
+}
+
+// ChangeFeedEntry is the latest change to one entity
+type ChangeFeedEntry struct {
+	Seq       int64       `json:"seq"`
+	Type      string      `json:"type"`
+	ID        uuid.UUID   `json:"id"`
+	Operation string      `json:"operation"` // upsert, or delete for tombstones
+	ChangedAt time.Time   `json:"changed_at"`
+	Data      interface{} `json:"data,omitempty"` // Current state; absent for tombstones
+}
+
+// ChangeFeedResponse is a page of the change feed
+type ChangeFeedResponse struct {
+	Changes []ChangeFeedEntry `json:"changes"`
+	Cursor  string            `json:"cursor"`   // Pass back on the next request
+	HasMore bool              `json:"has_more"` // More changes are waiting past Cursor
+}
+
+// EntityMutation is an entity created, edited or deleted on a device
+type EntityMutation struct {
+	Type       string          `json:"type" binding:"required"`
+	ID         uuid.UUID       `json:"id"`          // Generated by the device for new entities; ignored for preferences
+	Operation  string          `json:"operation"`   // upsert (default) or delete
+	BaseSeq    int64           `json:"base_seq"`    // Seq of the last change to the entity the device has seen, 0 if none
+	ModifiedAt time.Time       `json:"modified_at"` // When the change was made on the device
+	Data       json.RawMessage `json:"data"`        // The entity's sync record; a BookUpdateRequest for books, an AnnotationChange for annotations
+}
+
+// PushRequest is a batch of device mutations
+type PushRequest struct {
+	Mutations []EntityMutation `json:"mutations" binding:"required"`
+}
+
+// MutationResult reports what happened to a pushed mutation
+type MutationResult struct {
+	Type      string               `json:"type"`
+	ID        uuid.UUID            `json:"id"`
+	Status    string               `json:"status"`
+	Seq       int64                `json:"seq,omitempty"` // Seq of the entity's latest change after the push
+	Error     string               `json:"error,omitempty"`
+	Conflicts []AnnotationConflict `json:"conflicts,omitempty"`
+	Current   interface{}          `json:"current,omitempty"` // The server's state when the mutation lost a conflict
+}
+
+// PushResponse carries the result of each pushed mutation, in order
+type PushResponse struct {
+	Results []MutationResult `json:"results"`
+}
+
+// BookSyncRecord is a book as sent to devices
+type BookSyncRecord struct {
+	ID          uuid.UUID         `json:"id"`
+	Title       string            `json:"title"`
+	Author      string            `json:"author"`
+	Language    string            `json:"language"`
+	Genre       string            `json:"genre,omitempty"`
+	Publisher   string            `json:"publisher,omitempty"`
+	PublishedAt *time.Time        `json:"published_at,omitempty"`
+	ISBN        string            `json:"isbn,omitempty"`
+	Description string            `json:"description,omitempty"`
+	CoverURL    string            `json:"cover_url,omitempty"`
+	FileType    string            `json:"file_type"`
+	FileSize    int64             `json:"file_size"`
+	PageCount   int               `json:"page_count,omitempty"`
+	Status      models.BookStatus `json:"status"`
+	IsPublic    bool              `json:"is_public"`
+	Tags        []string          `json:"tags"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// BookmarkSyncRecord is a bookmark as sent to and pushed by devices
+type BookmarkSyncRecord struct {
+	ID         uuid.UUID `json:"id"`
+	BookID     uuid.UUID `json:"book_id"`
+	Name       string    `json:"name"`
+	PageNumber int       `json:"page_number"`
+	Position   int       `json:"position"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ReadingProgressSyncRecord is reading progress as sent to and pushed by devices
+type ReadingProgressSyncRecord struct {
+	ID               uuid.UUID `json:"id"`
+	BookID           uuid.UUID `json:"book_id"`
+	CurrentPage      int       `json:"current_page"`
+	TotalPages       int       `json:"total_pages"`
+	CurrentPosition  int       `json:"current_position"`
+	Percentage       float64   `json:"percentage"`
+	TimeSpentMinutes int       `json:"time_spent_minutes"`
+	LastRead         time.Time `json:"last_read"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// ReadingSessionSyncRecord is a reading session as sent to and pushed by devices
+type ReadingSessionSyncRecord struct {
+	ID              uuid.UUID  `json:"id"`
+	BookID          uuid.UUID  `json:"book_id"`
+	StartedAt       time.Time  `json:"started_at"`
+	EndedAt         *time.Time `json:"ended_at"`
+	DurationMinutes int        `json:"duration_minutes"`
+	PagesRead       int        `json:"pages_read"`
+	StartPage       *int       `json:"start_page"`
+	EndPage         *int       `json:"end_page"`
+	StartPosition   int        `json:"start_position"`
+	EndPosition     int        `json:"end_position"`
+	DeviceType      *string    `json:"device_type"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// TagSyncRecord is a tag as sent to and pushed by devices
+type TagSyncRecord struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Changes returns the user's changes past the cursor, oldest first,
+// optionally restricted to some entity types
+func (s *ChangeFeedService) Changes(ctx context.Context, userID uuid.UUID, cursor string, limit int, types []string) (*ChangeFeedResponse, error) {
+	cursorSeq, cursorID, err := decodeSyncCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	for _, entityType := range types {
+		if !containsString(ChangeFeedEntityTypes, entityType) {
+			return nil, fmt.Errorf("%w entity type %q", ErrInvalid, entityType)
+		}
+	}
+	if limit <= 0 || limit > 1000 {
+		limit = 500
+	}
+
+	db := s.db.WithContext(ctx)
+	query := db.Where("user_id = ? AND (seq, entity_id) > (?, ?)", userID, cursorSeq, cursorID)
+	if len(types) > 0 {
+		query = query.Where("entity_type IN ?", types)
+	}
+	var entries []models.ChangeLogEntry
+	if err := query.Order("seq ASC, entity_id ASC").Limit(limit + 1).Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve changes: %w", err)
+	}
+
+	response := &ChangeFeedResponse{Changes: []ChangeFeedEntry{}}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		response.HasMore = true
+	}
+
+	// Load the current state of upserted entities, one query per type
+	upserted := make(map[string][]uuid.UUID)
+	for _, entry := range entries {
+		if entry.Operation == models.ChangeUpsert {
+			upserted[entry.EntityType] = append(upserted[entry.EntityType], entry.EntityID)
+		}
+	}
+	records := make(map[string]map[uuid.UUID]interface{})
+	for entityType, ids := range upserted {
+		loaded, err := s.loadRecords(db, userID, entityType, ids)
+		if err != nil {
+			return nil, err
+		}
+		records[entityType] = loaded
+	}
+
+	for _, entry := range entries {
+		change := ChangeFeedEntry{
+			Seq:       entry.Seq,
+			Type:      entry.EntityType,
+			ID:        entry.EntityID,
+			Operation: entry.Operation,
+			ChangedAt: entry.ChangedAt,
+		}
+		if entry.Operation == models.ChangeUpsert {
+			if data, ok := records[entry.EntityType][entry.EntityID]; ok {
+				change.Data = data
+			} else {
+				// Deleted since the entry was read; its tombstone follows later
+				change.Operation = models.ChangeDelete
+			}
+		}
+		response.Changes = append(response.Changes, change)
+	}
+
+	if n := len(entries); n > 0 {
+		response.Cursor = encodeSyncCursor(entries[n-1].Seq, entries[n-1].EntityID)
+	} else {
+		response.Cursor = encodeSyncCursor(cursorSeq, cursorID)
+	}
+
+	return response, nil
+}
+
+// loadRecords loads the sync records of live entities of one type
+func (s *ChangeFeedService) loadRecords(db *gorm.DB, userID uuid.UUID, entityType string, ids []uuid.UUID) (map[uuid.UUID]interface{}, error) {
+	records := make(map[uuid.UUID]interface{}, len(ids))
+	query := db.Where("user_id = ? AND id IN ?", userID, ids)
+
+	var err error
+	switch entityType {
+	case models.EntityAnnotation:
+		var annotations []models.Annotation
+		if err = query.Find(&annotations).Error; err == nil {
+			for i := range annotations {
+				records[annotations[i].ID] = toAnnotationSyncRecord(&annotations[i])
+			}
+		}
+	case models.EntityBook:
+		var books []models.Book
+		if err = query.Preload("Tags").Find(&books).Error; err == nil {
+			for i := range books {
+				records[books[i].ID] = toBookSyncRecord(&books[i])
+			}
+		}
+	case models.EntityBookmark:
+		var bookmarks []models.Bookmark
+		if err = query.Find(&bookmarks).Error; err == nil {
+			for _, b := range bookmarks {
+				records[b.ID] = BookmarkSyncRecord{ID: b.ID, BookID: b.BookID, Name: b.Name, PageNumber: b.PageNumber, Position: b.Position, UpdatedAt: b.UpdatedAt}
+			}
+		}
+	case models.EntityReadingProgress:
+		var progress []models.ReadingProgress
+		if err = query.Find(&progress).Error; err == nil {
+			for _, p := range progress {
+				records[p.ID] = ReadingProgressSyncRecord{
+					ID: p.ID, BookID: p.BookID, CurrentPage: p.CurrentPage, TotalPages: p.TotalPages,
+					CurrentPosition: p.CurrentPosition, Percentage: p.Percentage,
+					TimeSpentMinutes: p.TimeSpentMinutes, LastRead: p.LastRead, UpdatedAt: p.UpdatedAt,
+				}
+			}
+		}
+	case models.EntityReadingSession:
+		var sessions []models.ReadingSession
+		if err = query.Find(&sessions).Error; err == nil {
+			for _, rs := range sessions {
+				records[rs.ID] = ReadingSessionSyncRecord{
+					ID: rs.ID, BookID: rs.BookID, StartedAt: rs.StartedAt, EndedAt: rs.EndedAt,
+					DurationMinutes: rs.DurationMinutes, PagesRead: rs.PagesRead,
+					StartPage: rs.StartPage, EndPage: rs.EndPage,
+					StartPosition: rs.StartPosition, EndPosition: rs.EndPosition,
+					DeviceType: rs.DeviceType, UpdatedAt: rs.UpdatedAt,
+				}
+			}
+		}
+	case models.EntityTag:
+		var tags []models.Tag
+		if err = query.Find(&tags).Error; err == nil {
+			for _, t := range tags {
+				records[t.ID] = TagSyncRecord{ID: t.ID, Name: t.Name, Color: t.Color, UpdatedAt: t.UpdatedAt}
+			}
+		}
+	case models.EntityPreferences:
+		var preferences []models.UserPreference
+		if err = query.Find(&preferences).Error; err == nil {
+			for _, p := range preferences {
+				records[p.ID] = p.Settings
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w entity type %q", ErrInvalid, entityType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s changes: %w", entityType, err)
+	}
+	return records, nil
+}
+
+// Push applies a batch of device mutations. Each mutation is applied in its
+// own transaction; a failed mutation is rejected without affecting the rest.
+// Annotations are merged field by field; other entities are last-writer-wins.
+func (s *ChangeFeedService) Push(ctx context.Context, userID uuid.UUID, mutations []EntityMutation) (*PushResponse, error) {
+	response := &PushResponse{Results: []MutationResult{}}
+
+	for _, mutation := range mutations {
+		result, err := s.applyMutation(ctx, userID, mutation)
+		if err != nil {
+			result = MutationResult{Type: mutation.Type, ID: mutation.ID, Status: SyncStatusRejected, Error: err.Error()}
+		} else if result.Status != SyncStatusConflict {
+			var entry models.ChangeLogEntry
+			if err := s.db.WithContext(ctx).
+				Where("user_id = ? AND entity_type = ? AND entity_id = ?", userID, result.Type, result.ID).
+				Limit(1).Find(&entry).Error; err == nil {
+				result.Seq = entry.Seq
+			}
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	return response, nil
+}
+
+// applyMutation validates a mutation and applies it
+func (s *ChangeFeedService) applyMutation(ctx context.Context, userID uuid.UUID, m EntityMutation) (MutationResult, error) {
+	if !containsString(ChangeFeedEntityTypes, m.Type) {
+		return MutationResult{}, fmt.Errorf("%w entity type %q", ErrInvalid, m.Type)
+	}
+	if m.Operation == "" {
+		m.Operation = models.ChangeUpsert
+	}
+	if m.Operation != models.ChangeUpsert && m.Operation != models.ChangeDelete {
+		return MutationResult{}, fmt.Errorf("%w operation %q", ErrInvalid, m.Operation)
+	}
+	if m.ID == uuid.Nil && m.Type != models.EntityPreferences {
+		return MutationResult{}, fmt.Errorf("%w: id is required", ErrInvalid)
+	}
+	if m.ModifiedAt.IsZero() {
+		m.ModifiedAt = time.Now()
+	}
+
+	// Deleting a book also removes its file, which BookService does after commit
+	if m.Type == models.EntityBook && m.Operation == models.ChangeDelete {
+		return s.deleteBook(ctx, userID, m)
+	}
+
+	var result MutationResult
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		switch m.Type {
+		case models.EntityAnnotation:
+			result, err = s.applyAnnotation(tx, userID, m)
+		case models.EntityBook:
+			result, err = s.applyBook(tx, userID, m)
+		case models.EntityBookmark:
+			result, err = s.applyBookmark(tx, userID, m)
+		case models.EntityReadingProgress:
+			result, err = s.applyReadingProgress(tx, userID, m)
+		case models.EntityReadingSession:
+			result, err = s.applyReadingSession(tx, userID, m)
+		case models.EntityTag:
+			result, err = s.applyTag(tx, userID, m)
+		case models.EntityPreferences:
+			result, err = s.applyPreferences(tx, userID, m)
+		}
+		return err
+	})
+	return result, err
+}
+
+// lostConflict reports whether the entity changed on the server after the
+// device's base seq and later than the device's edit, so the server wins
+func (s *ChangeFeedService) lostConflict(tx *gorm.DB, userID uuid.UUID, entityType string, entityID uuid.UUID, m EntityMutation) (bool, error) {
+	var entries []models.ChangeLogEntry
+	if err := tx.Where("user_id = ? AND entity_type = ? AND entity_id = ?", userID, entityType, entityID).
+		Limit(1).Find(&entries).Error; err != nil {
+		return false, fmt.Errorf("failed to check for conflicts: %w", err)
+	}
+	if len(entries) == 0 {
+		return false, nil
+	}
+	return entries[0].Seq > m.BaseSeq && entries[0].ChangedAt.After(m.ModifiedAt), nil
+}
+
+// conflictResult reports a lost conflict along with the server's state
+func (s *ChangeFeedService) conflictResult(tx *gorm.DB, userID uuid.UUID, entityType string, entityID uuid.UUID) (MutationResult, error) {
+	records, err := s.loadRecords(tx, userID, entityType, []uuid.UUID{entityID})
+	if err != nil {
+		return MutationResult{}, err
+	}
+	return MutationResult{Type: entityType, ID: entityID, Status: SyncStatusConflict, Current: records[entityID]}, nil
+}
+
+// deleteEntity soft-deletes one of the user's entities unless the server
+// changed it after the device's edit
+func (s *ChangeFeedService) deleteEntity(tx *gorm.DB, userID uuid.UUID, m EntityMutation, model interface{}) (MutationResult, error) {
+	lost, err := s.lostConflict(tx, userID, m.Type, m.ID, m)
+	if err != nil {
+		return MutationResult{}, err
+	}
+	if lost {
+		return s.conflictResult(tx, userID, m.Type, m.ID)
+	}
+
+	result := tx.Where("id = ? AND user_id = ?", m.ID, userID).Delete(model)
+	if result.Error != nil {
+		return MutationResult{}, fmt.Errorf("failed to delete %s: %w", m.Type, result.Error)
+	}
+	status := SyncStatusDeleted
+	if result.RowsAffected == 0 {
+		status = SyncStatusUnchanged
+	}
+	return MutationResult{Type: m.Type, ID: m.ID, Status: status}, nil
+}
+
+// applyAnnotation merges an annotation change field by field
+func (s *ChangeFeedService) applyAnnotation(tx *gorm.DB, userID uuid.UUID, m EntityMutation) (MutationResult, error) {
+	var change AnnotationChange
+	if len(m.Data) > 0 {
+		if err := json.Unmarshal(m.Data, &change); err != nil {
+			return MutationResult{}, fmt.Errorf("%w annotation data: %w", ErrInvalid, err)
+		}
+	}
+	change.ID = m.ID
+	change.Deleted = m.Operation == models.ChangeDelete
+	if change.ModifiedAt.IsZero() {
+		change.ModifiedAt = m.ModifiedAt
+	}
+
+	applied, conflicts, err := s.annotationSync.applyChange(tx, userID, change)
+	if err != nil {
+		return MutationResult{}, err
+	}
+	return MutationResult{Type: m.Type, ID: m.ID, Status: applied.Status, Conflicts: conflicts}, nil
+}
+
+// applyBook updates a book's metadata and tags. Books are created by upload.
+func (s *ChangeFeedService) applyBook(tx *gorm.DB, userID uuid.UUID, m EntityMutation) (MutationResult, error) {
+	var req BookUpdateRequest
+	if err := json.Unmarshal(m.Data, &req); err != nil {
+		return MutationResult{}, fmt.Errorf("%w book data: %w", ErrInvalid, err)
+	}
+
+	var book models.Book
+	if err := tx.Where("id = ? AND user_id = ?", m.ID, userID).First(&book).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return MutationResult{}, fmt.Errorf("book %w", ErrNotFound)
+		}
+		return MutationResult{}, fmt.Errorf("failed to retrieve book: %w", err)
+	}
+
+	lost, err := s.lostConflict(tx, userID, m.Type, m.ID, m)
+	if err != nil {
+		return MutationResult{}, err
+	}
+	if lost {
+		return s.conflictResult(tx, userID, m.Type, m.ID)
+	}
+
+	if err := s.bookService.applyBookUpdate(tx, &book, userID, &req); err != nil {
+		return MutationResult{}, err
+	}
+	return MutationResult{Type: m.Type, ID: m.ID, Status: SyncStatusUpdated}, nil
+}
+
+// deleteBook deletes a book and its file unless the server changed it after
+// the device's edit
+func (s *ChangeFeedService) deleteBook(ctx context.Context, userID uuid.UUID, m EntityMutation) (MutationResult, error) {
+	db := s.db.WithContext(ctx)
+	lost, err := s.lostConflict(db, userID, m.Type, m.ID, m)
+	if err != nil {
+		return MutationResult{}, err
+	}
+	if lost {
+		return s.conflictResult(db, userID, m.Type, m.ID)
+	}
+
+	if err := s.bookService.DeleteBook(ctx, userID, m.ID); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return MutationResult{Type: m.Type, ID: m.ID, Status: SyncStatusUnchanged}, nil
+		}
+		return MutationResult{}, err
+	}
+	return MutationResult{Type: m.Type, ID: m.ID, Status: SyncStatusDeleted}, nil
+}
+
+// requireLibraryBook checks that the book is in the user's library
+func requireLibraryBook(tx *gorm.DB, userID, bookID uuid.UUID) error {
+	var count int64
+	if err := tx.Model(&models.UserBook{}).
+		Where("user_id = ? AND book_id = ?", userID, bookID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to verify book access: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("book %w in your library", ErrNotFound)
+	}
+	return nil
+}
+
+// findForUpsert loads one of the user's entities by ID, including
+// soft-deleted rows. It returns false if the entity does not exist yet.
+func findForUpsert(tx *gorm.DB, userID, id uuid.UUID, entityType string, dest interface{}) (bool, error) {
+	result := tx.Unscoped().Where("id = ? AND user_id = ?", id, userID).Limit(1).Find(dest)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to retrieve %s: %w", entityType, result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// Device-generated IDs must not collide with another user's entity
+	var count int64
+	if err := tx.Unscoped().Model(dest).Where("id = ?", id).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to retrieve %s: %w", entityType, err)
+	}
+	if count > 0 {
+		return false, fmt.Errorf("%w: %s id already in use", ErrConflict, entityType)
+	}
+	return false, nil
+}
+
+// applyBookmark creates, updates or restores a bookmark
+func (s *ChangeFeedService) applyBookmark(tx *gorm.DB, userID uuid.UUID, m EntityMutation) (MutationResult, error) {
+	if m.Operation == models.ChangeDelete {
+		return s.deleteEntity(tx, userID, m, &models.Bookmark{})
+	}
+
+	var record BookmarkSyncRecord
+	if err := json.Unmarshal(m.Data, &record); err != nil {
+		return MutationResult{}, fmt.Errorf("%w bookmark data: %w", ErrInvalid, err)
+	}
+
+	var bookmark models.Bookmark
+	exists, err := findForUpsert(tx, userID, m.ID, m.Type, &bookmark)
+	if err != nil {
+		return MutationResult{}, err
+	}
+	if !exists {
+		if err := requireLibraryBook(tx, userID, record.BookID); err != nil {
+			return MutationResult{}, err
+		}
+		bookmark = models.Bookmark{
+			BaseModel:  models.BaseModel{ID: m.ID},
+			UserID:     userID,
+			BookID:     record.BookID,
+			Name:       record.Name,
+			PageNumber: record.PageNumber,
+			Position:   record.Position,
+		}
+		if err := tx.Create(&bookmark).Error; err != nil {
+			return MutationResult{}, fmt.Errorf("failed to create bookmark: %w", err)
+		}
+		return MutationResult{Type: m.Type, ID: m.ID, Status: SyncStatusCreated}, nil
+	}
+
+	lost, err := s.lostConflict(tx, userID, m.Type, m.ID, m)
+	if err != nil {
+		return MutationResult{}, err
+	}
+	if lost {
+		return s.conflictResult(tx, userID, m.Type, m.ID)
+	}
+
+	if err := tx.Unscoped().Model(&bookmark).Updates(map[string]interface{}{
+		"name":        record.Name,
+		"page_number": record.PageNumber,
+		"position":    record.Position,
+		"deleted_at":  nil,
+	}).Error; err != nil {
+		return MutationResult{}, fmt.Errorf("failed to update bookmark: %w", err)
+	}
+	return MutationResult{Type: m.Type, ID: m.ID, Status: SyncStatusUpdated}, nil
+}
+
+// applyReadingProgress creates or updates the reading progress for a book.
+// Progress is unique per book, so a device's new record for a book that
+// already has progress updates the existing record.
+func (s *ChangeFeedService) applyReadingProgress(tx *gorm.DB, userID uuid.UUID, m EntityMutation) (MutationResult, error) {
+	if m.Operation == models.ChangeDelete {
+		return s.deleteEntity(tx, userID, m, &models.ReadingProgress{})
+	}
+
+	var record ReadingProgressSyncRecord
+	if err := json.Unmarshal(m.Data, &record); err != nil {
+		return MutationResult{}, fmt.Errorf("%w reading progress data: %w", ErrInvalid, err)
+	}
+	if record.LastRead.IsZero() {
+		record.LastRead = m.ModifiedAt
+	}
+
+	var progress models.ReadingProgress
+	exists, err := findForUpsert(tx, userID, m.ID, m.Type, &progress)
+	if err != nil {
+		return MutationResult{}, err
+	}
+	if !exists {
+		if err := requireLibraryBook(tx, userID, record.BookID); err != nil {
+			return MutationResult{}, err
+		}
+		result := tx.Where("user_id = ? AND book_id = ?", userID, record.BookID).Limit(1).Find(&progress)
+		if result.Error != nil {
+			return MutationResult{}, fmt.Errorf("failed to retrieve reading progress: %w", result.Error)
+		}
+		exists = result.RowsAffected > 0
+	}
+	if !exists {
+		progress = models.ReadingProgress{
+			BaseModel:        models.BaseModel{ID: m.ID},
+			UserID:           userID,
+			BookID:           record.BookID,
+			CurrentPage:      record.CurrentPage,
+			TotalPages:       record.TotalPages,
+			CurrentPosition:  record.CurrentPosition,
+			Percentage:       record.Percentage,
+			TimeSpentMinutes: record.TimeSpentMinutes,
+			LastRead:         record.LastRead,
+		}
+		if err := tx.Create(&progress).Error; err != nil {
+			return MutationResult{}, fmt.Errorf("failed to create reading progress: %w", err)
+		}
+		return MutationResult{Type: m.Type, ID: m.ID, Status: SyncStatusCreated}, nil
+	}
+
+	lost, err := s.lostConflict(tx, userID, m.Type, progress.ID, m)
+	if err != nil {
+		return MutationResult{}, err
+	}
+	if lost {
+		return s.conflictResult(tx, userID, m.Type, progress.ID)
+	}
+
+	if err := tx.Unscoped().Model(&progress).Updates(map[string]interface{}{
+		"current_page":       record.CurrentPage,
+		"total_pages":        record.TotalPages,
+		"current_position":   record.CurrentPosition,
+		"percentage":         record.Percentage,
+		"time_spent_minutes": record.TimeSpentMinutes,
+		"last_read":          record.LastRead,
+		"deleted_at":         nil,
+	}).Error; err != nil {
+		return MutationResult{}, fmt.Errorf("failed to update reading progress: %w", err)
+	}
+	return MutationResult{Type: m.Type, ID: progress.ID, Status: SyncStatusUpdated}, nil
+}
+
+// applyReadingSession creates or updates a reading session
+func (s *ChangeFeedService) applyReadingSession(tx *gorm.DB, userID uuid.UUID, m EntityMutation) (MutationResult, error) {
+	if m.Operation == models.ChangeDelete {
+		return s.deleteEntity(tx, userID, m, &models.ReadingSession{})
+	}
+
+	var record ReadingSessionSyncRecord
+	if err := json.Unmarshal(m.Data, &record); err != nil {
+		return MutationResult{}, fmt.Errorf("%w reading session data: %w", ErrInvalid, err)
+	}
+	if record.StartedAt.IsZero() {
+		return MutationResult{}, fmt.Errorf("%w: started_at is required", ErrInvalid)
+	}
+
+	var session models.ReadingSession
+	exists, err := findForUpsert(tx, userID, m.ID, m.Type, &session)
+	if err != nil {
+		return MutationResult{}, err
+	}
+	if !exists {
+		if err := requireLibraryBook(tx, userID, record.BookID); err != nil {
+			return MutationResult{}, err
+		}
+		session = models.ReadingSession{
+			BaseModel:       models.BaseModel{ID: m.ID},
+			UserID:          userID,
+			BookID:          record.BookID,
+			StartedAt:       record.StartedAt,
+			EndedAt:         record.EndedAt,
+			DurationMinutes: record.DurationMinutes,
+			PagesRead:       record.PagesRead,
+			StartPage:       record.StartPage,
+			EndPage:         record.EndPage,
+			StartPosition:   record.StartPosition,
+			EndPosition:     record.EndPosition,
+			DeviceType:      record.DeviceType,
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return MutationResult{}, fmt.Errorf("failed to create reading session: %w", err)
+		}
+		return MutationResult{Type: m.Type, ID: m.ID, Status: SyncStatusCreated}, nil
+	}
+
+	lost, err := s.lostConflict(tx, userID, m.Type, m.ID, m)
+	if err != nil {
+		return MutationResult{}, err
+	}
+	if lost {
+		return s.conflictResult(tx, userID, m.Type, m.ID)
+	}
+
+	if err := tx.Unscoped().Model(&session).Updates(map[string]interface{}{
+		"started_at":       record.StartedAt,
+		"ended_at":         record.EndedAt,
+		"duration_minutes": record.DurationMinutes,
+		"pages_read":       record.PagesRead,
+		"start_page":       record.StartPage,
+		"end_page":         record.EndPage,
+		"start_position":   record.StartPosition,
+		"end_position":     record.EndPosition,
+		"device_type":      record.DeviceType,
+		"deleted_at":       nil,
+	}).Error; err != nil {
+		return MutationResult{}, fmt.Errorf("failed to update reading session: %w", err)
+	}
+	return MutationResult{Type: m.Type, ID: m.ID, Status: SyncStatusUpdated}, nil
+}
+
+// applyTag creates, renames or recolors a tag
+func (s *ChangeFeedService) applyTag(tx *gorm.DB, userID uuid.UUID, m EntityMutation) (MutationResult, error) {
+	if m.Operation == models.ChangeDelete {
+		return s.deleteEntity(tx, userID, m, &models.Tag{})
+	}
+
+	var record TagSyncRecord
+	if err := json.Unmarshal(m.Data, &record); err != nil {
+		return MutationResult{}, fmt.Errorf("%w tag data: %w", ErrInvalid, err)
+	}
+	names, err := ResolveTags(tx, userID, []string{record.Name})
+	if err != nil {
+		return MutationResult{}, err
+	}
+	if len(names) == 0 {
+		return MutationResult{}, fmt.Errorf("%w: tag name is required", ErrInvalid)
+	}
+	name := names[0]
+
+	var tag models.Tag
+	exists, err := findForUpsert(tx, userID, m.ID, m.Type, &tag)
+	if err != nil {
+		return MutationResult{}, err
+	}
+	if !exists {
+		// The name may be an alias or case variant of a tag the user has
+		var count int64
+		if err := tx.Model(&models.Tag{}).Where("user_id = ? AND name = ?", userID, name).Count(&count).Error; err != nil {
+			return MutationResult{}, fmt.Errorf("failed to check tags: %w", err)
+		}
+		if count > 0 {
+			return MutationResult{}, fmt.Errorf("%w: tag %q already exists", ErrConflict, name)
+		}
+		if _, err := RegisterTags(tx, userID, TagAncestors(name)); err != nil {
+			return MutationResult{}, err
+		}
+		tag = models.Tag{ID: m.ID, UserID: userID, Name: name, Color: record.Color}
+		if err := tx.Create(&tag).Error; err != nil {
+			return MutationResult{}, fmt.Errorf("failed to create tag: %w", err)
+		}
+		return MutationResult{Type: m.Type, ID: m.ID, Status: SyncStatusCreated}, nil
+	}
+
+	lost, err := s.lostConflict(tx, userID, m.Type, m.ID, m)
+	if err != nil {
+		return MutationResult{}, err
+	}
+	if lost {
+		return s.conflictResult(tx, userID, m.Type, m.ID)
+	}
+
+	if err := tx.Unscoped().Model(&tag).Updates(map[string]interface{}{
+		"color":      record.Color,
+		"deleted_at": nil,
+	}).Error; err != nil {
+		return MutationResult{}, fmt.Errorf("failed to update tag: %w", err)
+	}
+	// A new name renames the tag as TagService.Rename does, retagging its
+	// annotations and descendants
+	if name != tag.Name {
+		if used, err := tagInUse(tx, userID, name); err != nil {
+			return MutationResult{}, err
+		} else if used {
+			return MutationResult{}, fmt.Errorf("%w: tag %q already exists: merge the tags instead", ErrConflict, name)
+		}
+		if err := moveTag(tx, userID, tag.Name, name, &TagChange{Renamed: map[string]string{}}); err != nil {
+			return MutationResult{}, err
+		}
+	}
+	return MutationResult{Type: m.Type, ID: m.ID, Status: SyncStatusUpdated}, nil
+}
+
+// applyPreferences merges pushed settings into the user's preferences.
+// Nested objects are merged key by key; other values are replaced.
+func (s *ChangeFeedService) applyPreferences(tx *gorm.DB, userID uuid.UUID, m EntityMutation) (MutationResult, error) {
+	if m.Operation == models.ChangeDelete {
+		return MutationResult{}, fmt.Errorf("%w operation: preferences cannot be deleted", ErrInvalid)
+	}
+
+	var settings map[string]interface{}
+	if err := json.Unmarshal(m.Data, &settings); err != nil {
+		return MutationResult{}, fmt.Errorf("%w preferences data: %w", ErrInvalid, err)
+	}
+
+	var preference models.UserPreference
+	result := tx.Where("user_id = ?", userID).Limit(1).Find(&preference)
+	if result.Error != nil {
+		return MutationResult{}, fmt.Errorf("failed to retrieve preferences: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		preference = models.UserPreference{UserID: userID, Settings: settings}
+		if err := tx.Create(&preference).Error; err != nil {
+			return MutationResult{}, fmt.Errorf("failed to create preferences: %w", err)
+		}
+		return MutationResult{Type: m.Type, ID: preference.ID, Status: SyncStatusCreated}, nil
+	}
+
+	lost, err := s.lostConflict(tx, userID, m.Type, preference.ID, m)
+	if err != nil {
+		return MutationResult{}, err
+	}
+	if lost {
+		return s.conflictResult(tx, userID, m.Type, preference.ID)
+	}
+
+	if preference.Settings == nil {
+		preference.Settings = make(map[string]interface{})
+	}
+	mergeSettings(preference.Settings, settings)
+	if err := tx.Save(&preference).Error; err != nil {
+		return MutationResult{}, fmt.Errorf("failed to update preferences: %w", err)
+	}
+	return MutationResult{Type: m.Type, ID: preference.ID, Status: SyncStatusUpdated}, nil
+}
+
+// mergeSettings merges src into dst, recursing into objects present in both
+func mergeSettings(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if from, ok := value.(map[string]interface{}); ok {
+			if into, ok := dst[key].(map[string]interface{}); ok {
+				mergeSettings(into, from)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
+
+func toBookSyncRecord(b *models.Book) BookSyncRecord {
+	record := BookSyncRecord{
+		ID:          b.ID,
+		Title:       b.Title,
+		Author:      b.Author,
+		Language:    b.Language,
+		Genre:       b.Genre,
+		Publisher:   b.Publisher,
+		PublishedAt: b.PublishedAt,
+		ISBN:        b.ISBN,
+		Description: b.Description,
+		CoverURL:    b.CoverURL,
+		FileType:    b.FileType,
+		FileSize:    b.FileSize,
+		PageCount:   b.PageCount,
+		Status:      b.Status,
+		IsPublic:    b.IsPublic,
+		Tags:        []string{},
+		UpdatedAt:   b.UpdatedAt,
+	}
+	for _, tag := range b.Tags {
+		record.Tags = append(record.Tags, tag.Name)
+	}
+	return record
+}
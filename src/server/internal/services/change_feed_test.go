@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestApplyMutationValidation(t *testing.T) {
+	s := &ChangeFeedService{}
+	tests := []struct {
+		name     string
+		mutation EntityMutation
+		err      string
+	}{
+		{"unknown type", EntityMutation{Type: "shelf", ID: uuid.New()}, "invalid entity type"},
+		{"unknown operation", EntityMutation{Type: models.EntityBookmark, ID: uuid.New(), Operation: "merge"}, "invalid operation"},
+		{"missing id", EntityMutation{Type: models.EntityTag}, "id is required"},
+	}
+	for _, tt := range tests {
+		_, err := s.applyMutation(context.Background(), uuid.New(), tt.mutation)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got %v, want error containing %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestChangesRejectsUnknownTypes(t *testing.T) {
+	s := &ChangeFeedService{}
+	if _, err := s.Changes(context.Background(), uuid.New(), "", 10, []string{"book", "shelf"}); err == nil {
+		t.Fatal("expected an error for an unknown entity type")
+	}
+	if _, err := s.Changes(context.Background(), uuid.New(), "not-a-cursor!", 10, nil); err == nil {
+		t.Fatal("expected an error for an invalid cursor")
+	}
+}
+
+func TestToBookSyncRecord(t *testing.T) {
+	book := &models.Book{
+		ID:     uuid.New(),
+		Title:  "Meditations",
+		Author: "Marcus Aurelius",
+		Tags:   []models.Tag{{Name: "stoicism"}, {Name: "philosophy"}},
+	}
+	record := toBookSyncRecord(book)
+	if record.ID != book.ID || record.Title != book.Title {
+		t.Fatalf("unexpected record %+v", record)
+	}
+	if len(record.Tags) != 2 || record.Tags[0] != "stoicism" || record.Tags[1] != "philosophy" {
+		t.Fatalf("got tags %v", record.Tags)
+	}
+	if tags := toBookSyncRecord(&models.Book{}).Tags; tags == nil {
+		t.Fatal("untagged books should sync an empty tag list")
+	}
+}
+
+func TestApplyTagResolvesNames(t *testing.T) {
+	tx := testDB(t)
+	user := createTestUser(t, tx, "reader", nil)
+	s := NewChangeFeedService(tx, nil)
+	ctx := context.Background()
+	philosophy := models.Tag{UserID: user, Name: "philosophy"}
+	if err := tx.Create(&philosophy).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Create(&models.TagAlias{UserID: user, TagID: philosophy.ID, Alias: "phil"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	push := func(id uuid.UUID, name string) MutationResult {
+		t.Helper()
+		result, err := s.applyMutation(ctx, user, EntityMutation{Type: models.EntityTag, ID: id, Data: []byte(`{"name": "` + name + `"}`)})
+		if err != nil {
+			return MutationResult{Status: SyncStatusRejected, Error: err.Error()}
+		}
+		return result
+	}
+	stored := func(id uuid.UUID) string {
+		t.Helper()
+		var tag models.Tag
+		if err := tx.First(&tag, "id = ?", id).Error; err != nil {
+			t.Fatal(err)
+		}
+		return tag.Name
+	}
+
+	stoicism := uuid.New()
+	if result := push(stoicism, " Phil /  stoicism "); result.Status != SyncStatusCreated {
+		t.Fatalf("push = %+v", result)
+	}
+	if got := stored(stoicism); got != "philosophy/stoicism" {
+		t.Errorf("pushed tag stored as %q, want philosophy/stoicism", got)
+	}
+	if result := push(uuid.New(), "Philosophy"); result.Status != SyncStatusRejected || !strings.Contains(result.Error, "already exists") {
+		t.Errorf("pushing a case variant of an existing tag = %+v, want rejected", result)
+	}
+
+	if result := push(stoicism, "phil/stoa"); result.Status != SyncStatusUpdated {
+		t.Fatalf("rename = %+v", result)
+	}
+	if got := stored(stoicism); got != "philosophy/stoa" {
+		t.Errorf("renamed tag stored as %q, want philosophy/stoa", got)
+	}
+}
+
+func TestMergeSettings(t *testing.T) {
+	stored := map[string]interface{}{
+		"theme":   "dark",
+		"reading": map[string]interface{}{"font_size": 16.0, "line_height": 1.5},
+		"tags":    []interface{}{"a"},
+	}
+	mergeSettings(stored, map[string]interface{}{
+		"reading":  map[string]interface{}{"font_size": 18.0},
+		"tags":     []interface{}{"b"},
+		"language": "en",
+	})
+	want := map[string]interface{}{
+		"theme":    "dark",
+		"reading":  map[string]interface{}{"font_size": 18.0, "line_height": 1.5},
+		"tags":     []interface{}{"b"},
+		"language": "en",
+	}
+	if !reflect.DeepEqual(stored, want) {
+		t.Errorf("merged settings = %v, want %v", stored, want)
+	}
+}
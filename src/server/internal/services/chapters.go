@@ -0,0 +1,125 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Chapter is a contiguous section of a book's extracted text, located by rune offsets
+type Chapter struct {
+	Index int    `json:"index"`
+	Title string `json:"title"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// fallbackSectionRunes is the size of the synthetic sections used when a
+// text has no recognisable chapter headings
+const fallbackSectionRunes = 20000
+
+var chapterHeadingPattern = regexp.MustCompile(`(?i)^(chapter|book|part|canto|act|scene|section|letter|liber|caput|capitulum|pars|epistula|βιβλίον|βιβλιον|ῥαψῳδία|ραψῳδία|ραψωιδια|ραψωδια|κεφάλαιον|κεφαλαιον)[\s.:]+` +
+	`([0-9]+|[ivxlcdm]+|\p{Greek}{1,3}'?|one|two|three|four|five|six|seven|eight|nine|ten|eleven|twelve|` +
+	`first|second|third|fourth|fifth|sixth|seventh|eighth|ninth|tenth|eleventh|twelfth|` +
+	`prim[uv]s|secund[uv]s|terti[uv]s|q[uv]art[uv]s|q[uv]int[uv]s|sext[uv]s|septim[uv]s|octa[uv][uv]s|non[uv]s|decim[uv]s)` +
+	`([\s.:,;\-—].*)?$`)
+
+var romanHeadingPattern = regexp.MustCompile(`^(?i)[ivxlcdm]{1,7}\.?$`)
+
+// DetectChapters splits text into chapters using heading lines such as
+// "CHAPTER IV", "Book 2", "LIBER PRIMVS" or "ΡΑΨΩΙΔΙΑ Α". When fewer than two
+// headings are found the text is divided into equal synthetic sections so
+// that callers can always rely on a non-empty result for non-empty text.
+func DetectChapters(text string) []Chapter {
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return nil
+	}
+
+	type heading struct {
+		title string
+		start int
+	}
+	var headings []heading
+
+	lineStart := 0
+	prevBlank := true
+	for lineStart < len(runes) {
+		lineEnd := lineStart
+		for lineEnd < len(runes) && runes[lineEnd] != '\n' {
+			lineEnd++
+		}
+		line := strings.TrimSpace(string(runes[lineStart:lineEnd]))
+
+		if line != "" && len([]rune(line)) <= 80 {
+			if chapterHeadingPattern.MatchString(line) || (prevBlank && romanHeadingPattern.MatchString(line)) {
+				headings = append(headings, heading{title: line, start: lineStart})
+			}
+		}
+
+		prevBlank = line == ""
+		lineStart = lineEnd + 1
+	}
+
+	if len(headings) < 2 {
+		return syntheticSections(len(runes))
+	}
+
+	var chapters []Chapter
+	if headings[0].start > 0 && strings.TrimSpace(string(runes[:headings[0].start])) != "" {
+		chapters = append(chapters, Chapter{Title: "Front matter", Start: 0, End: headings[0].start})
+	}
+	for i, h := range headings {
+		end := len(runes)
+		if i+1 < len(headings) {
+			end = headings[i+1].start
+		}
+		chapters = append(chapters, Chapter{Title: h.title, Start: h.start, End: end})
+	}
+	for i := range chapters {
+		chapters[i].Index = i
+	}
+
+	return chapters
+}
+
+// ChapterAt returns the index of the chapter containing the rune offset, or -1
+func ChapterAt(chapters []Chapter, offset int) int {
+	lo, hi := 0, len(chapters)-1
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		switch {
+		case offset < chapters[mid].Start:
+			hi = mid - 1
+		case offset >= chapters[mid].End:
+			lo = mid + 1
+		default:
+			return mid
+		}
+	}
+	return -1
+}
+
+func syntheticSections(length int) []Chapter {
+	count := (length + fallbackSectionRunes - 1) / fallbackSectionRunes
+	if count < 1 {
+		count = 1
+	}
+	size := (length + count - 1) / count
+
+	chapters := make([]Chapter, 0, count)
+	for i := 0; i < count; i++ {
+		start := i * size
+		end := start + size
+		if end > length {
+			end = length
+		}
+		chapters = append(chapters, Chapter{
+			Index: i,
+			Title: fmt.Sprintf("Section %d", i+1),
+			Start: start,
+			End:   end,
+		})
+	}
+	return chapters
+}
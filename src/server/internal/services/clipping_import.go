@@ -0,0 +1,355 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/classius/server/internal/models"
+)
+
+const (
+	bookMatchThreshold = 0.7 // Minimum title/author similarity to import into a book
+	anchorEdgeWords    = 6   // Words matched at each end of a quote that is not found whole
+)
+
+// ClippingImportService imports e-reader highlights and notes, matching
+// them to the user's books by title and author and anchoring them in the
+// books' text
+type ClippingImportService struct {
+	db  *gorm.DB
+	web *WebAnnotationService
+}
+
+// NewClippingImportService creates a new clipping import service
+func NewClippingImportService(db *gorm.DB) *ClippingImportService {
+	return &ClippingImportService{db: db, web: NewWebAnnotationService(db)}
+}
+
+// ClippingImportResult reports the outcome of an import
+type ClippingImportResult struct {
+	Format     string              `json:"format"`
+	Imported   int                 `json:"imported"`
+	Duplicates int                 `json:"duplicates"`
+	Unanchored int                 `json:"unanchored"` // Imported without a position, their text not found in the book
+	Books      []ImportedBook      `json:"books"`
+	Unmatched  []UnmatchedClipping `json:"unmatched"`
+}
+
+// ImportedBook is a book clippings were imported into
+type ImportedBook struct {
+	BookID      uuid.UUID `json:"book_id"`
+	Title       string    `json:"title"`
+	SourceTitle string    `json:"source_title"` // The title in the export
+	MatchScore  float64   `json:"match_score"`
+	Imported    int       `json:"imported"`
+}
+
+// UnmatchedClipping is a clipping that was not imported
+type UnmatchedClipping struct {
+	Title    string `json:"title"`
+	Author   string `json:"author,omitempty"`
+	Kind     string `json:"kind"`
+	Text     string `json:"text,omitempty"`
+	Location string `json:"location,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+// Import parses an export and imports its clippings. If bookID is given,
+// every clipping is imported into that book instead of being matched.
+func (s *ClippingImportService) Import(ctx context.Context, userID uuid.UUID, bookID *uuid.UUID, format string, data []byte) (*ClippingImportResult, error) {
+	clippings, err := ParseClippings(format, data)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.db.WithContext(ctx)
+	var books []models.Book
+	query := db.Select("id", "title", "author").Where("user_id = ?", userID)
+	if bookID != nil {
+		query = query.Where("id = ?", *bookID)
+	}
+	if err := query.Find(&books).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve books: %w", err)
+	}
+	if bookID != nil && len(books) == 0 {
+		return nil, fmt.Errorf("book %w", ErrNotFound)
+	}
+
+	source := ImportSourceKindle
+	if format != ClippingFormatKindle {
+		source = ImportSourceKOReader
+	}
+	result := &ClippingImportResult{Format: format, Books: []ImportedBook{}, Unmatched: []UnmatchedClipping{}}
+	matches := make(map[string]int) // Source title and author -> index in result.Books, or -1
+	locators := make(map[uuid.UUID]*QuoteLocator)
+	seen := make(map[string]bool)
+	now := time.Now()
+	var annotations []models.Annotation
+	var bookIndexes []int
+
+	for _, c := range clippings {
+		if c.Kind != "bookmark" && strings.TrimSpace(c.Text) == "" && strings.TrimSpace(c.Note) == "" {
+			result.Unmatched = append(result.Unmatched, unmatchedClipping(c, "empty clipping"))
+			continue
+		}
+
+		key := c.Title + "\x00" + c.Author
+		index, ok := matches[key]
+		if !ok {
+			index = -1
+			var book *models.Book
+			score := 1.0
+			if bookID != nil {
+				book = &books[0]
+			} else {
+				book, score = matchBook(books, c.Title, c.Author)
+			}
+			if book != nil {
+				index = len(result.Books)
+				result.Books = append(result.Books, ImportedBook{BookID: book.ID, Title: book.Title, SourceTitle: c.Title, MatchScore: score})
+			}
+			matches[key] = index
+		}
+		if index < 0 {
+			result.Unmatched = append(result.Unmatched, unmatchedClipping(c, "no matching book in your library"))
+			continue
+		}
+		target := result.Books[index].BookID
+
+		annotation := models.Annotation{
+			UserID:       userID,
+			BookID:       target,
+			Type:         c.Kind,
+			SelectedText: strings.TrimSpace(c.Text),
+			Content:      strings.TrimSpace(c.Note),
+			Color:        c.Color,
+			PageNumber:   c.Page,
+			Tags:         []string{},
+			ImportSource: source,
+			ImportRef:    c.Location,
+			ImportedAt:   &now,
+		}
+		if c.AddedAt != nil {
+			annotation.CreatedAt = *c.AddedAt
+		}
+
+		dedupKey := fmt.Sprintf("%s|%s|%s|%s", target, annotation.ImportRef, annotation.SelectedText, annotation.Content)
+		if seen[dedupKey] {
+			result.Duplicates++
+			continue
+		}
+		seen[dedupKey] = true
+		duplicate, err := s.isDuplicate(db, &annotation)
+		if err != nil {
+			return nil, err
+		}
+		if duplicate {
+			result.Duplicates++
+			continue
+		}
+
+		if annotation.SelectedText != "" {
+			locator, ok := locators[target]
+			if !ok {
+				if locator, err = s.web.loadLocator(ctx, target); err != nil {
+					return nil, fmt.Errorf("failed to load book text: %w", err)
+				}
+				locators[target] = locator
+			}
+			if locator == nil || !anchorClipping(locator, &annotation) {
+				result.Unanchored++
+			}
+		}
+		annotations = append(annotations, annotation)
+		bookIndexes = append(bookIndexes, index)
+	}
+
+	if len(annotations) > 0 {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			ids := make([]uuid.UUID, 0, len(annotations))
+			for i := range annotations {
+				if err := tx.Create(&annotations[i]).Error; err != nil {
+					return fmt.Errorf("failed to create annotation: %w", err)
+				}
+				ids = append(ids, annotations[i].ID)
+			}
+			return RecordAnnotationChange(tx, userID, ids)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, index := range bookIndexes {
+		result.Books[index].Imported++
+	}
+	result.Imported = len(annotations)
+	return result, nil
+}
+
+// isDuplicate reports whether a clipping was already imported
+func (s *ClippingImportService) isDuplicate(db *gorm.DB, a *models.Annotation) (bool, error) {
+	var count int64
+	if err := db.Model(&models.Annotation{}).
+		Where("user_id = ? AND book_id = ? AND import_source = ? AND import_ref = ? AND selected_text = ? AND content = ?",
+			a.UserID, a.BookID, a.ImportSource, a.ImportRef, a.SelectedText, a.Content).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check for duplicates: %w", err)
+	}
+	return count > 0, nil
+}
+
+func unmatchedClipping(c Clipping, reason string) UnmatchedClipping {
+	text := c.Text
+	if text == "" {
+		text = c.Note
+	}
+	if runes := []rune(text); len(runes) > 200 {
+		text = string(runes[:200]) + "…"
+	}
+	return UnmatchedClipping{Title: c.Title, Author: c.Author, Kind: c.Kind, Text: text, Location: c.Location, Reason: reason}
+}
+
+// anchorClipping sets the annotation's position from its text. E-readers
+// sometimes alter a passage's middle (hyphenation, footnote markers), so a
+// quote not found whole is located by its first and last words.
+func anchorClipping(locator *QuoteLocator, a *models.Annotation) bool {
+	if start, end, ok := locator.Locate(a.SelectedText, "", "", -1); ok {
+		a.StartPosition, a.EndPosition = start, end
+		return true
+	}
+
+	words := strings.Fields(a.SelectedText)
+	if len(words) < 2*anchorEdgeWords {
+		return false
+	}
+	head := strings.Join(words[:anchorEdgeWords], " ")
+	tail := strings.Join(words[len(words)-anchorEdgeWords:], " ")
+	start, _, ok := locator.Locate(head, "", "", -1)
+	if !ok {
+		return false
+	}
+	_, end, ok := locator.Locate(tail, "", "", start)
+	length := len([]rune(a.SelectedText))
+	if !ok || end <= start || end-start > length*3/2 || end-start < length/2 {
+		return false
+	}
+	a.StartPosition, a.EndPosition = start, end
+	return true
+}
+
+// matchBook returns the book best matching a title and author, and its
+// score, or nil if none scores above bookMatchThreshold
+func matchBook(books []models.Book, title, author string) (*models.Book, float64) {
+	var best *models.Book
+	bestScore := 0.0
+	for i := range books {
+		score := bookMatchScore(books[i].Title, books[i].Author, title, author)
+		if score > bestScore {
+			best, bestScore = &books[i], score
+		}
+	}
+	if bestScore < bookMatchThreshold {
+		return nil, bestScore
+	}
+	return best, bestScore
+}
+
+// bookMatchScore scores how well a library book's title and author match
+// those in an export, from 0 to 1. Titles are also compared without their
+// subtitles, which e-readers often drop or add.
+func bookMatchScore(bookTitle, bookAuthor, title, author string) float64 {
+	a, b := normalizeTitle(bookTitle), normalizeTitle(title)
+	if a == "" || b == "" {
+		return 0
+	}
+	score := diceCoefficient(a, b)
+	if s := diceCoefficient(normalizeTitle(mainTitle(bookTitle)), normalizeTitle(mainTitle(title))); s > score {
+		score = s
+	}
+	if strings.TrimSpace(bookAuthor) == "" || strings.TrimSpace(author) == "" {
+		return score
+	}
+	return 0.8*score + 0.2*authorOverlap(bookAuthor, author)
+}
+
+// mainTitle strips a subtitle after a colon or dash
+func mainTitle(title string) string {
+	for _, sep := range []string{":", " - ", " – ", " — "} {
+		if i := strings.Index(title, sep); i > 0 {
+			title = title[:i]
+		}
+	}
+	return title
+}
+
+// normalizeTitle lower-cases a title and drops bracketed parts (series,
+// editions) and punctuation
+func normalizeTitle(title string) string {
+	var b strings.Builder
+	depth := 0
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r == '(' || r == '[':
+			depth++
+		case r == ')' || r == ']':
+			if depth > 0 {
+				depth--
+			}
+		case depth > 0:
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// diceCoefficient is the Sørensen–Dice similarity of the strings' bigrams
+func diceCoefficient(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) < 2 || len(rb) < 2 {
+		return 0
+	}
+	bigrams := make(map[string]int)
+	for i := 0; i+1 < len(ra); i++ {
+		bigrams[string(ra[i:i+2])]++
+	}
+	shared := 0
+	for i := 0; i+1 < len(rb); i++ {
+		bigram := string(rb[i : i+2])
+		if bigrams[bigram] > 0 {
+			bigrams[bigram]--
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(ra)+len(rb)-2)
+}
+
+// authorOverlap is the share of the shorter author's name tokens found in
+// the other, so "Tolstoy, Leo" matches "Leo Tolstoy"
+func authorOverlap(a, b string) float64 {
+	ta, tb := strings.Fields(normalizeTitle(a)), strings.Fields(normalizeTitle(b))
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	if len(ta) > len(tb) {
+		ta, tb = tb, ta
+	}
+	shared := 0
+	for _, token := range ta {
+		if containsString(tb, token) {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta))
+}
@@ -0,0 +1,430 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Clipping import formats
+const (
+	ClippingFormatKindle       = "kindle"        // Kindle "My Clippings.txt"
+	ClippingFormatKOReader     = "koreader"      // KOReader metadata.*.lua sidecar
+	ClippingFormatKOReaderJSON = "koreader_json" // KOReader JSON export
+)
+
+// Annotation import sources, recorded as provenance
+const (
+	ImportSourceKindle   = "kindle"
+	ImportSourceKOReader = "koreader"
+	ImportSourceW3C      = "w3c"
+)
+
+// Clipping is a highlight, note or bookmark read from an e-reader export
+type Clipping struct {
+	Title    string     `json:"title"`
+	Author   string     `json:"author,omitempty"`
+	Kind     string     `json:"kind"`           // highlight, note, bookmark
+	Text     string     `json:"text,omitempty"` // The highlighted passage
+	Note     string     `json:"note,omitempty"`
+	Page     int        `json:"page,omitempty"`
+	Location string     `json:"location,omitempty"` // Position in the source, e.g. a Kindle location range
+	Chapter  string     `json:"chapter,omitempty"`
+	Color    string     `json:"color,omitempty"`
+	AddedAt  *time.Time `json:"added_at,omitempty"`
+
+	locationStart, locationEnd int
+}
+
+// DetectClippingFormat guesses the format of an uploaded export
+func DetectClippingFormat(filename string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".lua":
+		return ClippingFormatKOReader
+	case ".json":
+		return ClippingFormatKOReaderJSON
+	case ".txt":
+		return ClippingFormatKindle
+	}
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\ufeff")))
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("[")):
+		return ClippingFormatKOReaderJSON
+	case bytes.HasPrefix(trimmed, []byte("return")) || bytes.HasPrefix(trimmed, []byte("--")):
+		return ClippingFormatKOReader
+	}
+	return ClippingFormatKindle
+}
+
+// ParseClippings parses an export in the given format
+func ParseClippings(format string, data []byte) ([]Clipping, error) {
+	switch format {
+	case ClippingFormatKindle:
+		return ParseKindleClippings(data), nil
+	case ClippingFormatKOReader:
+		return ParseKOReaderSidecar(data)
+	case ClippingFormatKOReaderJSON:
+		return ParseKOReaderJSON(data)
+	}
+	return nil, fmt.Errorf("%w format %q", ErrInvalid, format)
+}
+
+const kindleSeparator = "=========="
+
+// Words identifying the kind of a Kindle clipping in the languages Kindle
+// writes clippings in, checked in this order
+var kindleKindWords = []struct {
+	kind  string
+	words []string
+}{
+	{"bookmark", []string{"bookmark", "lesezeichen", "signet", "marcador", "segnalibro", "bladwijzer", "закладка", "ブックマーク", "书签"}},
+	{"note", []string{"note", "notiz", "nota", "notitie", "заметка", "メモ", "笔记"}},
+	{"highlight", []string{"highlight", "markierung", "surlignement", "subrayado", "evidenziazione", "destaque", "markering", "выделение", "ハイライト", "标注"}},
+}
+
+var (
+	kindlePageRe     = regexp.MustCompile(`(?i)(?:page|seite|página|pagina|страниц\S*)\s*(\d+)|(\d+)\s*ページ`)
+	kindleLocationRe = regexp.MustCompile(`(?i)(?:location|loc\.|position|posición|posizione|posição|emplacement|locatie|позиция|位置(?:no\.)?|#)\s*#?\s*(\d+)(?:\s*-\s*(\d+))?`)
+)
+
+// Layouts of the English "Added on" dates
+var kindleDateLayouts = []string{
+	"Monday, January 2, 2006 3:04:05 PM",
+	"Monday, 2 January 2006 15:04:05",
+	"Monday, January 2, 2006, 3:04 PM",
+	"Monday, 2 January 2006 15:04",
+}
+
+// ParseKindleClippings parses a Kindle "My Clippings.txt". Notes are
+// attached to the highlight ending at their location, and highlights
+// re-saved after being extended replace their earlier version.
+func ParseKindleClippings(data []byte) []Clipping {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	var clippings []Clipping
+	for _, block := range strings.Split(text, kindleSeparator) {
+		var lines []string
+		for _, line := range strings.Split(block, "\n") {
+			lines = append(lines, strings.TrimSpace(strings.ReplaceAll(line, "\ufeff", "")))
+		}
+		for len(lines) > 0 && lines[0] == "" {
+			lines = lines[1:]
+		}
+		if len(lines) < 2 {
+			continue
+		}
+
+		meta := lines[1]
+		kind := kindleKind(meta)
+		if kind == "" {
+			continue
+		}
+		c := Clipping{Kind: kind, Location: strings.TrimSpace(strings.TrimLeft(meta, "-– "))}
+		c.Title, c.Author = splitKindleTitle(lines[0])
+
+		parts := strings.Split(meta, "|")
+		details := strings.Join(parts[:len(parts)-1], "|")
+		if len(parts) == 1 {
+			details = meta
+		}
+		if m := kindlePageRe.FindStringSubmatch(details); m != nil {
+			c.Page, _ = strconv.Atoi(m[1] + m[2])
+		}
+		if m := kindleLocationRe.FindStringSubmatch(details); m != nil {
+			c.locationStart, _ = strconv.Atoi(m[1])
+			c.locationEnd = c.locationStart
+			if m[2] != "" {
+				c.locationEnd, _ = strconv.Atoi(m[2])
+			}
+		}
+		if len(parts) > 1 {
+			c.AddedAt = parseKindleDate(parts[len(parts)-1])
+		}
+
+		body := strings.TrimSpace(strings.Join(lines[2:], "\n"))
+		if kind == "note" {
+			c.Note = body
+		} else {
+			c.Text = body
+		}
+		clippings = append(clippings, c)
+	}
+	return mergeKindleClippings(clippings)
+}
+
+func kindleKind(meta string) string {
+	lower := strings.ToLower(meta)
+	if i := strings.Index(lower, "|"); i >= 0 {
+		lower = lower[:i]
+	}
+	for _, entry := range kindleKindWords {
+		for _, word := range entry.words {
+			if strings.Contains(lower, word) {
+				return entry.kind
+			}
+		}
+	}
+	return ""
+}
+
+// splitKindleTitle splits "Title (Author)"
+func splitKindleTitle(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	if !strings.HasSuffix(line, ")") {
+		return line, ""
+	}
+	depth := 0
+	for i := len(line) - 1; i >= 0; i-- {
+		switch line[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth == 0 {
+				title := strings.TrimSpace(line[:i])
+				if title == "" {
+					return line, ""
+				}
+				return title, strings.TrimSpace(line[i+1 : len(line)-1])
+			}
+		}
+	}
+	return line, ""
+}
+
+func parseKindleDate(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(strings.ToLower(s), "added on "); i >= 0 {
+		s = s[i+len("added on "):]
+	}
+	for _, layout := range kindleDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
+
+func mergeKindleClippings(clippings []Clipping) []Clipping {
+	var merged []Clipping
+	latest := make(map[string]int) // Title and start location of highlights -> index in merged
+	var notes []Clipping
+	for _, c := range clippings {
+		switch c.Kind {
+		case "note":
+			notes = append(notes, c)
+			continue
+		case "highlight":
+			key := fmt.Sprintf("%s\x00%d", c.Title, c.locationStart)
+			if i, ok := latest[key]; ok && c.locationStart > 0 {
+				merged[i] = c
+				continue
+			}
+			latest[key] = len(merged)
+		}
+		merged = append(merged, c)
+	}
+
+	for _, note := range notes {
+		attached := false
+		for i := range merged {
+			h := &merged[i]
+			if h.Kind != "highlight" || h.Title != note.Title || h.Note != "" || note.locationStart == 0 {
+				continue
+			}
+			if note.locationStart >= h.locationStart && note.locationStart <= h.locationEnd {
+				h.Kind = "note"
+				h.Note = note.Note
+				if note.AddedAt != nil {
+					h.AddedAt = note.AddedAt
+				}
+				attached = true
+				break
+			}
+		}
+		if !attached {
+			merged = append(merged, note)
+		}
+	}
+	return merged
+}
+
+// ParseKOReaderSidecar parses a KOReader metadata.<ext>.lua sidecar, in
+// both the current "annotations" layout and the legacy "bookmarks" one
+func ParseKOReaderSidecar(data []byte) ([]Clipping, error) {
+	root, err := parseLuaTable(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var title, author string
+	for _, key := range []string{"doc_props", "stats"} {
+		if props, ok := root[key].(map[string]interface{}); ok {
+			if title == "" {
+				title = luaString(props, "title")
+			}
+			if author == "" {
+				author = luaString(props, "authors")
+			}
+		}
+	}
+	author = strings.ReplaceAll(author, "\n", ", ")
+
+	var clippings []Clipping
+	if annotations, ok := root["annotations"]; ok {
+		for _, item := range luaList(annotations) {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			c := Clipping{
+				Title:   title,
+				Author:  author,
+				Text:    luaString(entry, "text"),
+				Note:    luaString(entry, "note"),
+				Page:    luaInt(entry, "pageno"),
+				Chapter: luaString(entry, "chapter"),
+				Color:   luaString(entry, "color"),
+				AddedAt: parseKOReaderTime(luaString(entry, "datetime")),
+			}
+			if c.Page == 0 {
+				c.Page = luaInt(entry, "page")
+			}
+			c.Location = luaString(entry, "pos0")
+			if c.Location == "" {
+				c.Location = luaString(entry, "page")
+			}
+			switch {
+			case entry["drawer"] == nil && entry["pos0"] == nil:
+				c.Kind = "bookmark"
+				c.Text = ""
+			case c.Note != "":
+				c.Kind = "note"
+			default:
+				c.Kind = "highlight"
+			}
+			clippings = append(clippings, c)
+		}
+		return clippings, nil
+	}
+
+	for _, item := range luaList(root["bookmarks"]) {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		c := Clipping{
+			Title:    title,
+			Author:   author,
+			Page:     luaInt(entry, "page"),
+			Chapter:  luaString(entry, "chapter"),
+			Location: luaString(entry, "pos0"),
+			AddedAt:  parseKOReaderTime(luaString(entry, "datetime")),
+		}
+		if c.Location == "" {
+			c.Location = luaString(entry, "page")
+		}
+		if highlighted, _ := entry["highlighted"].(bool); highlighted {
+			c.Text = luaString(entry, "notes")
+			// "text" holds the user's note, or a generated "Page N ..." label
+			if note := luaString(entry, "text"); note != "" && !koreaderLabelRe.MatchString(note) {
+				c.Note = note
+			}
+			c.Kind = "highlight"
+			if c.Note != "" {
+				c.Kind = "note"
+			}
+		} else {
+			c.Kind = "bookmark"
+		}
+		clippings = append(clippings, c)
+	}
+	return clippings, nil
+}
+
+var koreaderLabelRe = regexp.MustCompile(`^Page \d+ .* @ \d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}$`)
+
+// koreaderExport is a book in KOReader's JSON export
+type koreaderExport struct {
+	Title   string `json:"title"`
+	Author  string `json:"author"`
+	Entries []struct {
+		Text    string      `json:"text"`
+		Note    string      `json:"note"`
+		Page    json.Number `json:"page"`
+		Time    int64       `json:"time"`
+		Chapter string      `json:"chapter"`
+		Sort    string      `json:"sort"`
+		Drawer  string      `json:"drawer"`
+		Color   string      `json:"color"`
+	} `json:"entries"`
+}
+
+// ParseKOReaderJSON parses KOReader's JSON export of one book, or of
+// several as an array or under "documents"
+func ParseKOReaderJSON(data []byte) ([]Clipping, error) {
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\ufeff")))
+	var books []koreaderExport
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &books); err != nil {
+			return nil, fmt.Errorf("%w KOReader export: %w", ErrInvalid, err)
+		}
+	} else {
+		var container struct {
+			koreaderExport
+			Documents []koreaderExport `json:"documents"`
+		}
+		if err := json.Unmarshal(data, &container); err != nil {
+			return nil, fmt.Errorf("%w KOReader export: %w", ErrInvalid, err)
+		}
+		books = append(container.Documents, container.koreaderExport)
+	}
+
+	var clippings []Clipping
+	for _, book := range books {
+		for _, entry := range book.Entries {
+			c := Clipping{
+				Title:   book.Title,
+				Author:  book.Author,
+				Text:    entry.Text,
+				Note:    entry.Note,
+				Chapter: entry.Chapter,
+				Color:   entry.Color,
+			}
+			if page, err := entry.Page.Int64(); err == nil {
+				c.Page = int(page)
+			}
+			c.Location = entry.Page.String()
+			if entry.Time > 0 {
+				t := time.Unix(entry.Time, 0).UTC()
+				c.AddedAt = &t
+				c.Location += "@" + strconv.FormatInt(entry.Time, 10)
+			}
+			switch {
+			case entry.Sort == "bookmark" || (entry.Text == "" && entry.Note == ""):
+				c.Kind = "bookmark"
+			case entry.Note != "":
+				c.Kind = "note"
+			default:
+				c.Kind = "highlight"
+			}
+			clippings = append(clippings, c)
+		}
+	}
+	return clippings, nil
+}
+
+func parseKOReaderTime(s string) *time.Time {
+	if s == "" {
+		return nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
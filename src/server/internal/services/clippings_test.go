@@ -0,0 +1,199 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/classius/server/internal/models"
+)
+
+const kindleSample = "\ufeffMeditations (Marcus Aurelius)\r\n" +
+	"- Your Highlight on page 12 | Location 150-152 | Added on Monday, March 4, 2024 9:15:02 PM\r\n\r\n" +
+	"You have power over your mind\r\n==========\r\n" +
+	"Meditations (Marcus Aurelius)\r\n" +
+	"- Your Highlight on page 12 | Location 150-154 | Added on Monday, March 4, 2024 9:16:10 PM\r\n\r\n" +
+	"You have power over your mind - not outside events.\r\n==========\r\n" +
+	"Meditations (Marcus Aurelius)\r\n" +
+	"- Your Note on page 12 | Location 154 | Added on Monday, March 4, 2024 9:17:00 PM\r\n\r\n" +
+	"Realize this, and you will find strength.\r\n==========\r\n" +
+	"Der Prozess (Kafka, Franz)\r\n" +
+	"- Ihre Markierung auf Seite 7 | Position 88-90 | Hinzugefügt am Montag, 4. März 2024 21:15:02\r\n\r\n" +
+	"Jemand mußte Josef K. verleumdet haben\r\n==========\r\n" +
+	"Les Misérables (Tome I) (Victor Hugo)\r\n" +
+	"- Votre signet à l'emplacement 300 | Ajouté le lundi 4 mars 2024 21:15:02\r\n\r\n\r\n==========\r\n"
+
+func TestParseKindleClippings(t *testing.T) {
+	clippings := ParseKindleClippings([]byte(kindleSample))
+	if len(clippings) != 3 {
+		t.Fatalf("expected 3 clippings, got %d: %+v", len(clippings), clippings)
+	}
+
+	c := clippings[0]
+	if c.Title != "Meditations" || c.Author != "Marcus Aurelius" {
+		t.Errorf("unexpected title/author %q/%q", c.Title, c.Author)
+	}
+	if c.Text != "You have power over your mind - not outside events." {
+		t.Errorf("extended highlight should replace the earlier one, got %q", c.Text)
+	}
+	if c.Kind != "note" || c.Note != "Realize this, and you will find strength." {
+		t.Errorf("note should attach to its highlight, got %q %q", c.Kind, c.Note)
+	}
+	if c.Page != 12 || c.AddedAt == nil || c.AddedAt.Year() != 2024 {
+		t.Errorf("unexpected page or date: %d %v", c.Page, c.AddedAt)
+	}
+
+	if c := clippings[1]; c.Kind != "highlight" || c.Author != "Kafka, Franz" || c.Page != 7 || c.locationStart != 88 {
+		t.Errorf("unexpected German clipping %+v", c)
+	}
+	if c := clippings[2]; c.Kind != "bookmark" || c.Title != "Les Misérables (Tome I)" || c.Author != "Victor Hugo" || c.locationStart != 300 {
+		t.Errorf("unexpected French bookmark %+v", c)
+	}
+}
+
+func TestParseKOReaderSidecar(t *testing.T) {
+	sidecar := `-- we can read Lua syntax here!
+return {
+    ["annotations"] = {
+        [1] = {
+            ["chapter"] = "Book I",
+            ["color"] = "yellow",
+            ["datetime"] = "2024-03-04 21:15:02",
+            ["drawer"] = "lighten",
+            ["pageno"] = 12,
+            ["pos0"] = "/body/DocFragment[2]/body/p[3]/text().0",
+            ["text"] = "The \"universe\" is change;\
+our life is what our thoughts make it.",
+        },
+        [2] = {
+            ["datetime"] = "2024-03-05 08:00:00",
+            ["note"] = [[Compare Epictetus]],
+            ["drawer"] = "underscore",
+            ["pageno"] = 40,
+            ["pos0"] = "/body/DocFragment[4]/body/p[1]/text().0",
+            ["text"] = "Waste no more time",
+        },
+        [3] = {
+            ["datetime"] = "2024-03-06 10:00:00",
+            ["page"] = "/body/DocFragment[5]/body/p[1]/text().0",
+            ["pageno"] = 55,
+            ["text"] = "in Book V",
+        },
+    },
+    ["doc_props"] = {
+        ["authors"] = "Marcus Aurelius",
+        ["title"] = "Meditations",
+    },
+    percent_finished = 0.25,
+    summary = { status = "reading" },
+}`
+	clippings, err := ParseKOReaderSidecar([]byte(sidecar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clippings) != 3 {
+		t.Fatalf("expected 3 clippings, got %d", len(clippings))
+	}
+	if c := clippings[0]; c.Kind != "highlight" || c.Title != "Meditations" || c.Author != "Marcus Aurelius" ||
+		c.Text != "The \"universe\" is change;\nour life is what our thoughts make it." || c.Page != 12 || c.AddedAt == nil {
+		t.Errorf("unexpected highlight %+v", c)
+	}
+	if c := clippings[1]; c.Kind != "note" || c.Note != "Compare Epictetus" {
+		t.Errorf("unexpected note %+v", c)
+	}
+	if c := clippings[2]; c.Kind != "bookmark" || c.Text != "" || c.Page != 55 {
+		t.Errorf("unexpected bookmark %+v", c)
+	}
+
+	legacy := `return {
+    ["bookmarks"] = {
+        [1] = {
+            ["highlighted"] = true,
+            ["notes"] = "Waste no more time",
+            ["page"] = 40,
+            ["text"] = "Page 40 Waste no more time @ 2020-01-02 03:04:05",
+            ["datetime"] = "2020-01-02 03:04:05",
+        },
+    },
+    ["stats"] = { ["title"] = "Meditations", ["authors"] = "Marcus Aurelius" },
+}`
+	clippings, err = ParseKOReaderSidecar([]byte(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clippings) != 1 || clippings[0].Kind != "highlight" || clippings[0].Text != "Waste no more time" || clippings[0].Note != "" {
+		t.Errorf("unexpected legacy clippings %+v", clippings)
+	}
+
+	if _, err := ParseKOReaderSidecar([]byte(`return { ["a"] = `)); err == nil {
+		t.Error("expected an error for truncated input")
+	}
+	deep := "return " + strings.Repeat("{", 100000) + strings.Repeat("}", 100000)
+	if _, err := ParseKOReaderSidecar([]byte(deep)); err == nil || !strings.Contains(err.Error(), "invalid lua") {
+		t.Errorf("expected an invalid lua error for deeply nested tables, got %v", err)
+	}
+	nested := "return " + strings.Repeat("{", maxLuaDepth) + strings.Repeat("}", maxLuaDepth)
+	if _, err := parseLuaTable([]byte(nested)); err != nil {
+		t.Errorf("tables nested %d levels: %v", maxLuaDepth, err)
+	}
+}
+
+func TestParseKOReaderJSON(t *testing.T) {
+	export := `{"title": "Meditations", "author": "Marcus Aurelius", "entries": [
+		{"text": "Waste no more time", "note": "Compare Epictetus", "page": 40, "time": 1709586902, "chapter": "Book X", "sort": "highlight"},
+		{"text": "", "page": 55, "time": 1709586999, "sort": "bookmark"}
+	]}`
+	clippings, err := ParseKOReaderJSON([]byte(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clippings) != 2 || clippings[0].Kind != "note" || clippings[0].Page != 40 || clippings[1].Kind != "bookmark" {
+		t.Fatalf("unexpected clippings %+v", clippings)
+	}
+	if DetectClippingFormat("", []byte(export)) != ClippingFormatKOReaderJSON ||
+		DetectClippingFormat("metadata.epub.lua", nil) != ClippingFormatKOReader ||
+		DetectClippingFormat("", []byte(kindleSample)) != ClippingFormatKindle {
+		t.Error("unexpected detected format")
+	}
+}
+
+func TestMatchBook(t *testing.T) {
+	books := []models.Book{
+		{Title: "Meditations", Author: "Marcus Aurelius"},
+		{Title: "The Trial", Author: "Franz Kafka"},
+		{Title: "Crime and Punishment", Author: "Fyodor Dostoevsky"},
+	}
+	cases := []struct {
+		title, author, want string
+	}{
+		{"Meditations: A New Translation", "Aurelius, Marcus", "Meditations"},
+		{"Crime & Punishment (Penguin Classics)", "Dostoyevsky, Fyodor", "Crime and Punishment"},
+		{"The Trial", "", "The Trial"},
+		{"Beyond Good and Evil", "Friedrich Nietzsche", ""},
+	}
+	for _, tc := range cases {
+		book, score := matchBook(books, tc.title, tc.author)
+		got := ""
+		if book != nil {
+			got = book.Title
+		}
+		if got != tc.want {
+			t.Errorf("%q: matched %q (score %.2f), want %q", tc.title, got, score, tc.want)
+		}
+	}
+}
+
+func TestAnchorClipping(t *testing.T) {
+	locator := NewQuoteLocator("Begin the morning by saying to thyself, I shall meet with the busy-body, the ungrateful, arrogant, deceitful, envious, unsocial.")
+	a := &models.Annotation{SelectedText: "Begin the morning by saying to thyself, I shall meet with the busybody, the ungrateful, arrogant, deceitful, envious, unsocial."}
+	if !anchorClipping(locator, a) || a.StartPosition != 0 || a.EndPosition != len([]rune(locator.Text())) {
+		t.Errorf("expected the passage anchored by its ends, got %d-%d", a.StartPosition, a.EndPosition)
+	}
+	a = &models.Annotation{SelectedText: "the busy-body"}
+	if !anchorClipping(locator, a) || runeSlice(locator.Text(), a.StartPosition, a.EndPosition) != "the busy-body" {
+		t.Errorf("unexpected anchor %d-%d", a.StartPosition, a.EndPosition)
+	}
+	a = &models.Annotation{SelectedText: "not in the text"}
+	if anchorClipping(locator, a) {
+		t.Error("expected no anchor")
+	}
+}
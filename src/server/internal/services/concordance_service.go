@@ -0,0 +1,554 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/classius/server/internal/models"
+)
+
+// concordanceCacheSize is how many books' texts are kept in memory for
+// keyword-in-context and collocation queries
+const concordanceCacheSize = 4
+
+// ConcordanceService builds and queries per-book word indexes
+type ConcordanceService struct {
+	db *gorm.DB
+
+	mu    sync.Mutex
+	texts map[uuid.UUID]*concordanceText // Recently queried books
+}
+
+// NewConcordanceService creates a new concordance service
+func NewConcordanceService(db *gorm.DB) *ConcordanceService {
+	return &ConcordanceService{db: db, texts: make(map[uuid.UUID]*concordanceText)}
+}
+
+// concordanceText is an indexed book's text and token stream, as of the
+// index build it was loaded for
+type concordanceText struct {
+	builtAt time.Time
+	text    []rune
+	forms   []string       // Normalized form of each token, by ordinal
+	totals  map[string]int // Occurrences of each form
+	used    time.Time
+}
+
+// KWICQuery describes a keyword-in-context lookup
+type KWICQuery struct {
+	Word    string `json:"word"`
+	Stem    bool   `json:"stem"`    // Match every form sharing the word's stem
+	Context int    `json:"context"` // Characters of context on each side
+	Limit   int    `json:"limit"`
+	Offset  int    `json:"offset"`
+}
+
+// KWICLine is one occurrence of a word with its surrounding text
+type KWICLine struct {
+	Left         string `json:"left"`
+	Keyword      string `json:"keyword"`
+	Right        string `json:"right"`
+	Start        int    `json:"start_position"`
+	End          int    `json:"end_position"`
+	PageNumber   int    `json:"page_number,omitempty"`
+	Chapter      int    `json:"chapter"`
+	ChapterTitle string `json:"chapter_title"`
+}
+
+// KWICResult is the response for a keyword-in-context lookup
+type KWICResult struct {
+	Word     string     `json:"word"`
+	Language string     `json:"language"`
+	Forms    []string   `json:"forms"`
+	Total    int        `json:"total"`
+	Lines    []KWICLine `json:"lines"`
+}
+
+// TermFrequency is a word with its number of occurrences
+type TermFrequency struct {
+	Term    string   `json:"term"`
+	Forms   []string `json:"forms,omitempty"`
+	Count   int      `json:"count"`
+	PerTenK float64  `json:"per_10k"`
+}
+
+// Collocation is a word that appears near the query word
+type Collocation struct {
+	Term      string  `json:"term"`
+	CoCount   int     `json:"co_occurrences"`
+	TermCount int     `json:"term_count"`
+	PMI       float64 `json:"pmi"`
+}
+
+// ChapterDistribution counts occurrences of a word in one chapter
+type ChapterDistribution struct {
+	Chapter int     `json:"chapter"`
+	Title   string  `json:"title"`
+	Start   int     `json:"start_position"`
+	End     int     `json:"end_position"`
+	Count   int     `json:"count"`
+	PerTenK float64 `json:"per_10k"`
+}
+
+// BuildIndex tokenizes a book's extracted text and replaces its concordance index
+func (s *ConcordanceService) BuildIndex(ctx context.Context, bookID uuid.UUID) (*models.ConcordanceIndex, error) {
+	var book models.Book
+	if err := s.db.WithContext(ctx).Where("id = ?", bookID).First(&book).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("book %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to retrieve book: %w", err)
+	}
+
+	var content models.BookContent
+	if err := s.db.WithContext(ctx).Where("book_id = ?", bookID).First(&content).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("book content %w", ErrNotExtracted)
+		}
+		return nil, fmt.Errorf("failed to retrieve book content: %w", err)
+	}
+
+	language := DetectLanguage(LanguageSample(content.FullText), book.Language)
+
+	tokens := Tokenize(content.FullText)
+	termsByForm := make(map[string]*models.ConcordanceTerm)
+	for ordinal, tok := range tokens {
+		form := NormalizeForLanguage(tok.Text, language)
+		if len(form) > 100 {
+			continue
+		}
+		term, ok := termsByForm[form]
+		if !ok {
+			term = &models.ConcordanceTerm{
+				ID:         uuid.New(),
+				BookID:     bookID,
+				Term:       form,
+				Stem:       Stem(form, language),
+				IsStopword: IsStopword(form, language),
+			}
+			termsByForm[form] = term
+		}
+		term.Count++
+		term.Positions = append(term.Positions, tok.Start)
+		term.Ordinals = append(term.Ordinals, ordinal)
+	}
+
+	chapters := DetectChapters(content.FullText)
+	indexChapters := make([]models.ConcordanceChapter, len(chapters))
+	for i, ch := range chapters {
+		indexChapters[i] = models.ConcordanceChapter{Index: ch.Index, Title: ch.Title, Start: ch.Start, End: ch.End}
+	}
+	for _, tok := range tokens {
+		if idx := ChapterAt(chapters, tok.Start); idx >= 0 {
+			indexChapters[idx].TokenCount++
+		}
+	}
+
+	terms := make([]models.ConcordanceTerm, 0, len(termsByForm))
+	for _, term := range termsByForm {
+		terms = append(terms, *term)
+	}
+
+	index := &models.ConcordanceIndex{
+		BookID:     bookID,
+		Language:   language,
+		TokenCount: len(tokens),
+		TypeCount:  len(terms),
+		Chapters:   indexChapters,
+		BuiltAt:    time.Now(),
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("book_id = ?", bookID).Delete(&models.ConcordanceTerm{}).Error; err != nil {
+			return fmt.Errorf("failed to clear old terms: %w", err)
+		}
+		if err := tx.Where("book_id = ?", bookID).Delete(&models.ConcordanceIndex{}).Error; err != nil {
+			return fmt.Errorf("failed to clear old index: %w", err)
+		}
+		if len(terms) > 0 {
+			if err := tx.CreateInBatches(terms, 500).Error; err != nil {
+				return fmt.Errorf("failed to store terms: %w", err)
+			}
+		}
+		if err := tx.Create(index).Error; err != nil {
+			return fmt.Errorf("failed to store index: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	delete(s.texts, bookID)
+	s.mu.Unlock()
+
+	return index, nil
+}
+
+// GetIndex returns the concordance index summary for a book
+func (s *ConcordanceService) GetIndex(ctx context.Context, bookID uuid.UUID) (*models.ConcordanceIndex, error) {
+	var index models.ConcordanceIndex
+	if err := s.db.WithContext(ctx).Where("book_id = ?", bookID).First(&index).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("concordance index %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to retrieve concordance index: %w", err)
+	}
+	return &index, nil
+}
+
+// KeywordInContext returns every occurrence of a word with surrounding text
+func (s *ConcordanceService) KeywordInContext(ctx context.Context, bookID uuid.UUID, query KWICQuery) (*KWICResult, error) {
+	index, err := s.GetIndex(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	terms, err := s.findTerms(ctx, bookID, index.Language, query.Word, query.Stem)
+	if err != nil {
+		return nil, err
+	}
+
+	var book models.Book
+	if err := s.db.WithContext(ctx).Select("id, page_count").Where("id = ?", bookID).First(&book).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve book: %w", err)
+	}
+	loaded, err := s.loadText(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	text := loaded.text
+
+	var positions []int
+	forms := make([]string, 0, len(terms))
+	for _, term := range terms {
+		forms = append(forms, term.Term)
+		positions = append(positions, term.Positions...)
+	}
+	sort.Ints(positions)
+
+	result := &KWICResult{
+		Word:     query.Word,
+		Language: index.Language,
+		Forms:    forms,
+		Total:    len(positions),
+		Lines:    []KWICLine{},
+	}
+
+	if query.Offset >= len(positions) {
+		return result, nil
+	}
+	end := query.Offset + query.Limit
+	if end > len(positions) {
+		end = len(positions)
+	}
+
+	for _, pos := range positions[query.Offset:end] {
+		wordEnd := pos
+		for wordEnd < len(text) && isWordRune(text[wordEnd]) {
+			wordEnd++
+		}
+
+		line := KWICLine{
+			Left:    contextBefore(text, pos, query.Context),
+			Keyword: string(text[pos:wordEnd]),
+			Right:   contextAfter(text, wordEnd, query.Context),
+			Start:   pos,
+			End:     wordEnd,
+			Chapter: -1,
+		}
+		if book.PageCount > 0 && len(text) > 0 {
+			line.PageNumber = pos*book.PageCount/len(text) + 1
+		}
+		for _, ch := range index.Chapters {
+			if pos >= ch.Start && pos < ch.End {
+				line.Chapter = ch.Index
+				line.ChapterTitle = ch.Title
+				break
+			}
+		}
+		result.Lines = append(result.Lines, line)
+	}
+
+	return result, nil
+}
+
+// Frequencies returns the most frequent words in a book
+func (s *ConcordanceService) Frequencies(ctx context.Context, bookID uuid.UUID, limit int, includeStopwords, stem bool) ([]TermFrequency, error) {
+	index, err := s.GetIndex(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.db.WithContext(ctx).Model(&models.ConcordanceTerm{}).Where("book_id = ?", bookID)
+	if !includeStopwords {
+		query = query.Where("is_stopword = ?", false)
+	}
+
+	frequencies := []TermFrequency{}
+	if stem {
+		var rows []struct {
+			Stem  string
+			Count int
+			Forms string
+		}
+		if err := query.Select("stem, SUM(count) AS count, STRING_AGG(term, ',' ORDER BY count DESC) AS forms").
+			Group("stem").
+			Order("count DESC").
+			Limit(limit).
+			Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to compute frequencies: %w", err)
+		}
+		for _, row := range rows {
+			forms := strings.Split(row.Forms, ",")
+			frequencies = append(frequencies, TermFrequency{
+				Term:    forms[0],
+				Forms:   forms,
+				Count:   row.Count,
+				PerTenK: perTenThousand(row.Count, index.TokenCount),
+			})
+		}
+		return frequencies, nil
+	}
+
+	var terms []models.ConcordanceTerm
+	if err := query.Select("term, count").Order("count DESC, term ASC").Limit(limit).Find(&terms).Error; err != nil {
+		return nil, fmt.Errorf("failed to compute frequencies: %w", err)
+	}
+	for _, term := range terms {
+		frequencies = append(frequencies, TermFrequency{
+			Term:    term.Term,
+			Count:   term.Count,
+			PerTenK: perTenThousand(term.Count, index.TokenCount),
+		})
+	}
+	return frequencies, nil
+}
+
+// Collocations returns words that co-occur with the query word within a window
+// of tokens, ranked by pointwise mutual information.
+func (s *ConcordanceService) Collocations(ctx context.Context, bookID uuid.UUID, word string, stem bool, window, minCount, limit int, includeStopwords bool) ([]Collocation, error) {
+	index, err := s.GetIndex(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	terms, err := s.findTerms(ctx, bookID, index.Language, word, stem)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded, err := s.loadText(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	forms, totals := loaded.forms, loaded.totals
+
+	self := make(map[string]bool)
+	nodeCount := 0
+	for _, term := range terms {
+		self[term.Term] = true
+		nodeCount += term.Count
+	}
+
+	coCounts := make(map[string]int)
+	seen := make(map[int]bool)
+	for _, term := range terms {
+		for _, ordinal := range term.Ordinals {
+			for i := ordinal - window; i <= ordinal+window; i++ {
+				if i < 0 || i >= len(forms) || i == ordinal || seen[i] {
+					continue
+				}
+				form := forms[i]
+				if self[form] || (!includeStopwords && IsStopword(form, index.Language)) {
+					continue
+				}
+				// Count each collocate token once even when windows overlap
+				seen[i] = true
+				coCounts[form]++
+			}
+		}
+	}
+
+	total := float64(len(forms))
+	span := float64(2 * window)
+	collocations := []Collocation{}
+	for form, co := range coCounts {
+		if co < minCount {
+			continue
+		}
+		expected := float64(nodeCount) * float64(totals[form]) * span / total
+		collocations = append(collocations, Collocation{
+			Term:      form,
+			CoCount:   co,
+			TermCount: totals[form],
+			PMI:       math.Round(math.Log2(float64(co)/expected)*1000) / 1000,
+		})
+	}
+
+	sort.Slice(collocations, func(i, j int) bool {
+		if collocations[i].PMI == collocations[j].PMI {
+			return collocations[i].CoCount > collocations[j].CoCount
+		}
+		return collocations[i].PMI > collocations[j].PMI
+	})
+	if len(collocations) > limit {
+		collocations = collocations[:limit]
+	}
+
+	return collocations, nil
+}
+
+// Distribution counts occurrences of a word in each chapter of a book
+func (s *ConcordanceService) Distribution(ctx context.Context, bookID uuid.UUID, word string, stem bool) ([]ChapterDistribution, error) {
+	index, err := s.GetIndex(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	terms, err := s.findTerms(ctx, bookID, index.Language, word, stem)
+	if err != nil {
+		return nil, err
+	}
+
+	distribution := make([]ChapterDistribution, len(index.Chapters))
+	for i, ch := range index.Chapters {
+		distribution[i] = ChapterDistribution{Chapter: ch.Index, Title: ch.Title, Start: ch.Start, End: ch.End}
+	}
+
+	for _, term := range terms {
+		for _, pos := range term.Positions {
+			for i, ch := range index.Chapters {
+				if pos >= ch.Start && pos < ch.End {
+					distribution[i].Count++
+					break
+				}
+			}
+		}
+	}
+
+	for i, ch := range index.Chapters {
+		distribution[i].PerTenK = perTenThousand(distribution[i].Count, ch.TokenCount)
+	}
+
+	return distribution, nil
+}
+
+// loadText returns the text and token stream of an indexed book, tokenizing
+// it only when it is not cached for the index's current build
+func (s *ConcordanceService) loadText(ctx context.Context, index *models.ConcordanceIndex) (*concordanceText, error) {
+	s.mu.Lock()
+	cached, ok := s.texts[index.BookID]
+	if ok && cached.builtAt.Equal(index.BuiltAt) {
+		cached.used = time.Now()
+		s.mu.Unlock()
+		return cached, nil
+	}
+	s.mu.Unlock()
+
+	var content models.BookContent
+	if err := s.db.WithContext(ctx).Where("book_id = ?", index.BookID).First(&content).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve book content: %w", err)
+	}
+	loaded := &concordanceText{builtAt: index.BuiltAt, text: []rune(content.FullText), totals: make(map[string]int), used: time.Now()}
+	tokens := Tokenize(content.FullText)
+	loaded.forms = make([]string, len(tokens))
+	for i, tok := range tokens {
+		loaded.forms[i] = NormalizeForLanguage(tok.Text, index.Language)
+		loaded.totals[loaded.forms[i]]++
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.texts[index.BookID] = loaded
+	if len(s.texts) > concordanceCacheSize {
+		var oldest uuid.UUID
+		for id, t := range s.texts {
+			if oldest == uuid.Nil || t.used.Before(s.texts[oldest].used) {
+				oldest = id
+			}
+		}
+		delete(s.texts, oldest)
+	}
+	return loaded, nil
+}
+
+// findTerms loads the index entries matching a query word, optionally by stem
+func (s *ConcordanceService) findTerms(ctx context.Context, bookID uuid.UUID, language, word string, stem bool) ([]models.ConcordanceTerm, error) {
+	form := NormalizeForLanguage(strings.TrimSpace(word), language)
+	if form == "" {
+		return nil, fmt.Errorf("%w: word is required", ErrInvalid)
+	}
+
+	query := s.db.WithContext(ctx).Where("book_id = ?", bookID)
+	if stem {
+		query = query.Where("stem = ?", Stem(form, language))
+	} else {
+		query = query.Where("term = ?", form)
+	}
+
+	var terms []models.ConcordanceTerm
+	if err := query.Order("count DESC").Find(&terms).Error; err != nil {
+		return nil, fmt.Errorf("failed to look up term: %w", err)
+	}
+	return terms, nil
+}
+
+func perTenThousand(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(count)*10000/float64(total)*100) / 100
+}
+
+func isWordRune(r rune) bool {
+	return r == '\'' || r == '’' || isLetterOrMark(r)
+}
+
+// contextBefore returns up to width runes ending at pos, trimmed to a word boundary
+func contextBefore(text []rune, pos, width int) string {
+	start := pos - width
+	if start <= 0 {
+		return collapseSpace(string(text[:pos]))
+	}
+	for start < pos && isWordRune(text[start-1]) {
+		start++
+	}
+	return collapseSpace(string(text[start:pos]))
+}
+
+// contextAfter returns up to width runes starting at pos, trimmed to a word boundary
+func contextAfter(text []rune, pos, width int) string {
+	end := pos + width
+	if end >= len(text) {
+		return collapseSpace(string(text[pos:]))
+	}
+	for end > pos && isWordRune(text[end]) {
+		end--
+	}
+	return collapseSpace(string(text[pos:end]))
+}
+
+func collapseSpace(s string) string {
+	if s == "" {
+		return s
+	}
+	joined := strings.Join(strings.Fields(s), " ")
+	if unicode.IsSpace(rune(s[0])) {
+		joined = " " + joined
+	}
+	if unicode.IsSpace(rune(s[len(s)-1])) && joined != " " {
+		joined += " "
+	}
+	return joined
+}
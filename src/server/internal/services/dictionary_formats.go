@@ -0,0 +1,894 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dictionary formats understood by the loader
+const (
+	DictionaryFormatStarDict   = "stardict"
+	DictionaryFormatDICT       = "dict"
+	DictionaryFormatWhitaker   = "whitaker"
+	DictionaryFormatGreekMorph = "greek-morph"
+)
+
+// DictionaryInfo describes a loaded dictionary file
+type DictionaryInfo struct {
+	Name      string `json:"name"`
+	Format    string `json:"format"`
+	Language  string `json:"language"`
+	Path      string `json:"path"`
+	Headwords int    `json:"headwords"`
+}
+
+// Analysis is one morphological reading of a word form
+type Analysis struct {
+	Form           string   `json:"form"`
+	Lemma          string   `json:"lemma"`
+	DictionaryForm string   `json:"dictionary_form,omitempty"` // e.g. "rex, regis" or "amo, amare, amavi, amatus"
+	PartOfSpeech   string   `json:"part_of_speech"`
+	Parse          string   `json:"parse,omitempty"`
+	Definitions    []string `json:"definitions,omitempty"`
+	Source         string   `json:"source"`
+}
+
+// Definition is a headword's entry in a definition dictionary
+type Definition struct {
+	Headword string `json:"headword"`
+	Text     string `json:"text"`
+	Source   string `json:"source"`
+}
+
+// dictionarySource is implemented by every loaded dictionary. Keys passed to
+// the lookup methods are already normalized with NormalizeForLanguage.
+type dictionarySource interface {
+	// Info describes the dictionary
+	Info() DictionaryInfo
+
+	// Analyze returns morphological analyses of a word form; definition-only
+	// dictionaries return nil
+	Analyze(key string) []Analysis
+
+	// Define returns definitions for a headword; morphology-only
+	// dictionaries return nil
+	Define(key string) []Definition
+}
+
+// LoadDictionaries scans a directory for dictionary files. Files are grouped
+// by language using their parent directory name (e.g. "dictionaries/la/"),
+// except Whitaker and Greek morphology tables which are always Latin and
+// Greek. Files that fail to load are reported in the returned errors and
+// skipped.
+func LoadDictionaries(root string) ([]dictionarySource, []error) {
+	var sources []dictionarySource
+	var errs []error
+
+	walkErr := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		language := NormalizeLanguage(filepath.Base(filepath.Dir(path)))
+		name := strings.ToLower(info.Name())
+
+		var source dictionarySource
+		var loadErr error
+		switch {
+		case strings.HasSuffix(name, ".ifo"):
+			source, loadErr = loadStarDict(path, language)
+		case strings.HasSuffix(name, ".index"):
+			source, loadErr = loadDICT(path, language)
+		case name == "dictline.gen" || strings.HasSuffix(name, ".dictline"):
+			source, loadErr = loadWhitaker(path)
+		case strings.HasSuffix(name, ".morph.tsv"):
+			source, loadErr = loadGreekMorph(path)
+		default:
+			return nil
+		}
+
+		if loadErr != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, loadErr))
+			return nil
+		}
+		sources = append(sources, source)
+		return nil
+	})
+	if walkErr != nil && !os.IsNotExist(walkErr) {
+		errs = append(errs, walkErr)
+	}
+
+	return sources, errs
+}
+
+// readDictData reads a .dict file, transparently decompressing dictzip
+// (.dict.dz), which is gzip-compatible
+func readDictData(base string) ([]byte, error) {
+	if data, err := os.ReadFile(base + ".dict"); err == nil {
+		return data, nil
+	}
+
+	file, err := os.Open(base + ".dict.dz")
+	if err != nil {
+		return nil, fmt.Errorf("dictionary data file not found")
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dictzip data: %w", err)
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// definitionIndex maps normalized headwords to byte ranges of a data file
+type definitionIndex struct {
+	info    DictionaryInfo
+	data    []byte
+	entries map[string][]definitionRef
+	decode  func(raw []byte) string
+}
+
+type definitionRef struct {
+	headword string
+	offset   uint64
+	size     uint64
+}
+
+func (d *definitionIndex) Info() DictionaryInfo {
+	return d.info
+}
+
+func (d *definitionIndex) Analyze(key string) []Analysis {
+	return nil
+}
+
+func (d *definitionIndex) Define(key string) []Definition {
+	refs := d.entries[key]
+	definitions := make([]Definition, 0, len(refs))
+	for _, ref := range refs {
+		end := ref.offset + ref.size
+		if end > uint64(len(d.data)) {
+			continue
+		}
+		text := strings.TrimSpace(d.decode(d.data[ref.offset:end]))
+		if text == "" {
+			continue
+		}
+		definitions = append(definitions, Definition{Headword: ref.headword, Text: text, Source: d.info.Name})
+	}
+	return definitions
+}
+
+func (d *definitionIndex) add(headword string, offset, size uint64) {
+	key := NormalizeForLanguage(headword, d.info.Language)
+	if key == "" {
+		return
+	}
+	d.entries[key] = append(d.entries[key], definitionRef{headword: headword, offset: offset, size: size})
+}
+
+// loadStarDict loads a StarDict dictionary from its .ifo file together with
+// the matching .idx and .dict(.dz) files
+func loadStarDict(ifoPath, language string) (dictionarySource, error) {
+	ifo, err := os.ReadFile(ifoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(ifo), "\r\n", "\n"), "\n")
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "StarDict's dict ifo file") {
+		return nil, fmt.Errorf("invalid StarDict ifo header")
+	}
+	meta := make(map[string]string)
+	for _, line := range lines[1:] {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			meta[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	base := strings.TrimSuffix(ifoPath, filepath.Ext(ifoPath))
+	idx, err := os.ReadFile(base + ".idx")
+	if err != nil {
+		return nil, fmt.Errorf("StarDict idx file not found")
+	}
+	data, err := readDictData(base)
+	if err != nil {
+		return nil, err
+	}
+
+	offsetSize := 4
+	if meta["idxoffsetbits"] == "64" {
+		offsetSize = 8
+	}
+
+	name := meta["bookname"]
+	if name == "" {
+		name = filepath.Base(base)
+	}
+	d := &definitionIndex{
+		info:    DictionaryInfo{Name: name, Format: DictionaryFormatStarDict, Language: language, Path: ifoPath},
+		data:    data,
+		entries: make(map[string][]definitionRef),
+	}
+	sameType := meta["sametypesequence"]
+	d.decode = func(raw []byte) string {
+		return decodeStarDictEntry(raw, sameType)
+	}
+
+	for pos := 0; pos < len(idx); {
+		end := bytes.IndexByte(idx[pos:], 0)
+		if end < 0 || pos+end+1+offsetSize+4 > len(idx) {
+			return nil, fmt.Errorf("truncated StarDict idx file")
+		}
+		word := string(idx[pos : pos+end])
+		pos += end + 1
+
+		var offset uint64
+		if offsetSize == 8 {
+			offset = binary.BigEndian.Uint64(idx[pos:])
+		} else {
+			offset = uint64(binary.BigEndian.Uint32(idx[pos:]))
+		}
+		pos += offsetSize
+		size := uint64(binary.BigEndian.Uint32(idx[pos:]))
+		pos += 4
+
+		d.add(word, offset, size)
+	}
+	d.info.Headwords = len(d.entries)
+
+	return d, nil
+}
+
+// decodeStarDictEntry extracts the text fields of a StarDict entry. Lowercase
+// field types are NUL-terminated text, uppercase types are size-prefixed
+// binary data (images, sounds) and are skipped. With a sametypesequence the
+// type bytes are omitted and the last field runs to the end of the entry.
+func decodeStarDictEntry(raw []byte, sameType string) string {
+	var parts []string
+	appendText := func(fieldType byte, text []byte) {
+		switch fieldType {
+		case 'm', 'l', 't', 'y':
+			parts = append(parts, string(text))
+		case 'g', 'h', 'x', 'k', 'w':
+			parts = append(parts, stripMarkup(string(text)))
+		}
+	}
+
+	readField := func(fieldType byte, pos int, last bool) int {
+		if fieldType >= 'A' && fieldType <= 'Z' {
+			if last {
+				return len(raw)
+			}
+			if pos+4 > len(raw) {
+				return len(raw)
+			}
+			return pos + 4 + int(binary.BigEndian.Uint32(raw[pos:]))
+		}
+		end := len(raw)
+		if !last {
+			if i := bytes.IndexByte(raw[pos:], 0); i >= 0 {
+				end = pos + i
+			}
+		}
+		appendText(fieldType, raw[pos:end])
+		return end + 1
+	}
+
+	if sameType != "" {
+		pos := 0
+		for i := 0; i < len(sameType) && pos < len(raw); i++ {
+			pos = readField(sameType[i], pos, i == len(sameType)-1)
+		}
+	} else {
+		for pos := 0; pos < len(raw); {
+			fieldType := raw[pos]
+			pos = readField(fieldType, pos+1, false)
+		}
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+var (
+	markupBreakPattern = regexp.MustCompile(`(?i)<br\s*/?>|</(?:p|div|li)>`)
+	markupTagPattern   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// stripMarkup reduces HTML/Pango/XDXF markup to plain text
+func stripMarkup(s string) string {
+	s = markupBreakPattern.ReplaceAllString(s, "\n")
+	s = markupTagPattern.ReplaceAllString(s, "")
+	replacer := strings.NewReplacer("&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'", "&nbsp;", " ", "&amp;", "&")
+	return replacer.Replace(s)
+}
+
+// loadDICT loads a dictd dictionary from its .index file and the matching
+// .dict(.dz) file
+func loadDICT(indexPath, language string) (dictionarySource, error) {
+	file, err := os.Open(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	base := strings.TrimSuffix(indexPath, filepath.Ext(indexPath))
+	data, err := readDictData(base)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &definitionIndex{
+		info:    DictionaryInfo{Name: filepath.Base(base), Format: DictionaryFormatDICT, Language: language, Path: indexPath},
+		data:    data,
+		entries: make(map[string][]definitionRef),
+		decode:  func(raw []byte) string { return string(raw) },
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 3 {
+			continue
+		}
+		offset, err1 := decodeDICTNumber(fields[1])
+		size, err2 := decodeDICTNumber(fields[2])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid index line %q", scanner.Text())
+		}
+
+		if strings.HasPrefix(fields[0], "00-database-") || strings.HasPrefix(fields[0], "00database") {
+			if fields[0] == "00-database-short" || fields[0] == "00databaseshort" {
+				if end := offset + size; end <= uint64(len(data)) {
+					short := strings.TrimSpace(string(data[offset:end]))
+					short = strings.TrimSpace(strings.TrimPrefix(short, fields[0]))
+					if short != "" {
+						d.info.Name = short
+					}
+				}
+			}
+			continue
+		}
+
+		d.add(fields[0], offset, size)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	d.info.Headwords = len(d.entries)
+
+	return d, nil
+}
+
+const dictBase64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// decodeDICTNumber decodes the base64 numbers used in dictd index files
+func decodeDICTNumber(s string) (uint64, error) {
+	var n uint64
+	for _, r := range s {
+		digit := strings.IndexRune(dictBase64Alphabet, r)
+		if digit < 0 {
+			return 0, fmt.Errorf("invalid base64 digit %q", r)
+		}
+		n = n*64 + uint64(digit)
+	}
+	return n, nil
+}
+
+// whitakerEntry is one line of a Whitaker's Words DICTLINE file
+type whitakerEntry struct {
+	stems   [4]string // normalized; "" where the line has "zzz"
+	pos     string    // N, V, ADJ, ADV, PREP, CONJ, INTERJ, PRON, NUM, ...
+	class   int       // declension or conjugation
+	variant int
+	gender  string
+	meaning string
+}
+
+// whitakerIndex holds a Whitaker's Words-style Latin dictionary and parses
+// inflected forms against it with a table of regular endings
+type whitakerIndex struct {
+	info    DictionaryInfo
+	entries []whitakerEntry
+	byStem  map[string][]int
+}
+
+var whitakerFlagsPattern = regexp.MustCompile(`^\s*(.*?)\s+([A-Z] [A-Z] [A-Z] [A-Z] [A-Z])\s+(.*)$`)
+
+// loadWhitaker loads a DICTLINE file: four 19-column stems, then the part
+// of speech and its codes, five single-letter flags and the meaning
+func loadWhitaker(path string) (dictionarySource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	w := &whitakerIndex{
+		info:   DictionaryInfo{Name: "Whitaker's Words", Format: DictionaryFormatWhitaker, Language: LangLatin, Path: path},
+		byStem: make(map[string][]int),
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry, ok := parseWhitakerLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		index := len(w.entries)
+		w.entries = append(w.entries, entry)
+
+		seen := make(map[string]bool)
+		for _, stem := range entry.stems {
+			if stem != "" && !seen[stem] {
+				seen[stem] = true
+				w.byStem[stem] = append(w.byStem[stem], index)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	w.info.Headwords = len(w.entries)
+
+	return w, nil
+}
+
+func parseWhitakerLine(line string) (whitakerEntry, bool) {
+	var entry whitakerEntry
+	runes := []rune(line)
+	if len(runes) < 77 {
+		return entry, false
+	}
+
+	for i := 0; i < 4; i++ {
+		stem := strings.TrimSpace(string(runes[i*19 : i*19+19]))
+		if stem != "zzz" {
+			entry.stems[i] = NormalizeForLanguage(stem, LangLatin)
+		}
+	}
+
+	m := whitakerFlagsPattern.FindStringSubmatch(string(runes[76:]))
+	if m == nil {
+		return entry, false
+	}
+	codes := strings.Fields(m[1])
+	if len(codes) == 0 {
+		return entry, false
+	}
+	entry.pos = codes[0]
+	entry.meaning = strings.TrimSpace(m[3])
+
+	switch entry.pos {
+	case "N", "V", "ADJ", "PRON", "NUM", "VPAR":
+		if len(codes) >= 3 {
+			entry.class, _ = strconv.Atoi(codes[1])
+			entry.variant, _ = strconv.Atoi(codes[2])
+		}
+		if entry.pos == "N" && len(codes) >= 4 {
+			entry.gender = codes[3]
+		}
+	}
+
+	return entry, true
+}
+
+func (w *whitakerIndex) Info() DictionaryInfo {
+	return w.info
+}
+
+func (w *whitakerIndex) Define(key string) []Definition {
+	return nil
+}
+
+func (w *whitakerIndex) Analyze(key string) []Analysis {
+	var analyses []Analysis
+	seen := make(map[string]bool)
+
+	add := func(entry whitakerEntry, parse string) {
+		dedupe := entry.stems[0] + "|" + entry.pos + "|" + entry.meaning + "|" + parse
+		if seen[dedupe] {
+			return
+		}
+		seen[dedupe] = true
+		lemma, form := whitakerDictionaryForm(entry)
+		analysis := Analysis{
+			Form:           key,
+			Lemma:          lemma,
+			DictionaryForm: form,
+			PartOfSpeech:   whitakerPartsOfSpeech[entry.pos],
+			Parse:          parse,
+			Source:         w.info.Name,
+		}
+		if analysis.PartOfSpeech == "" {
+			analysis.PartOfSpeech = strings.ToLower(entry.pos)
+		}
+		if entry.meaning != "" {
+			analysis.Definitions = []string{entry.meaning}
+		}
+		analyses = append(analyses, analysis)
+	}
+
+	for _, ending := range latinEndings {
+		if !strings.HasSuffix(key, ending.ending) {
+			continue
+		}
+		stem := key[:len(key)-len(ending.ending)]
+		if stem == "" {
+			continue
+		}
+		for _, i := range w.byStem[stem] {
+			entry := w.entries[i]
+			if entry.pos != ending.pos || entry.stems[ending.stem-1] != stem {
+				continue
+			}
+			if ending.class != 0 && entry.class != ending.class {
+				continue
+			}
+			if ending.variant != 0 && entry.variant != ending.variant {
+				continue
+			}
+			add(entry, ending.parse)
+		}
+	}
+
+	// Indeclinable words match their first stem exactly
+	for _, i := range w.byStem[key] {
+		entry := w.entries[i]
+		switch entry.pos {
+		case "ADV", "PREP", "CONJ", "INTERJ":
+			if entry.stems[0] == key {
+				add(entry, "")
+			}
+		}
+	}
+
+	return analyses
+}
+
+var whitakerPartsOfSpeech = map[string]string{
+	"N":      "noun",
+	"V":      "verb",
+	"ADJ":    "adjective",
+	"ADV":    "adverb",
+	"PREP":   "preposition",
+	"CONJ":   "conjunction",
+	"INTERJ": "interjection",
+	"PRON":   "pronoun",
+	"NUM":    "numeral",
+	"VPAR":   "participle",
+}
+
+// whitakerDictionaryForm builds the lemma and principal parts of an entry
+func whitakerDictionaryForm(e whitakerEntry) (string, string) {
+	s := e.stems
+	switch e.pos {
+	case "N":
+		switch e.class {
+		case 1:
+			return s[0] + "a", s[0] + "a, " + s[1] + "ae"
+		case 2:
+			switch e.variant {
+			case 2:
+				return s[0] + "um", s[0] + "um, " + s[1] + "i"
+			case 3:
+				return s[0], s[0] + ", " + s[1] + "i"
+			default:
+				return s[0] + "us", s[0] + "us, " + s[1] + "i"
+			}
+		case 3:
+			return s[0], s[0] + ", " + s[1] + "is"
+		case 4:
+			return s[0] + "us", s[0] + "us, " + s[1] + "us"
+		case 5:
+			return s[0] + "es", s[0] + "es, " + s[1] + "ei"
+		}
+	case "V":
+		var present, infinitive string
+		switch {
+		case e.class == 1:
+			present, infinitive = s[0]+"o", s[1]+"are"
+		case e.class == 2:
+			present, infinitive = s[0]+"eo", s[1]+"ere"
+		case e.class == 3 && e.variant == 4:
+			present, infinitive = s[0]+"o", s[1]+"ire"
+		default:
+			present, infinitive = s[0]+"o", s[1]+"ere"
+		}
+		parts := []string{present, infinitive}
+		if s[2] != "" {
+			parts = append(parts, s[2]+"i")
+		}
+		if s[3] != "" {
+			parts = append(parts, s[3]+"us")
+		}
+		return present, strings.Join(parts, ", ")
+	case "ADJ":
+		if e.class == 3 {
+			return s[0], s[0] + ", " + s[1] + "is"
+		}
+		return s[0] + "us", s[0] + "us, " + s[1] + "a, " + s[1] + "um"
+	}
+	return s[0], s[0]
+}
+
+// latinEnding is a regular inflectional ending. stem is the DICTLINE stem
+// (1-4) it attaches to; class and variant restrict it to a declension or
+// conjugation (0 matches any).
+type latinEnding struct {
+	pos     string
+	class   int
+	variant int
+	stem    int
+	ending  string
+	parse   string
+}
+
+var latinEndings = buildLatinEndings()
+
+func buildLatinEndings() []latinEnding {
+	var endings []latinEnding
+	add := func(pos string, class, variant, stem int, parse string, forms ...string) {
+		for _, form := range forms {
+			endings = append(endings, latinEnding{pos: pos, class: class, variant: variant, stem: stem, ending: form, parse: parse})
+		}
+	}
+
+	// First declension
+	add("N", 1, 0, 1, "nominative/vocative/ablative singular", "a")
+	add("N", 1, 0, 2, "genitive/dative singular, nominative/vocative plural", "ae")
+	add("N", 1, 0, 2, "accusative singular", "am")
+	add("N", 1, 0, 2, "genitive plural", "arum")
+	add("N", 1, 0, 2, "dative/ablative plural", "is")
+	add("N", 1, 0, 2, "accusative plural", "as")
+
+	// Second declension
+	add("N", 2, 1, 1, "nominative singular", "us")
+	add("N", 2, 1, 2, "vocative singular", "e")
+	add("N", 2, 1, 2, "genitive singular, nominative plural", "i")
+	add("N", 2, 1, 2, "accusative plural", "os")
+	add("N", 2, 2, 1, "nominative/accusative singular", "um")
+	add("N", 2, 2, 2, "genitive singular", "i")
+	add("N", 2, 2, 2, "nominative/accusative plural", "a")
+	add("N", 2, 3, 1, "nominative/vocative singular", "")
+	add("N", 2, 3, 2, "genitive singular, nominative plural", "i")
+	add("N", 2, 3, 2, "accusative plural", "os")
+	add("N", 2, 1, 2, "accusative singular", "um")
+	add("N", 2, 3, 2, "accusative singular", "um")
+	add("N", 2, 0, 2, "dative/ablative singular", "o")
+	add("N", 2, 0, 2, "genitive plural", "orum")
+	add("N", 2, 0, 2, "dative/ablative plural", "is")
+
+	// Third declension
+	add("N", 3, 0, 1, "nominative/vocative singular", "")
+	add("N", 3, 0, 2, "genitive singular", "is")
+	add("N", 3, 0, 2, "dative singular", "i")
+	add("N", 3, 0, 2, "accusative singular", "em")
+	add("N", 3, 0, 2, "ablative singular", "e")
+	add("N", 3, 0, 2, "nominative/accusative plural", "es", "a", "ia")
+	add("N", 3, 0, 2, "genitive plural", "um", "ium")
+	add("N", 3, 0, 2, "dative/ablative plural", "ibus")
+
+	// Fourth declension
+	add("N", 4, 0, 1, "nominative singular", "us")
+	add("N", 4, 0, 2, "genitive singular, nominative/accusative plural", "us")
+	add("N", 4, 0, 2, "dative singular", "ui")
+	add("N", 4, 0, 2, "accusative singular", "um")
+	add("N", 4, 0, 2, "ablative singular", "u")
+	add("N", 4, 0, 2, "genitive plural", "uum")
+	add("N", 4, 0, 2, "dative/ablative plural", "ibus")
+
+	// Fifth declension
+	add("N", 5, 0, 1, "nominative singular", "es")
+	add("N", 5, 0, 2, "genitive/dative singular", "ei")
+	add("N", 5, 0, 2, "accusative singular", "em")
+	add("N", 5, 0, 2, "ablative singular", "e")
+	add("N", 5, 0, 2, "nominative/accusative plural", "es")
+	add("N", 5, 0, 2, "genitive plural", "erum")
+	add("N", 5, 0, 2, "dative/ablative plural", "ebus")
+
+	// First/second declension adjectives
+	add("ADJ", 1, 0, 1, "nominative masculine singular", "us")
+	add("ADJ", 1, 0, 2, "feminine nominative singular, neuter nominative/accusative plural", "a")
+	add("ADJ", 1, 0, 2, "neuter nominative/accusative singular, masculine accusative singular", "um")
+	add("ADJ", 1, 0, 2, "masculine/neuter genitive singular, masculine nominative plural", "i")
+	add("ADJ", 1, 0, 2, "feminine genitive/dative singular, feminine nominative plural", "ae")
+	add("ADJ", 1, 0, 2, "masculine/neuter dative/ablative singular", "o")
+	add("ADJ", 1, 0, 2, "feminine accusative singular", "am")
+	add("ADJ", 1, 0, 2, "genitive plural", "orum", "arum")
+	add("ADJ", 1, 0, 2, "dative/ablative plural", "is")
+	add("ADJ", 1, 0, 2, "accusative plural", "os", "as")
+
+	// Third declension adjectives
+	add("ADJ", 3, 0, 1, "nominative singular", "")
+	add("ADJ", 3, 0, 2, "genitive singular", "is")
+	add("ADJ", 3, 0, 2, "dative/ablative singular", "i")
+	add("ADJ", 3, 0, 2, "accusative singular", "em")
+	add("ADJ", 3, 0, 2, "nominative/accusative plural", "es", "ia")
+	add("ADJ", 3, 0, 2, "genitive plural", "ium")
+	add("ADJ", 3, 0, 2, "dative/ablative plural", "ibus")
+
+	// Verbs: present system by conjugation (first stem for the first person
+	// singular, second stem for the rest)
+	add("V", 1, 0, 1, "present active indicative, 1st person singular", "o")
+	add("V", 1, 0, 2, "present active indicative, 2nd person singular", "as")
+	add("V", 1, 0, 2, "present active indicative, 3rd person singular", "at")
+	add("V", 1, 0, 2, "present active indicative, 1st person plural", "amus")
+	add("V", 1, 0, 2, "present active indicative, 2nd person plural", "atis")
+	add("V", 1, 0, 2, "present active indicative, 3rd person plural", "ant")
+	add("V", 1, 0, 2, "present active infinitive", "are")
+	add("V", 1, 0, 2, "imperfect active indicative, 3rd person singular", "abat")
+	add("V", 1, 0, 2, "imperfect active indicative, 3rd person plural", "abant")
+	add("V", 1, 0, 2, "future active indicative, 1st person singular", "abo")
+	add("V", 1, 0, 2, "future active indicative, 3rd person singular", "abit")
+	add("V", 1, 0, 2, "present passive infinitive", "ari")
+	add("V", 1, 0, 2, "present active participle", "ans", "antis")
+
+	add("V", 2, 0, 1, "present active indicative, 1st person singular", "eo")
+	add("V", 2, 0, 2, "present active indicative, 2nd person singular", "es")
+	add("V", 2, 0, 2, "present active indicative, 3rd person singular", "et")
+	add("V", 2, 0, 2, "present active indicative, 1st person plural", "emus")
+	add("V", 2, 0, 2, "present active indicative, 2nd person plural", "etis")
+	add("V", 2, 0, 2, "present active indicative, 3rd person plural", "ent")
+	add("V", 2, 0, 2, "present active infinitive", "ere")
+	add("V", 2, 0, 2, "imperfect active indicative, 3rd person singular", "ebat")
+	add("V", 2, 0, 2, "imperfect active indicative, 3rd person plural", "ebant")
+	add("V", 2, 0, 2, "present passive infinitive", "eri")
+
+	add("V", 3, 1, 1, "present active indicative, 1st person singular", "o")
+	add("V", 3, 1, 2, "present active indicative, 2nd person singular", "is")
+	add("V", 3, 1, 2, "present active indicative, 3rd person singular", "it")
+	add("V", 3, 1, 2, "present active indicative, 1st person plural", "imus")
+	add("V", 3, 1, 2, "present active indicative, 2nd person plural", "itis")
+	add("V", 3, 1, 2, "present active indicative, 3rd person plural", "unt")
+	add("V", 3, 1, 2, "present active infinitive", "ere")
+	add("V", 3, 1, 2, "imperfect active indicative, 3rd person singular", "ebat")
+	add("V", 3, 1, 2, "imperfect active indicative, 3rd person plural", "ebant")
+	add("V", 3, 1, 2, "future active indicative, 3rd person singular", "et")
+	add("V", 3, 1, 2, "present passive infinitive", "i")
+
+	add("V", 3, 4, 1, "present active indicative, 1st person singular", "o")
+	add("V", 3, 4, 2, "present active indicative, 2nd person singular", "is")
+	add("V", 3, 4, 2, "present active indicative, 3rd person singular", "it")
+	add("V", 3, 4, 2, "present active indicative, 1st person plural", "imus")
+	add("V", 3, 4, 2, "present active indicative, 2nd person plural", "itis")
+	add("V", 3, 4, 2, "present active indicative, 3rd person plural", "iunt")
+	add("V", 3, 4, 2, "present active infinitive", "ire")
+	add("V", 3, 4, 2, "imperfect active indicative, 3rd person singular", "iebat")
+	add("V", 3, 4, 2, "present passive infinitive", "iri")
+
+	// Perfect system (third stem) and perfect passive participle (fourth)
+	add("V", 0, 0, 3, "perfect active indicative, 1st person singular", "i")
+	add("V", 0, 0, 3, "perfect active indicative, 2nd person singular", "isti")
+	add("V", 0, 0, 3, "perfect active indicative, 3rd person singular", "it")
+	add("V", 0, 0, 3, "perfect active indicative, 1st person plural", "imus")
+	add("V", 0, 0, 3, "perfect active indicative, 2nd person plural", "istis")
+	add("V", 0, 0, 3, "perfect active indicative, 3rd person plural", "erunt", "ere")
+	add("V", 0, 0, 3, "pluperfect active indicative, 3rd person singular", "erat")
+	add("V", 0, 0, 3, "pluperfect active indicative, 3rd person plural", "erant")
+	add("V", 0, 0, 3, "perfect active infinitive", "isse")
+	add("V", 0, 0, 4, "perfect passive participle, masculine nominative singular", "us")
+	add("V", 0, 0, 4, "perfect passive participle, feminine nominative singular", "a")
+	add("V", 0, 0, 4, "perfect passive participle, neuter nominative singular", "um")
+	add("V", 0, 0, 4, "perfect passive participle, masculine nominative plural", "i")
+	add("V", 0, 0, 4, "perfect passive participle, feminine nominative plural", "ae")
+
+	return endings
+}
+
+// greekMorphIndex holds a Greek form → lemma/morphology table
+type greekMorphIndex struct {
+	info    DictionaryInfo
+	entries map[string][]Analysis
+}
+
+// loadGreekMorph loads a tab-separated Greek morphology table with the
+// columns form, lemma, Perseus/AGDT morphology tag and an optional short
+// definition. Lines starting with "#" are comments.
+func loadGreekMorph(path string) (dictionarySource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	g := &greekMorphIndex{
+		info:    DictionaryInfo{Name: strings.TrimSuffix(filepath.Base(path), ".morph.tsv"), Format: DictionaryFormatGreekMorph, Language: LangGreek, Path: path},
+		entries: make(map[string][]Analysis),
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			continue
+		}
+
+		pos, parse := DecodePerseusTag(fields[2])
+		analysis := Analysis{
+			Form:         fields[0],
+			Lemma:        fields[1],
+			PartOfSpeech: pos,
+			Parse:        parse,
+			Source:       g.info.Name,
+		}
+		if len(fields) > 3 && strings.TrimSpace(fields[3]) != "" {
+			analysis.Definitions = []string{strings.TrimSpace(fields[3])}
+		}
+
+		key := NormalizeForLanguage(fields[0], LangGreek)
+		g.entries[key] = append(g.entries[key], analysis)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	g.info.Headwords = len(g.entries)
+
+	return g, nil
+}
+
+func (g *greekMorphIndex) Info() DictionaryInfo {
+	return g.info
+}
+
+func (g *greekMorphIndex) Analyze(key string) []Analysis {
+	return g.entries[key]
+}
+
+func (g *greekMorphIndex) Define(key string) []Definition {
+	return nil
+}
+
+// Positions of the nine-character Perseus/AGDT morphology tag
+var perseusTagValues = []map[byte]string{
+	{'n': "noun", 'v': "verb", 't': "participle", 'a': "adjective", 'd': "adverb", 'l': "article", 'g': "particle",
+		'c': "conjunction", 'r': "preposition", 'p': "pronoun", 'm': "numeral", 'i': "interjection", 'e': "exclamation", 'u': "punctuation"},
+	{'1': "1st person", '2': "2nd person", '3': "3rd person"},
+	{'s': "singular", 'p': "plural", 'd': "dual"},
+	{'p': "present", 'i': "imperfect", 'r': "perfect", 'l': "pluperfect", 't': "future perfect", 'f': "future", 'a': "aorist"},
+	{'i': "indicative", 's': "subjunctive", 'o': "optative", 'n': "infinitive", 'm': "imperative", 'p': "participle"},
+	{'a': "active", 'p': "passive", 'm': "middle", 'e': "middle/passive"},
+	{'m': "masculine", 'f': "feminine", 'n': "neuter"},
+	{'n': "nominative", 'g': "genitive", 'd': "dative", 'a': "accusative", 'v': "vocative", 'l': "locative"},
+	{'c': "comparative", 's': "superlative"},
+}
+
+// DecodePerseusTag turns a Perseus/AGDT tag such as "v3spia---" into a part
+// of speech ("verb") and a readable parse ("3rd person singular present
+// indicative active")
+func DecodePerseusTag(tag string) (string, string) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return "", ""
+	}
+
+	pos := perseusTagValues[0][tag[0]]
+	// Person and number first, then tense, mood, voice, gender, case, degree
+	var parts []string
+	for i := 1; i < len(tag) && i < len(perseusTagValues); i++ {
+		if value, ok := perseusTagValues[i][tag[i]]; ok {
+			parts = append(parts, value)
+		}
+	}
+	return pos, strings.Join(parts, " ")
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/classius/server/internal/models"
+)
+
+// DictionaryService looks words up in local dictionaries and records
+// lookups made while reading
+type DictionaryService struct {
+	db      *gorm.DB
+	sources []dictionarySource
+}
+
+// NewDictionaryService loads every dictionary found under path. A missing
+// directory or unreadable files are reported but do not prevent startup.
+func NewDictionaryService(db *gorm.DB, path string) *DictionaryService {
+	sources, errs := LoadDictionaries(path)
+	for _, err := range errs {
+		fmt.Printf("Warning: failed to load dictionary %v\n", err)
+	}
+	return &DictionaryService{db: db, sources: sources}
+}
+
+// LookupRequest describes a word looked up by a reader
+type LookupRequest struct {
+	Word       string     `json:"word"`
+	Language   string     `json:"language"`
+	BookID     *uuid.UUID `json:"book_id,omitempty"`
+	Position   *int       `json:"position,omitempty"`
+	PageNumber int        `json:"page_number,omitempty"`
+	Context    string     `json:"context,omitempty"`
+}
+
+// LemmaEntry groups the analyses and definitions found for one lemma
+type LemmaEntry struct {
+	Lemma          string       `json:"lemma"`
+	DictionaryForm string       `json:"dictionary_form,omitempty"`
+	PartOfSpeech   string       `json:"part_of_speech,omitempty"`
+	Parses         []string     `json:"parses,omitempty"`
+	Definitions    []Definition `json:"definitions"`
+}
+
+// LookupResult is the response to a dictionary lookup
+type LookupResult struct {
+	Word       string       `json:"word"`
+	Normalized string       `json:"normalized"`
+	Language   string       `json:"language"`
+	Found      bool         `json:"found"`
+	Entries    []LemmaEntry `json:"entries"`
+	LookupID   *uuid.UUID   `json:"lookup_id,omitempty"`
+}
+
+// VocabularyItem aggregates a user's lookups of one lemma
+type VocabularyItem struct {
+	Lemma         string    `json:"lemma"`
+	Language      string    `json:"language"`
+	PartOfSpeech  string    `json:"part_of_speech"`
+	Definition    string    `json:"definition"`
+	Context       string    `json:"context"`
+	Lookups       int       `json:"lookups"`
+	Books         int       `json:"books"`
+	FirstLookedUp time.Time `json:"first_looked_up"`
+	LastLookedUp  time.Time `json:"last_looked_up"`
+}
+
+// Sources returns the loaded dictionaries
+func (s *DictionaryService) Sources() []DictionaryInfo {
+	infos := make([]DictionaryInfo, len(s.sources))
+	for i, source := range s.sources {
+		infos[i] = source.Info()
+	}
+	return infos
+}
+
+// Lookup analyzes a word and collects definitions for each of its lemmas.
+// When language is empty it is detected from the word, falling back to
+// fallback (typically the book's language).
+func (s *DictionaryService) Lookup(word, language, fallback string) (*LookupResult, error) {
+	word = strings.TrimSpace(word)
+	if language != "" {
+		language = NormalizeLanguage(language)
+	} else {
+		language = DetectLanguage(word, NormalizeLanguage(fallback))
+	}
+
+	key := NormalizeForLanguage(word, language)
+	if key == "" {
+		return nil, fmt.Errorf("%w: word is required", ErrInvalid)
+	}
+
+	result := &LookupResult{Word: word, Normalized: key, Language: language, Entries: []LemmaEntry{}}
+
+	// Morphological analyses, grouped by lemma and part of speech
+	byLemma := make(map[string]int)
+	for _, source := range s.sources {
+		if source.Info().Language != language {
+			continue
+		}
+		for _, analysis := range source.Analyze(key) {
+			group := analysis.Lemma + "|" + analysis.PartOfSpeech
+			i, ok := byLemma[group]
+			if !ok {
+				i = len(result.Entries)
+				byLemma[group] = i
+				result.Entries = append(result.Entries, LemmaEntry{
+					Lemma:          analysis.Lemma,
+					DictionaryForm: analysis.DictionaryForm,
+					PartOfSpeech:   analysis.PartOfSpeech,
+					Definitions:    []Definition{},
+				})
+			}
+			entry := &result.Entries[i]
+			if analysis.Parse != "" && !containsString(entry.Parses, analysis.Parse) {
+				entry.Parses = append(entry.Parses, analysis.Parse)
+			}
+			for _, text := range analysis.Definitions {
+				if !hasDefinition(entry.Definitions, analysis.Source, text) {
+					entry.Definitions = append(entry.Definitions, Definition{Headword: analysis.Lemma, Text: text, Source: analysis.Source})
+				}
+			}
+		}
+	}
+
+	// Definitions for each lemma from the definition dictionaries
+	for i := range result.Entries {
+		entry := &result.Entries[i]
+		for _, def := range s.define(NormalizeForLanguage(entry.Lemma, language), language) {
+			if !hasDefinition(entry.Definitions, def.Source, def.Text) {
+				entry.Definitions = append(entry.Definitions, def)
+			}
+		}
+	}
+
+	// Without a morphological analysis, try the form itself and likely base forms
+	if len(result.Entries) == 0 {
+		for _, candidate := range baseFormCandidates(key, language) {
+			if defs := s.define(candidate, language); len(defs) > 0 {
+				result.Entries = append(result.Entries, LemmaEntry{Lemma: defs[0].Headword, Definitions: defs})
+				break
+			}
+		}
+	}
+
+	result.Found = len(result.Entries) > 0
+	return result, nil
+}
+
+// define collects definitions for a normalized headword from every
+// dictionary of the language
+func (s *DictionaryService) define(key, language string) []Definition {
+	var definitions []Definition
+	for _, source := range s.sources {
+		if source.Info().Language == language {
+			definitions = append(definitions, source.Define(key)...)
+		}
+	}
+	return definitions
+}
+
+// baseFormCandidates returns the word followed by plausible uninflected
+// forms for languages without a morphology table
+func baseFormCandidates(key, language string) []string {
+	candidates := []string{key}
+	if language != LangEnglish {
+		return candidates
+	}
+
+	rules := []struct{ suffix, replacement string }{
+		{"ies", "y"}, {"ied", "y"}, {"es", ""}, {"s", ""},
+		{"ed", ""}, {"ed", "e"}, {"ing", ""}, {"ing", "e"},
+		{"er", ""}, {"est", ""}, {"ly", ""},
+	}
+	for _, rule := range rules {
+		if strings.HasSuffix(key, rule.suffix) && len(key)-len(rule.suffix) >= 2 {
+			candidates = append(candidates, key[:len(key)-len(rule.suffix)]+rule.replacement)
+		}
+	}
+	// Doubled consonant before -ed/-ing ("stopped", "running")
+	for _, suffix := range []string{"ed", "ing"} {
+		if stem := strings.TrimSuffix(key, suffix); stem != key && len(stem) >= 3 && stem[len(stem)-1] == stem[len(stem)-2] {
+			candidates = append(candidates, stem[:len(stem)-1])
+		}
+	}
+	return candidates
+}
+
+// LogLookup records a lookup against the book and position it was made at.
+// When no context is supplied the surrounding sentence is taken from the
+// book's extracted text.
+func (s *DictionaryService) LogLookup(ctx context.Context, userID uuid.UUID, req LookupRequest, result *LookupResult) (*models.WordLookup, error) {
+	lookup := &models.WordLookup{
+		UserID:     userID,
+		BookID:     req.BookID,
+		Position:   req.Position,
+		PageNumber: req.PageNumber,
+		Word:       truncateRunes(result.Word, 100),
+		Language:   result.Language,
+		Lemma:      truncateRunes(result.Normalized, 100),
+		Context:    req.Context,
+		Found:      result.Found,
+	}
+	if len(result.Entries) > 0 {
+		first := result.Entries[0]
+		lookup.Lemma = truncateRunes(first.Lemma, 100)
+		lookup.PartOfSpeech = first.PartOfSpeech
+		if len(first.Definitions) > 0 {
+			lookup.Definition = first.Definitions[0].Text
+		}
+	}
+
+	if lookup.Context == "" && req.BookID != nil && req.Position != nil {
+		var content models.BookContent
+		if err := s.db.WithContext(ctx).Where("book_id = ?", *req.BookID).First(&content).Error; err == nil {
+			lookup.Context = sentenceAround([]rune(content.FullText), *req.Position, result.Language)
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Create(lookup).Error; err != nil {
+		fmt.Printf("Warning: failed to record lookup of %q: %v\n", lookup.Word, err)
+		return nil, fmt.Errorf("failed to record lookup: %w", err)
+	}
+	return lookup, nil
+}
+
+// GetLookups returns a user's lookup history, newest first
+func (s *DictionaryService) GetLookups(ctx context.Context, userID uuid.UUID, bookID *uuid.UUID, limit, offset int) ([]models.WordLookup, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.WordLookup{}).Where("user_id = ?", userID)
+	if bookID != nil {
+		query = query.Where("book_id = ?", *bookID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count lookups: %w", err)
+	}
+
+	var lookups []models.WordLookup
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&lookups).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve lookups: %w", err)
+	}
+	return lookups, total, nil
+}
+
+// GetVocabulary aggregates a user's successful lookups by lemma, most
+// frequently looked-up first
+func (s *DictionaryService) GetVocabulary(ctx context.Context, userID uuid.UUID, bookID *uuid.UUID, language string, limit int) ([]VocabularyItem, error) {
+	query := s.db.WithContext(ctx).Model(&models.WordLookup{}).
+		Select(`lemma, language,
+			(ARRAY_AGG(part_of_speech ORDER BY created_at DESC))[1] AS part_of_speech,
+			(ARRAY_AGG(definition ORDER BY created_at DESC))[1] AS definition,
+			(ARRAY_AGG(context ORDER BY created_at DESC))[1] AS context,
+			COUNT(*) AS lookups,
+			COUNT(DISTINCT book_id) AS books,
+			MIN(created_at) AS first_looked_up,
+			MAX(created_at) AS last_looked_up`).
+		Where("user_id = ? AND found = ?", userID, true)
+	if bookID != nil {
+		query = query.Where("book_id = ?", *bookID)
+	}
+	if language != "" {
+		query = query.Where("language = ?", NormalizeLanguage(language))
+	}
+
+	var items []VocabularyItem
+	if err := query.Group("lemma, language").
+		Order("lookups DESC, last_looked_up DESC").
+		Limit(limit).
+		Scan(&items).Error; err != nil {
+		return nil, fmt.Errorf("failed to build vocabulary list: %w", err)
+	}
+	return items, nil
+}
+
+// sentenceAround returns the sentence containing a rune offset
+func sentenceAround(text []rune, position int, language string) string {
+	if position < 0 || position >= len(text) {
+		return ""
+	}
+	start, end := position-400, position+400
+	if start < 0 {
+		start = 0
+	}
+	if end > len(text) {
+		end = len(text)
+	}
+	for _, span := range SplitSentences(text, start, end, language) {
+		if span.Start <= position && position < span.End {
+			return collapseSpace(string(text[span.Start:span.End]))
+		}
+	}
+	return ""
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func hasDefinition(definitions []Definition, source, text string) bool {
+	for _, d := range definitions {
+		if d.Source == source && d.Text == text {
+			return true
+		}
+	}
+	return false
+}
+
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
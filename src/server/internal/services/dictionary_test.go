@@ -0,0 +1,132 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func whitakerLine(stems [4]string, codes, meaning string) string {
+	return fmt.Sprintf("%-19s%-19s%-19s%-19s%-24s X X X A O %s\n", stems[0], stems[1], stems[2], stems[3], codes, meaning)
+}
+
+func newTestDictionaries(t *testing.T) *DictionaryService {
+	root := t.TempDir()
+
+	// StarDict (English): two entries, sametypesequence=m
+	var dict, idx bytes.Buffer
+	for _, entry := range []struct{ word, text string }{
+		{"read", "to look at and understand written words"},
+		{"virtue", "moral excellence"},
+	} {
+		idx.WriteString(entry.word)
+		idx.WriteByte(0)
+		binary.Write(&idx, binary.BigEndian, uint32(dict.Len()))
+		binary.Write(&idx, binary.BigEndian, uint32(len(entry.text)))
+		dict.WriteString(entry.text)
+	}
+	writeTestFile(t, filepath.Join(root, "en", "test.ifo"), []byte("StarDict's dict ifo file\nversion=2.4.2\nbookname=Test English\nwordcount=2\nsametypesequence=m\n"))
+	writeTestFile(t, filepath.Join(root, "en", "test.idx"), idx.Bytes())
+	writeTestFile(t, filepath.Join(root, "en", "test.dict"), dict.Bytes())
+
+	// DICT (Latin): one definition for "rex" at offset 0, length 16
+	writeTestFile(t, filepath.Join(root, "la", "lewis.index"), []byte("rex\tA\tQ\n"))
+	writeTestFile(t, filepath.Join(root, "la", "lewis.dict"), []byte("rex, regis: king"))
+
+	// Whitaker's Words DICTLINE
+	dictline := whitakerLine([4]string{"rex", "reg", "zzz", "zzz"}, "N  3 1 M T", "king;") +
+		whitakerLine([4]string{"am", "am", "amav", "amat"}, "V  1 1 TRANS", "love, like;") +
+		whitakerLine([4]string{"et", "zzz", "zzz", "zzz"}, "CONJ", "and, and even;")
+	writeTestFile(t, filepath.Join(root, "la", "DICTLINE.GEN"), []byte(dictline))
+
+	// Greek morphology table
+	writeTestFile(t, filepath.Join(root, "grc", "sample.morph.tsv"), []byte("# form\tlemma\ttag\tgloss\nλόγον\tλόγος\tn-s---ma-\tword\n"))
+
+	return NewDictionaryService(nil, root)
+}
+
+func TestDictionaryLoadsAllFormats(t *testing.T) {
+	service := newTestDictionaries(t)
+
+	formats := make(map[string]bool)
+	for _, info := range service.Sources() {
+		formats[info.Format] = true
+	}
+	for _, format := range []string{DictionaryFormatStarDict, DictionaryFormatDICT, DictionaryFormatWhitaker, DictionaryFormatGreekMorph} {
+		if !formats[format] {
+			t.Errorf("Expected a %s dictionary to be loaded, got %+v", format, service.Sources())
+		}
+	}
+}
+
+func TestDictionaryLookupLatin(t *testing.T) {
+	service := newTestDictionaries(t)
+
+	result, err := service.Lookup("regis", "la", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Found || len(result.Entries) != 1 {
+		t.Fatalf("Expected one entry for 'regis', got %+v", result)
+	}
+	entry := result.Entries[0]
+	if entry.Lemma != "rex" || entry.PartOfSpeech != "noun" || entry.DictionaryForm != "rex, regis" {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+	if len(entry.Parses) != 1 || entry.Parses[0] != "genitive singular" {
+		t.Errorf("Expected genitive singular parse, got %v", entry.Parses)
+	}
+	// Whitaker meaning plus the DICT definition of the lemma
+	if len(entry.Definitions) != 2 {
+		t.Errorf("Expected 2 definitions, got %+v", entry.Definitions)
+	}
+
+	result, _ = service.Lookup("amavit", "la", "")
+	if !result.Found || result.Entries[0].Lemma != "amo" || result.Entries[0].Parses[0] != "perfect active indicative, 3rd person singular" {
+		t.Errorf("Unexpected analysis of 'amavit': %+v", result)
+	}
+
+	result, _ = service.Lookup("et", "la", "")
+	if !result.Found || result.Entries[0].PartOfSpeech != "conjunction" {
+		t.Errorf("Expected 'et' to be found as a conjunction, got %+v", result)
+	}
+}
+
+func TestDictionaryLookupGreekAndEnglish(t *testing.T) {
+	service := newTestDictionaries(t)
+
+	result, _ := service.Lookup("λόγον", "", "en")
+	if result.Language != LangGreek || !result.Found {
+		t.Fatalf("Expected Greek lookup to succeed, got %+v", result)
+	}
+	if entry := result.Entries[0]; entry.Lemma != "λόγος" || entry.Parses[0] != "singular masculine accusative" {
+		t.Errorf("Unexpected Greek entry: %+v", entry)
+	}
+
+	result, _ = service.Lookup("Reading", "en", "")
+	if !result.Found || result.Entries[0].Lemma != "read" {
+		t.Errorf("Expected 'Reading' to resolve to 'read', got %+v", result)
+	}
+}
+
+func TestDecodeDICTNumber(t *testing.T) {
+	testCases := map[string]uint64{"A": 0, "B": 1, "BA": 64, "Bh": 97}
+	for input, expected := range testCases {
+		if n, err := decodeDICTNumber(input); err != nil || n != expected {
+			t.Errorf("decodeDICTNumber(%q): expected %d, got %d (%v)", input, expected, n, err)
+		}
+	}
+}
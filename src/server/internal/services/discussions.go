@@ -0,0 +1,818 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/classius/server/internal/models"
+)
+
+// Discussion limits
+const (
+	MaxDiscussionLength = 20000 // Runes of Markdown in a post
+	MaxDiscussionDepth  = 8     // Levels of nested replies
+	MaxQuoteRunes       = 1000  // Runes of an anchored passage
+	nestedReplyPreview  = 5     // Replies loaded with each nested post
+	maxMarginThreads    = 500   // Threads considered for a book's margin
+)
+
+// DiscussionService manages threaded discussions in reading groups and
+// around books. Threads can be anchored to a passage, which readers see
+// in the margin of their own copy.
+type DiscussionService struct {
+	db *gorm.DB
+}
+
+// NewDiscussionService creates a new discussion service
+func NewDiscussionService(db *gorm.DB) *DiscussionService {
+	return &DiscussionService{db: db}
+}
+
+// CreateDiscussionRequest starts a thread in a group or about a book. A
+// passage in the user's copy of the book, by offsets or quote, anchors it.
+type CreateDiscussionRequest struct {
+	GroupID          *uuid.UUID `json:"group_id"`
+	BookID           *uuid.UUID `json:"book_id"`
+	Title            string     `json:"title" binding:"required,max=255"`
+	Content          string     `json:"content" binding:"required"` // Markdown
+	PassageReference string     `json:"passage_reference"`          // e.g. "Republic 514a"
+	StartPosition    int        `json:"start_position"`
+	EndPosition      int        `json:"end_position"`
+	Quote            string     `json:"quote"`
+}
+
+// ReplyRequest replies to a post
+type ReplyRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// UpdateDiscussionRequest edits a post. Only threads have titles.
+type UpdateDiscussionRequest struct {
+	Title   *string `json:"title" binding:"omitempty,max=255"`
+	Content *string `json:"content"`
+}
+
+// DiscussionFilter selects threads in a group, about a book, or both
+type DiscussionFilter struct {
+	GroupID *uuid.UUID
+	BookID  *uuid.UUID // One of the viewer's books; threads about other copies match by ISBN or text
+	Sort    string     // active (default), recent or top
+}
+
+// TextRange is a span of rune offsets in a book's text
+type TextRange struct {
+	Start int
+	End   int
+}
+
+// DiscussionView is a post as shown to a viewer. Tombstoned posts keep
+// their place in the thread without their content or author.
+type DiscussionView struct {
+	ID               uuid.UUID        `json:"id"`
+	GroupID          *uuid.UUID       `json:"group_id,omitempty"`
+	BookID           *uuid.UUID       `json:"book_id,omitempty"`
+	RootID           *uuid.UUID       `json:"root_id,omitempty"`
+	ParentID         *uuid.UUID       `json:"parent_id,omitempty"`
+	UserID           uuid.UUID        `json:"user_id"`
+	AuthorName       string           `json:"author_name"`
+	AuthorUsername   string           `json:"author_username"`
+	AuthorVerified   bool             `json:"author_verified"`
+	Title            string           `json:"title,omitempty"`
+	Content          string           `json:"content"`
+	ContentHTML      string           `json:"content_html"`
+	PassageReference string           `json:"passage_reference,omitempty"`
+	StartPosition    int              `json:"start_position"`
+	EndPosition      int              `json:"end_position"`
+	Quote            string           `json:"quote,omitempty"`
+	QuotePrefix      string           `json:"-"`
+	QuoteSuffix      string           `json:"-"`
+	BookFingerprint  string           `json:"-"`
+	AnchorPercent    *float64         `json:"anchor_percent,omitempty"`
+	Anchored         bool             `json:"anchored"` // Whether the thread is anchored to a passage
+	Depth            int              `json:"depth"`
+	ReplyCount       int              `json:"reply_count"`
+	Upvotes          int              `json:"upvotes"`
+	Voted            bool             `json:"voted"` // Whether the viewer upvoted it
+	EditedAt         *time.Time       `json:"edited_at,omitempty"`
+	TombstonedAt     *time.Time       `json:"tombstoned_at,omitempty"`
+	TombstoneReason  string           `json:"tombstone_reason,omitempty"`
+	CreatedAt        time.Time        `json:"created_at"`
+	LastActivityAt   *time.Time       `json:"last_activity_at,omitempty"`
+	Replies          []DiscussionView `json:"replies,omitempty"`
+}
+
+// ParseTextRange parses a "start-end" range of rune offsets
+func ParseTextRange(s string) (*TextRange, error) {
+	startStr, endStr, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("%w range %q: use start-end", ErrInvalid, s)
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(startStr))
+	if err != nil {
+		return nil, fmt.Errorf("%w range %q: use start-end", ErrInvalid, s)
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(endStr))
+	if err != nil {
+		return nil, fmt.Errorf("%w range %q: use start-end", ErrInvalid, s)
+	}
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("%w range %q: end must be after start", ErrInvalid, s)
+	}
+	return &TextRange{Start: start, End: end}, nil
+}
+
+// CreateThread starts a thread. Group threads are for members; threads
+// about a book without a group are open to every reader of the text.
+func (s *DiscussionService) CreateThread(ctx context.Context, userID uuid.UUID, req CreateDiscussionRequest) (*DiscussionView, error) {
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return nil, fmt.Errorf("%w: title is required", ErrInvalid)
+	}
+	content, contentHTML, err := discussionContent(req.Content)
+	if err != nil {
+		return nil, err
+	}
+	if req.GroupID == nil && req.BookID == nil {
+		return nil, fmt.Errorf("%w: group_id or book_id is required", ErrInvalid)
+	}
+	if req.StartPosition < 0 || req.EndPosition < req.StartPosition {
+		return nil, fmt.Errorf("%w passage range", ErrInvalid)
+	}
+	anchored := req.EndPosition > req.StartPosition || strings.TrimSpace(req.Quote) != ""
+	if anchored && req.BookID == nil {
+		return nil, fmt.Errorf("%w passage: anchoring a thread requires book_id", ErrInvalid)
+	}
+
+	now := time.Now()
+	post := models.Discussion{
+		GroupID:          req.GroupID,
+		BookID:           req.BookID,
+		UserID:           userID,
+		Title:            title,
+		Content:          content,
+		ContentHTML:      contentHTML,
+		PassageReference: strings.TrimSpace(req.PassageReference),
+		LastActivityAt:   &now,
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if req.GroupID != nil {
+			if _, err := requireGroupRole(tx, *req.GroupID, userID, models.GroupRoleMember); err != nil {
+				return err
+			}
+		}
+		if req.BookID != nil {
+			var book models.Book
+			result := tx.Where("id = ? AND user_id = ?", *req.BookID, userID).Limit(1).Find(&book)
+			if result.Error != nil {
+				return fmt.Errorf("failed to retrieve book: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("book %w", ErrNotFound)
+			}
+			fingerprint, err := bookTextFingerprint(tx, &book)
+			if err != nil {
+				return err
+			}
+			post.BookISBN, post.BookFingerprint = normalizeISBN(book.ISBN), fingerprint
+			if anchored {
+				locator, err := bookLocator(tx, book.ID)
+				if err != nil {
+					return err
+				}
+				if err := anchorDiscussion(&post, locator, req.StartPosition, req.EndPosition, req.Quote); err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Omit(clause.Associations).Create(&post).Error; err != nil {
+			return fmt.Errorf("failed to create discussion: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, userID, post.ID, 0, 0)
+}
+
+// Reply replies to a post in a thread the user can post in
+func (s *DiscussionService) Reply(ctx context.Context, userID, parentID uuid.UUID, content string) (*DiscussionView, error) {
+	content, contentHTML, err := discussionContent(content)
+	if err != nil {
+		return nil, err
+	}
+	var reply models.Discussion
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		parent, err := loadDiscussion(tx.Clauses(clause.Locking{Strength: "UPDATE"}), parentID)
+		if err != nil {
+			return err
+		}
+		if err := requirePostingAccess(tx, userID, parent.GroupID); err != nil {
+			return err
+		}
+		if parent.TombstonedAt != nil {
+			return fmt.Errorf("%w reply: the post was deleted", ErrInvalid)
+		}
+		if parent.Depth >= MaxDiscussionDepth {
+			return fmt.Errorf("%w reply: replies nest at most %d levels", ErrInvalid, MaxDiscussionDepth)
+		}
+		rootID := parent.ID
+		if parent.RootID != nil {
+			rootID = *parent.RootID
+		}
+
+		reply = models.Discussion{
+			GroupID:         parent.GroupID,
+			BookID:          parent.BookID,
+			UserID:          userID,
+			Content:         content,
+			ContentHTML:     contentHTML,
+			ParentID:        &parent.ID,
+			RootID:          &rootID,
+			Depth:           parent.Depth + 1,
+			BookISBN:        parent.BookISBN,
+			BookFingerprint: parent.BookFingerprint,
+		}
+		if err := tx.Omit(clause.Associations).Create(&reply).Error; err != nil {
+			return fmt.Errorf("failed to create reply: %w", err)
+		}
+		if err := tx.Model(&models.Discussion{}).Where("id = ?", parent.ID).
+			UpdateColumn("reply_count", gorm.Expr("reply_count + 1")).Error; err != nil {
+			return fmt.Errorf("failed to update reply count: %w", err)
+		}
+		if err := tx.Model(&models.Discussion{}).Where("id = ?", rootID).
+			UpdateColumn("last_activity_at", reply.CreatedAt).Error; err != nil {
+			return fmt.Errorf("failed to update thread activity: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, userID, reply.ID, 0, 0)
+}
+
+// Threads lists threads in a group or about one of the viewer's books. Book
+// threads include those of the viewer's groups.
+func (s *DiscussionService) Threads(ctx context.Context, viewerID uuid.UUID, filter DiscussionFilter, limit, offset int) ([]DiscussionView, int64, error) {
+	if filter.GroupID == nil && filter.BookID == nil {
+		return nil, 0, fmt.Errorf("%w: group_id or book_id is required", ErrInvalid)
+	}
+	order, err := discussionOrder(filter.Sort)
+	if err != nil {
+		return nil, 0, err
+	}
+	db := s.db.WithContext(ctx)
+	query := s.views(ctx, viewerID).Where("d.parent_id IS NULL")
+	if filter.GroupID != nil {
+		if err := requireReadingAccess(db, viewerID, filter.GroupID); err != nil {
+			return nil, 0, err
+		}
+		query = query.Where("d.group_id = ?", *filter.GroupID)
+	}
+	if filter.BookID != nil {
+		book, err := ownBook(db, viewerID, *filter.BookID)
+		if err != nil {
+			return nil, 0, err
+		}
+		match, args, err := discussionBookMatch(db, book, viewerID)
+		if err != nil {
+			return nil, 0, err
+		}
+		query = query.Where(match, args...)
+	}
+	spoilers, spoilerArgs, err := spoilerFilter(db, viewerID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if spoilers != "" {
+		query = query.Where(spoilers, spoilerArgs...)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count discussions: %w", err)
+	}
+	threads := []DiscussionView{}
+	if err := query.Order(order).Limit(limit).Offset(offset).Scan(&threads).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve discussions: %w", err)
+	}
+	prepareDiscussionViews(threads)
+	return threads, total, nil
+}
+
+// Get returns a post with its first replies, nested depth levels deep
+func (s *DiscussionService) Get(ctx context.Context, viewerID, postID uuid.UUID, depth, replies int) (*DiscussionView, error) {
+	var post DiscussionView
+	result := s.views(ctx, viewerID).Where("d.id = ?", postID).Limit(1).Scan(&post)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve discussion: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("discussion %w", ErrNotFound)
+	}
+	if err := requireReadingAccess(s.db.WithContext(ctx), viewerID, post.GroupID); err != nil {
+		return nil, err
+	}
+	posts := []DiscussionView{post}
+	prepareDiscussionViews(posts)
+	if depth > 0 && replies > 0 && post.ReplyCount > 0 {
+		children, _, err := s.replies(ctx, viewerID, postID, depth, replies, 0)
+		if err != nil {
+			return nil, err
+		}
+		posts[0].Replies = children
+	}
+	return &posts[0], nil
+}
+
+// Replies pages through a post's direct replies, oldest first, each with
+// its first replies nested depth-1 levels deep
+func (s *DiscussionService) Replies(ctx context.Context, viewerID, postID uuid.UUID, depth, limit, offset int) ([]DiscussionView, int64, error) {
+	db := s.db.WithContext(ctx)
+	post, err := loadDiscussion(db, postID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := requireReadingAccess(db, viewerID, post.GroupID); err != nil {
+		return nil, 0, err
+	}
+	return s.replies(ctx, viewerID, postID, depth, limit, offset)
+}
+
+func (s *DiscussionService) replies(ctx context.Context, viewerID, postID uuid.UUID, depth, limit, offset int) ([]DiscussionView, int64, error) {
+	query := s.views(ctx, viewerID).Where("d.parent_id = ?", postID)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count replies: %w", err)
+	}
+	replies := []DiscussionView{}
+	if err := query.Order("d.created_at").Limit(limit).Offset(offset).Scan(&replies).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve replies: %w", err)
+	}
+	prepareDiscussionViews(replies)
+	if err := s.nestReplies(ctx, viewerID, replies, depth-1); err != nil {
+		return nil, 0, err
+	}
+	return replies, total, nil
+}
+
+// nestReplies loads the first replies to each post, depth levels deep.
+// Each level is one query.
+func (s *DiscussionService) nestReplies(ctx context.Context, viewerID uuid.UUID, posts []DiscussionView, depth int) error {
+	if depth <= 0 {
+		return nil
+	}
+	var parentIDs []uuid.UUID
+	for _, post := range posts {
+		if post.ReplyCount > 0 {
+			parentIDs = append(parentIDs, post.ID)
+		}
+	}
+	if len(parentIDs) == 0 {
+		return nil
+	}
+
+	ranked := s.views(ctx, viewerID).
+		Select(discussionColumns+", ROW_NUMBER() OVER (PARTITION BY d.parent_id ORDER BY d.created_at) AS reply_rank").
+		Where("d.parent_id IN ?", parentIDs)
+	var children []DiscussionView
+	if err := s.db.WithContext(ctx).Table("(?) AS r", ranked).Where("r.reply_rank <= ?", nestedReplyPreview).
+		Order("r.created_at").Scan(&children).Error; err != nil {
+		return fmt.Errorf("failed to retrieve replies: %w", err)
+	}
+	prepareDiscussionViews(children)
+	if err := s.nestReplies(ctx, viewerID, children, depth-1); err != nil {
+		return err
+	}
+
+	byParent := make(map[uuid.UUID][]DiscussionView)
+	for _, child := range children {
+		byParent[*child.ParentID] = append(byParent[*child.ParentID], child)
+	}
+	for i := range posts {
+		posts[i].Replies = byParent[posts[i].ID]
+	}
+	return nil
+}
+
+// BookDiscussions returns the anchored threads about one of the viewer's
+// books, placed in the viewer's copy, in text order. With a range, only the
+// threads on passages overlapping it are returned.
+func (s *DiscussionService) BookDiscussions(ctx context.Context, viewerID, bookID uuid.UUID, within *TextRange) ([]DiscussionView, error) {
+	db := s.db.WithContext(ctx)
+	book, err := ownBook(db, viewerID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	match, args, err := discussionBookMatch(db, book, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	query := s.views(ctx, viewerID).
+		Where("d.parent_id IS NULL AND d.end_position > d.start_position").
+		Where(match, args...)
+	spoilers, spoilerArgs, err := spoilerFilter(db, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if spoilers != "" {
+		query = query.Where(spoilers, spoilerArgs...)
+	}
+	var candidates []DiscussionView
+	if err := query.
+		Order("COALESCE(d.last_activity_at, d.created_at) DESC").
+		Limit(maxMarginThreads).Scan(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve discussions: %w", err)
+	}
+	prepareDiscussionViews(candidates)
+
+	var locator *QuoteLocator
+	if len(candidates) > 0 {
+		if locator, err = bookLocator(db, book.ID); err != nil {
+			return nil, err
+		}
+	}
+	threads := []DiscussionView{}
+	for _, thread := range candidates {
+		if thread.BookID != nil && *thread.BookID == book.ID {
+			thread.Anchored = true
+		} else {
+			sameText := book.TextFingerprint != "" && thread.BookFingerprint == book.TextFingerprint
+			thread.StartPosition, thread.EndPosition, thread.Anchored = anchorQuote(locator, thread.StartPosition,
+				thread.EndPosition, thread.Quote, thread.QuotePrefix, thread.QuoteSuffix, sameText)
+		}
+		if !thread.Anchored {
+			continue
+		}
+		if within != nil && (thread.EndPosition <= within.Start || thread.StartPosition >= within.End) {
+			continue
+		}
+		threads = append(threads, thread)
+	}
+	sort.SliceStable(threads, func(i, j int) bool {
+		return threads[i].StartPosition < threads[j].StartPosition
+	})
+	return threads, nil
+}
+
+// Update edits the user's own post
+func (s *DiscussionService) Update(ctx context.Context, userID, postID uuid.UUID, req UpdateDiscussionRequest) (*DiscussionView, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		post, err := loadDiscussion(tx.Clauses(clause.Locking{Strength: "UPDATE"}), postID)
+		if err != nil {
+			return err
+		}
+		if err := requireReadingAccess(tx, userID, post.GroupID); err != nil {
+			return err
+		}
+		if post.UserID != userID {
+			return fmt.Errorf("%w: only its author can edit a post", ErrAccessDenied)
+		}
+		if post.TombstonedAt != nil {
+			return fmt.Errorf("%w: the post was deleted", ErrConflict)
+		}
+
+		updates := make(map[string]interface{})
+		if req.Title != nil {
+			if post.ParentID != nil {
+				return fmt.Errorf("%w title: replies have no title", ErrInvalid)
+			}
+			title := strings.TrimSpace(*req.Title)
+			if title == "" {
+				return fmt.Errorf("%w: title is required", ErrInvalid)
+			}
+			updates["title"] = title
+		}
+		if req.Content != nil {
+			content, contentHTML, err := discussionContent(*req.Content)
+			if err != nil {
+				return err
+			}
+			updates["content"], updates["content_html"] = content, contentHTML
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		updates["edited_at"] = time.Now()
+		if err := tx.Model(post).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update discussion: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, userID, postID, 0, 0)
+}
+
+// Delete deletes a post. Authors delete their own posts; group moderators
+// and admins remove others'. A post with replies is kept as a tombstone
+// for them, and tombstones go once their last reply does.
+func (s *DiscussionService) Delete(ctx context.Context, userID, postID uuid.UUID) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		post, err := loadDiscussion(tx.Clauses(clause.Locking{Strength: "UPDATE"}), postID)
+		if err != nil {
+			return err
+		}
+		if err := requireReadingAccess(tx, userID, post.GroupID); err != nil {
+			return err
+		}
+		if post.TombstonedAt != nil {
+			return fmt.Errorf("discussion %w", ErrNotFound)
+		}
+		reason, err := deletionReason(tx, userID, post)
+		if err != nil {
+			return err
+		}
+
+		if post.ReplyCount > 0 {
+			if err := tx.Model(post).Updates(map[string]interface{}{
+				"title":            "",
+				"content":          "",
+				"content_html":     "",
+				"tombstoned_at":    time.Now(),
+				"tombstone_reason": reason,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to delete discussion: %w", err)
+			}
+			return nil
+		}
+		return deleteDiscussionPost(tx, post)
+	})
+}
+
+// Vote adds or withdraws the user's upvote of a post
+func (s *DiscussionService) Vote(ctx context.Context, userID, postID uuid.UUID, up bool) (*DiscussionView, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		post, err := loadDiscussion(tx.Clauses(clause.Locking{Strength: "UPDATE"}), postID)
+		if err != nil {
+			return err
+		}
+		if err := requirePostingAccess(tx, userID, post.GroupID); err != nil {
+			return err
+		}
+		if post.TombstonedAt != nil {
+			return fmt.Errorf("%w vote: the post was deleted", ErrInvalid)
+		}
+		if post.UserID == userID {
+			return fmt.Errorf("%w vote: you cannot vote for your own post", ErrInvalid)
+		}
+
+		var vote models.DiscussionVote
+		result := tx.Where("discussion_id = ? AND user_id = ?", postID, userID).Limit(1).Find(&vote)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve vote: %w", result.Error)
+		}
+		switch {
+		case up && result.RowsAffected == 0:
+			vote = models.DiscussionVote{DiscussionID: postID, UserID: userID}
+			if err := tx.Create(&vote).Error; err != nil {
+				return fmt.Errorf("failed to vote: %w", err)
+			}
+		case !up && result.RowsAffected > 0:
+			if err := tx.Delete(&vote).Error; err != nil {
+				return fmt.Errorf("failed to withdraw vote: %w", err)
+			}
+		default:
+			return nil
+		}
+		if err := tx.Model(&models.Discussion{}).Where("id = ?", postID).UpdateColumn("upvotes",
+			gorm.Expr("(SELECT COUNT(*) FROM discussion_votes WHERE discussion_id = ? AND deleted_at IS NULL)", postID)).Error; err != nil {
+			return fmt.Errorf("failed to count votes: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, userID, postID, 0, 0)
+}
+
+// discussionColumns are the columns of a DiscussionView
+const discussionColumns = `d.id, d.group_id, d.book_id, d.root_id, d.parent_id, d.user_id, d.title, d.content, d.content_html,
+	d.passage_reference, d.start_position, d.end_position, d.quote, d.quote_prefix, d.quote_suffix, d.book_fingerprint, d.anchor_percent,
+	d.depth, d.reply_count, d.upvotes, d.edited_at, d.tombstoned_at, d.tombstone_reason, d.created_at, d.last_activity_at,
+	COALESCE(NULLIF(u.full_name, ''), u.username) AS author_name, u.username AS author_username,
+	u.verified_scholar AS author_verified, v.id IS NOT NULL AS voted`
+
+// views selects posts with their authors and the viewer's votes
+func (s *DiscussionService) views(ctx context.Context, viewerID uuid.UUID) *gorm.DB {
+	return s.db.WithContext(ctx).Table("discussions d").
+		Select(discussionColumns).
+		Joins("JOIN users u ON u.id = d.user_id").
+		Joins("LEFT JOIN discussion_votes v ON v.discussion_id = d.id AND v.user_id = ? AND v.deleted_at IS NULL", viewerID).
+		Where("d.deleted_at IS NULL")
+}
+
+// prepareDiscussionViews marks anchored threads and hides who wrote
+// tombstoned posts
+func prepareDiscussionViews(posts []DiscussionView) {
+	for i := range posts {
+		post := &posts[i]
+		post.Anchored = post.EndPosition > post.StartPosition
+		if post.TombstonedAt != nil {
+			post.UserID = uuid.Nil
+			post.AuthorName, post.AuthorUsername, post.AuthorVerified = "", "", false
+		}
+	}
+}
+
+// discussionOrder maps a sort name to the order of threads
+func discussionOrder(sort string) (string, error) {
+	switch sort {
+	case "", "active":
+		return "COALESCE(d.last_activity_at, d.created_at) DESC", nil
+	case "recent":
+		return "d.created_at DESC", nil
+	case "top":
+		return "d.upvotes DESC, d.created_at DESC", nil
+	}
+	return "", fmt.Errorf("%w sort %q", ErrInvalid, sort)
+}
+
+// discussionContent checks a post's Markdown and renders it
+func discussionContent(raw string) (string, string, error) {
+	content := strings.TrimSpace(raw)
+	if content == "" {
+		return "", "", fmt.Errorf("%w: content is required", ErrInvalid)
+	}
+	if utf8.RuneCountInString(content) > MaxDiscussionLength {
+		return "", "", fmt.Errorf("%w content: posts are limited to %d characters", ErrInvalid, MaxDiscussionLength)
+	}
+	return content, RenderMarkdown(content), nil
+}
+
+// anchorDiscussion anchors a thread to a passage of the starter's copy,
+// given by offsets, a quote or both. With the book's text, the quote is
+// checked or taken from the offsets, and the text around it kept so other
+// copies can find it.
+func anchorDiscussion(post *models.Discussion, locator *QuoteLocator, start, end int, quote string) error {
+	quote = strings.TrimSpace(quote)
+	if locator != nil {
+		switch {
+		case quote == "":
+			if end > len(locator.Text()) {
+				return fmt.Errorf("%w passage range: past the end of the book", ErrInvalid)
+			}
+			quote = string(locator.Text()[start:end])
+		case !locator.Matches(start, end, quote):
+			var ok bool
+			if start, end, ok = locator.Locate(quote, "", "", start); !ok {
+				return fmt.Errorf("%w passage: the quote is not in the book", ErrInvalid)
+			}
+		}
+		post.QuotePrefix, post.QuoteSuffix = locator.Context(start, end, overlayContextRunes)
+		percent := float64(start) * 100 / float64(len(locator.Text()))
+		post.AnchorPercent = &percent
+	} else if end <= start {
+		return fmt.Errorf("%w passage: the book's text is not yet extracted, so give start_position and end_position", ErrInvalid)
+	}
+	if utf8.RuneCountInString(quote) > MaxQuoteRunes {
+		return fmt.Errorf("%w passage: quotes are limited to %d characters", ErrInvalid, MaxQuoteRunes)
+	}
+	post.StartPosition, post.EndPosition, post.Quote = start, end, quote
+	return nil
+}
+
+// discussionBookMatch builds the condition matching threads about another
+// copy of book, by ISBN or text fingerprint, that the viewer can read:
+// book threads and those of their groups
+func discussionBookMatch(db *gorm.DB, book *models.Book, viewerID uuid.UUID) (string, []interface{}, error) {
+	fingerprint, err := bookTextFingerprint(db, book)
+	if err != nil {
+		return "", nil, err
+	}
+	conditions := []string{"d.book_id = ?"}
+	args := []interface{}{book.ID}
+	if isbn := normalizeISBN(book.ISBN); isbn != "" {
+		conditions = append(conditions, "d.book_isbn = ?")
+		args = append(args, isbn)
+	}
+	if fingerprint != "" {
+		conditions = append(conditions, "d.book_fingerprint = ?")
+		args = append(args, fingerprint)
+	}
+	args = append(args, viewerID)
+	return "(" + strings.Join(conditions, " OR ") + ") AND " +
+		"(d.group_id IS NULL OR d.group_id IN (SELECT group_id FROM group_members WHERE user_id = ? AND deleted_at IS NULL))", args, nil
+}
+
+// ownBook loads one of the user's books
+func ownBook(db *gorm.DB, userID, bookID uuid.UUID) (*models.Book, error) {
+	var book models.Book
+	result := db.Where("id = ? AND user_id = ?", bookID, userID).Limit(1).Find(&book)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve book: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("book %w", ErrNotFound)
+	}
+	return &book, nil
+}
+
+// loadDiscussion loads a post
+func loadDiscussion(db *gorm.DB, postID uuid.UUID) (*models.Discussion, error) {
+	var post models.Discussion
+	result := db.Where("id = ?", postID).Limit(1).Find(&post)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve discussion: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("discussion %w", ErrNotFound)
+	}
+	return &post, nil
+}
+
+// requireReadingAccess checks that the user can read a group's threads:
+// those of public groups and groups they belong to. Threads without a
+// group are open to everyone.
+func requireReadingAccess(db *gorm.DB, userID uuid.UUID, groupID *uuid.UUID) error {
+	if groupID == nil {
+		return nil
+	}
+	_, err := requireGroupRole(db, *groupID, userID, models.GroupRoleMember)
+	if errors.Is(err, errNotMember) {
+		return nil // Public group
+	}
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("discussion %w", ErrNotFound)
+	}
+	return err
+}
+
+// requirePostingAccess checks that the user can post and vote in a group's
+// threads, which needs membership
+func requirePostingAccess(db *gorm.DB, userID uuid.UUID, groupID *uuid.UUID) error {
+	if groupID == nil {
+		return nil
+	}
+	_, err := requireGroupRole(db, *groupID, userID, models.GroupRoleMember)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("discussion %w", ErrNotFound)
+	}
+	return err
+}
+
+// deletionReason checks that the user can delete a post: its author, a
+// moderator of its group, or an admin for threads outside groups
+func deletionReason(tx *gorm.DB, userID uuid.UUID, post *models.Discussion) (string, error) {
+	if post.UserID == userID {
+		return models.TombstoneDeleted, nil
+	}
+	if post.GroupID != nil {
+		member, err := groupMembership(tx, *post.GroupID, userID)
+		if err != nil {
+			return "", err
+		}
+		if member != nil && models.GroupRoleRank(member.Role) >= models.GroupRoleRank(models.GroupRoleModerator) {
+			return models.TombstoneRemoved, nil
+		}
+		return "", fmt.Errorf("%w: only its author or a moderator can delete a post", ErrAccessDenied)
+	}
+	var user models.User
+	if err := tx.Select("is_admin").Where("id = ?", userID).First(&user).Error; err != nil {
+		return "", fmt.Errorf("failed to retrieve user: %w", err)
+	}
+	if !user.IsAdmin {
+		return "", fmt.Errorf("%w: only its author or an admin can delete a post", ErrAccessDenied)
+	}
+	return models.TombstoneRemoved, nil
+}
+
+// deleteDiscussionPost deletes a post without replies, with its votes, then
+// tombstones left without replies above it
+func deleteDiscussionPost(tx *gorm.DB, post *models.Discussion) error {
+	for {
+		if err := tx.Where("discussion_id = ?", post.ID).Delete(&models.DiscussionVote{}).Error; err != nil {
+			return fmt.Errorf("failed to delete votes: %w", err)
+		}
+		if err := tx.Delete(post).Error; err != nil {
+			return fmt.Errorf("failed to delete discussion: %w", err)
+		}
+		if post.ParentID == nil {
+			return nil
+		}
+		parent, err := loadDiscussion(tx.Clauses(clause.Locking{Strength: "UPDATE"}), *post.ParentID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(parent).UpdateColumn("reply_count", gorm.Expr("GREATEST(reply_count - 1, 0)")).Error; err != nil {
+			return fmt.Errorf("failed to update reply count: %w", err)
+		}
+		if parent.TombstonedAt == nil || parent.ReplyCount > 1 {
+			return nil
+		}
+		post = parent
+	}
+}
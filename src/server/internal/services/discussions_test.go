@@ -0,0 +1,102 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestParseTextRange(t *testing.T) {
+	tests := []struct {
+		in    string
+		want  TextRange
+		valid bool
+	}{
+		{"10-20", TextRange{10, 20}, true},
+		{" 0 - 5 ", TextRange{0, 5}, true},
+		{"20-10", TextRange{}, false},
+		{"10-10", TextRange{}, false},
+		{"10", TextRange{}, false},
+		{"a-b", TextRange{}, false},
+		{"-5-10", TextRange{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseTextRange(tt.in)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseTextRange(%q) error = %v", tt.in, err)
+			continue
+		}
+		if tt.valid && *got != tt.want {
+			t.Errorf("ParseTextRange(%q) = %+v, want %+v", tt.in, *got, tt.want)
+		}
+	}
+}
+
+func TestAnchorDiscussion(t *testing.T) {
+	text := "It is a truth universally acknowledged, that a single man in possession of a good fortune, must be in want of a wife."
+	locator := NewQuoteLocator(text)
+	start := strings.Index(text, "a single man")
+	end := start + len("a single man")
+
+	var post models.Discussion
+	if err := anchorDiscussion(&post, locator, start, end, ""); err != nil {
+		t.Fatalf("anchor by offsets: %v", err)
+	}
+	if post.Quote != "a single man" || post.QuotePrefix == "" || post.QuoteSuffix == "" {
+		t.Errorf("anchor by offsets = %q [%q|%q]", post.Quote, post.QuotePrefix, post.QuoteSuffix)
+	}
+
+	post = models.Discussion{}
+	if err := anchorDiscussion(&post, locator, 0, 0, "a good fortune"); err != nil {
+		t.Fatalf("anchor by quote: %v", err)
+	}
+	if got := text[post.StartPosition:post.EndPosition]; got != "a good fortune" {
+		t.Errorf("anchor by quote = %q", got)
+	}
+
+	if err := anchorDiscussion(&models.Discussion{}, locator, 0, 0, "a large fortune"); err == nil {
+		t.Error("expected an error for a quote not in the book")
+	}
+	if err := anchorDiscussion(&models.Discussion{}, locator, 10, len(text)+10, ""); err == nil {
+		t.Error("expected an error for a range past the end of the book")
+	}
+
+	// Without the text, the offsets are taken as given
+	post = models.Discussion{}
+	if err := anchorDiscussion(&post, nil, 5, 9, "truth"); err != nil || post.StartPosition != 5 || post.Quote != "truth" {
+		t.Errorf("anchor without text = %+v, %v", post, err)
+	}
+	if err := anchorDiscussion(&models.Discussion{}, nil, 0, 0, "truth"); err == nil {
+		t.Error("expected an error for a quote without offsets or text")
+	}
+}
+
+func TestPrepareDiscussionViews(t *testing.T) {
+	now := time.Now()
+	posts := []DiscussionView{
+		{UserID: uuid.New(), AuthorName: "Elizabeth", StartPosition: 5, EndPosition: 10},
+		{UserID: uuid.New(), AuthorName: "Darcy", AuthorUsername: "darcy", TombstonedAt: &now},
+	}
+	prepareDiscussionViews(posts)
+	if !posts[0].Anchored || posts[0].AuthorName != "Elizabeth" {
+		t.Errorf("post = %+v", posts[0])
+	}
+	if posts[1].Anchored || posts[1].UserID != uuid.Nil || posts[1].AuthorName != "" || posts[1].AuthorUsername != "" {
+		t.Errorf("tombstone = %+v", posts[1])
+	}
+}
+
+func TestDiscussionOrder(t *testing.T) {
+	for _, sort := range []string{"", "active", "recent", "top"} {
+		if _, err := discussionOrder(sort); err != nil {
+			t.Errorf("discussionOrder(%q): %v", sort, err)
+		}
+	}
+	if _, err := discussionOrder("votes; DROP TABLE"); err == nil {
+		t.Error("expected an error for an unknown sort")
+	}
+}
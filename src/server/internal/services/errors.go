@@ -0,0 +1,29 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Kinds of failure the handlers report to clients. Service errors wrap one
+// with %w, so callers tell them apart with errors.Is rather than by message.
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrAccessDenied     = errors.New("access denied")
+	ErrInvalid          = errors.New("invalid")
+	ErrConflict         = errors.New("conflict")
+	ErrExpired          = errors.New("expired")
+	ErrTooLarge         = errors.New("too large")
+	ErrQuotaExceeded    = errors.New("quota exceeded")
+	ErrUnsupportedMedia = errors.New("unsupported attachment type")
+	ErrPaymentRequired  = errors.New("payment required")
+	ErrNotExtracted     = errors.New("not yet extracted") // The book's text is still being processed
+)
+
+// isUniqueViolation reports whether err is a unique constraint violation,
+// as when a row checked not to exist was inserted concurrently
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
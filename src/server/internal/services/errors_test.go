@@ -0,0 +1,22 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsUniqueViolation(t *testing.T) {
+	duplicate := fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23505"})
+	if !isUniqueViolation(duplicate) {
+		t.Error("wrapped unique violation not recognised")
+	}
+	if isUniqueViolation(&pgconn.PgError{Code: "23503"}) {
+		t.Error("foreign key violation taken for a unique violation")
+	}
+	if isUniqueViolation(errors.New("duplicate key value violates unique constraint")) {
+		t.Error("error text taken for a unique violation")
+	}
+}
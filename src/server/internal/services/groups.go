@@ -0,0 +1,942 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/classius/server/internal/models"
+)
+
+// Reading group limits
+const (
+	DefaultGroupMembers  = 50
+	MaxGroupMembers      = 500
+	DefaultInvitationTTL = 7 * 24 * time.Hour
+	MaxInvitationTTL     = 30 * 24 * time.Hour
+	MaxGroupBooks        = 200
+	invitationTokenBytes = 16 // Random bytes in a link invitation token
+)
+
+// GroupService manages reading groups, their members, invitations and
+// shared book lists. Roles are enforced here: admins manage the group,
+// moderators invite and remove members and curate the book list.
+type GroupService struct {
+	db *gorm.DB
+}
+
+// NewGroupService creates a new group service
+func NewGroupService(db *gorm.DB) *GroupService {
+	return &GroupService{db: db}
+}
+
+// CreateGroupRequest creates a reading group
+type CreateGroupRequest struct {
+	Name        string `json:"name" binding:"required,max=255"`
+	Description string `json:"description"`
+	GroupType   string `json:"group_type"` // book_club (default), study_group, discussion
+	IsPublic    *bool  `json:"is_public"`  // Defaults to true
+	MaxMembers  int    `json:"max_members"`
+}
+
+// UpdateGroupRequest changes a group's settings
+type UpdateGroupRequest struct {
+	Name        *string `json:"name" binding:"omitempty,max=255"`
+	Description *string `json:"description"`
+	GroupType   *string `json:"group_type"`
+	IsPublic    *bool   `json:"is_public"`
+	MaxMembers  *int    `json:"max_members"`
+}
+
+// GroupInvitationRequest invites a user by username, or creates a link
+// anyone can join with
+type GroupInvitationRequest struct {
+	Username       string `json:"username"`
+	Link           bool   `json:"link"`
+	ExpiresInHours int    `json:"expires_in_hours"` // Defaults to 7 days
+	MaxUses        int    `json:"max_uses"`         // Link invitations, 0 for unlimited
+}
+
+// GroupBookRequest adds a book to a group's list, from one of the user's
+// books or by title
+type GroupBookRequest struct {
+	BookID   *uuid.UUID `json:"book_id"`
+	Title    string     `json:"title" binding:"max=255"`
+	Author   string     `json:"author" binding:"max=255"`
+	ISBN     string     `json:"isbn"`
+	Status   string     `json:"status"`
+	Position *int       `json:"position"`
+	Note     string     `json:"note"`
+}
+
+// UpdateGroupBookRequest changes a book list entry
+type UpdateGroupBookRequest struct {
+	Status   *string `json:"status"`
+	Position *int    `json:"position"`
+	Note     *string `json:"note"`
+}
+
+// GroupSummary is a reading group with its size and the viewer's role
+type GroupSummary struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	GroupType   string    `json:"group_type"`
+	IsPublic    bool      `json:"is_public"`
+	MaxMembers  int       `json:"max_members"`
+	CreatedBy   uuid.UUID `json:"created_by"`
+	CreatorName string    `json:"creator_name"`
+	MemberCount int       `json:"member_count"`
+	MyRole      string    `json:"my_role,omitempty"` // Empty when the viewer is not a member
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// GroupMemberView is a member of a group
+type GroupMemberView struct {
+	UserID          uuid.UUID `json:"user_id"`
+	Username        string    `json:"username"`
+	FullName        string    `json:"full_name"`
+	AvatarURL       string    `json:"avatar_url"`
+	VerifiedScholar bool      `json:"verified_scholar"`
+	Role            string    `json:"role"`
+	JoinedAt        time.Time `json:"joined_at"`
+}
+
+// GroupInvitationView is an invitation with the names involved
+type GroupInvitationView struct {
+	models.GroupInvitation
+	GroupName   string `json:"group_name"`
+	InviterName string `json:"inviter_name"`
+	InviteeName string `json:"invitee_name,omitempty"`
+}
+
+// GroupBookView is a book list entry with the viewer's matching copy
+type GroupBookView struct {
+	models.GroupBook
+	AddedByName string     `json:"added_by_name"`
+	MyBookID    *uuid.UUID `json:"my_book_id,omitempty"` // The viewer's copy, matched by ISBN or text
+}
+
+// Create creates a group, making its creator an admin
+func (s *GroupService) Create(ctx context.Context, userID uuid.UUID, req CreateGroupRequest) (*GroupSummary, error) {
+	group := models.ReadingGroup{
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+		GroupType:   req.GroupType,
+		IsPublic:    req.IsPublic == nil || *req.IsPublic,
+		MaxMembers:  req.MaxMembers,
+		CreatedBy:   userID,
+	}
+	if group.GroupType == "" {
+		group.GroupType = "book_club"
+	}
+	if group.MaxMembers == 0 {
+		group.MaxMembers = DefaultGroupMembers
+	}
+	if err := validGroup(&group); err != nil {
+		return nil, err
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Select the columns so a private group is not saved as public by
+		// the column default
+		if err := tx.Select("*").Omit("Members", "Discussions").Create(&group).Error; err != nil {
+			return fmt.Errorf("failed to create group: %w", err)
+		}
+		member := models.GroupMember{GroupID: group.ID, UserID: userID, Role: models.GroupRoleAdmin, JoinedAt: time.Now()}
+		if err := tx.Omit(clause.Associations).Create(&member).Error; err != nil {
+			return fmt.Errorf("failed to add group admin: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, userID, group.ID)
+}
+
+// Discover lists public groups, largest first, optionally matching a search
+// term and group type
+func (s *GroupService) Discover(ctx context.Context, viewerID uuid.UUID, search, groupType string, limit, offset int) ([]GroupSummary, int64, error) {
+	query := s.summaries(ctx, viewerID).Where("g.is_public = ?", true)
+	if groupType != "" {
+		query = query.Where("g.group_type = ?", groupType)
+	}
+	if search = strings.TrimSpace(search); search != "" {
+		term := "%" + escapeLike(strings.ToLower(search)) + "%"
+		query = query.Where("(LOWER(g.name) LIKE ? OR LOWER(g.description) LIKE ?)", term, term)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count groups: %w", err)
+	}
+	groups := []GroupSummary{}
+	if err := query.Order("member_count DESC, g.created_at DESC").Limit(limit).Offset(offset).Scan(&groups).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve groups: %w", err)
+	}
+	return groups, total, nil
+}
+
+// Mine lists the groups the user belongs to
+func (s *GroupService) Mine(ctx context.Context, userID uuid.UUID) ([]GroupSummary, error) {
+	groups := []GroupSummary{}
+	if err := s.summaries(ctx, userID).Where("me.id IS NOT NULL").
+		Order("g.name").Scan(&groups).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve groups: %w", err)
+	}
+	return groups, nil
+}
+
+// Get returns a public group or one the viewer belongs to
+func (s *GroupService) Get(ctx context.Context, viewerID, groupID uuid.UUID) (*GroupSummary, error) {
+	var group GroupSummary
+	result := s.summaries(ctx, viewerID).Where("g.id = ?", groupID).Limit(1).Scan(&group)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve group: %w", result.Error)
+	}
+	if result.RowsAffected == 0 || (!group.IsPublic && group.MyRole == "") {
+		return nil, fmt.Errorf("group %w", ErrNotFound)
+	}
+	return &group, nil
+}
+
+// Update changes a group's settings. Only admins can.
+func (s *GroupService) Update(ctx context.Context, userID, groupID uuid.UUID, req UpdateGroupRequest) (*GroupSummary, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := requireGroupRole(tx, groupID, userID, models.GroupRoleAdmin); err != nil {
+			return err
+		}
+		group, err := lockGroup(tx, groupID)
+		if err != nil {
+			return err
+		}
+		if req.Name != nil {
+			group.Name = strings.TrimSpace(*req.Name)
+		}
+		if req.Description != nil {
+			group.Description = strings.TrimSpace(*req.Description)
+		}
+		if req.GroupType != nil {
+			group.GroupType = *req.GroupType
+		}
+		if req.IsPublic != nil {
+			group.IsPublic = *req.IsPublic
+		}
+		if req.MaxMembers != nil {
+			group.MaxMembers = *req.MaxMembers
+		}
+		if err := validGroup(group); err != nil {
+			return err
+		}
+		if req.MaxMembers != nil {
+			count, err := countGroupMembers(tx, groupID)
+			if err != nil {
+				return err
+			}
+			if int64(group.MaxMembers) < count {
+				return fmt.Errorf("%w max_members: the group already has %d members", ErrInvalid, count)
+			}
+		}
+		if err := tx.Model(group).Updates(map[string]interface{}{
+			"name":        group.Name,
+			"description": group.Description,
+			"group_type":  group.GroupType,
+			"is_public":   group.IsPublic,
+			"max_members": group.MaxMembers,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update group: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, userID, groupID)
+}
+
+// Delete deletes a group with its members, invitations and book list. Only
+// admins can.
+func (s *GroupService) Delete(ctx context.Context, userID, groupID uuid.UUID) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := requireGroupRole(tx, groupID, userID, models.GroupRoleAdmin); err != nil {
+			return err
+		}
+		return deleteGroup(tx, groupID)
+	})
+}
+
+// Join adds the user to a public group
+func (s *GroupService) Join(ctx context.Context, userID, groupID uuid.UUID) (*GroupSummary, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		group, err := lockGroup(tx, groupID)
+		if err != nil {
+			return err
+		}
+		if !group.IsPublic {
+			// Private groups are only visible to members and the invited
+			return fmt.Errorf("group %w", ErrNotFound)
+		}
+		return addGroupMember(tx, group, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, userID, groupID)
+}
+
+// JoinByLink adds the user to the group of a link invitation
+func (s *GroupService) JoinByLink(ctx context.Context, userID uuid.UUID, token string) (*GroupSummary, error) {
+	var groupID uuid.UUID
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var invitation models.GroupInvitation
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token = ?", token).Limit(1).Find(&invitation)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve invitation: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("invitation %w", ErrNotFound)
+		}
+		if err := invitationUsable(&invitation, time.Now()); err != nil {
+			return err
+		}
+		group, err := lockGroup(tx, invitation.GroupID)
+		if err != nil {
+			return err
+		}
+		if err := addGroupMember(tx, group, userID); err != nil {
+			return err
+		}
+		groupID = group.ID
+		if err := tx.Model(&invitation).UpdateColumn("uses", gorm.Expr("uses + 1")).Error; err != nil {
+			return fmt.Errorf("failed to update invitation: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, userID, groupID)
+}
+
+// Leave removes the user from a group. The last admin must hand over the
+// group first; the last member leaving deletes it.
+func (s *GroupService) Leave(ctx context.Context, userID, groupID uuid.UUID) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := lockGroup(tx, groupID); err != nil {
+			return err
+		}
+		member, err := groupMembership(tx, groupID, userID)
+		if err != nil {
+			return err
+		}
+		if member == nil {
+			return fmt.Errorf("membership %w", ErrNotFound)
+		}
+		count, err := countGroupMembers(tx, groupID)
+		if err != nil {
+			return err
+		}
+		if count == 1 {
+			return deleteGroup(tx, groupID)
+		}
+		if member.Role == models.GroupRoleAdmin {
+			if err := ensureOtherAdmin(tx, groupID, userID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(member).Error; err != nil {
+			return fmt.Errorf("failed to leave group: %w", err)
+		}
+		return nil
+	})
+}
+
+// Members lists a group's members, by role then when they joined
+func (s *GroupService) Members(ctx context.Context, viewerID, groupID uuid.UUID, limit, offset int) ([]GroupMemberView, int64, error) {
+	if _, err := s.Get(ctx, viewerID, groupID); err != nil {
+		return nil, 0, err
+	}
+	query := s.db.WithContext(ctx).Table("group_members m").
+		Joins("JOIN users u ON u.id = m.user_id").
+		Where("m.group_id = ? AND m.deleted_at IS NULL", groupID)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count members: %w", err)
+	}
+	members := []GroupMemberView{}
+	if err := query.Select("m.user_id, u.username, u.full_name, u.avatar_url, u.verified_scholar, m.role, m.joined_at").
+		Order("CASE m.role WHEN 'admin' THEN 0 WHEN 'moderator' THEN 1 ELSE 2 END, m.joined_at").
+		Limit(limit).Offset(offset).Scan(&members).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve members: %w", err)
+	}
+	return members, total, nil
+}
+
+// SetRole changes a member's role. Only admins can, and a group keeps at
+// least one admin.
+func (s *GroupService) SetRole(ctx context.Context, adminID, groupID, userID uuid.UUID, role string) error {
+	if models.GroupRoleRank(role) == 0 {
+		return fmt.Errorf("%w role %q", ErrInvalid, role)
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := lockGroup(tx, groupID); err != nil {
+			return err
+		}
+		if _, err := requireGroupRole(tx, groupID, adminID, models.GroupRoleAdmin); err != nil {
+			return err
+		}
+		member, err := groupMembership(tx, groupID, userID)
+		if err != nil {
+			return err
+		}
+		if member == nil {
+			return fmt.Errorf("member %w", ErrNotFound)
+		}
+		if member.Role == models.GroupRoleAdmin && role != models.GroupRoleAdmin {
+			if err := ensureOtherAdmin(tx, groupID, userID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(member).Update("role", role).Error; err != nil {
+			return fmt.Errorf("failed to update role: %w", err)
+		}
+		return nil
+	})
+}
+
+// RemoveMember removes a member. Moderators can remove members and admins
+// can remove moderators and members; admins step down before removal.
+func (s *GroupService) RemoveMember(ctx context.Context, actorID, groupID, userID uuid.UUID) error {
+	if actorID == userID {
+		return fmt.Errorf("%w member: leave the group instead", ErrInvalid)
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		actor, err := requireGroupRole(tx, groupID, actorID, models.GroupRoleModerator)
+		if err != nil {
+			return err
+		}
+		member, err := groupMembership(tx, groupID, userID)
+		if err != nil {
+			return err
+		}
+		if member == nil {
+			return fmt.Errorf("member %w", ErrNotFound)
+		}
+		if !canRemoveGroupMember(actor.Role, member.Role) {
+			return fmt.Errorf("%w: a %s cannot remove a %s", ErrAccessDenied, actor.Role, member.Role)
+		}
+		if err := tx.Delete(member).Error; err != nil {
+			return fmt.Errorf("failed to remove member: %w", err)
+		}
+		return nil
+	})
+}
+
+// Invite invites a user by username, or creates a link invitation.
+// Moderators and admins can invite.
+func (s *GroupService) Invite(ctx context.Context, actorID, groupID uuid.UUID, req GroupInvitationRequest) (*models.GroupInvitation, error) {
+	username := strings.TrimSpace(req.Username)
+	if (username == "") == !req.Link {
+		return nil, fmt.Errorf("%w invitation: give either a username or link", ErrInvalid)
+	}
+	if req.MaxUses < 0 || (req.MaxUses > 0 && !req.Link) {
+		return nil, fmt.Errorf("%w max_uses", ErrInvalid)
+	}
+	ttl := DefaultInvitationTTL
+	if req.ExpiresInHours != 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+		if ttl <= 0 || ttl > MaxInvitationTTL {
+			return nil, fmt.Errorf("%w expires_in_hours: at most %d", ErrInvalid, int(MaxInvitationTTL.Hours()))
+		}
+	}
+	expires := time.Now().Add(ttl)
+	invitation := models.GroupInvitation{
+		GroupID:   groupID,
+		InviterID: actorID,
+		Status:    models.InvitationPending,
+		MaxUses:   req.MaxUses,
+		ExpiresAt: &expires,
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := requireGroupRole(tx, groupID, actorID, models.GroupRoleModerator); err != nil {
+			return err
+		}
+		if req.Link {
+			invitation.Token = randomHex(invitationTokenBytes)
+		} else {
+			var invitee models.User
+			result := tx.Select("id").Where("username = ?", username).Limit(1).Find(&invitee)
+			if result.Error != nil {
+				return fmt.Errorf("failed to retrieve user: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("user %w", ErrNotFound)
+			}
+			member, err := groupMembership(tx, groupID, invitee.ID)
+			if err != nil {
+				return err
+			}
+			if member != nil {
+				return fmt.Errorf("%w: %s is already a member", ErrConflict, username)
+			}
+			// Replace an expired invitation still pending
+			if err := tx.Where("group_id = ? AND invitee_id = ? AND status = ? AND expires_at < ?",
+				groupID, invitee.ID, models.InvitationPending, time.Now()).
+				Delete(&models.GroupInvitation{}).Error; err != nil {
+				return fmt.Errorf("failed to clear expired invitation: %w", err)
+			}
+			var pending int64
+			if err := tx.Model(&models.GroupInvitation{}).Where("group_id = ? AND invitee_id = ? AND status = ?",
+				groupID, invitee.ID, models.InvitationPending).Count(&pending).Error; err != nil {
+				return fmt.Errorf("failed to check invitations: %w", err)
+			}
+			if pending > 0 {
+				return fmt.Errorf("%w: %s is already invited", ErrConflict, username)
+			}
+			invitation.InviteeID = &invitee.ID
+		}
+		if err := tx.Create(&invitation).Error; err != nil {
+			return fmt.Errorf("failed to create invitation: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// GroupInvitations lists a group's pending invitations, for moderators and
+// admins
+func (s *GroupService) GroupInvitations(ctx context.Context, actorID, groupID uuid.UUID) ([]GroupInvitationView, error) {
+	db := s.db.WithContext(ctx)
+	if _, err := requireGroupRole(db, groupID, actorID, models.GroupRoleModerator); err != nil {
+		return nil, err
+	}
+	invitations := []GroupInvitationView{}
+	if err := s.invitations(ctx).Where("i.group_id = ? AND i.status = ?", groupID, models.InvitationPending).
+		Order("i.created_at DESC").Scan(&invitations).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+// RevokeInvitation revokes a pending invitation
+func (s *GroupService) RevokeInvitation(ctx context.Context, actorID, groupID, invitationID uuid.UUID) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := requireGroupRole(tx, groupID, actorID, models.GroupRoleModerator); err != nil {
+			return err
+		}
+		result := tx.Model(&models.GroupInvitation{}).
+			Where("id = ? AND group_id = ? AND status = ?", invitationID, groupID, models.InvitationPending).
+			Update("status", models.InvitationRevoked)
+		if result.Error != nil {
+			return fmt.Errorf("failed to revoke invitation: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("invitation %w", ErrNotFound)
+		}
+		return nil
+	})
+}
+
+// MyInvitations lists the user's pending, unexpired invitations
+func (s *GroupService) MyInvitations(ctx context.Context, userID uuid.UUID) ([]GroupInvitationView, error) {
+	invitations := []GroupInvitationView{}
+	if err := s.invitations(ctx).
+		Where("i.invitee_id = ? AND i.status = ? AND (i.expires_at IS NULL OR i.expires_at > ?)",
+			userID, models.InvitationPending, time.Now()).
+		Order("i.created_at DESC").Scan(&invitations).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+// RespondToInvitation accepts or declines an invitation to the user.
+// Accepting joins the group.
+func (s *GroupService) RespondToInvitation(ctx context.Context, userID, invitationID uuid.UUID, accept bool) (*GroupSummary, error) {
+	var groupID uuid.UUID
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var invitation models.GroupInvitation
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND invitee_id = ?", invitationID, userID).Limit(1).Find(&invitation)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve invitation: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("invitation %w", ErrNotFound)
+		}
+		if err := invitationUsable(&invitation, time.Now()); err != nil {
+			return err
+		}
+		groupID = invitation.GroupID
+
+		updates := map[string]interface{}{"status": models.InvitationDeclined}
+		if accept {
+			group, err := lockGroup(tx, invitation.GroupID)
+			if err != nil {
+				return err
+			}
+			if err := addGroupMember(tx, group, userID); err != nil {
+				return err
+			}
+			updates = map[string]interface{}{"status": models.InvitationAccepted, "uses": 1}
+		}
+		if err := tx.Model(&invitation).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update invitation: %w", err)
+		}
+		return nil
+	})
+	if err != nil || !accept {
+		return nil, err
+	}
+	return s.Get(ctx, userID, groupID)
+}
+
+// Books lists a group's book list, current books first, with the viewer's
+// own copies of them
+func (s *GroupService) Books(ctx context.Context, viewerID, groupID uuid.UUID) ([]GroupBookView, error) {
+	if _, err := s.Get(ctx, viewerID, groupID); err != nil {
+		return nil, err
+	}
+	books := []GroupBookView{}
+	if err := s.db.WithContext(ctx).Table("group_books gb").
+		Select(`gb.*, COALESCE(NULLIF(u.full_name, ''), u.username) AS added_by_name,
+			(`+fmt.Sprintf(groupBookCopySQL, "?")+`) AS my_book_id`, viewerID).
+		Joins("JOIN users u ON u.id = gb.added_by").
+		Where("gb.group_id = ? AND gb.deleted_at IS NULL", groupID).
+		Order("CASE gb.status WHEN 'current' THEN 0 WHEN 'upcoming' THEN 1 ELSE 2 END, gb.position, gb.created_at").
+		Scan(&books).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve group books: %w", err)
+	}
+	return books, nil
+}
+
+// AddBook adds a book to a group's list. Moderators and admins curate the
+// list.
+func (s *GroupService) AddBook(ctx context.Context, actorID, groupID uuid.UUID, req GroupBookRequest) (*models.GroupBook, error) {
+	entry := models.GroupBook{
+		GroupID: groupID,
+		AddedBy: actorID,
+		Title:   strings.TrimSpace(req.Title),
+		Author:  strings.TrimSpace(req.Author),
+		ISBN:    normalizeISBN(req.ISBN),
+		Status:  req.Status,
+		Note:    strings.TrimSpace(req.Note),
+	}
+	if entry.Status == "" {
+		entry.Status = models.GroupBookUpcoming
+	}
+	if !models.IsGroupBookStatus(entry.Status) {
+		return nil, fmt.Errorf("%w status %q", ErrInvalid, entry.Status)
+	}
+	if req.Position != nil {
+		entry.Position = *req.Position
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := requireGroupRole(tx, groupID, actorID, models.GroupRoleModerator); err != nil {
+			return err
+		}
+		if req.BookID != nil {
+			var book models.Book
+			result := tx.Where("id = ? AND user_id = ?", *req.BookID, actorID).Limit(1).Find(&book)
+			if result.Error != nil {
+				return fmt.Errorf("failed to retrieve book: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("book %w", ErrNotFound)
+			}
+			fingerprint, err := bookTextFingerprint(tx, &book)
+			if err != nil {
+				return err
+			}
+			entry.BookID = &book.ID
+			entry.TextFingerprint = fingerprint
+			if entry.Title == "" {
+				entry.Title = book.Title
+			}
+			if entry.Author == "" {
+				entry.Author = book.Author
+			}
+			if entry.ISBN == "" {
+				entry.ISBN = normalizeISBN(book.ISBN)
+			}
+		}
+		if entry.Title == "" {
+			return fmt.Errorf("%w: title or book_id is required", ErrInvalid)
+		}
+		if len(entry.ISBN) > 20 {
+			return fmt.Errorf("%w ISBN", ErrInvalid)
+		}
+
+		var count int64
+		if err := tx.Model(&models.GroupBook{}).Where("group_id = ?", groupID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count group books: %w", err)
+		}
+		if count >= MaxGroupBooks {
+			return fmt.Errorf("%w: a book list holds at most %d books", ErrQuotaExceeded, MaxGroupBooks)
+		}
+		if req.Position == nil {
+			entry.Position = int(count)
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return fmt.Errorf("failed to add group book: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// UpdateBook changes a book list entry's status, position or note
+func (s *GroupService) UpdateBook(ctx context.Context, actorID, groupID, entryID uuid.UUID, req UpdateGroupBookRequest) (*models.GroupBook, error) {
+	var entry models.GroupBook
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := requireGroupRole(tx, groupID, actorID, models.GroupRoleModerator); err != nil {
+			return err
+		}
+		result := tx.Where("id = ? AND group_id = ?", entryID, groupID).Limit(1).Find(&entry)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve group book: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("group book %w", ErrNotFound)
+		}
+		updates := make(map[string]interface{})
+		if req.Status != nil {
+			if !models.IsGroupBookStatus(*req.Status) {
+				return fmt.Errorf("%w status %q", ErrInvalid, *req.Status)
+			}
+			updates["status"] = *req.Status
+		}
+		if req.Position != nil {
+			updates["position"] = *req.Position
+		}
+		if req.Note != nil {
+			updates["note"] = strings.TrimSpace(*req.Note)
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		if err := tx.Model(&entry).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update group book: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// RemoveBook removes a book from a group's list
+func (s *GroupService) RemoveBook(ctx context.Context, actorID, groupID, entryID uuid.UUID) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := requireGroupRole(tx, groupID, actorID, models.GroupRoleModerator); err != nil {
+			return err
+		}
+		result := tx.Where("id = ? AND group_id = ?", entryID, groupID).Delete(&models.GroupBook{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to remove group book: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("group book %w", ErrNotFound)
+		}
+		return deleteSchedules(tx, tx.Model(&models.ReadingSchedule{}).Select("id").Where("group_book_id = ?", entryID))
+	})
+}
+
+// groupBookCopySQL selects a user's copy of the group book gb: the entry's
+// own book, or one with the same text or ISBN. The user is given as a
+// placeholder or column.
+const groupBookCopySQL = `SELECT b.id FROM books b WHERE b.user_id = %s AND b.deleted_at IS NULL AND
+	(b.id = gb.book_id OR (gb.text_fingerprint <> '' AND b.text_fingerprint = gb.text_fingerprint) OR
+	 (gb.isbn <> '' AND UPPER(REPLACE(REPLACE(b.isbn, '-', ''), ' ', '')) = gb.isbn))
+	ORDER BY b.id = gb.book_id DESC, b.created_at LIMIT 1`
+
+// summaries selects groups with their creators, sizes and the viewer's role
+func (s *GroupService) summaries(ctx context.Context, viewerID uuid.UUID) *gorm.DB {
+	return s.db.WithContext(ctx).Table("reading_groups g").
+		Select(`g.id, g.name, g.description, g.group_type, g.is_public, g.max_members, g.created_by, g.created_at,
+			COALESCE(NULLIF(u.full_name, ''), u.username) AS creator_name,
+			(SELECT COUNT(*) FROM group_members m WHERE m.group_id = g.id AND m.deleted_at IS NULL) AS member_count,
+			COALESCE(me.role, '') AS my_role`).
+		Joins("JOIN users u ON u.id = g.created_by").
+		Joins("LEFT JOIN group_members me ON me.group_id = g.id AND me.user_id = ? AND me.deleted_at IS NULL", viewerID).
+		Where("g.deleted_at IS NULL")
+}
+
+// invitations selects invitations with their group and the names involved
+func (s *GroupService) invitations(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).Table("group_invitations i").
+		Select(`i.*, g.name AS group_name,
+			COALESCE(NULLIF(inviter.full_name, ''), inviter.username) AS inviter_name,
+			COALESCE(invitee.username, '') AS invitee_name`).
+		Joins("JOIN reading_groups g ON g.id = i.group_id AND g.deleted_at IS NULL").
+		Joins("JOIN users inviter ON inviter.id = i.inviter_id").
+		Joins("LEFT JOIN users invitee ON invitee.id = i.invitee_id").
+		Where("i.deleted_at IS NULL")
+}
+
+// validGroup checks a group's settings
+func validGroup(group *models.ReadingGroup) error {
+	if group.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalid)
+	}
+	if len(group.Name) > 255 {
+		return fmt.Errorf("%w name: at most 255 characters", ErrInvalid)
+	}
+	if !models.IsGroupType(group.GroupType) {
+		return fmt.Errorf("%w group type %q", ErrInvalid, group.GroupType)
+	}
+	if group.MaxMembers < 2 || group.MaxMembers > MaxGroupMembers {
+		return fmt.Errorf("%w max_members: between 2 and %d", ErrInvalid, MaxGroupMembers)
+	}
+	return nil
+}
+
+// lockGroup loads a group for update, serializing membership changes
+func lockGroup(tx *gorm.DB, groupID uuid.UUID) (*models.ReadingGroup, error) {
+	var group models.ReadingGroup
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", groupID).Limit(1).Find(&group)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve group: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("group %w", ErrNotFound)
+	}
+	return &group, nil
+}
+
+// groupMembership returns the user's membership of a group, or nil
+func groupMembership(db *gorm.DB, groupID, userID uuid.UUID) (*models.GroupMember, error) {
+	var member models.GroupMember
+	result := db.Where("group_id = ? AND user_id = ?", groupID, userID).Limit(1).Find(&member)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve membership: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &member, nil
+}
+
+// errNotMember is returned for public groups the user has not joined
+var errNotMember = fmt.Errorf("%w: not a member of this group", ErrAccessDenied)
+
+// requireGroupRole returns the user's membership if their role is at least
+// role. Private groups are reported not found to non-members.
+func requireGroupRole(db *gorm.DB, groupID, userID uuid.UUID, role string) (*models.GroupMember, error) {
+	member, err := groupMembership(db, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		var group models.ReadingGroup
+		result := db.Select("is_public").Where("id = ?", groupID).Limit(1).Find(&group)
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to retrieve group: %w", result.Error)
+		}
+		if result.RowsAffected == 0 || !group.IsPublic {
+			return nil, fmt.Errorf("group %w", ErrNotFound)
+		}
+		return nil, errNotMember
+	}
+	if models.GroupRoleRank(member.Role) < models.GroupRoleRank(role) {
+		return nil, fmt.Errorf("%w: requires the %s role", ErrAccessDenied, role)
+	}
+	return member, nil
+}
+
+// countGroupMembers counts a group's members
+func countGroupMembers(db *gorm.DB, groupID uuid.UUID) (int64, error) {
+	var count int64
+	if err := db.Model(&models.GroupMember{}).Where("group_id = ?", groupID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count members: %w", err)
+	}
+	return count, nil
+}
+
+// addGroupMember adds a user to a locked group as a member, within its
+// member limit
+func addGroupMember(tx *gorm.DB, group *models.ReadingGroup, userID uuid.UUID) error {
+	member, err := groupMembership(tx, group.ID, userID)
+	if err != nil {
+		return err
+	}
+	if member != nil {
+		return fmt.Errorf("%w: already a member of this group", ErrConflict)
+	}
+	count, err := countGroupMembers(tx, group.ID)
+	if err != nil {
+		return err
+	}
+	if count >= int64(group.MaxMembers) {
+		return fmt.Errorf("%w: the group is full (%d members)", ErrConflict, group.MaxMembers)
+	}
+	member = &models.GroupMember{GroupID: group.ID, UserID: userID, Role: models.GroupRoleMember, JoinedAt: time.Now()}
+	if err := tx.Omit(clause.Associations).Create(member).Error; err != nil {
+		return fmt.Errorf("failed to join group: %w", err)
+	}
+	return nil
+}
+
+// ensureOtherAdmin fails unless the group has an admin besides userID
+func ensureOtherAdmin(tx *gorm.DB, groupID, userID uuid.UUID) error {
+	var admins int64
+	if err := tx.Model(&models.GroupMember{}).Where("group_id = ? AND role = ? AND user_id <> ?",
+		groupID, models.GroupRoleAdmin, userID).Count(&admins).Error; err != nil {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+	if admins == 0 {
+		return fmt.Errorf("%w: make another member an admin first", ErrConflict)
+	}
+	return nil
+}
+
+// deleteGroup deletes a group with its members, invitations and book list
+func deleteGroup(tx *gorm.DB, groupID uuid.UUID) error {
+	if err := deleteSchedules(tx, tx.Model(&models.ReadingSchedule{}).Select("id").Where("group_id = ?", groupID)); err != nil {
+		return err
+	}
+	for _, model := range []interface{}{&models.GroupMember{}, &models.GroupInvitation{}, &models.GroupBook{}} {
+		if err := tx.Where("group_id = ?", groupID).Delete(model).Error; err != nil {
+			return fmt.Errorf("failed to delete group: %w", err)
+		}
+	}
+	if err := tx.Where("id = ?", groupID).Delete(&models.ReadingGroup{}).Error; err != nil {
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+	return nil
+}
+
+// canRemoveGroupMember reports whether a member with role actor can remove
+// one with role target: only those of a lower role
+func canRemoveGroupMember(actor, target string) bool {
+	return models.GroupRoleRank(actor) >= models.GroupRoleRank(models.GroupRoleModerator) &&
+		models.GroupRoleRank(actor) > models.GroupRoleRank(target)
+}
+
+// invitationUsable checks that an invitation can still be accepted
+func invitationUsable(invitation *models.GroupInvitation, now time.Time) error {
+	if invitation.Status != models.InvitationPending {
+		return fmt.Errorf("%w: invitation already %s", ErrConflict, invitation.Status)
+	}
+	if invitation.ExpiresAt != nil && now.After(*invitation.ExpiresAt) {
+		return fmt.Errorf("invitation %w", ErrExpired)
+	}
+	if invitation.MaxUses > 0 && invitation.Uses >= invitation.MaxUses {
+		return fmt.Errorf("invitation %w: it has been used %d times", ErrExpired, invitation.Uses)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestCanRemoveGroupMember(t *testing.T) {
+	tests := []struct {
+		actor, target string
+		want          bool
+	}{
+		{models.GroupRoleAdmin, models.GroupRoleModerator, true},
+		{models.GroupRoleAdmin, models.GroupRoleMember, true},
+		{models.GroupRoleAdmin, models.GroupRoleAdmin, false},
+		{models.GroupRoleModerator, models.GroupRoleMember, true},
+		{models.GroupRoleModerator, models.GroupRoleModerator, false},
+		{models.GroupRoleModerator, models.GroupRoleAdmin, false},
+		{models.GroupRoleMember, models.GroupRoleMember, false},
+		{"", models.GroupRoleMember, false},
+	}
+	for _, tt := range tests {
+		if got := canRemoveGroupMember(tt.actor, tt.target); got != tt.want {
+			t.Errorf("canRemoveGroupMember(%q, %q) = %v", tt.actor, tt.target, got)
+		}
+	}
+}
+
+func TestInvitationUsable(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	tests := []struct {
+		name       string
+		invitation models.GroupInvitation
+		err        string
+	}{
+		{"pending", models.GroupInvitation{Status: models.InvitationPending, ExpiresAt: &future}, ""},
+		{"no expiry", models.GroupInvitation{Status: models.InvitationPending}, ""},
+		{"uses left", models.GroupInvitation{Status: models.InvitationPending, MaxUses: 3, Uses: 2}, ""},
+		{"expired", models.GroupInvitation{Status: models.InvitationPending, ExpiresAt: &past}, "expired"},
+		{"used up", models.GroupInvitation{Status: models.InvitationPending, MaxUses: 3, Uses: 3}, "expired"},
+		{"revoked", models.GroupInvitation{Status: models.InvitationRevoked, ExpiresAt: &future}, "already"},
+		{"accepted", models.GroupInvitation{Status: models.InvitationAccepted}, "already"},
+	}
+	for _, tt := range tests {
+		err := invitationUsable(&tt.invitation, now)
+		if tt.err == "" && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestValidGroup(t *testing.T) {
+	valid := models.ReadingGroup{Name: "Stoics", GroupType: "study_group", MaxMembers: DefaultGroupMembers}
+	if err := validGroup(&valid); err != nil {
+		t.Errorf("valid group: %v", err)
+	}
+	for name, group := range map[string]models.ReadingGroup{
+		"no name":      {GroupType: "book_club", MaxMembers: 10},
+		"bad type":     {Name: "Stoics", GroupType: "salon", MaxMembers: 10},
+		"one member":   {Name: "Stoics", GroupType: "book_club", MaxMembers: 1},
+		"over the cap": {Name: "Stoics", GroupType: "book_club", MaxMembers: MaxGroupMembers + 1},
+	} {
+		if err := validGroup(&group); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
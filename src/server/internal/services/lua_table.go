@@ -0,0 +1,360 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parseLuaTable parses a Lua table literal such as KOReader's metadata
+// sidecars ("return { ... }"). Tables become maps keyed by the string form
+// of their keys, with positional entries keyed "1", "2", ...; numbers are
+// float64, strings string and booleans bool. Nothing is evaluated.
+func parseLuaTable(source []byte) (map[string]interface{}, error) {
+	p := &luaParser{src: string(source)}
+	p.skip()
+	if strings.HasPrefix(p.src[p.pos:], "return") {
+		p.pos += len("return")
+		p.skip()
+	}
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	table, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid lua: expected a table")
+	}
+	return table, nil
+}
+
+// luaList returns the positional entries of a table in order
+func luaList(value interface{}) []interface{} {
+	table, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	var keys []int
+	for key := range table {
+		if n, err := strconv.Atoi(key); err == nil {
+			keys = append(keys, n)
+		}
+	}
+	sort.Ints(keys)
+	values := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, table[strconv.Itoa(key)])
+	}
+	return values
+}
+
+// luaString returns a table field as a string; numbers are formatted
+func luaString(table map[string]interface{}, key string) string {
+	switch v := table[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// luaInt returns a numeric table field, or 0
+func luaInt(table map[string]interface{}, key string) int {
+	if v, ok := table[key].(float64); ok {
+		return int(v)
+	}
+	return 0
+}
+
+// maxLuaDepth bounds table nesting. KOReader sidecars nest fewer than ten
+// levels; deeper input is rejected rather than recursed into.
+const maxLuaDepth = 32
+
+type luaParser struct {
+	src   string
+	pos   int
+	depth int // Tables currently open
+}
+
+func (p *luaParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid lua at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// skip skips whitespace and comments
+func (p *luaParser) skip() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "--"):
+			p.pos += 2
+			if level, ok := p.longBracket(); ok {
+				p.longString(level)
+				continue
+			}
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *luaParser) value() (interface{}, error) {
+	p.skip()
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("unexpected end of input")
+	}
+	switch c := p.src[p.pos]; {
+	case c == '{':
+		return p.table()
+	case c == '"' || c == '\'':
+		return p.quoted()
+	case c == '[':
+		if level, ok := p.longBracket(); ok {
+			return p.longString(level), nil
+		}
+		return nil, p.errorf("unexpected '['")
+	case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return p.number()
+	}
+	for _, word := range []string{"true", "false", "nil"} {
+		if strings.HasPrefix(p.src[p.pos:], word) {
+			p.pos += len(word)
+			switch word {
+			case "true":
+				return true, nil
+			case "false":
+				return false, nil
+			}
+			return nil, nil
+		}
+	}
+	return nil, p.errorf("unexpected %q", p.src[p.pos])
+}
+
+func (p *luaParser) table() (map[string]interface{}, error) {
+	if p.depth >= maxLuaDepth {
+		return nil, p.errorf("tables nested deeper than %d levels", maxLuaDepth)
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+
+	p.pos++ // {
+	table := make(map[string]interface{})
+	next := 1
+	for {
+		p.skip()
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated table")
+		}
+		if p.src[p.pos] == '}' {
+			p.pos++
+			return table, nil
+		}
+
+		var key string
+		explicit := false
+		if p.src[p.pos] == '[' && !p.atLongBracket() {
+			p.pos++
+			k, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			p.skip()
+			if p.pos >= len(p.src) || p.src[p.pos] != ']' {
+				return nil, p.errorf("expected ']'")
+			}
+			p.pos++
+			key, explicit = luaKey(k), true
+		} else if name := p.name(); name != "" {
+			save := p.pos
+			p.skip()
+			if p.pos < len(p.src) && p.src[p.pos] == '=' && !strings.HasPrefix(p.src[p.pos:], "==") {
+				key, explicit = name, true
+			} else {
+				p.pos = save - len(name) // A bare word value such as true
+			}
+		}
+
+		if explicit {
+			p.skip()
+			if p.pos >= len(p.src) || p.src[p.pos] != '=' {
+				return nil, p.errorf("expected '='")
+			}
+			p.pos++
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if !explicit {
+			key = strconv.Itoa(next)
+			next++
+		}
+		if value != nil {
+			table[key] = value
+		}
+
+		p.skip()
+		if p.pos < len(p.src) && (p.src[p.pos] == ',' || p.src[p.pos] == ';') {
+			p.pos++
+		}
+	}
+}
+
+// name reads an identifier used as a table key
+func (p *luaParser) name() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (p.pos > start && c >= '0' && c <= '9') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	name := p.src[start:p.pos]
+	if name == "true" || name == "false" || name == "nil" {
+		p.pos = start
+		return ""
+	}
+	return name
+}
+
+func luaKey(k interface{}) string {
+	switch v := k.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+func (p *luaParser) number() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.src) && strings.IndexByte("0123456789+-.eExXabcdefABCDEF", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+	text := p.src[start:p.pos]
+	if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "-0x") {
+		n, err := strconv.ParseInt(strings.Replace(text, "0x", "", 1), 16, 64)
+		if err != nil {
+			return 0, p.errorf("invalid number %q", text)
+		}
+		return float64(n), nil
+	}
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, p.errorf("invalid number %q", text)
+	}
+	return n, nil
+}
+
+// quoted reads a quoted string, decoding the escapes written by %q
+func (p *luaParser) quoted() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\\' && p.pos+1 < len(p.src):
+			p.pos++
+			e := p.src[p.pos]
+			switch e {
+			case 'n', '\n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'a':
+				b.WriteByte('\a')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'v':
+				b.WriteByte('\v')
+			case '\r':
+				b.WriteByte('\n')
+				if p.pos+1 < len(p.src) && p.src[p.pos+1] == '\n' {
+					p.pos++
+				}
+			default:
+				if e >= '0' && e <= '9' {
+					end := p.pos
+					for end < len(p.src) && end < p.pos+3 && p.src[end] >= '0' && p.src[end] <= '9' {
+						end++
+					}
+					n, _ := strconv.Atoi(p.src[p.pos:end])
+					b.WriteByte(byte(n))
+					p.pos = end
+					continue
+				}
+				b.WriteByte(e) // \\, \", \'
+			}
+			p.pos++
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// longBracket checks for an opening long bracket ([[ or [==[) at the
+// current position and consumes it, returning its level
+func (p *luaParser) longBracket() (int, bool) {
+	if p.pos >= len(p.src) || p.src[p.pos] != '[' {
+		return 0, false
+	}
+	end := p.pos + 1
+	for end < len(p.src) && p.src[end] == '=' {
+		end++
+	}
+	if end >= len(p.src) || p.src[end] != '[' {
+		return 0, false
+	}
+	level := end - p.pos - 1
+	p.pos = end + 1
+	return level, true
+}
+
+// atLongBracket reports whether a long bracket opens at the current position
+func (p *luaParser) atLongBracket() bool {
+	save := p.pos
+	_, ok := p.longBracket()
+	p.pos = save
+	return ok
+}
+
+// longString reads the rest of a long string opened at level
+func (p *luaParser) longString(level int) string {
+	closing := "]" + strings.Repeat("=", level) + "]"
+	// A newline directly after the opening bracket is skipped
+	if strings.HasPrefix(p.src[p.pos:], "\r\n") {
+		p.pos += 2
+	} else if strings.HasPrefix(p.src[p.pos:], "\n") {
+		p.pos++
+	}
+	end := strings.Index(p.src[p.pos:], closing)
+	if end < 0 {
+		s := p.src[p.pos:]
+		p.pos = len(p.src)
+		return s
+	}
+	s := p.src[p.pos : p.pos+end]
+	p.pos += end + len(closing)
+	return s
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Markdown for discussion posts. A small subset is supported: paragraphs,
+// headings, lists, block quotes, code, emphasis and links. All HTML in the
+// source is escaped and links are limited to http, https and mailto, so the
+// output is safe to embed as is.
+
+var (
+	mdHeading  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	mdBullet   = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	mdOrdered  = regexp.MustCompile(`^\d{1,9}[.)]\s+(.*)$`)
+	mdCodeSpan = regexp.MustCompile("`([^`]+)`")
+	mdLink     = regexp.MustCompile(`\[([^\[\]]+)\]\(([^()\s]+)\)`)
+	mdStrong   = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdEmphasis = regexp.MustCompile(`\*([^*\s][^*]*)\*|\b_([^_]+)_\b`)
+	mdSlot     = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// RenderMarkdown renders a discussion post's Markdown as sanitized HTML
+func RenderMarkdown(src string) string {
+	src = strings.ReplaceAll(strings.ReplaceAll(src, "\r\n", "\n"), "\x00", "")
+	lines := strings.Split(src, "\n")
+
+	var b strings.Builder
+	var paragraph, quote []string
+	list := "" // The open list's tag
+	closeList := func() {
+		if list != "" {
+			b.WriteString("</" + list + ">\n")
+			list = ""
+		}
+	}
+	flush := func() {
+		if len(paragraph) > 0 {
+			b.WriteString("<p>" + renderInlines(paragraph) + "</p>\n")
+			paragraph = nil
+		}
+		if len(quote) > 0 {
+			b.WriteString("<blockquote><p>" + renderInlines(quote) + "</p></blockquote>\n")
+			quote = nil
+		}
+		closeList()
+	}
+	item := func(tag, text string) {
+		if len(paragraph) > 0 || len(quote) > 0 || list != tag {
+			flush()
+			b.WriteString("<" + tag + ">\n")
+			list = tag
+		}
+		b.WriteString("<li>" + renderInline(text) + "</li>\n")
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, "```") {
+			flush()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			b.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+			continue
+		}
+
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, ">"):
+			if len(paragraph) > 0 || list != "" {
+				flush()
+			}
+			quote = append(quote, strings.TrimSpace(strings.TrimPrefix(line, ">")))
+		case mdHeading.MatchString(line):
+			flush()
+			m := mdHeading.FindStringSubmatch(line)
+			// Posts sit within a page, so headings start at h3
+			level := len(m[1]) + 2
+			if level > 6 {
+				level = 6
+			}
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", level, renderInline(m[2]), level)
+		case mdBullet.MatchString(line):
+			item("ul", mdBullet.FindStringSubmatch(line)[1])
+		case mdOrdered.MatchString(line):
+			item("ol", mdOrdered.FindStringSubmatch(line)[1])
+		default:
+			if len(quote) > 0 || list != "" {
+				flush()
+			}
+			paragraph = append(paragraph, line)
+		}
+	}
+	flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// renderInlines renders lines of a block, keeping their line breaks
+func renderInlines(lines []string) string {
+	rendered := make([]string, len(lines))
+	for i, line := range lines {
+		rendered[i] = renderInline(line)
+	}
+	return strings.Join(rendered, "<br>\n")
+}
+
+// renderInline renders code spans, links and emphasis in escaped text. Code
+// spans and links are set aside in slots first, so emphasis never applies
+// inside them; a link's label is rendered on its own, so emphasis never
+// spans its tags.
+func renderInline(text string) string {
+	var slots []string
+	slot := func(s string) string {
+		slots = append(slots, s)
+		return fmt.Sprintf("\x00%d\x00", len(slots)-1)
+	}
+	fill := func(s string) string {
+		return mdSlot.ReplaceAllStringFunc(s, func(m string) string {
+			var i int
+			fmt.Sscanf(mdSlot.FindStringSubmatch(m)[1], "%d", &i)
+			return slots[i]
+		})
+	}
+
+	text = mdCodeSpan.ReplaceAllStringFunc(text, func(m string) string {
+		return slot("<code>" + html.EscapeString(mdCodeSpan.FindStringSubmatch(m)[1]) + "</code>")
+	})
+	text = html.EscapeString(text)
+	text = mdLink.ReplaceAllStringFunc(text, func(m string) string {
+		parts := mdLink.FindStringSubmatch(m)
+		href, ok := safeLink(html.UnescapeString(parts[2]))
+		if !ok {
+			return m
+		}
+		return slot(`<a href="` + html.EscapeString(href) + `" rel="nofollow noopener noreferrer">` + fill(renderEmphasis(parts[1])) + "</a>")
+	})
+	return fill(renderEmphasis(text))
+}
+
+// renderEmphasis renders strong and emphasized text
+func renderEmphasis(text string) string {
+	text = mdStrong.ReplaceAllString(text, "<strong>$1$2</strong>")
+	return mdEmphasis.ReplaceAllString(text, "<em>$1$2</em>")
+}
+
+// safeLink accepts absolute http, https and mailto links
+func safeLink(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			return "", false
+		}
+	case "mailto":
+		if u.Opaque == "" {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	return u.String(), true
+}
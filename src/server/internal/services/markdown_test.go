@@ -0,0 +1,49 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct{ name, src, want string }{
+		{"paragraphs", "First line\nsecond line\n\nNext", "<p>First line<br>\nsecond line</p>\n<p>Next</p>"},
+		{"emphasis", "**bold**, *em*, _em_ and snake_case_name", "<p><strong>bold</strong>, <em>em</em>, <em>em</em> and snake_case_name</p>"},
+		{"code", "Use `<b>*x*</b>`", "<p>Use <code>&lt;b&gt;*x*&lt;/b&gt;</code></p>"},
+		{"fence", "```\n<script>\n```", "<pre><code>&lt;script&gt;</code></pre>"},
+		{"heading", "# Book VII", "<h3>Book VII</h3>"},
+		{"lists", "- one\n- two\n1. first", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n<ol>\n<li>first</li>\n</ol>"},
+		{"quote", "> the cave\n> and the sun\nReply", "<blockquote><p>the cave<br>\nand the sun</p></blockquote>\n<p>Reply</p>"},
+		{"link", "[Perseus](https://www.perseus.tufts.edu/?a=1&b=2)",
+			`<p><a href="https://www.perseus.tufts.edu/?a=1&amp;b=2" rel="nofollow noopener noreferrer">Perseus</a></p>`},
+		{"link emphasis", "[x](https://example.org/_a_/*b*)", `<p><a href="https://example.org/_a_/*b*" rel="nofollow noopener noreferrer">x</a></p>`},
+		{"emphasis around link", "**a [x](http://y) b** c", `<p><strong>a <a href="http://y" rel="nofollow noopener noreferrer">x</a> b</strong> c</p>`},
+		{"emphasis in link", "[*x* `y`](http://y) **b", `<p><a href="http://y" rel="nofollow noopener noreferrer"><em>x</em> <code>y</code></a> **b</p>`},
+		{"emphasis across link", "**a [x** b](http://y)", `<p>**a <a href="http://y" rel="nofollow noopener noreferrer">x** b</a></p>`},
+	}
+	for _, tt := range tests {
+		if got := RenderMarkdown(tt.src); got != tt.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderMarkdownSanitizes(t *testing.T) {
+	for _, src := range []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror=alert(1)>`,
+		`[click](javascript:alert(1))`,
+		`[click](JaVaScRiPt:alert(1))`,
+		`[click](data:text/html;base64,PHNjcmlwdD4=)`,
+		`[x](https://example.org/"onmouseover="alert(1))`,
+		"**<iframe>**",
+		"\x00<b>0\x00",
+	} {
+		out := RenderMarkdown(src)
+		for _, bad := range []string{"<script", "<img", "<iframe", "<b>", "href=\"javascript", "href=\"data", "\"onmouseover"} {
+			if strings.Contains(strings.ToLower(out), bad) {
+				t.Errorf("%q rendered %q", src, out)
+			}
+		}
+	}
+}
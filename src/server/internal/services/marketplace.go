@@ -0,0 +1,658 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/classius/server/internal/models"
+)
+
+// Price limits for paid published notes, in cents
+const (
+	MinNotePriceCents = 50
+	MaxNotePriceCents = 100000
+)
+
+// Marketplace defaults
+const (
+	DefaultPlatformFeePercent = 20
+	DefaultRefundWindow       = 14 * 24 * time.Hour
+)
+
+// MarketplaceService sells paid published notes: orders paid through a
+// payment provider grant entitlements, and refunds revoke them
+type MarketplaceService struct {
+	db                 *gorm.DB
+	provider           PaymentProvider
+	currency           string
+	platformFeePercent int
+	refundWindow       time.Duration
+}
+
+// NewMarketplaceService creates a new marketplace service. Prices are in
+// currency; the platform keeps platformFeePercent of each sale, and buyers
+// can refund orders within refundWindow of paying.
+func NewMarketplaceService(db *gorm.DB, provider PaymentProvider, currency string, platformFeePercent int, refundWindow time.Duration) *MarketplaceService {
+	if currency == "" {
+		currency = "usd"
+	}
+	if platformFeePercent < 0 || platformFeePercent > 100 {
+		platformFeePercent = DefaultPlatformFeePercent
+	}
+	if refundWindow <= 0 {
+		refundWindow = DefaultRefundWindow
+	}
+	return &MarketplaceService{
+		db:                 db,
+		provider:           provider,
+		currency:           strings.ToLower(currency),
+		platformFeePercent: platformFeePercent,
+		refundWindow:       refundWindow,
+	}
+}
+
+// SimulatesPayments reports whether payments go through the local fake
+// provider, which SimulatePayment can complete
+func (s *MarketplaceService) SimulatesPayments() bool {
+	_, ok := s.provider.(*FakePaymentProvider)
+	return ok
+}
+
+// OrderSummary is an order with the note it bought
+type OrderSummary struct {
+	models.NoteOrder
+	NoteTitle string `json:"note_title"`
+}
+
+// EntitlementSummary is a note the user has access to through a purchase
+type EntitlementSummary struct {
+	PublishedNoteID uuid.UUID  `json:"published_note_id"`
+	NoteTitle       string     `json:"note_title"`
+	OrderID         *uuid.UUID `json:"order_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// RevenueReport sums an author's sales over a period. Sales count when
+// paid and refunds when refunded, so a refunded sale can fall in an
+// earlier period than its refund.
+type RevenueReport struct {
+	Currency         string           `json:"currency"`
+	From             *time.Time       `json:"from,omitempty"`
+	To               *time.Time       `json:"to,omitempty"`
+	Sales            int              `json:"sales"`
+	Refunds          int              `json:"refunds"`
+	GrossCents       int              `json:"gross_cents"`
+	RefundedCents    int              `json:"refunded_cents"`
+	PlatformFeeCents int              `json:"platform_fee_cents"`
+	NetCents         int              `json:"net_cents"` // The author's share of sales less refunds
+	Notes            []NoteRevenue    `json:"notes"`
+	Months           []MonthlyRevenue `json:"months"`
+}
+
+// NoteRevenue is one note's part of a revenue report
+type NoteRevenue struct {
+	PublishedNoteID uuid.UUID `json:"published_note_id"`
+	Title           string    `json:"title"`
+	Sales           int       `json:"sales"`
+	Refunds         int       `json:"refunds"`
+	GrossCents      int       `json:"gross_cents"`
+	RefundedCents   int       `json:"refunded_cents"`
+	NetCents        int       `json:"net_cents"`
+}
+
+// MonthlyRevenue is one calendar month (UTC) of a revenue report
+type MonthlyRevenue struct {
+	Month         string `json:"month"` // YYYY-MM
+	Sales         int    `json:"sales"`
+	Refunds       int    `json:"refunds"`
+	GrossCents    int    `json:"gross_cents"`
+	RefundedCents int    `json:"refunded_cents"`
+	NetCents      int    `json:"net_cents"`
+}
+
+// Checkout starts buying a paid note, returning an order whose payment
+// the buyer's client completes with the provider. Repeating a checkout
+// with the same idempotency key, or while an order for the note is
+// pending, returns the same order.
+func (s *MarketplaceService) Checkout(ctx context.Context, userID, noteID uuid.UUID, idempotencyKey string) (*models.NoteOrder, error) {
+	if s.provider == nil {
+		return nil, fmt.Errorf("%w: payments are not configured", ErrAccessDenied)
+	}
+	db := s.db.WithContext(ctx)
+	idempotencyKey = strings.TrimSpace(idempotencyKey)
+	if len(idempotencyKey) > 255 {
+		return nil, fmt.Errorf("%w idempotency key: at most 255 characters", ErrInvalid)
+	}
+
+	var order models.NoteOrder
+	if idempotencyKey != "" {
+		result := db.Where("user_id = ? AND idempotency_key = ?", userID, idempotencyKey).Limit(1).Find(&order)
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to retrieve order: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			if order.PublishedNoteID != noteID {
+				return nil, fmt.Errorf("%w: idempotency key was used for another note", ErrConflict)
+			}
+			return &order, nil
+		}
+	}
+
+	var note models.PublishedNote
+	result := db.Where("id = ? AND status = ?", noteID, models.NotePublished).Limit(1).Find(&note)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve published note: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("published note %w", ErrNotFound)
+	}
+	if note.AuthorID == userID {
+		return nil, fmt.Errorf("%w: authors cannot buy their own notes", ErrAccessDenied)
+	}
+	if note.PriceCents <= 0 {
+		return nil, fmt.Errorf("%w checkout: the note is free", ErrInvalid)
+	}
+	entitled, err := hasEntitlement(db, userID, noteID)
+	if err != nil {
+		return nil, err
+	}
+	if entitled {
+		return nil, fmt.Errorf("%w: note already purchased", ErrConflict)
+	}
+
+	result = db.Where("user_id = ? AND published_note_id = ? AND status = ? AND amount_cents = ? AND currency = ? AND provider = ?",
+		userID, noteID, models.OrderPending, note.PriceCents, s.currency, s.provider.Name()).
+		Where("provider_ref <> ''").Order("created_at DESC").Limit(1).Find(&order)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve order: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return &order, nil
+	}
+
+	order = models.NoteOrder{
+		UserID:          userID,
+		PublishedNoteID: noteID,
+		AuthorID:        note.AuthorID,
+		AmountCents:     note.PriceCents,
+		Currency:        s.currency,
+		Status:          models.OrderPending,
+		Provider:        s.provider.Name(),
+		IdempotencyKey:  idempotencyKey,
+	}
+	if err := db.Create(&order).Error; err != nil {
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	intent, err := s.provider.CreateIntent(ctx, PaymentIntentRequest{
+		OrderID:     order.ID,
+		AmountCents: order.AmountCents,
+		Currency:    order.Currency,
+		Description: note.Title,
+	})
+	if err != nil {
+		if updateErr := db.Model(&order).Updates(map[string]interface{}{
+			"status":         models.OrderFailed,
+			"failure_reason": err.Error(),
+		}).Error; updateErr != nil {
+			fmt.Printf("Warning: failed to mark order %s failed: %v\n", order.ID, updateErr)
+		}
+		return nil, fmt.Errorf("failed to start payment: %w", err)
+	}
+	order.ProviderRef, order.ClientSecret, order.CheckoutURL = intent.ProviderRef, intent.ClientSecret, intent.CheckoutURL
+	if err := db.Model(&order).Updates(map[string]interface{}{
+		"provider_ref":  order.ProviderRef,
+		"client_secret": order.ClientSecret,
+		"checkout_url":  order.CheckoutURL,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to save payment: %w", err)
+	}
+	return &order, nil
+}
+
+// HandleWebhook applies a payment provider's webhook event. Each event is
+// applied once; redeliveries are acknowledged and ignored.
+func (s *MarketplaceService) HandleWebhook(ctx context.Context, providerName string, payload []byte, header http.Header) error {
+	if s.provider == nil || providerName != s.provider.Name() {
+		return fmt.Errorf("payment provider %w", ErrNotFound)
+	}
+	event, err := s.provider.ParseWebhook(payload, header)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		record := models.PaymentEvent{
+			Provider:    providerName,
+			EventID:     event.ID,
+			Type:        event.Type,
+			ProviderRef: event.ProviderRef,
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			return fmt.Errorf("failed to record payment event: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		var order models.NoteOrder
+		result = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND provider_ref = ?", providerName, event.ProviderRef).Limit(1).Find(&order)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve order: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			fmt.Printf("Warning: payment event %s for unknown payment %s\n", event.ID, event.ProviderRef)
+			return nil
+		}
+
+		now := time.Now()
+		switch event.Type {
+		case PaymentSucceeded:
+			return s.markPaid(tx, &order, event, now)
+		case PaymentFailed:
+			if order.Status != models.OrderPending {
+				return nil
+			}
+			return tx.Model(&order).Updates(map[string]interface{}{
+				"status":         models.OrderFailed,
+				"failure_reason": event.Reason,
+			}).Error
+		case RefundSucceeded:
+			return finalizeRefund(tx, &order, "", now)
+		}
+		return nil
+	})
+}
+
+// markPaid records an order's payment and grants its entitlement. Payments
+// for orders that failed meanwhile are still honoured. A payment that does
+// not match the order fails it for review instead: the event is still
+// recorded, so the provider does not redeliver it.
+func (s *MarketplaceService) markPaid(tx *gorm.DB, order *models.NoteOrder, event *PaymentEventPayload, now time.Time) error {
+	if order.Status != models.OrderPending && order.Status != models.OrderFailed {
+		return nil
+	}
+	if event.AmountCents != order.AmountCents || !strings.EqualFold(event.Currency, order.Currency) {
+		reason := fmt.Sprintf("payment of %d %s does not match the order", event.AmountCents, event.Currency)
+		fmt.Printf("Warning: payment event %s for order %s: %s\n", event.ID, order.ID, reason)
+		if err := tx.Model(order).Updates(map[string]interface{}{
+			"status":         models.OrderFailed,
+			"failure_reason": reason,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update order: %w", err)
+		}
+		return nil
+	}
+
+	fee, share := splitRevenue(order.AmountCents, s.platformFeePercent)
+	if err := tx.Model(order).Updates(map[string]interface{}{
+		"status":             models.OrderPaid,
+		"paid_at":            now,
+		"platform_fee_cents": fee,
+		"author_share_cents": share,
+		"failure_reason":     "",
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+
+	entitled, err := hasEntitlement(tx, order.UserID, order.PublishedNoteID)
+	if err != nil || entitled {
+		return err
+	}
+	orderID := order.ID
+	if err := tx.Create(&models.NoteEntitlement{
+		UserID:          order.UserID,
+		PublishedNoteID: order.PublishedNoteID,
+		OrderID:         &orderID,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to grant entitlement: %w", err)
+	}
+	return nil
+}
+
+// finalizeRefund applies a refund the provider made to a locked order. It
+// is reached both from Refund and from the provider's webhook, in either
+// order, and applies the refund once.
+func finalizeRefund(tx *gorm.DB, order *models.NoteOrder, refundRef string, now time.Time) error {
+	switch order.Status {
+	case models.OrderPaid, models.OrderRefundPending:
+		if err := markRefunded(tx, order, refundRef, now); err != nil {
+			return err
+		}
+		order.Status, order.RefundedAt, order.RefundRef = models.OrderRefunded, &now, refundRef
+	case models.OrderRefunded:
+		// The webhook got there first; keep the reference the provider returned
+		if refundRef != "" && order.RefundRef == "" {
+			if err := tx.Model(order).Update("refund_ref", refundRef).Error; err != nil {
+				return fmt.Errorf("failed to update order: %w", err)
+			}
+			order.RefundRef = refundRef
+		}
+	}
+	return nil
+}
+
+// markRefunded records an order's refund and revokes the entitlement it
+// granted
+func markRefunded(tx *gorm.DB, order *models.NoteOrder, refundRef string, now time.Time) error {
+	if err := tx.Model(order).Updates(map[string]interface{}{
+		"status":      models.OrderRefunded,
+		"refunded_at": now,
+		"refund_ref":  refundRef,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+	if err := tx.Where("order_id = ?", order.ID).Delete(&models.NoteEntitlement{}).Error; err != nil {
+		return fmt.Errorf("failed to revoke entitlement: %w", err)
+	}
+	return nil
+}
+
+// Refund refunds a paid order. Buyers can refund within the refund window;
+// authors can refund their sales at any time. The provider is called
+// outside any transaction: the order is marked refund_pending first, which
+// refuses concurrent refunds, and the refund is applied afterwards the same
+// way its webhook applies it.
+func (s *MarketplaceService) Refund(ctx context.Context, userID, orderID uuid.UUID) (*models.NoteOrder, error) {
+	if s.provider == nil {
+		return nil, fmt.Errorf("%w: payments are not configured", ErrAccessDenied)
+	}
+	order, err := s.beginRefund(ctx, userID, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	refundRef, err := s.provider.Refund(ctx, order.ProviderRef, order.AmountCents)
+	if err != nil {
+		// Reopen the order so the refund can be retried. Should the provider
+		// have refunded it anyway, its webhook still applies the refund.
+		if reopenErr := s.db.WithContext(context.WithoutCancel(ctx)).Model(&models.NoteOrder{}).
+			Where("id = ? AND status = ?", order.ID, models.OrderRefundPending).
+			Update("status", models.OrderPaid).Error; reopenErr != nil {
+			fmt.Printf("Warning: failed to reopen order %s after a failed refund: %v\n", order.ID, reopenErr)
+		}
+		return nil, fmt.Errorf("failed to refund payment: %w", err)
+	}
+
+	// The payment is refunded: apply it even if the request was cancelled
+	err = s.db.WithContext(context.WithoutCancel(ctx)).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", order.ID).Limit(1).Find(order)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve order: %w", result.Error)
+		}
+		return finalizeRefund(tx, order, refundRef, time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// beginRefund checks that the user can refund an order and marks it
+// refund_pending
+func (s *MarketplaceService) beginRefund(ctx context.Context, userID, orderID uuid.UUID) (*models.NoteOrder, error) {
+	var order models.NoteOrder
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND (user_id = ? OR author_id = ?)", orderID, userID, userID).Limit(1).Find(&order)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve order: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("order %w", ErrNotFound)
+		}
+		switch {
+		case order.Status == models.OrderRefunded:
+			return fmt.Errorf("%w: order already refunded", ErrConflict)
+		case order.Status == models.OrderRefundPending:
+			return fmt.Errorf("%w: a refund is already in progress", ErrConflict)
+		case order.Status != models.OrderPaid:
+			return fmt.Errorf("%w status: only paid orders can be refunded", ErrInvalid)
+		case order.AuthorID != userID && order.PaidAt != nil && time.Since(*order.PaidAt) > s.refundWindow:
+			return fmt.Errorf("%w: the refund window has closed", ErrAccessDenied)
+		case order.Provider != s.provider.Name():
+			return fmt.Errorf("%w: the order was paid through another provider", ErrAccessDenied)
+		}
+
+		if err := tx.Model(&order).Update("status", models.OrderRefundPending).Error; err != nil {
+			return fmt.Errorf("failed to update order: %w", err)
+		}
+		order.Status = models.OrderRefundPending
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// SimulatePayment completes or fails a pending order's payment by sending
+// the webhook event the fake provider would, for development without a
+// real provider
+func (s *MarketplaceService) SimulatePayment(ctx context.Context, userID, orderID uuid.UUID, succeed bool) (*models.NoteOrder, error) {
+	fake, ok := s.provider.(*FakePaymentProvider)
+	if !ok {
+		return nil, fmt.Errorf("%w: payments are not simulated", ErrAccessDenied)
+	}
+	order, err := s.Order(ctx, userID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.UserID != userID {
+		return nil, fmt.Errorf("order %w", ErrNotFound)
+	}
+
+	event := PaymentEventPayload{
+		Type:        PaymentSucceeded,
+		ProviderRef: order.ProviderRef,
+		AmountCents: order.AmountCents,
+		Currency:    order.Currency,
+	}
+	if !succeed {
+		event.Type, event.Reason = PaymentFailed, "card declined"
+	}
+	payload, header, err := fake.SignedEvent(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to simulate payment: %w", err)
+	}
+	if err := s.HandleWebhook(ctx, fake.Name(), payload, header); err != nil {
+		return nil, err
+	}
+	return s.Order(ctx, userID, orderID)
+}
+
+// Order returns an order the user bought or sold
+func (s *MarketplaceService) Order(ctx context.Context, userID, orderID uuid.UUID) (*models.NoteOrder, error) {
+	var order models.NoteOrder
+	result := s.db.WithContext(ctx).Where("id = ? AND (user_id = ? OR author_id = ?)", orderID, userID, userID).Limit(1).Find(&order)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve order: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("order %w", ErrNotFound)
+	}
+	if order.UserID != userID {
+		order.ClientSecret, order.CheckoutURL = "", ""
+	}
+	return &order, nil
+}
+
+// Orders lists the user's purchases, newest first
+func (s *MarketplaceService) Orders(ctx context.Context, userID uuid.UUID, limit, offset int) ([]OrderSummary, int64, error) {
+	query := s.db.WithContext(ctx).Table("note_orders o").
+		Joins("JOIN published_notes pn ON pn.id = o.published_note_id").
+		Where("o.user_id = ? AND o.deleted_at IS NULL", userID)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count orders: %w", err)
+	}
+	orders := []OrderSummary{}
+	if err := query.Select("o.*, pn.title AS note_title").
+		Order("o.created_at DESC").Limit(limit).Offset(offset).Scan(&orders).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve orders: %w", err)
+	}
+	return orders, total, nil
+}
+
+// Entitlements lists the paid notes the user has access to
+func (s *MarketplaceService) Entitlements(ctx context.Context, userID uuid.UUID) ([]EntitlementSummary, error) {
+	entitlements := []EntitlementSummary{}
+	if err := s.db.WithContext(ctx).Table("note_entitlements e").
+		Select("e.published_note_id, pn.title AS note_title, e.order_id, e.created_at").
+		Joins("JOIN published_notes pn ON pn.id = e.published_note_id AND pn.deleted_at IS NULL").
+		Where("e.user_id = ? AND e.deleted_at IS NULL", userID).
+		Order("e.created_at DESC").Scan(&entitlements).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve entitlements: %w", err)
+	}
+	return entitlements, nil
+}
+
+// revenueOrder is a sale or refund in an author's revenue report
+type revenueOrder struct {
+	PublishedNoteID  uuid.UUID
+	Title            string
+	AmountCents      int
+	PlatformFeeCents int
+	AuthorShareCents int
+	PaidAt           *time.Time
+	RefundedAt       *time.Time
+}
+
+// Revenue reports an author's sales and refunds between from and to,
+// either of which may be open
+func (s *MarketplaceService) Revenue(ctx context.Context, authorID uuid.UUID, from, to *time.Time) (*RevenueReport, error) {
+	inRange := func(column string) (string, []interface{}) {
+		condition, args := column+" IS NOT NULL", []interface{}{}
+		if from != nil {
+			condition += " AND " + column + " >= ?"
+			args = append(args, *from)
+		}
+		if to != nil {
+			condition += " AND " + column + " < ?"
+			args = append(args, *to)
+		}
+		return condition, args
+	}
+	paid, paidArgs := inRange("o.paid_at")
+	refunded, refundedArgs := inRange("o.refunded_at")
+
+	var orders []revenueOrder
+	if err := s.db.WithContext(ctx).Table("note_orders o").
+		Select("o.published_note_id, pn.title, o.amount_cents, o.platform_fee_cents, o.author_share_cents, o.paid_at, o.refunded_at").
+		Joins("JOIN published_notes pn ON pn.id = o.published_note_id").
+		Where("o.author_id = ? AND o.currency = ? AND o.status IN ? AND o.deleted_at IS NULL",
+			authorID, s.currency, []string{models.OrderPaid, models.OrderRefundPending, models.OrderRefunded}).
+		Where("(("+paid+") OR ("+refunded+"))", append(paidArgs, refundedArgs...)...).
+		Scan(&orders).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve orders: %w", err)
+	}
+
+	report := aggregateRevenue(orders, from, to)
+	report.Currency = s.currency
+	return report, nil
+}
+
+// aggregateRevenue totals orders paid or refunded between from and to, by
+// note and by month
+func aggregateRevenue(orders []revenueOrder, from, to *time.Time) *RevenueReport {
+	report := &RevenueReport{From: from, To: to, Notes: []NoteRevenue{}, Months: []MonthlyRevenue{}}
+	within := func(t *time.Time) bool {
+		return t != nil && (from == nil || !t.Before(*from)) && (to == nil || t.Before(*to))
+	}
+	notes := make(map[uuid.UUID]*NoteRevenue)
+	months := make(map[string]*MonthlyRevenue)
+	month := func(t time.Time) *MonthlyRevenue {
+		key := t.UTC().Format("2006-01")
+		if months[key] == nil {
+			months[key] = &MonthlyRevenue{Month: key}
+		}
+		return months[key]
+	}
+
+	for _, o := range orders {
+		note := notes[o.PublishedNoteID]
+		if note == nil {
+			note = &NoteRevenue{PublishedNoteID: o.PublishedNoteID, Title: o.Title}
+			notes[o.PublishedNoteID] = note
+		}
+		if within(o.PaidAt) {
+			m := month(*o.PaidAt)
+			report.Sales++
+			report.GrossCents += o.AmountCents
+			report.PlatformFeeCents += o.PlatformFeeCents
+			report.NetCents += o.AuthorShareCents
+			note.Sales++
+			note.GrossCents += o.AmountCents
+			note.NetCents += o.AuthorShareCents
+			m.Sales++
+			m.GrossCents += o.AmountCents
+			m.NetCents += o.AuthorShareCents
+		}
+		if within(o.RefundedAt) {
+			m := month(*o.RefundedAt)
+			report.Refunds++
+			report.RefundedCents += o.AmountCents
+			report.PlatformFeeCents -= o.PlatformFeeCents
+			report.NetCents -= o.AuthorShareCents
+			note.Refunds++
+			note.RefundedCents += o.AmountCents
+			note.NetCents -= o.AuthorShareCents
+			m.Refunds++
+			m.RefundedCents += o.AmountCents
+			m.NetCents -= o.AuthorShareCents
+		}
+	}
+
+	for _, note := range notes {
+		report.Notes = append(report.Notes, *note)
+	}
+	sort.Slice(report.Notes, func(i, j int) bool {
+		if report.Notes[i].NetCents != report.Notes[j].NetCents {
+			return report.Notes[i].NetCents > report.Notes[j].NetCents
+		}
+		return report.Notes[i].Title < report.Notes[j].Title
+	})
+	for _, m := range months {
+		report.Months = append(report.Months, *m)
+	}
+	sort.Slice(report.Months, func(i, j int) bool {
+		return report.Months[i].Month < report.Months[j].Month
+	})
+	return report
+}
+
+// splitRevenue divides a sale between the platform's fee, rounded to the
+// nearest cent, and the author's share
+func splitRevenue(amountCents, feePercent int) (int, int) {
+	fee := (amountCents*feePercent + 50) / 100
+	return fee, amountCents - fee
+}
+
+// hasEntitlement reports whether the user has bought access to a note
+func hasEntitlement(db *gorm.DB, userID, noteID uuid.UUID) (bool, error) {
+	var count int64
+	if err := db.Model(&models.NoteEntitlement{}).
+		Where("user_id = ? AND published_note_id = ?", userID, noteID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to retrieve entitlement: %w", err)
+	}
+	return count > 0, nil
+}
+
+// validNotePrice checks a note's price: free, or within the marketplace's
+// limits
+func validNotePrice(cents int) error {
+	if cents != 0 && (cents < MinNotePriceCents || cents > MaxNotePriceCents) {
+		return fmt.Errorf("%w price: use 0 for free or %d to %d cents", ErrInvalid, MinNotePriceCents, MaxNotePriceCents)
+	}
+	return nil
+}
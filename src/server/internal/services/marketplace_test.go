@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestFakePaymentProviderWebhook(t *testing.T) {
+	provider := NewFakePaymentProvider("secret")
+	intent, err := provider.CreateIntent(context.Background(), PaymentIntentRequest{OrderID: uuid.New(), AmountCents: 500, Currency: "usd"})
+	if err != nil || !strings.HasPrefix(intent.ProviderRef, "fake_pi_") || intent.ClientSecret == "" {
+		t.Fatalf("got %+v, %v", intent, err)
+	}
+
+	payload, header, err := provider.SignedEvent(PaymentEventPayload{
+		Type: PaymentSucceeded, ProviderRef: intent.ProviderRef, AmountCents: 500, Currency: "usd",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := provider.ParseWebhook(payload, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ID == "" || event.Type != PaymentSucceeded || event.ProviderRef != intent.ProviderRef || event.AmountCents != 500 {
+		t.Errorf("got %+v", event)
+	}
+
+	tampered := []byte(strings.Replace(string(payload), "500", "5", 1))
+	if _, err := provider.ParseWebhook(tampered, header); err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Errorf("tampered payload: got %v", err)
+	}
+	if _, err := NewFakePaymentProvider("other").ParseWebhook(payload, header); err == nil {
+		t.Errorf("accepted an event signed with another secret")
+	}
+	if _, err := NewFakePaymentProvider("").ParseWebhook(payload, nil); err == nil {
+		t.Errorf("accepted an unsigned event")
+	}
+	if _, err := provider.CreateIntent(context.Background(), PaymentIntentRequest{AmountCents: 0}); err == nil {
+		t.Errorf("created a free payment")
+	}
+}
+
+func TestSplitRevenue(t *testing.T) {
+	tests := []struct{ amount, percent, fee, share int }{
+		{1000, 20, 200, 800},
+		{999, 20, 200, 799}, // 199.8 rounds up
+		{50, 15, 8, 42},     // 7.5 rounds up
+		{1000, 0, 0, 1000},
+		{1000, 100, 1000, 0},
+	}
+	for _, tt := range tests {
+		if fee, share := splitRevenue(tt.amount, tt.percent); fee != tt.fee || share != tt.share {
+			t.Errorf("splitRevenue(%d, %d) = %d, %d; want %d, %d", tt.amount, tt.percent, fee, share, tt.fee, tt.share)
+		}
+	}
+}
+
+func TestValidNotePrice(t *testing.T) {
+	for _, cents := range []int{0, MinNotePriceCents, 999, MaxNotePriceCents} {
+		if err := validNotePrice(cents); err != nil {
+			t.Errorf("%d: %v", cents, err)
+		}
+	}
+	for _, cents := range []int{-1, 1, MinNotePriceCents - 1, MaxNotePriceCents + 1} {
+		if err := validNotePrice(cents); err == nil {
+			t.Errorf("%d accepted", cents)
+		}
+	}
+}
+
+func TestAggregateRevenue(t *testing.T) {
+	date := func(s string) *time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &d
+	}
+	republic, laws := uuid.New(), uuid.New()
+	orders := []revenueOrder{
+		{PublishedNoteID: republic, Title: "Republic", AmountCents: 1000, PlatformFeeCents: 200, AuthorShareCents: 800, PaidAt: date("2026-03-02")},
+		{PublishedNoteID: republic, Title: "Republic", AmountCents: 1000, PlatformFeeCents: 200, AuthorShareCents: 800, PaidAt: date("2026-03-20"), RefundedAt: date("2026-04-01")},
+		{PublishedNoteID: laws, Title: "Laws", AmountCents: 500, PlatformFeeCents: 100, AuthorShareCents: 400, PaidAt: date("2026-04-10")},
+		// Paid before the period, refunded in it
+		{PublishedNoteID: laws, Title: "Laws", AmountCents: 500, PlatformFeeCents: 100, AuthorShareCents: 400, PaidAt: date("2026-02-27"), RefundedAt: date("2026-03-05")},
+	}
+
+	report := aggregateRevenue(orders, date("2026-03-01"), date("2026-05-01"))
+	if report.Sales != 3 || report.Refunds != 2 || report.GrossCents != 2500 || report.RefundedCents != 1500 ||
+		report.PlatformFeeCents != 200 || report.NetCents != 800 {
+		t.Errorf("totals %+v", report)
+	}
+	if len(report.Notes) != 2 || report.Notes[0].Title != "Republic" || report.Notes[0].NetCents != 800 ||
+		report.Notes[1].NetCents != 0 || report.Notes[1].Sales != 1 || report.Notes[1].Refunds != 1 {
+		t.Errorf("notes %+v", report.Notes)
+	}
+	if len(report.Months) != 2 || report.Months[0].Month != "2026-03" || report.Months[0].NetCents != 1200 ||
+		report.Months[1].Month != "2026-04" || report.Months[1].NetCents != -400 {
+		t.Errorf("months %+v", report.Months)
+	}
+
+	open := aggregateRevenue(orders, nil, nil)
+	if open.Sales != 4 || open.Refunds != 2 || open.NetCents != 1200 {
+		t.Errorf("open period %+v", open)
+	}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/classius/server/internal/models"
+)
+
+// PublishedOverlay is an overlay as shown to a reader. Positions are in the
+// reader's copy of the book once anchored.
+type PublishedOverlay struct {
+	ID              uuid.UUID `json:"id"`
+	PublishedNoteID uuid.UUID `json:"published_note_id"`
+	NoteTitle       string    `json:"note_title,omitempty"`
+	Version         int       `json:"version"`
+	PageNumber      int       `json:"page_number"`
+	StartPosition   int       `json:"start_position"`
+	EndPosition     int       `json:"end_position"`
+	NoteType        string    `json:"note_type"`
+	Content         string    `json:"content"`
+	Quote           string    `json:"quote,omitempty"`
+	DisplayOrder    int       `json:"display_order"`
+	Anchored        bool      `json:"anchored"` // False when the passage was not found in the reader's copy
+}
+
+// BookOverlays are the overlays from a reader's subscriptions that apply to
+// one of their books
+type BookOverlays struct {
+	BookID   uuid.UUID              `json:"book_id"`
+	Notes    []PublishedNoteSummary `json:"notes"`
+	Overlays []PublishedOverlay     `json:"overlays"`
+}
+
+// BookOverlays merges the overlays of the notes the user subscribes to that
+// were published for the same book as bookID, matched by ISBN or text
+// fingerprint, and anchors them in the user's copy
+func (s *PublishedNoteService) BookOverlays(ctx context.Context, userID, bookID uuid.UUID) (*BookOverlays, error) {
+	db := s.db.WithContext(ctx)
+	var book models.Book
+	result := db.Where("id = ? AND user_id = ?", bookID, userID).Limit(1).Find(&book)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve book: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("book %w", ErrNotFound)
+	}
+
+	merged := &BookOverlays{BookID: bookID, Notes: []PublishedNoteSummary{}, Overlays: []PublishedOverlay{}}
+	match, args, err := s.bookMatch(ctx, &book)
+	if err != nil || match == "" {
+		return merged, err
+	}
+	// Withdrawn notes stay with their subscribers
+	if err := s.summaries(ctx, userID).
+		Where("ns.id IS NOT NULL AND pn.version > 0").
+		Where(match, args...).
+		Order("pn.title").Scan(&merged.Notes).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve subscriptions: %w", err)
+	}
+	if len(merged.Notes) == 0 {
+		return merged, nil
+	}
+
+	locator, err := bookLocator(db, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, note := range merged.Notes {
+		if !note.Entitled { // Paid notes stay locked until bought
+			continue
+		}
+		version := note.Version
+		if note.PinnedVersion != nil {
+			version = *note.PinnedVersion
+		}
+		var overlays []models.NoteOverlay
+		if err := db.Where("published_note_id = ? AND version = ?", note.ID, version).
+			Order("display_order").Find(&overlays).Error; err != nil {
+			return nil, fmt.Errorf("failed to retrieve overlays: %w", err)
+		}
+		sameText := book.TextFingerprint != "" && book.TextFingerprint == note.BookFingerprint
+		for i := range overlays {
+			view := overlayView(&overlays[i], note.Title)
+			anchorOverlay(&view, &overlays[i], locator, sameText)
+			merged.Overlays = append(merged.Overlays, view)
+		}
+	}
+
+	sort.SliceStable(merged.Overlays, func(i, j int) bool {
+		a, b := merged.Overlays[i], merged.Overlays[j]
+		if a.PageNumber != b.PageNumber {
+			return a.PageNumber < b.PageNumber
+		}
+		return a.StartPosition < b.StartPosition
+	})
+	return merged, nil
+}
+
+// anchorOverlay places an overlay in the reader's copy of the book. Quoted
+// overlays are kept where the quote still is, or moved to where it is found
+// near its old place; others are only trusted in the same text.
+func anchorOverlay(view *PublishedOverlay, overlay *models.NoteOverlay, locator *QuoteLocator, sameText bool) {
+	view.StartPosition, view.EndPosition, view.Anchored = anchorQuote(locator, overlay.StartPosition, overlay.EndPosition,
+		overlay.Quote, overlay.QuotePrefix, overlay.QuoteSuffix, sameText)
+}
+
+// anchorQuote places a passage quoted from another copy of a book in the
+// reader's copy, returning its offsets there and whether it was found.
+// Without a quote or the reader's text, the offsets are only trusted in
+// the same text.
+func anchorQuote(locator *QuoteLocator, start, end int, quote, prefix, suffix string, sameText bool) (int, int, bool) {
+	if quote == "" || locator == nil {
+		return start, end, sameText
+	}
+	if locator.Matches(start, end, quote) {
+		return start, end, true
+	}
+	if found, foundEnd, ok := locator.Locate(quote, prefix, suffix, start); ok {
+		return found, foundEnd, true
+	}
+	return start, end, false
+}
+
+// overlayView converts a stored overlay for display, at its author's
+// positions
+func overlayView(overlay *models.NoteOverlay, noteTitle string) PublishedOverlay {
+	return PublishedOverlay{
+		ID:              overlay.ID,
+		PublishedNoteID: overlay.PublishedNoteID,
+		NoteTitle:       noteTitle,
+		Version:         overlay.Version,
+		PageNumber:      overlay.PageNumber,
+		StartPosition:   overlay.StartPosition,
+		EndPosition:     overlay.EndPosition,
+		NoteType:        overlay.NoteType,
+		Content:         overlay.Content,
+		Quote:           overlay.Quote,
+		DisplayOrder:    overlay.DisplayOrder,
+		Anchored:        true,
+	}
+}
+
+// bookLocator returns a locator over a book's text, or nil if the text has
+// not been extracted
+func bookLocator(db *gorm.DB, bookID uuid.UUID) (*QuoteLocator, error) {
+	var content models.BookContent
+	result := db.Where("book_id = ?", bookID).Limit(1).Find(&content)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve book content: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return NewQuoteLocator(content.FullText), nil
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/classius/server/internal/models"
+)
+
+// VersionReview is a submitted note version in the admins' review queue
+type VersionReview struct {
+	ID              uuid.UUID  `json:"id"`
+	PublishedNoteID uuid.UUID  `json:"published_note_id"`
+	Version         int        `json:"version"`
+	Title           string     `json:"title"`
+	Description     string     `json:"description"`
+	DifficultyLevel string     `json:"difficulty_level"`
+	Changelog       string     `json:"changelog,omitempty"`
+	OverlayCount    int        `json:"overlay_count"`
+	ReviewStatus    string     `json:"review_status"`
+	ReviewComment   string     `json:"review_comment,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+	AuthorID        uuid.UUID  `json:"author_id"`
+	AuthorName      string     `json:"author_name"`
+	AuthorVerified  bool       `json:"author_verified"`
+	BookTitle       string     `json:"book_title"`
+	BookAuthor      string     `json:"book_author"`
+	PriceCents      int        `json:"price_cents"`
+	DownloadsCount  int        `json:"downloads_count"`
+	LiveVersion     int        `json:"live_version"` // The note's current version, 0 if none
+	SubmittedAt     time.Time  `json:"submitted_at"`
+}
+
+// VersionReviewDetail is a submitted version with the overlays under
+// review
+type VersionReviewDetail struct {
+	VersionReview
+	Overlays []PublishedOverlay `json:"overlays"`
+}
+
+// ReviewQueue lists note versions with a review status, submitted by
+// default, oldest first
+func (s *PublishedNoteService) ReviewQueue(ctx context.Context, status string, limit, offset int) ([]VersionReview, int64, error) {
+	if status == "" {
+		status = models.ReviewSubmitted
+	}
+	query := s.versionReviews(ctx).Where("v.review_status = ?", status)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count reviews: %w", err)
+	}
+	reviews := []VersionReview{}
+	if err := query.Order("v.created_at").Limit(limit).Offset(offset).Scan(&reviews).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve reviews: %w", err)
+	}
+	return reviews, total, nil
+}
+
+// ReviewDetail returns a note version with its overlays, for review
+func (s *PublishedNoteService) ReviewDetail(ctx context.Context, versionID uuid.UUID) (*VersionReviewDetail, error) {
+	var detail VersionReviewDetail
+	result := s.versionReviews(ctx).Where("v.id = ?", versionID).Limit(1).Scan(&detail.VersionReview)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve version: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("version %w", ErrNotFound)
+	}
+
+	var overlays []models.NoteOverlay
+	if err := s.db.WithContext(ctx).Where("published_note_id = ? AND version = ?", detail.PublishedNoteID, detail.Version).
+		Order("display_order").Find(&overlays).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve overlays: %w", err)
+	}
+	detail.Overlays = make([]PublishedOverlay, len(overlays))
+	for i := range overlays {
+		detail.Overlays[i] = overlayView(&overlays[i], detail.Title)
+	}
+	return &detail, nil
+}
+
+// DecideVersion approves a submitted version, releasing it to readers, or
+// rejects it with a reason for the author
+func (s *PublishedNoteService) DecideVersion(ctx context.Context, adminID, versionID uuid.UUID, approve bool, comment string) (*models.PublishedNoteVersion, error) {
+	comment = strings.TrimSpace(comment)
+	if !approve && comment == "" {
+		return nil, fmt.Errorf("%w: comment required: explain why the version is rejected", ErrInvalid)
+	}
+	var version models.PublishedNoteVersion
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", versionID).Limit(1).Find(&version)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve version: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("version %w", ErrNotFound)
+		}
+		var note models.PublishedNote
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", version.PublishedNoteID).First(&note).Error; err != nil {
+			return fmt.Errorf("failed to retrieve published note: %w", err)
+		}
+		if note.AuthorID == adminID {
+			return fmt.Errorf("%w: admins cannot review their own notes", ErrAccessDenied)
+		}
+		if version.ReviewStatus != models.ReviewSubmitted {
+			return fmt.Errorf("%w status: the version is %s", ErrInvalid, version.ReviewStatus)
+		}
+
+		status, action := models.ReviewRejected, "rejected"
+		if approve {
+			status, action = models.ReviewApproved, "approved"
+		}
+		now := time.Now()
+		if err := tx.Model(&version).Updates(map[string]interface{}{
+			"review_status":  status,
+			"review_comment": comment,
+			"reviewer_id":    adminID,
+			"reviewed_at":    now,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update version: %w", err)
+		}
+		version.ReviewStatus, version.ReviewComment, version.ReviewedAt = status, comment, &now
+
+		if approve {
+			if err := releaseVersion(tx, &note, version.Version); err != nil {
+				return err
+			}
+		}
+		return recordReviewDecision(tx, models.AuditNoteVersion, version.ID, adminID,
+			action, models.ReviewSubmitted, status, comment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// versionReviews selects note versions with their notes and authors
+func (s *PublishedNoteService) versionReviews(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).Table("published_note_versions v").
+		Select(`v.id, v.published_note_id, v.version, v.title, v.description, v.difficulty_level, v.changelog,
+			v.overlay_count, v.review_status, v.review_comment, v.reviewed_at, v.created_at AS submitted_at,
+			pn.author_id, COALESCE(NULLIF(u.full_name, ''), u.username) AS author_name, u.verified_scholar AS author_verified,
+			b.title AS book_title, b.author AS book_author, pn.price_cents, pn.downloads_count, pn.version AS live_version`).
+		Joins("JOIN published_notes pn ON pn.id = v.published_note_id AND pn.deleted_at IS NULL").
+		Joins("JOIN users u ON u.id = pn.author_id").
+		Joins("JOIN books b ON b.id = pn.book_id").
+		Where("v.deleted_at IS NULL")
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// Payment event types reported by providers' webhooks
+const (
+	PaymentSucceeded = "payment.succeeded"
+	PaymentFailed    = "payment.failed"
+	RefundSucceeded  = "refund.succeeded"
+)
+
+// PaymentIntentRequest asks a provider to collect payment for an order
+type PaymentIntentRequest struct {
+	OrderID     uuid.UUID
+	AmountCents int
+	Currency    string
+	Description string
+}
+
+// PaymentIntent is a payment a provider is ready to collect. The buyer's
+// client completes it with ClientSecret or at CheckoutURL.
+type PaymentIntent struct {
+	ProviderRef  string
+	ClientSecret string
+	CheckoutURL  string
+}
+
+// PaymentEventPayload is a verified webhook event
+type PaymentEventPayload struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	ProviderRef string `json:"provider_ref"`
+	AmountCents int    `json:"amount_cents"`
+	Currency    string `json:"currency"`
+	Reason      string `json:"reason,omitempty"` // Why a payment failed
+}
+
+// PaymentProvider collects payments for orders and refunds them. Providers
+// report payment outcomes asynchronously through webhooks.
+type PaymentProvider interface {
+	Name() string
+	CreateIntent(ctx context.Context, req PaymentIntentRequest) (*PaymentIntent, error)
+	Refund(ctx context.Context, providerRef string, amountCents int) (string, error)
+	ParseWebhook(payload []byte, header http.Header) (*PaymentEventPayload, error)
+}
+
+// FakePaymentSignatureHeader carries the fake provider's webhook signature
+const FakePaymentSignatureHeader = "X-Fake-Signature"
+
+// FakePaymentProvider is a local payment provider for development and
+// tests. It collects nothing: payments are completed by posting events it
+// signs to the webhook, and refunds always succeed.
+type FakePaymentProvider struct {
+	secret []byte
+}
+
+// NewFakePaymentProvider creates a fake provider signing webhooks with
+// secret, or with a random secret if it is empty, so that only events it
+// signs itself are accepted
+func NewFakePaymentProvider(secret string) *FakePaymentProvider {
+	if secret == "" {
+		secret = randomHex(32)
+	}
+	return &FakePaymentProvider{secret: []byte(secret)}
+}
+
+// Name returns the provider's name
+func (p *FakePaymentProvider) Name() string {
+	return "fake"
+}
+
+// CreateIntent returns a new fake payment
+func (p *FakePaymentProvider) CreateIntent(ctx context.Context, req PaymentIntentRequest) (*PaymentIntent, error) {
+	if req.AmountCents <= 0 {
+		return nil, fmt.Errorf("%w amount %d", ErrInvalid, req.AmountCents)
+	}
+	ref := "fake_pi_" + randomHex(12)
+	return &PaymentIntent{ProviderRef: ref, ClientSecret: ref + "_secret_" + randomHex(8)}, nil
+}
+
+// Refund refunds a fake payment
+func (p *FakePaymentProvider) Refund(ctx context.Context, providerRef string, amountCents int) (string, error) {
+	if providerRef == "" {
+		return "", fmt.Errorf("%w payment reference", ErrInvalid)
+	}
+	return "fake_re_" + randomHex(12), nil
+}
+
+// ParseWebhook verifies an event's signature and decodes it
+func (p *FakePaymentProvider) ParseWebhook(payload []byte, header http.Header) (*PaymentEventPayload, error) {
+	signature, err := hex.DecodeString(header.Get(FakePaymentSignatureHeader))
+	if err != nil || !hmac.Equal(signature, p.sign(payload)) {
+		return nil, fmt.Errorf("%w webhook signature", ErrInvalid)
+	}
+	var event PaymentEventPayload
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("%w webhook payload: %w", ErrInvalid, err)
+	}
+	if event.ID == "" || event.Type == "" || event.ProviderRef == "" {
+		return nil, fmt.Errorf("%w webhook payload: id, type and provider_ref are required", ErrInvalid)
+	}
+	return &event, nil
+}
+
+// SignedEvent encodes and signs an event as the fake provider's webhook
+// would deliver it
+func (p *FakePaymentProvider) SignedEvent(event PaymentEventPayload) ([]byte, http.Header, error) {
+	if event.ID == "" {
+		event.ID = "fake_evt_" + randomHex(12)
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := make(http.Header)
+	header.Set(FakePaymentSignatureHeader, hex.EncodeToString(p.sign(payload)))
+	return payload, header, nil
+}
+
+func (p *FakePaymentProvider) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// randomHex returns n random bytes in hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,343 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/classius/server/internal/models"
+)
+
+// Popular highlight defaults
+const (
+	DefaultPopularMinReaders = 5  // Readers needed before a passage is shown
+	minPopularRunes          = 10 // Shorter passages are not worth showing
+	maxPopularQuoteRunes     = MaxQuoteRunes
+)
+
+// PopularHighlightService aggregates the public highlights of readers who
+// share their annotations into the passages of each text that resonate
+// most. A passage is only shown once enough readers highlighted all of it
+// that none of them can be singled out.
+type PopularHighlightService struct {
+	db         *gorm.DB
+	minReaders int
+}
+
+// NewPopularHighlightService creates a new popular highlight service,
+// showing passages highlighted by at least minReaders readers
+func NewPopularHighlightService(db *gorm.DB, minReaders int) *PopularHighlightService {
+	if minReaders < 2 {
+		minReaders = DefaultPopularMinReaders
+	}
+	return &PopularHighlightService{db: db, minReaders: minReaders}
+}
+
+// PopularHighlightView is a popular passage placed in the viewer's copy
+type PopularHighlightView struct {
+	StartPosition int       `json:"start_position"`
+	EndPosition   int       `json:"end_position"`
+	Quote         string    `json:"quote"`
+	ReaderCount   int       `json:"reader_count"`
+	Popularity    float64   `json:"popularity"` // Share of the text's readers who highlighted it
+	ComputedAt    time.Time `json:"computed_at"`
+}
+
+// highlightSpan is one reader's highlight in a reference text
+type highlightSpan struct {
+	UserID     uuid.UUID
+	Start, End int
+}
+
+// popularSpan is a passage every part of which at least the threshold of
+// readers highlighted, with the most readers of any part
+type popularSpan struct {
+	Start, End int
+	Readers    int
+}
+
+// privacyOptIn is the condition that the user in userColumn turned a
+// privacy setting on. Privacy settings are stored with the user's
+// preferences and are off unless set.
+func privacyOptIn(userColumn, setting string) string {
+	return fmt.Sprintf(`EXISTS (SELECT 1 FROM user_preferences up WHERE up.user_id = %s AND up.deleted_at IS NULL
+		AND up.settings->'privacy_settings'->'%s' = 'true'::jsonb)`, userColumn, setting)
+}
+
+// publicHighlightSQL selects the highlights that may be aggregated: public
+// ones by readers whose annotations are public
+var publicHighlightSQL = `a.type = 'highlight' AND a.visibility = 'public' AND a.deleted_at IS NULL AND
+	b.deleted_at IS NULL AND b.text_fingerprint <> '' AND ` + privacyOptIn("a.user_id", "annotations_public")
+
+// Aggregate recomputes the popular highlights of every text, returning how
+// many passages were found
+func (s *PopularHighlightService) Aggregate(ctx context.Context) (int, error) {
+	db := s.db.WithContext(ctx)
+	started := time.Now()
+
+	var fingerprints []string
+	if err := db.Table("annotations a").
+		Joins("JOIN books b ON b.id = a.book_id").
+		Where(publicHighlightSQL).
+		Group("b.text_fingerprint").
+		Having("COUNT(DISTINCT a.user_id) >= ?", s.minReaders).
+		Pluck("b.text_fingerprint", &fingerprints).Error; err != nil {
+		return 0, fmt.Errorf("failed to find popular texts: %w", err)
+	}
+
+	found := 0
+	for _, fingerprint := range fingerprints {
+		if err := ctx.Err(); err != nil {
+			return found, err
+		}
+		highlights, err := s.aggregateText(ctx, fingerprint, started)
+		if err != nil {
+			return found, err
+		}
+		found += highlights
+	}
+
+	// Texts no longer reaching the threshold lose their passages
+	if err := db.Unscoped().Where("computed_at < ?", started).Delete(&models.PopularHighlight{}).Error; err != nil {
+		return found, fmt.Errorf("failed to remove stale popular highlights: %w", err)
+	}
+	return found, nil
+}
+
+// AggregateEvery runs Aggregate at each interval until ctx is done. A zero
+// interval turns aggregation off.
+func (s *PopularHighlightService) AggregateEvery(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Aggregate(ctx); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		}
+	}
+}
+
+// aggregateText replaces a text's popular highlights. Highlights are
+// placed in the copy holding most of them, by quote for the others.
+func (s *PopularHighlightService) aggregateText(ctx context.Context, fingerprint string, computedAt time.Time) (int, error) {
+	db := s.db.WithContext(ctx)
+
+	var highlights []struct {
+		UserID        uuid.UUID
+		BookID        uuid.UUID
+		StartPosition int
+		EndPosition   int
+		SelectedText  string
+	}
+	if err := db.Table("annotations a").
+		Select("a.user_id, a.book_id, a.start_position, a.end_position, a.selected_text").
+		Joins("JOIN books b ON b.id = a.book_id").
+		Where(publicHighlightSQL).
+		Where("b.text_fingerprint = ?", fingerprint).
+		Scan(&highlights).Error; err != nil {
+		return 0, fmt.Errorf("failed to retrieve highlights: %w", err)
+	}
+
+	perBook := map[uuid.UUID]int{}
+	var books []uuid.UUID
+	for _, highlight := range highlights {
+		if perBook[highlight.BookID] == 0 {
+			books = append(books, highlight.BookID)
+		}
+		perBook[highlight.BookID]++
+	}
+	sort.Slice(books, func(i, j int) bool {
+		if perBook[books[i]] != perBook[books[j]] {
+			return perBook[books[i]] > perBook[books[j]]
+		}
+		return books[i].String() < books[j].String()
+	})
+	var reference uuid.UUID
+	var locator *QuoteLocator
+	for _, bookID := range books {
+		var err error
+		if locator, err = bookLocator(db, bookID); err != nil {
+			return 0, err
+		}
+		if locator != nil {
+			reference = bookID
+			break
+		}
+	}
+	if locator == nil {
+		return 0, nil
+	}
+
+	var spans []highlightSpan
+	for _, highlight := range highlights {
+		start, end, ok := highlight.StartPosition, highlight.EndPosition, highlight.BookID == reference
+		if !ok {
+			start, end, ok = anchorQuote(locator, start, end, highlight.SelectedText, "", "", false)
+		}
+		if ok && start >= 0 && end <= len(locator.Text()) && end > start {
+			spans = append(spans, highlightSpan{UserID: highlight.UserID, Start: start, End: end})
+		}
+	}
+
+	var textReaders int64
+	if err := db.Model(&models.Book{}).
+		Where("text_fingerprint = ?", fingerprint).
+		Distinct("user_id").Count(&textReaders).Error; err != nil {
+		return 0, fmt.Errorf("failed to count readers: %w", err)
+	}
+
+	passages := popularSpans(spans, s.minReaders, minPopularRunes)
+	rows := make([]models.PopularHighlight, 0, len(passages))
+	for _, passage := range passages {
+		end := passage.End
+		if end-passage.Start > maxPopularQuoteRunes {
+			end = passage.Start + maxPopularQuoteRunes
+		}
+		prefix, suffix := locator.Context(passage.Start, end, overlayContextRunes)
+		rows = append(rows, models.PopularHighlight{
+			TextFingerprint: fingerprint,
+			ReferenceBookID: reference,
+			StartPosition:   passage.Start,
+			EndPosition:     end,
+			Quote:           string(locator.Text()[passage.Start:end]),
+			QuotePrefix:     prefix,
+			QuoteSuffix:     suffix,
+			ReaderCount:     passage.Readers,
+			TextReaders:     int(textReaders),
+			ComputedAt:      computedAt,
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("text_fingerprint = ?", fingerprint).Delete(&models.PopularHighlight{}).Error; err != nil {
+			return fmt.Errorf("failed to replace popular highlights: %w", err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		if err := tx.CreateInBatches(rows, 200).Error; err != nil {
+			return fmt.Errorf("failed to save popular highlights: %w", err)
+		}
+		return nil
+	})
+	return len(rows), err
+}
+
+// BookHighlights returns the popular passages of one of the viewer's books,
+// placed in their copy, most popular first. With a range, only passages
+// overlapping it are returned.
+func (s *PopularHighlightService) BookHighlights(ctx context.Context, viewerID, bookID uuid.UUID, within *TextRange, limit int) ([]PopularHighlightView, error) {
+	db := s.db.WithContext(ctx)
+	book, err := ownBook(db, viewerID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := bookTextFingerprint(db, book)
+	if err != nil {
+		return nil, err
+	}
+	views := []PopularHighlightView{}
+	if fingerprint == "" {
+		return views, nil
+	}
+
+	var rows []models.PopularHighlight
+	if err := db.Where("text_fingerprint = ? AND reader_count >= ?", fingerprint, s.minReaders).
+		Order("reader_count DESC, start_position").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve popular highlights: %w", err)
+	}
+	if len(rows) == 0 {
+		return views, nil
+	}
+	locator, err := bookLocator(db, book.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		start, end, ok := row.StartPosition, row.EndPosition, row.ReferenceBookID == book.ID
+		if !ok {
+			start, end, ok = anchorQuote(locator, start, end, row.Quote, row.QuotePrefix, row.QuoteSuffix, false)
+		}
+		if !ok || !inTextRange(within, start, end) {
+			continue
+		}
+		view := PopularHighlightView{
+			StartPosition: start,
+			EndPosition:   end,
+			Quote:         row.Quote,
+			ReaderCount:   row.ReaderCount,
+			ComputedAt:    row.ComputedAt,
+		}
+		if row.TextReaders > 0 {
+			view.Popularity = float64(row.ReaderCount) / float64(row.TextReaders)
+		}
+		views = append(views, view)
+		if len(views) == limit {
+			break
+		}
+	}
+	return views, nil
+}
+
+// popularSpans clusters overlapping highlights into the passages that at
+// least minReaders distinct readers highlighted throughout. Each reader
+// counts once however often they highlighted a part.
+func popularSpans(spans []highlightSpan, minReaders, minLength int) []popularSpan {
+	byUser := map[uuid.UUID][]highlightSpan{}
+	for _, span := range spans {
+		byUser[span.UserID] = append(byUser[span.UserID], span)
+	}
+
+	// Merge each reader's overlapping highlights, so coverage counts readers
+	type event struct{ pos, delta int }
+	var events []event
+	for _, own := range byUser {
+		sort.Slice(own, func(i, j int) bool { return own[i].Start < own[j].Start })
+		start, end := own[0].Start, own[0].End
+		for _, span := range own[1:] {
+			if span.Start <= end {
+				end = max(end, span.End)
+				continue
+			}
+			events = append(events, event{start, 1}, event{end, -1})
+			start, end = span.Start, span.End
+		}
+		events = append(events, event{start, 1}, event{end, -1})
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].pos < events[j].pos })
+
+	var passages []popularSpan
+	var current *popularSpan
+	readers := 0
+	for i := 0; i < len(events); {
+		pos := events[i].pos
+		for ; i < len(events) && events[i].pos == pos; i++ {
+			readers += events[i].delta
+		}
+		switch {
+		case readers >= minReaders && current == nil:
+			current = &popularSpan{Start: pos, Readers: readers}
+		case readers >= minReaders:
+			current.Readers = max(current.Readers, readers)
+		case current != nil:
+			current.End = pos
+			if current.End-current.Start >= minLength {
+				passages = append(passages, *current)
+			}
+			current = nil
+		}
+	}
+	return passages
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPopularSpans(t *testing.T) {
+	a, b, c, d := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	spans := []highlightSpan{
+		{a, 100, 200},
+		{b, 120, 220},
+		{c, 150, 180},
+		{c, 170, 260}, // Overlaps c's own highlight, so c still counts once
+		{d, 400, 500},
+	}
+
+	got := popularSpans(spans, 3, 10)
+	want := []popularSpan{{Start: 150, End: 200, Readers: 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("popularSpans(k=3) = %+v, want %+v", got, want)
+	}
+
+	got = popularSpans(spans, 2, 10)
+	want = []popularSpan{{Start: 120, End: 220, Readers: 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("popularSpans(k=2) = %+v, want %+v", got, want)
+	}
+
+	// One reader highlighting a passage many times is still one reader
+	repeated := []highlightSpan{{a, 0, 50}, {a, 0, 50}, {a, 10, 40}}
+	if got := popularSpans(repeated, 2, 10); len(got) != 0 {
+		t.Errorf("a single reader made %+v popular", got)
+	}
+
+	// Passages shorter than the minimum are dropped
+	if got := popularSpans(spans, 3, 60); len(got) != 0 {
+		t.Errorf("short passages kept: %+v", got)
+	}
+}
+
+func TestPrivacyOptIn(t *testing.T) {
+	condition := privacyOptIn("a.user_id", "annotations_public")
+	if !strings.Contains(condition, "up.user_id = a.user_id") ||
+		!strings.Contains(condition, "'annotations_public' = 'true'::jsonb") {
+		t.Errorf("condition = %q", condition)
+	}
+}
@@ -0,0 +1,846 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/classius/server/internal/models"
+)
+
+// MaxPublishedNoteItems bounds the annotations in one published note
+const MaxPublishedNoteItems = 5000
+
+// overlayContextRunes is how much text around a quote overlays keep for
+// re-anchoring in readers' copies
+const overlayContextRunes = 32
+
+// PublishedNoteService manages collections of annotations that authors
+// publish as overlays, and readers' subscriptions to them
+type PublishedNoteService struct {
+	db              *gorm.DB
+	reviewThreshold int
+}
+
+// NewPublishedNoteService creates a new published note service. New
+// versions of paid notes, and of notes with at least reviewThreshold
+// downloads (0 for none), wait for an admin's approval.
+func NewPublishedNoteService(db *gorm.DB, reviewThreshold int) *PublishedNoteService {
+	return &PublishedNoteService{db: db, reviewThreshold: reviewThreshold}
+}
+
+// CreatePublishedNoteRequest starts a draft collection for one of the
+// author's books
+type CreatePublishedNoteRequest struct {
+	BookID          uuid.UUID                `json:"book_id" binding:"required"`
+	Title           string                   `json:"title" binding:"required,max=255"`
+	Description     string                   `json:"description"`
+	DifficultyLevel string                   `json:"difficulty_level"` // beginner, intermediate (default), advanced, expert
+	PriceCents      int                      `json:"price_cents"`      // 0 for free
+	Items           []PublishedNoteItemInput `json:"items"`
+}
+
+// UpdatePublishedNoteRequest changes a note's listing. It applies to the
+// catalog at once and to the next published version.
+type UpdatePublishedNoteRequest struct {
+	Title           *string `json:"title" binding:"omitempty,max=255"`
+	Description     *string `json:"description"`
+	DifficultyLevel *string `json:"difficulty_level"`
+	PriceCents      *int    `json:"price_cents"` // Applies to new purchases only
+}
+
+// PublishedNoteItemInput is an annotation to include in a note
+type PublishedNoteItemInput struct {
+	AnnotationID uuid.UUID `json:"annotation_id" binding:"required"`
+	NoteType     string    `json:"note_type"` // explanation (default), context, cross_reference, translation, historical_note
+}
+
+// PublishedNoteFilter selects notes from the catalog
+type PublishedNoteFilter struct {
+	ViewerID   uuid.UUID
+	BookID     *uuid.UUID // One of the viewer's books, matched by ISBN or text
+	Query      string
+	Difficulty string
+	Sort       string // rating (default), downloads, recent
+	Limit      int
+	Offset     int
+}
+
+// PublishedNoteSummary describes a published note in listings
+type PublishedNoteSummary struct {
+	ID              uuid.UUID  `json:"id"`
+	AuthorID        uuid.UUID  `json:"author_id"`
+	AuthorName      string     `json:"author_name"`
+	BookID          uuid.UUID  `json:"book_id"`
+	BookTitle       string     `json:"book_title"`
+	BookAuthor      string     `json:"book_author"`
+	BookISBN        string     `json:"book_isbn,omitempty"`
+	BookFingerprint string     `json:"-"`
+	Title           string     `json:"title"`
+	Description     string     `json:"description"`
+	DifficultyLevel string     `json:"difficulty_level"`
+	Status          string     `json:"status"`
+	Version         int        `json:"version"`
+	PublishedAt     *time.Time `json:"published_at,omitempty"`
+	PriceCents      int        `json:"price_cents"`
+	Entitled        bool       `json:"entitled"` // Free, the viewer's own, or bought
+	IsVerified      bool       `json:"is_verified"`
+	Rating          float64    `json:"rating"`
+	RatingsCount    int        `json:"ratings_count"`
+	DownloadsCount  int        `json:"downloads_count"`
+	Subscribed      bool       `json:"subscribed"`
+	PinnedVersion   *int       `json:"pinned_version,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// PublishedNoteDetail is a note with its versions, and for its author the
+// draft's items
+type PublishedNoteDetail struct {
+	PublishedNoteSummary
+	Versions []models.PublishedNoteVersion `json:"versions"`
+	Items    []models.PublishedNoteItem    `json:"items,omitempty"`
+}
+
+// NoteReview is a rating with its reviewer
+type NoteReview struct {
+	ID           uuid.UUID `json:"id"`
+	Rating       int       `json:"rating"`
+	Review       string    `json:"review,omitempty"`
+	Version      int       `json:"version"`
+	ReviewerName string    `json:"reviewer_name"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Create starts a draft from the author's annotations on one of their books
+func (s *PublishedNoteService) Create(ctx context.Context, authorID uuid.UUID, req CreatePublishedNoteRequest) (*PublishedNoteDetail, error) {
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return nil, fmt.Errorf("%w: title is required", ErrInvalid)
+	}
+	difficulty := req.DifficultyLevel
+	if difficulty == "" {
+		difficulty = "intermediate"
+	}
+	if !models.IsDifficultyLevel(difficulty) {
+		return nil, fmt.Errorf("%w difficulty level %q", ErrInvalid, difficulty)
+	}
+	if err := validNotePrice(req.PriceCents); err != nil {
+		return nil, err
+	}
+
+	db := s.db.WithContext(ctx)
+	var count int64
+	if err := db.Model(&models.Book{}).Where("id = ? AND user_id = ?", req.BookID, authorID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve book: %w", err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("book %w", ErrNotFound)
+	}
+	var author models.User
+	if err := db.Select("verified_scholar").Where("id = ?", authorID).First(&author).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve author: %w", err)
+	}
+
+	note := &models.PublishedNote{
+		AuthorID:        authorID,
+		BookID:          req.BookID,
+		Title:           title,
+		Description:     strings.TrimSpace(req.Description),
+		DifficultyLevel: difficulty,
+		PriceCents:      req.PriceCents,
+		IsVerified:      author.VerifiedScholar,
+		Status:          models.NoteDraft,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(note).Error; err != nil {
+			return fmt.Errorf("failed to create published note: %w", err)
+		}
+		_, err := setNoteItems(tx, note, req.Items)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, authorID, note.ID)
+}
+
+// Update changes a note's title, description or difficulty
+func (s *PublishedNoteService) Update(ctx context.Context, authorID, noteID uuid.UUID, req UpdatePublishedNoteRequest) (*PublishedNoteDetail, error) {
+	db := s.db.WithContext(ctx)
+	if _, err := authoredNote(db, authorID, noteID); err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			return nil, fmt.Errorf("%w: title is required", ErrInvalid)
+		}
+		updates["title"] = title
+	}
+	if req.Description != nil {
+		updates["description"] = strings.TrimSpace(*req.Description)
+	}
+	if req.DifficultyLevel != nil {
+		if !models.IsDifficultyLevel(*req.DifficultyLevel) {
+			return nil, fmt.Errorf("%w difficulty level %q", ErrInvalid, *req.DifficultyLevel)
+		}
+		updates["difficulty_level"] = *req.DifficultyLevel
+	}
+	if req.PriceCents != nil {
+		if err := validNotePrice(*req.PriceCents); err != nil {
+			return nil, err
+		}
+		updates["price_cents"] = *req.PriceCents
+	}
+	if len(updates) > 0 {
+		if err := db.Model(&models.PublishedNote{}).Where("id = ?", noteID).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("failed to update published note: %w", err)
+		}
+	}
+	return s.Get(ctx, authorID, noteID)
+}
+
+// SetItems replaces the annotations in a note's draft, in display order
+func (s *PublishedNoteService) SetItems(ctx context.Context, authorID, noteID uuid.UUID, items []PublishedNoteItemInput) ([]models.PublishedNoteItem, error) {
+	var saved []models.PublishedNoteItem
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		note, err := authoredNote(tx, authorID, noteID)
+		if err != nil {
+			return err
+		}
+		saved, err = setNoteItems(tx, note, items)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// setNoteItems replaces a note's items with the author's annotations on
+// its book
+func setNoteItems(tx *gorm.DB, note *models.PublishedNote, inputs []PublishedNoteItemInput) ([]models.PublishedNoteItem, error) {
+	if len(inputs) > MaxPublishedNoteItems {
+		return nil, fmt.Errorf("%w items: a published note holds at most %d annotations", ErrInvalid, MaxPublishedNoteItems)
+	}
+
+	items := make([]models.PublishedNoteItem, 0, len(inputs))
+	ids := make([]uuid.UUID, 0, len(inputs))
+	seen := make(map[uuid.UUID]bool)
+	for _, input := range inputs {
+		if seen[input.AnnotationID] {
+			continue
+		}
+		seen[input.AnnotationID] = true
+		noteType := input.NoteType
+		if noteType == "" {
+			noteType = models.OverlayExplanation
+		}
+		if !models.IsOverlayType(noteType) {
+			return nil, fmt.Errorf("%w note type %q", ErrInvalid, noteType)
+		}
+		items = append(items, models.PublishedNoteItem{
+			PublishedNoteID: note.ID,
+			AnnotationID:    input.AnnotationID,
+			NoteType:        noteType,
+			DisplayOrder:    len(items),
+		})
+		ids = append(ids, input.AnnotationID)
+	}
+
+	if len(ids) > 0 {
+		var count int64
+		if err := tx.Model(&models.Annotation{}).
+			Where("id IN ? AND user_id = ? AND book_id = ?", ids, note.AuthorID, note.BookID).
+			Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to retrieve annotations: %w", err)
+		}
+		if int(count) != len(ids) {
+			return nil, fmt.Errorf("annotation %w: items must be your own annotations on the note's book", ErrNotFound)
+		}
+	}
+
+	if err := tx.Unscoped().Where("published_note_id = ?", note.ID).Delete(&models.PublishedNoteItem{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to save items: %w", err)
+	}
+	if len(items) > 0 {
+		if err := tx.CreateInBatches(&items, 500).Error; err != nil {
+			return nil, fmt.Errorf("failed to save items: %w", err)
+		}
+	}
+	return items, nil
+}
+
+// Publish snapshots the draft's annotations as the note's next version.
+// Versions are never changed; readers following the note see the newest.
+// Versions of notes that need review are submitted instead, and go live
+// once approved.
+func (s *PublishedNoteService) Publish(ctx context.Context, authorID, noteID uuid.UUID, changelog string) (*models.PublishedNoteVersion, error) {
+	var version *models.PublishedNoteVersion
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var note models.PublishedNote
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND author_id = ?", noteID, authorID).Limit(1).Find(&note)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve published note: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("published note %w", ErrNotFound)
+		}
+		var latest struct {
+			Version   int
+			Submitted int
+		}
+		if err := tx.Model(&models.PublishedNoteVersion{}).Where("published_note_id = ?", note.ID).
+			Select("COALESCE(MAX(version), 0) AS version, COALESCE(MAX(CASE WHEN review_status = ? THEN version END), 0) AS submitted", models.ReviewSubmitted).
+			Scan(&latest).Error; err != nil {
+			return fmt.Errorf("failed to retrieve versions: %w", err)
+		}
+		if latest.Submitted > 0 {
+			return fmt.Errorf("%w: version %d is awaiting review", ErrConflict, latest.Submitted)
+		}
+
+		var items []models.PublishedNoteItem
+		if err := tx.Where("published_note_id = ?", note.ID).Order("display_order").Find(&items).Error; err != nil {
+			return fmt.Errorf("failed to retrieve items: %w", err)
+		}
+		ids := make([]uuid.UUID, len(items))
+		for i, item := range items {
+			ids[i] = item.AnnotationID
+		}
+		var annotations []models.Annotation
+		if len(ids) > 0 {
+			if err := tx.Where("id IN ? AND user_id = ?", ids, authorID).Find(&annotations).Error; err != nil {
+				return fmt.Errorf("failed to retrieve annotations: %w", err)
+			}
+		}
+		byID := make(map[uuid.UUID]*models.Annotation, len(annotations))
+		for i := range annotations {
+			byID[annotations[i].ID] = &annotations[i]
+		}
+
+		locator, err := bookLocator(tx, note.BookID)
+		if err != nil {
+			return err
+		}
+
+		// Rejected versions keep their numbers
+		next := latest.Version + 1
+		overlays := make([]models.NoteOverlay, 0, len(items))
+		for _, item := range items {
+			a, ok := byID[item.AnnotationID] // Annotations deleted since they were added are left out
+			if !ok {
+				continue
+			}
+			overlays = append(overlays, overlayFromAnnotation(a, item, note.ID, next, len(overlays), locator))
+		}
+		if len(overlays) == 0 {
+			return fmt.Errorf("%w: at least one annotation is required to publish", ErrInvalid)
+		}
+		if err := tx.CreateInBatches(&overlays, 500).Error; err != nil {
+			return fmt.Errorf("failed to publish overlays: %w", err)
+		}
+
+		version = &models.PublishedNoteVersion{
+			PublishedNoteID: note.ID,
+			Version:         next,
+			Title:           note.Title,
+			Description:     note.Description,
+			DifficultyLevel: note.DifficultyLevel,
+			Changelog:       strings.TrimSpace(changelog),
+			OverlayCount:    len(overlays),
+			ReviewStatus:    models.ReviewApproved,
+		}
+		if s.needsReview(&note) {
+			version.ReviewStatus = models.ReviewSubmitted
+		}
+		if err := tx.Create(version).Error; err != nil {
+			return fmt.Errorf("failed to publish version: %w", err)
+		}
+
+		if version.ReviewStatus == models.ReviewSubmitted {
+			return recordReviewDecision(tx, models.AuditNoteVersion, version.ID, authorID,
+				"submitted", "", models.ReviewSubmitted, version.Changelog)
+		}
+		return releaseVersion(tx, &note, next)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// needsReview reports whether a note's new versions must be approved: it
+// is sold, or popular enough to reach many readers
+func (s *PublishedNoteService) needsReview(note *models.PublishedNote) bool {
+	return note.PriceCents > 0 || (s.reviewThreshold > 0 && note.DownloadsCount >= s.reviewThreshold)
+}
+
+// releaseVersion makes a version the note's latest and lists the note,
+// recording what identifies the author's copy of the book at the time
+func releaseVersion(tx *gorm.DB, note *models.PublishedNote, version int) error {
+	var book models.Book
+	if err := tx.Where("id = ?", note.BookID).First(&book).Error; err != nil {
+		return fmt.Errorf("failed to retrieve book: %w", err)
+	}
+	fingerprint, err := bookTextFingerprint(tx, &book)
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(note).Updates(map[string]interface{}{
+		"version":          version,
+		"status":           models.NotePublished,
+		"published_at":     time.Now(),
+		"book_isbn":        normalizeISBN(book.ISBN),
+		"book_fingerprint": fingerprint,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to publish note: %w", err)
+	}
+	noteID, bookID := note.ID, note.BookID
+	return RecordActivity(tx, &models.ActivityEvent{UserID: note.AuthorID, Type: models.ActivityPublishedNote,
+		Key: fmt.Sprintf("published_note:%s:%d", noteID, version), BookID: &bookID, SubjectID: &noteID, Summary: note.Title})
+}
+
+// overlayFromAnnotation snapshots an annotation as an overlay, keeping its
+// quote and the text around it when the author's copy has been extracted
+func overlayFromAnnotation(a *models.Annotation, item models.PublishedNoteItem, noteID uuid.UUID, version, order int, locator *QuoteLocator) models.NoteOverlay {
+	annotationID := a.ID
+	overlay := models.NoteOverlay{
+		PublishedNoteID: noteID,
+		Version:         version,
+		AnnotationID:    &annotationID,
+		PageNumber:      a.PageNumber,
+		StartPosition:   a.StartPosition,
+		EndPosition:     a.EndPosition,
+		NoteType:        item.NoteType,
+		Content:         a.Content,
+		Quote:           a.SelectedText,
+		DisplayOrder:    order,
+	}
+	if locator != nil && overlay.Quote != "" && locator.Matches(a.StartPosition, a.EndPosition, a.SelectedText) {
+		overlay.QuotePrefix, overlay.QuoteSuffix = locator.Context(a.StartPosition, a.EndPosition, overlayContextRunes)
+	}
+	return overlay
+}
+
+// Withdraw removes a published note from the catalog. Subscribers keep the
+// versions already published; publishing again lists it again.
+func (s *PublishedNoteService) Withdraw(ctx context.Context, authorID, noteID uuid.UUID) error {
+	db := s.db.WithContext(ctx)
+	note, err := authoredNote(db, authorID, noteID)
+	if err != nil {
+		return err
+	}
+	if note.Status != models.NotePublished {
+		return fmt.Errorf("%w status: only published notes can be withdrawn", ErrInvalid)
+	}
+	if err := db.Model(note).Update("status", models.NoteWithdrawn).Error; err != nil {
+		return fmt.Errorf("failed to withdraw published note: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes a note, ending its subscriptions
+func (s *PublishedNoteService) Delete(ctx context.Context, authorID, noteID uuid.UUID) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND author_id = ?", noteID, authorID).Delete(&models.PublishedNote{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete published note: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("published note %w", ErrNotFound)
+	}
+	return nil
+}
+
+// Mine lists the notes the user has authored, drafts included
+func (s *PublishedNoteService) Mine(ctx context.Context, authorID uuid.UUID) ([]PublishedNoteSummary, error) {
+	var notes []PublishedNoteSummary
+	if err := s.summaries(ctx, authorID).Where("pn.author_id = ?", authorID).
+		Order("pn.updated_at DESC").Scan(&notes).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve published notes: %w", err)
+	}
+	return notes, nil
+}
+
+// Catalog lists published notes
+func (s *PublishedNoteService) Catalog(ctx context.Context, filter PublishedNoteFilter) ([]PublishedNoteSummary, int64, error) {
+	query := s.summaries(ctx, filter.ViewerID).Where("pn.status = ?", models.NotePublished)
+	if filter.BookID != nil {
+		var book models.Book
+		result := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", *filter.BookID, filter.ViewerID).Limit(1).Find(&book)
+		if result.Error != nil {
+			return nil, 0, fmt.Errorf("failed to retrieve book: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil, 0, fmt.Errorf("book %w", ErrNotFound)
+		}
+		match, args, err := s.bookMatch(ctx, &book)
+		if err != nil {
+			return nil, 0, err
+		}
+		if match == "" {
+			return []PublishedNoteSummary{}, 0, nil
+		}
+		query = query.Where(match, args...)
+	}
+	if filter.Difficulty != "" {
+		query = query.Where("pn.difficulty_level = ?", filter.Difficulty)
+	}
+	if filter.Query != "" {
+		term := "%" + strings.ToLower(filter.Query) + "%"
+		query = query.Where("(LOWER(pn.title) LIKE ? OR LOWER(pn.description) LIKE ? OR LOWER(b.title) LIKE ? OR LOWER(b.author) LIKE ?)",
+			term, term, term, term)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count published notes: %w", err)
+	}
+
+	switch filter.Sort {
+	case "downloads":
+		query = query.Order("pn.downloads_count DESC")
+	case "recent":
+		query = query.Order("pn.published_at DESC")
+	default:
+		query = query.Order("pn.rating DESC, pn.ratings_count DESC")
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit).Offset(filter.Offset)
+	}
+	var notes []PublishedNoteSummary
+	if err := query.Order("pn.id").Scan(&notes).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve published notes: %w", err)
+	}
+	return notes, total, nil
+}
+
+// bookMatch builds the condition matching notes published for another copy
+// of book, by ISBN or text fingerprint. It is empty when the book has
+// neither.
+func (s *PublishedNoteService) bookMatch(ctx context.Context, book *models.Book) (string, []interface{}, error) {
+	fingerprint, err := bookTextFingerprint(s.db.WithContext(ctx), book)
+	if err != nil {
+		return "", nil, err
+	}
+	var conditions []string
+	var args []interface{}
+	if isbn := normalizeISBN(book.ISBN); isbn != "" {
+		conditions = append(conditions, "pn.book_isbn = ?")
+		args = append(args, isbn)
+	}
+	if fingerprint != "" {
+		conditions = append(conditions, "pn.book_fingerprint = ?")
+		args = append(args, fingerprint)
+	}
+	if len(conditions) == 0 {
+		return "", nil, nil
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args, nil
+}
+
+// Get returns a note visible to the viewer: their own, a listed one, or one
+// they subscribe to
+func (s *PublishedNoteService) Get(ctx context.Context, viewerID, noteID uuid.UUID) (*PublishedNoteDetail, error) {
+	var summary PublishedNoteSummary
+	result := s.summaries(ctx, viewerID).Where("pn.id = ?", noteID).Limit(1).Scan(&summary)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve published note: %w", result.Error)
+	}
+	if result.RowsAffected == 0 || !noteVisible(&summary, viewerID) {
+		return nil, fmt.Errorf("published note %w", ErrNotFound)
+	}
+
+	db := s.db.WithContext(ctx)
+	detail := &PublishedNoteDetail{PublishedNoteSummary: summary, Versions: []models.PublishedNoteVersion{}}
+	versions := db.Where("published_note_id = ?", noteID)
+	if summary.AuthorID != viewerID { // Only authors see versions under review or rejected
+		versions = versions.Where("review_status = ?", models.ReviewApproved)
+	}
+	if err := versions.Order("version DESC").Find(&detail.Versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve versions: %w", err)
+	}
+	if summary.AuthorID == viewerID {
+		detail.Items = []models.PublishedNoteItem{}
+		if err := db.Where("published_note_id = ?", noteID).Order("display_order").Find(&detail.Items).Error; err != nil {
+			return nil, fmt.Errorf("failed to retrieve items: %w", err)
+		}
+	}
+	return detail, nil
+}
+
+// VersionOverlays returns the overlays of a published version
+func (s *PublishedNoteService) VersionOverlays(ctx context.Context, viewerID, noteID uuid.UUID, version int) ([]PublishedOverlay, error) {
+	note, err := s.Get(ctx, viewerID, noteID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersionVisible(s.db.WithContext(ctx), noteID, version, note.AuthorID == viewerID); err != nil {
+		return nil, err
+	}
+	if !note.Entitled {
+		return nil, errPaymentRequired
+	}
+	var overlays []models.NoteOverlay
+	if err := s.db.WithContext(ctx).Where("published_note_id = ? AND version = ?", noteID, version).
+		Order("display_order").Find(&overlays).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve overlays: %w", err)
+	}
+	views := make([]PublishedOverlay, len(overlays))
+	for i := range overlays {
+		views[i] = overlayView(&overlays[i], note.Title)
+	}
+	return views, nil
+}
+
+// Subscribe subscribes the user to a published note, following its latest
+// version or pinned to one. A reader's first subscription counts as a
+// download.
+func (s *PublishedNoteService) Subscribe(ctx context.Context, userID, noteID uuid.UUID, pinnedVersion *int) (*models.NoteSubscription, error) {
+	var subscription models.NoteSubscription
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var note models.PublishedNote
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", noteID).Limit(1).Find(&note)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve published note: %w", result.Error)
+		}
+		if result.RowsAffected == 0 || (note.Status != models.NotePublished && note.AuthorID != userID) {
+			return fmt.Errorf("published note %w", ErrNotFound)
+		}
+		if note.Version == 0 {
+			return fmt.Errorf("%w subscription: the note has not been published", ErrInvalid)
+		}
+		if pinnedVersion != nil {
+			if err := checkVersionVisible(tx, noteID, *pinnedVersion, false); err != nil {
+				return err
+			}
+		}
+		if err := checkNoteAccess(tx, &note, userID); err != nil {
+			return err
+		}
+
+		result = tx.Where("user_id = ? AND published_note_id = ?", userID, noteID).Limit(1).Find(&subscription)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve subscription: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			subscription.PinnedVersion = pinnedVersion
+			if err := tx.Model(&subscription).Update("pinned_version", pinnedVersion).Error; err != nil {
+				return fmt.Errorf("failed to update subscription: %w", err)
+			}
+			return nil
+		}
+
+		var before int64
+		if err := tx.Unscoped().Model(&models.NoteSubscription{}).
+			Where("user_id = ? AND published_note_id = ?", userID, noteID).Count(&before).Error; err != nil {
+			return fmt.Errorf("failed to retrieve subscription: %w", err)
+		}
+		subscription = models.NoteSubscription{UserID: userID, PublishedNoteID: noteID, PinnedVersion: pinnedVersion}
+		if err := tx.Create(&subscription).Error; err != nil {
+			return fmt.Errorf("failed to subscribe: %w", err)
+		}
+		if before == 0 && note.AuthorID != userID {
+			if err := tx.Model(&note).UpdateColumn("downloads_count", gorm.Expr("downloads_count + 1")).Error; err != nil {
+				return fmt.Errorf("failed to count download: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// errPaymentRequired locks paid notes' overlays from users without an
+// entitlement
+var errPaymentRequired = fmt.Errorf("%w: buy this note to access its overlays", ErrPaymentRequired)
+
+// checkVersionVisible checks that a note has a version readers can use:
+// approved, or for its author any version
+func checkVersionVisible(db *gorm.DB, noteID uuid.UUID, version int, author bool) error {
+	query := db.Model(&models.PublishedNoteVersion{}).Where("published_note_id = ? AND version = ?", noteID, version)
+	if !author {
+		query = query.Where("review_status = ?", models.ReviewApproved)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to retrieve version: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("version %w", ErrNotFound)
+	}
+	return nil
+}
+
+// checkNoteAccess checks the user may use a note's overlays: it is free,
+// their own, or they have bought it
+func checkNoteAccess(tx *gorm.DB, note *models.PublishedNote, userID uuid.UUID) error {
+	if note.PriceCents == 0 || note.AuthorID == userID {
+		return nil
+	}
+	entitled, err := hasEntitlement(tx, userID, note.ID)
+	if err != nil {
+		return err
+	}
+	if !entitled {
+		return errPaymentRequired
+	}
+	return nil
+}
+
+// Unsubscribe ends the user's subscription to a note
+func (s *PublishedNoteService) Unsubscribe(ctx context.Context, userID, noteID uuid.UUID) error {
+	result := s.db.WithContext(ctx).Where("user_id = ? AND published_note_id = ?", userID, noteID).Delete(&models.NoteSubscription{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to unsubscribe: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("subscription %w", ErrNotFound)
+	}
+	return nil
+}
+
+// Subscriptions lists the notes the user subscribes to
+func (s *PublishedNoteService) Subscriptions(ctx context.Context, userID uuid.UUID) ([]PublishedNoteSummary, error) {
+	var notes []PublishedNoteSummary
+	if err := s.summaries(ctx, userID).Where("ns.id IS NOT NULL").
+		Order("b.title, pn.title").Scan(&notes).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve subscriptions: %w", err)
+	}
+	return notes, nil
+}
+
+// Rate records a subscriber's rating of a note, replacing any earlier one,
+// and updates the note's average
+func (s *PublishedNoteService) Rate(ctx context.Context, userID, noteID uuid.UUID, rating int, review string) (*models.NoteRating, error) {
+	if rating < 1 || rating > 5 {
+		return nil, fmt.Errorf("%w rating: use 1 to 5", ErrInvalid)
+	}
+	var saved models.NoteRating
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var note models.PublishedNote
+		result := tx.Where("id = ?", noteID).Limit(1).Find(&note)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve published note: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("published note %w", ErrNotFound)
+		}
+		if note.AuthorID == userID {
+			return fmt.Errorf("%w: authors cannot rate their own notes", ErrAccessDenied)
+		}
+		var subscription models.NoteSubscription
+		result = tx.Where("user_id = ? AND published_note_id = ?", userID, noteID).Limit(1).Find(&subscription)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve subscription: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: subscribe to a note before rating it", ErrAccessDenied)
+		}
+		if err := checkNoteAccess(tx, &note, userID); err != nil {
+			return err
+		}
+		version := note.Version
+		if subscription.PinnedVersion != nil {
+			version = *subscription.PinnedVersion
+		}
+
+		result = tx.Where("user_id = ? AND published_note_id = ?", userID, noteID).Limit(1).Find(&saved)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve rating: %w", result.Error)
+		}
+		saved.UserID, saved.PublishedNoteID = userID, noteID
+		saved.Rating, saved.Review, saved.Version = rating, strings.TrimSpace(review), version
+		if err := tx.Save(&saved).Error; err != nil {
+			return fmt.Errorf("failed to save rating: %w", err)
+		}
+		return updateNoteRating(tx, noteID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// updateNoteRating recomputes a note's average rating and count
+func updateNoteRating(tx *gorm.DB, noteID uuid.UUID) error {
+	err := tx.Exec(`
+		UPDATE published_notes SET
+			rating = COALESCE((SELECT ROUND(AVG(rating)::numeric, 2) FROM note_ratings WHERE published_note_id = ? AND deleted_at IS NULL), 0),
+			ratings_count = (SELECT COUNT(*) FROM note_ratings WHERE published_note_id = ? AND deleted_at IS NULL)
+		WHERE id = ?
+	`, noteID, noteID, noteID).Error
+	if err != nil {
+		return fmt.Errorf("failed to update rating: %w", err)
+	}
+	return nil
+}
+
+// Ratings lists a note's ratings, newest first
+func (s *PublishedNoteService) Ratings(ctx context.Context, viewerID, noteID uuid.UUID, limit, offset int) ([]NoteReview, int64, error) {
+	if _, err := s.Get(ctx, viewerID, noteID); err != nil {
+		return nil, 0, err
+	}
+	query := s.db.WithContext(ctx).Table("note_ratings r").
+		Joins("JOIN users u ON u.id = r.user_id").
+		Where("r.published_note_id = ? AND r.deleted_at IS NULL", noteID)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count ratings: %w", err)
+	}
+	reviews := []NoteReview{}
+	if err := query.Select(`r.id, r.rating, r.review, r.version, r.created_at, r.updated_at,
+			COALESCE(NULLIF(u.full_name, ''), u.username) AS reviewer_name`).
+		Order("r.updated_at DESC").Limit(limit).Offset(offset).Scan(&reviews).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve ratings: %w", err)
+	}
+	return reviews, total, nil
+}
+
+// summaries selects note summaries as seen by the viewer
+func (s *PublishedNoteService) summaries(ctx context.Context, viewerID uuid.UUID) *gorm.DB {
+	return s.db.WithContext(ctx).Table("published_notes pn").
+		Select(`pn.id, pn.author_id, COALESCE(NULLIF(u.full_name, ''), u.username) AS author_name,
+			pn.book_id, b.title AS book_title, b.author AS book_author, pn.book_isbn, pn.book_fingerprint,
+			pn.title, pn.description, pn.difficulty_level, pn.status, pn.version, pn.published_at,
+			pn.price_cents, pn.is_verified, pn.rating, pn.ratings_count, pn.downloads_count,
+			(pn.price_cents = 0 OR pn.author_id = ? OR EXISTS (
+				SELECT 1 FROM note_entitlements ne
+				WHERE ne.published_note_id = pn.id AND ne.user_id = ? AND ne.deleted_at IS NULL
+			)) AS entitled,
+			ns.id IS NOT NULL AS subscribed, ns.pinned_version, pn.created_at, pn.updated_at`, viewerID, viewerID).
+		Joins("JOIN users u ON u.id = pn.author_id").
+		Joins("JOIN books b ON b.id = pn.book_id").
+		Joins("LEFT JOIN note_subscriptions ns ON ns.published_note_id = pn.id AND ns.user_id = ? AND ns.deleted_at IS NULL", viewerID).
+		Where("pn.deleted_at IS NULL")
+}
+
+// noteVisible reports whether a viewer may see a note
+func noteVisible(note *PublishedNoteSummary, viewerID uuid.UUID) bool {
+	return note.AuthorID == viewerID || note.Status == models.NotePublished || note.Subscribed
+}
+
+// authoredNote loads a note written by the author
+func authoredNote(db *gorm.DB, authorID, noteID uuid.UUID) (*models.PublishedNote, error) {
+	var note models.PublishedNote
+	err := db.Where("id = ? AND author_id = ?", noteID, authorID).First(&note).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("published note %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve published note: %w", err)
+	}
+	return &note, nil
+}
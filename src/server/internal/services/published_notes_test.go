@@ -0,0 +1,109 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestTextFingerprint(t *testing.T) {
+	a := TextFingerprint("Sing, O goddess, the anger of Achilles son of Peleus")
+	if a == "" || len(a) != 64 {
+		t.Fatalf("got %q", a)
+	}
+	if b := TextFingerprint("SING  O Goddess — the anger of\nAchilles, son of Peleus."); b != a {
+		t.Errorf("punctuation, case and layout changed the fingerprint")
+	}
+	if b := TextFingerprint("Sing, O muse, the anger of Achilles son of Peleus"); b == a {
+		t.Errorf("different words gave the same fingerprint")
+	}
+	if got := TextFingerprint(" — \n"); got != "" {
+		t.Errorf("text without words: got %q", got)
+	}
+
+	// Long texts ignore their first and last tenth
+	body := strings.Repeat("arma virumque cano troiae qui primus ab oris ", 50)
+	front := "Project Gutenberg edition, transcribed 2004. "
+	if TextFingerprint(front+body) != TextFingerprint("Another publisher's licence text. "+body) {
+		t.Errorf("front matter changed the fingerprint of a long text")
+	}
+	if TextFingerprint("tiny "+strings.Repeat("word ", 20)) == TextFingerprint("other "+strings.Repeat("word ", 20)) {
+		t.Errorf("short texts lost their edges")
+	}
+}
+
+func TestOverlayFromAnnotation(t *testing.T) {
+	text := "It is a truth universally acknowledged, that a single man in possession of a good fortune, must be in want of a wife."
+	locator := NewQuoteLocator(text)
+	start := strings.Index(text, "a single man")
+	a := &models.Annotation{
+		BaseModel:     models.BaseModel{ID: uuid.New()},
+		PageNumber:    1,
+		StartPosition: start,
+		EndPosition:   start + len("a single man"),
+		SelectedText:  "a single man",
+		Content:       "Irony begins here",
+	}
+	item := models.PublishedNoteItem{NoteType: models.OverlayContext}
+	overlay := overlayFromAnnotation(a, item, uuid.New(), 3, 7, locator)
+	if overlay.Version != 3 || overlay.DisplayOrder != 7 || overlay.NoteType != models.OverlayContext ||
+		overlay.AnnotationID == nil || *overlay.AnnotationID != a.ID {
+		t.Errorf("got %+v", overlay)
+	}
+	if !strings.HasSuffix(overlay.QuotePrefix, "acknowledged, that ") || !strings.HasPrefix(overlay.QuoteSuffix, " in possession") {
+		t.Errorf("context %q / %q", overlay.QuotePrefix, overlay.QuoteSuffix)
+	}
+
+	// Positions that no longer hold the quote keep no context
+	a.StartPosition++
+	if overlay := overlayFromAnnotation(a, item, uuid.New(), 1, 0, locator); overlay.QuotePrefix != "" || overlay.QuoteSuffix != "" {
+		t.Errorf("stale positions kept context %q / %q", overlay.QuotePrefix, overlay.QuoteSuffix)
+	}
+	if overlay := overlayFromAnnotation(a, item, uuid.New(), 1, 0, nil); overlay.Quote != "a single man" {
+		t.Errorf("quote lost without text: %+v", overlay)
+	}
+}
+
+func TestAnchorOverlay(t *testing.T) {
+	author := "Chapter 1\n\nCall me Ishmael. Some years ago, never mind how long precisely."
+	reader := "THE WHALE\nby Herman Melville\n\nChapter 1\nCall me Ishmael.  Some years ago—never mind how long precisely."
+	start := strings.Index(author, "Some years ago")
+	quoted := &models.NoteOverlay{
+		StartPosition: start,
+		EndPosition:   start + len("Some years ago"),
+		Quote:         "Some years ago",
+		QuotePrefix:   "Call me Ishmael. ",
+		QuoteSuffix:   ", never mind",
+	}
+
+	view := overlayView(quoted, "")
+	anchorOverlay(&view, quoted, NewQuoteLocator(author), false)
+	if !view.Anchored || view.StartPosition != quoted.StartPosition {
+		t.Errorf("same text: got %+v", view)
+	}
+
+	view = overlayView(quoted, "")
+	anchorOverlay(&view, quoted, NewQuoteLocator(reader), false)
+	want := strings.Index(reader, "Some years ago")
+	if !view.Anchored || view.StartPosition != want || view.EndPosition != want+len("Some years ago") {
+		t.Errorf("moved text: got %+v, want start %d", view, want)
+	}
+
+	view = overlayView(quoted, "")
+	anchorOverlay(&view, quoted, NewQuoteLocator("A different book entirely."), false)
+	if view.Anchored || view.StartPosition != quoted.StartPosition {
+		t.Errorf("missing quote: got %+v", view)
+	}
+
+	unquoted := &models.NoteOverlay{StartPosition: 4, EndPosition: 9}
+	for _, sameText := range []bool{true, false} {
+		view = overlayView(unquoted, "")
+		anchorOverlay(&view, unquoted, NewQuoteLocator(reader), sameText)
+		if view.Anchored != sameText {
+			t.Errorf("unquoted overlay with same text %v: anchored %v", sameText, view.Anchored)
+		}
+	}
+}
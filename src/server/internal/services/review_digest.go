@@ -0,0 +1,182 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/models"
+)
+
+// ReviewDigest is the daily review rendered for delivery outside the app,
+// e.g. by email or chat
+type ReviewDigest struct {
+	UserID   uuid.UUID    `json:"user_id"`
+	Username string       `json:"username"`
+	Email    string       `json:"email"`
+	Date     string       `json:"date"`
+	Subject  string       `json:"subject"`
+	DueCount int64        `json:"due_count"`
+	Items    []DigestItem `json:"items"`
+	Text     string       `json:"text"` // Plain-text rendering of the items
+}
+
+// DigestItem is one annotation in a review digest
+type DigestItem struct {
+	AnnotationID uuid.UUID `json:"annotation_id"`
+	BookID       uuid.UUID `json:"book_id"`
+	BookTitle    string    `json:"book_title"`
+	Author       string    `json:"author"`
+	Type         string    `json:"type"`
+	Text         string    `json:"text"`
+	Note         string    `json:"note,omitempty"`
+	PageNumber   int       `json:"page_number"`
+	Favorite     bool      `json:"favorite"`
+}
+
+// DigestChannel delivers review digests to a notification channel
+type DigestChannel interface {
+	SendDigest(ctx context.Context, digest *ReviewDigest) error
+}
+
+// WebhookDigestChannel posts digests as JSON to a webhook, which can relay
+// them to email, chat or push notifications
+type WebhookDigestChannel struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookDigestChannel creates a digest channel posting to url
+func NewWebhookDigestChannel(url string) *WebhookDigestChannel {
+	return &WebhookDigestChannel{
+		url: url,
+		client: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+	}
+}
+
+// SendDigest posts the digest to the webhook
+func (w *WebhookDigestChannel) SendDigest(ctx context.Context, digest *ReviewDigest) error {
+	jsonData, err := json.Marshal(digest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal digest: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", w.url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send digest: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("digest webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// DigestEnabled reports whether a notification channel is configured
+func (s *ReviewService) DigestEnabled() bool {
+	return s.channel != nil
+}
+
+// BuildDigest renders today's review batch as a digest
+func (s *ReviewService) BuildDigest(ctx context.Context, userID uuid.UUID, limit int) (*ReviewDigest, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("user %w", ErrNotFound)
+	}
+
+	daily, err := s.Daily(ctx, userID, nil, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := &ReviewDigest{
+		UserID:   userID,
+		Username: user.Username,
+		Email:    user.Email,
+		Date:     daily.Date,
+		DueCount: daily.DueCount,
+		Items:    []DigestItem{},
+	}
+
+	var text strings.Builder
+	for _, review := range daily.Items {
+		if review.Annotation == nil {
+			continue
+		}
+		item := DigestItem{
+			AnnotationID: review.AnnotationID,
+			BookID:       review.BookID,
+			BookTitle:    review.Annotation.Book.Title,
+			Author:       review.Annotation.Book.Author,
+			Type:         review.Annotation.Type,
+			Text:         review.Annotation.SelectedText,
+			Note:         review.Annotation.Content,
+			PageNumber:   review.Annotation.PageNumber,
+			Favorite:     review.Favorite,
+		}
+		digest.Items = append(digest.Items, item)
+
+		if item.Text != "" {
+			fmt.Fprintf(&text, "“%s”\n", strings.TrimSpace(item.Text))
+		}
+		if item.Note != "" {
+			fmt.Fprintf(&text, "Note: %s\n", strings.TrimSpace(item.Note))
+		}
+		source := item.BookTitle
+		if item.Author != "" {
+			source += ", " + item.Author
+		}
+		if item.PageNumber > 0 {
+			source += fmt.Sprintf(" (p. %d)", item.PageNumber)
+		}
+		fmt.Fprintf(&text, "— %s\n\n", source)
+	}
+
+	switch len(digest.Items) {
+	case 0:
+		digest.Subject = "Nothing to review today"
+	case 1:
+		digest.Subject = "1 passage to revisit today"
+	default:
+		digest.Subject = fmt.Sprintf("%d passages to revisit today", len(digest.Items))
+	}
+	digest.Text = strings.TrimSpace(text.String())
+
+	return digest, nil
+}
+
+// SendDigest builds today's digest and delivers it through the configured
+// channel. Empty digests are not sent.
+func (s *ReviewService) SendDigest(ctx context.Context, userID uuid.UUID, limit int) (*ReviewDigest, error) {
+	if s.channel == nil {
+		return nil, fmt.Errorf("digest channel is not configured")
+	}
+
+	digest, err := s.BuildDigest(ctx, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(digest.Items) == 0 {
+		return digest, nil
+	}
+
+	if err := s.channel.SendDigest(ctx, digest); err != nil {
+		return nil, err
+	}
+	return digest, nil
+}
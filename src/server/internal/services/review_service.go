@@ -0,0 +1,526 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/classius/server/internal/models"
+)
+
+// defaultDailyReviewLimit is the size of the daily batch when the user has
+// not set one
+const defaultDailyReviewLimit = 10
+
+// favoriteIntervalMultiplier makes favorited annotations resurface twice as often
+const favoriteIntervalMultiplier = 0.5
+
+// ReviewService resurfaces highlights and notes on a spaced schedule
+type ReviewService struct {
+	db      *gorm.DB
+	channel DigestChannel
+}
+
+// NewReviewService creates a new review service. channel may be nil, in
+// which case digests can be built but not sent.
+func NewReviewService(db *gorm.DB, channel DigestChannel) *ReviewService {
+	return &ReviewService{db: db, channel: channel}
+}
+
+// DailyReview is today's batch of annotations to review
+type DailyReview struct {
+	Date          string                    `json:"date"`
+	DueCount      int64                     `json:"due_count"`
+	NewCount      int64                     `json:"new_count"`
+	ReviewedToday int64                     `json:"reviewed_today"`
+	Items         []models.AnnotationReview `json:"items"`
+}
+
+// ReviewItemFilter filters the list of review items
+type ReviewItemFilter struct {
+	UserID    uuid.UUID
+	BookID    *uuid.UUID
+	Favorite  *bool
+	Discarded *bool
+	Limit     int
+	Offset    int
+}
+
+// UpdateReviewItemRequest favorites, discards or restores a review item
+type UpdateReviewItemRequest struct {
+	Favorite  *bool `json:"favorite"`
+	Discarded *bool `json:"discarded"`
+}
+
+// ReviewSettingRequest creates or replaces the review setting for a scope
+type ReviewSettingRequest struct {
+	Scope              string     `json:"scope"` // default, book, tag
+	BookID             *uuid.UUID `json:"book_id"`
+	Tag                string     `json:"tag"`
+	IntervalMultiplier float64    `json:"interval_multiplier"`
+	Excluded           bool       `json:"excluded"`
+	DailyLimit         int        `json:"daily_limit"`
+	Algorithm          string     `json:"algorithm"`
+}
+
+// reviewPolicy resolves a user's review settings for an annotation
+type reviewPolicy struct {
+	fallback models.ReviewSetting
+	books    map[uuid.UUID]models.ReviewSetting
+	tags     map[string]models.ReviewSetting
+}
+
+func (s *ReviewService) loadPolicy(ctx context.Context, userID uuid.UUID) (*reviewPolicy, error) {
+	var settings []models.ReviewSetting
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Find(&settings).Error; err != nil {
+		return nil, fmt.Errorf("failed to load review settings: %w", err)
+	}
+
+	policy := &reviewPolicy{
+		fallback: models.ReviewSetting{Scope: models.ReviewScopeDefault, IntervalMultiplier: 1},
+		books:    make(map[uuid.UUID]models.ReviewSetting),
+		tags:     make(map[string]models.ReviewSetting),
+	}
+	for _, setting := range settings {
+		switch setting.Scope {
+		case models.ReviewScopeDefault:
+			policy.fallback = setting
+		case models.ReviewScopeBook:
+			if setting.BookID != nil {
+				policy.books[*setting.BookID] = setting
+			}
+		case models.ReviewScopeTag:
+			policy.tags[strings.ToLower(setting.Tag)] = setting
+		}
+	}
+	return policy, nil
+}
+
+// resolve returns the interval multiplier for an annotation and whether it
+// is excluded from review. When several tag settings apply, any exclusion
+// wins and otherwise the most frequent multiplier is used.
+func (p *reviewPolicy) resolve(bookID uuid.UUID, tags []string) (float64, bool) {
+	if setting, ok := p.books[bookID]; ok {
+		return multiplierOrOne(setting.IntervalMultiplier), setting.Excluded
+	}
+
+	multiplier, matched := 0.0, false
+	for _, tag := range tags {
+		setting, ok := p.tags[strings.ToLower(tag)]
+		if !ok {
+			continue
+		}
+		if setting.Excluded {
+			return 1, true
+		}
+		if m := multiplierOrOne(setting.IntervalMultiplier); !matched || m < multiplier {
+			multiplier, matched = m, true
+		}
+	}
+	if matched {
+		return multiplier, false
+	}
+
+	return multiplierOrOne(p.fallback.IntervalMultiplier), false
+}
+
+func (p *reviewPolicy) dailyLimit() int {
+	if p.fallback.DailyLimit > 0 {
+		return p.fallback.DailyLimit
+	}
+	return defaultDailyReviewLimit
+}
+
+func multiplierOrOne(m float64) float64 {
+	if m <= 0 {
+		return 1
+	}
+	return m
+}
+
+// applyExclusions leaves out annotations excluded by book or tag settings.
+// A book setting that is not excluded overrides exclusions by tag.
+func (p *reviewPolicy) applyExclusions(query *gorm.DB) *gorm.DB {
+	var excludedBooks, includedBooks []uuid.UUID
+	for bookID, setting := range p.books {
+		if setting.Excluded {
+			excludedBooks = append(excludedBooks, bookID)
+		} else {
+			includedBooks = append(includedBooks, bookID)
+		}
+	}
+	if len(excludedBooks) > 0 {
+		query = query.Where("annotation_reviews.book_id NOT IN ?", excludedBooks)
+	}
+	for _, setting := range p.tags {
+		if !setting.Excluded {
+			continue
+		}
+		if len(includedBooks) > 0 {
+			query = query.Where("(annotation_reviews.book_id IN ? OR NOT COALESCE(? = ANY(a.tags), FALSE))", includedBooks, setting.Tag)
+		} else {
+			query = query.Where("NOT COALESCE(? = ANY(a.tags), FALSE)", setting.Tag)
+		}
+	}
+	return query
+}
+
+// enroll creates review items for highlights and notes that have none yet.
+// New items are due from the moment the annotation was made.
+func (s *ReviewService) enroll(ctx context.Context, userID uuid.UUID, algorithm string) error {
+	if !ValidSchedulerAlgorithm(algorithm) {
+		algorithm = models.SchedulerSM2
+	}
+	err := s.db.WithContext(ctx).Exec(`
+		INSERT INTO annotation_reviews (id, user_id, annotation_id, book_id, algorithm, state, due_at, ease_factor, created_at, updated_at)
+		SELECT gen_random_uuid(), a.user_id, a.id, a.book_id, ?, ?, a.created_at, 2.5, NOW(), NOW()
+		FROM annotations a
+		WHERE a.user_id = ? AND a.deleted_at IS NULL AND a.type IN ('highlight', 'note')
+		  AND NOT EXISTS (SELECT 1 FROM annotation_reviews r WHERE r.annotation_id = a.id)
+	`, algorithm, models.ReviewStateNew, userID).Error
+	if err != nil {
+		return fmt.Errorf("failed to enroll annotations for review: %w", err)
+	}
+	return nil
+}
+
+// reviewItems returns the live review items of a user, joined with their annotation as "a"
+func (s *ReviewService) reviewItems(ctx context.Context, userID uuid.UUID) *gorm.DB {
+	return s.db.WithContext(ctx).Model(&models.AnnotationReview{}).
+		Joins("JOIN annotations a ON a.id = annotation_reviews.annotation_id AND a.deleted_at IS NULL").
+		Where("annotation_reviews.user_id = ?", userID)
+}
+
+// Daily returns today's batch: annotations already in review that fall due
+// today, favorites first, topped up with never-reviewed annotations. The
+// choice of new annotations is stable for the day.
+func (s *ReviewService) Daily(ctx context.Context, userID uuid.UUID, bookID *uuid.UUID, limit int) (*DailyReview, error) {
+	policy, err := s.loadPolicy(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.enroll(ctx, userID, policy.fallback.Algorithm); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = policy.dailyLimit()
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	tomorrow := today.AddDate(0, 0, 1)
+	date := today.Format("2006-01-02")
+
+	base := func() *gorm.DB {
+		q := policy.applyExclusions(s.reviewItems(ctx, userID).Where("annotation_reviews.discarded = ?", false))
+		if bookID != nil {
+			q = q.Where("annotation_reviews.book_id = ?", *bookID)
+		}
+		return q
+	}
+
+	daily := &DailyReview{Date: date, Items: []models.AnnotationReview{}}
+	if err := base().Where("annotation_reviews.state <> ? AND annotation_reviews.due_at < ?", models.ReviewStateNew, tomorrow).
+		Count(&daily.DueCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count due annotations: %w", err)
+	}
+	if err := base().Where("annotation_reviews.state = ?", models.ReviewStateNew).Count(&daily.NewCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count new annotations: %w", err)
+	}
+	if err := base().Where("annotation_reviews.last_reviewed_at >= ?", today).Count(&daily.ReviewedToday).Error; err != nil {
+		return nil, fmt.Errorf("failed to count reviewed annotations: %w", err)
+	}
+
+	// Annotations reviewed today count against the batch
+	remaining := limit - int(daily.ReviewedToday)
+	if remaining <= 0 {
+		return daily, nil
+	}
+
+	var due []models.AnnotationReview
+	if err := base().Preload("Annotation.Book").
+		Where("annotation_reviews.state <> ? AND annotation_reviews.due_at < ?", models.ReviewStateNew, tomorrow).
+		Order("annotation_reviews.favorite DESC, annotation_reviews.due_at ASC").
+		Limit(remaining).Find(&due).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve due annotations: %w", err)
+	}
+	daily.Items = append(daily.Items, due...)
+
+	if remaining -= len(due); remaining > 0 {
+		var fresh []models.AnnotationReview
+		if err := base().Preload("Annotation.Book").
+			Where("annotation_reviews.state = ?", models.ReviewStateNew).
+			Order(gorm.Expr("annotation_reviews.favorite DESC, md5(annotation_reviews.id::text || ?)", date)).
+			Limit(remaining).Find(&fresh).Error; err != nil {
+			return nil, fmt.Errorf("failed to retrieve new annotations: %w", err)
+		}
+		daily.Items = append(daily.Items, fresh...)
+	}
+
+	return daily, nil
+}
+
+// getItem returns the review item of an annotation, enrolling the
+// annotation if it has none yet
+func (s *ReviewService) getItem(ctx context.Context, userID, annotationID uuid.UUID) (*models.AnnotationReview, error) {
+	var item models.AnnotationReview
+	err := s.reviewItems(ctx, userID).Preload("Annotation.Book").
+		Where("annotation_reviews.annotation_id = ?", annotationID).
+		First(&item).Error
+	if err == nil {
+		return &item, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to retrieve review item: %w", err)
+	}
+
+	var annotation models.Annotation
+	if err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", annotationID, userID).First(&annotation).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("annotation %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to retrieve annotation: %w", err)
+	}
+	if annotation.Type != "highlight" && annotation.Type != "note" {
+		return nil, fmt.Errorf("%w annotation: only highlights and notes can be reviewed", ErrInvalid)
+	}
+
+	policy, err := s.loadPolicy(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.enroll(ctx, userID, policy.fallback.Algorithm); err != nil {
+		return nil, err
+	}
+	if err := s.reviewItems(ctx, userID).Preload("Annotation.Book").
+		Where("annotation_reviews.annotation_id = ?", annotationID).
+		First(&item).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve review item: %w", err)
+	}
+	return &item, nil
+}
+
+// ListItems returns review items matching a filter, soonest due first
+func (s *ReviewService) ListItems(ctx context.Context, filter ReviewItemFilter) ([]models.AnnotationReview, int64, error) {
+	policy, err := s.loadPolicy(ctx, filter.UserID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := s.enroll(ctx, filter.UserID, policy.fallback.Algorithm); err != nil {
+		return nil, 0, err
+	}
+
+	query := s.reviewItems(ctx, filter.UserID)
+	if filter.BookID != nil {
+		query = query.Where("annotation_reviews.book_id = ?", *filter.BookID)
+	}
+	if filter.Favorite != nil {
+		query = query.Where("annotation_reviews.favorite = ?", *filter.Favorite)
+	}
+	if filter.Discarded != nil {
+		query = query.Where("annotation_reviews.discarded = ?", *filter.Discarded)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count review items: %w", err)
+	}
+
+	var items []models.AnnotationReview
+	q := query.Preload("Annotation.Book").Order("annotation_reviews.due_at ASC")
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit).Offset(filter.Offset)
+	}
+	if err := q.Find(&items).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve review items: %w", err)
+	}
+	return items, total, nil
+}
+
+// UpdateItem favorites or unfavorites an annotation, or discards it from
+// the review and restores it
+func (s *ReviewService) UpdateItem(ctx context.Context, userID, annotationID uuid.UUID, req UpdateReviewItemRequest) (*models.AnnotationReview, error) {
+	item, err := s.getItem(ctx, userID, annotationID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if req.Favorite != nil {
+		updates["favorite"] = *req.Favorite
+	}
+	if req.Discarded != nil && *req.Discarded != item.Discarded {
+		updates["discarded"] = *req.Discarded
+		if *req.Discarded {
+			updates["discarded_at"] = time.Now()
+		} else {
+			// A restored annotation comes back in the next daily review
+			updates["discarded_at"] = nil
+			updates["due_at"] = time.Now()
+		}
+	}
+	if len(updates) > 0 {
+		if err := s.db.WithContext(ctx).Model(&models.AnnotationReview{}).Where("id = ?", item.ID).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("failed to update review item: %w", err)
+		}
+	}
+
+	return s.getItem(ctx, userID, annotationID)
+}
+
+// Grade records the answer for a reviewed annotation and reschedules it,
+// scaling the interval by the book or tag frequency setting
+func (s *ReviewService) Grade(ctx context.Context, userID, annotationID uuid.UUID, grade int) (*models.AnnotationReview, error) {
+	if !ValidGrade(grade) {
+		return nil, fmt.Errorf("%w grade %d: expected 1 (again) to 4 (easy)", ErrInvalid, grade)
+	}
+
+	item, err := s.getItem(ctx, userID, annotationID)
+	if err != nil {
+		return nil, err
+	}
+	if item.Discarded {
+		return nil, fmt.Errorf("%w: grading a discarded annotation is not allowed", ErrAccessDenied)
+	}
+
+	policy, err := s.loadPolicy(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	SchedulerFor(item.Algorithm).Schedule(&item.ReviewSchedule, grade, now)
+	if grade != models.GradeAgain {
+		var tags []string
+		if item.Annotation != nil {
+			tags = item.Annotation.Tags
+		}
+		multiplier, _ := policy.resolve(item.BookID, tags)
+		if item.Favorite {
+			multiplier *= favoriteIntervalMultiplier
+		}
+		if multiplier != 1 {
+			applyInterval(&item.ReviewSchedule, item.IntervalDays*multiplier, now)
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Model(&models.AnnotationReview{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+		"state":            item.State,
+		"due_at":           item.DueAt,
+		"interval_days":    item.IntervalDays,
+		"ease_factor":      item.EaseFactor,
+		"stability":        item.Stability,
+		"difficulty":       item.Difficulty,
+		"repetitions":      item.Repetitions,
+		"lapses":           item.Lapses,
+		"last_reviewed_at": item.LastReviewedAt,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to update schedule: %w", err)
+	}
+
+	return item, nil
+}
+
+// GetSettings returns a user's review settings
+func (s *ReviewService) GetSettings(ctx context.Context, userID uuid.UUID) ([]models.ReviewSetting, error) {
+	var settings []models.ReviewSetting
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).
+		Order("scope ASC, created_at ASC").Find(&settings).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve review settings: %w", err)
+	}
+	return settings, nil
+}
+
+// SaveSetting creates or replaces the setting for a scope
+func (s *ReviewService) SaveSetting(ctx context.Context, userID uuid.UUID, req ReviewSettingRequest) (*models.ReviewSetting, error) {
+	if req.Scope == "" {
+		req.Scope = models.ReviewScopeDefault
+	}
+	if req.IntervalMultiplier == 0 {
+		req.IntervalMultiplier = 1
+	}
+	if req.IntervalMultiplier < 0.1 || req.IntervalMultiplier > 10 {
+		return nil, fmt.Errorf("%w interval multiplier %.2f: expected 0.1 to 10", ErrInvalid, req.IntervalMultiplier)
+	}
+	if req.Algorithm != "" && !ValidSchedulerAlgorithm(req.Algorithm) {
+		return nil, fmt.Errorf("%w scheduler algorithm %q", ErrInvalid, req.Algorithm)
+	}
+	if req.DailyLimit < 0 || req.DailyLimit > 500 {
+		return nil, fmt.Errorf("%w daily limit %d: expected 0 to 500", ErrInvalid, req.DailyLimit)
+	}
+
+	query := s.db.WithContext(ctx).Where("user_id = ? AND scope = ?", userID, req.Scope)
+	switch req.Scope {
+	case models.ReviewScopeDefault:
+		if req.Excluded {
+			return nil, fmt.Errorf("%w setting: the default scope cannot be excluded", ErrInvalid)
+		}
+	case models.ReviewScopeBook:
+		if req.BookID == nil {
+			return nil, fmt.Errorf("%w: book_id is required for a book setting", ErrInvalid)
+		}
+		var count int64
+		if err := s.db.WithContext(ctx).Model(&models.Book{}).
+			Where("id = ? AND user_id = ?", *req.BookID, userID).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to verify book: %w", err)
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("book %w", ErrNotFound)
+		}
+		query = query.Where("book_id = ?", *req.BookID)
+	case models.ReviewScopeTag:
+		req.Tag = strings.TrimSpace(req.Tag)
+		if req.Tag == "" {
+			return nil, fmt.Errorf("%w: tag is required for a tag setting", ErrInvalid)
+		}
+		query = query.Where("LOWER(tag) = ?", strings.ToLower(req.Tag))
+	default:
+		return nil, fmt.Errorf("%w scope %q: expected default, book or tag", ErrInvalid, req.Scope)
+	}
+
+	setting := &models.ReviewSetting{}
+	err := query.First(setting).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to retrieve review setting: %w", err)
+	}
+
+	setting.UserID = userID
+	setting.Scope = req.Scope
+	setting.BookID = nil
+	setting.Tag = ""
+	switch req.Scope {
+	case models.ReviewScopeBook:
+		setting.BookID = req.BookID
+	case models.ReviewScopeTag:
+		setting.Tag = truncateRunes(req.Tag, 100)
+	}
+	setting.IntervalMultiplier = req.IntervalMultiplier
+	setting.Excluded = req.Excluded
+	setting.DailyLimit = 0
+	setting.Algorithm = ""
+	if req.Scope == models.ReviewScopeDefault {
+		setting.DailyLimit = req.DailyLimit
+		setting.Algorithm = req.Algorithm
+	}
+
+	if err := s.db.WithContext(ctx).Save(setting).Error; err != nil {
+		return nil, fmt.Errorf("failed to save review setting: %w", err)
+	}
+	return setting, nil
+}
+
+// DeleteSetting removes a review setting, reverting its scope to the default
+func (s *ReviewService) DeleteSetting(ctx context.Context, userID, settingID uuid.UUID) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", settingID, userID).Delete(&models.ReviewSetting{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete review setting: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("review setting %w", ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestReviewPolicyResolve(t *testing.T) {
+	tuned, skipped, other := uuid.New(), uuid.New(), uuid.New()
+	policy := &reviewPolicy{
+		fallback: models.ReviewSetting{Scope: models.ReviewScopeDefault, IntervalMultiplier: 1.5},
+		books: map[uuid.UUID]models.ReviewSetting{
+			tuned:   {Scope: models.ReviewScopeBook, IntervalMultiplier: 0.5},
+			skipped: {Scope: models.ReviewScopeBook, Excluded: true},
+		},
+		tags: map[string]models.ReviewSetting{
+			"ethics":   {Scope: models.ReviewScopeTag, Tag: "Ethics", IntervalMultiplier: 0.8},
+			"politics": {Scope: models.ReviewScopeTag, Tag: "politics", IntervalMultiplier: 0.6},
+			"trivia":   {Scope: models.ReviewScopeTag, Tag: "trivia", Excluded: true},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		book       uuid.UUID
+		tags       []string
+		multiplier float64
+		excluded   bool
+	}{
+		{"book setting wins over tags", tuned, []string{"trivia"}, 0.5, false},
+		{"excluded book", skipped, nil, 1, true},
+		{"tag match is case-insensitive", other, []string{"ETHICS"}, 0.8, false},
+		{"most frequent tag wins", other, []string{"ethics", "politics"}, 0.6, false},
+		{"excluded tag wins", other, []string{"ethics", "trivia"}, 1, true},
+		{"default", other, []string{"unrelated"}, 1.5, false},
+	}
+	for _, tt := range tests {
+		multiplier, excluded := policy.resolve(tt.book, tt.tags)
+		if multiplier != tt.multiplier || excluded != tt.excluded {
+			t.Errorf("%s: got (%.2f, %v), want (%.2f, %v)", tt.name, multiplier, excluded, tt.multiplier, tt.excluded)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/classius/server/internal/models"
+)
+
+// relearnDelay is how soon an item answered "again" comes back
+const relearnDelay = 10 * time.Minute
+
+// maxIntervalDays caps scheduled intervals at roughly a century
+const maxIntervalDays = 36500
+
+// ReviewScheduler computes the next review of an item from a grade
+type ReviewScheduler interface {
+	// Schedule updates the schedule for a review answered with grade
+	// (models.GradeAgain..models.GradeEasy) at now
+	Schedule(schedule *models.ReviewSchedule, grade int, now time.Time)
+}
+
+// SchedulerFor returns the scheduler for an algorithm, defaulting to SM-2
+func SchedulerFor(algorithm string) ReviewScheduler {
+	if algorithm == models.SchedulerFSRS {
+		return &fsrsScheduler{weights: fsrsDefaultWeights, retention: 0.9}
+	}
+	return &sm2Scheduler{}
+}
+
+// ValidSchedulerAlgorithm reports whether algorithm names a known scheduler
+func ValidSchedulerAlgorithm(algorithm string) bool {
+	return algorithm == models.SchedulerSM2 || algorithm == models.SchedulerFSRS
+}
+
+// ValidGrade reports whether grade is one of the four review answers
+func ValidGrade(grade int) bool {
+	return grade >= models.GradeAgain && grade <= models.GradeEasy
+}
+
+// NewReviewSchedule returns the schedule of a new item, due immediately
+func NewReviewSchedule(algorithm string, now time.Time) models.ReviewSchedule {
+	if !ValidSchedulerAlgorithm(algorithm) {
+		algorithm = models.SchedulerSM2
+	}
+	return models.ReviewSchedule{
+		Algorithm:  algorithm,
+		State:      models.ReviewStateNew,
+		DueAt:      now,
+		EaseFactor: 2.5,
+	}
+}
+
+// elapsedDays returns the days since the item was last reviewed
+func elapsedDays(schedule *models.ReviewSchedule, now time.Time) float64 {
+	if schedule.LastReviewedAt == nil {
+		return 0
+	}
+	days := now.Sub(*schedule.LastReviewedAt).Hours() / 24
+	if days < 0 {
+		return 0
+	}
+	return days
+}
+
+// applyInterval sets the due date from an interval in days
+func applyInterval(schedule *models.ReviewSchedule, days float64, now time.Time) {
+	days = math.Max(1, math.Min(maxIntervalDays, math.Round(days)))
+	schedule.IntervalDays = days
+	schedule.DueAt = now.Add(time.Duration(days * 24 * float64(time.Hour)))
+}
+
+// applyLapse schedules an item answered "again" for relearning
+func applyLapse(schedule *models.ReviewSchedule, now time.Time) {
+	if schedule.State == models.ReviewStateReview {
+		schedule.Lapses++
+		schedule.State = models.ReviewStateRelearning
+	} else if schedule.State == models.ReviewStateNew {
+		schedule.State = models.ReviewStateLearning
+	}
+	schedule.IntervalDays = 0
+	schedule.DueAt = now.Add(relearnDelay)
+}
+
+// sm2Scheduler implements the SuperMemo SM-2 algorithm with Anki-style
+// hard and easy modifiers
+type sm2Scheduler struct{}
+
+func (s *sm2Scheduler) Schedule(schedule *models.ReviewSchedule, grade int, now time.Time) {
+	defer func() { schedule.LastReviewedAt = &now }()
+
+	if schedule.EaseFactor == 0 {
+		schedule.EaseFactor = 2.5
+	}
+	// SM-2 quality on its 0-5 scale
+	quality := map[int]float64{models.GradeAgain: 1, models.GradeHard: 3, models.GradeGood: 4, models.GradeEasy: 5}[grade]
+
+	schedule.EaseFactor = math.Max(1.3, schedule.EaseFactor+(0.1-(5-quality)*(0.08+(5-quality)*0.02)))
+
+	if grade == models.GradeAgain {
+		schedule.Repetitions = 0
+		applyLapse(schedule, now)
+		return
+	}
+
+	schedule.Repetitions++
+	var interval float64
+	switch schedule.Repetitions {
+	case 1:
+		interval = 1
+	case 2:
+		interval = 6
+	default:
+		switch grade {
+		case models.GradeHard:
+			interval = schedule.IntervalDays * 1.2
+		case models.GradeEasy:
+			interval = schedule.IntervalDays * schedule.EaseFactor * 1.3
+		default:
+			interval = schedule.IntervalDays * schedule.EaseFactor
+		}
+	}
+	if grade == models.GradeEasy && schedule.Repetitions <= 2 {
+		interval *= 1.3
+	}
+
+	schedule.State = models.ReviewStateReview
+	applyInterval(schedule, interval, now)
+}
+
+// fsrsDefaultWeights are the published FSRS-4.5 default parameters
+var fsrsDefaultWeights = [17]float64{
+	0.4872, 1.4003, 3.7145, 13.8206, 5.1618, 1.2298, 0.8975, 0.031,
+	1.6474, 0.1367, 1.0461, 2.1072, 0.0793, 0.3246, 1.587, 0.2272, 2.8755,
+}
+
+const (
+	fsrsDecay  = -0.5
+	fsrsFactor = 19.0 / 81.0
+)
+
+// fsrsScheduler implements the FSRS-4.5 memory model: each review updates
+// the item's stability (days until recall probability falls to 90%) and
+// difficulty, and the next interval targets the desired retention
+type fsrsScheduler struct {
+	weights   [17]float64
+	retention float64
+}
+
+func (f *fsrsScheduler) Schedule(schedule *models.ReviewSchedule, grade int, now time.Time) {
+	w := f.weights
+	g := float64(grade)
+	elapsed := elapsedDays(schedule, now)
+	defer func() { schedule.LastReviewedAt = &now }()
+
+	if schedule.State == models.ReviewStateNew || schedule.Stability <= 0 {
+		schedule.Stability = w[grade-1]
+		schedule.Difficulty = f.initialDifficulty(g)
+	} else {
+		r := f.retrievability(elapsed, schedule.Stability)
+		d := schedule.Difficulty - w[6]*(g-3)
+		schedule.Difficulty = clamp(w[7]*f.initialDifficulty(3)+(1-w[7])*d, 1, 10)
+
+		if grade == models.GradeAgain {
+			schedule.Stability = w[11] * math.Pow(schedule.Difficulty, -w[12]) *
+				(math.Pow(schedule.Stability+1, w[13]) - 1) * math.Exp(w[14]*(1-r))
+		} else {
+			modifier := 1.0
+			if grade == models.GradeHard {
+				modifier = w[15]
+			} else if grade == models.GradeEasy {
+				modifier = w[16]
+			}
+			schedule.Stability *= math.Exp(w[8])*(11-schedule.Difficulty)*
+				math.Pow(schedule.Stability, -w[9])*(math.Exp(w[10]*(1-r))-1)*modifier + 1
+		}
+	}
+	schedule.Stability = math.Max(0.1, schedule.Stability)
+
+	if grade == models.GradeAgain {
+		applyLapse(schedule, now)
+		return
+	}
+
+	schedule.Repetitions++
+	schedule.State = models.ReviewStateReview
+	applyInterval(schedule, f.interval(schedule.Stability), now)
+}
+
+func (f *fsrsScheduler) initialDifficulty(g float64) float64 {
+	return clamp(f.weights[4]-(g-3)*f.weights[5], 1, 10)
+}
+
+// retrievability is the probability of recall after t days
+func (f *fsrsScheduler) retrievability(t, stability float64) float64 {
+	return math.Pow(1+fsrsFactor*t/stability, fsrsDecay)
+}
+
+// interval is the number of days until retrievability drops to the
+// desired retention
+func (f *fsrsScheduler) interval(stability float64) float64 {
+	return stability / fsrsFactor * (math.Pow(f.retention, 1/fsrsDecay) - 1)
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestSM2Intervals(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	schedule := NewReviewSchedule(models.SchedulerSM2, now)
+	scheduler := SchedulerFor(models.SchedulerSM2)
+
+	var intervals []float64
+	for i := 0; i < 3; i++ {
+		scheduler.Schedule(&schedule, models.GradeGood, now)
+		intervals = append(intervals, schedule.IntervalDays)
+		now = schedule.DueAt
+	}
+	if intervals[0] != 1 || intervals[1] != 6 || intervals[2] != 15 {
+		t.Errorf("intervals = %v, want [1 6 15]", intervals)
+	}
+	if schedule.State != models.ReviewStateReview || schedule.Repetitions != 3 {
+		t.Errorf("state = %s, repetitions = %d", schedule.State, schedule.Repetitions)
+	}
+
+	scheduler.Schedule(&schedule, models.GradeAgain, now)
+	if schedule.State != models.ReviewStateRelearning || schedule.Lapses != 1 || schedule.Repetitions != 0 {
+		t.Errorf("after lapse: state = %s, lapses = %d, repetitions = %d", schedule.State, schedule.Lapses, schedule.Repetitions)
+	}
+	if got := schedule.DueAt.Sub(now); got != relearnDelay {
+		t.Errorf("relearn delay = %v, want %v", got, relearnDelay)
+	}
+	if schedule.EaseFactor >= 2.5 {
+		t.Errorf("ease factor = %.2f, want it lowered after a lapse", schedule.EaseFactor)
+	}
+}
+
+func TestFSRSSchedule(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	schedule := NewReviewSchedule(models.SchedulerFSRS, now)
+	scheduler := SchedulerFor(models.SchedulerFSRS)
+
+	scheduler.Schedule(&schedule, models.GradeGood, now)
+	if schedule.Stability != fsrsDefaultWeights[2] {
+		t.Errorf("initial stability = %.4f, want %.4f", schedule.Stability, fsrsDefaultWeights[2])
+	}
+	first := schedule.IntervalDays
+
+	// At 90% retention the interval equals the stability
+	if first != 4 {
+		t.Errorf("first interval = %v, want 4", first)
+	}
+
+	now = schedule.DueAt
+	scheduler.Schedule(&schedule, models.GradeGood, now)
+	if schedule.IntervalDays <= first {
+		t.Errorf("second interval = %v, want more than %v", schedule.IntervalDays, first)
+	}
+
+	stability := schedule.Stability
+	difficulty := schedule.Difficulty
+	now = schedule.DueAt
+	scheduler.Schedule(&schedule, models.GradeAgain, now)
+	if schedule.State != models.ReviewStateRelearning || schedule.Lapses != 1 {
+		t.Errorf("after lapse: state = %s, lapses = %d", schedule.State, schedule.Lapses)
+	}
+	if schedule.Stability >= stability || schedule.Difficulty <= difficulty {
+		t.Errorf("lapse should lower stability and raise difficulty: %.2f/%.2f -> %.2f/%.2f",
+			stability, difficulty, schedule.Stability, schedule.Difficulty)
+	}
+}
+
+func TestWriteAnkiPackage(t *testing.T) {
+	now := time.Now()
+	words := []models.VocabularyWord{
+		{Word: "virtus", Lemma: "virtus", Language: "la", Definition: "manliness, courage", ReviewSchedule: NewReviewSchedule(models.SchedulerSM2, now)},
+		{Word: "λόγος", Lemma: "λόγος", Language: "grc", Definition: "word, reason", ReviewSchedule: NewReviewSchedule(models.SchedulerSM2, now)},
+	}
+	for i := range words {
+		words[i].ID = uuid.New()
+	}
+	SchedulerFor(models.SchedulerSM2).Schedule(&words[1].ReviewSchedule, models.GradeGood, now)
+
+	var buf bytes.Buffer
+	if err := WriteAnkiPackage(context.Background(), &buf, "Test Deck", words); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var collection []byte
+	for _, file := range archive.File {
+		if file.Name != "collection.anki2" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		collection, _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	if collection == nil {
+		t.Fatal("package has no collection.anki2")
+	}
+
+	path := filepath.Join(t.TempDir(), "collection.anki2")
+	if err := os.WriteFile(path, collection, 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var notes, reviewCards int
+	db.QueryRow("SELECT COUNT(*) FROM notes").Scan(&notes)
+	db.QueryRow("SELECT COUNT(*) FROM cards WHERE type = 2").Scan(&reviewCards)
+	if notes != 2 || reviewCards != 1 {
+		t.Errorf("notes = %d, review cards = %d, want 2 and 1", notes, reviewCards)
+	}
+}
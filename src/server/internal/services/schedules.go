@@ -0,0 +1,658 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/classius/server/internal/models"
+)
+
+// MaxScheduleAssignments limits the assignments of a reading schedule
+const MaxScheduleAssignments = 100
+
+// scheduleTolerance is the slack, in percentage points, before a member
+// counts as behind or an assignment as done
+const scheduleTolerance = 0.5
+
+// Where a member stands against a reading schedule
+const (
+	ScheduleBehind   = "behind"
+	ScheduleOnTrack  = "on_track"
+	ScheduleAhead    = "ahead"
+	ScheduleFinished = "finished"
+)
+
+// ScheduleService manages reading groups' schedules for the books on their
+// lists and compares members' progress against them
+type ScheduleService struct {
+	db *gorm.DB
+}
+
+// NewScheduleService creates a new schedule service
+func NewScheduleService(db *gorm.DB) *ScheduleService {
+	return &ScheduleService{db: db}
+}
+
+// AssignmentRequest ends an assignment at a chapter or a percentage of the
+// book, due on a date (YYYY-MM-DD, due by the end of the day UTC) or time
+type AssignmentRequest struct {
+	Title          string   `json:"title" binding:"max=255"`
+	Due            string   `json:"due"`
+	ThroughChapter *int     `json:"through_chapter"` // Index of the last chapter, for schedules by chapter
+	ThroughPercent *float64 `json:"through_percent"` // For schedules by percentage
+	Note           string   `json:"note"`
+}
+
+// SchedulePlan gives a schedule's assignments, or splits the book into
+// equal parts, the first due interval_days after starts_on
+type SchedulePlan struct {
+	Assignments  []AssignmentRequest `json:"assignments"`
+	Parts        int                 `json:"parts"`
+	StartsOn     string              `json:"starts_on"`
+	IntervalDays int                 `json:"interval_days"`
+}
+
+// ScheduleRequest creates a schedule for a book on the group's list
+type ScheduleRequest struct {
+	GroupBookID uuid.UUID `json:"group_book_id" binding:"required"`
+	Title       string    `json:"title" binding:"required,max=255"`
+	SplitBy     string    `json:"split_by" binding:"required,oneof=chapter percentage"`
+	SpoilerSafe *bool     `json:"spoiler_safe"` // Defaults to true
+	SchedulePlan
+}
+
+// UpdateScheduleRequest changes a schedule. A new plan replaces all of its
+// assignments.
+type UpdateScheduleRequest struct {
+	Title       *string `json:"title" binding:"omitempty,max=255"`
+	SpoilerSafe *bool   `json:"spoiler_safe"`
+	SchedulePlan
+}
+
+// ScheduleDetail is a schedule with where the group, and the viewer, are in it
+type ScheduleDetail struct {
+	models.ReadingSchedule
+	BookTitle         string                  `json:"book_title"`
+	ExpectedPercent   float64                 `json:"expected_percent"`             // Where members should be by now
+	CurrentAssignment *int                    `json:"current_assignment,omitempty"` // Position of the next assignment due
+	Me                *MemberScheduleProgress `json:"me,omitempty"`
+}
+
+// MemberScheduleProgress is a member's progress in their copy of a
+// schedule's book
+type MemberScheduleProgress struct {
+	UserID          uuid.UUID  `json:"user_id"`
+	Name            string     `json:"name"`
+	Username        string     `json:"username"`
+	Role            string     `json:"role"`
+	BookID          *uuid.UUID `json:"book_id,omitempty"` // Their copy, matched by ISBN or text
+	Percentage      float64    `json:"percentage"`
+	LastRead        *time.Time `json:"last_read,omitempty"`
+	AssignmentsDone int        `json:"assignments_done"`
+	Status          string     `json:"status"` // behind, on_track, ahead or finished
+}
+
+// ScheduleDashboard shows who in a group is on track with a schedule
+type ScheduleDashboard struct {
+	ScheduleID        uuid.UUID                `json:"schedule_id"`
+	ExpectedPercent   float64                  `json:"expected_percent"`
+	CurrentAssignment *int                     `json:"current_assignment,omitempty"`
+	Counts            map[string]int           `json:"counts"` // Members by status
+	Members           []MemberScheduleProgress `json:"members"`
+}
+
+// assignmentEnd is a parsed assignment request
+type assignmentEnd struct {
+	Title   string
+	Due     time.Time
+	Chapter int
+	Percent float64
+	Note    string
+}
+
+// Create plans a schedule for a book on the group's list. Moderators and
+// admins manage schedules.
+func (s *ScheduleService) Create(ctx context.Context, actorID, groupID uuid.UUID, req ScheduleRequest) (*ScheduleDetail, error) {
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return nil, fmt.Errorf("%w: title is required", ErrInvalid)
+	}
+	if req.SplitBy != models.ScheduleByChapter && req.SplitBy != models.ScheduleByPercentage {
+		return nil, fmt.Errorf("%w split_by %q", ErrInvalid, req.SplitBy)
+	}
+	schedule := models.ReadingSchedule{
+		GroupID:     groupID,
+		GroupBookID: req.GroupBookID,
+		CreatedBy:   actorID,
+		Title:       title,
+		SplitBy:     req.SplitBy,
+		SpoilerSafe: req.SpoilerSafe == nil || *req.SpoilerSafe,
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := requireGroupRole(tx, groupID, actorID, models.GroupRoleModerator); err != nil {
+			return err
+		}
+		var entry models.GroupBook
+		result := tx.Where("id = ? AND group_id = ?", req.GroupBookID, groupID).Limit(1).Find(&entry)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve group book: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("group book %w", ErrNotFound)
+		}
+		assignments, err := planSchedule(tx, actorID, &entry, schedule.SplitBy, req.SchedulePlan)
+		if err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Create(&schedule).Error; err != nil {
+			return fmt.Errorf("failed to create schedule: %w", err)
+		}
+		return saveAssignments(tx, schedule.ID, assignments)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, actorID, groupID, schedule.ID)
+}
+
+// List returns a group's schedules to its members
+func (s *ScheduleService) List(ctx context.Context, viewerID, groupID uuid.UUID) ([]ScheduleDetail, error) {
+	db := s.db.WithContext(ctx)
+	if _, err := requireGroupRole(db, groupID, viewerID, models.GroupRoleMember); err != nil {
+		return nil, err
+	}
+	var ids []uuid.UUID
+	if err := db.Model(&models.ReadingSchedule{}).Where("group_id = ?", groupID).
+		Order("created_at").Pluck("id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve schedules: %w", err)
+	}
+	schedules := make([]ScheduleDetail, 0, len(ids))
+	for _, id := range ids {
+		detail, err := s.detail(db, viewerID, groupID, id)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *detail)
+	}
+	return schedules, nil
+}
+
+// Get returns a schedule with the viewer's progress in it
+func (s *ScheduleService) Get(ctx context.Context, viewerID, groupID, scheduleID uuid.UUID) (*ScheduleDetail, error) {
+	db := s.db.WithContext(ctx)
+	if _, err := requireGroupRole(db, groupID, viewerID, models.GroupRoleMember); err != nil {
+		return nil, err
+	}
+	return s.detail(db, viewerID, groupID, scheduleID)
+}
+
+func (s *ScheduleService) detail(db *gorm.DB, viewerID, groupID, scheduleID uuid.UUID) (*ScheduleDetail, error) {
+	schedule, err := loadSchedule(db, groupID, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	detail := ScheduleDetail{ReadingSchedule: *schedule}
+	if err := db.Model(&models.GroupBook{}).Select("title").Where("id = ?", schedule.GroupBookID).
+		Scan(&detail.BookTitle).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve group book: %w", err)
+	}
+	now := time.Now()
+	var current int
+	detail.ExpectedPercent, current, _ = scheduleStanding(schedule.Assignments, 0, now)
+	if current >= 0 {
+		detail.CurrentAssignment = &current
+	}
+
+	members, err := scheduleProgress(db, schedule, &viewerID, now)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) > 0 {
+		detail.Me = &members[0]
+	}
+	return &detail, nil
+}
+
+// Progress compares every member's progress with a schedule
+func (s *ScheduleService) Progress(ctx context.Context, viewerID, groupID, scheduleID uuid.UUID) (*ScheduleDashboard, error) {
+	db := s.db.WithContext(ctx)
+	if _, err := requireGroupRole(db, groupID, viewerID, models.GroupRoleMember); err != nil {
+		return nil, err
+	}
+	schedule, err := loadSchedule(db, groupID, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	members, err := scheduleProgress(db, schedule, nil, now)
+	if err != nil {
+		return nil, err
+	}
+
+	dashboard := ScheduleDashboard{
+		ScheduleID: schedule.ID,
+		Counts: map[string]int{
+			ScheduleBehind: 0, ScheduleOnTrack: 0, ScheduleAhead: 0, ScheduleFinished: 0,
+		},
+		Members: members,
+	}
+	var current int
+	dashboard.ExpectedPercent, current, _ = scheduleStanding(schedule.Assignments, 0, now)
+	if current >= 0 {
+		dashboard.CurrentAssignment = &current
+	}
+	for _, member := range members {
+		dashboard.Counts[member.Status]++
+	}
+	return &dashboard, nil
+}
+
+// Update changes a schedule's settings or replans its assignments
+func (s *ScheduleService) Update(ctx context.Context, actorID, groupID, scheduleID uuid.UUID, req UpdateScheduleRequest) (*ScheduleDetail, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := requireGroupRole(tx, groupID, actorID, models.GroupRoleModerator); err != nil {
+			return err
+		}
+		var schedule models.ReadingSchedule
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND group_id = ?", scheduleID, groupID).Limit(1).Find(&schedule)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve schedule: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("schedule %w", ErrNotFound)
+		}
+
+		updates := make(map[string]interface{})
+		if req.Title != nil {
+			title := strings.TrimSpace(*req.Title)
+			if title == "" {
+				return fmt.Errorf("%w: title is required", ErrInvalid)
+			}
+			updates["title"] = title
+		}
+		if req.SpoilerSafe != nil {
+			updates["spoiler_safe"] = *req.SpoilerSafe
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&schedule).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to update schedule: %w", err)
+			}
+		}
+
+		if len(req.Assignments) == 0 && req.Parts == 0 {
+			return nil
+		}
+		var entry models.GroupBook
+		if err := tx.Where("id = ?", schedule.GroupBookID).First(&entry).Error; err != nil {
+			return fmt.Errorf("failed to retrieve group book: %w", err)
+		}
+		assignments, err := planSchedule(tx, actorID, &entry, schedule.SplitBy, req.SchedulePlan)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("schedule_id = ?", schedule.ID).Delete(&models.ScheduleAssignment{}).Error; err != nil {
+			return fmt.Errorf("failed to replace assignments: %w", err)
+		}
+		return saveAssignments(tx, schedule.ID, assignments)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, actorID, groupID, scheduleID)
+}
+
+// Delete deletes a schedule with its assignments
+func (s *ScheduleService) Delete(ctx context.Context, actorID, groupID, scheduleID uuid.UUID) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := requireGroupRole(tx, groupID, actorID, models.GroupRoleModerator); err != nil {
+			return err
+		}
+		if _, err := loadSchedule(tx, groupID, scheduleID); err != nil {
+			return err
+		}
+		return deleteSchedules(tx, []uuid.UUID{scheduleID})
+	})
+}
+
+// loadSchedule loads a group's schedule with its assignments in order
+func loadSchedule(db *gorm.DB, groupID, scheduleID uuid.UUID) (*models.ReadingSchedule, error) {
+	var schedule models.ReadingSchedule
+	result := db.Preload("Assignments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Where("id = ? AND group_id = ?", scheduleID, groupID).Limit(1).Find(&schedule)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve schedule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("schedule %w", ErrNotFound)
+	}
+	return &schedule, nil
+}
+
+// deleteSchedules deletes schedules, given by IDs or a subquery of them,
+// with their assignments
+func deleteSchedules(tx *gorm.DB, ids interface{}) error {
+	if err := tx.Where("schedule_id IN ?", ids).Delete(&models.ScheduleAssignment{}).Error; err != nil {
+		return fmt.Errorf("failed to delete schedule assignments: %w", err)
+	}
+	if err := tx.Where("id IN ?", ids).Delete(&models.ReadingSchedule{}).Error; err != nil {
+		return fmt.Errorf("failed to delete schedules: %w", err)
+	}
+	return nil
+}
+
+// saveAssignments creates a schedule's assignments
+func saveAssignments(tx *gorm.DB, scheduleID uuid.UUID, assignments []models.ScheduleAssignment) error {
+	for i := range assignments {
+		assignments[i].ScheduleID = scheduleID
+	}
+	if err := tx.Create(&assignments).Error; err != nil {
+		return fmt.Errorf("failed to create assignments: %w", err)
+	}
+	return nil
+}
+
+// planSchedule turns a plan into assignments. Schedules by chapter split
+// the text of the actor's copy of the book, or else the entry's own book.
+func planSchedule(tx *gorm.DB, actorID uuid.UUID, entry *models.GroupBook, splitBy string, plan SchedulePlan) ([]models.ScheduleAssignment, error) {
+	var chapters []Chapter
+	var textLen int
+	if splitBy == models.ScheduleByChapter {
+		var err error
+		if chapters, textLen, err = scheduleText(tx, actorID, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	var ends []assignmentEnd
+	switch {
+	case len(plan.Assignments) > 0:
+		if len(plan.Assignments) > MaxScheduleAssignments {
+			return nil, fmt.Errorf("%w assignments: a schedule has at most %d", ErrInvalid, MaxScheduleAssignments)
+		}
+		for i, req := range plan.Assignments {
+			due, err := parseDueDate(req.Due)
+			if err != nil {
+				return nil, fmt.Errorf("%w assignment %d: %w", ErrInvalid, i+1, err)
+			}
+			end := assignmentEnd{Title: strings.TrimSpace(req.Title), Due: due, Note: strings.TrimSpace(req.Note)}
+			switch {
+			case splitBy == models.ScheduleByChapter && req.ThroughChapter != nil:
+				end.Chapter = *req.ThroughChapter
+			case splitBy == models.ScheduleByPercentage && req.ThroughPercent != nil:
+				end.Percent = *req.ThroughPercent
+			default:
+				return nil, fmt.Errorf("%w assignment %d: through_%s is required", ErrInvalid, i+1, map[string]string{
+					models.ScheduleByChapter: "chapter", models.ScheduleByPercentage: "percent"}[splitBy])
+			}
+			ends = append(ends, end)
+		}
+	case plan.Parts > 0:
+		if plan.Parts > MaxScheduleAssignments {
+			return nil, fmt.Errorf("%w parts: a schedule has at most %d assignments", ErrInvalid, MaxScheduleAssignments)
+		}
+		if plan.IntervalDays < 1 {
+			return nil, fmt.Errorf("%w interval_days: splitting into parts requires at least 1", ErrInvalid)
+		}
+		startsOn, err := time.Parse("2006-01-02", plan.StartsOn)
+		if err != nil {
+			return nil, fmt.Errorf("%w starts_on: use YYYY-MM-DD", ErrInvalid)
+		}
+		if ends, err = equalParts(splitBy, chapters, plan.Parts, startsOn, plan.IntervalDays); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: assignments or parts is required", ErrInvalid)
+	}
+	return buildAssignments(splitBy, chapters, textLen, ends)
+}
+
+// scheduleText splits the text of the actor's copy of a group book, or of
+// the entry's own book, into chapters
+func scheduleText(tx *gorm.DB, actorID uuid.UUID, entry *models.GroupBook) ([]Chapter, int, error) {
+	var copyID *uuid.UUID
+	if err := tx.Raw("SELECT ("+fmt.Sprintf(groupBookCopySQL, "?")+") FROM group_books gb WHERE gb.id = ?",
+		actorID, entry.ID).Scan(&copyID).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve book: %w", err)
+	}
+	for _, bookID := range []*uuid.UUID{copyID, entry.BookID} {
+		if bookID == nil {
+			continue
+		}
+		var content models.BookContent
+		result := tx.Where("book_id = ?", *bookID).Limit(1).Find(&content)
+		if result.Error != nil {
+			return nil, 0, fmt.Errorf("failed to retrieve book content: %w", result.Error)
+		}
+		if result.RowsAffected > 0 && content.FullText != "" {
+			return DetectChapters(content.FullText), utf8.RuneCountInString(content.FullText), nil
+		}
+	}
+	return nil, 0, fmt.Errorf("%w split: the book has no extracted text to find chapters in, so split by percentage", ErrInvalid)
+}
+
+// parseDueDate parses a due date, due by the end of the day UTC, or time
+func parseDueDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, fmt.Errorf("%w: due is required", ErrInvalid)
+	}
+	if day, err := time.Parse("2006-01-02", s); err == nil {
+		return day.Add(24*time.Hour - time.Second), nil
+	}
+	due, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w due %q: use YYYY-MM-DD or RFC 3339", ErrInvalid, s)
+	}
+	return due.UTC(), nil
+}
+
+// equalParts splits a book into parts of about equal length, by chapter or
+// percentage, the first due intervalDays after startsOn
+func equalParts(splitBy string, chapters []Chapter, parts int, startsOn time.Time, intervalDays int) ([]assignmentEnd, error) {
+	if splitBy == models.ScheduleByChapter && parts > len(chapters) {
+		return nil, fmt.Errorf("%w parts: the book has only %d chapters", ErrInvalid, len(chapters))
+	}
+	textLen := 0
+	if len(chapters) > 0 {
+		textLen = chapters[len(chapters)-1].End
+	}
+
+	ends := make([]assignmentEnd, parts)
+	last := -1
+	for k := 1; k <= parts; k++ {
+		end := &ends[k-1]
+		end.Due = startsOn.AddDate(0, 0, k*intervalDays).Add(24*time.Hour - time.Second)
+		if splitBy == models.ScheduleByPercentage {
+			end.Percent = float64(100*k) / float64(parts)
+			continue
+		}
+		// The first chapter ending past an equal share, leaving a chapter
+		// for each part still to come
+		target := textLen * k / parts
+		i := last + 1
+		for i < len(chapters)-1 && chapters[i].End < target {
+			i++
+		}
+		if limit := len(chapters) - 1 - (parts - k); i > limit {
+			i = limit
+		}
+		end.Chapter, last = i, i
+	}
+	return ends, nil
+}
+
+// buildAssignments turns assignment ends into assignments, each starting
+// where the previous one ended
+func buildAssignments(splitBy string, chapters []Chapter, textLen int, ends []assignmentEnd) ([]models.ScheduleAssignment, error) {
+	if len(ends) == 0 {
+		return nil, fmt.Errorf("%w: assignments or parts is required", ErrInvalid)
+	}
+	assignments := make([]models.ScheduleAssignment, 0, len(ends))
+	prevPercent, prevChapter := 0.0, -1
+	for i, end := range ends {
+		if i > 0 && end.Due.Before(ends[i-1].Due) {
+			return nil, fmt.Errorf("%w assignment %d: due dates must not go backwards", ErrInvalid, i+1)
+		}
+		assignment := models.ScheduleAssignment{
+			Position:     i,
+			Title:        end.Title,
+			DueAt:        end.Due,
+			StartPercent: prevPercent,
+			Note:         end.Note,
+		}
+		switch splitBy {
+		case models.ScheduleByChapter:
+			if end.Chapter <= prevChapter || end.Chapter >= len(chapters) {
+				return nil, fmt.Errorf("%w assignment %d: chapters must advance within the book's %d", ErrInvalid, i+1, len(chapters))
+			}
+			first, last := prevChapter+1, end.Chapter
+			assignment.FirstChapter, assignment.LastChapter = &first, &last
+			assignment.EndPercent = float64(chapters[last].End) * 100 / float64(textLen)
+			if assignment.Title == "" {
+				assignment.Title = chapters[first].Title
+				if last > first {
+					assignment.Title += " – " + chapters[last].Title
+				}
+			}
+			prevChapter = last
+		default:
+			if end.Percent <= prevPercent || end.Percent > 100 {
+				return nil, fmt.Errorf("%w assignment %d: percentages must advance up to 100", ErrInvalid, i+1)
+			}
+			assignment.EndPercent = end.Percent
+			if assignment.Title == "" {
+				assignment.Title = fmt.Sprintf("To %.4g%%", end.Percent)
+			}
+		}
+		if utf8.RuneCountInString(assignment.Title) > 255 {
+			assignment.Title = string([]rune(assignment.Title)[:255])
+		}
+		prevPercent = assignment.EndPercent
+		assignments = append(assignments, assignment)
+	}
+	return assignments, nil
+}
+
+// scheduleStanding returns where a reader should be in a schedule by now,
+// the position of the next assignment due (-1 once all are) and where a
+// reader at percent stands
+func scheduleStanding(assignments []models.ScheduleAssignment, percent float64, now time.Time) (float64, int, string) {
+	expected, current := 0.0, -1
+	for _, assignment := range assignments {
+		if assignment.DueAt.After(now) {
+			current = assignment.Position
+			break
+		}
+		expected = assignment.EndPercent
+	}
+
+	status := ScheduleOnTrack
+	switch {
+	case len(assignments) > 0 && percent+scheduleTolerance >= assignments[len(assignments)-1].EndPercent:
+		status = ScheduleFinished
+	case percent+scheduleTolerance < expected:
+		status = ScheduleBehind
+	case current >= 0 && percent+scheduleTolerance >= assignments[current].EndPercent:
+		status = ScheduleAhead
+	}
+	return expected, current, status
+}
+
+// assignmentsDone counts the assignments a reader at percent has finished
+func assignmentsDone(assignments []models.ScheduleAssignment, percent float64) int {
+	done := 0
+	for _, assignment := range assignments {
+		if percent+scheduleTolerance >= assignment.EndPercent {
+			done++
+		}
+	}
+	return done
+}
+
+// memberProgressSQL selects each member's latest progress in their copy of
+// a group book
+const memberProgressSQL = `SELECT gm.user_id, COALESCE(NULLIF(u.full_name, ''), u.username) AS name,
+	u.username, gm.role, mc.id AS book_id, COALESCE(rp.percentage, 0) AS percentage, rp.last_read
+	FROM group_members gm
+	JOIN users u ON u.id = gm.user_id
+	JOIN group_books gb ON gb.id = ?
+	LEFT JOIN LATERAL (` + groupBookCopySQL + `) mc ON TRUE
+	LEFT JOIN LATERAL (SELECT p.percentage, p.last_read FROM reading_progress p
+		WHERE p.user_id = gm.user_id AND p.book_id = mc.id AND p.deleted_at IS NULL
+		ORDER BY p.updated_at DESC LIMIT 1) rp ON TRUE
+	WHERE gm.group_id = ? AND gm.deleted_at IS NULL`
+
+// scheduleProgress compares members' progress, or one member's, with a
+// schedule
+func scheduleProgress(db *gorm.DB, schedule *models.ReadingSchedule, userID *uuid.UUID, now time.Time) ([]MemberScheduleProgress, error) {
+	query := fmt.Sprintf(memberProgressSQL, "gm.user_id")
+	args := []interface{}{schedule.GroupBookID, schedule.GroupID}
+	if userID != nil {
+		query += " AND gm.user_id = ?"
+		args = append(args, *userID)
+	}
+	members := []MemberScheduleProgress{}
+	if err := db.Raw(query+" ORDER BY name", args...).Scan(&members).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve reading progress: %w", err)
+	}
+	for i := range members {
+		_, _, members[i].Status = scheduleStanding(schedule.Assignments, members[i].Percentage, now)
+		members[i].AssignmentsDone = assignmentsDone(schedule.Assignments, members[i].Percentage)
+	}
+	return members, nil
+}
+
+// spoilerGate is where a member is in the book of a spoiler-safe schedule
+type spoilerGate struct {
+	GroupID         uuid.UUID
+	BookID          *uuid.UUID
+	ISBN            string
+	TextFingerprint string
+	Percentage      float64
+}
+
+// spoilerFilter builds the condition hiding group threads anchored beyond
+// where the viewer is in a book their group reads to a spoiler-safe
+// schedule. Threads of their own are never hidden.
+func spoilerFilter(db *gorm.DB, viewerID uuid.UUID) (string, []interface{}, error) {
+	var gates []spoilerGate
+	if err := db.Raw(`SELECT DISTINCT rs.group_id, gb.book_id, gb.isbn, gb.text_fingerprint,
+			COALESCE(rp.percentage, 0) AS percentage
+		FROM reading_schedules rs
+		JOIN group_books gb ON gb.id = rs.group_book_id AND gb.deleted_at IS NULL
+		JOIN group_members gm ON gm.group_id = rs.group_id AND gm.user_id = ? AND gm.deleted_at IS NULL
+		LEFT JOIN LATERAL (`+fmt.Sprintf(groupBookCopySQL, "gm.user_id")+`) mc ON TRUE
+		LEFT JOIN LATERAL (SELECT p.percentage FROM reading_progress p
+			WHERE p.user_id = gm.user_id AND p.book_id = mc.id AND p.deleted_at IS NULL
+			ORDER BY p.updated_at DESC LIMIT 1) rp ON TRUE
+		WHERE rs.spoiler_safe AND rs.deleted_at IS NULL`, viewerID).Scan(&gates).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to retrieve reading schedules: %w", err)
+	}
+	condition, args := spoilerCondition(gates, viewerID)
+	return condition, args, nil
+}
+
+// spoilerCondition builds the condition for spoilerFilter from the gates
+func spoilerCondition(gates []spoilerGate, viewerID uuid.UUID) (string, []interface{}) {
+	if len(gates) == 0 {
+		return "", nil
+	}
+	conditions := make([]string, 0, len(gates))
+	var args []interface{}
+	for _, gate := range gates {
+		conditions = append(conditions, `NOT (d.group_id = ? AND d.user_id <> ? AND d.anchor_percent > ? AND
+			(d.book_id = ? OR (? <> '' AND d.book_isbn = ?) OR (? <> '' AND d.book_fingerprint = ?)))`)
+		args = append(args, gate.GroupID, viewerID, gate.Percentage, gate.BookID,
+			gate.ISBN, gate.ISBN, gate.TextFingerprint, gate.TextFingerprint)
+	}
+	return strings.Join(conditions, " AND "), args
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestParseDueDate(t *testing.T) {
+	due, err := parseDueDate("2026-03-06")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 3, 6, 23, 59, 59, 0, time.UTC); !due.Equal(want) {
+		t.Errorf("date due = %v, want %v", due, want)
+	}
+	due, err = parseDueDate("2026-03-06T18:00:00+02:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 3, 6, 16, 0, 0, 0, time.UTC); !due.Equal(want) {
+		t.Errorf("time due = %v, want %v", due, want)
+	}
+	for _, bad := range []string{"", "Friday", "06/03/2026"} {
+		if _, err := parseDueDate(bad); err == nil {
+			t.Errorf("parseDueDate(%q) should fail", bad)
+		}
+	}
+}
+
+func TestEqualParts(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	ends, err := equalParts(models.ScheduleByPercentage, nil, 4, start, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ends) != 4 || ends[0].Percent != 25 || ends[3].Percent != 100 {
+		t.Errorf("percentage parts = %+v", ends)
+	}
+	if ends[0].Due.Day() != 8 || ends[1].Due.Day() != 15 {
+		t.Errorf("due dates = %v, %v", ends[0].Due, ends[1].Due)
+	}
+
+	// A long first chapter still leaves one chapter for each later part
+	chapters := []Chapter{{0, "I", 0, 900}, {1, "II", 900, 950}, {2, "III", 950, 980}, {3, "IV", 980, 1000}}
+	ends, err = equalParts(models.ScheduleByChapter, chapters, 3, start, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, end := range ends {
+		got = append(got, end.Chapter)
+	}
+	if len(got) != 3 || got[0] != 0 || got[1] != 1 || got[2] != 3 {
+		t.Errorf("chapter parts = %v, want [0 1 3]", got)
+	}
+
+	if _, err := equalParts(models.ScheduleByChapter, chapters, 5, start, 7); err == nil {
+		t.Error("expected an error for more parts than chapters")
+	}
+}
+
+func TestBuildAssignments(t *testing.T) {
+	day := time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)
+	chapters := []Chapter{{0, "Book 1", 0, 250}, {1, "Book 2", 250, 500}, {2, "Book 3", 500, 750}, {3, "Book 4", 750, 1000}}
+
+	assignments, err := buildAssignments(models.ScheduleByChapter, chapters, 1000, []assignmentEnd{
+		{Due: day, Chapter: 1},
+		{Due: day.AddDate(0, 0, 7), Chapter: 3, Title: "The rest"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assignments[0].Title != "Book 1 – Book 2" || assignments[0].StartPercent != 0 || assignments[0].EndPercent != 50 {
+		t.Errorf("first = %+v", assignments[0])
+	}
+	if assignments[1].Title != "The rest" || *assignments[1].FirstChapter != 2 || assignments[1].StartPercent != 50 || assignments[1].EndPercent != 100 {
+		t.Errorf("second = %+v", assignments[1])
+	}
+
+	tests := []struct {
+		name    string
+		splitBy string
+		ends    []assignmentEnd
+	}{
+		{"no assignments", models.ScheduleByPercentage, nil},
+		{"chapters going back", models.ScheduleByChapter, []assignmentEnd{{Due: day, Chapter: 2}, {Due: day, Chapter: 1}}},
+		{"chapter past the end", models.ScheduleByChapter, []assignmentEnd{{Due: day, Chapter: 4}}},
+		{"percentage going back", models.ScheduleByPercentage, []assignmentEnd{{Due: day, Percent: 50}, {Due: day, Percent: 50}}},
+		{"percentage past 100", models.ScheduleByPercentage, []assignmentEnd{{Due: day, Percent: 120}}},
+		{"due dates going back", models.ScheduleByPercentage, []assignmentEnd{{Due: day, Percent: 50}, {Due: day.AddDate(0, 0, -1), Percent: 100}}},
+	}
+	for _, tt := range tests {
+		if _, err := buildAssignments(tt.splitBy, chapters, 1000, tt.ends); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestScheduleStanding(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	assignments := []models.ScheduleAssignment{
+		{Position: 0, DueAt: now.AddDate(0, 0, -3), EndPercent: 25},
+		{Position: 1, DueAt: now.AddDate(0, 0, 4), EndPercent: 50},
+		{Position: 2, DueAt: now.AddDate(0, 0, 11), EndPercent: 100},
+	}
+	tests := []struct {
+		percent float64
+		status  string
+	}{
+		{10, ScheduleBehind},
+		{24.8, ScheduleOnTrack},
+		{40, ScheduleOnTrack},
+		{50, ScheduleAhead},
+		{99.6, ScheduleFinished},
+	}
+	for _, tt := range tests {
+		expected, current, status := scheduleStanding(assignments, tt.percent, now)
+		if expected != 25 || current != 1 {
+			t.Errorf("expected, current = %v, %d", expected, current)
+		}
+		if status != tt.status {
+			t.Errorf("at %v%%: status = %q, want %q", tt.percent, status, tt.status)
+		}
+	}
+
+	expected, current, status := scheduleStanding(assignments, 60, now.AddDate(0, 1, 0))
+	if expected != 100 || current != -1 || status != ScheduleBehind {
+		t.Errorf("after the schedule: %v, %d, %q", expected, current, status)
+	}
+	if done := assignmentsDone(assignments, 60); done != 2 {
+		t.Errorf("assignmentsDone = %d, want 2", done)
+	}
+}
+
+func TestSpoilerCondition(t *testing.T) {
+	if condition, args := spoilerCondition(nil, uuid.New()); condition != "" || args != nil {
+		t.Errorf("no gates = %q, %v", condition, args)
+	}
+	gates := []spoilerGate{{GroupID: uuid.New(), ISBN: "9780140268867", Percentage: 30}, {GroupID: uuid.New()}}
+	condition, args := spoilerCondition(gates, uuid.New())
+	if strings.Count(condition, "NOT (") != 2 || strings.Count(condition, "?") != len(args) {
+		t.Errorf("condition %q with %d args", condition, len(args))
+	}
+}
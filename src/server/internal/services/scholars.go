@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/classius/server/internal/models"
+)
+
+// MaxEvidenceURLs bounds the links an application can cite
+const MaxEvidenceURLs = 10
+
+// ScholarService runs the verified scholar program: users apply with
+// credentials and admins approve, reject or later revoke verification
+type ScholarService struct {
+	db *gorm.DB
+}
+
+// NewScholarService creates a new scholar service
+func NewScholarService(db *gorm.DB) *ScholarService {
+	return &ScholarService{db: db}
+}
+
+// ScholarApplicationRequest applies for verification
+type ScholarApplicationRequest struct {
+	FullName     string   `json:"full_name" binding:"max=255"` // Defaults to the profile's name
+	Affiliation  string   `json:"affiliation" binding:"max=255"`
+	Field        string   `json:"field" binding:"required,max=255"`
+	Credentials  string   `json:"credentials" binding:"required"`
+	EvidenceURLs []string `json:"evidence_urls"`
+	ORCID        string   `json:"orcid"`
+}
+
+// ScholarApplicationView is an application in the admins' queue
+type ScholarApplicationView struct {
+	models.ScholarApplication
+	Username string `json:"username"`
+}
+
+// ScholarProfile is a user's public scholar standing
+type ScholarProfile struct {
+	UserID          uuid.UUID  `json:"user_id"`
+	Username        string     `json:"username"`
+	FullName        string     `json:"full_name"`
+	AvatarURL       string     `json:"avatar_url"`
+	VerifiedScholar bool       `json:"verified_scholar"`
+	Field           string     `json:"field,omitempty"`
+	Affiliation     string     `json:"affiliation,omitempty"`
+	VerifiedAt      *time.Time `json:"verified_at,omitempty"`
+	PublishedNotes  int64      `json:"published_notes"`
+}
+
+// ReviewAuditView is an audit entry with the name of who made the decision
+type ReviewAuditView struct {
+	models.ReviewAuditEntry
+	ActorName string `json:"actor_name"`
+}
+
+// Apply submits an application for verification. Users can have one
+// application under review at a time.
+func (s *ScholarService) Apply(ctx context.Context, userID uuid.UUID, req ScholarApplicationRequest) (*models.ScholarApplication, error) {
+	application := models.ScholarApplication{
+		UserID:      userID,
+		Status:      models.ApplicationSubmitted,
+		FullName:    strings.TrimSpace(req.FullName),
+		Affiliation: strings.TrimSpace(req.Affiliation),
+		Field:       strings.TrimSpace(req.Field),
+		Credentials: strings.TrimSpace(req.Credentials),
+		ORCID:       strings.ToUpper(strings.TrimSpace(req.ORCID)),
+	}
+	if application.Field == "" || application.Credentials == "" {
+		return nil, fmt.Errorf("%w: field and credentials are required", ErrInvalid)
+	}
+	if application.ORCID != "" && !validORCID(application.ORCID) {
+		return nil, fmt.Errorf("%w ORCID iD %q", ErrInvalid, req.ORCID)
+	}
+	evidence, err := cleanEvidenceURLs(req.EvidenceURLs)
+	if err != nil {
+		return nil, err
+	}
+	application.EvidenceURLs = evidence
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", userID).First(&user).Error; err != nil {
+			return fmt.Errorf("failed to retrieve user: %w", err)
+		}
+		if user.VerifiedScholar {
+			return fmt.Errorf("%w: already verified", ErrConflict)
+		}
+		var open int64
+		if err := tx.Model(&models.ScholarApplication{}).
+			Where("user_id = ? AND status = ?", userID, models.ApplicationSubmitted).Count(&open).Error; err != nil {
+			return fmt.Errorf("failed to retrieve applications: %w", err)
+		}
+		if open > 0 {
+			return fmt.Errorf("%w: an application is already under review", ErrConflict)
+		}
+		if application.FullName == "" {
+			application.FullName = user.FullName
+		}
+		if application.FullName == "" {
+			return fmt.Errorf("%w: full name is required", ErrInvalid)
+		}
+
+		if err := tx.Create(&application).Error; err != nil {
+			return fmt.Errorf("failed to submit application: %w", err)
+		}
+		return recordReviewDecision(tx, models.AuditScholarApplication, application.ID, userID,
+			"submitted", "", models.ApplicationSubmitted, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &application, nil
+}
+
+// Applications lists the user's applications, newest first
+func (s *ScholarService) Applications(ctx context.Context, userID uuid.UUID) ([]models.ScholarApplication, error) {
+	applications := []models.ScholarApplication{}
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).
+		Order("created_at DESC").Find(&applications).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve applications: %w", err)
+	}
+	return applications, nil
+}
+
+// Withdraw withdraws the user's application before it is reviewed
+func (s *ScholarService) Withdraw(ctx context.Context, userID, applicationID uuid.UUID) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		application, err := lockApplication(tx, applicationID)
+		if err != nil {
+			return err
+		}
+		if application.UserID != userID {
+			return fmt.Errorf("application %w", ErrNotFound)
+		}
+		if application.Status != models.ApplicationSubmitted {
+			return fmt.Errorf("%w status: the application is %s", ErrInvalid, application.Status)
+		}
+		if err := tx.Model(application).Update("status", models.ApplicationWithdrawn).Error; err != nil {
+			return fmt.Errorf("failed to withdraw application: %w", err)
+		}
+		return recordReviewDecision(tx, models.AuditScholarApplication, application.ID, userID,
+			"withdrawn", models.ApplicationSubmitted, models.ApplicationWithdrawn, "")
+	})
+}
+
+// Queue lists applications with a status, submitted by default, oldest
+// first
+func (s *ScholarService) Queue(ctx context.Context, status string, limit, offset int) ([]ScholarApplicationView, int64, error) {
+	if status == "" {
+		status = models.ApplicationSubmitted
+	}
+	query := s.db.WithContext(ctx).Table("scholar_applications a").
+		Joins("JOIN users u ON u.id = a.user_id").
+		Where("a.status = ? AND a.deleted_at IS NULL", status)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count applications: %w", err)
+	}
+	applications := []ScholarApplicationView{}
+	if err := query.Select("a.*, u.username").Order("a.created_at").
+		Limit(limit).Offset(offset).Scan(&applications).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve applications: %w", err)
+	}
+	return applications, total, nil
+}
+
+// Decide approves or rejects an application under review. Rejections must
+// say why; approval verifies the applicant and their notes.
+func (s *ScholarService) Decide(ctx context.Context, adminID, applicationID uuid.UUID, approve bool, comment string) (*models.ScholarApplication, error) {
+	comment = strings.TrimSpace(comment)
+	if !approve && comment == "" {
+		return nil, fmt.Errorf("%w: comment required: explain why the application is rejected", ErrInvalid)
+	}
+	var application *models.ScholarApplication
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		application, err = lockApplication(tx, applicationID)
+		if err != nil {
+			return err
+		}
+		if application.UserID == adminID {
+			return fmt.Errorf("%w: admins cannot review their own application", ErrAccessDenied)
+		}
+		if application.Status != models.ApplicationSubmitted {
+			return fmt.Errorf("%w status: the application is %s", ErrInvalid, application.Status)
+		}
+
+		now := time.Now()
+		status, action := models.ApplicationRejected, "rejected"
+		if approve {
+			status, action = models.ApplicationApproved, "approved"
+		}
+		if err := tx.Model(application).Updates(map[string]interface{}{
+			"status":         status,
+			"reviewer_id":    adminID,
+			"review_comment": comment,
+			"reviewed_at":    now,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update application: %w", err)
+		}
+		application.Status, application.ReviewComment, application.ReviewedAt = status, comment, &now
+
+		if approve {
+			if err := tx.Model(&models.User{}).Where("id = ?", application.UserID).Updates(map[string]interface{}{
+				"verified_scholar":    true,
+				"scholar_field":       application.Field,
+				"scholar_affiliation": application.Affiliation,
+				"verified_at":         now,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to verify user: %w", err)
+			}
+			if err := setNotesVerified(tx, application.UserID, true); err != nil {
+				return err
+			}
+		}
+		return recordReviewDecision(tx, models.AuditScholarApplication, application.ID, adminID,
+			action, models.ApplicationSubmitted, status, comment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return application, nil
+}
+
+// Revoke withdraws a scholar's verification, with a reason
+func (s *ScholarService) Revoke(ctx context.Context, adminID, userID uuid.UUID, comment string) error {
+	comment = strings.TrimSpace(comment)
+	if comment == "" {
+		return fmt.Errorf("%w: comment required: explain why verification is revoked", ErrInvalid)
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", userID).Limit(1).Find(&user)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve user: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("user %w", ErrNotFound)
+		}
+		if !user.VerifiedScholar {
+			return fmt.Errorf("%w status: the user is not a verified scholar", ErrInvalid)
+		}
+
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"verified_scholar":    false,
+			"scholar_field":       "",
+			"scholar_affiliation": "",
+			"verified_at":         nil,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to revoke verification: %w", err)
+		}
+		if err := setNotesVerified(tx, userID, false); err != nil {
+			return err
+		}
+
+		var application models.ScholarApplication
+		result = tx.Where("user_id = ? AND status = ?", userID, models.ApplicationApproved).
+			Order("reviewed_at DESC").Limit(1).Find(&application)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve application: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		if err := tx.Model(&application).Updates(map[string]interface{}{
+			"status":         models.ApplicationRevoked,
+			"reviewer_id":    adminID,
+			"review_comment": comment,
+			"reviewed_at":    time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update application: %w", err)
+		}
+		return recordReviewDecision(tx, models.AuditScholarApplication, application.ID, adminID,
+			"revoked", models.ApplicationApproved, models.ApplicationRevoked, comment)
+	})
+}
+
+// Profile returns a user's scholar standing
+func (s *ScholarService) Profile(ctx context.Context, userID uuid.UUID) (*ScholarProfile, error) {
+	db := s.db.WithContext(ctx)
+	var user models.User
+	result := db.Where("id = ?", userID).Limit(1).Find(&user)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve user: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("user %w", ErrNotFound)
+	}
+
+	profile := &ScholarProfile{
+		UserID:          user.ID,
+		Username:        user.Username,
+		FullName:        user.FullName,
+		AvatarURL:       user.AvatarURL,
+		VerifiedScholar: user.VerifiedScholar,
+		Field:           user.ScholarField,
+		Affiliation:     user.ScholarAffiliation,
+		VerifiedAt:      user.VerifiedAt,
+	}
+	if err := db.Model(&models.PublishedNote{}).
+		Where("author_id = ? AND status = ?", userID, models.NotePublished).
+		Count(&profile.PublishedNotes).Error; err != nil {
+		return nil, fmt.Errorf("failed to count published notes: %w", err)
+	}
+	return profile, nil
+}
+
+// AuditTrail lists review decisions, on scholar applications and note
+// versions, newest first. subjectType and subjectID narrow it when set.
+func (s *ScholarService) AuditTrail(ctx context.Context, subjectType string, subjectID *uuid.UUID, limit, offset int) ([]ReviewAuditView, int64, error) {
+	query := s.db.WithContext(ctx).Table("review_audit_entries r").
+		Joins("JOIN users u ON u.id = r.actor_id").
+		Where("r.deleted_at IS NULL")
+	if subjectType != "" {
+		query = query.Where("r.subject_type = ?", subjectType)
+	}
+	if subjectID != nil {
+		query = query.Where("r.subject_id = ?", *subjectID)
+	}
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+	entries := []ReviewAuditView{}
+	if err := query.Select("r.*, COALESCE(NULLIF(u.full_name, ''), u.username) AS actor_name").
+		Order("r.created_at DESC").Limit(limit).Offset(offset).Scan(&entries).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve audit entries: %w", err)
+	}
+	return entries, total, nil
+}
+
+// recordReviewDecision adds a decision to the review audit trail
+func recordReviewDecision(tx *gorm.DB, subjectType string, subjectID, actorID uuid.UUID, action, from, to, comment string) error {
+	entry := models.ReviewAuditEntry{
+		SubjectType: subjectType,
+		SubjectID:   subjectID,
+		ActorID:     actorID,
+		Action:      action,
+		FromStatus:  from,
+		ToStatus:    to,
+		Comment:     comment,
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return fmt.Errorf("failed to record review decision: %w", err)
+	}
+	return nil
+}
+
+// lockApplication loads an application for update
+func lockApplication(tx *gorm.DB, applicationID uuid.UUID) (*models.ScholarApplication, error) {
+	var application models.ScholarApplication
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", applicationID).Limit(1).Find(&application)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve application: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("application %w", ErrNotFound)
+	}
+	return &application, nil
+}
+
+// setNotesVerified marks an author's notes as by a verified scholar, or not
+func setNotesVerified(tx *gorm.DB, authorID uuid.UUID, verified bool) error {
+	if err := tx.Model(&models.PublishedNote{}).Where("author_id = ?", authorID).
+		Update("is_verified", verified).Error; err != nil {
+		return fmt.Errorf("failed to update published notes: %w", err)
+	}
+	return nil
+}
+
+// cleanEvidenceURLs checks that evidence links are absolute http(s) URLs
+func cleanEvidenceURLs(urls []string) ([]string, error) {
+	if len(urls) > MaxEvidenceURLs {
+		return nil, fmt.Errorf("%w evidence: at most %d links", ErrInvalid, MaxEvidenceURLs)
+	}
+	cleaned := make([]string, 0, len(urls))
+	for _, raw := range urls {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("%w evidence link %q", ErrInvalid, raw)
+		}
+		cleaned = append(cleaned, u.String())
+	}
+	return cleaned, nil
+}
+
+// validORCID checks an ORCID iD's format and ISO 7064 11-2 check digit
+func validORCID(id string) bool {
+	if len(id) != 19 {
+		return false
+	}
+	total := 0
+	for i, r := range id {
+		switch {
+		case i == 4 || i == 9 || i == 14:
+			if r != '-' {
+				return false
+			}
+		case i == 18:
+			check := (12 - total%11) % 11
+			if check == 10 {
+				return r == 'X'
+			}
+			return r == rune('0'+check)
+		case r >= '0' && r <= '9':
+			total = (total + int(r-'0')) * 2
+		default:
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestValidORCID(t *testing.T) {
+	for _, id := range []string{"0000-0002-1825-0097", "0000-0001-5109-3700", "0000-0002-1694-233X"} {
+		if !validORCID(id) {
+			t.Errorf("%s rejected", id)
+		}
+	}
+	for _, id := range []string{"", "0000-0002-1825-0098", "0000000218250097", "0000-0002-1825-009", "0000-0002-1694-2330", "0000-000a-1825-0097"} {
+		if validORCID(id) {
+			t.Errorf("%q accepted", id)
+		}
+	}
+}
+
+func TestCleanEvidenceURLs(t *testing.T) {
+	urls, err := cleanEvidenceURLs([]string{" https://example.edu/faculty/plato ", "", "http://example.org/cv.pdf"})
+	if err != nil || len(urls) != 2 || urls[0] != "https://example.edu/faculty/plato" {
+		t.Errorf("got %v, %v", urls, err)
+	}
+	for _, bad := range []string{"javascript:alert(1)", "example.edu/cv", "ftp://example.edu/cv", "https://"} {
+		if _, err := cleanEvidenceURLs([]string{bad}); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+	if _, err := cleanEvidenceURLs(make([]string, MaxEvidenceURLs+1)); err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Errorf("too many links: got %v", err)
+	}
+}
+
+func TestNeedsReview(t *testing.T) {
+	s := NewPublishedNoteService(nil, 100)
+	tests := []struct {
+		price, downloads int
+		want             bool
+	}{
+		{0, 0, false},
+		{0, 99, false},
+		{0, 100, true},
+		{500, 0, true},
+	}
+	for _, tt := range tests {
+		if got := s.needsReview(&models.PublishedNote{PriceCents: tt.price, DownloadsCount: tt.downloads}); got != tt.want {
+			t.Errorf("price %d, downloads %d: got %v", tt.price, tt.downloads, got)
+		}
+	}
+	if NewPublishedNoteService(nil, 0).needsReview(&models.PublishedNote{DownloadsCount: 1000000}) {
+		t.Errorf("a zero threshold reviewed a free note")
+	}
+}
@@ -0,0 +1,623 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/classius/server/internal/models"
+)
+
+const maxTagNameLength = 100 // tags.name is VARCHAR(100)
+
+// TagService manages a user's tags across annotations and books. Tags are
+// hierarchical: "philosophy/ethics" is a child of "philosophy".
+type TagService struct {
+	db *gorm.DB
+}
+
+// NewTagService creates a new tag service
+func NewTagService(db *gorm.DB) *TagService {
+	return &TagService{db: db}
+}
+
+// TagInfo is a tag with its usage
+type TagInfo struct {
+	ID                   *uuid.UUID     `json:"id,omitempty"` // Unset for tags only used on annotations
+	Name                 string         `json:"name"`
+	Parent               string         `json:"parent,omitempty"`
+	Depth                int            `json:"depth"` // 0 for top-level tags
+	Color                string         `json:"color,omitempty"`
+	Aliases              []TagAliasInfo `json:"aliases"`
+	AnnotationCount      int            `json:"annotation_count"`       // Tagged with the tag itself
+	BookCount            int            `json:"book_count"`             // Tagged with the tag itself
+	TotalAnnotationCount int            `json:"total_annotation_count"` // Tagged with the tag or a descendant
+	TotalBookCount       int            `json:"total_book_count"`       // Tagged with the tag or a descendant
+}
+
+// TagAliasInfo is an alias of a tag
+type TagAliasInfo struct {
+	ID    uuid.UUID `json:"id"`
+	Alias string    `json:"alias"`
+}
+
+// TagChange reports what a rename or merge rewrote
+type TagChange struct {
+	Renamed     map[string]string `json:"renamed"`     // Old tag names to new, descendants included
+	Annotations int               `json:"annotations"` // Annotations retagged
+	Books       int               `json:"books"`       // Book taggings moved
+}
+
+// NormalizeTagName tidies a tag name: whitespace is trimmed and collapsed
+// in each level, and empty levels are dropped
+func NormalizeTagName(name string) string {
+	var levels []string
+	for _, level := range strings.Split(name, models.TagPathSeparator) {
+		if level = strings.Join(strings.Fields(level), " "); level != "" {
+			levels = append(levels, level)
+		}
+	}
+	return strings.Join(levels, models.TagPathSeparator)
+}
+
+// TagAncestors returns the names of a tag's ancestors, outermost first
+func TagAncestors(name string) []string {
+	var ancestors []string
+	for i := 0; i < len(name); i++ {
+		if strings.HasPrefix(name[i:], models.TagPathSeparator) {
+			ancestors = append(ancestors, name[:i])
+		}
+	}
+	return ancestors
+}
+
+// IsTagOrDescendant reports whether name is tag or one of its descendants
+func IsTagOrDescendant(name, tag string) bool {
+	return name == tag || strings.HasPrefix(name, tag+models.TagPathSeparator)
+}
+
+// TagScope returns the arguments matching a tag and its descendants in a
+// "name = ? OR name LIKE ?" condition
+func TagScope(tag string) (string, string) {
+	return tag, escapeLike(tag) + models.TagPathSeparator + "%"
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// tagIndex maps names to the user's tags, for resolving tag names
+type tagIndex struct {
+	exact   map[string]bool   // Registered tag names
+	folded  map[string]string // Lowercased tag names to the tag names
+	aliases map[string]string // Lowercased aliases to tag names
+}
+
+// resolve maps a tag name to the user's tag: the tag with that name, or the
+// one it is a case variant or alias of. The longest such prefix is used,
+// so "Philosophy/new" becomes "philosophy/new" when only "philosophy" is
+// registered.
+func (idx *tagIndex) resolve(name string) string {
+	prefix := name
+	for {
+		if idx.exact[prefix] {
+			return name
+		}
+		key := strings.ToLower(prefix)
+		if tag, ok := idx.folded[key]; ok {
+			return tag + name[len(prefix):]
+		}
+		if tag, ok := idx.aliases[key]; ok {
+			return tag + name[len(prefix):]
+		}
+		i := strings.LastIndex(prefix, models.TagPathSeparator)
+		if i < 0 {
+			return name
+		}
+		prefix = prefix[:i]
+	}
+}
+
+// ResolveTags normalizes tag names and maps aliases and case variants to
+// the user's tags, dropping duplicates
+func ResolveTags(db *gorm.DB, userID uuid.UUID, names []string) ([]string, error) {
+	normalized := make([]string, 0, len(names))
+	var keys []string
+	for _, name := range names {
+		name = NormalizeTagName(name)
+		if name == "" {
+			continue
+		}
+		if len(name) > maxTagNameLength {
+			return nil, fmt.Errorf("%w tag %q: longer than %d characters", ErrInvalid, name, maxTagNameLength)
+		}
+		normalized = append(normalized, name)
+		keys = append(keys, strings.ToLower(name))
+		for _, ancestor := range TagAncestors(name) {
+			keys = append(keys, strings.ToLower(ancestor))
+		}
+	}
+	if len(normalized) == 0 {
+		return []string{}, nil
+	}
+
+	var tags []models.Tag
+	if err := db.Select("name").Where("user_id = ? AND LOWER(name) IN ?", userID, keys).Order("name").Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve tags: %w", err)
+	}
+	var aliases []struct {
+		Alias string
+		Name  string
+	}
+	if err := db.Table("tag_aliases ta").Select("ta.alias, t.name").
+		Joins("JOIN tags t ON t.id = ta.tag_id AND t.deleted_at IS NULL").
+		Where("ta.user_id = ? AND ta.deleted_at IS NULL AND LOWER(ta.alias) IN ?", userID, keys).
+		Scan(&aliases).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve tag aliases: %w", err)
+	}
+
+	idx := &tagIndex{exact: map[string]bool{}, folded: map[string]string{}, aliases: map[string]string{}}
+	for _, t := range tags {
+		idx.exact[t.Name] = true
+		if _, ok := idx.folded[strings.ToLower(t.Name)]; !ok {
+			idx.folded[strings.ToLower(t.Name)] = t.Name
+		}
+	}
+	for _, a := range aliases {
+		idx.aliases[strings.ToLower(a.Alias)] = a.Name
+	}
+	return resolveTagNames(normalized, idx), nil
+}
+
+func resolveTagNames(names []string, idx *tagIndex) []string {
+	resolved := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = idx.resolve(name)
+		if key := strings.ToLower(name); !seen[key] {
+			seen[key] = true
+			resolved = append(resolved, name)
+		}
+	}
+	return resolved
+}
+
+// RegisterTags makes sure the user has tags with the given (resolved)
+// names and their ancestors, and returns the named tags
+func RegisterTags(tx *gorm.DB, userID uuid.UUID, names []string) ([]models.Tag, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	var all []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		for _, n := range append(TagAncestors(name), name) {
+			if !seen[n] {
+				seen[n] = true
+				all = append(all, n)
+			}
+		}
+	}
+
+	var existing []models.Tag
+	if err := tx.Where("user_id = ? AND name IN ?", userID, all).Find(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve tags: %w", err)
+	}
+	byName := make(map[string]models.Tag, len(all))
+	for _, t := range existing {
+		byName[t.Name] = t
+	}
+	for _, name := range all {
+		if _, ok := byName[name]; ok {
+			continue
+		}
+		tag := models.Tag{ID: uuid.New(), UserID: userID, Name: name}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tag)
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to create tag %s: %w", name, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			// Created concurrently
+			if err := tx.Where("user_id = ? AND name = ?", userID, name).First(&tag).Error; err != nil {
+				return nil, fmt.Errorf("failed to retrieve tag %s: %w", name, err)
+			}
+		}
+		byName[name] = tag
+	}
+
+	tags := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		tags = append(tags, byName[name])
+	}
+	return tags, nil
+}
+
+// List returns the user's tags, with those only used on annotations and
+// the ancestors of used tags, sorted by name
+func (s *TagService) List(ctx context.Context, userID uuid.UUID) ([]TagInfo, error) {
+	db := s.db.WithContext(ctx)
+
+	var tags []models.Tag
+	if err := db.Where("user_id = ?", userID).Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve tags: %w", err)
+	}
+	var aliases []models.TagAlias
+	if err := db.Where("user_id = ?", userID).Order("alias").Find(&aliases).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve tag aliases: %w", err)
+	}
+
+	var annotationRows []struct {
+		ID   uuid.UUID
+		Name string
+	}
+	if err := db.Raw(`SELECT a.id, t.name FROM annotations a, unnest(a.tags) AS t(name)
+		WHERE a.user_id = ? AND a.deleted_at IS NULL AND t.name <> ''`, userID).Scan(&annotationRows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count annotation tags: %w", err)
+	}
+	var bookRows []struct {
+		BookID uuid.UUID
+		Name   string
+	}
+	if err := db.Table("book_tags bt").Select("bt.book_id, t.name").
+		Joins("JOIN tags t ON t.id = bt.tag_id AND t.deleted_at IS NULL").
+		Joins("JOIN books b ON b.id = bt.book_id AND b.deleted_at IS NULL").
+		Where("t.user_id = ?", userID).Scan(&bookRows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count book tags: %w", err)
+	}
+
+	annotationTags := make(map[uuid.UUID][]string)
+	for _, r := range annotationRows {
+		annotationTags[r.ID] = append(annotationTags[r.ID], r.Name)
+	}
+	bookTags := make(map[uuid.UUID][]string)
+	for _, r := range bookRows {
+		bookTags[r.BookID] = append(bookTags[r.BookID], r.Name)
+	}
+	infos := countTagUsage(annotationTags, bookTags)
+
+	for _, t := range tags {
+		info := tagInfo(infos, t.Name)
+		id := t.ID
+		info.ID, info.Color = &id, t.Color
+		for _, a := range aliases {
+			if a.TagID == t.ID {
+				info.Aliases = append(info.Aliases, TagAliasInfo{ID: a.ID, Alias: a.Alias})
+			}
+		}
+	}
+	for _, t := range tags {
+		for _, ancestor := range TagAncestors(t.Name) {
+			tagInfo(infos, ancestor)
+		}
+	}
+
+	result := make([]TagInfo, 0, len(infos))
+	for _, info := range infos {
+		result = append(result, *info)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// countTagUsage counts the annotations and books tagged with each tag,
+// directly and through descendants. Each map holds the tags of an
+// annotation or book.
+func countTagUsage(annotationTags, bookTags map[uuid.UUID][]string) map[string]*TagInfo {
+	infos := make(map[string]*TagInfo)
+	count := func(tags []string, direct func(*TagInfo), total func(*TagInfo)) {
+		counted := make(map[string]bool)
+		for _, tag := range tags {
+			if counted[tag] {
+				continue
+			}
+			counted[tag] = true
+			direct(tagInfo(infos, tag))
+		}
+		seen := make(map[string]bool)
+		for tag := range counted {
+			for _, name := range append(TagAncestors(tag), tag) {
+				if !seen[name] {
+					seen[name] = true
+					total(tagInfo(infos, name))
+				}
+			}
+		}
+	}
+	for _, tags := range annotationTags {
+		count(tags, func(i *TagInfo) { i.AnnotationCount++ }, func(i *TagInfo) { i.TotalAnnotationCount++ })
+	}
+	for _, tags := range bookTags {
+		count(tags, func(i *TagInfo) { i.BookCount++ }, func(i *TagInfo) { i.TotalBookCount++ })
+	}
+	return infos
+}
+
+func tagInfo(infos map[string]*TagInfo, name string) *TagInfo {
+	info, ok := infos[name]
+	if !ok {
+		info = &TagInfo{Name: name, Aliases: []TagAliasInfo{}}
+		if ancestors := TagAncestors(name); len(ancestors) > 0 {
+			info.Parent, info.Depth = ancestors[len(ancestors)-1], len(ancestors)
+		}
+		infos[name] = info
+	}
+	return info
+}
+
+// Rename renames a tag and its descendants on the user's annotations and
+// books. With keepAlias, the old name becomes an alias of the new one.
+func (s *TagService) Rename(ctx context.Context, userID uuid.UUID, from, to string, keepAlias bool) (*TagChange, error) {
+	from, to = NormalizeTagName(from), NormalizeTagName(to)
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("%w: from and to are required", ErrInvalid)
+	}
+	if from == to {
+		return nil, fmt.Errorf("%w rename: the names are the same", ErrInvalid)
+	}
+
+	change := &TagChange{Renamed: map[string]string{}}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		used, err := tagInUse(tx, userID, to)
+		if err != nil {
+			return err
+		}
+		if used {
+			return fmt.Errorf("%w: tag %q already exists: merge the tags instead", ErrConflict, to)
+		}
+		if err := moveTag(tx, userID, from, to, change); err != nil {
+			return err
+		}
+		if keepAlias {
+			return addTagAlias(tx, userID, to, from)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// Merge retags everything tagged with the sources, or their descendants,
+// with the target. The sources become aliases of the target.
+func (s *TagService) Merge(ctx context.Context, userID uuid.UUID, sources []string, target string) (*TagChange, error) {
+	target = NormalizeTagName(target)
+	if target == "" || len(sources) == 0 {
+		return nil, fmt.Errorf("%w: sources and target are required", ErrInvalid)
+	}
+
+	change := &TagChange{Renamed: map[string]string{}}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, source := range sources {
+			source = NormalizeTagName(source)
+			if source == "" || source == target {
+				continue
+			}
+			if err := moveTag(tx, userID, source, target, change); err != nil {
+				return err
+			}
+			if err := addTagAlias(tx, userID, target, source); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// moveTag renames from and its descendants to to, merging them into any
+// tags that already have the new names
+func moveTag(tx *gorm.DB, userID uuid.UUID, from, to string, change *TagChange) error {
+	if IsTagOrDescendant(to, from) {
+		return fmt.Errorf("%w name: %q cannot move under itself", ErrInvalid, from)
+	}
+	if len(to) > maxTagNameLength {
+		return fmt.Errorf("%w tag %q: longer than %d characters", ErrInvalid, to, maxTagNameLength)
+	}
+	exact, pattern := TagScope(from)
+
+	var tags []models.Tag
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND (name = ? OR name LIKE ?)", userID, exact, pattern).
+		Order("name").Find(&tags).Error; err != nil {
+		return fmt.Errorf("failed to retrieve tags: %w", err)
+	}
+	var annotations []models.Annotation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "tags").
+		Where("user_id = ? AND EXISTS (SELECT 1 FROM unnest(tags) AS t(name) WHERE t.name = ? OR t.name LIKE ?)", userID, exact, pattern).
+		Find(&annotations).Error; err != nil {
+		return fmt.Errorf("failed to retrieve annotations: %w", err)
+	}
+	if len(tags) == 0 && len(annotations) == 0 {
+		return fmt.Errorf("tag %q %w", from, ErrNotFound)
+	}
+
+	for i := range tags {
+		tag := &tags[i]
+		name := to + tag.Name[len(from):]
+		change.Renamed[tag.Name] = name
+		if len(name) > maxTagNameLength {
+			return fmt.Errorf("%w tag %q: longer than %d characters", ErrInvalid, name, maxTagNameLength)
+		}
+
+		var existing models.Tag
+		result := tx.Where("user_id = ? AND name = ?", userID, name).Limit(1).Find(&existing)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve tag %s: %w", name, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			if err := tx.Model(tag).Update("name", name).Error; err != nil {
+				return fmt.Errorf("failed to rename tag %s: %w", tag.Name, err)
+			}
+			var books int64
+			if err := tx.Table("book_tags").Where("tag_id = ?", tag.ID).Count(&books).Error; err != nil {
+				return fmt.Errorf("failed to count books: %w", err)
+			}
+			change.Books += int(books)
+			continue
+		}
+
+		// Merge into the existing tag
+		if err := tx.Exec(`INSERT INTO book_tags (book_id, tag_id) SELECT book_id, ? FROM book_tags WHERE tag_id = ?
+			ON CONFLICT DO NOTHING`, existing.ID, tag.ID).Error; err != nil {
+			return fmt.Errorf("failed to move books to tag %s: %w", name, err)
+		}
+		moved := tx.Exec("DELETE FROM book_tags WHERE tag_id = ?", tag.ID)
+		if moved.Error != nil {
+			return fmt.Errorf("failed to move books to tag %s: %w", name, moved.Error)
+		}
+		change.Books += int(moved.RowsAffected)
+		if err := tx.Model(&models.TagAlias{}).Where("tag_id = ?", tag.ID).Update("tag_id", existing.ID).Error; err != nil {
+			return fmt.Errorf("failed to move aliases to tag %s: %w", name, err)
+		}
+		if err := tx.Delete(tag).Error; err != nil {
+			return fmt.Errorf("failed to delete tag %s: %w", tag.Name, err)
+		}
+	}
+
+	ids := make([]uuid.UUID, 0, len(annotations))
+	for _, a := range annotations {
+		for _, tag := range a.Tags {
+			if IsTagOrDescendant(tag, from) {
+				change.Renamed[tag] = to + tag[len(from):]
+			}
+		}
+		if err := tx.Model(&models.Annotation{}).Where("id = ?", a.ID).Update("tags", retagNames(a.Tags, from, to)).Error; err != nil {
+			return fmt.Errorf("failed to retag annotation: %w", err)
+		}
+		ids = append(ids, a.ID)
+	}
+	if err := RecordAnnotationChange(tx, userID, ids, "tags"); err != nil {
+		return err
+	}
+	change.Annotations += len(ids)
+
+	// The new name is a tag now, not an alias
+	if err := tx.Where("user_id = ? AND LOWER(alias) = LOWER(?)", userID, to).Delete(&models.TagAlias{}).Error; err != nil {
+		return fmt.Errorf("failed to remove alias %s: %w", to, err)
+	}
+	_, err := RegisterTags(tx, userID, []string{to})
+	return err
+}
+
+// retagNames replaces from, and from as an ancestor, with to in a list of
+// tags, dropping duplicates
+func retagNames(tags []string, from, to string) []string {
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if IsTagOrDescendant(tag, from) {
+			tag = to + tag[len(from):]
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// tagInUse reports whether a tag or any of its descendants is registered
+// or used on an annotation
+func tagInUse(tx *gorm.DB, userID uuid.UUID, name string) (bool, error) {
+	exact, pattern := TagScope(name)
+	var count int64
+	if err := tx.Model(&models.Tag{}).Where("user_id = ? AND (name = ? OR name LIKE ?)", userID, exact, pattern).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check tags: %w", err)
+	}
+	if count > 0 {
+		return true, nil
+	}
+	if err := tx.Model(&models.Annotation{}).
+		Where("user_id = ? AND EXISTS (SELECT 1 FROM unnest(tags) AS t(name) WHERE t.name = ? OR t.name LIKE ?)", userID, exact, pattern).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check annotation tags: %w", err)
+	}
+	return count > 0, nil
+}
+
+// AddAlias makes alias another name for a tag
+func (s *TagService) AddAlias(ctx context.Context, userID uuid.UUID, tagName, alias string) (*models.TagAlias, error) {
+	tagName, alias = NormalizeTagName(tagName), NormalizeTagName(alias)
+	if tagName == "" || alias == "" {
+		return nil, fmt.Errorf("%w: tag and alias are required", ErrInvalid)
+	}
+	if len(alias) > maxTagNameLength {
+		return nil, fmt.Errorf("%w alias %q: longer than %d characters", ErrInvalid, alias, maxTagNameLength)
+	}
+
+	var created models.TagAlias
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Tag{}).Where("user_id = ? AND LOWER(name) = LOWER(?)", userID, alias).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check tags: %w", err)
+		}
+		used, err := tagInUse(tx, userID, alias)
+		if err != nil {
+			return err
+		}
+		if count > 0 || used {
+			return fmt.Errorf("%w: tag %q already exists: merge the tags instead", ErrConflict, alias)
+		}
+		if err := tx.Model(&models.TagAlias{}).Where("user_id = ? AND LOWER(alias) = LOWER(?)", userID, alias).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check aliases: %w", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: alias %q already exists", ErrConflict, alias)
+		}
+
+		var tag models.Tag
+		result := tx.Where("user_id = ? AND name = ?", userID, tagName).Limit(1).Find(&tag)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retrieve tag: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("tag %q %w", tagName, ErrNotFound)
+		}
+		created = models.TagAlias{UserID: userID, TagID: tag.ID, Alias: alias}
+		if err := tx.Create(&created).Error; err != nil {
+			return fmt.Errorf("failed to create alias: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// RemoveAlias deletes one of the user's tag aliases
+func (s *TagService) RemoveAlias(ctx context.Context, userID, aliasID uuid.UUID) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", aliasID, userID).Delete(&models.TagAlias{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete alias: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("alias %w", ErrNotFound)
+	}
+	return nil
+}
+
+// addTagAlias points alias at a tag, replacing any alias of that name
+func addTagAlias(tx *gorm.DB, userID uuid.UUID, tagName, alias string) error {
+	var tag models.Tag
+	if err := tx.Where("user_id = ? AND name = ?", userID, tagName).First(&tag).Error; err != nil {
+		return fmt.Errorf("failed to retrieve tag %s: %w", tagName, err)
+	}
+	if err := tx.Where("user_id = ? AND LOWER(alias) = LOWER(?)", userID, alias).Delete(&models.TagAlias{}).Error; err != nil {
+		return fmt.Errorf("failed to replace alias %s: %w", alias, err)
+	}
+	if err := tx.Create(&models.TagAlias{UserID: userID, TagID: tag.ID, Alias: alias}).Error; err != nil {
+		return fmt.Errorf("failed to create alias %s: %w", alias, err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNormalizeTagName(t *testing.T) {
+	tests := map[string]string{
+		"  philosophy ":                "philosophy",
+		"philosophy / virtue  ethics/": "philosophy/virtue ethics",
+		"//a///b":                      "a/b",
+		" / ":                          "",
+	}
+	for in, want := range tests {
+		if got := NormalizeTagName(in); got != want {
+			t.Errorf("NormalizeTagName(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	if got := TagAncestors("philosophy/ethics/virtue"); !reflect.DeepEqual(got, []string{"philosophy", "philosophy/ethics"}) {
+		t.Errorf("unexpected ancestors %q", got)
+	}
+	if !IsTagOrDescendant("philosophy/ethics", "philosophy") || IsTagOrDescendant("philosophyx", "philosophy") {
+		t.Error("IsTagOrDescendant matched the wrong tags")
+	}
+	if _, pattern := TagScope("100%_done"); pattern != `100\%\_done/%` {
+		t.Errorf("unescaped pattern %q", pattern)
+	}
+}
+
+func TestResolveTagNames(t *testing.T) {
+	idx := &tagIndex{
+		exact:   map[string]bool{"philosophy": true, "Stoicism": true},
+		folded:  map[string]string{"philosophy": "philosophy", "stoicism": "Stoicism"},
+		aliases: map[string]string{"philo": "philosophy", "stoa": "Stoicism"},
+	}
+	got := resolveTagNames([]string{"Philosophy/Ethics", "philo/ethics", "stoa", "STOICISM", "new/tag"}, idx)
+	want := []string{"philosophy/Ethics", "Stoicism", "new/tag"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveTagNames = %q, want %q", got, want)
+	}
+}
+
+func TestRetagNames(t *testing.T) {
+	got := retagNames([]string{"ethcs", "ethcs/virtue", "ethics", "ethcsx", "logic"}, "ethcs", "ethics")
+	want := []string{"ethics", "ethics/virtue", "ethcsx", "logic"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("retagNames = %q, want %q", got, want)
+	}
+}
+
+func TestCountTagUsage(t *testing.T) {
+	infos := countTagUsage(map[uuid.UUID][]string{
+		uuid.New(): {"philosophy/ethics", "philosophy"},
+		uuid.New(): {"philosophy/ethics/virtue"},
+		uuid.New(): {"logic"},
+	}, map[uuid.UUID][]string{
+		uuid.New(): {"philosophy/ethics"},
+	})
+
+	philosophy, ethics, virtue := infos["philosophy"], infos["philosophy/ethics"], infos["philosophy/ethics/virtue"]
+	if philosophy.AnnotationCount != 1 || philosophy.TotalAnnotationCount != 2 || philosophy.TotalBookCount != 1 {
+		t.Errorf("unexpected philosophy counts %+v", philosophy)
+	}
+	if ethics.AnnotationCount != 1 || ethics.TotalAnnotationCount != 2 || ethics.BookCount != 1 || ethics.Parent != "philosophy" {
+		t.Errorf("unexpected ethics counts %+v", ethics)
+	}
+	if virtue.Depth != 2 || virtue.TotalAnnotationCount != 1 {
+		t.Errorf("unexpected virtue counts %+v", virtue)
+	}
+	if len(infos) != 4 {
+		t.Errorf("expected 4 tags, got %d", len(infos))
+	}
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Language codes understood by the text analysis helpers
+const (
+	LangEnglish = "en"
+	LangLatin   = "la"
+	LangGreek   = "grc"
+)
+
+// Token is a single word found in a text, located by rune offsets
+type Token struct {
+	Text  string `json:"text"`  // Surface form as it appears in the text
+	Norm  string `json:"norm"`  // Lowercased form with diacritics removed
+	Start int    `json:"start"` // Rune offset of the first character
+	End   int    `json:"end"`   // Rune offset one past the last character
+}
+
+// NormalizeLanguage maps a book language to one of the supported analysis languages
+func NormalizeLanguage(language string) string {
+	switch strings.ToLower(strings.TrimSpace(language)) {
+	case "la", "lat", "latin":
+		return LangLatin
+	case "grc", "el", "gr", "ell", "greek", "ancient greek":
+		return LangGreek
+	default:
+		return LangEnglish
+	}
+}
+
+// maxLanguageSample is how many bytes of a text DetectLanguage is given
+const maxLanguageSample = 20000
+
+// LanguageSample returns the start of a text for DetectLanguage, at most
+// maxLanguageSample bytes and cut on a rune boundary
+func LanguageSample(text string) string {
+	if len(text) <= maxLanguageSample {
+		return text
+	}
+	end := maxLanguageSample
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return text[:end]
+}
+
+// DetectLanguage guesses the analysis language of a text sample.
+// Greek script is recognised directly; Latin is told apart from English
+// by the share of common function words.
+func DetectLanguage(sample string, fallback string) string {
+	var greek, letters int
+	for _, r := range sample {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.Is(unicode.Greek, r) {
+			greek++
+		}
+	}
+	if letters > 0 && greek*2 > letters {
+		return LangGreek
+	}
+
+	var latinHits, englishHits int
+	for _, tok := range Tokenize(sample) {
+		if latinStopwords[tok.Norm] && !englishStopwords[tok.Norm] {
+			latinHits++
+		}
+		if englishStopwords[tok.Norm] && !latinStopwords[tok.Norm] {
+			englishHits++
+		}
+	}
+	if latinHits > englishHits*2 && latinHits > 5 {
+		return LangLatin
+	}
+	if englishHits > 5 {
+		return LangEnglish
+	}
+	return NormalizeLanguage(fallback)
+}
+
+// NormalizeWord lowercases a word and strips accents, breathings and other
+// combining marks so that "ἀρετή", "ἀρετὴ" and "αρετη" compare equal.
+func NormalizeWord(word string) string {
+	decomposed := norm.NFD.String(word)
+	var b strings.Builder
+	b.Grow(len(decomposed))
+	for _, r := range decomposed {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		r = unicode.ToLower(r)
+		if r == 'ς' {
+			r = 'σ'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// NormalizeForLanguage applies language-specific spelling folds on top of NormalizeWord
+func NormalizeForLanguage(word, language string) string {
+	w := NormalizeWord(word)
+	if language == LangLatin {
+		w = strings.NewReplacer("j", "i", "v", "u").Replace(w)
+	}
+	return w
+}
+
+// Tokenize splits text into word tokens. Letters (including combining marks)
+// and internal apostrophes form words; everything else is a separator.
+func Tokenize(text string) []Token {
+	var tokens []Token
+	var current []rune
+	start := -1
+	pos := 0
+
+	flush := func() {
+		if start < 0 {
+			return
+		}
+		word := strings.TrimRight(string(current), "'’")
+		if word != "" {
+			tokens = append(tokens, Token{
+				Text:  word,
+				Norm:  NormalizeWord(word),
+				Start: start,
+				End:   start + len([]rune(word)),
+			})
+		}
+		current = current[:0]
+		start = -1
+	}
+
+	for _, r := range text {
+		switch {
+		case isLetterOrMark(r):
+			if start < 0 {
+				start = pos
+			}
+			current = append(current, r)
+		case (r == '\'' || r == '’') && start >= 0:
+			current = append(current, r)
+		default:
+			flush()
+		}
+		pos++
+	}
+	flush()
+
+	return tokens
+}
+
+func isLetterOrMark(r rune) bool {
+	return unicode.IsLetter(r) || unicode.Is(unicode.Mn, r)
+}
+
+// IsStopword reports whether a normalized word is a stopword in the given language
+func IsStopword(normWord, language string) bool {
+	switch language {
+	case LangLatin:
+		return latinStopwords[normWord]
+	case LangGreek:
+		return greekStopwords[normWord]
+	default:
+		return englishStopwords[normWord]
+	}
+}
+
+// Stem reduces a normalized word to a crude stem for the given language.
+// The stemmers are deliberately light: they conflate common inflections
+// without attempting full morphological analysis.
+func Stem(normWord, language string) string {
+	switch language {
+	case LangLatin:
+		return stemLatin(normWord)
+	case LangGreek:
+		return stemGreek(normWord)
+	default:
+		return stemEnglish(normWord)
+	}
+}
+
+// stemEnglish strips common English inflectional and derivational suffixes
+func stemEnglish(w string) string {
+	if len(w) <= 3 {
+		return w
+	}
+	switch {
+	case strings.HasSuffix(w, "sses"):
+		w = w[:len(w)-2]
+	case strings.HasSuffix(w, "ies") && len(w) > 4:
+		w = w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && !strings.HasSuffix(w, "us") && !strings.HasSuffix(w, "is"):
+		w = w[:len(w)-1]
+	}
+
+	for _, suffix := range []string{"ational", "fulness", "iveness", "ization", "ousness", "ments", "ment", "ness", "ingly", "edly", "ing", "ed", "ly", "ful", "ous", "ive", "ize", "ise"} {
+		if strings.HasSuffix(w, suffix) && len(w)-len(suffix) >= 3 {
+			stem := w[:len(w)-len(suffix)]
+			if !containsVowel(stem) {
+				continue
+			}
+			if suffix == "ing" || suffix == "ed" {
+				// hopping -> hop, hoped -> hope is not attempted; collapse doubled consonants
+				if n := len(stem); n >= 2 && stem[n-1] == stem[n-2] && !strings.ContainsRune("lsz", rune(stem[n-1])) {
+					stem = stem[:n-1]
+				}
+			}
+			return stem
+		}
+	}
+	return w
+}
+
+func containsVowel(s string) bool {
+	return strings.ContainsAny(s, "aeiouy")
+}
+
+// stemLatin implements the Schinke Latin stemmer's noun branch, which
+// removes enclitic -que and the common nominal and adjectival endings.
+func stemLatin(w string) string {
+	if strings.HasSuffix(w, "que") {
+		switch w {
+		case "atque", "quoque", "neque", "itaque", "absque", "apsque", "abusque", "adaeque", "adusque",
+			"denique", "deque", "susque", "oblique", "peraeque", "plenisque", "quandoque", "quisque",
+			"quaeque", "cuiusque", "cuique", "quemque", "quamque", "quaque", "quique", "quorumque",
+			"quarumque", "quibusque", "quosque", "quasque", "quotusquisque", "quousque", "ubique",
+			"undique", "usque", "uterque", "utique", "utroque", "utribique", "torque", "coque",
+			"concoque", "contorque", "detorque", "decoque", "excoque", "extorque", "obtorque",
+			"optorque", "retorque", "recoque", "attorque", "incoque", "intorque", "praetorque":
+			return w
+		}
+		w = strings.TrimSuffix(w, "que")
+	}
+
+	for _, suffix := range []string{"ibus", "ius", "ae", "am", "as", "em", "es", "ia", "is", "nt", "os", "ud", "um", "us", "a", "e", "i", "o", "u"} {
+		if strings.HasSuffix(w, suffix) && len([]rune(w))-len([]rune(suffix)) >= 2 {
+			return strings.TrimSuffix(w, suffix)
+		}
+	}
+	return w
+}
+
+// stemGreek strips common nominal and verbal endings from unaccented Greek
+func stemGreek(w string) string {
+	for _, suffix := range []string{
+		"ουσιν", "ομενοσ", "ομενη", "ομενον", "ονται", "εσθαι", "ουσι", "οντα", "ουσα",
+		"οισ", "αισ", "ων", "ου", "ωι", "ον", "οσ", "ησ", "ην", "ηι", "ασ", "αν", "ει", "ειν", "εσ", "αι", "οι",
+		"α", "η", "ω", "ε", "ι", "σ",
+	} {
+		if strings.HasSuffix(w, suffix) && len([]rune(w))-len([]rune(suffix)) >= 2 {
+			return strings.TrimSuffix(w, suffix)
+		}
+	}
+	return w
+}
+
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+var englishStopwords = wordSet(
+	"a", "about", "above", "after", "again", "against", "all", "am", "an", "and", "any", "are", "as", "at",
+	"be", "because", "been", "before", "being", "below", "between", "both", "but", "by", "can", "could",
+	"did", "do", "does", "doing", "down", "during", "each", "few", "for", "from", "further", "had", "has",
+	"have", "having", "he", "her", "here", "hers", "herself", "him", "himself", "his", "how", "i", "if",
+	"in", "into", "is", "it", "its", "itself", "me", "more", "most", "my", "myself", "no", "nor", "not",
+	"now", "of", "off", "on", "once", "only", "or", "other", "our", "ours", "ourselves", "out", "over",
+	"own", "same", "shall", "she", "should", "so", "some", "such", "than", "that", "the", "their",
+	"theirs", "them", "themselves", "then", "there", "these", "they", "this", "those", "thou", "thee",
+	"thy", "through", "to", "too", "under", "until", "up", "upon", "very", "was", "we", "were", "what",
+	"when", "where", "which", "while", "who", "whom", "why", "will", "with", "would", "ye", "you", "your",
+	"yours", "yourself", "unto",
+)
+
+var latinStopwords = wordSet(
+	"a", "ab", "ac", "ad", "adhuc", "at", "atque", "aut", "autem", "cum", "de", "dum", "e", "ea", "eam",
+	"eas", "ego", "ei", "eis", "eius", "enim", "eo", "eorum", "eos", "erat", "ergo", "esse", "est", "et",
+	"etiam", "eum", "ex", "hic", "haec", "hoc", "iam", "id", "igitur", "ille", "illa", "illud", "in",
+	"inter", "ipse", "ipsa", "ipsum", "is", "ita", "itaque", "me", "mihi", "nam", "ne", "nec", "neque",
+	"nisi", "non", "nos", "nunc", "ob", "per", "post", "pro", "quae", "quam", "qui", "quid", "quidem",
+	"quo", "quod", "sed", "si", "sic", "sine", "sub", "sum", "sunt", "super", "tamen", "te", "tibi",
+	"tu", "tum", "ut", "uel", "uero", "uos",
+)
+
+var greekStopwords = wordSet(
+	"αλλ", "αλλα", "αν", "αυτοσ", "αυτον", "αυτου", "αυτω", "αυτη", "αυτην", "αυτησ", "αυτο", "γαρ",
+	"γε", "δ", "δε", "δη", "δια", "εαν", "εγω", "ει", "ειμι", "ειναι", "εισ", "εκ", "εν", "επι", "εστι",
+	"εστιν", "η", "ην", "και", "κατα", "μεν", "μετα", "μη", "ο", "οδε", "οι", "ον", "οσ", "οστισ", "οτι",
+	"ου", "ουδε", "ουκ", "ουν", "ουτοσ", "ουχ", "παρα", "περι", "προ", "προσ", "σ", "συν", "τα", "ταισ",
+	"τε", "την", "τησ", "τι", "τισ", "το", "τοι", "τοισ", "τον", "τουσ", "του", "τω", "των", "υπερ",
+	"υπο", "ωσ", "ωσπερ",
+)
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestNormalizeWord(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"Virtue", "virtue"},
+		{"ἀρετή", "αρετη"},
+		{"ἀρετὴ", "αρετη"},
+		{"λόγος", "λογοσ"},
+		{"Ἀχιλλεύς", "αχιλλευσ"},
+	}
+
+	for _, tc := range testCases {
+		if result := NormalizeWord(tc.input); result != tc.expected {
+			t.Errorf("NormalizeWord(%q): expected %q, got %q", tc.input, tc.expected, result)
+		}
+	}
+
+	if result := NormalizeForLanguage("Iuuenis", LangLatin); result != NormalizeForLanguage("Juvenis", LangLatin) {
+		t.Errorf("Expected Latin u/v and i/j spellings to normalize equally, got %q", result)
+	}
+}
+
+func TestTokenizeOffsets(t *testing.T) {
+	text := "μῆνιν ἄειδε, θεά — sing, goddess"
+	tokens := Tokenize(text)
+
+	expected := []string{"μῆνιν", "ἄειδε", "θεά", "sing", "goddess"}
+	if len(tokens) != len(expected) {
+		t.Fatalf("Expected %d tokens, got %d: %+v", len(expected), len(tokens), tokens)
+	}
+
+	runes := []rune(text)
+	for i, tok := range tokens {
+		if tok.Text != expected[i] {
+			t.Errorf("Token %d: expected %q, got %q", i, expected[i], tok.Text)
+		}
+		if got := string(runes[tok.Start:tok.End]); got != tok.Text {
+			t.Errorf("Token %d offsets [%d:%d] point at %q, expected %q", i, tok.Start, tok.End, got, tok.Text)
+		}
+	}
+}
+
+func TestStemConflatesInflections(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		language string
+	}{
+		{"virtues", "virtue", LangEnglish},
+		{"reading", "read", LangEnglish},
+		{"uirtutis", "uirtutem", LangLatin},
+		{"arma", "armis", LangLatin},
+		{"αρετησ", "αρετη", LangGreek},
+	}
+
+	for _, tc := range testCases {
+		if Stem(tc.a, tc.language) != Stem(tc.b, tc.language) {
+			t.Errorf("Expected %q and %q to share a stem in %s, got %q and %q",
+				tc.a, tc.b, tc.language, Stem(tc.a, tc.language), Stem(tc.b, tc.language))
+		}
+	}
+
+	if Stem("atque", LangLatin) != "atque" {
+		t.Errorf("Expected 'atque' to be protected from -que stripping")
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	greek := "ἄνδρα μοι ἔννεπε, μοῦσα, πολύτροπον, ὃς μάλα πολλὰ πλάγχθη"
+	if lang := DetectLanguage(greek, "en"); lang != LangGreek {
+		t.Errorf("Expected Greek, got %s", lang)
+	}
+
+	latin := "Gallia est omnis divisa in partes tres, quarum unam incolunt Belgae, aliam Aquitani, tertiam qui ipsorum lingua Celtae, nostra Galli appellantur. Hi omnes lingua, institutis, legibus inter se differunt, et sunt non ita ut in hoc."
+	if lang := DetectLanguage(latin, "en"); lang != LangLatin {
+		t.Errorf("Expected Latin, got %s", lang)
+	}
+}
+
+func TestDetectChapters(t *testing.T) {
+	text := "Preface text.\n\nCHAPTER I\n\nIt was a dark night.\n\nCHAPTER II. The Return\n\nMorning came."
+	chapters := DetectChapters(text)
+
+	if len(chapters) != 3 {
+		t.Fatalf("Expected 3 chapters (front matter + 2), got %d: %+v", len(chapters), chapters)
+	}
+	if chapters[1].Title != "CHAPTER I" {
+		t.Errorf("Expected second section to be 'CHAPTER I', got %q", chapters[1].Title)
+	}
+	if chapters[len(chapters)-1].End != len([]rune(text)) {
+		t.Errorf("Expected last chapter to end at text length")
+	}
+
+	offset := len([]rune("Preface text.\n\nCHAPTER I\n\nIt"))
+	if idx := ChapterAt(chapters, offset); idx != 1 {
+		t.Errorf("Expected offset %d to fall in chapter 1, got %d", offset, idx)
+	}
+}
+
+func TestLanguageSample(t *testing.T) {
+	short := "ἀρετή"
+	if got := LanguageSample(short); got != short {
+		t.Errorf("LanguageSample(%q) = %q", short, got)
+	}
+
+	// Greek letters take two bytes, so an odd cut would split one
+	greek := strings.Repeat("α", maxLanguageSample/2) + "βγ"
+	sample := LanguageSample("x" + greek)
+	if !utf8.ValidString(sample) {
+		t.Fatal("LanguageSample split a rune")
+	}
+	if len(sample) != maxLanguageSample-1 {
+		t.Errorf("len(LanguageSample) = %d, want %d", len(sample), maxLanguageSample-1)
+	}
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+
+	"github.com/classius/server/internal/models"
+)
+
+// fingerprintMinWords is the length from which texts lose their edges
+// before fingerprinting
+const fingerprintMinWords = 200
+
+// TextFingerprint identifies a text independently of the file it came
+// from: a hash of its lower-cased words, without punctuation, layout or
+// the first and last tenth, where copies of a text differ most (front
+// matter, licences). Text without words has no fingerprint.
+func TextFingerprint(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+	if len(words) >= fingerprintMinWords {
+		trim := len(words) / 10
+		words = words[trim : len(words)-trim]
+	}
+	hash := sha256.New()
+	for _, word := range words {
+		hash.Write([]byte(strings.ToLower(word)))
+		hash.Write([]byte{' '})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// bookTextFingerprint returns a book's fingerprint, computing and saving it
+// for books extracted before fingerprints were kept. Books whose text is
+// not extracted have none.
+func bookTextFingerprint(db *gorm.DB, book *models.Book) (string, error) {
+	if book.TextFingerprint != "" {
+		return book.TextFingerprint, nil
+	}
+	var content models.BookContent
+	result := db.Where("book_id = ?", book.ID).Limit(1).Find(&content)
+	if result.Error != nil {
+		return "", fmt.Errorf("failed to retrieve book content: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return "", nil
+	}
+	fingerprint := TextFingerprint(content.FullText)
+	if err := db.Model(&models.Book{}).Where("id = ?", book.ID).
+		UpdateColumn("text_fingerprint", fingerprint).Error; err != nil {
+		return "", fmt.Errorf("failed to save text fingerprint: %w", err)
+	}
+	book.TextFingerprint = fingerprint
+	return fingerprint, nil
+}
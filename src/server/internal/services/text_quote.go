@@ -0,0 +1,141 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QuoteLocator finds quoted passages in a book's text. Matching ignores
+// case, typographic quotes and dashes, and differences in whitespace;
+// offsets are runes into the original text.
+type QuoteLocator struct {
+	text   []rune
+	folded string // Folded text with whitespace runs collapsed to a space
+	origin []int  // Rune offset in text of each rune of folded
+}
+
+// NewQuoteLocator prepares text for quote lookups
+func NewQuoteLocator(text string) *QuoteLocator {
+	l := &QuoteLocator{text: []rune(text)}
+	var b strings.Builder
+	b.Grow(len(text))
+	space := false
+	for i, r := range l.text {
+		if unicode.IsSpace(r) {
+			if space {
+				continue
+			}
+			space = true
+			r = ' '
+		} else {
+			space = false
+			r = foldRune(r)
+		}
+		b.WriteRune(r)
+		l.origin = append(l.origin, i)
+	}
+	l.folded = b.String()
+	return l
+}
+
+// Text returns the runes of the original text
+func (l *QuoteLocator) Text() []rune {
+	return l.text
+}
+
+// Locate returns the rune offsets of exact in the text. When exact occurs
+// more than once, the occurrence whose surroundings best match prefix and
+// suffix wins, then the one nearest to hint (an expected start offset, or
+// -1 for none).
+func (l *QuoteLocator) Locate(exact, prefix, suffix string, hint int) (int, int, bool) {
+	needle := foldQuote(exact)
+	if needle == "" {
+		return 0, 0, false
+	}
+	prefix, suffix = foldQuote(prefix), foldQuote(suffix)
+
+	bestStart, bestEnd, bestScore, bestDistance := 0, 0, -1, 0
+	byteOffset, runeOffset := 0, 0
+	for {
+		idx := strings.Index(l.folded[byteOffset:], needle)
+		if idx < 0 {
+			break
+		}
+		runeOffset += utf8.RuneCountInString(l.folded[byteOffset : byteOffset+idx])
+		byteOffset += idx
+
+		matchEnd := byteOffset + len(needle)
+		score := commonSuffixLen(strings.TrimRight(l.folded[:byteOffset], " "), prefix) +
+			commonPrefixLen(strings.TrimLeft(l.folded[matchEnd:], " "), suffix)
+		start := l.origin[runeOffset]
+		distance := 0
+		if hint >= 0 {
+			distance = start - hint
+			if distance < 0 {
+				distance = -distance
+			}
+		}
+		if score > bestScore || (score == bestScore && distance < bestDistance) {
+			lastRune := runeOffset + utf8.RuneCountInString(needle) - 1
+			bestStart, bestEnd, bestScore, bestDistance = start, l.origin[lastRune]+1, score, distance
+		}
+
+		_, size := utf8.DecodeRuneInString(l.folded[byteOffset:])
+		byteOffset += size
+		runeOffset++
+	}
+	return bestStart, bestEnd, bestScore >= 0
+}
+
+// Matches reports whether the text between start and end is exact, ignoring
+// case and whitespace differences
+func (l *QuoteLocator) Matches(start, end int, exact string) bool {
+	if start < 0 || end > len(l.text) || start >= end {
+		return false
+	}
+	return foldQuote(string(l.text[start:end])) == foldQuote(exact)
+}
+
+// Context returns up to n runes before start and after end
+func (l *QuoteLocator) Context(start, end, n int) (string, string) {
+	return runeSlice(l.text, start-n, start), runeSlice(l.text, end, end+n)
+}
+
+// foldQuote folds s as the locator's text: lower-cased, with typographic
+// quotes and dashes replaced and whitespace collapsed
+func foldQuote(s string) string {
+	return strings.Map(foldRune, strings.Join(strings.Fields(s), " "))
+}
+
+// foldRune lower-cases r and maps typographic punctuation, which e-readers
+// often alter, to ASCII
+func foldRune(r rune) rune {
+	switch r {
+	case '‘', '’', '‚', '‛', '′':
+		return '\''
+	case '“', '”', '„', '‟', '″', '«', '»':
+		return '"'
+	case '‐', '‑', '‒', '–', '—', '―':
+		return '-'
+	}
+	return unicode.ToLower(r)
+}
+
+// commonSuffixLen counts the bytes shared by the ends of a and b
+func commonSuffixLen(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return n
+}
+
+// commonPrefixLen counts the bytes shared by the starts of a and b
+func commonPrefixLen(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,486 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/classius/server/internal/models"
+)
+
+// VocabularyService manages saved words and their review schedule
+type VocabularyService struct {
+	db *gorm.DB
+}
+
+// NewVocabularyService creates a new vocabulary service
+func NewVocabularyService(db *gorm.DB) *VocabularyService {
+	return &VocabularyService{db: db}
+}
+
+// AddWordRequest saves a word to the vocabulary list. When LookupID is set,
+// empty fields are filled from the dictionary lookup.
+type AddWordRequest struct {
+	Word         string     `json:"word"`
+	Lemma        string     `json:"lemma"`
+	Language     string     `json:"language"`
+	PartOfSpeech string     `json:"part_of_speech"`
+	Definition   string     `json:"definition"`
+	Context      string     `json:"context"`
+	Notes        string     `json:"notes"`
+	BookID       *uuid.UUID `json:"book_id"`
+	Position     *int       `json:"position"`
+	PageNumber   int        `json:"page_number"`
+	LookupID     *uuid.UUID `json:"lookup_id"`
+	Algorithm    string     `json:"algorithm"` // sm2 (default) or fsrs
+}
+
+// UpdateWordRequest changes the editable fields of a saved word
+type UpdateWordRequest struct {
+	Definition *string `json:"definition"`
+	Context    *string `json:"context"`
+	Notes      *string `json:"notes"`
+	Suspended  *bool   `json:"suspended"`
+}
+
+// VocabularyFilter filters the vocabulary list
+type VocabularyFilter struct {
+	UserID   uuid.UUID
+	BookID   *uuid.UUID
+	Language string
+	State    string
+	Query    string
+	Limit    int
+	Offset   int
+}
+
+// ReviewQueue is the set of words to review now
+type ReviewQueue struct {
+	DueCount int64                   `json:"due_count"`
+	NewCount int64                   `json:"new_count"`
+	Words    []models.VocabularyWord `json:"words"`
+}
+
+// DailyReviews counts reviews on one day
+type DailyReviews struct {
+	Date    string `json:"date"`
+	Reviews int    `json:"reviews"`
+	Correct int    `json:"correct"`
+}
+
+// DailyForecast counts words falling due on one day
+type DailyForecast struct {
+	Date string `json:"date"`
+	Due  int    `json:"due"`
+}
+
+// VocabularyStats summarises the vocabulary list and review history
+type VocabularyStats struct {
+	TotalWords        int64            `json:"total_words"`
+	ByState           map[string]int64 `json:"by_state"`
+	Suspended         int64            `json:"suspended"`
+	Mature            int64            `json:"mature"` // Interval of 21 days or more
+	DueNow            int64            `json:"due_now"`
+	ReviewsToday      int64            `json:"reviews_today"`
+	ReviewsLast30Days int64            `json:"reviews_last_30_days"`
+	Retention         float64          `json:"retention"`          // Share of mature-state reviews recalled, last 30 days
+	LearningRetention float64          `json:"learning_retention"` // Share of new/learning reviews passed, last 30 days
+	AverageEase       float64          `json:"average_ease"`
+	AverageStability  float64          `json:"average_stability"`
+	Daily             []DailyReviews   `json:"daily"`
+	Forecast          []DailyForecast  `json:"forecast"`
+}
+
+// AddWord saves a word with its context and schedules it for review
+func (s *VocabularyService) AddWord(ctx context.Context, userID uuid.UUID, req AddWordRequest) (*models.VocabularyWord, error) {
+	if req.LookupID != nil {
+		var lookup models.WordLookup
+		if err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", *req.LookupID, userID).First(&lookup).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, fmt.Errorf("lookup %w", ErrNotFound)
+			}
+			return nil, fmt.Errorf("failed to retrieve lookup: %w", err)
+		}
+		req.Word = firstNonEmpty(req.Word, lookup.Word)
+		req.Lemma = firstNonEmpty(req.Lemma, lookup.Lemma)
+		req.Language = firstNonEmpty(req.Language, lookup.Language)
+		req.PartOfSpeech = firstNonEmpty(req.PartOfSpeech, lookup.PartOfSpeech)
+		req.Definition = firstNonEmpty(req.Definition, lookup.Definition)
+		req.Context = firstNonEmpty(req.Context, lookup.Context)
+		if req.BookID == nil {
+			req.BookID = lookup.BookID
+		}
+		if req.Position == nil {
+			req.Position = lookup.Position
+		}
+		if req.PageNumber == 0 {
+			req.PageNumber = lookup.PageNumber
+		}
+	}
+
+	req.Word = strings.TrimSpace(req.Word)
+	if req.Word == "" {
+		return nil, fmt.Errorf("%w: word is required", ErrInvalid)
+	}
+	if req.Algorithm != "" && !ValidSchedulerAlgorithm(req.Algorithm) {
+		return nil, fmt.Errorf("%w scheduler algorithm %q", ErrInvalid, req.Algorithm)
+	}
+	if req.Language == "" {
+		req.Language = DetectLanguage(req.Word, LangEnglish)
+	}
+	req.Language = NormalizeLanguage(req.Language)
+	if req.Lemma == "" {
+		req.Lemma = NormalizeForLanguage(req.Word, req.Language)
+	}
+
+	if req.BookID != nil {
+		var count int64
+		if err := s.db.WithContext(ctx).Model(&models.Book{}).
+			Where("id = ? AND user_id = ?", *req.BookID, userID).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to verify book: %w", err)
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("book %w", ErrNotFound)
+		}
+
+		if req.Context == "" && req.Position != nil {
+			var content models.BookContent
+			if err := s.db.WithContext(ctx).Where("book_id = ?", *req.BookID).First(&content).Error; err == nil {
+				req.Context = sentenceAround([]rune(content.FullText), *req.Position, req.Language)
+			}
+		}
+	}
+
+	var existing int64
+	s.db.WithContext(ctx).Model(&models.VocabularyWord{}).
+		Where("user_id = ? AND lemma = ? AND language = ?", userID, req.Lemma, req.Language).
+		Count(&existing)
+	if existing > 0 {
+		return nil, fmt.Errorf("%w: word already in vocabulary", ErrConflict)
+	}
+
+	word := &models.VocabularyWord{
+		UserID:         userID,
+		BookID:         req.BookID,
+		LookupID:       req.LookupID,
+		Word:           truncateRunes(req.Word, 100),
+		Lemma:          truncateRunes(req.Lemma, 100),
+		Language:       req.Language,
+		PartOfSpeech:   truncateRunes(req.PartOfSpeech, 30),
+		Definition:     req.Definition,
+		Context:        req.Context,
+		Notes:          req.Notes,
+		Position:       req.Position,
+		PageNumber:     req.PageNumber,
+		ReviewSchedule: NewReviewSchedule(req.Algorithm, time.Now()),
+	}
+	if err := s.db.WithContext(ctx).Create(word).Error; err != nil {
+		return nil, fmt.Errorf("failed to save word: %w", err)
+	}
+
+	return word, nil
+}
+
+// GetWord retrieves a saved word
+func (s *VocabularyService) GetWord(ctx context.Context, userID, wordID uuid.UUID) (*models.VocabularyWord, error) {
+	var word models.VocabularyWord
+	if err := s.db.WithContext(ctx).Preload("Book").
+		Where("id = ? AND user_id = ?", wordID, userID).
+		First(&word).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("word %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to retrieve word: %w", err)
+	}
+	return &word, nil
+}
+
+// ListWords returns saved words matching a filter, most recent first
+func (s *VocabularyService) ListWords(ctx context.Context, filter VocabularyFilter) ([]models.VocabularyWord, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.VocabularyWord{}).Where("user_id = ?", filter.UserID)
+	if filter.BookID != nil {
+		query = query.Where("book_id = ?", *filter.BookID)
+	}
+	if filter.Language != "" {
+		query = query.Where("language = ?", NormalizeLanguage(filter.Language))
+	}
+	if filter.State != "" {
+		query = query.Where("state = ?", filter.State)
+	}
+	if filter.Query != "" {
+		term := "%" + strings.ToLower(filter.Query) + "%"
+		query = query.Where("LOWER(word) LIKE ? OR LOWER(lemma) LIKE ? OR LOWER(definition) LIKE ?", term, term, term)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count words: %w", err)
+	}
+
+	var words []models.VocabularyWord
+	q := query.Preload("Book").Order("created_at DESC")
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit).Offset(filter.Offset)
+	}
+	if err := q.Find(&words).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve words: %w", err)
+	}
+	return words, total, nil
+}
+
+// UpdateWord changes a saved word's definition, context, notes or suspension
+func (s *VocabularyService) UpdateWord(ctx context.Context, userID, wordID uuid.UUID, req UpdateWordRequest) (*models.VocabularyWord, error) {
+	word, err := s.GetWord(ctx, userID, wordID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if req.Definition != nil {
+		updates["definition"] = *req.Definition
+	}
+	if req.Context != nil {
+		updates["context"] = *req.Context
+	}
+	if req.Notes != nil {
+		updates["notes"] = *req.Notes
+	}
+	if req.Suspended != nil {
+		updates["suspended"] = *req.Suspended
+	}
+	if len(updates) > 0 {
+		if err := s.db.WithContext(ctx).Model(word).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("failed to update word: %w", err)
+		}
+	}
+
+	return s.GetWord(ctx, userID, wordID)
+}
+
+// DeleteWord removes a saved word and its review history
+func (s *VocabularyService) DeleteWord(ctx context.Context, userID, wordID uuid.UUID) error {
+	if _, err := s.GetWord(ctx, userID, wordID); err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("word_id = ?", wordID).Delete(&models.VocabularyReview{}).Error; err != nil {
+			return fmt.Errorf("failed to delete reviews: %w", err)
+		}
+		if err := tx.Delete(&models.VocabularyWord{}, "id = ?", wordID).Error; err != nil {
+			return fmt.Errorf("failed to delete word: %w", err)
+		}
+		return nil
+	})
+}
+
+// ReviewQueue returns words due for review, oldest due first, followed by
+// up to newLimit words that have never been reviewed
+func (s *VocabularyService) ReviewQueue(ctx context.Context, userID uuid.UUID, bookID *uuid.UUID, language string, limit, newLimit int) (*ReviewQueue, error) {
+	now := time.Now()
+	base := func() *gorm.DB {
+		q := s.db.WithContext(ctx).Model(&models.VocabularyWord{}).
+			Where("user_id = ? AND suspended = ?", userID, false)
+		if bookID != nil {
+			q = q.Where("book_id = ?", *bookID)
+		}
+		if language != "" {
+			q = q.Where("language = ?", NormalizeLanguage(language))
+		}
+		return q
+	}
+
+	queue := &ReviewQueue{Words: []models.VocabularyWord{}}
+	if err := base().Where("state <> ? AND due_at <= ?", models.ReviewStateNew, now).Count(&queue.DueCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count due words: %w", err)
+	}
+	if err := base().Where("state = ?", models.ReviewStateNew).Count(&queue.NewCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count new words: %w", err)
+	}
+
+	var due []models.VocabularyWord
+	if err := base().Preload("Book").
+		Where("state <> ? AND due_at <= ?", models.ReviewStateNew, now).
+		Order("due_at ASC").Limit(limit).Find(&due).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve due words: %w", err)
+	}
+	queue.Words = append(queue.Words, due...)
+
+	if remaining := limit - len(due); remaining > 0 && newLimit > 0 {
+		if newLimit < remaining {
+			remaining = newLimit
+		}
+		var fresh []models.VocabularyWord
+		if err := base().Preload("Book").
+			Where("state = ?", models.ReviewStateNew).
+			Order("created_at ASC").Limit(remaining).Find(&fresh).Error; err != nil {
+			return nil, fmt.Errorf("failed to retrieve new words: %w", err)
+		}
+		queue.Words = append(queue.Words, fresh...)
+	}
+
+	return queue, nil
+}
+
+// GradeWord records a review and reschedules the word
+func (s *VocabularyService) GradeWord(ctx context.Context, userID, wordID uuid.UUID, grade, durationMs int) (*models.VocabularyWord, error) {
+	if !ValidGrade(grade) {
+		return nil, fmt.Errorf("%w grade %d: expected 1 (again) to 4 (easy)", ErrInvalid, grade)
+	}
+
+	word, err := s.GetWord(ctx, userID, wordID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	review := &models.VocabularyReview{
+		WordID:      word.ID,
+		UserID:      userID,
+		Grade:       grade,
+		Algorithm:   word.Algorithm,
+		StateBefore: word.State,
+		ElapsedDays: elapsedDays(&word.ReviewSchedule, now),
+		DurationMs:  durationMs,
+		ReviewedAt:  now,
+	}
+
+	SchedulerFor(word.Algorithm).Schedule(&word.ReviewSchedule, grade, now)
+	review.IntervalDays = word.IntervalDays
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.VocabularyWord{}).Where("id = ?", word.ID).Updates(map[string]interface{}{
+			"state":            word.State,
+			"due_at":           word.DueAt,
+			"interval_days":    word.IntervalDays,
+			"ease_factor":      word.EaseFactor,
+			"stability":        word.Stability,
+			"difficulty":       word.Difficulty,
+			"repetitions":      word.Repetitions,
+			"lapses":           word.Lapses,
+			"last_reviewed_at": word.LastReviewedAt,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update schedule: %w", err)
+		}
+		if err := tx.Create(review).Error; err != nil {
+			return fmt.Errorf("failed to record review: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return word, nil
+}
+
+// Stats computes retention and workload statistics for a user's vocabulary
+func (s *VocabularyService) Stats(ctx context.Context, userID uuid.UUID) (*VocabularyStats, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthAgo := today.AddDate(0, 0, -29)
+
+	stats := &VocabularyStats{ByState: make(map[string]int64)}
+	words := func() *gorm.DB {
+		return s.db.WithContext(ctx).Model(&models.VocabularyWord{}).Where("user_id = ?", userID)
+	}
+	reviews := func() *gorm.DB {
+		return s.db.WithContext(ctx).Model(&models.VocabularyReview{}).Where("user_id = ?", userID)
+	}
+
+	if err := words().Count(&stats.TotalWords).Error; err != nil {
+		return nil, fmt.Errorf("failed to count words: %w", err)
+	}
+
+	var byState []struct {
+		State string
+		Count int64
+	}
+	words().Select("state, COUNT(*) AS count").Group("state").Scan(&byState)
+	for _, row := range byState {
+		stats.ByState[row.State] = row.Count
+	}
+
+	words().Where("suspended = ?", true).Count(&stats.Suspended)
+	words().Where("state = ? AND interval_days >= ?", models.ReviewStateReview, 21).Count(&stats.Mature)
+	words().Where("suspended = ? AND state <> ? AND due_at <= ?", false, models.ReviewStateNew, now).Count(&stats.DueNow)
+	reviews().Where("reviewed_at >= ?", today).Count(&stats.ReviewsToday)
+	reviews().Where("reviewed_at >= ?", monthAgo).Count(&stats.ReviewsLast30Days)
+
+	var averages struct {
+		Ease      float64
+		Stability float64
+	}
+	words().Where("state = ?", models.ReviewStateReview).
+		Select("COALESCE(AVG(ease_factor), 0) AS ease, COALESCE(AVG(stability), 0) AS stability").
+		Scan(&averages)
+	stats.AverageEase = averages.Ease
+	stats.AverageStability = averages.Stability
+
+	var outcomes []struct {
+		StateBefore string
+		Grade       int
+		Count       int
+	}
+	reviews().Where("reviewed_at >= ?", monthAgo).
+		Select("state_before, grade, COUNT(*) AS count").
+		Group("state_before, grade").Scan(&outcomes)
+	var matureTotal, matureCorrect, learningTotal, learningCorrect int
+	for _, row := range outcomes {
+		if row.StateBefore == models.ReviewStateReview {
+			matureTotal += row.Count
+			if row.Grade > models.GradeAgain {
+				matureCorrect += row.Count
+			}
+		} else {
+			learningTotal += row.Count
+			if row.Grade > models.GradeAgain {
+				learningCorrect += row.Count
+			}
+		}
+	}
+	if matureTotal > 0 {
+		stats.Retention = float64(matureCorrect) / float64(matureTotal)
+	}
+	if learningTotal > 0 {
+		stats.LearningRetention = float64(learningCorrect) / float64(learningTotal)
+	}
+
+	var daily []struct {
+		Day     time.Time
+		Reviews int
+		Correct int
+	}
+	reviews().Where("reviewed_at >= ?", monthAgo).
+		Select("DATE(reviewed_at) AS day, COUNT(*) AS reviews, SUM(CASE WHEN grade > 1 THEN 1 ELSE 0 END) AS correct").
+		Group("DATE(reviewed_at)").Order("day ASC").Scan(&daily)
+	for _, row := range daily {
+		stats.Daily = append(stats.Daily, DailyReviews{Date: row.Day.Format("2006-01-02"), Reviews: row.Reviews, Correct: row.Correct})
+	}
+
+	var forecast []struct {
+		Day time.Time
+		Due int
+	}
+	words().Where("suspended = ? AND state <> ? AND due_at < ?", false, models.ReviewStateNew, today.AddDate(0, 0, 7)).
+		Select("DATE(GREATEST(due_at, ?)) AS day, COUNT(*) AS due", today).
+		Group("day").Order("day ASC").Scan(&forecast)
+	for _, row := range forecast {
+		stats.Forecast = append(stats.Forecast, DailyForecast{Date: row.Day.Format("2006-01-02"), Due: row.Due})
+	}
+
+	return stats, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
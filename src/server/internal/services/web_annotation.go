@@ -0,0 +1,639 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/classius/server/internal/models"
+)
+
+// W3C Web Annotation Data Model (https://www.w3.org/TR/annotation-model/)
+const (
+	WebAnnotationContext     = "http://www.w3.org/ns/anno.jsonld"
+	WebAnnotationContentType = `application/ld+json; profile="http://www.w3.org/ns/anno.jsonld"`
+
+	// Namespace of the annotation properties the model has no term for
+	// (color, page, privacy), so round trips are lossless
+	classiusNamespace = "https://classius.app/ns/annotation#"
+
+	quoteContextLength = 32 // Runes of prefix and suffix in TextQuoteSelectors
+)
+
+// Annotation types and their W3C motivations
+var webAnnotationMotivations = map[string]string{
+	"highlight": "highlighting",
+	"note":      "commenting",
+	"bookmark":  "bookmarking",
+}
+
+// WebAnnotationService converts annotations to and from W3C Web Annotations
+type WebAnnotationService struct {
+	db *gorm.DB
+}
+
+// NewWebAnnotationService creates a new web annotation service
+func NewWebAnnotationService(db *gorm.DB) *WebAnnotationService {
+	return &WebAnnotationService{db: db}
+}
+
+// WebAnnotationCollection is an exported set of annotations, embedded in
+// a single page
+type WebAnnotationCollection struct {
+	Context   []interface{}     `json:"@context"`
+	Type      string            `json:"type"`
+	Label     string            `json:"label"`
+	Total     int               `json:"total"`
+	Generated time.Time         `json:"generated"`
+	First     WebAnnotationPage `json:"first"`
+}
+
+// WebAnnotationPage is a page of a WebAnnotationCollection
+type WebAnnotationPage struct {
+	Type       string          `json:"type"`
+	StartIndex int             `json:"startIndex"`
+	Items      []WebAnnotation `json:"items"`
+}
+
+// WebAnnotation is an annotation in the W3C Web Annotation Data Model
+type WebAnnotation struct {
+	ID         string              `json:"id"`
+	Type       string              `json:"type"`
+	Motivation string              `json:"motivation"`
+	Created    time.Time           `json:"created"`
+	Modified   time.Time           `json:"modified"`
+	Body       []WebAnnotationBody `json:"body,omitempty"`
+	Target     WebAnnotationTarget `json:"target"`
+	Color      string              `json:"classius:color,omitempty"`
+	Page       int                 `json:"classius:page,omitempty"`
+	Private    bool                `json:"classius:private"`
+}
+
+// WebAnnotationBody is a TextualBody: the note, or a tag
+type WebAnnotationBody struct {
+	Type    string `json:"type"`
+	Value   string `json:"value"`
+	Purpose string `json:"purpose,omitempty"` // commenting, tagging
+	Format  string `json:"format,omitempty"`
+}
+
+// WebAnnotationTarget is the passage of a book an annotation is about
+type WebAnnotationTarget struct {
+	Source   string                  `json:"source"` // urn:uuid of the book
+	Selector []WebAnnotationSelector `json:"selector,omitempty"`
+	Title    string                  `json:"classius:title,omitempty"`
+	ISBN     string                  `json:"classius:isbn,omitempty"`
+}
+
+// WebAnnotationSelector is a TextQuoteSelector, TextPositionSelector or,
+// for area and ink annotations, an SvgSelector
+type WebAnnotationSelector struct {
+	Type   string `json:"type"`
+	Exact  string `json:"exact,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Suffix string `json:"suffix,omitempty"`
+	Start  *int   `json:"start,omitempty"`
+	End    *int   `json:"end,omitempty"`
+	Value  string `json:"value,omitempty"` // SVG of the page areas and ink
+}
+
+// WebAnnotationImportResult summarizes an import
+type WebAnnotationImportResult struct {
+	Imported    int         `json:"imported"`
+	Duplicates  int         `json:"duplicates"`
+	Skipped     int         `json:"skipped"`
+	Unanchored  int         `json:"unanchored"` // Imported without a position in the book's text
+	Errors      []string    `json:"errors,omitempty"`
+	Annotations []uuid.UUID `json:"annotations"`
+}
+
+// webAnnotationInput accepts the forms the model allows: single values or
+// arrays, and embedded or string bodies and targets
+type webAnnotationInput struct {
+	ID         string          `json:"id"`
+	Motivation json.RawMessage `json:"motivation"`
+	Created    *time.Time      `json:"created"`
+	Modified   *time.Time      `json:"modified"`
+	Body       json.RawMessage `json:"body"`
+	BodyValue  string          `json:"bodyValue"`
+	Target     json.RawMessage `json:"target"`
+	Color      string          `json:"classius:color"`
+	Page       int             `json:"classius:page"`
+	Private    bool            `json:"classius:private"`
+}
+
+type webAnnotationTargetInput struct {
+	Source   string          `json:"source"`
+	Selector json.RawMessage `json:"selector"`
+	Title    string          `json:"classius:title"`
+	ISBN     string          `json:"classius:isbn"`
+}
+
+// Export returns the user's annotations as a W3C annotation collection
+func (s *WebAnnotationService) Export(ctx context.Context, userID uuid.UUID, bookID *uuid.UUID, annotationType string) (*WebAnnotationCollection, error) {
+	query := s.db.WithContext(ctx).Preload("Book").
+		Joins("JOIN books ON books.id = annotations.book_id AND books.deleted_at IS NULL").
+		Where("annotations.user_id = ?", userID)
+	if bookID != nil {
+		query = query.Where("annotations.book_id = ?", *bookID)
+	}
+	if annotationType != "" {
+		query = query.Where("annotations.type = ?", annotationType)
+	}
+
+	var annotations []models.Annotation
+	if err := query.Order("annotations.book_id, annotations.start_position, annotations.created_at").
+		Find(&annotations).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve annotations: %w", err)
+	}
+
+	locators := make(map[uuid.UUID]*QuoteLocator)
+	collection := &WebAnnotationCollection{
+		Context:   []interface{}{WebAnnotationContext, map[string]string{"classius": classiusNamespace}},
+		Type:      "AnnotationCollection",
+		Label:     "Classius annotations",
+		Total:     len(annotations),
+		Generated: time.Now(),
+		First:     WebAnnotationPage{Type: "AnnotationPage", Items: []WebAnnotation{}},
+	}
+	for i := range annotations {
+		a := &annotations[i]
+		locator, ok := locators[a.BookID]
+		if !ok {
+			locator, _ = s.loadLocator(ctx, a.BookID)
+			locators[a.BookID] = locator
+		}
+		collection.First.Items = append(collection.First.Items, ToWebAnnotation(a, locator))
+	}
+	return collection, nil
+}
+
+// ToWebAnnotation converts an annotation. locator, when the book's text is
+// available, supplies the quote's prefix and suffix.
+func ToWebAnnotation(a *models.Annotation, locator *QuoteLocator) WebAnnotation {
+	motivation, ok := webAnnotationMotivations[a.Type]
+	if !ok {
+		motivation = "commenting"
+	}
+	w := WebAnnotation{
+		ID:         "urn:uuid:" + a.ID.String(),
+		Type:       "Annotation",
+		Motivation: motivation,
+		Created:    a.CreatedAt,
+		Modified:   a.UpdatedAt,
+		Target: WebAnnotationTarget{
+			Source: "urn:uuid:" + a.BookID.String(),
+			Title:  a.Book.Title,
+			ISBN:   a.Book.ISBN,
+		},
+		Color:   a.Color,
+		Page:    a.PageNumber,
+		Private: a.IsPrivate,
+	}
+
+	if a.Content != "" {
+		w.Body = append(w.Body, WebAnnotationBody{Type: "TextualBody", Value: a.Content, Purpose: "commenting", Format: "text/plain"})
+	}
+	for _, tag := range a.Tags {
+		w.Body = append(w.Body, WebAnnotationBody{Type: "TextualBody", Value: tag, Purpose: "tagging"})
+	}
+
+	exact := a.SelectedText
+	positioned := a.EndPosition > a.StartPosition && a.StartPosition >= 0
+	if positioned && locator != nil && a.EndPosition > len(locator.Text()) {
+		positioned = false
+	}
+	if exact == "" && positioned && locator != nil {
+		exact = runeSlice(locator.Text(), a.StartPosition, a.EndPosition)
+	}
+	if exact != "" {
+		quote := WebAnnotationSelector{Type: "TextQuoteSelector", Exact: exact}
+		if positioned && locator != nil {
+			quote.Prefix, quote.Suffix = locator.Context(a.StartPosition, a.EndPosition, quoteContextLength)
+		}
+		w.Target.Selector = append(w.Target.Selector, quote)
+	}
+	if positioned || (a.Type == "bookmark" && a.StartPosition > 0) {
+		start, end := a.StartPosition, a.EndPosition
+		if end < start {
+			end = start
+		}
+		w.Target.Selector = append(w.Target.Selector, WebAnnotationSelector{Type: "TextPositionSelector", Start: &start, End: &end})
+	}
+	if HasShape(a) {
+		w.Target.Selector = append(w.Target.Selector, WebAnnotationSelector{Type: "SvgSelector", Value: string(RenderAnnotationSVG(a))})
+	}
+	return w
+}
+
+// Import creates annotations from a W3C annotation document: a single
+// annotation, an array, an AnnotationPage or an AnnotationCollection with
+// embedded pages. Annotations already present, by ID or by content, are
+// skipped. Targets are matched to the user's books by ID, ISBN or title
+// unless bookID is given.
+func (s *WebAnnotationService) Import(ctx context.Context, userID uuid.UUID, bookID *uuid.UUID, document []byte) (*WebAnnotationImportResult, error) {
+	items, err := webAnnotationItems(document)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.db.WithContext(ctx)
+	result := &WebAnnotationImportResult{Annotations: []uuid.UUID{}}
+	resolver := &importBookResolver{ctx: ctx, db: s.db, userID: userID, bookID: bookID, books: make(map[string]*models.Book), locators: make(map[uuid.UUID]*QuoteLocator), service: s}
+	seen := make(map[string]bool)
+	seenIDs := make(map[uuid.UUID]bool)
+	var annotations []models.Annotation
+
+	for i, raw := range items {
+		annotation, unanchored, err := s.fromWebAnnotation(resolver, raw)
+		if err != nil {
+			result.Skipped++
+			result.Errors = append(result.Errors, fmt.Sprintf("item %d: %v", i+1, err))
+			continue
+		}
+
+		duplicate, err := s.isDuplicate(db, userID, annotation)
+		if err != nil {
+			return nil, err
+		}
+		key := annotationDedupKey(annotation)
+		if duplicate || seen[key] {
+			result.Duplicates++
+			continue
+		}
+		seen[key] = true
+		if annotation.ID != uuid.Nil {
+			if seenIDs[annotation.ID] {
+				annotation.ID = uuid.Nil
+			}
+			seenIDs[annotation.ID] = true
+		}
+		if unanchored {
+			result.Unanchored++
+		}
+		annotations = append(annotations, *annotation)
+	}
+
+	if len(annotations) > 0 {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			ids := make([]uuid.UUID, 0, len(annotations))
+			for i := range annotations {
+				if err := tx.Create(&annotations[i]).Error; err != nil {
+					return fmt.Errorf("failed to create annotation: %w", err)
+				}
+				ids = append(ids, annotations[i].ID)
+			}
+			return RecordAnnotationChange(tx, userID, ids)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, a := range annotations {
+		result.Annotations = append(result.Annotations, a.ID)
+	}
+	result.Imported = len(annotations)
+	return result, nil
+}
+
+// webAnnotationItems extracts the annotations of a document
+func webAnnotationItems(document []byte) ([]json.RawMessage, error) {
+	document = bytes.TrimSpace(document)
+	if len(document) == 0 {
+		return nil, fmt.Errorf("%w document: empty", ErrInvalid)
+	}
+	if document[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(document, &items); err != nil {
+			return nil, fmt.Errorf("%w document: %w", ErrInvalid, err)
+		}
+		return items, nil
+	}
+
+	var container struct {
+		Type  json.RawMessage   `json:"type"`
+		Items []json.RawMessage `json:"items"`
+		First json.RawMessage   `json:"first"`
+	}
+	if err := json.Unmarshal(document, &container); err != nil {
+		return nil, fmt.Errorf("%w document: %w", ErrInvalid, err)
+	}
+	types := jsonStrings(container.Type)
+	switch {
+	case containsString(types, "AnnotationCollection"):
+		if len(container.First) == 0 {
+			return []json.RawMessage{}, nil
+		}
+		if container.First[0] == '"' {
+			return nil, fmt.Errorf("%w document: collection pages must be embedded", ErrInvalid)
+		}
+		return webAnnotationItems(container.First)
+	case containsString(types, "AnnotationPage"):
+		return container.Items, nil
+	default:
+		return []json.RawMessage{document}, nil
+	}
+}
+
+// fromWebAnnotation converts one W3C annotation. It reports whether the
+// annotation could not be positioned in the book's text.
+func (s *WebAnnotationService) fromWebAnnotation(resolver *importBookResolver, raw json.RawMessage) (*models.Annotation, bool, error) {
+	var in webAnnotationInput
+	if err := json.Unmarshal(raw, &in); err != nil {
+		return nil, false, fmt.Errorf("%w annotation: %w", ErrInvalid, err)
+	}
+
+	var target webAnnotationTargetInput
+	targets := jsonObjects(in.Target)
+	if len(targets) == 0 {
+		if sources := jsonStrings(in.Target); len(sources) > 0 {
+			target.Source = sources[0]
+		} else {
+			return nil, false, fmt.Errorf("%w: target is required", ErrInvalid)
+		}
+	} else if err := json.Unmarshal(targets[0], &target); err != nil {
+		return nil, false, fmt.Errorf("%w target: %w", ErrInvalid, err)
+	}
+
+	book, err := resolver.resolve(target)
+	if err != nil {
+		return nil, false, err
+	}
+
+	annotation := &models.Annotation{
+		UserID:     resolver.userID,
+		BookID:     book.ID,
+		Color:      in.Color,
+		PageNumber: in.Page,
+		IsPrivate:  in.Private,
+		Tags:       []string{},
+	}
+	now := time.Now()
+	annotation.ImportSource, annotation.ImportRef, annotation.ImportedAt = ImportSourceW3C, in.ID, &now
+	if id, ok := uuidFromURN(in.ID); ok {
+		annotation.ID = id
+	}
+	if in.Created != nil {
+		annotation.CreatedAt = *in.Created
+	}
+	if in.Modified != nil {
+		annotation.UpdatedAt = *in.Modified
+	}
+
+	// Bodies: tags and note text
+	var notes []string
+	if in.BodyValue != "" {
+		notes = append(notes, in.BodyValue)
+	}
+	for _, rawBody := range jsonObjects(in.Body) {
+		var body struct {
+			Value   string          `json:"value"`
+			Purpose json.RawMessage `json:"purpose"`
+		}
+		if err := json.Unmarshal(rawBody, &body); err != nil || strings.TrimSpace(body.Value) == "" {
+			continue
+		}
+		if containsString(jsonStrings(body.Purpose), "tagging") {
+			tag := strings.TrimSpace(body.Value)
+			if !containsString(annotation.Tags, tag) {
+				annotation.Tags = append(annotation.Tags, tag)
+			}
+			continue
+		}
+		notes = append(notes, body.Value)
+	}
+	annotation.Content = strings.Join(notes, "\n\n")
+	annotation.Type = annotationTypeForMotivation(jsonStrings(in.Motivation), annotation.Content != "")
+
+	// Selectors
+	var quote, position *WebAnnotationSelector
+	for _, rawSelector := range jsonObjects(target.Selector) {
+		var selector WebAnnotationSelector
+		if err := json.Unmarshal(rawSelector, &selector); err != nil {
+			continue
+		}
+		switch selector.Type {
+		case "TextQuoteSelector":
+			if quote == nil {
+				quote = &selector
+			}
+		case "TextPositionSelector":
+			if position == nil && selector.Start != nil && selector.End != nil {
+				position = &selector
+			}
+		}
+	}
+	if quote != nil {
+		annotation.SelectedText = quote.Exact
+	}
+	if position != nil {
+		annotation.StartPosition, annotation.EndPosition = *position.Start, *position.End
+	}
+
+	locator := resolver.locator(book.ID)
+	if locator == nil {
+		return annotation, position == nil && quote != nil, nil
+	}
+	if quote == nil || quote.Exact == "" {
+		if position != nil && annotation.EndPosition > annotation.StartPosition {
+			annotation.SelectedText = runeSlice(locator.Text(), annotation.StartPosition, annotation.EndPosition)
+		}
+		return annotation, false, nil
+	}
+	if position != nil && locator.Matches(annotation.StartPosition, annotation.EndPosition, quote.Exact) {
+		return annotation, false, nil
+	}
+
+	// The quote is authoritative: re-anchor it, preferring the occurrence
+	// nearest the given position
+	hint := -1
+	if position != nil {
+		hint = annotation.StartPosition
+	}
+	start, end, found := locator.Locate(quote.Exact, quote.Prefix, quote.Suffix, hint)
+	if !found {
+		return annotation, true, nil
+	}
+	annotation.StartPosition, annotation.EndPosition = start, end
+	return annotation, false, nil
+}
+
+// isDuplicate reports whether the annotation already exists, by ID or by content
+func (s *WebAnnotationService) isDuplicate(db *gorm.DB, userID uuid.UUID, a *models.Annotation) (bool, error) {
+	if a.ID != uuid.Nil {
+		var existing models.Annotation
+		result := db.Unscoped().Select("id", "user_id", "deleted_at").Where("id = ?", a.ID).Limit(1).Find(&existing)
+		if result.Error != nil {
+			return false, fmt.Errorf("failed to check for duplicates: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			if existing.UserID == userID && !existing.DeletedAt.Valid {
+				return true, nil
+			}
+			// Taken by another user or by a deleted annotation
+			a.ID = uuid.Nil
+		}
+	}
+
+	var count int64
+	if err := db.Model(&models.Annotation{}).
+		Where("user_id = ? AND book_id = ? AND type = ? AND start_position = ? AND end_position = ? AND selected_text = ? AND content = ?",
+			userID, a.BookID, a.Type, a.StartPosition, a.EndPosition, a.SelectedText, a.Content).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check for duplicates: %w", err)
+	}
+	return count > 0, nil
+}
+
+func annotationDedupKey(a *models.Annotation) string {
+	return fmt.Sprintf("%s|%s|%d|%d|%s|%s", a.BookID, a.Type, a.StartPosition, a.EndPosition, a.SelectedText, a.Content)
+}
+
+// annotationTypeForMotivation maps W3C motivations to annotation types
+func annotationTypeForMotivation(motivations []string, hasNote bool) string {
+	for _, motivation := range motivations {
+		switch strings.TrimPrefix(motivation, "oa:") {
+		case "bookmarking":
+			return "bookmark"
+		case "commenting", "describing", "questioning", "replying":
+			return "note"
+		case "highlighting":
+			if !hasNote {
+				return "highlight"
+			}
+		}
+	}
+	if hasNote {
+		return "note"
+	}
+	return "highlight"
+}
+
+// loadLocator returns a locator over the book's text, or nil if the text
+// has not been extracted
+func (s *WebAnnotationService) loadLocator(ctx context.Context, bookID uuid.UUID) (*QuoteLocator, error) {
+	return bookLocator(s.db.WithContext(ctx), bookID)
+}
+
+// importBookResolver matches annotation targets to the user's books
+type importBookResolver struct {
+	ctx      context.Context
+	db       *gorm.DB
+	userID   uuid.UUID
+	bookID   *uuid.UUID
+	books    map[string]*models.Book
+	locators map[uuid.UUID]*QuoteLocator
+	service  *WebAnnotationService
+}
+
+func (r *importBookResolver) resolve(target webAnnotationTargetInput) (*models.Book, error) {
+	key := target.Source + "|" + target.ISBN + "|" + target.Title
+	if r.bookID != nil {
+		key = r.bookID.String()
+	}
+	if book, ok := r.books[key]; ok {
+		if book == nil {
+			return nil, fmt.Errorf("book %w for target %q", ErrNotFound, target.Source)
+		}
+		return book, nil
+	}
+
+	// Conditions to try in order, each a query and its argument
+	var conditions [][2]interface{}
+	if r.bookID != nil {
+		conditions = append(conditions, [2]interface{}{"id = ?", *r.bookID})
+	} else {
+		if id, ok := uuidFromURN(target.Source); ok {
+			conditions = append(conditions, [2]interface{}{"id = ?", id})
+		}
+		isbn := target.ISBN
+		if strings.HasPrefix(strings.ToLower(target.Source), "urn:isbn:") {
+			isbn = target.Source[len("urn:isbn:"):]
+		}
+		if isbn = normalizeISBN(isbn); isbn != "" {
+			conditions = append(conditions, [2]interface{}{"UPPER(REPLACE(REPLACE(isbn, '-', ''), ' ', '')) = ?", isbn})
+		}
+		if title := strings.TrimSpace(target.Title); title != "" {
+			conditions = append(conditions, [2]interface{}{"LOWER(title) = ?", strings.ToLower(title)})
+		}
+	}
+
+	var found *models.Book
+	for _, condition := range conditions {
+		var book models.Book
+		result := r.db.WithContext(r.ctx).Where("user_id = ?", r.userID).
+			Where(condition[0], condition[1]).Limit(1).Find(&book)
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to retrieve book: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			found = &book
+			break
+		}
+	}
+	r.books[key] = found
+	if found == nil {
+		return nil, fmt.Errorf("book %w for target %q", ErrNotFound, target.Source)
+	}
+	return found, nil
+}
+
+func (r *importBookResolver) locator(bookID uuid.UUID) *QuoteLocator {
+	locator, ok := r.locators[bookID]
+	if !ok {
+		locator, _ = r.service.loadLocator(r.ctx, bookID)
+		r.locators[bookID] = locator
+	}
+	return locator
+}
+
+// uuidFromURN parses urn:uuid: identifiers and bare UUIDs
+func uuidFromURN(s string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(strings.TrimPrefix(strings.TrimSpace(s), "urn:uuid:"))
+	return id, err == nil
+}
+
+func normalizeISBN(isbn string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(isbn)))
+}
+
+// jsonStrings decodes a JSON string or array of strings
+func jsonStrings(raw json.RawMessage) []string {
+	var one string
+	if err := json.Unmarshal(raw, &one); err == nil {
+		return []string{one}
+	}
+	var many []string
+	if err := json.Unmarshal(raw, &many); err == nil {
+		return many
+	}
+	return nil
+}
+
+// jsonObjects decodes a JSON object or array, returning its objects
+func jsonObjects(raw json.RawMessage) []json.RawMessage {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil
+	}
+	if raw[0] == '{' {
+		return []json.RawMessage{raw}
+	}
+	var many []json.RawMessage
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return nil
+	}
+	var objects []json.RawMessage
+	for _, item := range many {
+		if item = bytes.TrimSpace(item); len(item) > 0 && item[0] == '{' {
+			objects = append(objects, item)
+		}
+	}
+	return objects
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/classius/server/internal/models"
+)
+
+func TestQuoteLocator(t *testing.T) {
+	text := "Know thyself.\nThe unexamined life is not worth living.  Know   THYSELF, said the oracle."
+	locator := NewQuoteLocator(text)
+
+	start, end, ok := locator.Locate("know thyself", "", ", said", -1)
+	if !ok || string([]rune(text)[start:end]) != "Know   THYSELF" {
+		t.Fatalf("suffix should pick the second occurrence, got %q", string([]rune(text)[start:end]))
+	}
+	start, _, ok = locator.Locate("Know thyself", "", "", 0)
+	if !ok || start != 0 {
+		t.Fatalf("hint should pick the first occurrence, got %d", start)
+	}
+	start, end, ok = locator.Locate("thyself. The unexamined", "", "", -1)
+	if !ok || string([]rune(text)[start:end]) != "thyself.\nThe unexamined" {
+		t.Fatalf("whitespace should be ignored, got %q", string([]rune(text)[start:end]))
+	}
+	if _, _, ok := locator.Locate("the examined life", "", "", -1); ok {
+		t.Fatal("expected no match")
+	}
+	if !locator.Matches(start, end, "THYSELF. the unexamined") {
+		t.Fatal("Matches should ignore case and whitespace")
+	}
+}
+
+func TestToWebAnnotation(t *testing.T) {
+	locator := NewQuoteLocator("It is the mark of an educated mind to entertain a thought.")
+	a := &models.Annotation{
+		BaseModel:     models.BaseModel{ID: uuid.New()},
+		BookID:        uuid.New(),
+		Type:          "note",
+		StartPosition: 10,
+		EndPosition:   14,
+		Content:       "Aristotle, supposedly",
+		Tags:          []string{"ethics"},
+		Color:         "#ffff00",
+	}
+	w := ToWebAnnotation(a, locator)
+
+	if w.Motivation != "commenting" || len(w.Body) != 2 || w.Body[1].Purpose != "tagging" {
+		t.Fatalf("unexpected motivation or bodies: %+v", w)
+	}
+	if len(w.Target.Selector) != 2 {
+		t.Fatalf("expected quote and position selectors, got %+v", w.Target.Selector)
+	}
+	quote, position := w.Target.Selector[0], w.Target.Selector[1]
+	if quote.Exact != "mark" || quote.Prefix != "It is the " || quote.Suffix != " of an educated mind to entertai" {
+		t.Errorf("unexpected quote selector %+v", quote)
+	}
+	if *position.Start != 10 || *position.End != 14 {
+		t.Errorf("unexpected position selector %+v", position)
+	}
+}
+
+func TestWebAnnotationItems(t *testing.T) {
+	collection := `{"type": "AnnotationCollection", "first": {"type": "AnnotationPage", "items": [{"id": "a"}, {"id": "b"}]}}`
+	tests := []struct {
+		document string
+		count    int
+	}{
+		{collection, 2},
+		{`{"type": ["AnnotationPage"], "items": [{"id": "a"}]}`, 1},
+		{`[{"id": "a"}, {"id": "b"}, {"id": "c"}]`, 3},
+		{`{"type": "Annotation", "id": "a"}`, 1},
+	}
+	for _, tt := range tests {
+		items, err := webAnnotationItems([]byte(tt.document))
+		if err != nil || len(items) != tt.count {
+			t.Errorf("%s: got %d items, %v", tt.document, len(items), err)
+		}
+	}
+	if _, err := webAnnotationItems([]byte(`{"type": "AnnotationCollection", "first": "https://example.org/page1"}`)); err == nil {
+		t.Error("expected an error for a collection with a linked page")
+	}
+}
+
+func TestAnnotationTypeForMotivation(t *testing.T) {
+	tests := []struct {
+		motivations []string
+		hasNote     bool
+		want        string
+	}{
+		{[]string{"oa:highlighting"}, false, "highlight"},
+		{[]string{"highlighting"}, true, "note"},
+		{[]string{"commenting"}, false, "note"},
+		{[]string{"bookmarking"}, true, "bookmark"},
+		{[]string{"tagging"}, false, "highlight"},
+		{nil, true, "note"},
+	}
+	for _, tt := range tests {
+		if got := annotationTypeForMotivation(tt.motivations, tt.hasNote); got != tt.want {
+			t.Errorf("%v (note %v): got %s, want %s", tt.motivations, tt.hasNote, got, tt.want)
+		}
+	}
+}